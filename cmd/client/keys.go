@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"golang.org/x/term"
+)
+
+// runExportKeys implements `sc export-keys FILE`, which writes every stored
+// encryption key into one passphrase-protected backup file
+func runExportKeys(stateDir string, args []string) error {
+	fs := flag.NewFlagSet("export-keys", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s export-keys FILE\n\nWrite all DM encryption keys to a passphrase-protected backup file.\n", os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	keys, err := crypto.NewKeyStore(stateDir).ExportKeys()
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return errors.New("no encryption keys to export")
+	}
+
+	passphrase, err := readPassphrase("Backup passphrase: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return err
+	}
+	if passphrase != confirm {
+		return errors.New("passphrases don't match")
+	}
+
+	backup, err := crypto.EncryptKeyBackup(keys, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, backup, crypto.KeyFileMode); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	fmt.Printf("Exported %d key(s) to %s\n", len(keys), path)
+	return nil
+}
+
+// runImportKeys implements `sc import-keys FILE`, which restores the keys of
+// a backup made by export-keys. They are kept next to this machine's own
+// keys, which the client makes when it needs them.
+func runImportKeys(stateDir string, args []string) error {
+	fs := flag.NewFlagSet("import-keys", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s import-keys FILE\n\nRestore DM encryption keys from a backup made by export-keys, so messages\nencrypted to them can be read here. This machine keeps its own device key.\n", os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	backup, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	passphrase, err := readPassphrase("Backup passphrase: ")
+	if err != nil {
+		return err
+	}
+	keys, err := crypto.DecryptKeyBackup(backup, passphrase)
+	if err != nil {
+		return err
+	}
+
+	imported, err := crypto.NewKeyStore(stateDir).ImportKeys(keys)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d of %d key(s)\n", imported, len(keys))
+	return nil
+}
+
+// stdinReader reads passphrases piped into export-keys and import-keys
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassphrase prompts for a passphrase without echoing it, or reads a
+// line from stdin when it isn't a terminal
+func readPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdinReader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
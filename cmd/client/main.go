@@ -64,7 +64,7 @@ func main() {
 	// Command line flags
 	defaultConfig := getDefaultConfigPath()
 	configPath := flag.String("config", defaultConfig, "Path to config file")
-	server := flag.String("server", "", "Server address (host:port, sc://host:port, ssh://user@host:port, ws://host:port; default port varies by scheme)")
+	server := flag.String("server", "", "Server address (host:port, sc://host:port, sc+tls://host:port, ssh://user@host:port, ws://host:port; default port varies by scheme)")
 	directory := flag.String("directory", "", "Directory server address (host:port) to fetch server list from")
 	profile := flag.String("profile", "", "Profile name for separate configuration (default: none)")
 	statePath := flag.String("state", "", "Path to state database (overrides config)")
@@ -79,11 +79,22 @@ func main() {
 	}
 
 	// Handle subcommands
+	forgetCertAddr := ""
+	keyCommand := ""
 	if flag.NArg() > 0 {
 		switch flag.Arg(0) {
 		case "update":
 			handleUpdate()
 			return
+		case "forget-cert":
+			// Needs the state database, handled once it is open
+			if flag.NArg() < 2 {
+				log.Fatalf("Usage: sc forget-cert host:port")
+			}
+			forgetCertAddr = flag.Arg(1)
+		case "export-keys", "import-keys":
+			// Keys are stored next to the state database, handled once it is open
+			keyCommand = flag.Arg(0)
 		default:
 			log.Fatalf("Unknown command: %s", flag.Arg(0))
 		}
@@ -132,6 +143,27 @@ func main() {
 	}
 	defer state.Close()
 
+	if forgetCertAddr != "" {
+		if err := state.DeletePinnedCertificate(forgetCertAddr); err != nil {
+			log.Fatalf("Failed to forget TLS certificate for %s: %v", forgetCertAddr, err)
+		}
+		fmt.Printf("Forgot pinned TLS certificate for %s; it will be trusted again on next connect\n", forgetCertAddr)
+		return
+	}
+
+	switch keyCommand {
+	case "export-keys":
+		if err := runExportKeys(state.GetStateDir(), flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to export keys: %v", err)
+		}
+		return
+	case "import-keys":
+		if err := runImportKeys(state.GetStateDir(), flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to import keys: %v", err)
+		}
+		return
+	}
+
 	// Set up debug logger early (before determining connection address)
 	logger, logFile, err := setupLogger(state.GetStateDir())
 	if err != nil {
@@ -205,6 +237,7 @@ func main() {
 	if logger != nil {
 		c.SetLogger(logger)
 	}
+	c.SetCertificatePinStore(state)
 
 	// Apply bandwidth throttling if requested
 	if *throttle > 0 {
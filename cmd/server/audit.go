@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/server"
+)
+
+// auditEntry is the JSON form of an audit log entry
+type auditEntry struct {
+	ID               int64   `json:"id"`
+	PerformedAt      string  `json:"performed_at"`
+	AdminUserID      *int64  `json:"admin_user_id"`
+	AdminNickname    string  `json:"admin_nickname"`
+	ActionType       string  `json:"action_type"`
+	TargetType       string  `json:"target_type,omitempty"`
+	TargetID         *int64  `json:"target_id,omitempty"`
+	TargetIdentifier string  `json:"target_identifier,omitempty"`
+	Details          string  `json:"details,omitempty"`
+	IPAddress        *string `json:"ip_address,omitempty"`
+}
+
+// runAudit implements `scd audit`, which prints the admin audit log without
+// starting the server
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	configPath := fs.String("config", "~/.superchat/config.toml", "Path to config file")
+	dbPath := fs.String("db", "", "Path to SQLite database (overrides config)")
+	admin := fs.String("admin", "", "Only show actions by this admin")
+	action := fs.String("action", "", "Only show this action type (e.g. ban_user, DELETE_CHANNEL)")
+	since := fs.String("since", "", "Only show actions at or after this time (2006-01-02, RFC 3339, or a duration like 24h or 7d)")
+	until := fs.String("until", "", "Only show actions before this time (same formats as --since)")
+	limit := fs.Int("limit", 100, "Maximum number of entries to print (0 = all)")
+	jsonOutput := fs.Bool("json", false, "Print entries as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s audit [flags]\n\nPrint the admin audit log, newest first.\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	filter := database.AdminActionFilter{
+		AdminNickname: *admin,
+		ActionType:    *action,
+		Limit:         max(*limit, 0),
+	}
+	now := time.Now()
+	if *since != "" {
+		t, err := parseAuditTime(*since, now)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		filter.Since = t.UnixMilli()
+	}
+	if *until != "" {
+		t, err := parseAuditTime(*until, now)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+		filter.Until = t.UnixMilli()
+	}
+
+	store, err := openOfflineStore(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	actions, err := store.ListAdminActions(filter)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if *jsonOutput {
+		return writeAuditJSON(os.Stdout, actions)
+	}
+	return writeAuditText(os.Stdout, actions)
+}
+
+// openOfflineStore opens the database the server would use for the given
+// config, without seeding channels or starting the in-memory cache
+func openOfflineStore(configPath, dbPath string) (database.Store, error) {
+	target, err := offlineDatabase(configPath, dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if target.databaseURL != "" {
+		pgDB, err := database.OpenPostgres(target.databaseURL, target.workerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
+		}
+		return pgDB, nil
+	}
+
+	db, err := database.Open(target.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// offlineTarget is the database the server would use: a PostgreSQL database
+// or an existing SQLite file
+type offlineTarget struct {
+	databaseURL string // Empty for SQLite
+	workerID    int64  // The server's ID generator worker ID (PostgreSQL only)
+	path        string // SQLite file (empty for PostgreSQL)
+}
+
+// offlineDatabase finds the database the server would use for the given config
+func offlineDatabase(configPath, dbPath string) (offlineTarget, error) {
+	config, err := server.LoadConfig(configPath)
+	if err != nil {
+		return offlineTarget{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	if config.Server.DatabaseURL != "" {
+		if config.Server.WorkerID == nil {
+			return offlineTarget{}, fmt.Errorf("worker_id must be set when database_url is set")
+		}
+		return offlineTarget{databaseURL: config.Server.DatabaseURL, workerID: *config.Server.WorkerID}, nil
+	}
+
+	if dbPath != "" {
+		config.Server.DatabasePath = dbPath
+	}
+	path, err := config.GetDatabasePath()
+	if err != nil {
+		return offlineTarget{}, fmt.Errorf("failed to resolve database path: %w", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return offlineTarget{}, fmt.Errorf("database not found: %w", err)
+	}
+	return offlineTarget{path: path}, nil
+}
+
+// parseAuditTime accepts a date, an RFC 3339 timestamp, or a duration
+// before now ("24h", "7d")
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, RFC 3339 timestamp or duration", value)
+}
+
+// writeAuditText prints entries as an aligned table
+func writeAuditText(w io.Writer, actions []*database.AdminAction) error {
+	if len(actions) == 0 {
+		_, err := fmt.Fprintln(w, "No admin actions found")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tADMIN\tACTION\tTARGET\tDETAILS")
+	for _, action := range actions {
+		target := action.TargetIdentifier
+		if action.TargetType != "" && target != "" {
+			target = action.TargetType + ":" + target
+		}
+		if target == "" {
+			target = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			action.ID,
+			time.UnixMilli(action.PerformedAt).Local().Format("2006-01-02 15:04:05"),
+			action.AdminNickname,
+			action.ActionType,
+			target,
+			strings.Join(strings.Fields(action.Details), " "))
+	}
+	return tw.Flush()
+}
+
+// writeAuditJSON prints entries as a JSON array
+func writeAuditJSON(w io.Writer, actions []*database.AdminAction) error {
+	entries := make([]auditEntry, len(actions))
+	for i, action := range actions {
+		entries[i] = auditEntry{
+			ID:               action.ID,
+			PerformedAt:      time.UnixMilli(action.PerformedAt).UTC().Format(time.RFC3339),
+			AdminUserID:      action.AdminUserID,
+			AdminNickname:    action.AdminNickname,
+			ActionType:       action.ActionType,
+			TargetType:       action.TargetType,
+			TargetID:         action.TargetID,
+			TargetIdentifier: action.TargetIdentifier,
+			Details:          action.Details,
+			IPAddress:        action.IPAddress,
+		}
+	}
+
+	return writeJSON(w, entries)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+)
+
+// banEntry is the JSON form of a ban
+type banEntry struct {
+	ID          int64   `json:"id"`
+	Type        string  `json:"type"` // "user" or "ip"
+	UserID      *int64  `json:"user_id,omitempty"`
+	Nickname    *string `json:"nickname,omitempty"`
+	IPCIDR      *string `json:"ip_cidr,omitempty"`
+	Reason      string  `json:"reason"`
+	Shadowban   bool    `json:"shadowban"`
+	BannedAt    string  `json:"banned_at"`
+	BannedUntil string  `json:"banned_until,omitempty"`
+	BannedBy    string  `json:"banned_by"`
+}
+
+// runBans implements `scd bans`
+func runBans(args []string) error {
+	return runSubcommand("bans", args, []subcommand{
+		{"list", "List active bans", runBansList},
+		{"add", "Ban a user by nickname, or an IP address or CIDR range", runBansAdd},
+		{"remove", "Lift the bans on a nickname, IP address or CIDR range", runBansRemove},
+	})
+}
+
+func runBansList(args []string) error {
+	f := newReadOnlyFlags("bans list", "", "List bans, newest first.")
+	all := f.fs.Bool("all", false, "Include expired bans")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	bans, err := store.ListBans(*all)
+	if err != nil {
+		return fmt.Errorf("failed to list bans: %w", err)
+	}
+	entries := make([]banEntry, len(bans))
+	for i, ban := range bans {
+		entries[i] = newBanEntry(ban)
+	}
+
+	return f.write(entries, func(w io.Writer) error {
+		if len(bans) == 0 {
+			_, err := fmt.Fprintln(w, "No bans found")
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTARGET\tBANNED\tUNTIL\tBY\tREASON")
+		for _, ban := range bans {
+			until := "permanent"
+			if ban.BannedUntil != nil {
+				until = textTime(*ban.BannedUntil)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				ban.ID, banTarget(ban), textTime(ban.BannedAt), until, ban.BannedBy,
+				strings.Join(strings.Fields(ban.Reason), " "))
+		}
+		return tw.Flush()
+	})
+}
+
+func runBansAdd(args []string) error {
+	f := newOfflineFlags("bans add", "<nickname|ip|cidr>", "Ban a user by nickname, or an IP address or CIDR range.")
+	reason := f.fs.String("reason", "", "Reason for the ban")
+	duration := f.fs.String("duration", "", "How long the ban lasts, like 24h or 7d (default: permanent)")
+	shadowban := f.fs.Bool("shadow", false, "Shadowban the user instead (their messages are only shown to themselves)")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	target := f.fs.Arg(0)
+
+	var durationSeconds *uint64
+	if *duration != "" {
+		d, err := parseBanDuration(*duration)
+		if err != nil {
+			return fmt.Errorf("invalid --duration: %w", err)
+		}
+		seconds := uint64(d / time.Second)
+		durationSeconds = &seconds
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var banID int64
+	if isIPTarget(target) {
+		if *shadowban {
+			return fmt.Errorf("--shadow only applies to user bans")
+		}
+		banID, err = store.CreateIPBan(target, *reason, durationSeconds, offlineAdmin, "")
+	} else {
+		// Bans on nicknames nobody has registered still stop anonymous use
+		var userID *int64
+		if user, err := store.GetUserByNickname(target); err == nil {
+			userID = &user.ID
+		}
+		banID, err = store.CreateUserBan(userID, &target, *reason, *shadowban, durationSeconds, offlineAdmin, "")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create ban: %w", err)
+	}
+
+	bans, err := store.ListBans(true)
+	if err != nil {
+		return fmt.Errorf("failed to read ban: %w", err)
+	}
+	for _, ban := range bans {
+		if ban.ID == banID {
+			return f.write(newBanEntry(ban), func(w io.Writer) error {
+				_, err := fmt.Fprintf(w, "Banned %s (ban %d)\n", banTarget(ban), ban.ID)
+				return err
+			})
+		}
+	}
+	return fmt.Errorf("ban %d not found after creating it", banID)
+}
+
+func runBansRemove(args []string) error {
+	f := newOfflineFlags("bans remove", "<nickname|ip|cidr>", "Lift the bans on a nickname, IP address or CIDR range.")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	target := f.fs.Arg(0)
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var removed int64
+	if isIPTarget(target) {
+		removed, err = store.DeleteIPBan(target, offlineAdmin, "")
+	} else {
+		removed, err = removeUserBans(store, target)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove ban: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("no bans found for %s", target)
+	}
+
+	return f.write(map[string]any{"target": target, "removed": removed}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Removed %d ban(s) for %s\n", removed, target)
+		return err
+	})
+}
+
+// removeUserBans lifts the bans on a nickname. Bans are stored with the
+// nickname they were made with, so a user who has been renamed since is
+// unbanned by user ID.
+func removeUserBans(store database.Store, nickname string) (int64, error) {
+	bans, err := store.ListBans(true)
+	if err != nil {
+		return 0, err
+	}
+	for _, ban := range bans {
+		if ban.BanType == "user" && ban.Nickname != nil && *ban.Nickname == nickname {
+			return store.DeleteUserBan(nil, &nickname, offlineAdmin, "")
+		}
+	}
+	user, err := store.GetUserByNickname(nickname)
+	if err != nil {
+		return 0, nil
+	}
+	return store.DeleteUserBan(&user.ID, &nickname, offlineAdmin, "")
+}
+
+// isIPTarget returns true if a ban target is an IP address or CIDR range
+// rather than a nickname
+func isIPTarget(target string) bool {
+	if net.ParseIP(target) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(target)
+	return err == nil
+}
+
+// parseBanDuration accepts a Go duration or a number of days ("7d")
+func parseBanDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= time.Second {
+		return d, nil
+	}
+	return 0, fmt.Errorf("%q is not a duration", value)
+}
+
+// banTarget describes who or what a ban applies to
+func banTarget(ban *database.Ban) string {
+	switch {
+	case ban.IPCIDR != nil:
+		return *ban.IPCIDR
+	case ban.Nickname != nil:
+		return *ban.Nickname
+	case ban.UserID != nil:
+		return fmt.Sprintf("user_id:%d", *ban.UserID)
+	default:
+		return "-"
+	}
+}
+
+// newBanEntry converts a ban to its JSON form
+func newBanEntry(ban *database.Ban) banEntry {
+	entry := banEntry{
+		ID:        ban.ID,
+		Type:      ban.BanType,
+		UserID:    ban.UserID,
+		Nickname:  ban.Nickname,
+		IPCIDR:    ban.IPCIDR,
+		Reason:    ban.Reason,
+		Shadowban: ban.Shadowban,
+		BannedAt:  jsonTime(ban.BannedAt),
+		BannedBy:  ban.BannedBy,
+	}
+	if ban.BannedUntil != nil {
+		entry.BannedUntil = jsonTime(*ban.BannedUntil)
+	}
+	return entry
+}
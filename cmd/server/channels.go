@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/aeolun/superchat/pkg/database"
+)
+
+// channelEntry is the JSON form of a channel
+type channelEntry struct {
+	ID             int64   `json:"id"`
+	Name           string  `json:"name"`
+	DisplayName    string  `json:"display_name"`
+	Description    *string `json:"description,omitempty"`
+	Type           string  `json:"type"` // "chat" or "forum"
+	RetentionHours uint32  `json:"retention_hours"`
+	Private        bool    `json:"private"`
+	Archived       bool    `json:"archived"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// runChannels implements `scd channels`
+func runChannels(args []string) error {
+	return runSubcommand("channels", args, []subcommand{
+		{"list", "List public channels", runChannelsList},
+		{"create", "Create a public channel", runChannelsCreate},
+		{"delete", "Delete a channel and its messages", runChannelsDelete},
+		{"set-retention", "Change how long a channel keeps messages", runChannelsSetRetention},
+	})
+}
+
+func runChannelsList(args []string) error {
+	f := newReadOnlyFlags("channels list", "", "List public channels by name.")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	channels, err := store.ListChannels()
+	if err != nil {
+		return fmt.Errorf("failed to list channels: %w", err)
+	}
+	entries := make([]channelEntry, len(channels))
+	for i, channel := range channels {
+		entries[i] = newChannelEntry(channel)
+	}
+
+	return f.write(entries, func(w io.Writer) error {
+		if len(entries) == 0 {
+			_, err := fmt.Fprintln(w, "No channels found")
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tDISPLAY NAME\tTYPE\tRETENTION\tARCHIVED")
+		for _, entry := range entries {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%dh\t%s\n",
+				entry.ID, entry.Name, entry.DisplayName, entry.Type, entry.RetentionHours, yesNo(entry.Archived))
+		}
+		return tw.Flush()
+	})
+}
+
+func runChannelsCreate(args []string) error {
+	f := newOfflineFlags("channels create", "<name>", "Create a public channel.")
+	displayName := f.fs.String("display-name", "", "Display name (default: #<name>)")
+	description := f.fs.String("description", "", "Channel description")
+	channelType := f.fs.String("type", "chat", "Channel type (chat or forum)")
+	retention := f.fs.Uint("retention", 168, "Hours to keep messages (1-8760)")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+
+	// Same limits as CREATE_CHANNEL
+	name := f.fs.Arg(0)
+	if *displayName == "" {
+		*displayName = "#" + name
+	}
+	if len(name) < 3 || len(name) > 50 {
+		return fmt.Errorf("channel name must be 3-50 characters")
+	}
+	if len(*displayName) > 100 {
+		return fmt.Errorf("display name must be 1-100 characters")
+	}
+	if len(*description) > 500 {
+		return fmt.Errorf("description must be at most 500 characters")
+	}
+	typeID, err := parseChannelType(*channelType)
+	if err != nil {
+		return err
+	}
+	if err := validateRetention(*retention); err != nil {
+		return err
+	}
+	var desc *string
+	if *description != "" {
+		desc = description
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	channelID, err := store.CreateChannel(name, *displayName, desc, typeID, uint32(*retention), nil)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return fmt.Errorf("channel %q already exists", name)
+		}
+		return fmt.Errorf("failed to create channel: %w", err)
+	}
+	channel, err := store.GetChannel(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to read channel: %w", err)
+	}
+
+	return f.write(newChannelEntry(channel), func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Created #%s (channel %d)\n", channel.Name, channel.ID)
+		return err
+	})
+}
+
+func runChannelsDelete(args []string) error {
+	f := newOfflineFlags("channels delete", "<channel>", "Delete a channel with its subchannels and messages. The channel is a\nname or, for private channels, an ID.")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	channel, err := findChannel(store, f.fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	logOfflineAction(store, "DELETE_CHANNEL", fmt.Sprintf("channel_id=%d name=%s", channel.ID, channel.Name))
+	if err := store.DeleteChannel(uint64(channel.ID)); err != nil {
+		return fmt.Errorf("failed to delete channel: %w", err)
+	}
+
+	return f.write(newChannelEntry(channel), func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Deleted #%s\n", channel.Name)
+		return err
+	})
+}
+
+func runChannelsSetRetention(args []string) error {
+	f := newOfflineFlags("channels set-retention", "<channel> <hours>", "Change how many hours a channel keeps messages (1-8760). The channel is a\nname or, for private channels, an ID.")
+	if err := f.parse(args, 2); err != nil {
+		return err
+	}
+	hours, err := strconv.ParseUint(f.fs.Arg(1), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid hours %q", f.fs.Arg(1))
+	}
+	if err := validateRetention(uint(hours)); err != nil {
+		return err
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	channel, err := findChannel(store, f.fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := store.UpdateChannel(channel.ID, channel.DisplayName, channel.Description, channel.ChannelType, uint32(hours), channel.Archived); err != nil {
+		return fmt.Errorf("failed to update channel: %w", err)
+	}
+	channel.MessageRetentionHours = uint32(hours)
+	logOfflineAction(store, "UPDATE_CHANNEL", fmt.Sprintf("channel_id=%d name=%s retention_hours=%d", channel.ID, channel.Name, hours))
+
+	return f.write(newChannelEntry(channel), func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "#%s now keeps messages for %d hours\n", channel.Name, hours)
+		return err
+	})
+}
+
+// parseChannelType converts "chat" or "forum" to its channel type
+func parseChannelType(value string) (uint8, error) {
+	switch value {
+	case "chat":
+		return 0, nil
+	case "forum":
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("invalid channel type %q (must be chat or forum)", value)
+	}
+}
+
+// validateRetention checks a retention period like CREATE_CHANNEL and
+// UPDATE_CHANNEL do
+func validateRetention(hours uint) error {
+	if hours < 1 || hours > 8760 {
+		return fmt.Errorf("retention hours must be between 1 and 8760 (1 year)")
+	}
+	return nil
+}
+
+// newChannelEntry converts a channel to its JSON form
+func newChannelEntry(channel *database.Channel) channelEntry {
+	channelType := "chat"
+	if channel.ChannelType == 1 {
+		channelType = "forum"
+	}
+	return channelEntry{
+		ID:             channel.ID,
+		Name:           channel.Name,
+		DisplayName:    channel.DisplayName,
+		Description:    channel.Description,
+		Type:           channelType,
+		RetentionHours: channel.MessageRetentionHours,
+		Private:        channel.IsPrivate,
+		Archived:       channel.Archived,
+		CreatedAt:      jsonTime(channel.CreatedAt),
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/aeolun/superchat/pkg/database"
+)
+
+// dbStatsEntry is the JSON form of the database statistics
+type dbStatsEntry struct {
+	Path      string           `json:"path"`
+	FileSize  int64            `json:"file_size"`
+	WALSize   int64            `json:"wal_size"`
+	PageSize  int64            `json:"page_size"`
+	PageCount int64            `json:"page_count"`
+	FreePages int64            `json:"free_pages"`
+	Tables    map[string]int64 `json:"tables"` // Row count by table name
+}
+
+// dbCheckEntry is the JSON form of an integrity check
+type dbCheckEntry struct {
+	OK       bool     `json:"ok"`
+	Problems []string `json:"problems"`
+}
+
+// dbVacuumEntry is the JSON form of a vacuum
+type dbVacuumEntry struct {
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+}
+
+// runDB implements `scd db`
+func runDB(args []string) error {
+	return runSubcommand("db", args, []subcommand{
+		{"check", "Check the database for corruption and broken references", runDBCheck},
+		{"vacuum", "Rebuild the database file to reclaim free space", runDBVacuum},
+		{"stats", "Show the database size and row counts", runDBStats},
+	})
+}
+
+// openSQLite opens the SQLite database; the maintenance commands don't apply
+// to PostgreSQL
+func openSQLite(f *offlineFlags) (*database.DB, error) {
+	store, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	db, ok := store.(*database.DB)
+	if !ok {
+		store.Close()
+		return nil, fmt.Errorf("scd db only works on SQLite databases; use the PostgreSQL tools instead")
+	}
+	return db, nil
+}
+
+func runDBCheck(args []string) error {
+	f := newReadOnlyFlags("db check", "", "Run SQLite's integrity and foreign key checks. Exits non-zero if problems are found.")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	db, err := openSQLite(f)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	problems, err := db.IntegrityCheck()
+	if err != nil {
+		return fmt.Errorf("failed to check database: %w", err)
+	}
+	if err := f.write(dbCheckEntry{OK: len(problems) == 0, Problems: append([]string{}, problems...)}, func(w io.Writer) error {
+		if len(problems) == 0 {
+			_, err := fmt.Fprintln(w, "No problems found")
+			return err
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(w, problem)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s)", len(problems))
+	}
+	return nil
+}
+
+func runDBVacuum(args []string) error {
+	f := newOfflineFlags("db vacuum", "", "Fold the write-ahead log into the database and rebuild the file, giving\nfree pages back to the filesystem. Needs free disk space about the size of\nthe database.")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	db, err := openSQLite(f)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	before, err := db.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to read database size: %w", err)
+	}
+	if err := db.Vacuum(); err != nil {
+		return err
+	}
+	after, err := db.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	entry := dbVacuumEntry{
+		SizeBefore: before.FileSize + before.WALSize,
+		SizeAfter:  after.FileSize + after.WALSize,
+	}
+	return f.write(entry, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Vacuumed %s: %s -> %s\n", after.Path, formatBytes(entry.SizeBefore), formatBytes(entry.SizeAfter))
+		return err
+	})
+}
+
+func runDBStats(args []string) error {
+	f := newReadOnlyFlags("db stats", "", "Show the database file size, page usage and the row count of every table.")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	db, err := openSQLite(f)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats, err := db.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to read statistics: %w", err)
+	}
+	entry := dbStatsEntry{
+		Path:      stats.Path,
+		FileSize:  stats.FileSize,
+		WALSize:   stats.WALSize,
+		PageSize:  stats.PageSize,
+		PageCount: stats.PageCount,
+		FreePages: stats.FreePages,
+		Tables:    make(map[string]int64, len(stats.Tables)),
+	}
+	for _, table := range stats.Tables {
+		entry.Tables[table.Name] = table.Rows
+	}
+
+	return f.write(entry, func(w io.Writer) error {
+		fmt.Fprintf(w, "Path:       %s\n", stats.Path)
+		fmt.Fprintf(w, "Size:       %s (write-ahead log: %s)\n", formatBytes(stats.FileSize), formatBytes(stats.WALSize))
+		fmt.Fprintf(w, "Pages:      %d of %d bytes, %d free\n\n", stats.PageCount, stats.PageSize, stats.FreePages)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TABLE\tROWS")
+		for _, table := range stats.Tables {
+			fmt.Fprintf(tw, "%s\t%d\n", table.Name, table.Rows)
+		}
+		return tw.Flush()
+	})
+}
+
+// formatBytes formats a size in bytes for people
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
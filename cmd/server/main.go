@@ -24,6 +24,15 @@ func main() {
 	// Configure logger with microsecond precision
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
 
+	// Offline subcommands work on the database without starting the server
+	if len(os.Args) > 1 && offlineCommands[os.Args[1]] != nil {
+		if err := offlineCommands[os.Args[1]](os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Command line flags
 	configPath := flag.String("config", "~/.superchat/config.toml", "Path to config file")
 	port := flag.Int("port", 0, "TCP port to listen on (overrides config)")
@@ -88,6 +97,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
+	srv.SetVersion(Version)
 
 	// Enable debug logging if requested
 	if *debug {
@@ -104,7 +114,11 @@ func main() {
 	}
 
 	log.Printf("Config: %s (resolved to %s, using defaults if not found)", *configPath, resolvedConfigPath)
-	log.Printf("Database: %s", finalDBPath)
+	if serverConfig.DatabaseURL != "" {
+		log.Printf("Database: PostgreSQL (worker ID %d)", *serverConfig.WorkerID)
+	} else {
+		log.Printf("Database: %s", finalDBPath)
+	}
 
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -149,6 +163,9 @@ func main() {
 	if serverConfig.SSHPort > 0 {
 		log.Printf("  - SSH: port %d", serverConfig.SSHPort)
 	}
+	if serverConfig.TLSPort > 0 && serverConfig.TLSCertPath != "" && serverConfig.TLSKeyPath != "" {
+		log.Printf("  - Binary Protocol (TLS): port %d (sc+tls://server:%d)", serverConfig.TLSPort, serverConfig.TLSPort)
+	}
 	if serverConfig.HTTPPort > 0 {
 		log.Printf("  - WebSocket: port %d (ws://server:%d/ws)", serverConfig.HTTPPort, serverConfig.HTTPPort)
 	}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+)
+
+// offlineAdmin is the admin nickname changes made by the offline subcommands
+// are recorded under in the audit log
+const offlineAdmin = "console"
+
+// offlineCommands are the subcommands that work on the database without
+// starting the server
+var offlineCommands = map[string]func(args []string) error{
+	"audit":    runAudit,
+	"users":    runUsers,
+	"bans":     runBans,
+	"channels": runChannels,
+	"db":       runDB,
+}
+
+// subcommand is one action of an offline command, like `users list`
+type subcommand struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// runSubcommand runs the subcommand named by the first argument
+func runSubcommand(command string, args []string, subcommands []subcommand) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s <command> [flags]\n\nCommands:\n", os.Args[0], command)
+		for _, sub := range subcommands {
+			fmt.Fprintf(os.Stderr, "  %-16s %s\n", sub.name, sub.summary)
+		}
+		fmt.Fprintf(os.Stderr, "\nRun '%s %s <command> -h' for the flags of a command.\n", os.Args[0], command)
+	}
+
+	if len(args) == 0 {
+		usage()
+		return fmt.Errorf("missing %s command", command)
+	}
+	for _, sub := range subcommands {
+		if sub.name == args[0] {
+			return sub.run(args[1:])
+		}
+	}
+	if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage()
+		return nil
+	}
+	usage()
+	return fmt.Errorf("unknown %s command %q", command, args[0])
+}
+
+// offlineFlags are the flags every offline subcommand takes
+type offlineFlags struct {
+	fs         *flag.FlagSet
+	configPath *string
+	dbPath     *string
+	json       *bool
+	force      *bool    // nil for commands that only read
+	lock       *os.File // Lock on the SQLite database, held until the command exits
+}
+
+// newOfflineFlags creates the flag set for `scd <command>` for a command that
+// changes the database. arguments names the positional arguments in the usage
+// line.
+func newOfflineFlags(command, arguments, description string) *offlineFlags {
+	f := newReadOnlyFlags(command, arguments, description)
+	f.force = f.fs.Bool("force", false, "Write even if a server is running on the database")
+	return f
+}
+
+// newReadOnlyFlags creates the flag set for a command that only reads the
+// database, which is safe while the server runs
+func newReadOnlyFlags(command, arguments, description string) *offlineFlags {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	f := &offlineFlags{
+		fs:         fs,
+		configPath: fs.String("config", "~/.superchat/config.toml", "Path to config file"),
+		dbPath:     fs.String("db", "", "Path to SQLite database (overrides config)"),
+		json:       fs.Bool("json", false, "Print the result as JSON"),
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", os.Args[0], command, arguments, description)
+		fs.PrintDefaults()
+	}
+	return f
+}
+
+// parse parses the flags and checks the number of positional arguments
+func (f *offlineFlags) parse(args []string, nargs int) error {
+	f.fs.Parse(args)
+	if f.fs.NArg() != nargs {
+		f.fs.Usage()
+		return fmt.Errorf("expected %d argument(s), got %d", nargs, f.fs.NArg())
+	}
+	return nil
+}
+
+// open opens the database the server would use. Commands that write refuse
+// to while a server runs on it, unless --force is given.
+func (f *offlineFlags) open() (database.Store, error) {
+	if f.force != nil {
+		if err := f.lockDatabase(); err != nil {
+			return nil, err
+		}
+	}
+	return openOfflineStore(*f.configPath, *f.dbPath)
+}
+
+// lockDatabase takes the lock a running server holds on a SQLite database.
+// A running server keeps users and channels in memory and writes them back
+// in snapshots, so a change made behind its back would be lost. PostgreSQL
+// servers read the database directly and need no lock.
+func (f *offlineFlags) lockDatabase() error {
+	target, err := offlineDatabase(*f.configPath, *f.dbPath)
+	if err != nil || target.path == "" {
+		return err
+	}
+	path := target.path
+	lock, err := database.LockDatabase(path)
+	if errors.Is(err, database.ErrDatabaseLocked) {
+		if *f.force {
+			fmt.Fprintf(os.Stderr, "Warning: a server is running on %s and may overwrite this change\n", path)
+			return nil
+		}
+		return fmt.Errorf("a server is running on %s; stop it first, or use --force to write anyway", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock database: %w", err)
+	}
+	// Keep the lock until we exit, so a server can't start halfway through
+	f.lock = lock
+	return nil
+}
+
+// write prints v as JSON with --json, or calls text otherwise
+func (f *offlineFlags) write(v any, text func(w io.Writer) error) error {
+	if *f.json {
+		return writeJSON(os.Stdout, v)
+	}
+	return text(os.Stdout)
+}
+
+// writeJSON prints v as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// textTime formats a Unix millisecond timestamp for tables
+func textTime(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.UnixMilli(ms).Local().Format("2006-01-02 15:04:05")
+}
+
+// jsonTime formats a Unix millisecond timestamp for JSON output
+func jsonTime(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
+
+// findUser looks up a registered user by nickname
+func findUser(store database.Store, nickname string) (*database.User, error) {
+	user, err := store.GetUserByNickname(nickname)
+	if err != nil {
+		return nil, fmt.Errorf("user %q not found", nickname)
+	}
+	return user, nil
+}
+
+// findChannel looks up a channel by name (with or without the leading #),
+// or by ID for channels that aren't listed publicly
+func findChannel(store database.Store, nameOrID string) (*database.Channel, error) {
+	name := strings.TrimPrefix(nameOrID, "#")
+	channels, err := store.ListChannels()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channels: %w", err)
+	}
+	for _, channel := range channels {
+		if channel.Name == name {
+			return channel, nil
+		}
+	}
+	if id, err := strconv.ParseInt(nameOrID, 10, 64); err == nil {
+		if channel, err := store.GetChannel(id); err == nil && !channel.IsDM {
+			return channel, nil
+		}
+	}
+	return nil, fmt.Errorf("channel %q not found", nameOrID)
+}
+
+// logOfflineAction records a change in the audit log. Offline changes have
+// no admin user, so they are logged with user ID 0.
+func logOfflineAction(store database.Store, actionType, details string) {
+	if err := store.LogAdminAction(0, offlineAdmin, actionType, details); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to log admin action: %v\n", err)
+	}
+}
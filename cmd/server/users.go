@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+	"github.com/aeolun/superchat/pkg/server"
+	"golang.org/x/term"
+)
+
+// userEntry is the JSON form of a registered user
+type userEntry struct {
+	ID        int64  `json:"id"`
+	Nickname  string `json:"nickname"`
+	Admin     bool   `json:"admin"`
+	TwoFactor bool   `json:"two_factor"`
+	CreatedAt string `json:"created_at"`
+	LastSeen  string `json:"last_seen,omitempty"`
+}
+
+// channelRoleEntry is the JSON form of a channel role change
+type channelRoleEntry struct {
+	ChannelID int64  `json:"channel_id"`
+	Channel   string `json:"channel"`
+	UserID    int64  `json:"user_id"`
+	Nickname  string `json:"nickname"`
+	Role      string `json:"role"` // "owner", "moderator" or "member"
+}
+
+// runUsers implements `scd users`
+func runUsers(args []string) error {
+	return runSubcommand("users", args, []subcommand{
+		{"list", "List registered users", runUsersList},
+		{"promote", "Make a user an admin (adds them to admin_users), or a channel moderator or owner", runUsersPromote},
+		{"demote", "Remove a user's admin flag and admin_users entry, or channel role", runUsersDemote},
+		{"delete", "Delete a user (their messages are kept, anonymized)", runUsersDelete},
+		{"reset-password", "Set a user's password", runUsersResetPassword},
+	})
+}
+
+func runUsersList(args []string) error {
+	f := newReadOnlyFlags("users list", "", "List registered users by nickname.")
+	limit := f.fs.Int("limit", 0, "Maximum number of users to print (0 = all)")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *limit <= 0 {
+		*limit = math.MaxInt32
+	}
+	users, err := store.ListAllUsers(*limit)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	entries := make([]userEntry, len(users))
+	for i, user := range users {
+		if entries[i], err = newUserEntry(store, user); err != nil {
+			return err
+		}
+	}
+
+	return f.write(entries, func(w io.Writer) error {
+		if len(entries) == 0 {
+			_, err := fmt.Fprintln(w, "No users found")
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNICKNAME\tADMIN\t2FA\tCREATED\tLAST SEEN")
+		for i, entry := range entries {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				entry.ID, entry.Nickname, yesNo(entry.Admin), yesNo(entry.TwoFactor),
+				textTime(users[i].CreatedAt), textTime(users[i].LastSeen))
+		}
+		return tw.Flush()
+	})
+}
+
+func runUsersPromote(args []string) error {
+	f := newOfflineFlags("users promote", "<nickname>", "Make a user an admin, or with --channel give them a role in that channel.\nAdmins are listed in admin_users, so the nickname is added there in the\n--config file as well as flagged in the database.")
+	channelName := f.fs.String("channel", "", "Give the user a role in this channel instead")
+	role := f.fs.String("role", database.ChannelRoleModerator, "Channel role to give with --channel (moderator or owner)")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	if *role != database.ChannelRoleModerator && *role != database.ChannelRoleOwner {
+		return fmt.Errorf("invalid --role %q (must be moderator or owner)", *role)
+	}
+	if *channelName != "" {
+		return setUserChannelRole(f, *channelName, *role)
+	}
+	return setUserAdmin(f, true)
+}
+
+func runUsersDemote(args []string) error {
+	f := newOfflineFlags("users demote", "<nickname>", "Remove a user's admin flag, or with --channel their role in that channel.\nThe nickname is removed from admin_users in the --config file as well.")
+	channelName := f.fs.String("channel", "", "Remove the user's role in this channel instead")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	if *channelName != "" {
+		return setUserChannelRole(f, *channelName, database.ChannelRoleMember)
+	}
+	return setUserAdmin(f, false)
+}
+
+// setUserAdmin makes the user named on the command line an admin or not,
+// in admin_users and in their admin flag
+func setUserAdmin(f *offlineFlags, admin bool) error {
+	nickname := f.fs.Arg(0)
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := findUser(store, nickname)
+	if err != nil {
+		return err
+	}
+
+	// The server syncs the admin flag with admin_users at every login, so
+	// the config has to change too or the flag would be undone
+	if os.Getenv("SUPERCHAT_SERVER_ADMIN_USERS") != "" {
+		return fmt.Errorf("admin_users is set by SUPERCHAT_SERVER_ADMIN_USERS, which overrides %s; change it there instead", *f.configPath)
+	}
+	configChanged, err := server.SetConfigAdminUser(*f.configPath, user.Nickname, admin)
+	if err != nil {
+		return err
+	}
+
+	flags := user.UserFlags &^ uint8(protocol.UserFlagAdmin)
+	action := "DEMOTE_USER"
+	if admin {
+		flags |= uint8(protocol.UserFlagAdmin)
+		action = "PROMOTE_USER"
+	}
+	if err := store.UpdateUserFlags(user.ID, flags); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	user.UserFlags = flags
+	logOfflineAction(store, action, fmt.Sprintf("user_id=%d nickname=%s", user.ID, user.Nickname))
+	configNote := ""
+	if configChanged {
+		configNote = fmt.Sprintf(" (admin_users updated in %s)", *f.configPath)
+	}
+
+	entry, err := newUserEntry(store, user)
+	if err != nil {
+		return err
+	}
+	return f.write(entry, func(w io.Writer) error {
+		if admin {
+			_, err := fmt.Fprintf(w, "%s is now an admin%s\n", user.Nickname, configNote)
+			return err
+		}
+		_, err := fmt.Fprintf(w, "%s is no longer an admin%s\n", user.Nickname, configNote)
+		return err
+	})
+}
+
+// setUserChannelRole gives the user named on the command line a role in a
+// channel (ChannelRoleMember removes it)
+func setUserChannelRole(f *offlineFlags, channelName, role string) error {
+	nickname := f.fs.Arg(0)
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := findUser(store, nickname)
+	if err != nil {
+		return err
+	}
+	channel, err := findChannel(store, channelName)
+	if err != nil {
+		return err
+	}
+	if channel.ParentID != nil {
+		return fmt.Errorf("roles are set on the parent channel")
+	}
+	if err := store.SetChannelRole(channel.ID, user.ID, role, offlineAdmin); err != nil {
+		return fmt.Errorf("failed to set channel role: %w", err)
+	}
+
+	label := role
+	if role == database.ChannelRoleMember {
+		label = "member"
+	}
+	message := fmt.Sprintf("%s is now %s of #%s", user.Nickname, label, channel.Name)
+	logOfflineAction(store, "SET_CHANNEL_ROLE", message)
+
+	return f.write(channelRoleEntry{
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		UserID:    user.ID,
+		Nickname:  user.Nickname,
+		Role:      label,
+	}, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, message)
+		return err
+	})
+}
+
+func runUsersDelete(args []string) error {
+	f := newOfflineFlags("users delete", "<nickname>", "Delete a user. Their messages are kept, attributed to the nickname without an account.")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := findUser(store, f.fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	entry, err := newUserEntry(store, user)
+	if err != nil {
+		return err
+	}
+	if _, err := store.DeleteUser(uint64(user.ID)); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	logOfflineAction(store, "DELETE_USER", fmt.Sprintf("user_id=%d nickname=%s", user.ID, user.Nickname))
+
+	return f.write(entry, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Deleted user %s\n", user.Nickname)
+		return err
+	})
+}
+
+func runUsersResetPassword(args []string) error {
+	f := newOfflineFlags("users reset-password", "<nickname>", "Set a user's password and log out their other devices. The password is\nprompted for, or read from the first line of standard input when it isn't\na terminal.")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+
+	store, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := findUser(store, f.fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	password, err := readNewPassword(user.Nickname)
+	if err != nil {
+		return err
+	}
+	if err := server.ResetUserPassword(store, user.Nickname, password); err != nil {
+		return err
+	}
+	// Resume tokens would keep old logins alive without the new password
+	if _, err := store.DeleteUserResumeTokens(user.ID, 0); err != nil {
+		return fmt.Errorf("failed to delete resume tokens: %w", err)
+	}
+	logOfflineAction(store, "RESET_PASSWORD", fmt.Sprintf("user_id=%d nickname=%s", user.ID, user.Nickname))
+
+	entry, err := newUserEntry(store, user)
+	if err != nil {
+		return err
+	}
+	return f.write(entry, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Reset password for %s\n", user.Nickname)
+		return err
+	})
+}
+
+// readNewPassword prompts for a password twice on a terminal, or reads it
+// from the first line of standard input
+func readNewPassword(nickname string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("password cannot be empty")
+		}
+		return password, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "New password for %s: ", nickname)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if len(password) == 0 {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	repeat, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if string(repeat) != string(password) {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return string(password), nil
+}
+
+// newUserEntry converts a user to its JSON form
+func newUserEntry(store database.Store, user *database.User) (userEntry, error) {
+	totp, err := store.GetTOTP(user.ID)
+	if err != nil {
+		return userEntry{}, fmt.Errorf("failed to read two-factor status: %w", err)
+	}
+	return userEntry{
+		ID:        user.ID,
+		Nickname:  user.Nickname,
+		Admin:     protocol.UserFlags(user.UserFlags).IsAdmin(),
+		TwoFactor: totp != nil,
+		CreatedAt: jsonTime(user.CreatedAt),
+		LastSeen:  jsonTime(user.LastSeen),
+	}, nil
+}
+
+// yesNo formats a flag for tables
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -447,6 +447,8 @@ export interface NewMessage {
   created_at: bigint;
   edited_at: { present: number, value?: bigint };
   reply_count: number;
+  plus_one_count: number;
+  plus_one_anon_count: number;
 }
 
 export class NewMessageEncoder extends BitStreamEncoder {
@@ -490,6 +492,8 @@ export class NewMessageEncoder extends BitStreamEncoder {
       this.writeInt64(value.edited_at.value, "big_endian");
     }
     this.writeUint32(value.reply_count, "big_endian");
+    this.writeUint32(value.plus_one_count, "big_endian");
+    this.writeUint32(value.plus_one_anon_count, "big_endian");
     return this.finish();
   }
 }
@@ -538,6 +542,8 @@ export class NewMessageDecoder extends BitStreamDecoder {
       value.edited_at.value = this.readInt64("big_endian");
     }
     value.reply_count = this.readUint32("big_endian");
+    value.plus_one_count = this.readUint32("big_endian");
+    value.plus_one_anon_count = this.readUint32("big_endian");
     return value;
   }
 }
@@ -1025,6 +1031,8 @@ export interface Message {
   created_at: bigint;
   edited_at: { present: number, value?: bigint };
   reply_count: number;
+  plus_one_count: number;
+  plus_one_anon_count: number;
 }
 
 export class MessageEncoder extends BitStreamEncoder {
@@ -1068,6 +1076,8 @@ export class MessageEncoder extends BitStreamEncoder {
       this.writeInt64(value.edited_at.value, "big_endian");
     }
     this.writeUint32(value.reply_count, "big_endian");
+    this.writeUint32(value.plus_one_count, "big_endian");
+    this.writeUint32(value.plus_one_anon_count, "big_endian");
     return this.finish();
   }
 }
@@ -1116,6 +1126,8 @@ export class MessageDecoder extends BitStreamDecoder {
       value.edited_at.value = this.readInt64("big_endian");
     }
     value.reply_count = this.readUint32("big_endian");
+    value.plus_one_count = this.readUint32("big_endian");
+    value.plus_one_anon_count = this.readUint32("big_endian");
     return value;
   }
 }
@@ -1183,6 +1195,8 @@ export class MessageListEncoder extends BitStreamEncoder {
         this.writeInt64(value_messages_item.edited_at.value, "big_endian");
       }
       this.writeUint32(value_messages_item.reply_count, "big_endian");
+      this.writeUint32(value_messages_item.plus_one_count, "big_endian");
+      this.writeUint32(value_messages_item.plus_one_anon_count, "big_endian");
     }
     return this.finish();
   }
@@ -1252,6 +1266,8 @@ export class MessageListDecoder extends BitStreamDecoder {
         messages_item.edited_at.value = this.readInt64("big_endian");
       }
       messages_item.reply_count = this.readUint32("big_endian");
+      messages_item.plus_one_count = this.readUint32("big_endian");
+      messages_item.plus_one_anon_count = this.readUint32("big_endian");
       value.messages.push(messages_item);
     }
     return value;
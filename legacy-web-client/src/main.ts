@@ -1183,7 +1183,9 @@ class SuperChatClient {
       content: newMsg.content,
       created_at: newMsg.created_at,
       edited_at: newMsg.edited_at,
-      reply_count: newMsg.reply_count
+      reply_count: newMsg.reply_count,
+      plus_one_count: newMsg.plus_one_count,
+      plus_one_anon_count: newMsg.plus_one_anon_count
     };
 
     // Check if this is our own message by comparing nicknames
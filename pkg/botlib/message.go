@@ -4,6 +4,8 @@ package botlib
 import (
 	"strings"
 	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
 )
 
 // Message represents a chat message received by the bot.
@@ -41,20 +43,21 @@ func (m *Message) ThreadID() uint64 {
 }
 
 // MentionsMe returns true if the message content mentions the bot.
-// Checks for @nickname patterns (case-insensitive).
+// Checks for @nickname patterns (case-insensitive), using the same rules as
+// the server's mention notifications.
 func (m *Message) MentionsMe() bool {
 	if m.botNickname == "" {
 		return false
 	}
 
-	content := strings.ToLower(m.Content)
-	nickname := strings.ToLower(m.botNickname)
-
 	// Check for @nickname mention
-	if strings.Contains(content, "@"+nickname) {
+	if protocol.MentionsNickname(m.Content, m.botNickname) {
 		return true
 	}
 
+	content := strings.ToLower(m.Content)
+	nickname := strings.ToLower(m.botNickname)
+
 	// Also check for nickname at start of message (common pattern)
 	if strings.HasPrefix(content, nickname+":") ||
 		strings.HasPrefix(content, nickname+",") ||
@@ -60,7 +60,8 @@ type Connection struct {
 	reconnecting    bool
 	securityWarning string
 	warningOnce     sync.Once
-	connectionType  string // "tcp", "ssh", or "websocket"
+	connectionType  string // "tcp", "tls", "ssh", or "websocket"
+	tlsVerifier     *certPinVerifier // Set for sc+tls:// connections
 
 	// Channels for communication
 	incoming    chan *protocol.Frame
@@ -109,6 +110,7 @@ func NewConnection(addr string) (*Connection, error) {
 		rawAddr:           dialConfig.raw,
 		dial:              dialConfig.dial,
 		securityWarning:   dialConfig.warning,
+		tlsVerifier:       dialConfig.tlsVerifier,
 		incoming:          make(chan *protocol.Frame, 100),
 		outgoing:          make(chan *protocol.Frame, 100),
 		errors:            make(chan error, 10),
@@ -139,6 +141,14 @@ func (c *Connection) SetThrottle(bytesPerSec int) {
 	}
 }
 
+// SetCertificatePinStore sets where TLS certificate pins are persisted.
+// Without a store, sc+tls:// pins only last for the lifetime of the connection.
+func (c *Connection) SetCertificatePinStore(store CertificatePinStore) {
+	if c.tlsVerifier != nil {
+		c.tlsVerifier.setStore(store)
+	}
+}
+
 // DisableAutoReconnect disables automatic reconnection on connection loss
 func (c *Connection) DisableAutoReconnect() {
 	c.mu.Lock()
@@ -182,6 +192,8 @@ func (c *Connection) Connect() error {
 	connType := "tcp" // Default
 	if strings.HasPrefix(c.addr, "ssh://") {
 		connType = "ssh"
+	} else if strings.HasPrefix(c.addr, "sc+tls://") {
+		connType = "tls"
 	} else if strings.HasPrefix(c.addr, "ws://") {
 		connType = "websocket"
 	}
@@ -189,6 +201,16 @@ func (c *Connection) Connect() error {
 	if err != nil {
 		c.logf("Primary connection failed: %v", err)
 
+		// Never fall back from TLS: the WebSocket fallback may end up on
+		// plaintext ws://, and silently switching transports would also
+		// defeat the certificate pin
+		if connType == "tls" {
+			c.mu.Lock()
+			c.connectionType = connType
+			c.mu.Unlock()
+			return err
+		}
+
 		// Only try WebSocket fallback if not already trying WebSocket
 		if connType != "websocket" {
 			c.logf("Attempting WebSocket fallback...")
@@ -231,8 +253,9 @@ func (c *Connection) Connect() error {
 		c.logf("Protocol validation failed: %v", err)
 		conn.Close()
 
-		// Only try WebSocket fallback if not already using it
-		if connType != "websocket" {
+		// Only try WebSocket fallback if not already using it (and never
+		// from TLS, see above)
+		if connType != "websocket" && connType != "tls" {
 			c.logf("Attempting WebSocket fallback after protocol failure...")
 			wsConn, wsAddr, wsErr := c.tryWebSocketFallback()
 			if wsErr != nil {
@@ -853,15 +876,17 @@ func (c *Connection) SendMessage(msgType uint8, msg interface{}) error {
 }
 
 type dialConfig struct {
-	display string // Display address with scheme
-	raw     string // Raw host:port without scheme
-	dial    func() (net.Conn, error)
-	warning string
+	display     string // Display address with scheme
+	raw         string // Raw host:port without scheme
+	dial        func() (net.Conn, error)
+	warning     string
+	tlsVerifier *certPinVerifier
 }
 
 const (
 	defaultTCPPort            = "6465"
 	defaultSSHPort            = "6466"
+	defaultTLSPort            = "6467"
 	defaultHTTPPort           = "8080"
 	superChatSSHVersionPrefix = "SSH-2.0-SuperChat"
 )
@@ -916,6 +941,26 @@ func parseServerAddress(raw string) (*dialConfig, error) {
 			dial:    dial,
 		}, nil
 
+	case "sc+tls", "scs":
+		host, port, err := splitHostPortWithDefault(hostPort, defaultTLSPort)
+		if err != nil {
+			return nil, err
+		}
+
+		verifier := newCertPinVerifier(host, port)
+		address := net.JoinHostPort(host, port)
+
+		dial := func() (net.Conn, error) {
+			return dialTLS(host, port, verifier)
+		}
+
+		return &dialConfig{
+			display:     fmt.Sprintf("sc+tls://%s", address),
+			raw:         address,
+			dial:        dial,
+			tlsVerifier: verifier,
+		}, nil
+
 	case "ssh":
 		host, port, err := splitHostPortWithDefault(hostPort, defaultSSHPort)
 		if err != nil {
@@ -1266,6 +1311,15 @@ func dialSSH(user, host, port string, verifier *hostKeyVerifier) (net.Conn, erro
 
 	go ssh.DiscardRequests(requests)
 
+	// Ask for the binary protocol explicitly so the server doesn't wait to see
+	// whether we want a terminal. Older servers ignore the request.
+	subsystem := ssh.Marshal(struct{ Name string }{"superchat"})
+	if _, err := channel.SendRequest("subsystem", false, subsystem); err != nil {
+		channel.Close()
+		client.Close()
+		return nil, err
+	}
+
 	return &sshClientConn{
 		channel:    channel,
 		client:     client,
@@ -29,6 +29,20 @@ func (m *MockStateForHelpers) UpdateReadState(channelID uint64, subchannelID *ui
 func (m *MockStateForHelpers) GetFirstRun() bool { return false }
 func (m *MockStateForHelpers) SetFirstRunComplete() error { return nil }
 func (m *MockStateForHelpers) SaveSuccessfulConnection(serverAddress string, method string) error { return nil }
+func (m *MockStateForHelpers) GetPinnedCertificate(serverAddress string) (string, error) { return "", nil }
+func (m *MockStateForHelpers) SavePinnedCertificate(serverAddress string, fingerprint string) error { return nil }
+func (m *MockStateForHelpers) GetRatchetState(serverAddress string, channelID uint64) ([]byte, error) { return nil, nil }
+func (m *MockStateForHelpers) SaveRatchetState(serverAddress string, channelID uint64, state []byte) error { return nil }
+func (m *MockStateForHelpers) GetRatchetPlaintext(serverAddress string, digest []byte) (string, error) { return "", nil }
+func (m *MockStateForHelpers) SaveRatchetPlaintext(serverAddress string, channelID uint64, digest []byte, plaintext string) error { return nil }
+func (m *MockStateForHelpers) GetDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte) ([]byte, error) { return nil, nil }
+func (m *MockStateForHelpers) SaveDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte, state []byte) error { return nil }
+func (m *MockStateForHelpers) GetContactKey(serverAddress string, userID uint64) ([]byte, bool, error) { return nil, false, nil }
+func (m *MockStateForHelpers) SaveContactKey(serverAddress string, userID uint64, publicKey []byte) error { return nil }
+func (m *MockStateForHelpers) SetContactKeyVerified(serverAddress string, userID uint64, verified bool) error { return nil }
+func (m *MockStateForHelpers) GetResumeToken(serverAddress, nickname string) (string, error) { return "", nil }
+func (m *MockStateForHelpers) SaveResumeToken(serverAddress, nickname, token string) error { return nil }
+func (m *MockStateForHelpers) DeleteResumeToken(serverAddress, nickname string) error { return nil }
 func (m *MockStateForHelpers) GetStateDir() string { return "" }
 func (m *MockStateForHelpers) GetFirstPostWarningDismissed() bool { return false }
 func (m *MockStateForHelpers) SetFirstPostWarningDismissed() error { return nil }
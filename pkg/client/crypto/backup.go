@@ -0,0 +1,223 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// A key backup is every private key in a KeyStore, encrypted with a key
+// derived from a passphrase. Nothing else in it is readable without the
+// passphrase, so it can be kept anywhere, including on the chat server.
+//
+// Format: "SCKB" || version (1) || argon2 time (u32) || argon2 memory in KiB
+// (u32) || argon2 threads (1) || salt (16) || nonce (12) || AES-256-GCM
+// ciphertext || tag (16). Everything before the ciphertext is authenticated.
+//
+// Restoring a backup doesn't copy the keys into place: each one is saved as
+// a restored key, {name}.restored-{public key prefix}.x25519, which is only
+// used to decrypt. The machine keeps (or makes) its own device key.
+
+const (
+	// KeyBackupVersion is the current key backup format version
+	KeyBackupVersion = 1
+
+	// KeyBackupSaltSize is the size of the Argon2id salt
+	KeyBackupSaltSize = 16
+
+	// keyBackupHeaderSize is the size of everything before the nonce
+	keyBackupHeaderSize = 4 + 1 + 4 + 4 + 1 + KeyBackupSaltSize
+
+	// Argon2id parameters for new backups
+	keyBackupArgonTime    = 3
+	keyBackupArgonMemory  = 64 * 1024 // 64 MB
+	keyBackupArgonThreads = 4
+
+	// Limits on the parameters of a backup being opened, so a tampered
+	// backup can't make us use unbounded memory or time
+	maxKeyBackupArgonTime   = 16
+	maxKeyBackupArgonMemory = 1024 * 1024 // 1 GB
+)
+
+var keyBackupMagic = []byte("SCKB")
+
+var (
+	ErrInvalidKeyBackup = errors.New("invalid key backup")
+	ErrWrongPassphrase  = errors.New("wrong passphrase or corrupt key backup")
+	ErrEmptyPassphrase  = errors.New("passphrase cannot be empty")
+)
+
+// BackupKey is one private key in a key backup. Name is the key's file name
+// in the KeyStore without the extension, which says which server and user
+// it belongs to.
+type BackupKey struct {
+	Name       string
+	PrivateKey []byte
+}
+
+// EncryptKeyBackup encrypts private keys into a key backup
+func EncryptKeyBackup(keys []BackupKey, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	plaintext := new(bytes.Buffer)
+	binary.Write(plaintext, binary.BigEndian, uint16(len(keys)))
+	for _, key := range keys {
+		if len(key.PrivateKey) != X25519KeySize {
+			return nil, fmt.Errorf("%w: key %s must be %d bytes", ErrInvalidKeySize, key.Name, X25519KeySize)
+		}
+		binary.Write(plaintext, binary.BigEndian, uint16(len(key.Name)))
+		plaintext.WriteString(key.Name)
+		plaintext.Write(key.PrivateKey)
+	}
+
+	salt := make([]byte, KeyBackupSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	header := new(bytes.Buffer)
+	header.Write(keyBackupMagic)
+	header.WriteByte(KeyBackupVersion)
+	binary.Write(header, binary.BigEndian, uint32(keyBackupArgonTime))
+	binary.Write(header, binary.BigEndian, uint32(keyBackupArgonMemory))
+	header.WriteByte(keyBackupArgonThreads)
+	header.Write(salt)
+
+	key := argon2.IDKey([]byte(passphrase), salt, keyBackupArgonTime, keyBackupArgonMemory, keyBackupArgonThreads, AESKeySize)
+	return sealWithHeader(key, header.Bytes(), plaintext.Bytes())
+}
+
+// DecryptKeyBackup decrypts the private keys in a key backup
+func DecryptKeyBackup(backup []byte, passphrase string) ([]BackupKey, error) {
+	if len(backup) < keyBackupHeaderSize+NonceSize+TagSize || !bytes.Equal(backup[:4], keyBackupMagic) {
+		return nil, ErrInvalidKeyBackup
+	}
+	if backup[4] != KeyBackupVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidKeyBackup, backup[4])
+	}
+	argonTime := binary.BigEndian.Uint32(backup[5:9])
+	argonMemory := binary.BigEndian.Uint32(backup[9:13])
+	argonThreads := backup[13]
+	salt := backup[14:keyBackupHeaderSize]
+	if argonTime == 0 || argonTime > maxKeyBackupArgonTime || argonMemory == 0 || argonMemory > maxKeyBackupArgonMemory || argonThreads == 0 {
+		return nil, fmt.Errorf("%w: unsupported key derivation parameters", ErrInvalidKeyBackup)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), salt, argonTime, argonMemory, argonThreads, AESKeySize)
+	plaintext, err := openWithHeader(key, backup, keyBackupHeaderSize)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	buf := bytes.NewReader(plaintext)
+	var count uint16
+	if err := binary.Read(buf, binary.BigEndian, &count); err != nil {
+		return nil, ErrInvalidKeyBackup
+	}
+	keys := make([]BackupKey, count)
+	for i := range keys {
+		var nameLen uint16
+		if err := binary.Read(buf, binary.BigEndian, &nameLen); err != nil {
+			return nil, ErrInvalidKeyBackup
+		}
+		name := make([]byte, nameLen)
+		privateKey := make([]byte, X25519KeySize)
+		if _, err := io.ReadFull(buf, name); err != nil {
+			return nil, ErrInvalidKeyBackup
+		}
+		if _, err := io.ReadFull(buf, privateKey); err != nil {
+			return nil, ErrInvalidKeyBackup
+		}
+		keys[i] = BackupKey{Name: string(name), PrivateKey: privateKey}
+	}
+	if buf.Len() != 0 {
+		return nil, ErrInvalidKeyBackup
+	}
+	return keys, nil
+}
+
+// ExportKeys returns every private key in the store, for a key backup
+func (ks *KeyStore) ExportKeys() ([]BackupKey, error) {
+	names, err := ks.ListKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ks.keysDir()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]BackupKey, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(data) != X25519KeySize {
+			return nil, fmt.Errorf("%w: %s", ErrKeyFileCorrupt, name)
+		}
+		keys = append(keys, BackupKey{Name: strings.TrimSuffix(name, KeyFileExtension), PrivateKey: data})
+	}
+	return keys, nil
+}
+
+// ImportKeys saves the private keys of a key backup as restored keys, next
+// to the keys this machine made itself. A restored key never becomes this
+// machine's own key: it made its own device key, or makes one, so two
+// machines restored from one backup are still two devices. Restored keys
+// only open what was encrypted to them before. Keys already in the store
+// are skipped. Returns how many keys were saved.
+func (ks *KeyStore) ImportKeys(keys []BackupKey) (int, error) {
+	dir, err := ks.keysDir()
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, key := range keys {
+		// Names come from the backup, so they must not point anywhere else
+		if key.Name == "" || key.Name != sanitizeHostForFilename(key.Name) {
+			return imported, fmt.Errorf("%w: bad key name %q", ErrInvalidKeyBackup, key.Name)
+		}
+		if len(key.PrivateKey) != X25519KeySize {
+			return imported, fmt.Errorf("%w: key %s must be %d bytes", ErrInvalidKeySize, key.Name, X25519KeySize)
+		}
+		publicKey, err := X25519PrivateToPublic(key.PrivateKey)
+		if err != nil {
+			return imported, err
+		}
+
+		// Restored keys in the backup go back next to the key they were
+		// restored for. This machine's own key is already in place.
+		base, _, _ := strings.Cut(key.Name, restoredKeyMarker)
+		if existing, err := os.ReadFile(filepath.Join(dir, base+KeyFileExtension)); err == nil && bytes.Equal(existing, key.PrivateKey) {
+			continue
+		}
+		path := filepath.Join(dir, restoredKeyName(base, publicKey)+KeyFileExtension)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+
+		// Write atomically by writing to temp file first
+		tempPath := path + ".tmp"
+		if err := os.WriteFile(tempPath, key.PrivateKey, KeyFileMode); err != nil {
+			return imported, fmt.Errorf("failed to write key file: %w", err)
+		}
+		if err := os.Rename(tempPath, path); err != nil {
+			os.Remove(tempPath) // Clean up temp file
+			return imported, fmt.Errorf("failed to save key file: %w", err)
+		}
+		imported++
+	}
+	return imported, nil
+}
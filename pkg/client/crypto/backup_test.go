@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyBackup_RoundTrip(t *testing.T) {
+	source := NewKeyStore(t.TempDir())
+	registered, err := source.GenerateAndSaveKey("chat.example.com:6465", 42)
+	if err != nil {
+		t.Fatalf("GenerateAndSaveKey() error = %v", err)
+	}
+	anon, _ := GenerateX25519KeyPair()
+	if err := source.SaveAnonKey("chat.example.com:6465", "guest", anon.PrivateKey[:]); err != nil {
+		t.Fatalf("SaveAnonKey() error = %v", err)
+	}
+
+	keys, err := source.ExportKeys()
+	if err != nil {
+		t.Fatalf("ExportKeys() error = %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("ExportKeys() returned %d keys, want 2", len(keys))
+	}
+	backup, err := EncryptKeyBackup(keys, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("EncryptKeyBackup() error = %v", err)
+	}
+	if bytes.Contains(backup, registered.PrivateKey[:]) || bytes.Contains(backup, []byte("guest")) {
+		t.Error("backup contains plaintext key material")
+	}
+
+	restored, err := DecryptKeyBackup(backup, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("DecryptKeyBackup() error = %v", err)
+	}
+
+	// A new machine gets the keys back as restored keys, not as its own
+	target := NewKeyStore(t.TempDir())
+	imported, err := target.ImportKeys(restored)
+	if err != nil || imported != 2 {
+		t.Fatalf("ImportKeys() = %d, %v; want 2, nil", imported, err)
+	}
+	if _, err := target.LoadKey("chat.example.com:6465", 42); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("LoadKey() error = %v, want ErrKeyNotFound", err)
+	}
+	loaded, err := target.RestoredKeys("chat.example.com:6465", 42)
+	if err != nil || len(loaded) != 1 || !bytes.Equal(loaded[0], registered.PrivateKey[:]) {
+		t.Errorf("RestoredKeys() = %x, %v; want the exported key", loaded, err)
+	}
+	loaded, err = target.RestoredAnonKeys("chat.example.com:6465", "guest")
+	if err != nil || len(loaded) != 1 || !bytes.Equal(loaded[0], anon.PrivateKey[:]) {
+		t.Errorf("RestoredAnonKeys() = %x, %v; want the exported key", loaded, err)
+	}
+
+	// Importing again changes nothing
+	if imported, _ := target.ImportKeys(restored); imported != 0 {
+		t.Errorf("second ImportKeys() = %d; want 0", imported)
+	}
+
+	// Restored keys survive another backup and restore under the same name
+	keys, err = target.ExportKeys()
+	if err != nil {
+		t.Fatalf("ExportKeys() error = %v", err)
+	}
+	third := NewKeyStore(t.TempDir())
+	if imported, err := third.ImportKeys(keys); err != nil || imported != 2 {
+		t.Fatalf("ImportKeys(restored keys) = %d, %v; want 2, nil", imported, err)
+	}
+	if loaded, _ := third.RestoredKeys("chat.example.com:6465", 42); len(loaded) != 1 || !bytes.Equal(loaded[0], registered.PrivateKey[:]) {
+		t.Errorf("RestoredKeys() after a second restore = %x; want the exported key", loaded)
+	}
+}
+
+func TestKeyBackup_KeepsDeviceKey(t *testing.T) {
+	ks := NewKeyStore(t.TempDir())
+	current, _ := ks.GenerateAndSaveKey("chat.example.com:6465", 42)
+	old, _ := GenerateX25519KeyPair()
+	keys := []BackupKey{
+		{Name: "chat.example.com_6465_42", PrivateKey: old.PrivateKey[:]},
+		{Name: "chat.example.com_6465_42", PrivateKey: current.PrivateKey[:]},
+	}
+
+	// The other machine's key is restored next to this machine's key, and
+	// this machine's own key isn't restored again
+	if imported, err := ks.ImportKeys(keys); err != nil || imported != 1 {
+		t.Fatalf("ImportKeys() = %d, %v; want 1, nil", imported, err)
+	}
+	if loaded, _ := ks.LoadKey("chat.example.com:6465", 42); !bytes.Equal(loaded, current.PrivateKey[:]) {
+		t.Error("expected the device key to be kept")
+	}
+	if loaded, _ := ks.RestoredKeys("chat.example.com:6465", 42); len(loaded) != 1 || !bytes.Equal(loaded[0], old.PrivateKey[:]) {
+		t.Errorf("RestoredKeys() = %x; want the other machine's key", loaded)
+	}
+
+	// Names can't escape the keys directory
+	dir := t.TempDir()
+	escaping := []BackupKey{{Name: "../escaped", PrivateKey: old.PrivateKey[:]}}
+	if _, err := NewKeyStore(dir).ImportKeys(escaping); !errors.Is(err, ErrInvalidKeyBackup) {
+		t.Errorf("ImportKeys(../) error = %v, want ErrInvalidKeyBackup", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped"+KeyFileExtension)); err == nil {
+		t.Error("key was written outside the keys directory")
+	}
+}
+
+func TestKeyBackup_WrongPassphrase(t *testing.T) {
+	key, _ := GenerateX25519KeyPair()
+	backup, err := EncryptKeyBackup([]BackupKey{{Name: "host_1", PrivateKey: key.PrivateKey[:]}}, "right")
+	if err != nil {
+		t.Fatalf("EncryptKeyBackup() error = %v", err)
+	}
+
+	if _, err := DecryptKeyBackup(backup, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("DecryptKeyBackup(wrong) error = %v, want ErrWrongPassphrase", err)
+	}
+
+	// The header is authenticated
+	tampered := append([]byte{}, backup...)
+	tampered[keyBackupHeaderSize-1] ^= 0x01
+	if _, err := DecryptKeyBackup(tampered, "right"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("DecryptKeyBackup(tampered salt) error = %v, want ErrWrongPassphrase", err)
+	}
+
+	// Absurd key derivation parameters are refused before deriving
+	expensive := append([]byte{}, backup...)
+	expensive[9] = 0xFF
+	if _, err := DecryptKeyBackup(expensive, "right"); !errors.Is(err, ErrInvalidKeyBackup) {
+		t.Errorf("DecryptKeyBackup(huge memory) error = %v, want ErrInvalidKeyBackup", err)
+	}
+
+	if _, err := DecryptKeyBackup([]byte("not a backup"), "right"); !errors.Is(err, ErrInvalidKeyBackup) {
+		t.Errorf("DecryptKeyBackup(garbage) error = %v, want ErrInvalidKeyBackup", err)
+	}
+	if _, err := EncryptKeyBackup(nil, ""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Errorf("EncryptKeyBackup(\"\") error = %v, want ErrEmptyPassphrase", err)
+	}
+}
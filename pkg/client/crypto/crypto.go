@@ -1,5 +1,5 @@
-// Package crypto provides end-to-end encryption for SuperChat DMs using
-// X25519 key agreement and AES-256-GCM message encryption.
+// Package crypto provides end-to-end encryption for SuperChat DMs and private
+// channels using X25519 key agreement and AES-256-GCM message encryption.
 package crypto
 
 import (
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A multi-device DM message is encrypted separately for every device of
+// both users except the sender's, each copy with the ratchet between the
+// sender's device and that device. The copies travel together in one
+// envelope, and each device reads the one addressed to its public key.
+//
+// Format: version (1) || sender public key (32) || count (u16) || for each
+// recipient: recipient public key (32) || length (u16) || ratchet ciphertext
+
+const (
+	// DeviceEnvelopeVersion is the current envelope format version
+	DeviceEnvelopeVersion = 1
+
+	// MaxEnvelopeRecipients is the most devices one envelope is sealed for
+	MaxEnvelopeRecipients = 64
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("invalid device envelope")
+	ErrNotARecipient   = errors.New("message was not encrypted for this device")
+)
+
+// DeviceCiphertext is one recipient device's copy of a message
+type DeviceCiphertext struct {
+	PublicKey  [X25519KeySize]byte // The recipient device's X25519 public key
+	Ciphertext []byte              // Encrypted with the ratchet to that device
+}
+
+// DeviceEnvelope carries a message encrypted for several devices
+type DeviceEnvelope struct {
+	SenderPublicKey [X25519KeySize]byte // The sending device's X25519 public key
+	Recipients      []DeviceCiphertext
+}
+
+// MarshalBinary encodes the envelope
+func (e *DeviceEnvelope) MarshalBinary() ([]byte, error) {
+	if len(e.Recipients) == 0 || len(e.Recipients) > MaxEnvelopeRecipients {
+		return nil, fmt.Errorf("%w: %d recipients", ErrInvalidEnvelope, len(e.Recipients))
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(DeviceEnvelopeVersion)
+	buf.Write(e.SenderPublicKey[:])
+	binary.Write(&buf, binary.BigEndian, uint16(len(e.Recipients)))
+	for _, recipient := range e.Recipients {
+		if len(recipient.Ciphertext) > 0xFFFF {
+			return nil, fmt.Errorf("%w: ciphertext too long", ErrInvalidEnvelope)
+		}
+		buf.Write(recipient.PublicKey[:])
+		binary.Write(&buf, binary.BigEndian, uint16(len(recipient.Ciphertext)))
+		buf.Write(recipient.Ciphertext)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalDeviceEnvelope decodes an envelope
+func UnmarshalDeviceEnvelope(data []byte) (*DeviceEnvelope, error) {
+	if len(data) < 1+X25519KeySize+2 || data[0] != DeviceEnvelopeVersion {
+		return nil, ErrInvalidEnvelope
+	}
+	e := &DeviceEnvelope{}
+	copy(e.SenderPublicKey[:], data[1:])
+	count := int(binary.BigEndian.Uint16(data[1+X25519KeySize:]))
+	if count == 0 || count > MaxEnvelopeRecipients {
+		return nil, ErrInvalidEnvelope
+	}
+
+	rest := data[1+X25519KeySize+2:]
+	for i := 0; i < count; i++ {
+		if len(rest) < X25519KeySize+2 {
+			return nil, ErrInvalidEnvelope
+		}
+		var recipient DeviceCiphertext
+		copy(recipient.PublicKey[:], rest)
+		length := int(binary.BigEndian.Uint16(rest[X25519KeySize:]))
+		rest = rest[X25519KeySize+2:]
+		if len(rest) < length {
+			return nil, ErrInvalidEnvelope
+		}
+		recipient.Ciphertext = rest[:length]
+		rest = rest[length:]
+		e.Recipients = append(e.Recipients, recipient)
+	}
+	if len(rest) != 0 {
+		return nil, ErrInvalidEnvelope
+	}
+	return e, nil
+}
+
+// CiphertextFor returns the copy of the message addressed to a device
+func (e *DeviceEnvelope) CiphertextFor(publicKey []byte) ([]byte, error) {
+	for _, recipient := range e.Recipients {
+		if bytes.Equal(recipient.PublicKey[:], publicKey) {
+			return recipient.Ciphertext, nil
+		}
+	}
+	return nil, ErrNotARecipient
+}
@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDeviceEnvelope_RoundTrip(t *testing.T) {
+	// Alice's work machine sends to bob's only device and her home machine
+	work, _ := GenerateX25519KeyPair()
+	home, _ := GenerateX25519KeyPair()
+	bob, _ := GenerateX25519KeyPair()
+
+	envelope := &DeviceEnvelope{SenderPublicKey: work.PublicKey}
+	readers := map[*X25519KeyPair]*Ratchet{}
+	for _, device := range []*X25519KeyPair{home, bob} {
+		sending, err := NewRatchet(work.PrivateKey[:], work.PublicKey[:], device.PublicKey[:], 7)
+		if err != nil {
+			t.Fatalf("NewRatchet() error = %v", err)
+		}
+		readers[device], err = NewRatchet(device.PrivateKey[:], device.PublicKey[:], work.PublicKey[:], 7)
+		if err != nil {
+			t.Fatalf("NewRatchet() error = %v", err)
+		}
+		envelope.Recipients = append(envelope.Recipients, DeviceCiphertext{
+			PublicKey:  device.PublicKey,
+			Ciphertext: mustEncrypt(t, sending, "see you tomorrow"),
+		})
+	}
+
+	data, err := envelope.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	decoded, err := UnmarshalDeviceEnvelope(data)
+	if err != nil {
+		t.Fatalf("UnmarshalDeviceEnvelope() error = %v", err)
+	}
+	if decoded.SenderPublicKey != work.PublicKey {
+		t.Error("sender public key changed")
+	}
+	for device, r := range readers {
+		ciphertext, err := decoded.CiphertextFor(device.PublicKey[:])
+		if err != nil {
+			t.Fatalf("CiphertextFor() error = %v", err)
+		}
+		expectDecrypt(t, r, ciphertext, "see you tomorrow")
+	}
+
+	// The sender wasn't a recipient
+	if _, err := decoded.CiphertextFor(work.PublicKey[:]); !errors.Is(err, ErrNotARecipient) {
+		t.Errorf("CiphertextFor(sender) error = %v, want ErrNotARecipient", err)
+	}
+}
+
+func TestDeviceEnvelope_Invalid(t *testing.T) {
+	envelope := &DeviceEnvelope{Recipients: []DeviceCiphertext{{Ciphertext: []byte("ciphertext")}}}
+	data, err := envelope.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	tests := map[string][]byte{
+		"empty":             nil,
+		"wrong version":     append([]byte{2}, data[1:]...),
+		"truncated":         data[:len(data)-1],
+		"trailing bytes":    append(append([]byte(nil), data...), 0),
+		"no recipients":     append(append([]byte(nil), data[:1+X25519KeySize]...), 0, 0),
+		"static ciphertext": bytes.Repeat([]byte{0x42}, 80),
+	}
+	for name, data := range tests {
+		if _, err := UnmarshalDeviceEnvelope(data); !errors.Is(err, ErrInvalidEnvelope) {
+			t.Errorf("%s: error = %v, want ErrInvalidEnvelope", name, err)
+		}
+	}
+
+	if _, err := (&DeviceEnvelope{}).MarshalBinary(); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("MarshalBinary() without recipients error = %v, want ErrInvalidEnvelope", err)
+	}
+}
@@ -0,0 +1,181 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Private channels have more than two members, so they can't use a pairwise
+// shared secret. Instead one member generates a random channel key and wraps
+// it for every member's X25519 public key. Each new key gets the next epoch
+// number, and a new key is made whenever someone joins or leaves, so a
+// removed member never learns the keys used after they left.
+
+const (
+	// EpochSize is the size of the epoch prefix on group ciphertexts
+	EpochSize = 4
+
+	// WrappedKeySize is the size of a wrapped channel key:
+	// ephemeral public key (32) || nonce (12) || encrypted key (32) || tag (16)
+	WrappedKeySize = X25519KeySize + NonceSize + AESKeySize + TagSize
+
+	// GroupHKDFSalt is the salt used to derive key wrapping keys
+	GroupHKDFSalt = "superchat-group-v1"
+)
+
+var (
+	ErrInvalidWrappedKey = errors.New("invalid wrapped channel key")
+	ErrUnknownEpoch      = errors.New("no channel key for this epoch")
+	ErrNoChannelKey      = errors.New("no channel key")
+)
+
+// GenerateChannelKey generates a random AES-256 key for a private channel.
+func GenerateChannelKey() ([]byte, error) {
+	key := make([]byte, AESKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyGenerationFailed, err)
+	}
+	return key, nil
+}
+
+// WrapChannelKey encrypts a channel key for one member. A fresh ephemeral
+// key pair is used for every wrap, and the wrapping key is bound to the
+// channel and epoch, so a wrapped key can't be replayed for another epoch.
+// Returns: ephemeral public key (32 bytes) || nonce || encrypted key || tag
+func WrapChannelKey(channelKey, recipientPublicKey []byte, channelID uint64, epoch uint32) ([]byte, error) {
+	if len(channelKey) != AESKeySize {
+		return nil, fmt.Errorf("%w: channel key must be %d bytes", ErrInvalidKeySize, AESKeySize)
+	}
+
+	ephemeral, err := GenerateX25519KeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	wrappingKey, err := deriveWrappingKey(ephemeral.PrivateKey[:], recipientPublicKey, ephemeral.PublicKey[:], recipientPublicKey, channelID, epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := EncryptMessage(wrappingKey, channelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ephemeral.PublicKey[:], encrypted...), nil
+}
+
+// UnwrapChannelKey decrypts a channel key wrapped with WrapChannelKey.
+// It fails with ErrDecryptionFailed if the key was wrapped for someone else,
+// or for a different channel or epoch.
+func UnwrapChannelKey(wrapped, myPrivateKey []byte, channelID uint64, epoch uint32) ([]byte, error) {
+	if len(wrapped) != WrappedKeySize {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidWrappedKey, WrappedKeySize, len(wrapped))
+	}
+
+	myPublicKey, err := X25519PrivateToPublic(myPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralPublicKey := wrapped[:X25519KeySize]
+	wrappingKey, err := deriveWrappingKey(myPrivateKey, ephemeralPublicKey, ephemeralPublicKey, myPublicKey, channelID, epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptMessage(wrappingKey, wrapped[X25519KeySize:])
+}
+
+// deriveWrappingKey derives the AES key that wraps a channel key from the
+// X25519 shared secret, binding both public keys, the channel and the epoch.
+func deriveWrappingKey(privateKey, peerPublicKey, ephemeralPublicKey, recipientPublicKey []byte, channelID uint64, epoch uint32) ([]byte, error) {
+	sharedSecret, err := ComputeSharedSecret(privateKey, peerPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	info := make([]byte, 0, 2*X25519KeySize+8+EpochSize)
+	info = append(info, ephemeralPublicKey...)
+	info = append(info, recipientPublicKey...)
+	info = binary.BigEndian.AppendUint64(info, channelID)
+	info = binary.BigEndian.AppendUint32(info, epoch)
+
+	hkdfReader := hkdf.New(sha512.New, sharedSecret, []byte(GroupHKDFSalt), info)
+
+	key := make([]byte, AESKeySize)
+	if _, err := io.ReadFull(hkdfReader, key); err != nil {
+		return nil, fmt.Errorf("HKDF key derivation failed: %w", err)
+	}
+
+	return key, nil
+}
+
+// GroupKeyring holds the keys of one private channel by epoch. Messages are
+// encrypted with the newest key and decrypted with whichever key their epoch
+// names, so history from earlier epochs stays readable.
+type GroupKeyring struct {
+	keys    map[uint32][]byte
+	current uint32
+}
+
+// NewGroupKeyring creates an empty keyring.
+func NewGroupKeyring() *GroupKeyring {
+	return &GroupKeyring{keys: make(map[uint32][]byte)}
+}
+
+// Add stores the channel key for an epoch. The newest epoch becomes the one
+// used for encryption.
+func (k *GroupKeyring) Add(epoch uint32, key []byte) error {
+	if len(key) != AESKeySize {
+		return fmt.Errorf("%w: channel key must be %d bytes", ErrInvalidKeySize, AESKeySize)
+	}
+	k.keys[epoch] = append([]byte(nil), key...)
+	if epoch > k.current {
+		k.current = epoch
+	}
+	return nil
+}
+
+// Epoch returns the newest epoch in the keyring (0 if it is empty).
+func (k *GroupKeyring) Epoch() uint32 {
+	return k.current
+}
+
+// Encrypt encrypts a message with the newest channel key.
+// Returns: epoch (4 bytes) || nonce (12 bytes) || ciphertext || tag (16 bytes)
+func (k *GroupKeyring) Encrypt(plaintext []byte) ([]byte, error) {
+	key, ok := k.keys[k.current]
+	if !ok {
+		return nil, ErrNoChannelKey
+	}
+
+	encrypted, err := EncryptMessage(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(binary.BigEndian.AppendUint32(make([]byte, 0, EpochSize+len(encrypted)), k.current), encrypted...), nil
+}
+
+// Decrypt decrypts a message encrypted with Encrypt. It fails with
+// ErrUnknownEpoch if the keyring doesn't have the key the message was
+// encrypted with.
+func (k *GroupKeyring) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < EpochSize+NonceSize+TagSize {
+		return nil, ErrInvalidCiphertext
+	}
+
+	key, ok := k.keys[binary.BigEndian.Uint32(ciphertext[:EpochSize])]
+	if !ok {
+		return nil, ErrUnknownEpoch
+	}
+
+	return DecryptMessage(key, ciphertext[EpochSize:])
+}
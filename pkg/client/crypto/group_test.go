@@ -0,0 +1,224 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWrapUnwrapChannelKey(t *testing.T) {
+	bob, err := GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatalf("GenerateX25519KeyPair() error = %v", err)
+	}
+	channelKey, err := GenerateChannelKey()
+	if err != nil {
+		t.Fatalf("GenerateChannelKey() error = %v", err)
+	}
+
+	wrapped, err := WrapChannelKey(channelKey, bob.PublicKey[:], 42, 1)
+	if err != nil {
+		t.Fatalf("WrapChannelKey() error = %v", err)
+	}
+	if len(wrapped) != WrappedKeySize {
+		t.Errorf("wrapped key size = %d, want %d", len(wrapped), WrappedKeySize)
+	}
+
+	unwrapped, err := UnwrapChannelKey(wrapped, bob.PrivateKey[:], 42, 1)
+	if err != nil {
+		t.Fatalf("UnwrapChannelKey() error = %v", err)
+	}
+	if !bytes.Equal(channelKey, unwrapped) {
+		t.Error("unwrapped key doesn't match the channel key")
+	}
+
+	// Wrapping twice uses different ephemeral keys
+	again, err := WrapChannelKey(channelKey, bob.PublicKey[:], 42, 1)
+	if err != nil {
+		t.Fatalf("WrapChannelKey() second call error = %v", err)
+	}
+	if bytes.Equal(wrapped, again) {
+		t.Error("two wraps of the same key are identical")
+	}
+}
+
+func TestUnwrapChannelKey_Rejects(t *testing.T) {
+	bob, _ := GenerateX25519KeyPair()
+	carol, _ := GenerateX25519KeyPair()
+	channelKey, _ := GenerateChannelKey()
+
+	wrapped, err := WrapChannelKey(channelKey, bob.PublicKey[:], 42, 1)
+	if err != nil {
+		t.Fatalf("WrapChannelKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		wrapped    []byte
+		privateKey []byte
+		channelID  uint64
+		epoch      uint32
+	}{
+		{"other recipient", wrapped, carol.PrivateKey[:], 42, 1},
+		{"other channel", wrapped, bob.PrivateKey[:], 43, 1},
+		{"other epoch", wrapped, bob.PrivateKey[:], 42, 2},
+		{"truncated", wrapped[:WrappedKeySize-1], bob.PrivateKey[:], 42, 1},
+		{"tampered", func() []byte {
+			tampered := append([]byte(nil), wrapped...)
+			tampered[WrappedKeySize-1] ^= 0xFF
+			return tampered
+		}(), bob.PrivateKey[:], 42, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := UnwrapChannelKey(tt.wrapped, tt.privateKey, tt.channelID, tt.epoch); err == nil {
+				t.Error("UnwrapChannelKey() expected an error")
+			}
+		})
+	}
+}
+
+func TestWrapChannelKey_InvalidInput(t *testing.T) {
+	bob, _ := GenerateX25519KeyPair()
+	if _, err := WrapChannelKey(make([]byte, 16), bob.PublicKey[:], 42, 1); err == nil {
+		t.Error("WrapChannelKey() expected an error for a short channel key")
+	}
+	channelKey, _ := GenerateChannelKey()
+	if _, err := WrapChannelKey(channelKey, lowOrderPoints[0][:], 42, 1); err == nil {
+		t.Error("WrapChannelKey() expected an error for a low order public key")
+	}
+}
+
+func TestGroupKeyring(t *testing.T) {
+	keyring := NewGroupKeyring()
+	if _, err := keyring.Encrypt([]byte("hello")); !errors.Is(err, ErrNoChannelKey) {
+		t.Errorf("Encrypt() on an empty keyring error = %v, want ErrNoChannelKey", err)
+	}
+
+	key1, _ := GenerateChannelKey()
+	key2, _ := GenerateChannelKey()
+	if err := keyring.Add(1, key1); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	old, err := keyring.Encrypt([]byte("epoch one"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	if err := keyring.Add(2, key2); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	// Adding an older key later doesn't change the current epoch
+	if err := keyring.Add(1, key1); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if keyring.Epoch() != 2 {
+		t.Errorf("Epoch() = %d, want 2", keyring.Epoch())
+	}
+
+	current, err := keyring.Encrypt([]byte("epoch two"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if _, err := DecryptMessage(key2, current[EpochSize:]); err != nil {
+		t.Errorf("expected the newest key to be used: %v", err)
+	}
+
+	for ciphertext, want := range map[string]string{string(old): "epoch one", string(current): "epoch two"} {
+		plaintext, err := keyring.Decrypt([]byte(ciphertext))
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		if string(plaintext) != want {
+			t.Errorf("Decrypt() = %q, want %q", plaintext, want)
+		}
+	}
+
+	if _, err := keyring.Decrypt(current[:EpochSize+NonceSize]); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Decrypt() of a short ciphertext error = %v, want ErrInvalidCiphertext", err)
+	}
+	if err := keyring.Add(3, make([]byte, 16)); err == nil {
+		t.Error("Add() expected an error for a short key")
+	}
+}
+
+// TestGroupRekeyExcludesRemovedMember walks through a private channel where
+// a member is removed and the remaining members rekey.
+func TestGroupRekeyExcludesRemovedMember(t *testing.T) {
+	const channelID = uint64(7)
+
+	type member struct {
+		keys    *X25519KeyPair
+		keyring *GroupKeyring
+	}
+	newMember := func() *member {
+		kp, err := GenerateX25519KeyPair()
+		if err != nil {
+			t.Fatalf("GenerateX25519KeyPair() error = %v", err)
+		}
+		return &member{keys: kp, keyring: NewGroupKeyring()}
+	}
+	alice, bob, carol := newMember(), newMember(), newMember()
+
+	// distribute wraps a new channel key for the recipients, who each add it
+	// to their keyring
+	distribute := func(epoch uint32, recipients ...*member) {
+		t.Helper()
+		channelKey, err := GenerateChannelKey()
+		if err != nil {
+			t.Fatalf("GenerateChannelKey() error = %v", err)
+		}
+		for _, m := range recipients {
+			wrapped, err := WrapChannelKey(channelKey, m.keys.PublicKey[:], channelID, epoch)
+			if err != nil {
+				t.Fatalf("WrapChannelKey() error = %v", err)
+			}
+			key, err := UnwrapChannelKey(wrapped, m.keys.PrivateKey[:], channelID, epoch)
+			if err != nil {
+				t.Fatalf("UnwrapChannelKey() error = %v", err)
+			}
+			if err := m.keyring.Add(epoch, key); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+		}
+	}
+
+	distribute(1, alice, bob, carol)
+	before, err := alice.keyring.Encrypt([]byte("before carol left"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	// Carol is removed, so Alice rekeys for the members who remain. The
+	// wrapped key for Bob is useless to Carol.
+	channelKey, _ := GenerateChannelKey()
+	wrappedForBob, err := WrapChannelKey(channelKey, bob.keys.PublicKey[:], channelID, 2)
+	if err != nil {
+		t.Fatalf("WrapChannelKey() error = %v", err)
+	}
+	if _, err := UnwrapChannelKey(wrappedForBob, carol.keys.PrivateKey[:], channelID, 2); err == nil {
+		t.Error("expected Carol to be unable to unwrap Bob's key")
+	}
+	distribute(2, alice, bob)
+
+	after, err := alice.keyring.Encrypt([]byte("after carol left"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	if plaintext, err := bob.keyring.Decrypt(after); err != nil || string(plaintext) != "after carol left" {
+		t.Errorf("expected Bob to read the new message, got %q (%v)", plaintext, err)
+	}
+	if _, err := carol.keyring.Decrypt(after); !errors.Is(err, ErrUnknownEpoch) {
+		t.Errorf("expected Carol to be unable to read the new message, got error %v", err)
+	}
+	// Her old key is no use on the new message either
+	if _, err := DecryptMessage(carol.keyring.keys[1], after[EpochSize:]); err == nil {
+		t.Error("expected the old key to fail on the new message")
+	}
+	// But she can still read what was sent while she was a member
+	if plaintext, err := carol.keyring.Decrypt(before); err != nil || string(plaintext) != "before carol left" {
+		t.Errorf("expected Carol to read the old message, got %q (%v)", plaintext, err)
+	}
+}
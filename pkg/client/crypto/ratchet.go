@@ -0,0 +1,475 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// A DM encrypted with the static channel key from DeriveChannelKey can be
+// read by anyone who later gets hold of either user's X25519 private key.
+// Ratcheted DMs follow the Double Ratchet algorithm instead: every message
+// has its own key, message keys are deleted once used, and each reply mixes
+// a fresh X25519 key pair into the root key. A leaked static key therefore
+// only exposes the messages sent before the other party first replied.
+//
+// The static channel key is the shared secret the ratchet starts from. The
+// user with the smaller static public key starts the first DH ratchet step
+// (the "initiator"); the other user can send before hearing from them on a
+// bootstrap chain derived from the same secret.
+
+const (
+	// RatchetHeaderSize is the size of the header on ratchet ciphertexts:
+	// ratchet public key (32) || previous chain length (4) || message number (4)
+	RatchetHeaderSize = X25519KeySize + 8
+
+	// MaxSkip is the most message keys one message may skip over, and the
+	// most skipped keys kept for late messages
+	MaxSkip = 1000
+
+	// RatchetHKDFInfo is the HKDF info used to advance the root key
+	RatchetHKDFInfo = "superchat-ratchet-v1"
+
+	// ratchetBootstrapInfo derives the responder's first sending chain
+	ratchetBootstrapInfo = "superchat-ratchet-bootstrap"
+
+	// ratchetStateVersion is the version of the MarshalBinary format
+	ratchetStateVersion = 1
+)
+
+var (
+	ErrTooManySkipped      = errors.New("too many skipped messages")
+	ErrInvalidRatchetState = errors.New("invalid ratchet state")
+	ErrSameStaticKey       = errors.New("both parties have the same static key")
+)
+
+// RatchetHeader is sent in the clear in front of every ratchet ciphertext
+// and authenticated with it
+type RatchetHeader struct {
+	PublicKey [X25519KeySize]byte // Sender's current ratchet public key
+	PN        uint32              // Number of messages in the sender's previous sending chain
+	N         uint32              // Message number in the current sending chain
+}
+
+func (h RatchetHeader) bytes() []byte {
+	b := make([]byte, RatchetHeaderSize)
+	copy(b, h.PublicKey[:])
+	binary.BigEndian.PutUint32(b[X25519KeySize:], h.PN)
+	binary.BigEndian.PutUint32(b[X25519KeySize+4:], h.N)
+	return b
+}
+
+// ParseRatchetHeader reads the header of a ratchet ciphertext
+func ParseRatchetHeader(ciphertext []byte) (RatchetHeader, error) {
+	var h RatchetHeader
+	if len(ciphertext) < RatchetHeaderSize+NonceSize+TagSize {
+		return h, ErrInvalidCiphertext
+	}
+	copy(h.PublicKey[:], ciphertext)
+	h.PN = binary.BigEndian.Uint32(ciphertext[X25519KeySize:])
+	h.N = binary.BigEndian.Uint32(ciphertext[X25519KeySize+4:])
+	return h, nil
+}
+
+type skippedKey struct {
+	publicKey [X25519KeySize]byte
+	n         uint32
+}
+
+// Ratchet is the Double Ratchet state of one DM channel on one side
+type Ratchet struct {
+	dhs     X25519KeyPair        // Our current ratchet key pair
+	dhr     *[X25519KeySize]byte // Their current ratchet public key
+	rootKey []byte
+	sendKey []byte // Sending chain key, nil until we can send
+	recvKey []byte // Receiving chain key, nil until they have sent
+	ns, nr  uint32 // Message numbers in the sending and receiving chains
+	pn      uint32 // Length of our previous sending chain
+
+	// Keys of messages that were skipped over, oldest first, for messages
+	// that arrive late or out of order
+	skipped      map[skippedKey][]byte
+	skippedOrder []skippedKey
+}
+
+// NewRatchet starts the ratchet of a DM channel from both users' static
+// X25519 keys. Both sides derive matching states without exchanging any
+// messages first.
+func NewRatchet(myPrivateKey, myPublicKey, theirPublicKey []byte, channelID uint64) (*Ratchet, error) {
+	if len(myPrivateKey) != X25519KeySize || len(myPublicKey) != X25519KeySize {
+		return nil, fmt.Errorf("%w: key pair must be %d bytes", ErrInvalidKeySize, X25519KeySize)
+	}
+	sharedSecret, err := ComputeSharedSecret(myPrivateKey, theirPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := DeriveChannelKey(sharedSecret, channelID)
+	if err != nil {
+		return nil, err
+	}
+	bootstrap := make([]byte, AESKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha512.New, secret, nil, []byte(ratchetBootstrapInfo)), bootstrap); err != nil {
+		return nil, fmt.Errorf("HKDF key derivation failed: %w", err)
+	}
+
+	r := &Ratchet{skipped: make(map[skippedKey][]byte)}
+	var theirs [X25519KeySize]byte
+	copy(theirs[:], theirPublicKey)
+
+	switch bytes.Compare(myPublicKey, theirPublicKey) {
+	case 0:
+		return nil, ErrSameStaticKey
+	case -1:
+		// Initiator: ratchet straight away against their static key, and
+		// accept their bootstrap chain until they ratchet back
+		dhs, err := GenerateX25519KeyPair()
+		if err != nil {
+			return nil, err
+		}
+		r.dhs = *dhs
+		r.dhr = &theirs
+		r.rootKey, r.sendKey, err = kdfRootKey(secret, r.dhs.PrivateKey[:], theirPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		r.recvKey = bootstrap
+	default:
+		// Responder: our static key is the first ratchet key
+		copy(r.dhs.PrivateKey[:], myPrivateKey)
+		copy(r.dhs.PublicKey[:], myPublicKey)
+		r.rootKey = secret
+		r.sendKey = bootstrap
+	}
+	return r, nil
+}
+
+// Encrypt encrypts a message with the next key of the sending chain.
+// Returns: header (40 bytes) || nonce (12 bytes) || ciphertext || tag (16 bytes)
+func (r *Ratchet) Encrypt(plaintext []byte) ([]byte, error) {
+	header := RatchetHeader{PublicKey: r.dhs.PublicKey, PN: r.pn, N: r.ns}
+	messageKey, nextKey := kdfChainKey(r.sendKey)
+
+	ciphertext, err := sealWithHeader(messageKey, header.bytes(), plaintext)
+	if err != nil {
+		return nil, err
+	}
+	r.sendKey = nextKey
+	r.ns++
+	return ciphertext, nil
+}
+
+// Decrypt decrypts a message from the other party, performing a DH ratchet
+// step if they have a new ratchet key. Keys of skipped messages are kept so
+// they can be decrypted when they arrive. The state is left untouched if
+// the message can't be decrypted.
+func (r *Ratchet) Decrypt(ciphertext []byte) ([]byte, error) {
+	header, err := ParseRatchetHeader(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	key := skippedKey{publicKey: header.PublicKey, n: header.N}
+	if messageKey, ok := r.skipped[key]; ok {
+		plaintext, err := openWithHeader(messageKey, ciphertext, RatchetHeaderSize)
+		if err != nil {
+			return nil, err
+		}
+		r.forgetSkipped(key)
+		return plaintext, nil
+	}
+
+	next := r.clone()
+	if next.dhr == nil || header.PublicKey != *next.dhr {
+		if err := next.skipMessageKeys(header.PN); err != nil {
+			return nil, err
+		}
+		if err := next.dhRatchet(header.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+	if err := next.skipMessageKeys(header.N); err != nil {
+		return nil, err
+	}
+	messageKey, nextKey := kdfChainKey(next.recvKey)
+	next.recvKey = nextKey
+	next.nr++
+
+	plaintext, err := openWithHeader(messageKey, ciphertext, RatchetHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	*r = *next
+	return plaintext, nil
+}
+
+// skipMessageKeys stores the keys of the receiving chain's messages up to
+// (but not including) message number until
+func (r *Ratchet) skipMessageKeys(until uint32) error {
+	if r.recvKey == nil || until <= r.nr {
+		return nil
+	}
+	if until-r.nr > MaxSkip {
+		return ErrTooManySkipped
+	}
+	for r.nr < until {
+		messageKey, nextKey := kdfChainKey(r.recvKey)
+		key := skippedKey{publicKey: *r.dhr, n: r.nr}
+		r.skipped[key] = messageKey
+		r.skippedOrder = append(r.skippedOrder, key)
+		r.recvKey = nextKey
+		r.nr++
+	}
+	// Forget the oldest skipped keys; those messages are lost
+	for len(r.skippedOrder) > MaxSkip {
+		delete(r.skipped, r.skippedOrder[0])
+		r.skippedOrder = r.skippedOrder[1:]
+	}
+	return nil
+}
+
+// dhRatchet moves to their new ratchet key: a new receiving chain from
+// their key, then a new key pair of ours and a new sending chain
+func (r *Ratchet) dhRatchet(theirPublicKey [X25519KeySize]byte) error {
+	r.pn = r.ns
+	r.ns = 0
+	r.nr = 0
+	r.dhr = &theirPublicKey
+
+	var err error
+	r.rootKey, r.recvKey, err = kdfRootKey(r.rootKey, r.dhs.PrivateKey[:], theirPublicKey[:])
+	if err != nil {
+		return err
+	}
+	dhs, err := GenerateX25519KeyPair()
+	if err != nil {
+		return err
+	}
+	r.dhs = *dhs
+	r.rootKey, r.sendKey, err = kdfRootKey(r.rootKey, r.dhs.PrivateKey[:], theirPublicKey[:])
+	return err
+}
+
+func (r *Ratchet) forgetSkipped(key skippedKey) {
+	delete(r.skipped, key)
+	for i, k := range r.skippedOrder {
+		if k == key {
+			r.skippedOrder = append(r.skippedOrder[:i:i], r.skippedOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *Ratchet) clone() *Ratchet {
+	c := *r
+	if r.dhr != nil {
+		dhr := *r.dhr
+		c.dhr = &dhr
+	}
+	c.skipped = make(map[skippedKey][]byte, len(r.skipped))
+	for k, v := range r.skipped {
+		c.skipped[k] = v
+	}
+	c.skippedOrder = append([]skippedKey(nil), r.skippedOrder...)
+	return &c
+}
+
+// MarshalBinary encodes the ratchet state for storage. The state holds
+// private keys and must be stored as carefully as the static key.
+func (r *Ratchet) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(ratchetStateVersion)
+	buf.Write(r.dhs.PrivateKey[:])
+	buf.Write(r.dhs.PublicKey[:])
+	writeOptionalKey(buf, r.dhr)
+	buf.Write(r.rootKey)
+	writeOptionalKey(buf, chainKeyArray(r.sendKey))
+	writeOptionalKey(buf, chainKeyArray(r.recvKey))
+	for _, n := range []uint32{r.ns, r.nr, r.pn, uint32(len(r.skippedOrder))} {
+		binary.Write(buf, binary.BigEndian, n)
+	}
+	for _, key := range r.skippedOrder {
+		buf.Write(key.publicKey[:])
+		binary.Write(buf, binary.BigEndian, key.n)
+		buf.Write(r.skipped[key])
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalRatchet decodes a ratchet state encoded with MarshalBinary
+func UnmarshalRatchet(data []byte) (*Ratchet, error) {
+	buf := bytes.NewReader(data)
+	version, err := buf.ReadByte()
+	if err != nil || version != ratchetStateVersion {
+		return nil, ErrInvalidRatchetState
+	}
+
+	r := &Ratchet{rootKey: make([]byte, AESKeySize), skipped: make(map[skippedKey][]byte)}
+	if _, err := io.ReadFull(buf, r.dhs.PrivateKey[:]); err != nil {
+		return nil, ErrInvalidRatchetState
+	}
+	if _, err := io.ReadFull(buf, r.dhs.PublicKey[:]); err != nil {
+		return nil, ErrInvalidRatchetState
+	}
+	if r.dhr, err = readOptionalKey(buf); err != nil {
+		return nil, ErrInvalidRatchetState
+	}
+	if _, err := io.ReadFull(buf, r.rootKey); err != nil {
+		return nil, ErrInvalidRatchetState
+	}
+	sendKey, err := readOptionalKey(buf)
+	if err != nil {
+		return nil, ErrInvalidRatchetState
+	}
+	recvKey, err := readOptionalKey(buf)
+	if err != nil {
+		return nil, ErrInvalidRatchetState
+	}
+	if sendKey != nil {
+		r.sendKey = sendKey[:]
+	}
+	if recvKey != nil {
+		r.recvKey = recvKey[:]
+	}
+
+	var skippedCount uint32
+	for _, n := range []*uint32{&r.ns, &r.nr, &r.pn, &skippedCount} {
+		if err := binary.Read(buf, binary.BigEndian, n); err != nil {
+			return nil, ErrInvalidRatchetState
+		}
+	}
+	if skippedCount > MaxSkip {
+		return nil, ErrInvalidRatchetState
+	}
+	for i := uint32(0); i < skippedCount; i++ {
+		var key skippedKey
+		messageKey := make([]byte, AESKeySize)
+		if _, err := io.ReadFull(buf, key.publicKey[:]); err != nil {
+			return nil, ErrInvalidRatchetState
+		}
+		if err := binary.Read(buf, binary.BigEndian, &key.n); err != nil {
+			return nil, ErrInvalidRatchetState
+		}
+		if _, err := io.ReadFull(buf, messageKey); err != nil {
+			return nil, ErrInvalidRatchetState
+		}
+		r.skipped[key] = messageKey
+		r.skippedOrder = append(r.skippedOrder, key)
+	}
+	if buf.Len() != 0 {
+		return nil, ErrInvalidRatchetState
+	}
+	return r, nil
+}
+
+func chainKeyArray(key []byte) *[AESKeySize]byte {
+	if key == nil {
+		return nil
+	}
+	var arr [AESKeySize]byte
+	copy(arr[:], key)
+	return &arr
+}
+
+func writeOptionalKey(buf *bytes.Buffer, key *[32]byte) {
+	if key == nil {
+		buf.WriteByte(0)
+		return
+	}
+	buf.WriteByte(1)
+	buf.Write(key[:])
+}
+
+func readOptionalKey(buf *bytes.Reader) (*[32]byte, error) {
+	present, err := buf.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if present == 0 {
+		return nil, nil
+	}
+	var key [32]byte
+	if _, err := io.ReadFull(buf, key[:]); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// kdfRootKey mixes a DH output into the root key, returning the next root
+// key and a new chain key
+func kdfRootKey(rootKey, myPrivateKey, theirPublicKey []byte) ([]byte, []byte, error) {
+	dh, err := ComputeSharedSecret(myPrivateKey, theirPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make([]byte, 2*AESKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha512.New, dh, rootKey, []byte(RatchetHKDFInfo)), out); err != nil {
+		return nil, nil, fmt.Errorf("HKDF key derivation failed: %w", err)
+	}
+	return out[:AESKeySize], out[AESKeySize:], nil
+}
+
+// kdfChainKey returns the message key for a chain key and the next chain key
+func kdfChainKey(chainKey []byte) ([]byte, []byte) {
+	derive := func(b byte) []byte {
+		mac := hmac.New(sha256.New, chainKey)
+		mac.Write([]byte{b})
+		return mac.Sum(nil)
+	}
+	return derive(0x01), derive(0x02)
+}
+
+// sealWithHeader encrypts with AES-256-GCM, authenticating the header
+// Returns: header || nonce || ciphertext || tag
+func sealWithHeader(key, header, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append(append([]byte{}, header...), nonce...)
+	return gcm.Seal(out, nonce, plaintext, header), nil
+}
+
+// openWithHeader decrypts a ciphertext made by sealWithHeader with a header
+// of headerSize bytes
+func openWithHeader(key, ciphertext []byte, headerSize int) ([]byte, error) {
+	if len(ciphertext) < headerSize+NonceSize+TagSize {
+		return nil, ErrInvalidCiphertext
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := ciphertext[:headerSize]
+	nonce := ciphertext[headerSize : headerSize+NonceSize]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext[headerSize+NonceSize:], header)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
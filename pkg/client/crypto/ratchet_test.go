@@ -0,0 +1,211 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// newRatchetPair starts both sides of a DM's ratchet
+func newRatchetPair(t *testing.T) (alice, bob *Ratchet, aliceKeys, bobKeys *X25519KeyPair) {
+	t.Helper()
+	aliceKeys, _ = GenerateX25519KeyPair()
+	bobKeys, _ = GenerateX25519KeyPair()
+
+	var err error
+	alice, err = NewRatchet(aliceKeys.PrivateKey[:], aliceKeys.PublicKey[:], bobKeys.PublicKey[:], 42)
+	if err != nil {
+		t.Fatalf("NewRatchet(alice) error = %v", err)
+	}
+	bob, err = NewRatchet(bobKeys.PrivateKey[:], bobKeys.PublicKey[:], aliceKeys.PublicKey[:], 42)
+	if err != nil {
+		t.Fatalf("NewRatchet(bob) error = %v", err)
+	}
+	return alice, bob, aliceKeys, bobKeys
+}
+
+func mustEncrypt(t *testing.T, r *Ratchet, plaintext string) []byte {
+	t.Helper()
+	ciphertext, err := r.Encrypt([]byte(plaintext))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	return ciphertext
+}
+
+func expectDecrypt(t *testing.T, r *Ratchet, ciphertext []byte, want string) {
+	t.Helper()
+	plaintext, err := r.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt(%q) error = %v", want, err)
+	}
+	if string(plaintext) != want {
+		t.Errorf("Decrypt() = %q, want %q", plaintext, want)
+	}
+}
+
+func TestRatchet_Conversation(t *testing.T) {
+	alice, bob, _, _ := newRatchetPair(t)
+
+	// Either side may send first
+	for _, sender := range []string{"alice", "bob"} {
+		t.Run(sender+" sends first", func(t *testing.T) {
+			a, b, _, _ := newRatchetPair(t)
+			first, second := a, b
+			if sender == "bob" {
+				first, second = b, a
+			}
+			expectDecrypt(t, second, mustEncrypt(t, first, "hello"), "hello")
+			expectDecrypt(t, first, mustEncrypt(t, second, "hi"), "hi")
+			expectDecrypt(t, second, mustEncrypt(t, first, "how are you?"), "how are you?")
+		})
+	}
+
+	// Every message uses a different key, so the same plaintext never
+	// encrypts the same way
+	one := mustEncrypt(t, alice, "same")
+	two := mustEncrypt(t, alice, "same")
+	if bytes.Equal(one[RatchetHeaderSize:], two[RatchetHeaderSize:]) {
+		t.Error("two messages encrypted identically")
+	}
+	expectDecrypt(t, bob, one, "same")
+	expectDecrypt(t, bob, two, "same")
+
+	// Replies move both sides to new ratchet keys
+	headerBefore, _ := ParseRatchetHeader(two)
+	expectDecrypt(t, alice, mustEncrypt(t, bob, "reply"), "reply")
+	headerAfter, _ := ParseRatchetHeader(mustEncrypt(t, alice, "next"))
+	if headerBefore.PublicKey == headerAfter.PublicKey {
+		t.Error("expected a new ratchet key after a reply")
+	}
+	if headerAfter.PN != 2 || headerAfter.N != 0 {
+		t.Errorf("header = PN %d N %d, want PN 2 N 0", headerAfter.PN, headerAfter.N)
+	}
+
+	// Replays are rejected
+	if _, err := bob.Decrypt(one); err == nil {
+		t.Error("expected a replayed message to fail")
+	}
+}
+
+func TestRatchet_OutOfOrder(t *testing.T) {
+	alice, bob, _, _ := newRatchetPair(t)
+
+	m1 := mustEncrypt(t, alice, "one")
+	m2 := mustEncrypt(t, alice, "two")
+	m3 := mustEncrypt(t, alice, "three")
+
+	expectDecrypt(t, bob, m3, "three")
+	expectDecrypt(t, alice, mustEncrypt(t, bob, "got three"), "got three")
+
+	// Messages from an earlier chain still decrypt after a ratchet step
+	m4 := mustEncrypt(t, alice, "four")
+	expectDecrypt(t, bob, m4, "four")
+	expectDecrypt(t, bob, m1, "one")
+	expectDecrypt(t, bob, m2, "two")
+
+	// Skipped keys are deleted once used
+	if _, err := bob.Decrypt(m2); err == nil {
+		t.Error("expected a second decryption of a skipped message to fail")
+	}
+}
+
+func TestRatchet_TooManySkipped(t *testing.T) {
+	alice, bob, _, _ := newRatchetPair(t)
+
+	for i := 0; i <= MaxSkip; i++ {
+		mustEncrypt(t, alice, "lost")
+	}
+	if _, err := bob.Decrypt(mustEncrypt(t, alice, "too late")); !errors.Is(err, ErrTooManySkipped) {
+		t.Errorf("Decrypt() error = %v, want ErrTooManySkipped", err)
+	}
+}
+
+func TestRatchet_FailedDecryptLeavesState(t *testing.T) {
+	alice, bob, _, _ := newRatchetPair(t)
+
+	m1 := mustEncrypt(t, alice, "one")
+	tampered := append([]byte{}, m1...)
+	tampered[len(tampered)-1] ^= 0xFF
+	if _, err := bob.Decrypt(tampered); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("Decrypt(tampered) error = %v, want ErrDecryptionFailed", err)
+	}
+	expectDecrypt(t, bob, m1, "one")
+
+	// The header is authenticated too
+	m2 := mustEncrypt(t, alice, "two")
+	m2[X25519KeySize+4] ^= 0x01
+	if _, err := bob.Decrypt(m2); err == nil {
+		t.Error("expected a tampered header to fail")
+	}
+
+	if _, err := bob.Decrypt([]byte("short")); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Decrypt(short) error = %v, want ErrInvalidCiphertext", err)
+	}
+}
+
+func TestRatchet_LeakedStaticKeys(t *testing.T) {
+	alice, bob, aliceKeys, bobKeys := newRatchetPair(t)
+
+	early := mustEncrypt(t, alice, "before the reply")
+	expectDecrypt(t, bob, early, "before the reply")
+	expectDecrypt(t, alice, mustEncrypt(t, bob, "reply"), "reply")
+	later := mustEncrypt(t, alice, "after the reply")
+	expectDecrypt(t, bob, later, "after the reply")
+
+	// An attacker with both static private keys and every ciphertext can
+	// start their own ratchets, but can't follow the fresh ratchet keys
+	attacker, err := NewRatchet(bobKeys.PrivateKey[:], bobKeys.PublicKey[:], aliceKeys.PublicKey[:], 42)
+	if err != nil {
+		t.Fatalf("NewRatchet() error = %v", err)
+	}
+	if _, err := attacker.Decrypt(later); err == nil {
+		t.Error("expected messages after a reply to stay secret")
+	}
+
+	// The static channel key doesn't decrypt ratchet messages either
+	sharedSecret, _ := ComputeSharedSecret(aliceKeys.PrivateKey[:], bobKeys.PublicKey[:])
+	staticKey, _ := DeriveChannelKey(sharedSecret, 42)
+	if _, err := DecryptMessage(staticKey, later); err == nil {
+		t.Error("expected the static key to fail")
+	}
+}
+
+func TestRatchet_MarshalBinary(t *testing.T) {
+	alice, bob, _, _ := newRatchetPair(t)
+
+	m1 := mustEncrypt(t, alice, "one")
+	m2 := mustEncrypt(t, alice, "two")
+	expectDecrypt(t, bob, m2, "two")
+
+	// Round trip both sides, including bob's skipped key for m1
+	for _, r := range []**Ratchet{&alice, &bob} {
+		data, err := (*r).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() error = %v", err)
+		}
+		restored, err := UnmarshalRatchet(data)
+		if err != nil {
+			t.Fatalf("UnmarshalRatchet() error = %v", err)
+		}
+		*r = restored
+	}
+
+	expectDecrypt(t, bob, m1, "one")
+	expectDecrypt(t, alice, mustEncrypt(t, bob, "reply"), "reply")
+	expectDecrypt(t, bob, mustEncrypt(t, alice, "three"), "three")
+
+	data, _ := alice.MarshalBinary()
+	for _, bad := range [][]byte{nil, {2}, data[:len(data)-1], append(data, 0)} {
+		if _, err := UnmarshalRatchet(bad); !errors.Is(err, ErrInvalidRatchetState) {
+			t.Errorf("UnmarshalRatchet(%d bytes) error = %v, want ErrInvalidRatchetState", len(bad), err)
+		}
+	}
+}
+
+func TestNewRatchet_SameKey(t *testing.T) {
+	keys, _ := GenerateX25519KeyPair()
+	if _, err := NewRatchet(keys.PrivateKey[:], keys.PublicKey[:], keys.PublicKey[:], 1); !errors.Is(err, ErrSameStaticKey) {
+		t.Errorf("NewRatchet() error = %v, want ErrSameStaticKey", err)
+	}
+}
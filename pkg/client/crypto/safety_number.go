@@ -0,0 +1,62 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// A safety number lets two users check that the server gave each of them the
+// other's real public key. Both sides compute it from the same two keys, so
+// it's the same on both screens unless a key was swapped along the way.
+
+const (
+	// SafetyNumberIterations is how often each key is hashed, to make finding
+	// a key with a matching safety number expensive
+	SafetyNumberIterations = 5200
+
+	// SafetyNumberVersion is mixed into the hash so the format can change
+	SafetyNumberVersion = 0
+
+	// safetyNumberGroups is the number of 5-digit groups per key
+	safetyNumberGroups = 6
+)
+
+// SafetyNumber returns the safety number of two X25519 public keys as 12
+// groups of 5 digits. The order of the keys doesn't matter.
+func SafetyNumber(publicKeyA, publicKeyB []byte) (string, error) {
+	if len(publicKeyA) != X25519KeySize || len(publicKeyB) != X25519KeySize {
+		return "", fmt.Errorf("%w: public keys must be %d bytes", ErrInvalidKeySize, X25519KeySize)
+	}
+
+	first, second := publicKeyA, publicKeyB
+	if bytes.Compare(first, second) > 0 {
+		first, second = second, first
+	}
+
+	groups := append(keyFingerprint(first), keyFingerprint(second)...)
+	return strings.Join(groups, " "), nil
+}
+
+// keyFingerprint hashes one public key into its 5-digit groups
+func keyFingerprint(publicKey []byte) []string {
+	var version [2]byte
+	binary.BigEndian.PutUint16(version[:], SafetyNumberVersion)
+
+	hash := append(version[:], publicKey...)
+	for i := 0; i < SafetyNumberIterations; i++ {
+		sum := sha512.Sum512(append(hash, publicKey...))
+		hash = sum[:]
+	}
+
+	// Every 5 bytes of the hash make one group
+	groups := make([]string, safetyNumberGroups)
+	for i := range groups {
+		chunk := hash[i*5 : i*5+5]
+		n := uint64(chunk[0])<<32 | uint64(chunk[1])<<24 | uint64(chunk[2])<<16 | uint64(chunk[3])<<8 | uint64(chunk[4])
+		groups[i] = fmt.Sprintf("%05d", n%100000)
+	}
+	return groups
+}
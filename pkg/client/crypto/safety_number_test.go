@@ -0,0 +1,37 @@
+package crypto
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestSafetyNumber(t *testing.T) {
+	alice, _ := GenerateX25519KeyPair()
+	bob, _ := GenerateX25519KeyPair()
+	mallory, _ := GenerateX25519KeyPair()
+
+	aliceView, err := SafetyNumber(alice.PublicKey[:], bob.PublicKey[:])
+	if err != nil {
+		t.Fatalf("SafetyNumber() error = %v", err)
+	}
+	if !regexp.MustCompile(`^\d{5}( \d{5}){11}$`).MatchString(aliceView) {
+		t.Errorf("SafetyNumber() = %q, want 12 groups of 5 digits", aliceView)
+	}
+
+	// Both sides see the same number
+	bobView, _ := SafetyNumber(bob.PublicKey[:], alice.PublicKey[:])
+	if aliceView != bobView {
+		t.Errorf("safety numbers differ: %q and %q", aliceView, bobView)
+	}
+
+	// A swapped key gives a different number
+	swapped, _ := SafetyNumber(alice.PublicKey[:], mallory.PublicKey[:])
+	if swapped == aliceView {
+		t.Error("expected a different safety number for a different key")
+	}
+
+	if _, err := SafetyNumber(alice.PublicKey[:16], bob.PublicKey[:]); !errors.Is(err, ErrInvalidKeySize) {
+		t.Errorf("SafetyNumber(short key) error = %v, want ErrInvalidKeySize", err)
+	}
+}
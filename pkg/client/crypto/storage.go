@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -15,6 +16,10 @@ const (
 	// KeyFileExtension is the extension for key files
 	KeyFileExtension = ".x25519"
 
+	// restoredKeyMarker separates the name of a key from the public key
+	// prefix of a key restored for it from a backup
+	restoredKeyMarker = ".restored-"
+
 	// KeyFileMode is the file permission for key files (owner read/write only)
 	KeyFileMode = 0600
 
@@ -319,6 +324,59 @@ func (ks *KeyStore) ListKeys() ([]string, error) {
 	return keys, nil
 }
 
+// restoredKeyName is the file name, without the extension, of a key restored
+// from a backup for the key named base
+func restoredKeyName(base string, publicKey []byte) string {
+	return base + restoredKeyMarker + hex.EncodeToString(publicKey[:4])
+}
+
+// RestoredKeys returns the keys restored from backups for a user on a server
+// (see ImportKeys). They aren't the user's key on this machine; they only
+// decrypt what was encrypted to the user's other machines.
+func (ks *KeyStore) RestoredKeys(serverHost string, userID uint64) ([][]byte, error) {
+	path, err := ks.keyFilePath(serverHost, userID)
+	if err != nil {
+		return nil, err
+	}
+	return loadRestoredKeys(path)
+}
+
+// RestoredAnonKeys returns the keys restored from backups for an anonymous
+// user on a server
+func (ks *KeyStore) RestoredAnonKeys(serverHost string, nickname string) ([][]byte, error) {
+	path, err := ks.anonKeyFilePath(serverHost, nickname)
+	if err != nil {
+		return nil, err
+	}
+	return loadRestoredKeys(path)
+}
+
+// loadRestoredKeys reads the restored keys stored for the key file at path
+func loadRestoredKeys(path string) ([][]byte, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(filepath.Base(path), KeyFileExtension) + restoredKeyMarker
+	var keys [][]byte
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, KeyFileExtension) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(data) != X25519KeySize {
+			return nil, fmt.Errorf("%w: %s", ErrKeyFileCorrupt, name)
+		}
+		keys = append(keys, data)
+	}
+	return keys, nil
+}
+
 // GenerateAndSaveKey generates a new key pair and saves it.
 // This is a convenience function for first-time key setup.
 func (ks *KeyStore) GenerateAndSaveKey(serverHost string, userID uint64) (*X25519KeyPair, error) {
@@ -74,8 +74,27 @@ func FormatThreadItem(thread protocol.Message, maxPreviewChars int) string {
 		replyCount = fmt.Sprintf(" (%d)", thread.ReplyCount)
 	}
 
-	// Format: "author preview  time(replies)"
-	return fmt.Sprintf("%s %s  %s%s", author, preview, timeStr, replyCount)
+	// Format +1s
+	plusOnes := FormatPlusOnes(thread.PlusOneCount, thread.PlusOneAnonCount)
+	if plusOnes != "" {
+		plusOnes = "  " + plusOnes
+	}
+
+	// Format: "author preview  time(replies)  +1s"
+	return fmt.Sprintf("%s %s  %s%s%s", author, preview, timeStr, replyCount, plusOnes)
+}
+
+// FormatPlusOnes formats +1 counts as "+42 (+∞ 1337)".
+// The anonymous part is omitted when zero; returns "" when there are no +1s.
+func FormatPlusOnes(registered, anonymous uint32) string {
+	switch {
+	case registered == 0 && anonymous == 0:
+		return ""
+	case anonymous == 0:
+		return fmt.Sprintf("+%d", registered)
+	default:
+		return fmt.Sprintf("+%d (+∞ %d)", registered, anonymous)
+	}
 }
 
 // ExtractThreadTitle extracts the title from thread content
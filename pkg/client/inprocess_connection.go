@@ -0,0 +1,216 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// InProcessConnection is a ConnectionInterface over an already established
+// net.Conn, typically one end of a net.Pipe whose other end is served by a
+// server in the same process. The server uses it to run the TUI for users who
+// connect over SSH without a client. It never reconnects: once the conn is
+// closed the connection is gone for good.
+type InProcessConnection struct {
+	conn    net.Conn
+	address string // Display address, e.g. ssh://alice@chat.example.com
+	rawAddr string
+
+	mu        sync.RWMutex
+	sendMu    sync.Mutex // Serializes frame writes
+	connected bool
+	started   bool
+	closed    bool
+
+	incoming    chan *protocol.Frame
+	errors      chan error
+	stateChange chan ConnectionStateUpdate
+	done        chan struct{}
+
+	bytesSent     atomic.Uint64
+	bytesReceived atomic.Uint64
+}
+
+// NewInProcessConnection wraps conn. Call Connect to start reading frames.
+func NewInProcessConnection(conn net.Conn, address, rawAddr string) *InProcessConnection {
+	return &InProcessConnection{
+		conn:        conn,
+		address:     address,
+		rawAddr:     rawAddr,
+		incoming:    make(chan *protocol.Frame, 100),
+		errors:      make(chan error, 10),
+		stateChange: make(chan ConnectionStateUpdate, 10),
+		done:        make(chan struct{}),
+	}
+}
+
+// Connect starts reading frames. It fails once the connection has been lost,
+// since there is nothing to reconnect to.
+func (c *InProcessConnection) Connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		if c.connected {
+			return nil
+		}
+		return fmt.Errorf("session closed")
+	}
+
+	c.started = true
+	c.connected = true
+	go c.readLoop()
+	return nil
+}
+
+// Disconnect closes the underlying conn
+func (c *InProcessConnection) Disconnect() {
+	c.mu.Lock()
+	c.connected = false
+	c.mu.Unlock()
+	c.conn.Close()
+}
+
+// Close closes the connection and stops the read loop
+func (c *InProcessConnection) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	c.Disconnect()
+	close(c.done)
+}
+
+// IsConnected returns whether the connection is active
+func (c *InProcessConnection) IsConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connected
+}
+
+// GetAddress returns the display address
+func (c *InProcessConnection) GetAddress() string {
+	return c.address
+}
+
+// GetRawAddress returns the address without scheme
+func (c *InProcessConnection) GetRawAddress() string {
+	return c.rawAddr
+}
+
+// GetConnectionType returns "ssh", since in-process connections serve SSH users
+func (c *InProcessConnection) GetConnectionType() string {
+	return "ssh"
+}
+
+// Send writes a frame to the server
+func (c *InProcessConnection) Send(frame *protocol.Frame) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("connection closed")
+	}
+
+	var buf bytes.Buffer
+	if err := protocol.EncodeFrame(&buf, frame, protocol.ProtocolVersion); err != nil {
+		return fmt.Errorf("encode error: %w", err)
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	n, err := c.conn.Write(buf.Bytes())
+	c.bytesSent.Add(uint64(n))
+	return err
+}
+
+// SendMessage encodes and sends a message
+func (c *InProcessConnection) SendMessage(msgType uint8, msg interface{}) error {
+	m, ok := msg.(interface{ Encode() ([]byte, error) })
+	if !ok {
+		return fmt.Errorf("message type does not implement Encode()")
+	}
+	payload, err := m.Encode()
+	if err != nil {
+		return err
+	}
+
+	return c.Send(&protocol.Frame{
+		Version: protocol.ProtocolVersion,
+		Type:    msgType,
+		Flags:   0,
+		Payload: payload,
+	})
+}
+
+// Incoming returns the channel for receiving frames from the server
+func (c *InProcessConnection) Incoming() <-chan *protocol.Frame {
+	return c.incoming
+}
+
+// Errors returns the channel for connection errors
+func (c *InProcessConnection) Errors() <-chan error {
+	return c.errors
+}
+
+// StateChanges returns the channel for connection state updates
+func (c *InProcessConnection) StateChanges() <-chan ConnectionStateUpdate {
+	return c.stateChange
+}
+
+// DisableAutoReconnect is a no-op; in-process connections never reconnect
+func (c *InProcessConnection) DisableAutoReconnect() {}
+
+// EnableAutoReconnect is a no-op; in-process connections never reconnect
+func (c *InProcessConnection) EnableAutoReconnect() {}
+
+// SetThrottle is a no-op; the SSH channel already limits the user's bandwidth
+func (c *InProcessConnection) SetThrottle(bytesPerSec int) {}
+
+// GetBytesSent returns the total bytes sent
+func (c *InProcessConnection) GetBytesSent() uint64 {
+	return c.bytesSent.Load()
+}
+
+// GetBytesReceived returns the total bytes received
+func (c *InProcessConnection) GetBytesReceived() uint64 {
+	return c.bytesReceived.Load()
+}
+
+// readLoop reads frames until the conn is closed
+func (c *InProcessConnection) readLoop() {
+	reader := &countingReader{r: c.conn, counter: &c.bytesReceived}
+	for {
+		frame, err := protocol.DecodeFrame(reader)
+		if err != nil {
+			c.mu.Lock()
+			wasConnected := c.connected
+			c.connected = false
+			c.mu.Unlock()
+
+			if wasConnected {
+				disconnectErr := fmt.Errorf("disconnected from server")
+				if err != io.EOF {
+					disconnectErr = fmt.Errorf("read error: %w", err)
+				}
+				select {
+				case c.stateChange <- ConnectionStateUpdate{State: StateTypeDisconnected, Err: disconnectErr}:
+				default:
+				}
+			}
+			return
+		}
+
+		select {
+		case c.incoming <- frame:
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestInProcessConnectionRoundTrip(t *testing.T) {
+	serverEnd, clientEnd := net.Pipe()
+	defer serverEnd.Close()
+
+	conn := NewInProcessConnection(clientEnd, "ssh://alice@example.com", "example.com")
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Client -> server
+	sent := make(chan error, 1)
+	go func() {
+		sent <- conn.SendMessage(protocol.TypePing, &protocol.PingMessage{Timestamp: 42})
+	}()
+	frame, err := protocol.DecodeFrame(serverEnd)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if frame.Type != protocol.TypePing {
+		t.Fatalf("expected PING, got 0x%02X", frame.Type)
+	}
+
+	// Server -> client
+	go protocol.EncodeFrame(serverEnd, &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypePong})
+	select {
+	case frame := <-conn.Incoming():
+		if frame.Type != protocol.TypePong {
+			t.Fatalf("expected PONG, got 0x%02X", frame.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for frame")
+	}
+
+	if conn.GetBytesSent() == 0 || conn.GetBytesReceived() == 0 {
+		t.Errorf("expected traffic to be counted, got sent=%d received=%d", conn.GetBytesSent(), conn.GetBytesReceived())
+	}
+}
+
+func TestInProcessConnectionDoesNotReconnect(t *testing.T) {
+	serverEnd, clientEnd := net.Pipe()
+
+	conn := NewInProcessConnection(clientEnd, "ssh://alice@example.com", "example.com")
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer conn.Close()
+
+	serverEnd.Close()
+	select {
+	case update := <-conn.StateChanges():
+		if update.State != StateTypeDisconnected {
+			t.Fatalf("expected disconnected state, got %v", update.State)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for disconnect")
+	}
+
+	if conn.IsConnected() {
+		t.Error("expected connection to be closed")
+	}
+	if err := conn.Connect(); err == nil {
+		t.Error("expected Connect to fail after the session closed")
+	}
+}
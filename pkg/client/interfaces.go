@@ -68,6 +68,30 @@ type StateInterface interface {
 	GetLastSuccessfulMethod(serverAddress string) (string, error)
 	SaveSuccessfulConnection(serverAddress string, method string) error
 
+	// TLS certificate pinning (trust on first use)
+	GetPinnedCertificate(serverAddress string) (string, error)
+	SavePinnedCertificate(serverAddress string, fingerprint string) error
+
+	// Ratcheted DMs (state and decrypted messages)
+	GetRatchetState(serverAddress string, channelID uint64) ([]byte, error)
+	SaveRatchetState(serverAddress string, channelID uint64, state []byte) error
+	GetRatchetPlaintext(serverAddress string, digest []byte) (string, error)
+	SaveRatchetPlaintext(serverAddress string, channelID uint64, digest []byte, plaintext string) error
+
+	// Multi-device DMs (a ratchet per device of the other party)
+	GetDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte) ([]byte, error)
+	SaveDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte, state []byte) error
+
+	// Contact encryption keys (safety number verification)
+	GetContactKey(serverAddress string, userID uint64) ([]byte, bool, error)
+	SaveContactKey(serverAddress string, userID uint64, publicKey []byte) error
+	SetContactKeyVerified(serverAddress string, userID uint64, verified bool) error
+
+	// Resume tokens (logging in again without the password)
+	GetResumeToken(serverAddress, nickname string) (string, error)
+	SaveResumeToken(serverAddress, nickname, token string) error
+	DeleteResumeToken(serverAddress, nickname string) error
+
 	// Last seen timestamp (for anonymous user unread counts)
 	GetLastSeenTimestamp() int64
 	SetLastSeenTimestamp(timestamp int64) error
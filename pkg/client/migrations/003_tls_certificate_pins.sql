@@ -0,0 +1,9 @@
+-- Migration 003: Trust-on-first-use TLS certificate pins
+-- Stores the certificate fingerprint first seen for each sc+tls:// server
+
+CREATE TABLE IF NOT EXISTS TLSCertificatePin (
+	server_address TEXT PRIMARY KEY,  -- host:port
+	fingerprint TEXT NOT NULL,        -- SHA256:base64 of the leaf certificate
+	first_seen_at INTEGER NOT NULL,
+	last_seen_at INTEGER NOT NULL
+);
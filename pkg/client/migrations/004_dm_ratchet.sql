@@ -0,0 +1,20 @@
+-- Migration 004: Ratcheted DM state
+-- RatchetState holds the Double Ratchet state of each ratcheted DM, including
+-- private keys. Message keys are deleted once used, so RatchetPlaintext keeps
+-- decrypted messages (and our own sent ones) to show history again.
+
+CREATE TABLE IF NOT EXISTS RatchetState (
+	server_address TEXT NOT NULL,
+	channel_id INTEGER NOT NULL,
+	state BLOB NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (server_address, channel_id)
+);
+
+CREATE TABLE IF NOT EXISTS RatchetPlaintext (
+	server_address TEXT NOT NULL,
+	channel_id INTEGER NOT NULL,
+	digest BLOB NOT NULL,             -- SHA-256 of the ciphertext
+	plaintext TEXT NOT NULL,
+	PRIMARY KEY (server_address, digest)
+);
@@ -0,0 +1,14 @@
+-- Migration 005: Contact encryption keys
+-- ContactKey remembers the last encryption public key seen for each user, so
+-- the client can warn when it changes, and whether the user verified it by
+-- comparing safety numbers.
+
+CREATE TABLE IF NOT EXISTS ContactKey (
+	server_address TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	public_key BLOB NOT NULL,
+	verified INTEGER NOT NULL DEFAULT 0,
+	first_seen_at INTEGER NOT NULL,   -- When this key was first seen
+	last_seen_at INTEGER NOT NULL,
+	PRIMARY KEY (server_address, user_id)
+);
@@ -0,0 +1,13 @@
+-- Migration 006: Multi-device DM ratchets
+-- A multi-device DM has a Double Ratchet between this device and every other
+-- device of both users, keyed by the other device's public key. Decrypted
+-- messages are kept in RatchetPlaintext like those of other ratcheted DMs.
+
+CREATE TABLE IF NOT EXISTS DeviceRatchetState (
+	server_address TEXT NOT NULL,
+	channel_id INTEGER NOT NULL,
+	other_public_key BLOB NOT NULL,   -- The other device's X25519 public key
+	state BLOB NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (server_address, channel_id, other_public_key)
+);
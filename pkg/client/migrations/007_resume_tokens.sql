@@ -0,0 +1,12 @@
+-- Migration 007: Resume tokens
+-- After logging in with a password, the server gives us a resume token so we
+-- can log in again after reconnecting or restarting without the password.
+-- One token per registered nickname on each server.
+
+CREATE TABLE IF NOT EXISTS ResumeToken (
+	server_address TEXT NOT NULL,
+	nickname TEXT NOT NULL,
+	token TEXT NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (server_address, nickname)
+);
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 	"time"
@@ -13,6 +14,11 @@ type MockState struct {
 	// In-memory storage
 	config    map[string]string
 	readState map[uint64]ReadStateData
+	certPins  map[string]string
+	ratchets  map[string][]byte
+	plaintext map[string]string
+	contacts  map[string]mockContactKey
+	tokens    map[string]string
 	dir       string
 
 	// Error injection
@@ -23,6 +29,12 @@ type MockState struct {
 	setFirstRunCompleteErr error
 }
 
+// mockContactKey is a remembered contact encryption key
+type mockContactKey struct {
+	publicKey []byte
+	verified  bool
+}
+
 // ReadStateData holds read state information
 type ReadStateData struct {
 	LastReadAt        int64
@@ -34,6 +46,11 @@ func NewMockState() *MockState {
 	return &MockState{
 		config:    make(map[string]string),
 		readState: make(map[uint64]ReadStateData),
+		certPins:  make(map[string]string),
+		ratchets:  make(map[string][]byte),
+		plaintext: make(map[string]string),
+		contacts:  make(map[string]mockContactKey),
+		tokens:    make(map[string]string),
 		dir:       "/tmp/mock-state",
 	}
 }
@@ -212,6 +229,11 @@ func (s *MockState) Clear() {
 	defer s.mu.Unlock()
 	s.config = make(map[string]string)
 	s.readState = make(map[uint64]ReadStateData)
+	s.certPins = make(map[string]string)
+	s.ratchets = make(map[string][]byte)
+	s.plaintext = make(map[string]string)
+	s.contacts = make(map[string]mockContactKey)
+	s.tokens = make(map[string]string)
 }
 
 // GetLastSuccessfulMethod retrieves the last successful connection method (mock)
@@ -224,6 +246,124 @@ func (s *MockState) SaveSuccessfulConnection(serverAddress string, method string
 	return nil // Mock: no-op
 }
 
+// GetPinnedCertificate returns the pinned TLS certificate fingerprint (mock)
+func (s *MockState) GetPinnedCertificate(serverAddress string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.certPins[serverAddress], nil
+}
+
+// SavePinnedCertificate pins a TLS certificate fingerprint (mock)
+func (s *MockState) SavePinnedCertificate(serverAddress string, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.certPins[serverAddress] = fingerprint
+	return nil
+}
+
+// GetRatchetState returns the stored ratchet state of a DM channel (mock)
+func (s *MockState) GetRatchetState(serverAddress string, channelID uint64) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ratchets[fmt.Sprintf("%s/%d", serverAddress, channelID)], nil
+}
+
+// SaveRatchetState stores the ratchet state of a DM channel (mock)
+func (s *MockState) SaveRatchetState(serverAddress string, channelID uint64, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratchets[fmt.Sprintf("%s/%d", serverAddress, channelID)] = state
+	return nil
+}
+
+// GetRatchetPlaintext returns the plaintext of a ratcheted DM message (mock)
+func (s *MockState) GetRatchetPlaintext(serverAddress string, digest []byte) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.plaintext[fmt.Sprintf("%s/%x", serverAddress, digest)], nil
+}
+
+// SaveRatchetPlaintext stores the plaintext of a ratcheted DM message (mock)
+func (s *MockState) SaveRatchetPlaintext(serverAddress string, channelID uint64, digest []byte, plaintext string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plaintext[fmt.Sprintf("%s/%x", serverAddress, digest)] = plaintext
+	return nil
+}
+
+// GetDeviceRatchetState returns the stored ratchet state between this device
+// and another device in a multi-device DM (mock)
+func (s *MockState) GetDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ratchets[fmt.Sprintf("%s/%d/%x", serverAddress, channelID, otherPublicKey)], nil
+}
+
+// SaveDeviceRatchetState stores the ratchet state between this device and
+// another device in a multi-device DM (mock)
+func (s *MockState) SaveDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratchets[fmt.Sprintf("%s/%d/%x", serverAddress, channelID, otherPublicKey)] = state
+	return nil
+}
+
+// GetContactKey returns the remembered encryption key of a user (mock)
+func (s *MockState) GetContactKey(serverAddress string, userID uint64) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	contact := s.contacts[fmt.Sprintf("%s/%d", serverAddress, userID)]
+	return contact.publicKey, contact.verified, nil
+}
+
+// SaveContactKey remembers the encryption key of a user (mock)
+func (s *MockState) SaveContactKey(serverAddress string, userID uint64, publicKey []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s/%d", serverAddress, userID)
+	contact := s.contacts[key]
+	if !bytes.Equal(contact.publicKey, publicKey) {
+		contact = mockContactKey{publicKey: append([]byte(nil), publicKey...)}
+	}
+	s.contacts[key] = contact
+	return nil
+}
+
+// SetContactKeyVerified marks the remembered key of a user as verified (mock)
+func (s *MockState) SetContactKeyVerified(serverAddress string, userID uint64, verified bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s/%d", serverAddress, userID)
+	if contact, ok := s.contacts[key]; ok {
+		contact.verified = verified
+		s.contacts[key] = contact
+	}
+	return nil
+}
+
+// GetResumeToken returns the resume token for a nickname on a server (mock)
+func (s *MockState) GetResumeToken(serverAddress, nickname string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tokens[fmt.Sprintf("%s/%s", serverAddress, nickname)], nil
+}
+
+// SaveResumeToken stores the resume token for a nickname on a server (mock)
+func (s *MockState) SaveResumeToken(serverAddress, nickname, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[fmt.Sprintf("%s/%s", serverAddress, nickname)] = token
+	return nil
+}
+
+// DeleteResumeToken forgets the resume token for a nickname on a server (mock)
+func (s *MockState) DeleteResumeToken(serverAddress, nickname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, fmt.Sprintf("%s/%s", serverAddress, nickname))
+	return nil
+}
+
 // GetFirstPostWarningDismissed checks if the first post warning has been dismissed (mock)
 func (s *MockState) GetFirstPostWarningDismissed() bool {
 	val, _ := s.GetConfig("first_post_warning_dismissed")
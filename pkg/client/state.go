@@ -169,6 +169,204 @@ func (s *State) SaveSuccessfulConnection(serverAddress string, method string) er
 	return err
 }
 
+// GetPinnedCertificate returns the pinned TLS certificate fingerprint for a server
+// Returns "" if no certificate has been pinned yet
+func (s *State) GetPinnedCertificate(serverAddress string) (string, error) {
+	var fingerprint string
+	err := s.db.QueryRow(`
+		SELECT fingerprint
+		FROM TLSCertificatePin
+		WHERE server_address = ?
+	`, serverAddress).Scan(&fingerprint)
+
+	if err == sql.ErrNoRows {
+		return "", nil // Never connected via TLS
+	}
+	return fingerprint, err
+}
+
+// SavePinnedCertificate pins a TLS certificate fingerprint for a server
+func (s *State) SavePinnedCertificate(serverAddress string, fingerprint string) error {
+	now := time.Now().Unix()
+	_, err := s.db.Exec(`
+		INSERT INTO TLSCertificatePin (server_address, fingerprint, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(server_address) DO UPDATE SET
+			fingerprint = excluded.fingerprint,
+			first_seen_at = CASE WHEN fingerprint = excluded.fingerprint THEN first_seen_at ELSE excluded.first_seen_at END,
+			last_seen_at = excluded.last_seen_at
+	`, serverAddress, fingerprint, now, now)
+	return err
+}
+
+// DeletePinnedCertificate forgets the pinned TLS certificate for a server
+func (s *State) DeletePinnedCertificate(serverAddress string) error {
+	_, err := s.db.Exec("DELETE FROM TLSCertificatePin WHERE server_address = ?", serverAddress)
+	return err
+}
+
+// GetRatchetState returns the stored ratchet state of a DM channel
+// Returns nil if the DM has no ratchet state yet
+func (s *State) GetRatchetState(serverAddress string, channelID uint64) ([]byte, error) {
+	var state []byte
+	err := s.db.QueryRow(`
+		SELECT state
+		FROM RatchetState
+		WHERE server_address = ? AND channel_id = ?
+	`, serverAddress, channelID).Scan(&state)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return state, err
+}
+
+// SaveRatchetState stores the ratchet state of a DM channel
+func (s *State) SaveRatchetState(serverAddress string, channelID uint64, state []byte) error {
+	_, err := s.db.Exec(`
+		INSERT INTO RatchetState (server_address, channel_id, state, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(server_address, channel_id) DO UPDATE SET
+			state = excluded.state,
+			updated_at = excluded.updated_at
+	`, serverAddress, channelID, state, time.Now().Unix())
+	return err
+}
+
+// GetRatchetPlaintext returns the plaintext of a ratcheted DM message by the
+// SHA-256 digest of its ciphertext
+// Returns "" if the message hasn't been decrypted before
+func (s *State) GetRatchetPlaintext(serverAddress string, digest []byte) (string, error) {
+	var plaintext string
+	err := s.db.QueryRow(`
+		SELECT plaintext
+		FROM RatchetPlaintext
+		WHERE server_address = ? AND digest = ?
+	`, serverAddress, digest).Scan(&plaintext)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return plaintext, err
+}
+
+// SaveRatchetPlaintext stores the plaintext of a ratcheted DM message
+func (s *State) SaveRatchetPlaintext(serverAddress string, channelID uint64, digest []byte, plaintext string) error {
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO RatchetPlaintext (server_address, channel_id, digest, plaintext)
+		VALUES (?, ?, ?, ?)
+	`, serverAddress, channelID, digest, plaintext)
+	return err
+}
+
+// GetDeviceRatchetState returns the stored ratchet state between this device
+// and another device in a multi-device DM
+// Returns nil if there is no ratchet state yet
+func (s *State) GetDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte) ([]byte, error) {
+	var state []byte
+	err := s.db.QueryRow(`
+		SELECT state
+		FROM DeviceRatchetState
+		WHERE server_address = ? AND channel_id = ? AND other_public_key = ?
+	`, serverAddress, channelID, otherPublicKey).Scan(&state)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return state, err
+}
+
+// SaveDeviceRatchetState stores the ratchet state between this device and
+// another device in a multi-device DM
+func (s *State) SaveDeviceRatchetState(serverAddress string, channelID uint64, otherPublicKey []byte, state []byte) error {
+	_, err := s.db.Exec(`
+		INSERT INTO DeviceRatchetState (server_address, channel_id, other_public_key, state, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(server_address, channel_id, other_public_key) DO UPDATE SET
+			state = excluded.state,
+			updated_at = excluded.updated_at
+	`, serverAddress, channelID, otherPublicKey, state, time.Now().Unix())
+	return err
+}
+
+// GetContactKey returns the last encryption public key seen for a user and
+// whether it was verified
+// Returns a nil key if no key has been seen for the user yet
+func (s *State) GetContactKey(serverAddress string, userID uint64) ([]byte, bool, error) {
+	var publicKey []byte
+	var verified bool
+	err := s.db.QueryRow(`
+		SELECT public_key, verified
+		FROM ContactKey
+		WHERE server_address = ? AND user_id = ?
+	`, serverAddress, userID).Scan(&publicKey, &verified)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	return publicKey, verified, err
+}
+
+// SaveContactKey remembers the encryption public key seen for a user. A
+// different key than the remembered one is no longer verified.
+func (s *State) SaveContactKey(serverAddress string, userID uint64, publicKey []byte) error {
+	now := time.Now().Unix()
+	_, err := s.db.Exec(`
+		INSERT INTO ContactKey (server_address, user_id, public_key, verified, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, 0, ?, ?)
+		ON CONFLICT(server_address, user_id) DO UPDATE SET
+			verified = CASE WHEN public_key = excluded.public_key THEN verified ELSE 0 END,
+			first_seen_at = CASE WHEN public_key = excluded.public_key THEN first_seen_at ELSE excluded.first_seen_at END,
+			public_key = excluded.public_key,
+			last_seen_at = excluded.last_seen_at
+	`, serverAddress, userID, publicKey, now, now)
+	return err
+}
+
+// SetContactKeyVerified marks the remembered key of a user as verified or not
+func (s *State) SetContactKeyVerified(serverAddress string, userID uint64, verified bool) error {
+	_, err := s.db.Exec(`
+		UPDATE ContactKey SET verified = ? WHERE server_address = ? AND user_id = ?
+	`, verified, serverAddress, userID)
+	return err
+}
+
+// GetResumeToken returns the resume token for a nickname on a server
+// Returns an empty string if there is none
+func (s *State) GetResumeToken(serverAddress, nickname string) (string, error) {
+	var token string
+	err := s.db.QueryRow(`
+		SELECT token
+		FROM ResumeToken
+		WHERE server_address = ? AND nickname = ?
+	`, serverAddress, nickname).Scan(&token)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return token, err
+}
+
+// SaveResumeToken stores the resume token for a nickname on a server
+func (s *State) SaveResumeToken(serverAddress, nickname, token string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO ResumeToken (server_address, nickname, token, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(server_address, nickname) DO UPDATE SET
+			token = excluded.token,
+			updated_at = excluded.updated_at
+	`, serverAddress, nickname, token, time.Now().Unix())
+	return err
+}
+
+// DeleteResumeToken forgets the resume token for a nickname on a server
+func (s *State) DeleteResumeToken(serverAddress, nickname string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM ResumeToken WHERE server_address = ? AND nickname = ?
+	`, serverAddress, nickname)
+	return err
+}
+
 // GetFirstRun checks if this is the first time running the client
 func (s *State) GetFirstRun() bool {
 	val, _ := s.GetConfig("first_run_complete")
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificatePinStore persists trust-on-first-use TLS certificate pins
+type CertificatePinStore interface {
+	GetPinnedCertificate(serverAddress string) (string, error)
+	SavePinnedCertificate(serverAddress string, fingerprint string) error
+}
+
+// ErrCertificateChanged is returned when a server presents a certificate that
+// doesn't match the pinned one and isn't trusted by the system roots
+var ErrCertificateChanged = errors.New("tls certificate changed")
+
+// certPinVerifier checks server certificates for sc+tls:// connections.
+//
+// Certificates that chain to a system root are trusted and re-pinned.
+// Anything else (typically self-signed) is trusted on first use: the
+// fingerprint is pinned and every later connection must present the same one.
+type certPinVerifier struct {
+	address string // host:port used as the pin key
+	host    string // expected server name
+
+	mu    sync.Mutex
+	store CertificatePinStore
+	pin   string // In-memory pin when no store is attached
+}
+
+func newCertPinVerifier(host, port string) *certPinVerifier {
+	return &certPinVerifier{
+		address: net.JoinHostPort(host, port),
+		host:    host,
+	}
+}
+
+// setStore attaches persistent pin storage
+func (v *certPinVerifier) setStore(store CertificatePinStore) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.store = store
+}
+
+// verify is used as tls.Config.VerifyConnection
+func (v *certPinVerifier) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no TLS certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+	fingerprint := certificateFingerprint(leaf)
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, caErr := leaf.Verify(x509.VerifyOptions{
+		DNSName:       v.host,
+		Intermediates: intermediates,
+	})
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pinned, err := v.loadPin()
+	if err != nil {
+		return fmt.Errorf("failed to load pinned certificate for %s: %w", v.address, err)
+	}
+
+	if caErr != nil && pinned != "" && pinned != fingerprint {
+		return fmt.Errorf("%w: %s presented certificate %s but %s was pinned on first use. This could indicate a man-in-the-middle attack. If the server's certificate was legitimately replaced, run `sc forget-cert %s` and reconnect",
+			ErrCertificateChanged, v.address, fingerprint, pinned, v.address)
+	}
+
+	if pinned != fingerprint {
+		if err := v.savePin(fingerprint); err != nil {
+			return fmt.Errorf("failed to pin certificate for %s: %w", v.address, err)
+		}
+	}
+	return nil
+}
+
+func (v *certPinVerifier) loadPin() (string, error) {
+	if v.store == nil {
+		return v.pin, nil
+	}
+	return v.store.GetPinnedCertificate(v.address)
+}
+
+func (v *certPinVerifier) savePin(fingerprint string) error {
+	v.pin = fingerprint
+	if v.store == nil {
+		return nil
+	}
+	return v.store.SavePinnedCertificate(v.address, fingerprint)
+}
+
+// certificateFingerprint returns the SHA256 fingerprint of a certificate in
+// the same format OpenSSH uses for keys (SHA256:base64)
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// loadClientCertificate loads the optional client certificate used for
+// certificate authentication from SUPERCHAT_TLS_CLIENT_CERT/SUPERCHAT_TLS_CLIENT_KEY
+func loadClientCertificate() ([]tls.Certificate, error) {
+	certPath := os.Getenv("SUPERCHAT_TLS_CLIENT_CERT")
+	keyPath := os.Getenv("SUPERCHAT_TLS_CLIENT_KEY")
+	if certPath == "" && keyPath == "" {
+		return nil, nil
+	}
+	if certPath == "" || keyPath == "" {
+		return nil, errors.New("SUPERCHAT_TLS_CLIENT_CERT and SUPERCHAT_TLS_CLIENT_KEY must both be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+	}
+	return []tls.Certificate{cert}, nil
+}
+
+// dialTLS connects to a SuperChat TLS listener, verifying the server with verifier
+func dialTLS(host, port string, verifier *certPinVerifier) (net.Conn, error) {
+	clientCerts, err := loadClientCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ServerName:   host,
+		Certificates: clientCerts,
+		MinVersion:   tls.VersionTLS12,
+		// Standard verification would reject self-signed certificates outright;
+		// verifier applies CA verification with a TOFU pin fallback instead.
+		InsecureSkipVerify: true,
+		VerifyConnection:   verifier.verify,
+	}
+
+	dialer := &net.Dialer{Timeout: 1 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), config)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
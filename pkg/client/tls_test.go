@@ -0,0 +1,129 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryPinStore map[string]string
+
+func (s memoryPinStore) GetPinnedCertificate(serverAddress string) (string, error) {
+	return s[serverAddress], nil
+}
+
+func (s memoryPinStore) SavePinnedCertificate(serverAddress string, fingerprint string) error {
+	s[serverAddress] = fingerprint
+	return nil
+}
+
+func selfSignedCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert
+}
+
+func TestParseServerAddressTLS(t *testing.T) {
+	for _, address := range []string{"sc+tls://example.com", "scs://example.com"} {
+		cfg, err := parseServerAddress(address)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", address, err)
+		}
+		if cfg.display != "sc+tls://example.com:6467" {
+			t.Errorf("%s: expected display sc+tls://example.com:6467, got %s", address, cfg.display)
+		}
+		if cfg.tlsVerifier == nil {
+			t.Errorf("%s: expected certificate verifier", address)
+		}
+	}
+}
+
+func TestCertPinVerifierTrustOnFirstUse(t *testing.T) {
+	store := memoryPinStore{}
+	verifier := newCertPinVerifier("example.com", "6467")
+	verifier.setStore(store)
+
+	first := selfSignedCertificate(t)
+	if err := verifier.verify(tls.ConnectionState{PeerCertificates: []*x509.Certificate{first}}); err != nil {
+		t.Fatalf("first connection should be trusted: %v", err)
+	}
+	if store["example.com:6467"] != certificateFingerprint(first) {
+		t.Fatalf("expected certificate to be pinned, got %q", store["example.com:6467"])
+	}
+
+	if err := verifier.verify(tls.ConnectionState{PeerCertificates: []*x509.Certificate{first}}); err != nil {
+		t.Fatalf("same certificate should be trusted: %v", err)
+	}
+
+	second := selfSignedCertificate(t)
+	err := verifier.verify(tls.ConnectionState{PeerCertificates: []*x509.Certificate{second}})
+	if !errors.Is(err, ErrCertificateChanged) {
+		t.Fatalf("expected ErrCertificateChanged, got %v", err)
+	}
+	if store["example.com:6467"] != certificateFingerprint(first) {
+		t.Error("pin should not change after a mismatch")
+	}
+}
+
+func TestConnectTLSDoesNotFallBack(t *testing.T) {
+	// A server that accepts TCP but never completes the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	conn, err := NewConnection("sc+tls://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("NewConnection: %v", err)
+	}
+	conn.SetCertificatePinStore(memoryPinStore{})
+
+	err = conn.Connect()
+	if err == nil {
+		t.Fatal("expected the TLS connection to fail")
+	}
+	if strings.Contains(err.Error(), "WebSocket") {
+		t.Errorf("TLS connection fell back to WebSocket: %v", err)
+	}
+	if conn.GetConnectionType() != "tls" {
+		t.Errorf("connection type = %q, want tls", conn.GetConnectionType())
+	}
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// createAuditLogModal creates the audit log viewer and requests the first page
+func (m *Model) createAuditLogModal() (modal.Modal, tea.Cmd) {
+	auditModal := modal.NewAuditLogModal(m.sendListAdminActions)
+	return auditModal, m.sendListAdminActions(auditModal.Query(nil))
+}
+
+// sendListAdminActions sends a LIST_ADMIN_ACTIONS request
+func (m *Model) sendListAdminActions(query *protocol.ListAdminActionsMessage) tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		if err := conn.SendMessage(protocol.TypeListAdminActions, query); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// findAuditLogModal returns the audit log modal if it is open
+func (m Model) findAuditLogModal() *modal.AuditLogModal {
+	var found *modal.AuditLogModal
+	m.modalStack.ForEach(func(md modal.Modal) {
+		if auditModal, ok := md.(*modal.AuditLogModal); ok {
+			found = auditModal
+		}
+	})
+	return found
+}
+
+// handleAdminActionList processes ADMIN_ACTION_LIST
+func (m Model) handleAdminActionList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.AdminActionListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		if auditModal := m.findAuditLogModal(); auditModal != nil {
+			auditModal.SetError("Failed to load the audit log")
+		}
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode ADMIN_ACTION_LIST: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if auditModal := m.findAuditLogModal(); auditModal != nil {
+		auditModal.SetActions(msg.Actions, msg.HasMore)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
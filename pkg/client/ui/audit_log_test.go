@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestAuditLogModal(t *testing.T) {
+	m := NewModel(client.NewMockConnection("localhost:6465"), client.NewMockState(), "1.0.0", false, 0, log.New(io.Discard, "", 0), "", nil)
+
+	var queries []*protocol.ListAdminActionsMessage
+	auditModal := modal.NewAuditLogModal(func(query *protocol.ListAdminActionsMessage) tea.Cmd {
+		queries = append(queries, query)
+		return nil
+	})
+	m.modalStack.Push(auditModal)
+
+	frameFor := func(msg *protocol.AdminActionListMessage) *protocol.Frame {
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Type: protocol.TypeAdminActionList, Payload: payload}
+	}
+
+	actions := make([]protocol.AdminActionEntry, 6)
+	for i := range actions {
+		actions[i] = protocol.AdminActionEntry{ID: uint64(10 - i), AdminNickname: "alice", ActionType: "DELETE_CHANNEL", Details: "Deleted channel #old"}
+	}
+	updated, _ := m.handleAdminActionList(frameFor(&protocol.AdminActionListMessage{Actions: actions, HasMore: true}))
+	m = updated.(Model)
+	if view := auditModal.Render(120, 50); !strings.Contains(view, "DELETE_CHANNEL") {
+		t.Fatalf("expected the entries to be shown, got:\n%s", view)
+	}
+
+	// Moving towards the end of the list asks for the next page
+	auditModal.HandleKey(tea.KeyMsg{Type: tea.KeyDown})
+	if len(queries) != 1 || queries[0].BeforeID == nil || *queries[0].BeforeID != 5 {
+		t.Fatalf("expected a request for entries before #5, got %+v", queries)
+	}
+
+	// Filtering by admin starts over from the newest entry
+	for _, key := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("a")},
+		{Type: tea.KeyRunes, Runes: []rune("bob")},
+		{Type: tea.KeyEnter},
+	} {
+		auditModal.HandleKey(key)
+	}
+	last := queries[len(queries)-1]
+	if last.AdminNickname != "bob" || last.BeforeID != nil {
+		t.Errorf("expected a fresh query for bob, got %+v", last)
+	}
+
+	// Cycling the period limits the query to recent entries
+	auditModal.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if last := queries[len(queries)-1]; last.Since == nil || last.AdminNickname != "bob" {
+		t.Errorf("expected the period to set a start time, got %+v", last)
+	}
+}
@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// encryptContent encrypts a message for a DM or private channel we hold a
+// key for. Other channels get the content unchanged.
+func (m *Model) encryptContent(channelID uint64, content string) (string, error) {
+	if dm, ok := m.deviceDMs[channelID]; ok {
+		encrypted, err := m.encryptDeviceDM(channelID, dm, content)
+		if err != nil {
+			return "", err
+		}
+		return string(encrypted), nil
+	}
+	if r, ok := m.dmRatchets[channelID]; ok {
+		encrypted, err := m.encryptRatchet(channelID, r, content)
+		if err != nil {
+			return "", err
+		}
+		return string(encrypted), nil
+	}
+	if key, ok := m.dmChannelKeys[channelID]; ok {
+		encrypted, err := crypto.EncryptMessage(key, []byte(content))
+		if err != nil {
+			return "", err
+		}
+		return string(encrypted), nil
+	}
+	if keyring, ok := m.channelKeyrings[channelID]; ok {
+		encrypted, err := keyring.Encrypt([]byte(content))
+		if err != nil {
+			return "", err
+		}
+		return string(encrypted), nil
+	}
+	return content, nil
+}
+
+// decryptContent decrypts a message from a DM or private channel we hold
+// keys for, in place. System messages are never encrypted.
+func (m *Model) decryptContent(msg *protocol.Message) {
+	if msg.AuthorUserID == nil && msg.AuthorNickname == "" {
+		return
+	}
+
+	var decrypted []byte
+	var err error
+	if dm, ok := m.deviceDMs[msg.ChannelID]; ok {
+		decrypted, err = m.decryptDeviceDM(msg.ChannelID, dm, []byte(msg.Content))
+	} else if r, ok := m.dmRatchets[msg.ChannelID]; ok {
+		decrypted, err = m.decryptRatchet(msg.ChannelID, r, []byte(msg.Content))
+	} else if key, ok := m.dmChannelKeys[msg.ChannelID]; ok {
+		decrypted, err = crypto.DecryptMessage(key, []byte(msg.Content))
+		// Messages from before a key backup was restored use the old key
+		for _, restored := range m.dmRestoredKeys[msg.ChannelID] {
+			if err == nil {
+				break
+			}
+			decrypted, err = crypto.DecryptMessage(restored, []byte(msg.Content))
+		}
+	} else if keyring, ok := m.channelKeyrings[msg.ChannelID]; ok {
+		decrypted, err = keyring.Decrypt([]byte(msg.Content))
+	} else {
+		return
+	}
+
+	if err != nil {
+		if m.logger != nil {
+			m.logger.Printf("[E2E] Failed to decrypt message %d: %v", msg.ID, err)
+		}
+		// Show encrypted indicator instead of garbage
+		msg.Content = "[Encrypted message - decryption failed]"
+		if errors.Is(err, crypto.ErrNotARecipient) {
+			msg.Content = "[Encrypted message - not encrypted for this device]"
+		}
+		return
+	}
+	msg.Content = string(decrypted)
+}
+
+// requestChannelKeys fetches our keys for private channels
+func (m Model) requestChannelKeys(channels []protocol.Channel) tea.Cmd {
+	if m.encryptionKeyPriv == nil || len(channels) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		for _, ch := range channels {
+			if err := m.conn.SendMessage(protocol.TypeGetChannelKeys, &protocol.GetChannelKeysMessage{ChannelID: ch.ID}); err != nil {
+				return ErrorMsg{Err: err}
+			}
+		}
+		return nil
+	}
+}
+
+// handleChannelKeys processes CHANNEL_KEYS by unwrapping each key with our
+// private key, or a restored key for epochs wrapped before this machine had
+// its own
+func (m Model) handleChannelKeys(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ChannelKeysMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode channel keys: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+	if m.encryptionKeyPriv == nil {
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	keyring, ok := m.channelKeyrings[msg.ChannelID]
+	if !ok {
+		keyring = crypto.NewGroupKeyring()
+	}
+	for _, entry := range msg.Keys {
+		key, err := crypto.UnwrapChannelKey(entry.WrappedKey[:], m.encryptionKeyPriv, msg.ChannelID, entry.Epoch)
+		for _, restored := range m.restoredKeys {
+			if err == nil {
+				break
+			}
+			key, err = crypto.UnwrapChannelKey(entry.WrappedKey[:], restored, msg.ChannelID, entry.Epoch)
+		}
+		if err == nil {
+			err = keyring.Add(entry.Epoch, key)
+		}
+		if err != nil && m.logger != nil {
+			m.logger.Printf("[E2E] Failed to unwrap key epoch %d of channel %d: %v", entry.Epoch, msg.ChannelID, err)
+		}
+	}
+	if keyring.Epoch() > 0 {
+		m.channelKeyrings[msg.ChannelID] = keyring
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleChannelRekeyRequired processes CHANNEL_REKEY_REQUIRED by making a new
+// channel key and wrapping it for every member
+func (m Model) handleChannelRekeyRequired(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ChannelRekeyRequiredMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode rekey request: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+	if m.encryptionKeyPriv == nil {
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	distribute, err := wrapChannelKeyForMembers(msg)
+	if err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to make channel key: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeDistributeChannelKey, distribute); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	})
+}
+
+// wrapChannelKeyForMembers generates a new channel key for the requested
+// epoch and wraps it for each member's public key
+func wrapChannelKeyForMembers(msg *protocol.ChannelRekeyRequiredMessage) (*protocol.DistributeChannelKeyMessage, error) {
+	channelKey, err := crypto.GenerateChannelKey()
+	if err != nil {
+		return nil, err
+	}
+
+	distribute := &protocol.DistributeChannelKeyMessage{
+		ChannelID: msg.ChannelID,
+		Epoch:     msg.Epoch,
+		Keys:      make([]protocol.WrappedChannelKey, 0, len(msg.Members)),
+	}
+	for _, member := range msg.Members {
+		wrapped, err := crypto.WrapChannelKey(channelKey, member.PublicKey[:], msg.ChannelID, msg.Epoch)
+		if err != nil {
+			return nil, fmt.Errorf("wrapping for %s: %w", member.Nickname, err)
+		}
+		key := protocol.WrappedChannelKey{UserID: member.UserID}
+		copy(key.WrappedKey[:], wrapped)
+		distribute.Keys = append(distribute.Keys, key)
+	}
+	return distribute, nil
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestPrivateChannelEncryption(t *testing.T) {
+	m := NewTestModel()
+	keys, err := crypto.GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatalf("GenerateX25519KeyPair: %v", err)
+	}
+	m.encryptionKeyPub = keys.PublicKey[:]
+	m.encryptionKeyPriv = keys.PrivateKey[:]
+
+	frame := func(msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+		t.Helper()
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Version: protocol.ProtocolVersion, Type: msgType, Payload: payload}
+	}
+
+	// The member asked to rekey wraps the new key for everyone, us included
+	distribute, err := wrapChannelKeyForMembers(&protocol.ChannelRekeyRequiredMessage{
+		ChannelID: 7,
+		Epoch:     1,
+		Members:   []protocol.ChannelKeyMember{{UserID: 1, Nickname: "alice", PublicKey: keys.PublicKey}},
+	})
+	if err != nil {
+		t.Fatalf("wrapChannelKeyForMembers: %v", err)
+	}
+	if distribute.ChannelID != 7 || distribute.Epoch != 1 || len(distribute.Keys) != 1 || distribute.Keys[0].UserID != 1 {
+		t.Fatalf("unexpected DISTRIBUTE_CHANNEL_KEY %+v", distribute)
+	}
+
+	updated, _ := m.handleChannelKeys(frame(protocol.TypeChannelKeys, &protocol.ChannelKeysMessage{
+		ChannelID: 7,
+		Keys:      []protocol.ChannelKeyEntry{{Epoch: 1, WrappedKey: distribute.Keys[0].WrappedKey}},
+	}))
+	m = updated.(Model)
+	if keyring, ok := m.channelKeyrings[7]; !ok || keyring.Epoch() != 1 {
+		t.Fatal("expected the epoch 1 key to be added to the channel's keyring")
+	}
+
+	encrypted, err := m.encryptContent(7, "secret plans")
+	if err != nil {
+		t.Fatalf("encryptContent: %v", err)
+	}
+	if encrypted == "secret plans" {
+		t.Fatal("expected the content to be encrypted")
+	}
+	if plain, _ := m.encryptContent(1, "hello"); plain != "hello" {
+		t.Errorf("expected channels without keys to be sent as is, got %q", plain)
+	}
+
+	userID := uint64(1)
+	msg := protocol.Message{ID: 1, ChannelID: 7, AuthorUserID: &userID, Content: encrypted}
+	m.decryptContent(&msg)
+	if msg.Content != "secret plans" {
+		t.Errorf("expected the message to decrypt, got %q", msg.Content)
+	}
+
+	// System messages are sent in the clear
+	system := protocol.Message{ID: 2, ChannelID: 7, Content: "bob was added to #team by alice"}
+	m.decryptContent(&system)
+	if system.Content != "bob was added to #team by alice" {
+		t.Errorf("expected system messages to be left alone, got %q", system.Content)
+	}
+
+	updated, _ = m.handleRemovedFromChannel(frame(protocol.TypeRemovedFromChannel, &protocol.RemovedFromChannelMessage{ChannelID: 7, Message: "You left #team"}))
+	m = updated.(Model)
+	if _, ok := m.channelKeyrings[7]; ok {
+		t.Error("expected the keyring to be dropped when leaving the channel")
+	}
+}
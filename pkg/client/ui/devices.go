@@ -0,0 +1,335 @@
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Every encryption key a registered user provides is one of their devices.
+// Multi-device DMs are encrypted separately for every device of both users,
+// each copy with a Double Ratchet between the sending and the receiving
+// device, and sent together in one crypto.DeviceEnvelope. Device lists come
+// from USER_INFO; until the other user's list arrives, messages go to the key
+// in DM_READY. Like other ratcheted DMs, decrypted messages are kept in the
+// state DB, by the SHA-256 of the whole envelope.
+//
+// The safety number only covers the key in DM_READY, and the device list
+// comes from the server. When a contact whose key we verified shows up with
+// a device we've never had a ratchet with, the client warns before
+// encrypting anything for it.
+
+// deviceDM is a multi-device DM
+type deviceDM struct {
+	otherNickname  string
+	otherPublicKey []byte                     // The other user's key from DM_READY
+	ratchets       map[string]*crypto.Ratchet // Other device's public key -> ratchet
+}
+
+// deviceLabel names this device in the user's device list
+func deviceLabel() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "generated"
+}
+
+// requestUserDevices asks the server for the devices of some users
+func (m Model) requestUserDevices(nicknames ...string) tea.Cmd {
+	return func() tea.Msg {
+		for _, nickname := range nicknames {
+			if nickname == "" {
+				continue
+			}
+			if err := m.conn.SendMessage(protocol.TypeGetUserInfo, &protocol.GetUserInfoMessage{Nickname: nickname}); err != nil {
+				return ErrorMsg{Err: err}
+			}
+		}
+		return nil
+	}
+}
+
+// deviceRatchet returns the ratchet between this device and another device
+// in a multi-device DM, loading or starting it if needed
+func (m *Model) deviceRatchet(channelID uint64, dm *deviceDM, otherPublicKey []byte) (*crypto.Ratchet, error) {
+	if r, ok := dm.ratchets[string(otherPublicKey)]; ok {
+		return r, nil
+	}
+
+	// Stored as: our public key (32) || ratchet state
+	stored, err := m.state.GetDeviceRatchetState(m.conn.GetAddress(), channelID, otherPublicKey)
+	if err == nil && len(stored) > crypto.X25519KeySize && bytes.Equal(stored[:crypto.X25519KeySize], m.encryptionKeyPub) {
+		if r, err := crypto.UnmarshalRatchet(stored[crypto.X25519KeySize:]); err == nil {
+			dm.ratchets[string(otherPublicKey)] = r
+			return r, nil
+		}
+	}
+
+	r, err := crypto.NewRatchet(m.encryptionKeyPriv, m.encryptionKeyPub, otherPublicKey, channelID)
+	if err != nil {
+		return nil, err
+	}
+	dm.ratchets[string(otherPublicKey)] = r
+	m.saveDeviceRatchet(channelID, otherPublicKey, r)
+	return r, nil
+}
+
+// saveDeviceRatchet stores the state of the ratchet with another device
+func (m *Model) saveDeviceRatchet(channelID uint64, otherPublicKey []byte, r *crypto.Ratchet) {
+	state, err := r.MarshalBinary()
+	if err == nil {
+		stored := append(append([]byte(nil), m.encryptionKeyPub...), state...)
+		err = m.state.SaveDeviceRatchetState(m.conn.GetAddress(), channelID, otherPublicKey, stored)
+	}
+	if err != nil && m.logger != nil {
+		m.logger.Printf("[E2E] Failed to save ratchet state of DM %d: %v", channelID, err)
+	}
+}
+
+// recipientDevices lists the public keys a multi-device DM message is
+// encrypted for: the other user's devices and our own other devices
+func (m *Model) recipientDevices(dm *deviceDM) [][]byte {
+	var keys [][]byte
+	add := func(key []byte) {
+		if len(key) != crypto.X25519KeySize || bytes.Equal(key, m.encryptionKeyPub) {
+			return
+		}
+		for _, existing := range keys {
+			if bytes.Equal(existing, key) {
+				return
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	if devices := m.userDevices[dm.otherNickname]; len(devices) > 0 {
+		for _, device := range devices {
+			add(append([]byte(nil), device.PublicKey[:]...))
+		}
+	} else {
+		add(dm.otherPublicKey)
+	}
+	for _, device := range m.userDevices[m.nickname] {
+		add(append([]byte(nil), device.PublicKey[:]...))
+	}
+	return keys
+}
+
+// encryptDeviceDM encrypts a message for every device in a multi-device DM
+func (m *Model) encryptDeviceDM(channelID uint64, dm *deviceDM, content string) ([]byte, error) {
+	envelope := &crypto.DeviceEnvelope{}
+	copy(envelope.SenderPublicKey[:], m.encryptionKeyPub)
+	for _, key := range m.recipientDevices(dm) {
+		r, err := m.deviceRatchet(channelID, dm, key)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := r.Encrypt([]byte(content))
+		if err != nil {
+			return nil, err
+		}
+		m.saveDeviceRatchet(channelID, key, r)
+
+		recipient := crypto.DeviceCiphertext{Ciphertext: ciphertext}
+		copy(recipient.PublicKey[:], key)
+		envelope.Recipients = append(envelope.Recipients, recipient)
+	}
+
+	encrypted, err := envelope.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	m.rememberPlaintext(channelID, encrypted, content)
+	return encrypted, nil
+}
+
+// decryptDeviceDM decrypts this device's copy of a multi-device DM message,
+// or returns its plaintext if we've seen it before
+func (m *Model) decryptDeviceDM(channelID uint64, dm *deviceDM, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	if plaintext, err := m.state.GetRatchetPlaintext(m.conn.GetAddress(), digest[:]); err == nil && plaintext != "" {
+		return []byte(plaintext), nil
+	}
+
+	envelope, err := crypto.UnmarshalDeviceEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := envelope.CiphertextFor(m.encryptionKeyPub)
+	if err != nil {
+		return nil, err
+	}
+	r, err := m.deviceRatchet(channelID, dm, envelope.SenderPublicKey[:])
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := r.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	m.saveDeviceRatchet(channelID, envelope.SenderPublicKey[:], r)
+	m.rememberPlaintext(channelID, data, string(decrypted))
+	return decrypted, nil
+}
+
+// showDevicesModal lists our devices and lets us revoke them
+func (m *Model) showDevicesModal() tea.Cmd {
+	nickname := m.nickname
+	devicesModal := modal.NewDevicesModal(m.encryptionKeyPub,
+		func() tea.Cmd { return m.requestUserDevices(nickname) },
+		func(deviceID uint64) tea.Cmd {
+			return func() tea.Msg {
+				if err := m.conn.SendMessage(protocol.TypeRevokeDevice, &protocol.RevokeDeviceMessage{DeviceID: deviceID}); err != nil {
+					return ErrorMsg{Err: err}
+				}
+				return nil
+			}
+		})
+	if devices, ok := m.userDevices[nickname]; ok {
+		devicesModal.SetDevices(devices)
+	}
+	m.modalStack.Push(devicesModal)
+	return m.requestUserDevices(nickname)
+}
+
+// findDevicesModal returns the devices modal if it's open
+func (m Model) findDevicesModal() *modal.DevicesModal {
+	var found *modal.DevicesModal
+	m.modalStack.ForEach(func(md modal.Modal) {
+		if devicesModal, ok := md.(*modal.DevicesModal); ok {
+			found = devicesModal
+		}
+	})
+	return found
+}
+
+// updateUserDevices remembers the devices from a USER_INFO response
+func (m *Model) updateUserDevices(msg *protocol.UserInfoMessage) {
+	if !msg.IsRegistered {
+		delete(m.userDevices, msg.Nickname)
+		return
+	}
+	m.userDevices[msg.Nickname] = msg.Devices
+	if msg.Nickname == m.nickname {
+		if devicesModal := m.findDevicesModal(); devicesModal != nil {
+			devicesModal.SetDevices(msg.Devices)
+		}
+		return
+	}
+	m.warnAboutNewDevices(msg.Nickname, msg.Devices)
+}
+
+// knownDevice reports whether we've had a ratchet with another device in a
+// multi-device DM before
+func (m *Model) knownDevice(channelID uint64, dm *deviceDM, publicKey []byte) bool {
+	if _, ok := dm.ratchets[string(publicKey)]; ok {
+		return true
+	}
+	stored, err := m.state.GetDeviceRatchetState(m.conn.GetAddress(), channelID, publicKey)
+	return err == nil && len(stored) > 0
+}
+
+// warnAboutNewDevices shows a blocking warning when a contact whose key we
+// verified has devices the safety number doesn't cover and that we've never
+// encrypted for. The modal blocks input, so nothing is sent to the new
+// devices until it's dismissed; their ratchets are started right away so the
+// warning is shown once per device.
+func (m *Model) warnAboutNewDevices(nickname string, devices []protocol.DeviceInfo) {
+	var labels []string
+	for channelID, dm := range m.deviceDMs {
+		if dm.otherNickname != nickname || !m.dmKeyVerified(channelID) {
+			continue
+		}
+		for _, device := range devices {
+			key := append([]byte(nil), device.PublicKey[:]...)
+			if bytes.Equal(key, dm.otherPublicKey) || m.knownDevice(channelID, dm, key) {
+				continue
+			}
+			if _, err := m.deviceRatchet(channelID, dm, key); err != nil && m.logger != nil {
+				m.logger.Printf("[E2E] Failed to start ratchet with a device of %s: %v", nickname, err)
+			}
+			label := device.Label
+			if label == "" {
+				label = fmt.Sprintf("device %d", device.DeviceID)
+			}
+			if !slices.Contains(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	if len(labels) == 0 {
+		return
+	}
+
+	if m.logger != nil {
+		m.logger.Printf("[E2E] %s has new devices: %s", nickname, strings.Join(labels, ", "))
+	}
+	m.modalStack.Push(modal.NewErrorModal(
+		"⚠ New device",
+		fmt.Sprintf("%s has a device you haven't sent to before: %s.\n\n"+
+			"The safety number you verified doesn't cover it. %s may have set up a "+
+			"new device, but the server could also have added one to read your "+
+			"messages. Your DM will be encrypted for it from now on; check with %s "+
+			"before sending anything sensitive.", nickname, strings.Join(labels, ", "), nickname, nickname),
+		nil,
+	))
+}
+
+// dmKeyVerified reports whether the other party's key in a DM was verified
+func (m *Model) dmKeyVerified(channelID uint64) bool {
+	for _, dm := range m.dmChannels {
+		if dm.ChannelID == channelID {
+			return dm.KeyVerified
+		}
+	}
+	return false
+}
+
+// handleDeviceRevoked processes DEVICE_REVOKED, sent when one of our devices
+// or one of a DM partner's devices was revoked
+func (m Model) handleDeviceRevoked(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.DeviceRevokedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode DEVICE_REVOKED: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	// Stop encrypting for it straight away
+	var revoked *protocol.DeviceInfo
+	for nickname, devices := range m.userDevices {
+		kept := make([]protocol.DeviceInfo, 0, len(devices))
+		for i := range devices {
+			if devices[i].DeviceID == msg.DeviceID {
+				if nickname == m.nickname {
+					revoked = &devices[i]
+				}
+				continue
+			}
+			kept = append(kept, devices[i])
+		}
+		m.userDevices[nickname] = kept
+	}
+	if revoked == nil {
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if devicesModal := m.findDevicesModal(); devicesModal != nil {
+		devicesModal.SetDevices(m.userDevices[m.nickname])
+	}
+	if bytes.Equal(revoked.PublicKey[:], m.encryptionKeyPub) {
+		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration),
+			m.setError("This device was revoked and won't receive new encrypted DMs. Generate a new encryption key to use it again."))
+	}
+	label := revoked.Label
+	if label == "" {
+		label = fmt.Sprintf("device %d", revoked.DeviceID)
+	}
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setStatus(fmt.Sprintf("Revoked %s", label)))
+}
@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestMultiDeviceDM(t *testing.T) {
+	workKeys, _ := crypto.GenerateX25519KeyPair()
+	homeKeys, _ := crypto.GenerateX25519KeyPair()
+	bobKeys, _ := crypto.GenerateX25519KeyPair()
+	aliceDevices := []protocol.DeviceInfo{
+		{DeviceID: 1, PublicKey: workKeys.PublicKey, Label: "work"},
+		{DeviceID: 2, PublicKey: homeKeys.PublicKey, Label: "home"},
+	}
+	bobDevices := []protocol.DeviceInfo{{DeviceID: 3, PublicKey: bobKeys.PublicKey}}
+
+	frame := func(t *testing.T, msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+		t.Helper()
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Version: protocol.ProtocolVersion, Type: msgType, Payload: payload}
+	}
+	// open starts a client, opens the multi-device DM and gives it both
+	// users' device lists
+	open := func(t *testing.T, nickname string, keys *crypto.X25519KeyPair, other string, otherKey [32]byte) Model {
+		t.Helper()
+		conn := client.NewMockConnection("localhost:6465")
+		conn.Connect()
+		m := NewTestModelWithMocks(conn, client.NewMockState())
+		m.nickname = nickname
+		m.encryptionKeyPub = keys.PublicKey[:]
+		m.encryptionKeyPriv = keys.PrivateKey[:]
+
+		updated, _ := m.handleDMReady(frame(t, protocol.TypeDMReady, &protocol.DMReadyMessage{
+			ChannelID: 5, OtherNickname: other, IsEncrypted: true, OtherPublicKey: otherKey, Ratchet: true, MultiDevice: true,
+		}))
+		m = updated.(Model)
+		if _, ok := m.deviceDMs[5]; !ok {
+			t.Fatal("expected a multi-device DM")
+		}
+		if _, ok := m.dmRatchets[5]; ok {
+			t.Fatal("expected no single ratchet for a multi-device DM")
+		}
+		for user, devices := range map[string][]protocol.DeviceInfo{"alice": aliceDevices, "bob": bobDevices} {
+			updated, _ = m.handleUserInfo(frame(t, protocol.TypeUserInfo, &protocol.UserInfoMessage{Nickname: user, IsRegistered: true, Devices: devices}))
+			m = updated.(Model)
+		}
+		return m
+	}
+	send := func(t *testing.T, m Model, content string) string {
+		t.Helper()
+		encrypted, err := m.encryptContent(5, content)
+		if err != nil {
+			t.Fatalf("encryptContent: %v", err)
+		}
+		if encrypted == content {
+			t.Fatal("expected the content to be encrypted")
+		}
+		return encrypted
+	}
+	expectRead := func(t *testing.T, m Model, content, want string) {
+		t.Helper()
+		userID := uint64(1)
+		msg := protocol.Message{ID: 1, ChannelID: 5, AuthorUserID: &userID, Content: content}
+		m.decryptContent(&msg)
+		if msg.Content != want {
+			t.Errorf("%s: expected %q, got %q", m.nickname, want, msg.Content)
+		}
+	}
+
+	work := open(t, "alice", workKeys, "bob", bobKeys.PublicKey)
+	home := open(t, "alice", homeKeys, "bob", bobKeys.PublicKey)
+	bob := open(t, "bob", bobKeys, "alice", homeKeys.PublicKey)
+
+	// Every device reads every message, including the sender's other device
+	hello := send(t, work, "hello from work")
+	for _, m := range []Model{work, home, bob} {
+		expectRead(t, m, hello, "hello from work")
+	}
+	reply := send(t, bob, "hi alice")
+	expectRead(t, work, reply, "hi alice")
+	expectRead(t, home, reply, "hi alice")
+	expectRead(t, work, send(t, home, "back home"), "back home")
+
+	// Once the home device is revoked, bob stops encrypting for it
+	updated, _ := bob.handleDeviceRevoked(frame(t, protocol.TypeDeviceRevoked, &protocol.DeviceRevokedMessage{DeviceID: 2}))
+	bob = updated.(Model)
+	secret := send(t, bob, "not for home")
+	expectRead(t, work, secret, "not for home")
+	expectRead(t, home, secret, "[Encrypted message - not encrypted for this device]")
+}
+
+func TestDevicesModal(t *testing.T) {
+	m := NewTestModelWithMocks(client.NewMockConnection("localhost:6465"), client.NewMockState())
+	m.nickname = "alice"
+	m.encryptionKeyPub = make([]byte, 32)
+	m.showDevicesModal()
+	if m.modalStack.TopType() != modal.ModalDevices {
+		t.Fatalf("expected the devices modal, got %v", m.modalStack.TopType())
+	}
+
+	devices := []protocol.DeviceInfo{{DeviceID: 1, Label: "work"}, {DeviceID: 2, PublicKey: [32]byte{1}, Label: "home"}}
+	payload, _ := (&protocol.UserInfoMessage{Nickname: "alice", IsRegistered: true, Devices: devices}).Encode()
+	updated, _ := m.handleUserInfo(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeUserInfo, Payload: payload})
+	m = updated.(Model)
+	if len(m.userDevices["alice"]) != 2 {
+		t.Fatalf("expected two devices, got %d", len(m.userDevices["alice"]))
+	}
+
+	// Revoking the other device shows a status; revoking this one an error
+	payload, _ = (&protocol.DeviceRevokedMessage{DeviceID: 2}).Encode()
+	updated, _ = m.handleDeviceRevoked(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeDeviceRevoked, Payload: payload})
+	m = updated.(Model)
+	if len(m.userDevices["alice"]) != 1 || m.statusMessage != "Revoked home" {
+		t.Errorf("expected the home device to be gone, got %d devices and status %q", len(m.userDevices["alice"]), m.statusMessage)
+	}
+	payload, _ = (&protocol.DeviceRevokedMessage{DeviceID: 1}).Encode()
+	updated, _ = m.handleDeviceRevoked(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeDeviceRevoked, Payload: payload})
+	m = updated.(Model)
+	if m.errorMessage == "" {
+		t.Error("expected an error when this device is revoked")
+	}
+}
+
+func TestNewDeviceOfVerifiedContact(t *testing.T) {
+	myKeys, _ := crypto.GenerateX25519KeyPair()
+	bobKeys, _ := crypto.GenerateX25519KeyPair()
+	tabletKeys, _ := crypto.GenerateX25519KeyPair()
+	conn := client.NewMockConnection("localhost:6465")
+	conn.Connect()
+	m := NewTestModelWithMocks(conn, client.NewMockState())
+	m.nickname = "alice"
+	m.encryptionKeyPub = myKeys.PublicKey[:]
+	m.encryptionKeyPriv = myKeys.PrivateKey[:]
+
+	bobID := uint64(7)
+	payload, _ := (&protocol.DMReadyMessage{
+		ChannelID: 5, OtherUserID: &bobID, OtherNickname: "bob", IsEncrypted: true, OtherPublicKey: bobKeys.PublicKey, Ratchet: true, MultiDevice: true,
+	}).Encode()
+	updated, _ := m.handleDMReady(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeDMReady, Payload: payload})
+	m = updated.(Model)
+	userInfo := func(t *testing.T, m Model, devices ...protocol.DeviceInfo) Model {
+		t.Helper()
+		payload, _ := (&protocol.UserInfoMessage{Nickname: "bob", IsRegistered: true, Devices: devices}).Encode()
+		updated, _ := m.handleUserInfo(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeUserInfo, Payload: payload})
+		return updated.(Model)
+	}
+	phone := protocol.DeviceInfo{DeviceID: 3, PublicKey: bobKeys.PublicKey, Label: "phone"}
+	tablet := protocol.DeviceInfo{DeviceID: 4, PublicKey: tabletKeys.PublicKey, Label: "tablet"}
+
+	// The key the safety number covers is never a surprise
+	updated, _ = m.handleKeyVerified(KeyVerifiedMsg{ChannelID: 5, Verified: true})
+	m = updated.(Model)
+	m.modalStack.Clear()
+	m = userInfo(t, m, phone)
+	if m.modalStack.TopType() != modal.ModalNone {
+		t.Fatalf("expected no warning for the verified key, got %v", m.modalStack.TopType())
+	}
+
+	// A device the safety number doesn't cover is a loud warning, once
+	m = userInfo(t, m, phone, tablet)
+	if m.modalStack.TopType() != modal.ModalError {
+		t.Fatalf("expected a new device warning, got %v", m.modalStack.TopType())
+	}
+	m.modalStack.Clear()
+	m = userInfo(t, m, phone, tablet)
+	if m.modalStack.TopType() != modal.ModalNone {
+		t.Errorf("expected the warning to be shown once, got %v", m.modalStack.TopType())
+	}
+
+	// Unverified contacts get no warning
+	updated, _ = m.handleKeyVerified(KeyVerifiedMsg{ChannelID: 5, Verified: false})
+	m = updated.(Model)
+	otherKeys, _ := crypto.GenerateX25519KeyPair()
+	m = userInfo(t, m, phone, protocol.DeviceInfo{DeviceID: 5, PublicKey: otherKeys.PublicKey})
+	if m.modalStack.TopType() != modal.ModalNone {
+		t.Errorf("expected no warning for an unverified contact, got %v", m.modalStack.TopType())
+	}
+}
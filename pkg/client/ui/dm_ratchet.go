@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Ratcheted DMs use a Double Ratchet instead of the static channel key. Its
+// state is saved in the client state DB after every message. Message keys
+// are deleted once used, so decrypted messages (and the ones we send, which
+// we can't decrypt ourselves) are kept in the state DB by the SHA-256 of
+// their ciphertext, for when history is loaded again.
+
+// dmRatchet is the ratchet of one DM and the keys it was started from
+type dmRatchet struct {
+	ratchet        *crypto.Ratchet
+	publicKey      []byte // Our X25519 public key
+	otherPublicKey []byte // The other party's X25519 public key
+}
+
+// loadRatchet returns a DM's stored ratchet, or starts a new one if there is
+// none or it was started from different keys
+func (m *Model) loadRatchet(channelID uint64, otherPublicKey []byte) (*dmRatchet, error) {
+	r := &dmRatchet{
+		publicKey:      append([]byte(nil), m.encryptionKeyPub...),
+		otherPublicKey: append([]byte(nil), otherPublicKey...),
+	}
+
+	// Stored as: our public key (32) || their public key (32) || ratchet state
+	stored, err := m.state.GetRatchetState(m.conn.GetAddress(), channelID)
+	if err == nil && len(stored) > 2*crypto.X25519KeySize &&
+		bytes.Equal(stored[:crypto.X25519KeySize], r.publicKey) &&
+		bytes.Equal(stored[crypto.X25519KeySize:2*crypto.X25519KeySize], r.otherPublicKey) {
+		if r.ratchet, err = crypto.UnmarshalRatchet(stored[2*crypto.X25519KeySize:]); err == nil {
+			return r, nil
+		}
+	}
+	if err != nil && m.logger != nil {
+		m.logger.Printf("[E2E] Discarding ratchet state of DM %d: %v", channelID, err)
+	}
+
+	if r.ratchet, err = crypto.NewRatchet(m.encryptionKeyPriv, m.encryptionKeyPub, otherPublicKey, channelID); err != nil {
+		return nil, err
+	}
+	m.saveRatchet(channelID, r)
+	return r, nil
+}
+
+// saveRatchet stores a DM's ratchet state. A state that can't be saved
+// means messages after a restart can't be decrypted, so it's logged.
+func (m *Model) saveRatchet(channelID uint64, r *dmRatchet) {
+	state, err := r.ratchet.MarshalBinary()
+	if err == nil {
+		stored := append(append(append([]byte(nil), r.publicKey...), r.otherPublicKey...), state...)
+		err = m.state.SaveRatchetState(m.conn.GetAddress(), channelID, stored)
+	}
+	if err != nil && m.logger != nil {
+		m.logger.Printf("[E2E] Failed to save ratchet state of DM %d: %v", channelID, err)
+	}
+}
+
+// rememberPlaintext keeps the plaintext of a ratcheted DM message
+func (m *Model) rememberPlaintext(channelID uint64, ciphertext []byte, plaintext string) {
+	digest := sha256.Sum256(ciphertext)
+	if err := m.state.SaveRatchetPlaintext(m.conn.GetAddress(), channelID, digest[:], plaintext); err != nil && m.logger != nil {
+		m.logger.Printf("[E2E] Failed to save decrypted message of DM %d: %v", channelID, err)
+	}
+}
+
+// encryptRatchet encrypts a message for a ratcheted DM
+func (m *Model) encryptRatchet(channelID uint64, r *dmRatchet, content string) ([]byte, error) {
+	encrypted, err := r.ratchet.Encrypt([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+	m.saveRatchet(channelID, r)
+	m.rememberPlaintext(channelID, encrypted, content)
+	return encrypted, nil
+}
+
+// decryptRatchet decrypts a message of a ratcheted DM, or returns its
+// plaintext if we've seen it before
+func (m *Model) decryptRatchet(channelID uint64, r *dmRatchet, ciphertext []byte) ([]byte, error) {
+	digest := sha256.Sum256(ciphertext)
+	if plaintext, err := m.state.GetRatchetPlaintext(m.conn.GetAddress(), digest[:]); err == nil && plaintext != "" {
+		return []byte(plaintext), nil
+	}
+
+	decrypted, err := r.ratchet.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	m.saveRatchet(channelID, r)
+	m.rememberPlaintext(channelID, ciphertext, string(decrypted))
+	return decrypted, nil
+}
+
+// advertiseEncryptionKey sends our stored public key again after logging in,
+// so the server knows this client supports ratcheted and multi-device DMs
+// when someone starts a DM with us
+func (m Model) advertiseEncryptionKey() tea.Cmd {
+	if m.userID == nil || len(m.encryptionKeyPub) != crypto.X25519KeySize {
+		return nil
+	}
+	var publicKey [32]byte
+	copy(publicKey[:], m.encryptionKeyPub)
+	return m.sendProvidePublicKey(protocol.KeyTypeGenerated, publicKey, deviceLabel())
+}
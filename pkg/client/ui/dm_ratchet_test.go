@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestRatchetedDM(t *testing.T) {
+	aliceKeys, _ := crypto.GenerateX25519KeyPair()
+	bobKeys, _ := crypto.GenerateX25519KeyPair()
+	bobState := client.NewMockState()
+
+	// open starts a client and opens the ratcheted DM with the other user
+	open := func(t *testing.T, state client.StateInterface, keys, other *crypto.X25519KeyPair) Model {
+		t.Helper()
+		conn := client.NewMockConnection("localhost:6465")
+		conn.Connect()
+		m := NewTestModelWithMocks(conn, state)
+		m.encryptionKeyPub = keys.PublicKey[:]
+		m.encryptionKeyPriv = keys.PrivateKey[:]
+
+		payload, err := (&protocol.DMReadyMessage{
+			ChannelID: 5, OtherNickname: "other", IsEncrypted: true, OtherPublicKey: other.PublicKey, Ratchet: true,
+		}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		updated, _ := m.handleDMReady(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeDMReady, Payload: payload})
+		m = updated.(Model)
+		if _, ok := m.dmRatchets[5]; !ok {
+			t.Fatal("expected the DM to use a ratchet")
+		}
+		if _, ok := m.dmChannelKeys[5]; ok {
+			t.Fatal("expected no static key for a ratcheted DM")
+		}
+		return m
+	}
+	send := func(t *testing.T, m Model, content string) string {
+		t.Helper()
+		encrypted, err := m.encryptContent(5, content)
+		if err != nil {
+			t.Fatalf("encryptContent: %v", err)
+		}
+		if encrypted == content {
+			t.Fatal("expected the content to be encrypted")
+		}
+		return encrypted
+	}
+	expectRead := func(t *testing.T, m Model, content, want string) {
+		t.Helper()
+		userID := uint64(1)
+		msg := protocol.Message{ID: 1, ChannelID: 5, AuthorUserID: &userID, Content: content}
+		m.decryptContent(&msg)
+		if msg.Content != want {
+			t.Errorf("expected %q, got %q", want, msg.Content)
+		}
+	}
+
+	alice := open(t, client.NewMockState(), aliceKeys, bobKeys)
+	bob := open(t, bobState, bobKeys, aliceKeys)
+
+	hello := send(t, alice, "hello bob")
+	expectRead(t, bob, hello, "hello bob")
+	expectRead(t, alice, hello, "hello bob") // Our own message comes back from the server
+	expectRead(t, alice, send(t, bob, "hi alice"), "hi alice")
+
+	// Reloading history shows messages whose keys are gone
+	expectRead(t, bob, hello, "hello bob")
+
+	// The ratchet carries on after a restart
+	bob = open(t, bobState, bobKeys, aliceKeys)
+	expectRead(t, bob, send(t, alice, "still there?"), "still there?")
+	expectRead(t, alice, send(t, bob, "yes"), "yes")
+
+	// A client with a different key starts over and can't read the DM
+	otherKeys, _ := crypto.GenerateX25519KeyPair()
+	stranger := open(t, bobState, otherKeys, aliceKeys)
+	expectRead(t, stranger, send(t, alice, "secret"), "[Encrypted message - decryption failed]")
+}
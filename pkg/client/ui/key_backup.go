@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Registered users can keep a passphrase-encrypted backup of their DM
+// encryption keys on the server, so logging in on another machine can
+// restore them. The backup is made and opened here; the server only stores
+// the bytes. Deriving the key from the passphrase is deliberately slow, so it
+// runs as a command instead of in Update.
+
+// KeyBackupEncryptedMsg is sent when the keys were encrypted into a backup
+// that is ready to be sent to the server
+type KeyBackupEncryptedMsg struct {
+	Backup []byte
+	Err    error
+}
+
+// KeysRestoredMsg is sent when a key backup from the server was opened and
+// its keys saved
+type KeysRestoredMsg struct {
+	Imported int
+	Err      error
+}
+
+// showBackupKeysModal asks for a passphrase and backs up all stored keys
+// with it
+func (m *Model) showBackupKeysModal() {
+	keyStore := m.keyStore
+	m.modalStack.Push(modal.NewKeyBackupModal(false, func(passphrase string) tea.Cmd {
+		return func() tea.Msg {
+			keys, err := keyStore.ExportKeys()
+			if err != nil {
+				return KeyBackupEncryptedMsg{Err: err}
+			}
+			if len(keys) == 0 {
+				return KeyBackupEncryptedMsg{Err: errors.New("no encryption keys to back up")}
+			}
+			backup, err := crypto.EncryptKeyBackup(keys, passphrase)
+			return KeyBackupEncryptedMsg{Backup: backup, Err: err}
+		}
+	}))
+}
+
+// handleKeyBackupEncrypted sends a new key backup to the server
+func (m Model) handleKeyBackupEncrypted(msg KeyBackupEncryptedMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, m.setError(fmt.Sprintf("Failed to back up keys: %v", msg.Err))
+	}
+	return m, tea.Batch(m.setStatus("Uploading key backup..."), func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeStoreKeyBackup, &protocol.StoreKeyBackupMessage{Backup: msg.Backup}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	})
+}
+
+// handleKeyBackupStored processes KEY_BACKUP_STORED
+func (m Model) handleKeyBackupStored(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.KeyBackupStoredMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode key backup response: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+	if msg.UpdatedAt == nil {
+		return m, tea.Batch(m.setStatus("Key backup deleted from server"), listenForServerFrames(m.conn, m.connGeneration))
+	}
+	return m, tea.Batch(m.setStatus("Keys backed up to server"), listenForServerFrames(m.conn, m.connGeneration))
+}
+
+// sendGetKeyBackup requests our key backup from the server
+func (m Model) sendGetKeyBackup() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeGetKeyBackup, &protocol.GetKeyBackupMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// handleKeyBackup processes KEY_BACKUP by asking for the passphrase of the
+// backup and restoring its keys
+func (m Model) handleKeyBackup(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.KeyBackupMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode key backup: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+	if len(msg.Backup) == 0 {
+		return m, tea.Batch(m.setError("No key backup stored on this server"), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	keyStore := m.keyStore
+	m.modalStack.Push(modal.NewKeyBackupModal(true, func(passphrase string) tea.Cmd {
+		return func() tea.Msg {
+			keys, err := crypto.DecryptKeyBackup(msg.Backup, passphrase)
+			if err != nil {
+				return KeysRestoredMsg{Err: err}
+			}
+			imported, err := keyStore.ImportKeys(keys)
+			return KeysRestoredMsg{Imported: imported, Err: err}
+		}
+	}))
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleKeysRestored starts using the restored keys to decrypt. A machine
+// without a key of its own makes one rather than taking over a restored
+// key, so it registers as a device of its own.
+func (m Model) handleKeysRestored(msg KeysRestoredMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, m.setError(fmt.Sprintf("Failed to restore keys: %v", msg.Err))
+	}
+
+	var keyCmd tea.Cmd
+	if !m.loadEncryptionKey() && m.encryptionKeyPriv == nil && m.userID != nil {
+		kp, err := crypto.GenerateX25519KeyPair()
+		if err != nil {
+			return m, m.setError(fmt.Sprintf("Failed to generate encryption key: %v", err))
+		}
+		m.encryptionKeyPriv = kp.PrivateKey[:]
+		m.encryptionKeyPub = kp.PublicKey[:]
+		m.persistEncryptionKey(m.encryptionKeyPriv)
+		keyCmd = m.advertiseEncryptionKey()
+	}
+
+	return m, tea.Batch(m.setStatus(fmt.Sprintf("Restored %d key(s) from backup", msg.Imported)), keyCmd)
+}
+
+// deriveRestoredDMKeys derives the keys of a static encrypted DM from each
+// restored key, for messages sent before this machine had its own key
+func (m Model) deriveRestoredDMKeys(channelID uint64, otherPublicKey []byte) [][]byte {
+	var keys [][]byte
+	for _, restored := range m.restoredKeys {
+		sharedSecret, err := crypto.ComputeSharedSecret(restored, otherPublicKey)
+		if err != nil {
+			continue
+		}
+		if key, err := crypto.DeriveChannelKey(sharedSecret, channelID); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,146 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typeIntoModal types text into the top modal and returns the command of
+// the last key
+func typeIntoModal(t *testing.T, m *Model, keys ...tea.KeyMsg) tea.Cmd {
+	t.Helper()
+	var cmd tea.Cmd
+	for _, key := range keys {
+		top := m.modalStack.Top()
+		if top == nil {
+			t.Fatal("expected a modal")
+		}
+		var next modal.Modal
+		_, next, cmd = top.HandleKey(key)
+		if next == nil {
+			m.modalStack.Pop()
+		}
+	}
+	return cmd
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestKeyBackupRestore(t *testing.T) {
+	userID := uint64(42)
+	newModel := func(t *testing.T) Model {
+		conn := client.NewMockConnection("localhost:6465")
+		conn.Connect()
+		m := NewTestModelWithMocks(conn, client.NewMockState())
+		m.keyStore = crypto.NewKeyStore(t.TempDir())
+		m.userID = &userID
+		m.authState = AuthStateAuthenticated
+		return m
+	}
+
+	// Back up the key of the first machine
+	first := newModel(t)
+	keys, err := first.keyStore.GenerateAndSaveKey("localhost:6465", userID)
+	if err != nil {
+		t.Fatalf("GenerateAndSaveKey: %v", err)
+	}
+	first.showBackupKeysModal()
+	if first.modalStack.TopType() != modal.ModalKeyBackup {
+		t.Fatalf("expected the key backup modal, got %v", first.modalStack.TopType())
+	}
+	typeIntoModal(t, &first, runes("passphrase"), tea.KeyMsg{Type: tea.KeyTab}, runes("different"), tea.KeyMsg{Type: tea.KeyEnter})
+	if first.modalStack.TopType() != modal.ModalKeyBackup {
+		t.Fatal("expected mismatched passphrases to keep the modal open")
+	}
+	first.modalStack.Pop()
+	first.showBackupKeysModal()
+	cmd := typeIntoModal(t, &first, runes("passphrase"), tea.KeyMsg{Type: tea.KeyTab}, runes("passphrase"), tea.KeyMsg{Type: tea.KeyEnter})
+	encrypted, ok := cmd().(KeyBackupEncryptedMsg)
+	if !ok || encrypted.Err != nil {
+		t.Fatalf("expected an encrypted backup, got %+v", encrypted)
+	}
+
+	// A second machine without keys gets the backup from the server
+	second := newModel(t)
+	backupFrame := func(backup []byte) *protocol.Frame {
+		payload, err := (&protocol.KeyBackupMessage{Backup: backup}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeKeyBackup, Payload: payload}
+	}
+	updated, _ := second.handleKeyBackup(backupFrame(encrypted.Backup))
+	second = updated.(Model)
+	cmd = typeIntoModal(t, &second, runes("wrong"), tea.KeyMsg{Type: tea.KeyEnter})
+	updated, _ = second.handleKeysRestored(cmd().(KeysRestoredMsg))
+	second = updated.(Model)
+	if second.encryptionKeyPriv != nil || second.errorMessage == "" {
+		t.Fatal("expected a wrong passphrase to be an error")
+	}
+
+	updated, _ = second.handleKeyBackup(backupFrame(encrypted.Backup))
+	second = updated.(Model)
+	cmd = typeIntoModal(t, &second, runes("passphrase"), tea.KeyMsg{Type: tea.KeyEnter})
+	restored := cmd().(KeysRestoredMsg)
+	if restored.Err != nil || restored.Imported != 1 {
+		t.Fatalf("expected 1 restored key, got %+v", restored)
+	}
+	updated, _ = second.handleKeysRestored(restored)
+	second = updated.(Model)
+
+	// The second machine is a device of its own: it made its own key, which
+	// the server registers as a different device, and keeps the first
+	// machine's key to decrypt with
+	if len(second.encryptionKeyPub) != crypto.X25519KeySize || bytes.Equal(second.encryptionKeyPub, keys.PublicKey[:]) {
+		t.Fatal("expected the second machine to make a device key of its own")
+	}
+	if stored, err := second.keyStore.LoadKey("localhost:6465", userID); err != nil || !bytes.Equal(stored, second.encryptionKeyPriv) {
+		t.Errorf("expected the new device key to be saved, got %v", err)
+	}
+	if len(second.restoredKeys) != 1 || !bytes.Equal(second.restoredKeys[0], keys.PrivateKey[:]) {
+		t.Error("expected the first machine's key to be restored for decryption")
+	}
+
+	// A channel key wrapped for the first machine still opens
+	channelKey, _ := crypto.GenerateChannelKey()
+	wrapped, err := crypto.WrapChannelKey(channelKey, keys.PublicKey[:], 7, 1)
+	if err != nil {
+		t.Fatalf("WrapChannelKey: %v", err)
+	}
+	var entry protocol.ChannelKeyEntry
+	entry.Epoch = 1
+	copy(entry.WrappedKey[:], wrapped)
+	payload, _ := (&protocol.ChannelKeysMessage{ChannelID: 7, Keys: []protocol.ChannelKeyEntry{entry}}).Encode()
+	updated, _ = second.handleChannelKeys(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeChannelKeys, Payload: payload})
+	second = updated.(Model)
+	if keyring, ok := second.channelKeyrings[7]; !ok || keyring.Epoch() != 1 {
+		t.Error("expected a restored key to unwrap the channel key")
+	}
+
+	// Another machine restored from the same backup is another device
+	other := newModel(t)
+	updated, _ = other.handleKeyBackup(backupFrame(encrypted.Backup))
+	other = updated.(Model)
+	cmd = typeIntoModal(t, &other, runes("passphrase"), tea.KeyMsg{Type: tea.KeyEnter})
+	updated, _ = other.handleKeysRestored(cmd().(KeysRestoredMsg))
+	other = updated.(Model)
+	if other.encryptionKeyPub == nil || bytes.Equal(other.encryptionKeyPub, second.encryptionKeyPub) || bytes.Equal(other.encryptionKeyPub, keys.PublicKey[:]) {
+		t.Error("expected machines restored from one backup to have different device keys")
+	}
+
+	// No backup on the server
+	third := newModel(t)
+	updated, _ = third.handleKeyBackup(backupFrame([]byte{}))
+	third = updated.(Model)
+	if third.modalStack.TopType() != modal.ModalNone || third.errorMessage == "" {
+		t.Error("expected an error without a modal when there is no backup")
+	}
+}
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// showMentionsModal opens the mentions view and requests the newest mentions
+func (m *Model) showMentionsModal() tea.Cmd {
+	m.unreadMentions = 0
+	mentionsModal := modal.NewMentionsModal(
+		m.channelDisplayNames(),
+		m.sendListMentions,
+		func(mention protocol.SearchResult) tea.Cmd {
+			return func() tea.Msg {
+				return SearchResultSelectedMsg{Result: mention}
+			}
+		},
+	)
+	m.modalStack.Push(mentionsModal)
+	return m.sendListMentions(nil)
+}
+
+// sendListMentions sends a LIST_MENTIONS request for mentions older than beforeID
+func (m *Model) sendListMentions(beforeID *uint64) tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		msg := &protocol.ListMentionsMessage{BeforeID: beforeID, Limit: 50}
+		if err := conn.SendMessage(protocol.TypeListMentions, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// handleMentionList processes MENTION_LIST
+func (m Model) handleMentionList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.MentionListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode mentions: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if mentionsModal, ok := m.modalStack.Top().(*modal.MentionsModal); ok {
+		mentionsModal.SetMentions(msg.Mentions, msg.HasMore)
+	} else if m.logger != nil {
+		m.logger.Printf("[DEBUG] MentionsModal not on top of stack, dropping %d mentions", len(msg.Mentions))
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleMentionNotification processes MENTION_NOTIFICATION. The server sends
+// these regardless of channel subscriptions, so this is how mentions in other
+// channels reach the user.
+func (m Model) handleMentionNotification(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.MentionNotificationMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode mention: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	mention := msg.Mention.Message
+	where := m.channelDisplayNames()[mention.ChannelID]
+	if where == "" {
+		where = fmt.Sprintf("channel %d", mention.ChannelID)
+	}
+
+	if mentionsModal, ok := m.modalStack.Top().(*modal.MentionsModal); ok {
+		mentionsModal.AddMention(msg.Mention)
+	} else {
+		m.unreadMentions++
+	}
+
+	writeTerminalNotification(m.terminalOut, "SuperChat", fmt.Sprintf("%s mentioned you in %s: %s", mention.AuthorNickname, where, mention.Content))
+
+	statusCmd := m.setStatus(fmt.Sprintf("%s mentioned you in %s - Ctrl+G to view mentions", mention.AuthorNickname, where))
+	return m, tea.Batch(statusCmd, listenForServerFrames(m.conn, m.connGeneration))
+}
+
+// writeTerminalNotification rings the terminal bell and sends an OSC 9
+// notification, which terminals like iTerm2, WezTerm and Windows Terminal show
+// as a desktop notification. Terminals without OSC 9 support ignore it.
+func writeTerminalNotification(w io.Writer, title, body string) {
+	if w == nil {
+		return
+	}
+	text := sanitizeTerminalText(title + ": " + body)
+	if runes := []rune(text); len(runes) > 200 {
+		text = string(runes[:197]) + "..."
+	}
+	fmt.Fprintf(w, "\a\x1b]9;%s\a", text)
+}
+
+// sanitizeTerminalText removes control characters so message content can't
+// end the OSC sequence early or inject escape sequences of its own
+func sanitizeTerminalText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return ' '
+		case r < 0x20 || (r >= 0x7f && r < 0xa0):
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestWriteTerminalNotification(t *testing.T) {
+	var buf bytes.Buffer
+	writeTerminalNotification(&buf, "SuperChat", "alice: hi\x1b]0;pwned\a\nthere")
+
+	want := "\a\x1b]9;SuperChat: alice: hi]0;pwned there\a"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestHandleMentionNotification(t *testing.T) {
+	m := NewModel(client.NewMockConnection("localhost:6465"), client.NewMockState(), "1.0.0", false, 0, log.New(io.Discard, "", 0), "", nil)
+	var out bytes.Buffer
+	m.terminalOut = &out
+	m.channels = []protocol.Channel{{ID: 3, Name: "general"}}
+
+	payload, err := (&protocol.MentionNotificationMessage{
+		Mention: protocol.SearchResult{Message: protocol.Message{ID: 7, ChannelID: 3, AuthorNickname: "bob", Content: "@alice look"}},
+	}).Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	frame := &protocol.Frame{Type: protocol.TypeMentionNotification, Payload: payload}
+
+	updated, _ := m.handleMentionNotification(frame)
+	m = updated.(Model)
+	if m.unreadMentions != 1 {
+		t.Errorf("unreadMentions = %d, want 1", m.unreadMentions)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("\a")) {
+		t.Errorf("expected a terminal bell, got %q", out.String())
+	}
+
+	// Opening the mentions view clears the counter and shows later mentions in place
+	m.showMentionsModal()
+	if m.unreadMentions != 0 {
+		t.Errorf("unreadMentions = %d after opening the view, want 0", m.unreadMentions)
+	}
+	mentionsModal, ok := m.modalStack.Top().(*modal.MentionsModal)
+	if !ok {
+		t.Fatal("expected the mentions modal on top")
+	}
+	mentionsModal.SetMentions([]protocol.SearchResult{{Message: protocol.Message{ID: 7}}}, false)
+
+	updated, _ = m.handleMentionNotification(frame)
+	m = updated.(Model)
+	if m.unreadMentions != 0 {
+		t.Errorf("unreadMentions = %d with the view open, want 0", m.unreadMentions)
+	}
+}
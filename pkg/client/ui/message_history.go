@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// showMessageHistoryModal opens the history view for a message and requests its revisions
+func (m *Model) showMessageHistoryModal(messageID uint64) tea.Cmd {
+	m.modalStack.Push(modal.NewMessageHistoryModal(messageID))
+	return m.sendGetMessageHistory(messageID)
+}
+
+// sendGetMessageHistory sends a GET_MESSAGE_HISTORY request
+func (m *Model) sendGetMessageHistory(messageID uint64) tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		msg := &protocol.GetMessageHistoryMessage{MessageID: messageID}
+		if err := conn.SendMessage(protocol.TypeGetMessageHistory, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// handleMessageHistory processes MESSAGE_HISTORY
+func (m Model) handleMessageHistory(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.MessageHistoryMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode message history: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	historyModal, ok := m.modalStack.Top().(*modal.MessageHistoryModal)
+	if !ok || historyModal.MessageID() != msg.MessageID {
+		if m.logger != nil {
+			m.logger.Printf("[DEBUG] No history modal open for message %d, dropping history", msg.MessageID)
+		}
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if msg.Success {
+		historyModal.SetRevisions(msg.Revisions)
+	} else {
+		historyModal.SetError(msg.Message)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestDiffLines(t *testing.T) {
+	diff := modal.DiffLines("one\ntwo\nthree", "one\n2\nthree\nfour")
+
+	var got []string
+	for _, line := range diff {
+		prefix := " "
+		switch line.Op {
+		case modal.DiffDelete:
+			prefix = "-"
+		case modal.DiffInsert:
+			prefix = "+"
+		}
+		got = append(got, prefix+line.Text)
+	}
+
+	want := []string{" one", "-two", "+2", " three", "+four"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("diff = %v, want %v", got, want)
+	}
+}
+
+func TestHandleMessageHistory(t *testing.T) {
+	m := NewModel(client.NewMockConnection("localhost:6465"), client.NewMockState(), "1.0.0", false, 0, log.New(io.Discard, "", 0), "", nil)
+
+	m.showMessageHistoryModal(7)
+	historyModal, ok := m.modalStack.Top().(*modal.MessageHistoryModal)
+	if !ok {
+		t.Fatal("expected the history modal on top")
+	}
+	if !strings.Contains(historyModal.Render(100, 40), "Loading") {
+		t.Error("expected the modal to show loading before the history arrives")
+	}
+
+	now := time.Now()
+	frameFor := func(msg *protocol.MessageHistoryMessage) *protocol.Frame {
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Type: protocol.TypeMessageHistory, Payload: payload}
+	}
+
+	// History for another message is ignored
+	updated, _ := m.handleMessageHistory(frameFor(&protocol.MessageHistoryMessage{Success: true, MessageID: 8}))
+	m = updated.(Model)
+	if !strings.Contains(historyModal.Render(100, 40), "Loading") {
+		t.Error("expected history for another message to be dropped")
+	}
+
+	updated, _ = m.handleMessageHistory(frameFor(&protocol.MessageHistoryMessage{
+		Success:   true,
+		MessageID: 7,
+		Revisions: []protocol.MessageRevision{
+			{Kind: protocol.RevisionCreated, Timestamp: now.Add(-time.Minute), Content: "helo"},
+			{Kind: protocol.RevisionEdited, Timestamp: now, Content: "hello"},
+		},
+	}))
+	m = updated.(Model)
+
+	// The newest revision is selected and shown as a diff against the one before it
+	view := historyModal.Render(100, 40)
+	if !strings.Contains(view, "- helo") || !strings.Contains(view, "+ hello") {
+		t.Errorf("expected a diff between the revisions, got:\n%s", view)
+	}
+
+	historyModal.SetError("Message not found")
+	if !strings.Contains(historyModal.Render(100, 40), "Message not found") {
+		t.Error("expected the error to be shown")
+	}
+}
@@ -31,11 +31,15 @@ func NewAdminPanelModal() *AdminPanelModal {
 func (m *AdminPanelModal) SetMenuActions(
 	banUserAction func() (Modal, tea.Cmd),
 	banIPAction func() (Modal, tea.Cmd),
+	kickUserAction func() (Modal, tea.Cmd),
+	muteUserAction func() (Modal, tea.Cmd),
 	listUsersAction func() (Modal, tea.Cmd),
 	unbanAction func() (Modal, tea.Cmd),
 	viewBansAction func() (Modal, tea.Cmd),
 	deleteUserAction func() (Modal, tea.Cmd),
 	deleteChannelAction func() (Modal, tea.Cmd),
+	incomingWebhooksAction func() (Modal, tea.Cmd),
+	auditLogAction func() (Modal, tea.Cmd),
 ) {
 	m.menuItems = []adminMenuItem{
 		{
@@ -48,6 +52,16 @@ func (m *AdminPanelModal) SetMenuActions(
 			description: "Ban an IP address or CIDR range",
 			action:      banIPAction,
 		},
+		{
+			label:       "Kick User",
+			description: "Disconnect all of a user's sessions",
+			action:      kickUserAction,
+		},
+		{
+			label:       "Mute User",
+			description: "Stop a user posting, everywhere or in one channel",
+			action:      muteUserAction,
+		},
 		{
 			label:       "List Users",
 			description: "View all users with online status",
@@ -73,6 +87,16 @@ func (m *AdminPanelModal) SetMenuActions(
 			description: "Permanently delete a channel",
 			action:      deleteChannelAction,
 		},
+		{
+			label:       "Incoming Webhooks",
+			description: "Create and revoke tokens for posting over HTTP",
+			action:      incomingWebhooksAction,
+		},
+		{
+			label:       "Audit Log",
+			description: "Review bans, deletions and other admin actions",
+			action:      auditLogAction,
+		},
 	}
 }
 
@@ -0,0 +1,355 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// auditPeriods are the time ranges the audit log can be limited to
+var auditPeriods = []struct {
+	label    string
+	duration time.Duration // 0 = all time
+}{
+	{"All time", 0},
+	{"Last 24 hours", 24 * time.Hour},
+	{"Last 7 days", 7 * 24 * time.Hour},
+	{"Last 30 days", 30 * 24 * time.Hour},
+}
+
+// Which filter is being edited
+const (
+	auditEditNone = iota
+	auditEditAdmin
+	auditEditAction
+)
+
+// AuditLogModal pages through the admin audit log with filters for admin,
+// action type and time range
+type AuditLogModal struct {
+	actions       []protocol.AdminActionEntry
+	hasMore       bool
+	loading       bool
+	loadingMore   bool
+	errorMsg      string
+	selectedIndex int
+
+	adminFilter  string
+	actionFilter string
+	periodIndex  int
+	editing      int
+	editBuffer   string
+
+	onQuery func(query *protocol.ListAdminActionsMessage) tea.Cmd
+}
+
+// NewAuditLogModal creates a new audit log modal. The caller is expected to
+// send the initial query; the modal starts out loading.
+func NewAuditLogModal(onQuery func(query *protocol.ListAdminActionsMessage) tea.Cmd) *AuditLogModal {
+	return &AuditLogModal{
+		loading: true,
+		onQuery: onQuery,
+	}
+}
+
+// Type returns the modal type
+func (m *AuditLogModal) Type() ModalType {
+	return ModalAuditLog
+}
+
+// Query returns the request for the current filters. beforeID continues
+// from an earlier page.
+func (m *AuditLogModal) Query(beforeID *uint64) *protocol.ListAdminActionsMessage {
+	query := &protocol.ListAdminActionsMessage{
+		AdminNickname: m.adminFilter,
+		ActionType:    m.actionFilter,
+		BeforeID:      beforeID,
+	}
+	if period := auditPeriods[m.periodIndex].duration; period > 0 {
+		since := time.Now().Add(-period).UnixMilli()
+		query.Since = &since
+	}
+	return query
+}
+
+// SetActions stores a page of audit log entries
+func (m *AuditLogModal) SetActions(actions []protocol.AdminActionEntry, hasMore bool) {
+	if m.loadingMore {
+		m.actions = append(m.actions, actions...)
+	} else {
+		m.actions = actions
+		m.selectedIndex = 0
+	}
+	m.hasMore = hasMore
+	m.loading = false
+	m.loadingMore = false
+	m.errorMsg = ""
+}
+
+// SetError shows an error returned by the server
+func (m *AuditLogModal) SetError(message string) {
+	m.loading = false
+	m.loadingMore = false
+	m.errorMsg = message
+}
+
+// refresh reloads the first page for the current filters
+func (m *AuditLogModal) refresh() tea.Cmd {
+	if m.onQuery == nil {
+		return nil
+	}
+	m.loading = true
+	m.loadingMore = false
+	return m.onQuery(m.Query(nil))
+}
+
+// loadMore requests the next (older) page of entries
+func (m *AuditLogModal) loadMore() tea.Cmd {
+	if !m.hasMore || m.loading || len(m.actions) == 0 || m.onQuery == nil {
+		return nil
+	}
+	beforeID := m.actions[len(m.actions)-1].ID
+	m.loading = true
+	m.loadingMore = true
+	return m.onQuery(m.Query(&beforeID))
+}
+
+// HandleKey processes keyboard input
+func (m *AuditLogModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	if m.editing != auditEditNone {
+		return m.handleEditKey(msg)
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		// Close modal and return to admin panel
+		return true, nil, nil
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.actions)-1 {
+			m.selectedIndex++
+		}
+		// Fetch the next page when reaching the end of the list
+		if m.selectedIndex >= len(m.actions)-5 {
+			return true, m, m.loadMore()
+		}
+		return true, m, nil
+
+	case "a":
+		m.editing = auditEditAdmin
+		m.editBuffer = m.adminFilter
+		return true, m, nil
+
+	case "t":
+		m.editing = auditEditAction
+		m.editBuffer = m.actionFilter
+		return true, m, nil
+
+	case "p":
+		m.periodIndex = (m.periodIndex + 1) % len(auditPeriods)
+		return true, m, m.refresh()
+
+	case "r":
+		return true, m, m.refresh()
+
+	default:
+		return true, m, nil
+	}
+}
+
+// handleEditKey processes input while a filter is being edited
+func (m *AuditLogModal) handleEditKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.editing = auditEditNone
+		return true, m, nil
+
+	case "enter":
+		value := strings.TrimSpace(m.editBuffer)
+		if m.editing == auditEditAdmin {
+			m.adminFilter = value
+		} else {
+			m.actionFilter = value
+		}
+		m.editing = auditEditNone
+		return true, m, m.refresh()
+
+	case "backspace":
+		if len(m.editBuffer) > 0 {
+			runes := []rune(m.editBuffer)
+			m.editBuffer = string(runes[:len(runes)-1])
+		}
+		return true, m, nil
+
+	case "ctrl+u":
+		m.editBuffer = ""
+		return true, m, nil
+
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.editBuffer += string(msg.Runes)
+		}
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *AuditLogModal) Render(width, height int) string {
+	modalWidth := min(max(width-10, 60), 100)
+	contentWidth := modalWidth - 6
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("196")).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("196")).
+		Bold(true)
+
+	unselectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252"))
+
+	filterStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("39"))
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	detailStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("196")).
+		Padding(1, 2).
+		Width(modalWidth)
+
+	title := titleStyle.Render("Audit Log")
+
+	filters := fmt.Sprintf("Admin: %s  Action: %s  Period: %s",
+		filterStyle.Render(m.filterLabel(auditEditAdmin, m.adminFilter)),
+		filterStyle.Render(m.filterLabel(auditEditAction, m.actionFilter)),
+		filterStyle.Render(auditPeriods[m.periodIndex].label))
+
+	// Keep the modal within the terminal
+	maxVisible := max(min(height-4, 40)-16, 3)
+
+	var lines []string
+	switch {
+	case m.errorMsg != "":
+		lines = append(lines, errorStyle.Render(m.errorMsg))
+	case m.loading && !m.loadingMore:
+		lines = append(lines, hintStyle.Render("Loading..."))
+	case len(m.actions) == 0:
+		lines = append(lines, hintStyle.Render("No admin actions match these filters"))
+	default:
+		start := 0
+		if len(m.actions) > maxVisible {
+			start = max(m.selectedIndex-maxVisible/2, 0)
+			if start+maxVisible > len(m.actions) {
+				start = len(m.actions) - maxVisible
+			}
+		}
+		end := min(start+maxVisible, len(m.actions))
+
+		if start > 0 {
+			lines = append(lines, hintStyle.Render("  ↑ newer entries above"))
+		}
+		for i := start; i < end; i++ {
+			action := m.actions[i]
+			line := fmt.Sprintf("%s  %-12s %-16s %s",
+				time.UnixMilli(action.PerformedAt).Local().Format("2006-01-02 15:04"),
+				truncateRunes(action.AdminNickname, 12),
+				truncateRunes(action.ActionType, 16),
+				auditSummary(action))
+			line = truncateRunes(line, contentWidth)
+			if i == m.selectedIndex {
+				lines = append(lines, selectedStyle.Render(line))
+			} else {
+				lines = append(lines, unselectedStyle.Render(line))
+			}
+		}
+		if end < len(m.actions) || m.hasMore {
+			lines = append(lines, hintStyle.Render("  ↓ older entries below"))
+		}
+
+		// Full details of the selected entry
+		selected := m.actions[m.selectedIndex]
+		lines = append(lines, "", strings.Repeat("─", contentWidth))
+		if selected.TargetType != "" {
+			target := selected.TargetType
+			if selected.TargetIdentifier != "" {
+				target += " " + selected.TargetIdentifier
+			}
+			if selected.TargetID != nil {
+				target += fmt.Sprintf(" (#%d)", *selected.TargetID)
+			}
+			lines = append(lines, detailStyle.Render(truncateRunes("Target: "+target, contentWidth)))
+		}
+		if selected.Details != "" {
+			lines = append(lines, detailStyle.Render(truncateRunes("Details: "+selected.Details, contentWidth)))
+		}
+		if selected.IPAddress != nil {
+			lines = append(lines, detailStyle.Render("From: "+*selected.IPAddress))
+		}
+	}
+
+	var help string
+	if m.editing != auditEditNone {
+		help = hintStyle.Render("[Enter] Apply filter (empty = any)  [Ctrl+U] Clear  [Esc] Cancel")
+	} else {
+		help = hintStyle.Render("[a] Admin  [t] Action  [p] Period  [r] Refresh  [↑/↓] Navigate  [Esc/q] Close")
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		filters,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		help,
+	)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content))
+}
+
+// filterLabel renders a filter value, or the edit buffer while it is being edited
+func (m *AuditLogModal) filterLabel(field int, value string) string {
+	if m.editing == field {
+		return m.editBuffer + "█"
+	}
+	if value == "" {
+		return "any"
+	}
+	return value
+}
+
+// auditSummary describes the target of an action, falling back to its details
+func auditSummary(action protocol.AdminActionEntry) string {
+	if action.TargetIdentifier != "" {
+		return action.TargetIdentifier
+	}
+	return strings.Join(strings.Fields(action.Details), " ")
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *AuditLogModal) IsBlockingInput() bool {
+	return true
+}
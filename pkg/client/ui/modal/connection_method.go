@@ -13,6 +13,7 @@ type ConnectionMethod string
 
 const (
 	MethodTCP       ConnectionMethod = "tcp"
+	MethodTLS       ConnectionMethod = "tls"
 	MethodSSH       ConnectionMethod = "ssh"
 	MethodWebSocket ConnectionMethod = "websocket"
 )
@@ -41,17 +42,20 @@ func NewConnectionMethodModal(server string, failedMethod ConnectionMethod, erro
 	
 	switch failedMethod {
 	case MethodTCP:
-		// TCP failed, offer WebSocket and SSH
-		available = append(available, MethodWebSocket, MethodSSH)
+		// TCP failed, offer WebSocket, TLS and SSH
+		available = append(available, MethodWebSocket, MethodTLS, MethodSSH)
+	case MethodTLS:
+		// TLS failed, offer TCP, WebSocket and SSH
+		available = append(available, MethodTCP, MethodWebSocket, MethodSSH)
 	case MethodSSH:
-		// SSH failed, offer TCP and WebSocket
-		available = append(available, MethodTCP, MethodWebSocket)
+		// SSH failed, offer TCP, TLS and WebSocket
+		available = append(available, MethodTCP, MethodTLS, MethodWebSocket)
 	case MethodWebSocket:
-		// WebSocket failed, offer TCP and SSH
-		available = append(available, MethodTCP, MethodSSH)
+		// WebSocket failed, offer TCP, TLS and SSH
+		available = append(available, MethodTCP, MethodTLS, MethodSSH)
 	default:
 		// Unknown method failed, offer all
-		available = append(available, MethodTCP, MethodWebSocket, MethodSSH)
+		available = append(available, MethodTCP, MethodTLS, MethodWebSocket, MethodSSH)
 	}
 	
 	return &ConnectionMethodModal{
@@ -136,6 +140,8 @@ func (m *ConnectionMethodModal) Render(width, height int) string {
 	switch m.failedMethod {
 	case MethodTCP:
 		failedMethodName = "TCP (binary protocol)"
+	case MethodTLS:
+		failedMethodName = "TLS (encrypted binary protocol)"
 	case MethodSSH:
 		failedMethodName = "SSH"
 	case MethodWebSocket:
@@ -160,6 +166,9 @@ func (m *ConnectionMethodModal) Render(width, height int) string {
 		case MethodTCP:
 			methodName = "TCP (binary protocol)"
 			methodDesc = "Standard connection on port 6465"
+		case MethodTLS:
+			methodName = "TLS (encrypted binary protocol)"
+			methodDesc = "Encrypted connection on port 6467 (no keys needed)"
 		case MethodSSH:
 			methodName = "SSH"
 			methodDesc = "Encrypted connection on port 6466 (requires keys)"
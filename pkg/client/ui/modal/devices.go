@@ -0,0 +1,191 @@
+package modal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DevicesModal lists the devices the user reads encrypted DMs on and lets
+// them revoke the ones they no longer use
+type DevicesModal struct {
+	devices       []protocol.DeviceInfo
+	currentKey    []byte // This device's public key
+	selectedIndex int
+	loading       bool
+	confirmRevoke bool
+	onRefresh     func() tea.Cmd
+	onRevoke      func(deviceID uint64) tea.Cmd
+}
+
+// NewDevicesModal creates a new devices modal
+func NewDevicesModal(currentKey []byte, onRefresh func() tea.Cmd, onRevoke func(deviceID uint64) tea.Cmd) *DevicesModal {
+	return &DevicesModal{
+		currentKey: currentKey,
+		loading:    true, // Start in loading state
+		onRefresh:  onRefresh,
+		onRevoke:   onRevoke,
+	}
+}
+
+// SetDevices sets the device list
+func (m *DevicesModal) SetDevices(devices []protocol.DeviceInfo) {
+	m.devices = devices
+	m.loading = false
+	m.confirmRevoke = false
+	if m.selectedIndex >= len(devices) {
+		m.selectedIndex = max(len(devices)-1, 0)
+	}
+}
+
+// Type returns the modal type
+func (m *DevicesModal) Type() ModalType {
+	return ModalDevices
+}
+
+// HandleKey processes keyboard input
+func (m *DevicesModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	if m.confirmRevoke {
+		switch msg.String() {
+		case "y", "Y":
+			m.confirmRevoke = false
+			if m.onRevoke != nil && m.selectedIndex < len(m.devices) {
+				return true, m, m.onRevoke(m.devices[m.selectedIndex].DeviceID)
+			}
+		case "n", "N", "esc":
+			m.confirmRevoke = false
+		}
+		return true, m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		return true, nil, nil
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.devices)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case "d":
+		if m.selectedIndex < len(m.devices) {
+			m.confirmRevoke = true
+		}
+		return true, m, nil
+
+	case "r":
+		m.loading = true
+		if m.onRefresh != nil {
+			return true, m, m.onRefresh()
+		}
+		return true, m, nil
+
+	default:
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *DevicesModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("205")).
+		Bold(true).
+		Padding(0, 1)
+
+	unselectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252")).
+		Padding(0, 1)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	warningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("208")).
+		Bold(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(80).
+		Height(min(height-4, 30))
+
+	title := titleStyle.Render("Encryption Devices")
+
+	var lines []string
+	if m.loading {
+		lines = append(lines, hintStyle.Render("Loading..."))
+	} else if len(m.devices) == 0 {
+		lines = append(lines, hintStyle.Render("No devices have an encryption key yet"))
+	} else {
+		for i, device := range m.devices {
+			line := fmt.Sprintf("%s | Key: %s | Last seen: %s",
+				formatDeviceLabel(device, m.currentKey), hex.EncodeToString(device.PublicKey[:4]),
+				time.UnixMilli(device.LastSeenAt).Format("2006-01-02 15:04"))
+			if i == m.selectedIndex {
+				lines = append(lines, selectedStyle.Render(line))
+			} else {
+				lines = append(lines, unselectedStyle.Render(line))
+			}
+		}
+	}
+
+	var footer string
+	if m.confirmRevoke && m.selectedIndex < len(m.devices) {
+		footer = warningStyle.Render(fmt.Sprintf("Revoke '%s'? It won't receive new encrypted DMs and its key can't be used again. [y/n]",
+			formatDeviceLabel(m.devices[m.selectedIndex], m.currentKey)))
+	} else {
+		footer = hintStyle.Render("[d] Revoke  [r] Refresh  [↑/↓] Navigate  [Esc/q] Close")
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		footer,
+	)
+
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(content),
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *DevicesModal) IsBlockingInput() bool {
+	return true
+}
+
+func formatDeviceLabel(device protocol.DeviceInfo, currentKey []byte) string {
+	label := device.Label
+	if label == "" {
+		label = fmt.Sprintf("Device %d", device.DeviceID)
+	}
+	if string(device.PublicKey[:]) == string(currentKey) {
+		label += " (this device)"
+	}
+	return label
+}
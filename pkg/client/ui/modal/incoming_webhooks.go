@@ -0,0 +1,478 @@
+package modal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// IncomingWebhooksModal lists incoming webhooks and lets admins create and revoke them
+type IncomingWebhooksModal struct {
+	webhooks      []protocol.IncomingWebhookEntry
+	selectedIndex int
+	loading       bool
+	confirmRevoke bool
+	createdInfo   string // Server message for the last created webhook
+	createdToken  string // Token of the last created webhook (only shown once)
+	onRefresh     func() tea.Cmd
+	onCreate      func() (Modal, tea.Cmd)
+	onRevoke      func(webhookID uint64) tea.Cmd
+}
+
+// NewIncomingWebhooksModal creates a new incoming webhooks modal
+func NewIncomingWebhooksModal() *IncomingWebhooksModal {
+	return &IncomingWebhooksModal{
+		loading: true, // Start in loading state
+	}
+}
+
+// SetWebhooks sets the webhook list
+func (m *IncomingWebhooksModal) SetWebhooks(webhooks []protocol.IncomingWebhookEntry) {
+	m.webhooks = webhooks
+	m.loading = false
+	m.confirmRevoke = false
+	if m.selectedIndex >= len(webhooks) {
+		m.selectedIndex = max(len(webhooks)-1, 0)
+	}
+}
+
+// SetCreatedToken shows the token of a newly created webhook
+func (m *IncomingWebhooksModal) SetCreatedToken(info, token string) {
+	m.createdInfo = info
+	m.createdToken = token
+}
+
+// SetHandlers sets the callbacks for refreshing, creating and revoking webhooks
+func (m *IncomingWebhooksModal) SetHandlers(
+	onRefresh func() tea.Cmd,
+	onCreate func() (Modal, tea.Cmd),
+	onRevoke func(webhookID uint64) tea.Cmd,
+) {
+	m.onRefresh = onRefresh
+	m.onCreate = onCreate
+	m.onRevoke = onRevoke
+}
+
+// Type returns the modal type
+func (m *IncomingWebhooksModal) Type() ModalType {
+	return ModalIncomingWebhooks
+}
+
+// HandleKey processes keyboard input
+func (m *IncomingWebhooksModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	if m.confirmRevoke {
+		switch msg.String() {
+		case "y", "Y":
+			m.confirmRevoke = false
+			if m.onRevoke != nil && m.selectedIndex < len(m.webhooks) {
+				return true, m, m.onRevoke(m.webhooks[m.selectedIndex].ID)
+			}
+		case "n", "N", "esc":
+			m.confirmRevoke = false
+		}
+		return true, m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		// Close modal and return to admin panel
+		return true, nil, nil
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.webhooks)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case "n":
+		if m.onCreate != nil {
+			newModal, modalCmd := m.onCreate()
+			return true, m, func() tea.Msg {
+				return PushModalMsg{Modal: newModal, Cmd: modalCmd}
+			}
+		}
+		return true, m, nil
+
+	case "d":
+		if m.selectedIndex < len(m.webhooks) {
+			m.confirmRevoke = true
+		}
+		return true, m, nil
+
+	case "r":
+		m.loading = true
+		if m.onRefresh != nil {
+			return true, m, m.onRefresh()
+		}
+		return true, m, nil
+
+	default:
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *IncomingWebhooksModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("196")).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("196")).
+		Bold(true).
+		Padding(0, 1)
+
+	unselectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252")).
+		Padding(0, 1)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	tokenStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("46")).
+		Bold(true)
+
+	warningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("208")).
+		Bold(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("196")).
+		Padding(1, 2).
+		Width(90).
+		Height(min(height-4, 30))
+
+	title := titleStyle.Render("Incoming Webhooks")
+
+	var lines []string
+	if m.createdToken != "" {
+		lines = append(lines,
+			tokenStyle.Render(m.createdInfo+". POST messages to:"),
+			tokenStyle.Render("  /hooks/"+m.createdToken),
+			hintStyle.Render("The token is only shown once. Copy it now."),
+			"",
+		)
+	}
+
+	if m.loading {
+		lines = append(lines, hintStyle.Render("Loading..."))
+	} else if len(m.webhooks) == 0 {
+		lines = append(lines, hintStyle.Render("No incoming webhooks (press N to create one)"))
+	} else {
+		for i, hook := range m.webhooks {
+			line := fmt.Sprintf("%s | %s | By: %s | Last used: %s",
+				hook.Name, formatWebhookTarget(hook), hook.CreatedBy, formatWebhookLastUsed(hook.LastUsedAt))
+			if i == m.selectedIndex {
+				lines = append(lines, selectedStyle.Render(line))
+			} else {
+				lines = append(lines, unselectedStyle.Render(line))
+			}
+		}
+	}
+
+	var footer string
+	if m.confirmRevoke && m.selectedIndex < len(m.webhooks) {
+		footer = warningStyle.Render(fmt.Sprintf("Revoke webhook '%s'? Its token will stop working. [y/n]", m.webhooks[m.selectedIndex].Name))
+	} else {
+		footer = hintStyle.Render("[n] New  [d] Revoke  [r] Refresh  [↑/↓] Navigate  [Esc/q] Close")
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		footer,
+	)
+
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(content),
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *IncomingWebhooksModal) IsBlockingInput() bool {
+	return true
+}
+
+func formatWebhookTarget(hook protocol.IncomingWebhookEntry) string {
+	target := "#" + hook.ChannelName
+	if hook.ChannelName == "" {
+		target = fmt.Sprintf("channel %d", hook.ChannelID)
+	}
+	if hook.SubchannelID != nil {
+		target += fmt.Sprintf(" / sub %d", *hook.SubchannelID)
+	}
+	if hook.ParentID != nil {
+		target += fmt.Sprintf(" / thread %d", *hook.ParentID)
+	}
+	return target
+}
+
+func formatWebhookLastUsed(lastUsedAt *int64) string {
+	if lastUsedAt == nil {
+		return "never"
+	}
+	return time.UnixMilli(*lastUsedAt).Format("2006-01-02 15:04")
+}
+
+// CreateIncomingWebhookModal is the form for creating an incoming webhook
+type CreateIncomingWebhookModal struct {
+	activeField  int // 0=name, 1=channel, 2=subchannel, 3=thread
+	name         string
+	channel      string
+	subchannel   string // Optional subchannel ID
+	thread       string // Optional message ID to reply to
+	channels     []ChannelInfo
+	errorMessage string
+	onSubmit     func(*protocol.CreateIncomingWebhookMessage) tea.Cmd
+}
+
+// NewCreateIncomingWebhookModal creates a new create incoming webhook modal
+func NewCreateIncomingWebhookModal() *CreateIncomingWebhookModal {
+	return &CreateIncomingWebhookModal{}
+}
+
+// SetChannels sets the channels the webhook can post into
+func (m *CreateIncomingWebhookModal) SetChannels(channels []ChannelInfo) {
+	m.channels = channels
+}
+
+// SetTarget pre-fills the channel and thread fields
+func (m *CreateIncomingWebhookModal) SetTarget(channel string, threadID *uint64) {
+	m.channel = channel
+	if threadID != nil {
+		m.thread = strconv.FormatUint(*threadID, 10)
+	}
+}
+
+// SetSubmitHandler sets the callback for when the form is submitted
+func (m *CreateIncomingWebhookModal) SetSubmitHandler(handler func(*protocol.CreateIncomingWebhookMessage) tea.Cmd) {
+	m.onSubmit = handler
+}
+
+// Type returns the modal type
+func (m *CreateIncomingWebhookModal) Type() ModalType {
+	return ModalCreateIncomingWebhook
+}
+
+// field returns a pointer to the active field's value
+func (m *CreateIncomingWebhookModal) field() *string {
+	switch m.activeField {
+	case 0:
+		return &m.name
+	case 1:
+		return &m.channel
+	case 2:
+		return &m.subchannel
+	default:
+		return &m.thread
+	}
+}
+
+// HandleKey processes keyboard input
+func (m *CreateIncomingWebhookModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		return true, nil, nil
+
+	case "tab", "down":
+		m.activeField = (m.activeField + 1) % 4
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "shift+tab", "up":
+		m.activeField = (m.activeField - 1 + 4) % 4
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "enter", "ctrl+enter":
+		return m.submit()
+
+	case "backspace":
+		if f := m.field(); len(*f) > 0 {
+			*f = (*f)[:len(*f)-1]
+		}
+		m.errorMessage = ""
+		return true, m, nil
+
+	default:
+		if len(msg.String()) == 1 {
+			char := msg.String()
+			f := m.field()
+			switch m.activeField {
+			case 0, 1: // name, channel
+				if len(*f) < 50 {
+					*f += char
+				}
+			case 2, 3: // IDs are digits only
+				if char >= "0" && char <= "9" && len(*f) < 20 {
+					*f += char
+				}
+			}
+			m.errorMessage = ""
+		}
+		return true, m, nil
+	}
+}
+
+func (m *CreateIncomingWebhookModal) submit() (bool, Modal, tea.Cmd) {
+	name := strings.TrimSpace(m.name)
+	if len(name) < 3 {
+		m.errorMessage = "Name must be at least 3 characters"
+		m.activeField = 0
+		return true, m, nil
+	}
+
+	channelName := strings.TrimPrefix(strings.TrimSpace(m.channel), "#")
+	var channelID uint64
+	found := false
+	for _, ch := range m.channels {
+		if strings.EqualFold(ch.Name, channelName) {
+			channelID = ch.ID
+			found = true
+			break
+		}
+	}
+	if !found {
+		m.errorMessage = fmt.Sprintf("Unknown channel '%s'", channelName)
+		m.activeField = 1
+		return true, m, nil
+	}
+
+	msg := &protocol.CreateIncomingWebhookMessage{
+		Name:      name,
+		ChannelID: channelID,
+	}
+	if m.subchannel != "" {
+		id, err := strconv.ParseUint(m.subchannel, 10, 64)
+		if err != nil {
+			m.errorMessage = "Invalid subchannel ID"
+			m.activeField = 2
+			return true, m, nil
+		}
+		msg.SubchannelID = &id
+	}
+	if m.thread != "" {
+		id, err := strconv.ParseUint(m.thread, 10, 64)
+		if err != nil {
+			m.errorMessage = "Invalid thread message ID"
+			m.activeField = 3
+			return true, m, nil
+		}
+		msg.ParentID = &id
+	}
+
+	var cmd tea.Cmd
+	if m.onSubmit != nil {
+		cmd = m.onSubmit(msg)
+	}
+	return true, nil, cmd
+}
+
+// Render returns the modal content
+func (m *CreateIncomingWebhookModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("196")).
+		MarginBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252")).
+		Width(15)
+
+	activeInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	inactiveInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245")).
+		Padding(0, 1)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("196")).
+		Padding(1, 2).
+		Width(70)
+
+	values := []string{m.name, m.channel, m.subchannel, m.thread}
+	fields := make([]string, len(values))
+	for i, value := range values {
+		if i == m.activeField {
+			fields[i] = activeInputStyle.Render(value + "█")
+		} else {
+			fields[i] = inactiveInputStyle.Render(value)
+		}
+	}
+
+	form := lipgloss.JoinVertical(
+		lipgloss.Left,
+		labelStyle.Render("Name:")+"  "+fields[0],
+		hintStyle.Render("               (shown as the message author)"),
+		"",
+		labelStyle.Render("Channel:")+"  "+fields[1],
+		"",
+		labelStyle.Render("Subchannel:")+"  "+fields[2],
+		hintStyle.Render("               (optional subchannel ID)"),
+		"",
+		labelStyle.Render("Thread:")+"  "+fields[3],
+		hintStyle.Render("               (optional message ID, posts replies to that thread)"),
+	)
+
+	var errorLine string
+	if m.errorMessage != "" {
+		errorLine = "\n" + errorStyle.Render("✗ "+m.errorMessage) + "\n"
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render("Create Incoming Webhook"),
+		"",
+		form,
+		errorLine,
+		hintStyle.Render("[Tab] Next field  [Enter] Create  [Esc] Cancel"),
+	)
+
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(content),
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *CreateIncomingWebhookModal) IsBlockingInput() bool {
+	return true
+}
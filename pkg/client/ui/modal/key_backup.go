@@ -0,0 +1,163 @@
+package modal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// KeyBackupModal asks for the passphrase of a key backup. When backing up,
+// the passphrase has to be entered twice.
+type KeyBackupModal struct {
+	restore      bool
+	passphrase   string
+	confirm      string
+	focusIndex   int
+	errorMessage string
+	onConfirm    func(passphrase string) tea.Cmd
+}
+
+// NewKeyBackupModal creates a new key backup passphrase modal. restore is
+// true when opening a backup rather than making one.
+func NewKeyBackupModal(restore bool, onConfirm func(passphrase string) tea.Cmd) *KeyBackupModal {
+	return &KeyBackupModal{
+		restore:   restore,
+		onConfirm: onConfirm,
+	}
+}
+
+// Type returns the modal type
+func (m *KeyBackupModal) Type() ModalType {
+	return ModalKeyBackup
+}
+
+// HandleKey processes keyboard input
+func (m *KeyBackupModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		return m.submit()
+
+	case "esc":
+		return true, nil, nil
+
+	case "tab", "down", "shift+tab", "up":
+		if !m.restore {
+			m.focusIndex = 1 - m.focusIndex
+		}
+		return true, m, nil
+
+	case "backspace":
+		field := m.field()
+		if len(*field) > 0 {
+			*field = (*field)[:len(*field)-1]
+		}
+		return true, m, nil
+
+	default:
+		if msg.Type == tea.KeyRunes {
+			field := m.field()
+			if len(*field) < 256 {
+				*field += string(msg.Runes)
+			}
+		}
+		return true, m, nil
+	}
+}
+
+// field returns the focused input field
+func (m *KeyBackupModal) field() *string {
+	if m.focusIndex == 1 {
+		return &m.confirm
+	}
+	return &m.passphrase
+}
+
+func (m *KeyBackupModal) submit() (bool, Modal, tea.Cmd) {
+	if m.passphrase == "" {
+		m.errorMessage = "Passphrase cannot be empty"
+		return true, m, nil
+	}
+	if !m.restore {
+		if len(m.passphrase) < 8 {
+			m.errorMessage = "Passphrase must be at least 8 characters"
+			return true, m, nil
+		}
+		if m.passphrase != m.confirm {
+			m.errorMessage = "Passphrases do not match"
+			return true, m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	if m.onConfirm != nil {
+		cmd = m.onConfirm(m.passphrase)
+	}
+	return true, nil, cmd
+}
+
+// Render returns the modal content
+func (m *KeyBackupModal) Render(width, height int) string {
+	inputFocusedStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170")).
+		Padding(0, 1)
+
+	inputBlurredStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	mutedTextStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240"))
+
+	boldStyle := lipgloss.NewStyle().Bold(true)
+
+	renderField := func(label, value string, focused bool) string {
+		style := inputBlurredStyle
+		if focused {
+			style = inputFocusedStyle
+		}
+		return label + "\n" + style.Render(strings.Repeat("•", len(value))) + "\n\n"
+	}
+
+	var content string
+	if m.restore {
+		content = boldStyle.Render("Restore Keys") + "\n\n" +
+			mutedTextStyle.Render("Enter the passphrase of the key backup stored on this server.") + "\n\n" +
+			renderField("Passphrase:", m.passphrase, true)
+	} else {
+		content = boldStyle.Render("Back Up Keys") + "\n\n" +
+			mutedTextStyle.Render("Your encryption keys are encrypted with this passphrase before\nthey are stored on the server. Without it they can't be restored.") + "\n\n" +
+			renderField("Passphrase:", m.passphrase, m.focusIndex == 0) +
+			renderField("Confirm Passphrase:", m.confirm, m.focusIndex == 1)
+	}
+
+	if m.errorMessage != "" {
+		content += errorStyle.Render(m.errorMessage) + "\n\n"
+	}
+
+	if m.restore {
+		content += mutedTextStyle.Render("[Enter] Restore • [Esc] Cancel")
+	} else {
+		content += mutedTextStyle.Render("[Enter] Back Up • [Esc] Cancel • [Tab] Next Field")
+	}
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(70)
+
+	box := modalStyle.Render(content)
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, box)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *KeyBackupModal) IsBlockingInput() bool {
+	return true
+}
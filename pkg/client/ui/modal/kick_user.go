@@ -0,0 +1,180 @@
+package modal
+
+import (
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// KickUserModal handles disconnecting a user
+type KickUserModal struct {
+	activeField  int // 0=nickname, 1=reason
+	nickname     string
+	reason       string
+	errorMessage string
+	onSubmit     func(*protocol.KickUserMessage) tea.Cmd
+}
+
+// NewKickUserModal creates a new kick user modal
+func NewKickUserModal() *KickUserModal {
+	return &KickUserModal{}
+}
+
+// SetSubmitHandler sets the callback for when the form is submitted
+func (m *KickUserModal) SetSubmitHandler(handler func(*protocol.KickUserMessage) tea.Cmd) {
+	m.onSubmit = handler
+}
+
+// SetNickname pre-fills the nickname field
+func (m *KickUserModal) SetNickname(nickname string) {
+	m.nickname = nickname
+}
+
+// Type returns the modal type
+func (m *KickUserModal) Type() ModalType {
+	return ModalKickUser
+}
+
+// HandleKey processes keyboard input
+func (m *KickUserModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		// Close modal and return to admin panel
+		return true, nil, nil
+
+	case "tab", "down", "shift+tab", "up":
+		m.activeField = 1 - m.activeField
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "enter":
+		if m.activeField == 1 { // On reason field, submit
+			return m.submit()
+		}
+		m.activeField = 1
+		return true, m, nil
+
+	case "ctrl+enter":
+		// Submit from any field
+		return m.submit()
+
+	case "backspace":
+		if m.activeField == 0 {
+			if len(m.nickname) > 0 {
+				m.nickname = m.nickname[:len(m.nickname)-1]
+			}
+		} else if len(m.reason) > 0 {
+			m.reason = m.reason[:len(m.reason)-1]
+		}
+		m.errorMessage = ""
+		return true, m, nil
+
+	default:
+		// Type into active field
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			if m.activeField == 0 {
+				if len(m.nickname) < 20 && msg.Type == tea.KeyRunes {
+					m.nickname += string(msg.Runes)
+				}
+			} else if len(m.reason) < 200 {
+				m.reason += string(msg.Runes)
+			}
+			m.errorMessage = ""
+		}
+		return true, m, nil
+	}
+}
+
+func (m *KickUserModal) submit() (bool, Modal, tea.Cmd) {
+	if strings.TrimSpace(m.nickname) == "" {
+		m.errorMessage = "Nickname is required"
+		m.activeField = 0
+		return true, m, nil
+	}
+
+	nickname := strings.TrimSpace(m.nickname)
+	msg := &protocol.KickUserMessage{
+		Nickname: &nickname,
+		Reason:   strings.TrimSpace(m.reason),
+	}
+
+	var cmd tea.Cmd
+	if m.onSubmit != nil {
+		cmd = m.onSubmit(msg)
+	}
+	return true, nil, cmd
+}
+
+// Render returns the modal content
+func (m *KickUserModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("196")).
+		MarginBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252")).
+		Width(15)
+
+	activeInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	inactiveInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245")).
+		Padding(0, 1)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("196")).
+		Padding(1, 2).
+		Width(70)
+
+	nicknameField := inactiveInputStyle.Render(m.nickname)
+	reasonField := inactiveInputStyle.Render(m.reason)
+	if m.activeField == 0 {
+		nicknameField = activeInputStyle.Render(m.nickname + "█")
+	} else {
+		reasonField = activeInputStyle.Render(m.reason + "█")
+	}
+
+	form := lipgloss.JoinVertical(
+		lipgloss.Left,
+		labelStyle.Render("Nickname:")+"  "+nicknameField,
+		"",
+		labelStyle.Render("Reason:")+"  "+reasonField,
+		hintStyle.Render("               (shown to the user when they are disconnected)"),
+	)
+
+	var errorLine string
+	if m.errorMessage != "" {
+		errorLine = "\n" + errorStyle.Render("✗ "+m.errorMessage) + "\n"
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render("Kick User"),
+		"",
+		form,
+		errorLine,
+		hintStyle.Render("[Tab] Next field  [Ctrl+Enter] Submit  [Esc] Cancel"),
+	)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content))
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *KickUserModal) IsBlockingInput() bool {
+	return true
+}
@@ -0,0 +1,231 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MentionsModal lists messages that mentioned the user, newest first
+type MentionsModal struct {
+	channelNames  map[uint64]string
+	mentions      []protocol.SearchResult
+	hasMore       bool
+	loading       bool
+	loadingMore   bool
+	errorMsg      string
+	selectedIndex int
+	onLoad        func(beforeID *uint64) tea.Cmd
+	onSelect      func(mention protocol.SearchResult) tea.Cmd
+}
+
+// NewMentionsModal creates a new mentions modal. The caller is expected to
+// send the request for the first page; the modal starts out loading.
+func NewMentionsModal(
+	channelNames map[uint64]string,
+	onLoad func(beforeID *uint64) tea.Cmd,
+	onSelect func(mention protocol.SearchResult) tea.Cmd,
+) *MentionsModal {
+	return &MentionsModal{
+		channelNames: channelNames,
+		loading:      true,
+		onLoad:       onLoad,
+		onSelect:     onSelect,
+	}
+}
+
+// Type returns the modal type
+func (m *MentionsModal) Type() ModalType {
+	return ModalMentions
+}
+
+// SetMentions stores a page of mentions. Pages that arrive when nothing was
+// requested are ignored.
+func (m *MentionsModal) SetMentions(mentions []protocol.SearchResult, hasMore bool) {
+	if !m.loading {
+		return
+	}
+	if m.loadingMore {
+		m.mentions = append(m.mentions, mentions...)
+	} else {
+		m.mentions = mentions
+		m.selectedIndex = 0
+	}
+	m.hasMore = hasMore
+	m.loading = false
+	m.loadingMore = false
+	m.errorMsg = ""
+}
+
+// AddMention inserts a mention that arrived while the modal is open
+func (m *MentionsModal) AddMention(mention protocol.SearchResult) {
+	if m.loading && !m.loadingMore {
+		return // The first page will include it
+	}
+	m.mentions = append([]protocol.SearchResult{mention}, m.mentions...)
+	if len(m.mentions) > 1 {
+		m.selectedIndex++
+	}
+}
+
+// SetError shows an error returned by the server
+func (m *MentionsModal) SetError(message string) {
+	m.loading = false
+	m.loadingMore = false
+	m.errorMsg = message
+}
+
+// loadMore requests the next (older) page of mentions
+func (m *MentionsModal) loadMore() tea.Cmd {
+	if !m.hasMore || m.loading || len(m.mentions) == 0 || m.onLoad == nil {
+		return nil
+	}
+	beforeID := m.mentions[len(m.mentions)-1].Message.ID
+	m.loading = true
+	m.loadingMore = true
+	return m.onLoad(&beforeID)
+}
+
+// HandleKey processes keyboard input
+func (m *MentionsModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "ctrl+c":
+		return true, nil, nil
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.mentions)-1 {
+			m.selectedIndex++
+		}
+		// Fetch the next page when reaching the end of the list
+		if m.selectedIndex >= len(m.mentions)-5 {
+			return true, m, m.loadMore()
+		}
+		return true, m, nil
+
+	case "enter":
+		if m.onSelect != nil && m.selectedIndex < len(m.mentions) {
+			return true, nil, m.onSelect(m.mentions[m.selectedIndex])
+		}
+		return true, m, nil
+	}
+
+	return true, m, nil
+}
+
+// Render returns the modal content
+func (m *MentionsModal) Render(width, height int) string {
+	modalWidth := min(max(width-10, 50), 90)
+	contentWidth := modalWidth - 6
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		MarginBottom(1)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	selectedStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205"))
+
+	metaStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(modalWidth)
+
+	title := titleStyle.Render("Mentions")
+
+	// Each mention takes two lines; keep the modal within the terminal
+	maxVisible := max((min(height-4, 40)-12)/2, 3)
+
+	var lines []string
+	switch {
+	case m.errorMsg != "":
+		lines = append(lines, errorStyle.Render(m.errorMsg))
+	case m.loading && !m.loadingMore:
+		lines = append(lines, hintStyle.Render("Loading..."))
+	case len(m.mentions) == 0:
+		lines = append(lines, hintStyle.Render("Nobody has mentioned you yet"))
+	default:
+		start := 0
+		if len(m.mentions) > maxVisible {
+			start = max(m.selectedIndex-maxVisible/2, 0)
+			if start+maxVisible > len(m.mentions) {
+				start = len(m.mentions) - maxVisible
+			}
+		}
+		end := min(start+maxVisible, len(m.mentions))
+
+		if start > 0 {
+			lines = append(lines, hintStyle.Render("  ↑ more mentions above"))
+		}
+		for i := start; i < end; i++ {
+			mention := m.mentions[i]
+			prefix := "  "
+			style := lipgloss.NewStyle()
+			if i == m.selectedIndex {
+				prefix = "> "
+				style = selectedStyle
+			}
+
+			snippet := strings.Join(strings.Fields(mention.Message.Content), " ")
+			lines = append(lines, prefix+style.Render(truncateRunes(snippet, contentWidth-2)))
+
+			meta := fmt.Sprintf("%s · %s · %s",
+				m.channelLabel(mention.Message.ChannelID),
+				mention.Message.AuthorNickname,
+				mention.Message.CreatedAt.Local().Format("2006-01-02 15:04"))
+			if mention.ThreadRoot != nil {
+				rootTitle := strings.Join(strings.Fields(mention.ThreadRoot.Content), " ")
+				meta += " · in " + rootTitle
+			}
+			lines = append(lines, "    "+metaStyle.Render(truncateRunes(meta, contentWidth-4)))
+		}
+		if end < len(m.mentions) || m.hasMore {
+			lines = append(lines, hintStyle.Render("  ↓ more mentions below"))
+		}
+	}
+
+	help := hintStyle.Render("[Enter] Open  [↑/↓] Navigate  [Esc] Close")
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		help,
+	)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content))
+}
+
+// channelLabel returns the display name for a mention's channel
+func (m *MentionsModal) channelLabel(channelID uint64) string {
+	if name, ok := m.channelNames[channelID]; ok {
+		return name
+	}
+	return fmt.Sprintf("channel %d", channelID)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *MentionsModal) IsBlockingInput() bool {
+	return true
+}
@@ -0,0 +1,206 @@
+package modal
+
+import (
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MessageHistoryModal shows the revisions of a message, with a line diff
+// between the selected revision and the one before it
+type MessageHistoryModal struct {
+	messageID     uint64
+	revisions     []protocol.MessageRevision
+	loading       bool
+	errorMsg      string
+	selectedIndex int
+}
+
+// NewMessageHistoryModal creates a new history modal. The caller is expected
+// to send the request; the modal starts out loading.
+func NewMessageHistoryModal(messageID uint64) *MessageHistoryModal {
+	return &MessageHistoryModal{
+		messageID: messageID,
+		loading:   true,
+	}
+}
+
+// Type returns the modal type
+func (m *MessageHistoryModal) Type() ModalType {
+	return ModalMessageHistory
+}
+
+// MessageID returns the ID of the message whose history is shown
+func (m *MessageHistoryModal) MessageID() uint64 {
+	return m.messageID
+}
+
+// SetRevisions stores the revisions, oldest first, and selects the newest
+func (m *MessageHistoryModal) SetRevisions(revisions []protocol.MessageRevision) {
+	m.revisions = revisions
+	m.selectedIndex = max(len(revisions)-1, 0)
+	m.loading = false
+	m.errorMsg = ""
+}
+
+// SetError shows an error returned by the server
+func (m *MessageHistoryModal) SetError(message string) {
+	m.loading = false
+	m.errorMsg = message
+}
+
+// HandleKey processes keyboard input
+func (m *MessageHistoryModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "ctrl+c":
+		return true, nil, nil
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.revisions)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+	}
+
+	return true, m, nil
+}
+
+// Render returns the modal content
+func (m *MessageHistoryModal) Render(width, height int) string {
+	modalWidth := min(max(width-10, 50), 90)
+	contentWidth := modalWidth - 6
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		MarginBottom(1)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	selectedStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(modalWidth)
+
+	title := titleStyle.Render("Edit History")
+
+	var lines []string
+	switch {
+	case m.errorMsg != "":
+		lines = append(lines, errorStyle.Render(m.errorMsg))
+	case m.loading:
+		lines = append(lines, hintStyle.Render("Loading..."))
+	case len(m.revisions) == 0:
+		lines = append(lines, hintStyle.Render("No history for this message"))
+	default:
+		for i, rev := range m.revisions {
+			prefix := "  "
+			style := lipgloss.NewStyle()
+			if i == m.selectedIndex {
+				prefix = "> "
+				style = selectedStyle
+			}
+			label := revisionLabel(rev.Kind) + "  " + rev.Timestamp.Local().Format("2006-01-02 15:04:05")
+			lines = append(lines, prefix+style.Render(label))
+		}
+		lines = append(lines, "", strings.Repeat("─", contentWidth))
+
+		// Keep the modal within the terminal
+		maxDiffLines := max(min(height-4, 40)-len(m.revisions)-10, 3)
+		lines = append(lines, m.renderDiff(contentWidth, maxDiffLines)...)
+	}
+
+	help := hintStyle.Render("[↑/↓] Select revision  [Esc] Close")
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		help,
+	)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content))
+}
+
+// renderDiff renders the selected revision as a diff against the previous one
+func (m *MessageHistoryModal) renderDiff(width, maxLines int) []string {
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+	removedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	addedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("46"))
+	keptStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("250"))
+
+	rev := m.revisions[m.selectedIndex]
+	if rev.Hidden {
+		return []string{hintStyle.Render("Content of deleted messages is only visible to admins")}
+	}
+
+	var diff []DiffLine
+	switch {
+	case rev.Kind == protocol.RevisionDeleted:
+		// Show what was deleted
+		diff = DiffLines(rev.Content, "")
+		diff = diff[:len(diff)-1] // Drop the inserted empty line
+	case m.selectedIndex == 0:
+		diff = DiffLines("", rev.Content)
+		diff = diff[1:] // Drop the removed empty line
+	default:
+		diff = DiffLines(m.revisions[m.selectedIndex-1].Content, rev.Content)
+	}
+
+	var lines []string
+	for _, line := range diff {
+		if len(lines) == maxLines {
+			lines = append(lines, hintStyle.Render("…"))
+			break
+		}
+		text := truncateRunes(line.Text, width-2)
+		switch line.Op {
+		case DiffDelete:
+			lines = append(lines, removedStyle.Render("- "+text))
+		case DiffInsert:
+			lines = append(lines, addedStyle.Render("+ "+text))
+		default:
+			lines = append(lines, keptStyle.Render("  "+text))
+		}
+	}
+	return lines
+}
+
+func revisionLabel(kind uint8) string {
+	switch kind {
+	case protocol.RevisionCreated:
+		return "Posted "
+	case protocol.RevisionEdited:
+		return "Edited "
+	case protocol.RevisionDeleted:
+		return "Deleted"
+	default:
+		return "Changed"
+	}
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *MessageHistoryModal) IsBlockingInput() bool {
+	return true
+}
@@ -0,0 +1,312 @@
+package modal
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Mute form fields
+const (
+	muteFieldNickname = iota
+	muteFieldChannel
+	muteFieldReason
+	muteFieldDuration
+	muteFieldUnmute
+	muteFieldCount
+)
+
+// MuteUserModal handles muting a user everywhere or in one channel, and
+// lifting mutes again
+type MuteUserModal struct {
+	activeField  int
+	nickname     string
+	channels     []ChannelInfo
+	channelIndex int // 0 = all channels, otherwise channels[channelIndex-1]
+	reason       string
+	duration     string // Empty for permanent, or number of seconds
+	unmute       bool
+	errorMessage string
+	onMute       func(*protocol.MuteUserMessage) tea.Cmd
+	onUnmute     func(*protocol.UnmuteUserMessage) tea.Cmd
+}
+
+// NewMuteUserModal creates a new mute user modal
+func NewMuteUserModal(channels []ChannelInfo) *MuteUserModal {
+	return &MuteUserModal{
+		channels: channels,
+		reason:   "Spam", // Default reason
+		duration: "3600", // One hour by default
+	}
+}
+
+// SetSubmitHandlers sets the callbacks for muting and unmuting
+func (m *MuteUserModal) SetSubmitHandlers(onMute func(*protocol.MuteUserMessage) tea.Cmd, onUnmute func(*protocol.UnmuteUserMessage) tea.Cmd) {
+	m.onMute = onMute
+	m.onUnmute = onUnmute
+}
+
+// SetNickname pre-fills the nickname field
+func (m *MuteUserModal) SetNickname(nickname string) {
+	m.nickname = nickname
+}
+
+// Type returns the modal type
+func (m *MuteUserModal) Type() ModalType {
+	return ModalMuteUser
+}
+
+// HandleKey processes keyboard input
+func (m *MuteUserModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		// Close modal and return to admin panel
+		return true, nil, nil
+
+	case "tab", "down":
+		m.activeField = m.nextField(1)
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "shift+tab", "up":
+		m.activeField = m.nextField(-1)
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "left", "right":
+		if m.activeField == muteFieldChannel {
+			step := 1
+			if msg.String() == "left" {
+				step = len(m.channels)
+			}
+			m.channelIndex = (m.channelIndex + step) % (len(m.channels) + 1)
+		}
+		return true, m, nil
+
+	case "enter":
+		if m.activeField == muteFieldUnmute {
+			return m.submit()
+		}
+		m.activeField = m.nextField(1)
+		return true, m, nil
+
+	case "ctrl+enter":
+		// Submit from any field
+		return m.submit()
+
+	case " ":
+		if m.activeField == muteFieldUnmute {
+			m.unmute = !m.unmute
+			return true, m, nil
+		}
+		if m.activeField == muteFieldReason && len(m.reason) < 200 {
+			m.reason += " "
+		}
+		return true, m, nil
+
+	case "backspace":
+		switch m.activeField {
+		case muteFieldNickname:
+			if len(m.nickname) > 0 {
+				m.nickname = m.nickname[:len(m.nickname)-1]
+			}
+		case muteFieldReason:
+			if len(m.reason) > 0 {
+				m.reason = m.reason[:len(m.reason)-1]
+			}
+		case muteFieldDuration:
+			if len(m.duration) > 0 {
+				m.duration = m.duration[:len(m.duration)-1]
+			}
+		}
+		m.errorMessage = ""
+		return true, m, nil
+
+	default:
+		// Type into active field
+		if msg.Type != tea.KeyRunes {
+			return true, m, nil
+		}
+		input := string(msg.Runes)
+		switch m.activeField {
+		case muteFieldNickname:
+			if len(m.nickname) < 20 {
+				m.nickname += input
+			}
+		case muteFieldReason:
+			if len(m.reason) < 200 {
+				m.reason += input
+			}
+		case muteFieldDuration:
+			// Only allow digits
+			if _, err := strconv.ParseUint(input, 10, 64); err == nil && len(m.duration) < 10 {
+				m.duration += input
+			}
+		}
+		m.errorMessage = ""
+		return true, m, nil
+	}
+}
+
+// nextField moves through the form, skipping fields that don't apply when
+// lifting a mute
+func (m *MuteUserModal) nextField(step int) int {
+	field := m.activeField
+	for {
+		field = (field + step + muteFieldCount) % muteFieldCount
+		if !m.unmute || (field != muteFieldReason && field != muteFieldDuration) {
+			return field
+		}
+	}
+}
+
+func (m *MuteUserModal) submit() (bool, Modal, tea.Cmd) {
+	nickname := strings.TrimSpace(m.nickname)
+	if nickname == "" {
+		m.errorMessage = "Nickname is required"
+		m.activeField = muteFieldNickname
+		return true, m, nil
+	}
+
+	var channelID *uint64
+	if m.channelIndex > 0 {
+		id := m.channels[m.channelIndex-1].ID
+		channelID = &id
+	}
+
+	var cmd tea.Cmd
+	if m.unmute {
+		if m.onUnmute != nil {
+			cmd = m.onUnmute(&protocol.UnmuteUserMessage{Nickname: &nickname, ChannelID: channelID})
+		}
+		return true, nil, cmd
+	}
+
+	// Parse duration
+	var durationSeconds *uint64
+	if m.duration != "" {
+		seconds, err := strconv.ParseUint(m.duration, 10, 64)
+		if err != nil {
+			m.errorMessage = "Invalid duration (must be seconds)"
+			m.activeField = muteFieldDuration
+			return true, m, nil
+		}
+		durationSeconds = &seconds
+	}
+
+	if m.onMute != nil {
+		cmd = m.onMute(&protocol.MuteUserMessage{
+			Nickname:        &nickname,
+			ChannelID:       channelID,
+			Reason:          strings.TrimSpace(m.reason),
+			DurationSeconds: durationSeconds,
+		})
+	}
+	return true, nil, cmd
+}
+
+// Render returns the modal content
+func (m *MuteUserModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("196")).
+		MarginBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252")).
+		Width(15)
+
+	activeInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	inactiveInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245")).
+		Padding(0, 1)
+
+	disabledInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("196")).
+		Padding(1, 2).
+		Width(70)
+
+	field := func(index int, value string, cursor string) string {
+		switch {
+		case m.activeField == index:
+			return activeInputStyle.Render(value + cursor)
+		case m.unmute && (index == muteFieldReason || index == muteFieldDuration):
+			return disabledInputStyle.Render(value)
+		default:
+			return inactiveInputStyle.Render(value)
+		}
+	}
+
+	channel := "All channels"
+	if m.channelIndex > 0 {
+		channel = "#" + m.channels[m.channelIndex-1].Name
+	}
+	if m.activeField == muteFieldChannel {
+		channel = "◀ " + channel + " ▶"
+	}
+
+	checkbox := "[ ]"
+	if m.unmute {
+		checkbox = "[✓]"
+	}
+
+	title := "Mute User"
+	if m.unmute {
+		title = "Unmute User"
+	}
+
+	form := lipgloss.JoinVertical(
+		lipgloss.Left,
+		labelStyle.Render("Nickname:")+"  "+field(muteFieldNickname, m.nickname, "█"),
+		"",
+		labelStyle.Render("Channel:")+"  "+field(muteFieldChannel, channel, ""),
+		"",
+		labelStyle.Render("Reason:")+"  "+field(muteFieldReason, m.reason, "█"),
+		"",
+		labelStyle.Render("Duration:")+"  "+field(muteFieldDuration, m.duration, "█"),
+		hintStyle.Render("               (seconds, leave empty for permanent)"),
+		"",
+		field(muteFieldUnmute, checkbox+" Lift mute instead", ""),
+	)
+
+	var errorLine string
+	if m.errorMessage != "" {
+		errorLine = "\n" + errorStyle.Render("✗ "+m.errorMessage) + "\n"
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render(title),
+		"",
+		form,
+		errorLine,
+		hintStyle.Render("[Tab] Next field  [←/→] Channel  [Ctrl+Enter] Submit  [Esc] Cancel"),
+	)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content))
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *MuteUserModal) IsBlockingInput() bool {
+	return true
+}
@@ -9,14 +9,17 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-// PasswordAuthModal prompts for password authentication
+// PasswordAuthModal prompts for password authentication, and for the
+// two-factor code when the server asks for one after the password
 type PasswordAuthModal struct {
 	nickname         string
 	passwordInput    []byte
 	errorMessage     string
 	cooldownUntil    time.Time
 	isAuthenticating bool
+	codePrompt       bool // Asking for a two-factor code instead of the password
 	onConfirm        func(password []byte) tea.Cmd
+	onCode           func(code string) tea.Cmd
 	onCancel         func() tea.Cmd
 }
 
@@ -40,6 +43,29 @@ func NewPasswordAuthModal(
 	}
 }
 
+// NewTOTPCodeModal creates a password authentication modal that asks for the
+// code from an authenticator app, or a recovery code
+func NewTOTPCodeModal(
+	nickname string,
+	errorMessage string,
+	onCode func(code string) tea.Cmd,
+	onCancel func() tea.Cmd,
+) *PasswordAuthModal {
+	return &PasswordAuthModal{
+		nickname:      nickname,
+		passwordInput: []byte{},
+		errorMessage:  errorMessage,
+		codePrompt:    true,
+		onCode:        onCode,
+		onCancel:      onCancel,
+	}
+}
+
+// IsCodePrompt returns true if the modal asks for a two-factor code
+func (m *PasswordAuthModal) IsCodePrompt() bool {
+	return m.codePrompt
+}
+
 // Type returns the modal type
 func (m *PasswordAuthModal) Type() ModalType {
 	return ModalPasswordAuth
@@ -54,6 +80,19 @@ func (m *PasswordAuthModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 			return true, m, nil
 		}
 
+		if m.codePrompt {
+			if len(m.passwordInput) == 0 {
+				m.errorMessage = "Code cannot be empty"
+				return true, m, nil
+			}
+			var cmd tea.Cmd
+			if m.onCode != nil {
+				cmd = m.onCode(string(m.passwordInput))
+			}
+			m.isAuthenticating = true
+			return true, m, cmd
+		}
+
 		// Validate password
 		if len(m.passwordInput) == 0 {
 			m.errorMessage = "Password cannot be empty"
@@ -124,11 +163,15 @@ func (m *PasswordAuthModal) Render(width, height int) string {
 		MarginBottom(1).
 		Render(fmt.Sprintf("🔐 Authenticate as '%s'", m.nickname))
 
+	promptText := "This nickname is registered. Enter password:"
+	if m.codePrompt {
+		promptText = "Enter the code from your authenticator app,\nor one of your recovery codes:"
+	}
 	prompt := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("252")).
 		Align(lipgloss.Left).
 		MarginBottom(1).
-		Render("This nickname is registered. Enter password:")
+		Render(promptText)
 
 	// Password input (hidden) - fixed width
 	inputFocusedStyle := lipgloss.NewStyle().
@@ -138,6 +181,9 @@ func (m *PasswordAuthModal) Render(width, height int) string {
 		Width(40)
 
 	passwordDisplay := strings.Repeat("•", len(m.passwordInput))
+	if m.codePrompt {
+		passwordDisplay = string(m.passwordInput)
+	}
 	if !m.isAuthenticating {
 		passwordDisplay += "█" // Cursor
 	}
@@ -170,6 +216,12 @@ func (m *PasswordAuthModal) Render(width, height int) string {
 			Align(lipgloss.Left).
 			MarginTop(1).
 			Render("Authenticating...")
+	} else if m.codePrompt {
+		statusMsg = lipgloss.NewStyle().
+			Foreground(mutedColor).
+			Align(lipgloss.Left).
+			MarginTop(1).
+			Render("[Enter] Verify  [ESC] Browse anonymously")
 	} else {
 		statusMsg = lipgloss.NewStyle().
 			Foreground(mutedColor).
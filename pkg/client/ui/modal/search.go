@@ -0,0 +1,327 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SearchScope limits a search to a channel, subchannel or thread.
+// A scope with all IDs nil searches every accessible channel.
+type SearchScope struct {
+	Label        string
+	ChannelID    *uint64
+	SubchannelID *uint64
+	ThreadID     *uint64
+}
+
+// SearchModal searches message content and lists the hits
+type SearchModal struct {
+	query         string
+	scopes        []SearchScope
+	scopeIndex    int
+	channelNames  map[uint64]string
+	results       []protocol.SearchResult
+	hasMore       bool
+	searching     bool
+	loadingMore   bool
+	searchedQuery string // Query the current results belong to
+	errorMsg      string
+	selectedIndex int
+	onSearch      func(query string, scope SearchScope, beforeID *uint64) (tea.Cmd, error)
+	onSelect      func(result protocol.SearchResult) tea.Cmd
+}
+
+// NewSearchModal creates a new search modal. The first scope is selected
+// initially. onSearch returns an error for queries that can't be sent.
+func NewSearchModal(
+	scopes []SearchScope,
+	channelNames map[uint64]string,
+	onSearch func(query string, scope SearchScope, beforeID *uint64) (tea.Cmd, error),
+	onSelect func(result protocol.SearchResult) tea.Cmd,
+) *SearchModal {
+	if len(scopes) == 0 {
+		scopes = []SearchScope{{Label: "All channels"}}
+	}
+	return &SearchModal{
+		scopes:       scopes,
+		channelNames: channelNames,
+		onSearch:     onSearch,
+		onSelect:     onSelect,
+	}
+}
+
+// Type returns the modal type
+func (m *SearchModal) Type() ModalType {
+	return ModalSearch
+}
+
+// SetResults stores a page of search results. Results that arrive when no
+// search is pending are ignored.
+func (m *SearchModal) SetResults(results []protocol.SearchResult, hasMore bool) {
+	if !m.searching {
+		return
+	}
+	if m.loadingMore {
+		m.results = append(m.results, results...)
+	} else {
+		m.results = results
+		m.selectedIndex = 0
+	}
+	m.hasMore = hasMore
+	m.searching = false
+	m.loadingMore = false
+	m.errorMsg = ""
+}
+
+// SetError shows an error returned by the server for the current search
+func (m *SearchModal) SetError(message string) {
+	m.searching = false
+	m.loadingMore = false
+	m.errorMsg = message
+}
+
+// submit starts a new search for the current query
+func (m *SearchModal) submit() tea.Cmd {
+	query := strings.TrimSpace(m.query)
+	if query == "" || m.onSearch == nil {
+		return nil
+	}
+	m.searchedQuery = query
+	m.results = nil
+	m.hasMore = false
+	m.loadingMore = false
+	cmd, err := m.onSearch(query, m.scopes[m.scopeIndex], nil)
+	if err != nil {
+		m.SetError(err.Error())
+		return nil
+	}
+	m.searching = true
+	m.errorMsg = ""
+	return cmd
+}
+
+// loadMore requests the next (older) page of results
+func (m *SearchModal) loadMore() tea.Cmd {
+	if !m.hasMore || m.searching || len(m.results) == 0 || m.onSearch == nil {
+		return nil
+	}
+	beforeID := m.results[len(m.results)-1].Message.ID
+	cmd, err := m.onSearch(m.searchedQuery, m.scopes[m.scopeIndex], &beforeID)
+	if err != nil {
+		m.SetError(err.Error())
+		return nil
+	}
+	m.searching = true
+	m.loadingMore = true
+	return cmd
+}
+
+// HandleKey processes keyboard input
+func (m *SearchModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		return true, nil, nil
+
+	case "tab":
+		m.scopeIndex = (m.scopeIndex + 1) % len(m.scopes)
+		if m.searchedQuery != "" {
+			return true, m, m.submit()
+		}
+		return true, m, nil
+
+	case "up", "ctrl+p":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "ctrl+n":
+		if m.selectedIndex < len(m.results)-1 {
+			m.selectedIndex++
+		}
+		// Fetch the next page when reaching the end of the list
+		if m.selectedIndex >= len(m.results)-5 {
+			return true, m, m.loadMore()
+		}
+		return true, m, nil
+
+	case "enter":
+		// A changed query starts a new search; otherwise open the selected hit
+		if strings.TrimSpace(m.query) != m.searchedQuery || len(m.results) == 0 {
+			return true, m, m.submit()
+		}
+		if m.onSelect != nil && m.selectedIndex < len(m.results) {
+			return true, nil, m.onSelect(m.results[m.selectedIndex])
+		}
+		return true, m, nil
+
+	case "backspace":
+		if len(m.query) > 0 {
+			runes := []rune(m.query)
+			m.query = string(runes[:len(runes)-1])
+		}
+		return true, m, nil
+
+	case "ctrl+u":
+		m.query = ""
+		return true, m, nil
+
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.query += string(msg.Runes)
+		}
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *SearchModal) Render(width, height int) string {
+	modalWidth := min(max(width-10, 50), 90)
+	contentWidth := modalWidth - 6
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		MarginBottom(1)
+
+	searchStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170")).
+		Padding(0, 1).
+		Width(contentWidth - 2)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	scopeStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("39"))
+
+	selectedStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205"))
+
+	metaStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(modalWidth)
+
+	title := titleStyle.Render("Search Messages")
+
+	var searchDisplay string
+	if m.query == "" {
+		searchDisplay = "█" + hintStyle.Render(" Words to find, plus from:nick in:#channel after:2024-01-31 before:...")
+	} else {
+		searchDisplay = m.query + "█"
+	}
+	searchField := searchStyle.Render(searchDisplay)
+
+	scope := "Scope: " + scopeStyle.Render(m.scopes[m.scopeIndex].Label)
+	if len(m.scopes) > 1 {
+		scope += hintStyle.Render("  [Tab] change")
+	}
+
+	// Each result takes two lines; keep the modal within the terminal
+	maxVisible := max((min(height-4, 40)-16)/2, 3)
+
+	var lines []string
+	switch {
+	case m.errorMsg != "":
+		lines = append(lines, errorStyle.Render(m.errorMsg))
+	case m.searching && !m.loadingMore:
+		lines = append(lines, hintStyle.Render("Searching..."))
+	case m.searchedQuery == "":
+		lines = append(lines, hintStyle.Render("Type a query and press Enter"))
+	case len(m.results) == 0:
+		lines = append(lines, hintStyle.Render(fmt.Sprintf("No messages match %q", m.searchedQuery)))
+	default:
+		start := 0
+		if len(m.results) > maxVisible {
+			start = max(m.selectedIndex-maxVisible/2, 0)
+			if start+maxVisible > len(m.results) {
+				start = len(m.results) - maxVisible
+			}
+		}
+		end := min(start+maxVisible, len(m.results))
+
+		if start > 0 {
+			lines = append(lines, hintStyle.Render("  ↑ more results above"))
+		}
+		for i := start; i < end; i++ {
+			result := m.results[i]
+			prefix := "  "
+			style := lipgloss.NewStyle()
+			if i == m.selectedIndex {
+				prefix = "> "
+				style = selectedStyle
+			}
+
+			snippet := strings.Join(strings.Fields(result.Message.Content), " ")
+			lines = append(lines, prefix+style.Render(truncateRunes(snippet, contentWidth-2)))
+
+			meta := fmt.Sprintf("%s · %s · %s",
+				m.channelLabel(result.Message.ChannelID),
+				result.Message.AuthorNickname,
+				result.Message.CreatedAt.Local().Format("2006-01-02 15:04"))
+			if result.ThreadRoot != nil {
+				rootTitle := strings.Join(strings.Fields(result.ThreadRoot.Content), " ")
+				meta += " · in " + rootTitle
+			}
+			lines = append(lines, "    "+metaStyle.Render(truncateRunes(meta, contentWidth-4)))
+		}
+		if end < len(m.results) || m.hasMore {
+			lines = append(lines, hintStyle.Render("  ↓ more results below"))
+		}
+	}
+
+	help := hintStyle.Render("[Enter] Search / Open  [↑/↓] Navigate  [Ctrl+U] Clear  [Esc] Close")
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		searchField,
+		scope,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		help,
+	)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content))
+}
+
+// channelLabel returns the display name for a result's channel
+func (m *SearchModal) channelLabel(channelID uint64) string {
+	if name, ok := m.channelNames[channelID]; ok {
+		return name
+	}
+	return fmt.Sprintf("channel %d", channelID)
+}
+
+// truncateRunes shortens s to at most width runes, adding an ellipsis when cut
+func truncateRunes(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *SearchModal) IsBlockingInput() bool {
+	return true
+}
@@ -0,0 +1,203 @@
+package modal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SessionsModal lists the user's logins and lets them log out the ones they
+// no longer use
+type SessionsModal struct {
+	sessions      []protocol.LoginSessionInfo
+	selectedIndex int
+	loading       bool
+	confirmRevoke bool
+	onRefresh     func() tea.Cmd
+	onRevoke      func(sessionID uint64) tea.Cmd
+}
+
+// NewSessionsModal creates a new sessions modal
+func NewSessionsModal(onRefresh func() tea.Cmd, onRevoke func(sessionID uint64) tea.Cmd) *SessionsModal {
+	return &SessionsModal{
+		loading:   true, // Start in loading state
+		onRefresh: onRefresh,
+		onRevoke:  onRevoke,
+	}
+}
+
+// SetSessions sets the session list
+func (m *SessionsModal) SetSessions(sessions []protocol.LoginSessionInfo) {
+	m.sessions = sessions
+	m.loading = false
+	m.confirmRevoke = false
+	if m.selectedIndex >= len(sessions) {
+		m.selectedIndex = max(len(sessions)-1, 0)
+	}
+}
+
+// RemoveSession removes a revoked session from the list
+func (m *SessionsModal) RemoveSession(sessionID uint64) {
+	kept := make([]protocol.LoginSessionInfo, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		if session.SessionID != sessionID {
+			kept = append(kept, session)
+		}
+	}
+	m.SetSessions(kept)
+}
+
+// Type returns the modal type
+func (m *SessionsModal) Type() ModalType {
+	return ModalSessions
+}
+
+// HandleKey processes keyboard input
+func (m *SessionsModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	if m.confirmRevoke {
+		switch msg.String() {
+		case "y", "Y":
+			m.confirmRevoke = false
+			if m.onRevoke != nil && m.selectedIndex < len(m.sessions) {
+				return true, m, m.onRevoke(m.sessions[m.selectedIndex].SessionID)
+			}
+		case "n", "N", "esc":
+			m.confirmRevoke = false
+		}
+		return true, m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		return true, nil, nil
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.sessions)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case "d":
+		// The current login ends by logging out, not from here
+		if m.selectedIndex < len(m.sessions) && !m.sessions[m.selectedIndex].Current {
+			m.confirmRevoke = true
+		}
+		return true, m, nil
+
+	case "r":
+		m.loading = true
+		if m.onRefresh != nil {
+			return true, m, m.onRefresh()
+		}
+		return true, m, nil
+
+	default:
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *SessionsModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205")).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("205")).
+		Bold(true).
+		Padding(0, 1)
+
+	unselectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252")).
+		Padding(0, 1)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	warningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("208")).
+		Bold(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(80).
+		Height(min(height-4, 30))
+
+	title := titleStyle.Render("Sessions")
+
+	var lines []string
+	if m.loading {
+		lines = append(lines, hintStyle.Render("Loading..."))
+	} else if len(m.sessions) == 0 {
+		lines = append(lines, hintStyle.Render("No saved logins"))
+	} else {
+		for i, session := range m.sessions {
+			line := fmt.Sprintf("%s | Logged in: %s | Last used: %s",
+				formatSessionLabel(session),
+				time.UnixMilli(session.CreatedAt).Format("2006-01-02 15:04"),
+				time.UnixMilli(session.LastUsedAt).Format("2006-01-02 15:04"))
+			if i == m.selectedIndex {
+				lines = append(lines, selectedStyle.Render(line))
+			} else {
+				lines = append(lines, unselectedStyle.Render(line))
+			}
+		}
+	}
+
+	var footer string
+	if m.confirmRevoke && m.selectedIndex < len(m.sessions) {
+		footer = warningStyle.Render(fmt.Sprintf("Log out '%s'? It will have to log in with the password again. [y/n]",
+			formatSessionLabel(m.sessions[m.selectedIndex])))
+	} else {
+		footer = hintStyle.Render("[d] Log out  [r] Refresh  [↑/↓] Navigate  [Esc/q] Close")
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		footer,
+	)
+
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(content),
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *SessionsModal) IsBlockingInput() bool {
+	return true
+}
+
+func formatSessionLabel(session protocol.LoginSessionInfo) string {
+	label := session.Label
+	if label == "" {
+		label = fmt.Sprintf("Session %d", session.SessionID)
+	}
+	if session.Current {
+		label += " (this session)"
+	} else if session.Online {
+		label += " (online)"
+	}
+	return label
+}
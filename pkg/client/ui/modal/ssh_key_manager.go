@@ -117,6 +117,12 @@ func NewSSHKeyManagerModal(
 	}
 }
 
+// ClearPublicKeyFiles hides the .pub files found in ~/.ssh, for when that
+// directory doesn't belong to the user
+func (m *SSHKeyManagerModal) ClearPublicKeyFiles() {
+	m.pubKeyFiles = nil
+}
+
 func (m *SSHKeyManagerModal) Type() ModalType {
 	return ModalTypeSSHKeyManager
 }
@@ -0,0 +1,184 @@
+package modal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TwoFactorModal enrolls an authenticator app: it shows the secret from the
+// server, asks for a code to confirm it, then shows the recovery codes. When
+// disabling two-factor authentication it only asks for a code.
+type TwoFactorModal struct {
+	disable       bool
+	secret        string
+	uri           string
+	recoveryCodes []string
+	code          string
+	errorMessage  string
+	submitted     bool
+	onConfirm     func(code string) tea.Cmd
+	onCancel      func() tea.Cmd
+}
+
+// NewTwoFactorSetupModal creates a modal that enrolls an authenticator. It
+// waits for SetSecret before asking for a code.
+func NewTwoFactorSetupModal(onConfirm func(code string) tea.Cmd, onCancel func() tea.Cmd) *TwoFactorModal {
+	return &TwoFactorModal{
+		onConfirm: onConfirm,
+		onCancel:  onCancel,
+	}
+}
+
+// NewTwoFactorDisableModal creates a modal that asks for a code to disable
+// two-factor authentication
+func NewTwoFactorDisableModal(onConfirm func(code string) tea.Cmd) *TwoFactorModal {
+	return &TwoFactorModal{
+		disable:   true,
+		onConfirm: onConfirm,
+	}
+}
+
+// SetSecret shows the secret to add to the authenticator app
+func (m *TwoFactorModal) SetSecret(secret, uri string) {
+	m.secret = secret
+	m.uri = uri
+	m.errorMessage = ""
+}
+
+// SetRecoveryCodes shows the recovery codes once enrollment is done
+func (m *TwoFactorModal) SetRecoveryCodes(codes []string) {
+	m.recoveryCodes = codes
+	m.errorMessage = ""
+}
+
+// SetError shows an error and lets the code be entered again
+func (m *TwoFactorModal) SetError(message string) {
+	m.errorMessage = message
+	m.submitted = false
+	m.code = ""
+}
+
+// Type returns the modal type
+func (m *TwoFactorModal) Type() ModalType {
+	return ModalTwoFactor
+}
+
+// HandleKey processes keyboard input
+func (m *TwoFactorModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	// The recovery codes only need to be read
+	if m.recoveryCodes != nil {
+		switch msg.String() {
+		case "enter", "esc":
+			return true, nil, nil
+		}
+		return true, m, nil
+	}
+
+	switch msg.String() {
+	case "enter":
+		if m.submitted || (!m.disable && m.secret == "") {
+			return true, m, nil
+		}
+		if strings.TrimSpace(m.code) == "" {
+			m.errorMessage = "Code cannot be empty"
+			return true, m, nil
+		}
+		m.submitted = true
+		m.errorMessage = ""
+		if m.onConfirm != nil {
+			return true, m, m.onConfirm(strings.TrimSpace(m.code))
+		}
+		return true, m, nil
+
+	case "esc":
+		var cmd tea.Cmd
+		if m.onCancel != nil {
+			cmd = m.onCancel()
+		}
+		return true, nil, cmd
+
+	case "backspace":
+		if len(m.code) > 0 && !m.submitted {
+			m.code = m.code[:len(m.code)-1]
+		}
+		return true, m, nil
+
+	default:
+		if msg.Type == tea.KeyRunes && !m.submitted && len(m.code) < 32 {
+			m.code += string(msg.Runes)
+		}
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *TwoFactorModal) Render(width, height int) string {
+	inputFocusedStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("170")).
+		Padding(0, 1).
+		Width(20)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	mutedTextStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240"))
+
+	boldStyle := lipgloss.NewStyle().Bold(true)
+
+	var content string
+	switch {
+	case m.recoveryCodes != nil:
+		content = boldStyle.Render("Two-Factor Authentication Enabled") + "\n\n" +
+			mutedTextStyle.Render("Store these recovery codes somewhere safe. Each one can be used\nonce to log in if you lose your authenticator app. They won't be\nshown again.") + "\n\n" +
+			strings.Join(m.recoveryCodes, "\n") + "\n\n" +
+			mutedTextStyle.Render("[Enter] Done")
+
+	case m.disable:
+		content = boldStyle.Render("Disable Two-Factor Authentication") + "\n\n" +
+			mutedTextStyle.Render("Enter the code from your authenticator app, or a recovery code.") + "\n\n" +
+			inputFocusedStyle.Render(m.code) + "\n\n"
+
+	case m.secret == "":
+		content = boldStyle.Render("Set Up Two-Factor Authentication") + "\n\n" +
+			mutedTextStyle.Render("Loading...") + "\n\n"
+
+	default:
+		content = boldStyle.Render("Set Up Two-Factor Authentication") + "\n\n" +
+			mutedTextStyle.Render("Add this key to your authenticator app, then enter the code it shows.") + "\n\n" +
+			"Key: " + boldStyle.Render(m.secret) + "\n\n" +
+			mutedTextStyle.Render(m.uri) + "\n\n" +
+			"Code:\n" + inputFocusedStyle.Render(m.code) + "\n\n"
+	}
+
+	if m.recoveryCodes == nil {
+		if m.errorMessage != "" {
+			content += errorStyle.Render(m.errorMessage) + "\n\n"
+		}
+		if m.submitted {
+			content += mutedTextStyle.Render("Verifying...")
+		} else if m.disable {
+			content += mutedTextStyle.Render("[Enter] Disable • [Esc] Cancel")
+		} else {
+			content += mutedTextStyle.Render("[Enter] Verify • [Esc] Cancel")
+		}
+	}
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(80)
+
+	box := modalStyle.Render(content)
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, box)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *TwoFactorModal) IsBlockingInput() bool {
+	return true
+}
@@ -39,6 +39,19 @@ const (
 	ModalEncryptionSetup
 	ModalStartDM
 	ModalError
+	ModalSearch
+	ModalMentions
+	ModalIncomingWebhooks
+	ModalCreateIncomingWebhook
+	ModalMessageHistory
+	ModalAuditLog
+	ModalKickUser
+	ModalMuteUser
+	ModalVerifyKey
+	ModalKeyBackup
+	ModalDevices
+	ModalSessions
+	ModalTwoFactor
 )
 
 // String returns the string representation of the modal type
@@ -106,6 +119,32 @@ func (m ModalType) String() string {
 		return "StartDM"
 	case ModalError:
 		return "Error"
+	case ModalSearch:
+		return "Search"
+	case ModalMentions:
+		return "Mentions"
+	case ModalIncomingWebhooks:
+		return "IncomingWebhooks"
+	case ModalCreateIncomingWebhook:
+		return "CreateIncomingWebhook"
+	case ModalMessageHistory:
+		return "MessageHistory"
+	case ModalAuditLog:
+		return "AuditLog"
+	case ModalKickUser:
+		return "KickUser"
+	case ModalMuteUser:
+		return "MuteUser"
+	case ModalVerifyKey:
+		return "VerifyKey"
+	case ModalKeyBackup:
+		return "KeyBackup"
+	case ModalDevices:
+		return "Devices"
+	case ModalSessions:
+		return "Sessions"
+	case ModalTwoFactor:
+		return "TwoFactor"
 	default:
 		return "Unknown"
 	}
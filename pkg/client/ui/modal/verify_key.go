@@ -0,0 +1,108 @@
+package modal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// VerifyKeyModal shows the safety number of a DM and lets the user mark the
+// other party's key as verified after comparing it with them
+type VerifyKeyModal struct {
+	nickname     string
+	safetyNumber string
+	verified     bool
+	onConfirm    func(verified bool) tea.Cmd
+}
+
+// NewVerifyKeyModal creates a new key verification modal
+func NewVerifyKeyModal(nickname, safetyNumber string, verified bool, onConfirm func(verified bool) tea.Cmd) *VerifyKeyModal {
+	return &VerifyKeyModal{
+		nickname:     nickname,
+		safetyNumber: safetyNumber,
+		verified:     verified,
+		onConfirm:    onConfirm,
+	}
+}
+
+// Type returns the modal type
+func (m *VerifyKeyModal) Type() ModalType {
+	return ModalVerifyKey
+}
+
+// HandleKey processes keyboard input
+func (m *VerifyKeyModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "v":
+		// Toggle the verified state
+		var cmd tea.Cmd
+		if m.onConfirm != nil {
+			cmd = m.onConfirm(!m.verified)
+		}
+		return true, nil, cmd
+
+	case "esc", "enter", "q":
+		return true, nil, nil
+
+	default:
+		// Consume all other keys (don't let them fall through)
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *VerifyKeyModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("205"))
+
+	numberStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("252"))
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		Italic(true)
+
+	verifiedStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("42"))
+
+	// Four groups per line, like the other party sees it
+	groups := strings.Fields(m.safetyNumber)
+	var lines []string
+	for i := 0; i < len(groups); i += 4 {
+		end := i + 4
+		if end > len(groups) {
+			end = len(groups)
+		}
+		lines = append(lines, strings.Join(groups[i:end], "  "))
+	}
+
+	var content strings.Builder
+	content.WriteString(titleStyle.Render("Safety number with "+m.nickname) + "\n\n")
+	content.WriteString(numberStyle.Render(strings.Join(lines, "\n")) + "\n\n")
+	content.WriteString("Compare this number with " + m.nickname + " in person or over\n")
+	content.WriteString("another trusted channel. If it matches, nobody has\n")
+	content.WriteString("swapped your encryption keys.\n\n")
+
+	if m.verified {
+		content.WriteString(verifiedStyle.Render("✓ Verified") + "\n\n")
+		content.WriteString(hintStyle.Render("[v] Mark as not verified    [esc] Close"))
+	} else {
+		content.WriteString(hintStyle.Render("[v] Mark as verified    [esc] Close"))
+	}
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(content.String()))
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *VerifyKeyModal) IsBlockingInput() bool {
+	return true
+}
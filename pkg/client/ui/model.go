@@ -2,8 +2,10 @@ package ui
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -71,6 +73,8 @@ type DMChannel struct {
 	OtherNickname   string
 	IsEncrypted     bool
 	OtherPubKey     []byte // Other party's X25519 public key (for encrypted DMs)
+	SafetyNumber    string // Computed from both public keys (for encrypted DMs)
+	KeyVerified     bool   // True if the user verified OtherPubKey's safety number
 	UnreadCount     uint32
 	ParticipantLeft bool // True if the other participant has permanently left
 }
@@ -116,6 +120,7 @@ type Model struct {
 	// Server state
 	serverConfig       *protocol.ServerConfigMessage
 	channels           []protocol.Channel
+	privateChannels    []protocol.Channel // V4: Private channels we're a member of (also in channels)
 	currentChannel     *protocol.Channel
 	expandedChannelID  *uint64                   // Which channel is expanded in sidebar (nil = none)
 	subchannels        []protocol.SubchannelInfo // Subchannels for the expanded channel
@@ -157,6 +162,7 @@ type Model struct {
 	newMessageIDs      map[uint64]bool // Track new messages in current thread
 	confirmingDelete   bool
 	pendingDeleteID    uint64
+	searchJumpID       uint64 // Search hit to select once thread replies load (0 = none)
 
 	// Chat channel state
 	chatMessages  []protocol.Message // Linear list of all messages in chat channel
@@ -181,6 +187,7 @@ type Model struct {
 	authAttempts      int       // For rate limiting
 	authCooldownUntil time.Time // For rate limiting
 	authErrorMessage  string    // For displaying errors in password modal
+	resumingSession   bool      // V4: RESUME_SESSION sent, waiting for AUTH_RESPONSE
 
 	// First post warning (session-level, resets on restart)
 	firstPostWarningAskedThisSession bool // True if warning was shown this session
@@ -217,6 +224,9 @@ type Model struct {
 	// Notifications
 	lastInteractionTime  time.Time
 	notificationIconPath string
+	terminalOut          io.Writer // Receives bell and OSC notification sequences
+	remote               bool      // Running on the server for a user connected over SSH
+	unreadMentions       int       // Mentions received since the mentions view was last opened
 
 	// Command system
 	commands *commands.Registry
@@ -230,8 +240,14 @@ type Model struct {
 	pendingDMInvites   []DMInvite           // Incoming DM requests awaiting response
 	outgoingDMInvites  []OutgoingDMInvite   // Outgoing DM requests we're waiting on
 	dmChannelKeys      map[uint64][]byte    // channelID -> derived AES key for encryption
+	dmRestoredKeys     map[uint64][][]byte  // channelID -> AES keys derived from restored keys, for decryption only
+	channelKeyrings    map[uint64]*crypto.GroupKeyring // V4: private channel ID -> group keys by epoch
+	dmRatchets         map[uint64]*dmRatchet // V4: ratcheted DM channel ID -> Double Ratchet state
+	deviceDMs          map[uint64]*deviceDM  // V4: multi-device DM channel ID -> ratchets per device
+	userDevices        map[string][]protocol.DeviceInfo // V4: nickname -> devices from USER_INFO
 	encryptionKeyPub  []byte               // Our X25519 public key (nil if not set up)
 	encryptionKeyPriv []byte               // Our X25519 private key (nil if not set up)
+	restoredKeys      [][]byte             // X25519 private keys restored from a backup, for decryption only
 	dmCursor          int                  // Cursor position in DM list
 	showDMList        bool                 // True when viewing DM list instead of channels
 }
@@ -310,6 +326,12 @@ func NewModel(conn client.ConnectionInterface, state client.StateInterface, curr
 		serverRoster:           make(map[uint64]presenceEntry),
 		unreadCounts:           make(map[uint64]uint32),
 		dmChannelKeys:          make(map[uint64][]byte),
+		dmRestoredKeys:         make(map[uint64][][]byte),
+		channelKeyrings:        make(map[uint64]*crypto.GroupKeyring),
+		dmRatchets:             make(map[uint64]*dmRatchet),
+		deviceDMs:              make(map[uint64]*deviceDM),
+		userDevices:            make(map[string][]protocol.DeviceInfo),
+		terminalOut:            os.Stdout,
 	}
 
 	// Initialize notification icon (write to data directory if needed)
@@ -346,6 +368,17 @@ func NewModel(conn client.ConnectionInterface, state client.StateInterface, curr
 	return m
 }
 
+// SetRemoteTerminal marks the model as running on the server on behalf of a
+// user connected over SSH, whose terminal is out. Notifications are sent to
+// that terminal and features that would act on the local machine are disabled.
+// That includes encrypted DMs: their private key would be generated and kept
+// on the server, so DMs started here are unencrypted.
+func (m *Model) SetRemoteTerminal(out io.Writer) {
+	m.remote = true
+	m.terminalOut = out
+	m.keyStore = nil
+}
+
 // registerCommands sets up all keyboard commands
 func (m *Model) registerCommands() {
 	// === Global Commands ===
@@ -486,6 +519,50 @@ func (m *Model) registerCommands() {
 		Priority(20).
 		Build())
 
+	// +1 message
+	m.commands.Register(commands.NewCommand().
+		Keys("+").
+		Name("+1").
+		Help("+1 the selected message").
+		InViews(int(ViewThreadView)).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			if model.nickname == "" || model.conn == nil {
+				return false
+			}
+			msg, ok := model.selectedMessage()
+			return ok && !isDeletedMessageContent(msg.Content)
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			msg, _ := model.selectedMessage()
+			return model, model.sendPlusOne(msg.ID)
+		}).
+		Priority(25).
+		Build())
+
+	// Message history
+	m.commands.Register(commands.NewCommand().
+		Keys("H").
+		Name("History").
+		Help("Show the edit history of the selected message").
+		InViews(int(ViewThreadView)).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			if model.conn == nil {
+				return false
+			}
+			msg, ok := model.selectedMessage()
+			return ok && (msg.EditedAt != nil || isDeletedMessageContent(msg.Content))
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			msg, _ := model.selectedMessage()
+			return model, model.showMessageHistoryModal(msg.ID)
+		}).
+		Priority(26).
+		Build())
+
 	// Edit message
 	m.commands.Register(commands.NewCommand().
 		Keys("e").
@@ -630,6 +707,43 @@ func (m *Model) registerCommands() {
 		Priority(10).
 		Build())
 
+	// Ctrl+G to view mentions
+	m.commands.Register(commands.NewCommand().
+		Keys("ctrl+g").
+		Name("Mentions").
+		Help("Show messages that mention you").
+		Global().
+		InModals(modal.ModalNone). // Only available when no modal is open
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.conn != nil && model.connectionState == StateConnected && model.userID != nil
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			return model, model.showMentionsModal()
+		}).
+		Priority(20).
+		Build())
+
+	// Ctrl+F to search messages
+	m.commands.Register(commands.NewCommand().
+		Keys("ctrl+f").
+		Name("Search").
+		Help("Search messages").
+		Global().
+		InModals(modal.ModalNone). // Only available when no modal is open
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.conn != nil && model.connectionState == StateConnected
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showSearchModal()
+			return model, nil
+		}).
+		Priority(20).
+		Build())
+
 	// Back to thread list
 	m.commands.Register(commands.NewCommand().
 		Keys("esc").
@@ -850,6 +964,26 @@ func (m *Model) registerCommands() {
 		Priority(800).
 		Build())
 
+	// Show the safety number of an encrypted DM
+	m.commands.Register(commands.NewCommand().
+		Keys("ctrl+y").
+		Name("Verify Key").
+		Help("Compare safety numbers and mark the other party's key as verified").
+		InViews(int(ViewChatChannel)).
+		InModals(modal.ModalNone). // Only available when no modal is open
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			dm := model.currentDM()
+			return dm != nil && dm.SafetyNumber != ""
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showVerifyKeyModal()
+			return model, nil
+		}).
+		Priority(60).
+		Build())
+
 	// === ChannelList Commands ===
 
 	// Navigate up in channel list
@@ -1024,6 +1158,116 @@ func (m *Model) registerCommands() {
 		Priority(10).
 		Build())
 
+	// Back up DM encryption keys to the server (command palette only)
+	m.commands.Register(commands.NewCommand().
+		Name("Backup Keys").
+		Aliases("backup-keys").
+		Help("Store a passphrase-encrypted backup of your encryption keys on the server").
+		Global().
+		InModals(modal.ModalNone).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.authState == AuthStateAuthenticated && model.keyStore != nil
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showBackupKeysModal()
+			return model, nil
+		}).
+		Priority(10).
+		Build())
+
+	// Restore DM encryption keys from the server's backup (command palette only)
+	m.commands.Register(commands.NewCommand().
+		Name("Restore Keys").
+		Aliases("restore-keys").
+		Help("Restore your encryption keys from the backup stored on the server").
+		Global().
+		InModals(modal.ModalNone).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.authState == AuthStateAuthenticated && model.keyStore != nil
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			return model, model.sendGetKeyBackup()
+		}).
+		Priority(10).
+		Build())
+
+	// List and revoke the devices that can read encrypted DMs (command palette only)
+	m.commands.Register(commands.NewCommand().
+		Name("Devices").
+		Aliases("devices").
+		Help("List the devices that can read your encrypted DMs and revoke old ones").
+		Global().
+		InModals(modal.ModalNone).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.authState == AuthStateAuthenticated
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			return model, model.showDevicesModal()
+		}).
+		Priority(10).
+		Build())
+
+	// List the logins with a resume token and log out other ones (command palette only)
+	m.commands.Register(commands.NewCommand().
+		Name("Sessions").
+		Aliases("sessions").
+		Help("List where you're logged in and log out the sessions you don't use").
+		Global().
+		InModals(modal.ModalNone).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.authState == AuthStateAuthenticated
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			return model, model.showSessionsModal()
+		}).
+		Priority(10).
+		Build())
+
+	// Set up an authenticator app for password logins (command palette only)
+	m.commands.Register(commands.NewCommand().
+		Name("Enable two-factor").
+		Aliases("2fa").
+		Help("Require a code from an authenticator app when logging in with your password").
+		Global().
+		InModals(modal.ModalNone).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.authState == AuthStateAuthenticated
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			return model, model.showTwoFactorSetupModal(false)
+		}).
+		Priority(10).
+		Build())
+
+	// Remove the authenticator app (command palette only)
+	m.commands.Register(commands.NewCommand().
+		Name("Disable two-factor").
+		Aliases("no2fa").
+		Help("Stop asking for an authenticator code when logging in").
+		Global().
+		InModals(modal.ModalNone).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.authState == AuthStateAuthenticated
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showTwoFactorDisableModal()
+			return model, nil
+		}).
+		Priority(10).
+		Build())
+
 	// Toggle user sidebar with U key
 	m.commands.Register(commands.NewCommand().
 		Keys("u").
@@ -1315,6 +1559,10 @@ func (m *Model) showSSHKeyManagerModal(keys []modal.SSHKeyInfo) {
 			return nil
 		},
 	)
+	if m.remote {
+		// ~/.ssh belongs to the server, not the user
+		sshKeyManagerModal.ClearPublicKeyFiles()
+	}
 	m.modalStack.Push(sshKeyManagerModal)
 }
 
@@ -1465,11 +1713,15 @@ func (m *Model) createConfiguredAdminPanel() modal.Modal {
 	adminPanel.SetMenuActions(
 		func() (modal.Modal, tea.Cmd) { return m.createBanUserModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createBanIPModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createKickUserModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createMuteUserModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createListUsersModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createUnbanModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createViewBansModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createDeleteUserModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createDeleteChannelModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createIncomingWebhooksModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createAuditLogModal() },
 	)
 
 	return adminPanel
@@ -1587,6 +1839,50 @@ func (m *Model) createDeleteChannelModal() (modal.Modal, tea.Cmd) {
 	return deleteChannelModal, nil
 }
 
+// createIncomingWebhooksModal creates an incoming webhooks modal with handlers
+func (m *Model) createIncomingWebhooksModal() (modal.Modal, tea.Cmd) {
+	webhooksModal := modal.NewIncomingWebhooksModal()
+	webhooksModal.SetHandlers(
+		m.sendListIncomingWebhooks,
+		m.createCreateIncomingWebhookModal,
+		func(webhookID uint64) tea.Cmd {
+			m.statusMessage = "Revoking webhook..."
+			return m.sendRevokeIncomingWebhook(&protocol.RevokeIncomingWebhookMessage{WebhookID: webhookID})
+		},
+	)
+	// Return the modal with initial load command
+	return webhooksModal, m.sendListIncomingWebhooks()
+}
+
+// createCreateIncomingWebhookModal creates a create incoming webhook modal,
+// targeting the channel and thread the admin is currently viewing
+func (m *Model) createCreateIncomingWebhookModal() (modal.Modal, tea.Cmd) {
+	createModal := modal.NewCreateIncomingWebhookModal()
+
+	channels := make([]modal.ChannelInfo, len(m.channels))
+	for i, ch := range m.channels {
+		channels[i] = modal.ChannelInfo{
+			ID:   ch.ID,
+			Name: ch.Name,
+		}
+	}
+	createModal.SetChannels(channels)
+
+	if m.currentChannel != nil {
+		var threadID *uint64
+		if m.currentThread != nil {
+			threadID = makeUint64Ptr(m.currentThread.ID)
+		}
+		createModal.SetTarget(m.currentChannel.Name, threadID)
+	}
+
+	createModal.SetSubmitHandler(func(msg *protocol.CreateIncomingWebhookMessage) tea.Cmd {
+		m.statusMessage = "Creating webhook..."
+		return m.sendCreateIncomingWebhook(msg)
+	})
+	return createModal, nil
+}
+
 func (m *Model) lookupUserID(nickname string) (uint64, bool) {
 	if m.userDirectory == nil {
 		return 0, false
@@ -1797,12 +2093,17 @@ func (m *Model) startDMWithUser(userID *uint64, nickname string) (*Model, tea.Cm
 	}
 
 	// Show encryption setup modal first
-	m.showDMEncryptionChoiceModal(targetType, targetUserID, nickname)
-	return m, nil
+	return m, m.showDMEncryptionChoiceModal(targetType, targetUserID, nickname)
 }
 
-// showDMEncryptionChoiceModal shows the encryption setup modal before starting a DM
-func (m *Model) showDMEncryptionChoiceModal(targetType uint8, targetUserID uint64, nickname string) {
+// showDMEncryptionChoiceModal shows the encryption setup modal before starting a DM.
+// In the SSH terminal, where there are no keys, the DM starts unencrypted.
+func (m *Model) showDMEncryptionChoiceModal(targetType uint8, targetUserID uint64, nickname string) tea.Cmd {
+	if m.remote {
+		m.statusMessage = fmt.Sprintf("Starting unencrypted DM with %s (encryption isn't available over SSH)...", nickname)
+		return m.sendStartDM(targetType, targetUserID, nickname, true)
+	}
+
 	// Check if user has SSH key (authenticated via SSH)
 	hasSSHKey := m.userID != nil && m.authState == AuthStateAuthenticated
 
@@ -1833,6 +2134,7 @@ func (m *Model) showDMEncryptionChoiceModal(targetType uint8, targetUserID uint6
 		},
 	})
 	m.modalStack.Push(encModal)
+	return nil
 }
 
 // generateKeyAndStartDM generates a new encryption key and then starts the DM
@@ -1847,7 +2149,7 @@ func (m *Model) generateKeyAndStartDM(targetType uint8, targetUserID uint64, nic
 		// Determine key type based on user registration status
 		// Anonymous users can only use ephemeral (session-only) keys
 		var keyType uint8 = protocol.KeyTypeGenerated
-		label := "generated"
+		label := deviceLabel()
 		if m.userID == nil {
 			keyType = protocol.KeyTypeEphemeral
 			label = "ephemeral"
@@ -1855,9 +2157,11 @@ func (m *Model) generateKeyAndStartDM(targetType uint8, targetUserID uint64, nic
 
 		// Send public key to server
 		msg := &protocol.ProvidePublicKeyMessage{
-			KeyType:   keyType,
-			PublicKey: kp.PublicKey,
-			Label:     label,
+			KeyType:     keyType,
+			PublicKey:   kp.PublicKey,
+			Label:       label,
+			Ratchet:     true,
+			MultiDevice: true,
 		}
 		if err := m.conn.SendMessage(protocol.TypeProvidePublicKey, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -1927,18 +2231,26 @@ func (m *Model) loadEncryptionKey() bool {
 	serverHost := m.conn.GetAddress()
 
 	var privateKey []byte
-	var err error
+	var restoredKeys [][]byte
+	var err, restoredErr error
 
 	if m.userID != nil {
 		// Registered user - load by userID
 		privateKey, err = m.keyStore.LoadKey(serverHost, *m.userID)
+		restoredKeys, restoredErr = m.keyStore.RestoredKeys(serverHost, *m.userID)
 	} else if m.nickname != "" {
 		// Anonymous user - load by nickname
 		privateKey, err = m.keyStore.LoadAnonKey(serverHost, m.nickname)
+		restoredKeys, restoredErr = m.keyStore.RestoredAnonKeys(serverHost, m.nickname)
 	} else {
 		return false
 	}
 
+	if restoredErr != nil && m.logger != nil {
+		m.logger.Printf("Failed to load restored encryption keys: %v", restoredErr)
+	}
+	m.restoredKeys = restoredKeys
+
 	if err != nil {
 		if m.logger != nil && err != crypto.ErrKeyNotFound {
 			m.logger.Printf("Failed to load encryption key: %v", err)
@@ -2182,7 +2494,9 @@ func (m Model) Init() tea.Cmd {
 		listenForServerFrames(m.conn, m.connGeneration), // Always listen for frames
 		tickCmd(),
 		m.spinner.Tick,
-		checkForUpdates(m.currentVersion), // Check for updates in background
+	}
+	if !m.remote {
+		cmds = append(cmds, checkForUpdates(m.currentVersion)) // Check for updates in background
 	}
 
 	// If in directory mode, request server list (selector modal already shown in NewModel)
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
 	"github.com/aeolun/superchat/pkg/protocol"
 )
 
@@ -381,3 +382,58 @@ func TestCalculateThreadDepths(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoteTerminalStartsUnencryptedDMs(t *testing.T) {
+	conn := client.NewMockConnection("localhost:6465")
+	conn.Connect()
+	m := NewTestModelWithMocks(conn, client.NewMockState())
+	userID := uint64(1)
+	m.userID = &userID
+	m.authState = AuthStateAuthenticated
+	m.SetRemoteTerminal(io.Discard)
+
+	if m.keyStore != nil {
+		t.Fatal("the SSH terminal should not store encryption keys on the server")
+	}
+
+	// Starting a DM skips the encryption choice and never generates a key
+	bobID := uint64(2)
+	_, cmd := m.startDMWithUser(&bobID, "bob")
+	if m.modalStack.HasType(modal.ModalEncryptionSetup) {
+		t.Fatal("expected no encryption setup modal")
+	}
+	if cmd == nil {
+		t.Fatal("expected a START_DM command")
+	}
+	cmd()
+	sent, err := conn.GetLastSentMessage()
+	if err != nil {
+		t.Fatalf("GetLastSentMessage: %v", err)
+	}
+	startDM, ok := sent.Msg.(*protocol.StartDMMessage)
+	if sent.Type != protocol.TypeStartDM || !ok {
+		t.Fatalf("expected START_DM, got 0x%02X", sent.Type)
+	}
+	if !startDM.AllowUnencrypted {
+		t.Error("expected the DM to allow unencrypted messages")
+	}
+
+	// A DM that needs a key explains why it can't be joined here
+	payload, err := (&protocol.KeyRequiredMessage{Reason: "bob requires encryption"}).Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	updated, _ := m.handleKeyRequired(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeKeyRequired, Payload: payload})
+	m = updated.(Model)
+	if m.modalStack.HasType(modal.ModalEncryptionSetup) {
+		t.Error("expected no encryption setup modal for KEY_REQUIRED")
+	}
+	if m.errorMessage == "" {
+		t.Error("expected an error explaining encryption isn't available")
+	}
+	for _, sent := range conn.SentMessages {
+		if sent.Type == protocol.TypeProvidePublicKey {
+			t.Error("the SSH terminal should never register an encryption key")
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// createKickUserModal creates a kick user modal with submit handler
+func (m *Model) createKickUserModal() (modal.Modal, tea.Cmd) {
+	kickModal := modal.NewKickUserModal()
+	kickModal.SetSubmitHandler(func(msg *protocol.KickUserMessage) tea.Cmd {
+		m.statusMessage = "Kicking user..."
+		return m.sendModeration(protocol.TypeKickUser, msg)
+	})
+	return kickModal, nil
+}
+
+// createMuteUserModal creates a mute user modal with submit handlers
+func (m *Model) createMuteUserModal() (modal.Modal, tea.Cmd) {
+	channels := make([]modal.ChannelInfo, len(m.channels))
+	for i, ch := range m.channels {
+		channels[i] = modal.ChannelInfo{ID: ch.ID, Name: ch.Name}
+	}
+
+	muteModal := modal.NewMuteUserModal(channels)
+	muteModal.SetSubmitHandlers(
+		func(msg *protocol.MuteUserMessage) tea.Cmd {
+			m.statusMessage = "Muting user..."
+			return m.sendModeration(protocol.TypeMuteUser, msg)
+		},
+		func(msg *protocol.UnmuteUserMessage) tea.Cmd {
+			m.statusMessage = "Unmuting user..."
+			return m.sendModeration(protocol.TypeUnmuteUser, msg)
+		},
+	)
+	return muteModal, nil
+}
+
+// sendModeration sends a KICK_USER, MUTE_USER or UNMUTE_USER request
+func (m *Model) sendModeration(msgType uint8, msg protocol.ProtocolMessage) tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		if err := conn.SendMessage(msgType, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// handleModerationResult shows the outcome of a kick, mute or unmute
+func (m Model) handleModerationResult(name string, modalType modal.ModalType, success bool, message string, decodeErr error) (tea.Model, tea.Cmd) {
+	if decodeErr != nil {
+		m.statusMessage = "" // Clear in-progress status
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode %s: %v", name, decodeErr)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	var statusCmd tea.Cmd
+	if success {
+		statusCmd = m.setStatus(message)
+		m.modalStack.RemoveByType(modalType)
+	} else {
+		m.statusMessage = "" // Clear in-progress status
+		statusCmd = m.setError(message)
+	}
+
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
+}
+
+// handleUserKicked processes USER_KICKED
+func (m Model) handleUserKicked(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.UserKickedMessage{}
+	err := msg.Decode(frame.Payload)
+	return m.handleModerationResult("USER_KICKED", modal.ModalKickUser, msg.Success, msg.Message, err)
+}
+
+// handleUserMuted processes USER_MUTED
+func (m Model) handleUserMuted(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.UserMutedMessage{}
+	err := msg.Decode(frame.Payload)
+	return m.handleModerationResult("USER_MUTED", modal.ModalMuteUser, msg.Success, msg.Message, err)
+}
+
+// handleUserUnmuted processes USER_UNMUTED
+func (m Model) handleUserUnmuted(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.UserUnmutedMessage{}
+	err := msg.Decode(frame.Payload)
+	return m.handleModerationResult("USER_UNMUTED", modal.ModalMuteUser, msg.Success, msg.Message, err)
+}
@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestMuteUserModal(t *testing.T) {
+	m := NewModel(client.NewMockConnection("localhost:6465"), client.NewMockState(), "1.0.0", false, 0, log.New(io.Discard, "", 0), "", nil)
+
+	var muted *protocol.MuteUserMessage
+	var unmuted *protocol.UnmuteUserMessage
+	newModal := func() *modal.MuteUserModal {
+		muteModal := modal.NewMuteUserModal([]modal.ChannelInfo{{ID: 4, Name: "general"}, {ID: 9, Name: "random"}})
+		muteModal.SetNickname("bob")
+		muteModal.SetSubmitHandlers(
+			func(msg *protocol.MuteUserMessage) tea.Cmd { muted = msg; return nil },
+			func(msg *protocol.UnmuteUserMessage) tea.Cmd { unmuted = msg; return nil },
+		)
+		return muteModal
+	}
+	press := func(md *modal.MuteUserModal, keys ...tea.KeyMsg) {
+		for _, key := range keys {
+			md.HandleKey(key)
+		}
+	}
+	tab := tea.KeyMsg{Type: tea.KeyTab}
+	right := tea.KeyMsg{Type: tea.KeyRight}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+
+	// Mute in the second channel for the default hour
+	muteModal := newModal()
+	press(muteModal, tab, right, right, tab, tab, tab, enter)
+	if muted == nil || *muted.Nickname != "bob" || muted.ChannelID == nil || *muted.ChannelID != 9 ||
+		muted.DurationSeconds == nil || *muted.DurationSeconds != 3600 || muted.Reason != "Spam" {
+		t.Fatalf("unexpected mute request %+v", muted)
+	}
+
+	// Shift+Tab wraps round to the unmute toggle; lifting a global mute sends no channel
+	muteModal = newModal()
+	press(muteModal, tea.KeyMsg{Type: tea.KeyShiftTab}, space, enter)
+	if unmuted == nil || *unmuted.Nickname != "bob" || unmuted.ChannelID != nil {
+		t.Fatalf("unexpected unmute request %+v", unmuted)
+	}
+
+	// A successful response closes the modal
+	m.modalStack.Push(muteModal)
+	payload, err := (&protocol.UserMutedMessage{Success: true, MuteID: 1, Message: "User bob muted"}).Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	updated, _ := m.handleUserMuted(&protocol.Frame{Type: protocol.TypeUserMuted, Payload: payload})
+	m = updated.(Model)
+	if m.modalStack.TopType() == modal.ModalMuteUser {
+		t.Error("expected the mute modal to close")
+	}
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sendPlusOne sends a PLUS_ONE for a message
+func (m Model) sendPlusOne(messageID uint64) tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		msg := &protocol.PlusOneMessage{MessageID: messageID}
+		if err := conn.SendMessage(protocol.TypePlusOne, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// handlePlusOneUpdate processes PLUS_ONE_UPDATE
+func (m Model) handlePlusOneUpdate(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.PlusOneUpdateMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode +1 update: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	m.applyPlusOneUpdate(msg)
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// applyPlusOneUpdate sets the +1 counts of a message wherever it is shown
+func (m *Model) applyPlusOneUpdate(update *protocol.PlusOneUpdateMessage) {
+	apply := func(msg *protocol.Message) bool {
+		if msg.ID != update.MessageID {
+			return false
+		}
+		msg.PlusOneCount = update.PlusOneCount
+		msg.PlusOneAnonCount = update.PlusOneAnonCount
+		return true
+	}
+
+	updatedThreadList := false
+	for i := range m.threads {
+		if apply(&m.threads[i]) {
+			updatedThreadList = true
+		}
+	}
+
+	if m.currentThread != nil {
+		apply(m.currentThread)
+	}
+	for i := range m.threadReplies {
+		apply(&m.threadReplies[i])
+	}
+	for _, replies := range m.threadRepliesCache {
+		for i := range replies {
+			apply(&replies[i])
+		}
+	}
+
+	if updatedThreadList {
+		m.threadListViewport.SetContent(m.buildThreadListContent())
+	}
+	if m.currentView == ViewThreadView {
+		m.threadViewport.SetContent(m.buildThreadContent())
+	}
+}
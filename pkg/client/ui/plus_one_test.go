@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestFormatPlusOnes(t *testing.T) {
+	tests := []struct {
+		registered, anonymous uint32
+		want                  string
+	}{
+		{0, 0, ""},
+		{42, 0, "+42"},
+		{42, 1337, "+42 (+∞ 1337)"},
+		{0, 3, "+0 (+∞ 3)"},
+	}
+	for _, tt := range tests {
+		if got := client.FormatPlusOnes(tt.registered, tt.anonymous); got != tt.want {
+			t.Errorf("FormatPlusOnes(%d, %d) = %q, want %q", tt.registered, tt.anonymous, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPlusOneUpdate(t *testing.T) {
+	m := NewModel(client.NewMockConnection("localhost:6465"), client.NewMockState(), "1.0.0", false, 0, log.New(io.Discard, "", 0), "", nil)
+	m.currentChannel = &protocol.Channel{ID: 1, Name: "general", Type: 1}
+	m.threads = []protocol.Message{{ID: 10}, {ID: 20}}
+	m.currentThread = &protocol.Message{ID: 10}
+	m.threadReplies = []protocol.Message{{ID: 11}, {ID: 12}}
+	m.threadRepliesCache[10] = []protocol.Message{{ID: 11}, {ID: 12}}
+	m.currentView = ViewThreadView
+
+	m.applyPlusOneUpdate(&protocol.PlusOneUpdateMessage{MessageID: 10, PlusOneCount: 42, PlusOneAnonCount: 1337})
+	m.applyPlusOneUpdate(&protocol.PlusOneUpdateMessage{MessageID: 12, PlusOneCount: 1})
+
+	if m.threads[0].PlusOneCount != 42 || m.threads[0].PlusOneAnonCount != 1337 {
+		t.Errorf("thread list counts = %d/%d, want 42/1337", m.threads[0].PlusOneCount, m.threads[0].PlusOneAnonCount)
+	}
+	if m.currentThread.PlusOneCount != 42 {
+		t.Errorf("current thread PlusOneCount = %d, want 42", m.currentThread.PlusOneCount)
+	}
+	if m.threadReplies[1].PlusOneCount != 1 || m.threadRepliesCache[10][1].PlusOneCount != 1 {
+		t.Error("reply counts should be updated in the loaded and cached replies")
+	}
+	if m.threads[1].PlusOneCount != 0 || m.threadReplies[0].PlusOneCount != 0 {
+		t.Error("other messages should be unchanged")
+	}
+}
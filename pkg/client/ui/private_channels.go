@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// isPrivateChannel reports whether a channel came from PRIVATE_CHANNEL_LIST
+func (m *Model) isPrivateChannel(channelID uint64) bool {
+	for _, ch := range m.privateChannels {
+		if ch.ID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// setPrivateChannels replaces the private channels shown after the public ones
+func (m *Model) setPrivateChannels(private []protocol.Channel) {
+	public := make([]protocol.Channel, 0, len(m.channels))
+	for _, ch := range m.channels {
+		if !m.isPrivateChannel(ch.ID) {
+			public = append(public, ch)
+		}
+	}
+	m.privateChannels = private
+	m.channels = append(public, private...)
+}
+
+// handlePrivateChannelList processes PRIVATE_CHANNEL_LIST
+func (m Model) handlePrivateChannelList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ChannelListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode private channel list: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	m.setPrivateChannels(msg.Channels)
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.requestChannelKeys(msg.Channels))
+}
+
+// handleChannelInvited processes CHANNEL_INVITED
+func (m Model) handleChannelInvited(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ChannelInvitedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode channel invite: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if !m.isPrivateChannel(msg.ChannelID) {
+		private := append([]protocol.Channel{}, m.privateChannels...)
+		m.setPrivateChannels(append(private, protocol.Channel{
+			ID:             msg.ChannelID,
+			Name:           msg.Name,
+			Description:    msg.Description,
+			Type:           msg.Type,
+			RetentionHours: msg.RetentionHours,
+		}))
+	}
+
+	statusCmd := m.setStatus(fmt.Sprintf("%s added you to #%s", msg.InvitedBy, msg.Name))
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
+}
+
+// handleRemovedFromChannel processes REMOVED_FROM_CHANNEL
+func (m Model) handleRemovedFromChannel(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.RemovedFromChannelMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode channel removal: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	private := make([]protocol.Channel, 0, len(m.privateChannels))
+	for _, ch := range m.privateChannels {
+		if ch.ID != msg.ChannelID {
+			private = append(private, ch)
+		}
+	}
+	m.setPrivateChannels(private)
+	delete(m.channelKeyrings, msg.ChannelID)
+
+	// If we were in the channel, navigate to channel list
+	if m.currentChannel != nil && m.currentChannel.ID == msg.ChannelID {
+		m.clearActiveChannel()
+		m.currentChannel = nil
+		m.threads = nil
+		m.currentThread = nil
+		m.threadReplies = nil
+		m.currentView = ViewChannelList
+	}
+
+	statusCmd := m.setStatus(msg.Message)
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
+}
+
+// handleChannelMembershipChanged processes CHANNEL_MEMBERSHIP_CHANGED
+func (m Model) handleChannelMembershipChanged(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ChannelMembershipChangedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.statusMessage = "" // Clear in-progress status
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode membership change: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	var statusCmd tea.Cmd
+	if msg.Success {
+		statusCmd = m.setStatus(msg.Message)
+	} else {
+		m.statusMessage = "" // Clear in-progress status
+		statusCmd = m.setError(msg.Message)
+	}
+
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
+}
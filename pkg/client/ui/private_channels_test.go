@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestPrivateChannelsInChannelList(t *testing.T) {
+	m := NewTestModel()
+	frame := func(msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+		t.Helper()
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Version: protocol.ProtocolVersion, Type: msgType, Payload: payload}
+	}
+	ids := func(m Model) []uint64 {
+		result := make([]uint64, len(m.channels))
+		for i, ch := range m.channels {
+			result[i] = ch.ID
+		}
+		return result
+	}
+
+	updated, _ := m.handleChannelList(CreateTestChannelListFrame([]protocol.Channel{CreateTestChannel(1, "general")}))
+	m = updated.(Model)
+	updated, _ = m.handlePrivateChannelList(frame(protocol.TypePrivateChannelList, &protocol.ChannelListMessage{
+		Channels: []protocol.Channel{CreateTestChannel(7, "team")},
+	}))
+	m = updated.(Model)
+	if got := ids(m); len(got) != 2 || got[0] != 1 || got[1] != 7 {
+		t.Fatalf("expected general then team, got %v", got)
+	}
+
+	// Refreshing the public list keeps the private channels
+	updated, _ = m.handleChannelList(CreateTestChannelListFrame([]protocol.Channel{CreateTestChannel(1, "general"), CreateTestChannel(2, "random")}))
+	m = updated.(Model)
+	if got := ids(m); len(got) != 3 || got[2] != 7 {
+		t.Fatalf("expected team to survive a channel list refresh, got %v", got)
+	}
+
+	updated, _ = m.handleChannelInvited(frame(protocol.TypeChannelInvited, &protocol.ChannelInvitedMessage{ChannelID: 8, Name: "ops", InvitedBy: "alice"}))
+	m = updated.(Model)
+	if !m.isPrivateChannel(8) || m.statusMessage != "alice added you to #ops" {
+		t.Fatalf("expected #ops to be added, got channels %v and status %q", ids(m), m.statusMessage)
+	}
+
+	ch := m.channels[2]
+	m.currentChannel = &ch
+	updated, _ = m.handleRemovedFromChannel(frame(protocol.TypeRemovedFromChannel, &protocol.RemovedFromChannelMessage{ChannelID: 7, Message: "You were removed from #team by alice"}))
+	m = updated.(Model)
+	if got := ids(m); len(got) != 3 || m.isPrivateChannel(7) {
+		t.Fatalf("expected team to be removed, got %v", got)
+	}
+	if m.currentChannel != nil {
+		t.Error("expected to leave the channel we were removed from")
+	}
+}
@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The server hands out the public keys of encrypted DMs, so it could swap
+// them. The client remembers the last key seen for every registered user in
+// the state DB, shows a safety number computed from both keys in the DM, and
+// warns when the key of a user changes. Comparing the safety number with the
+// other party marks their key as verified; a key that changed after that is
+// shown as a blocking warning.
+
+// KeyVerifiedMsg is sent when the user marks a DM partner's key as verified
+// (or no longer verified) in the verify key modal
+type KeyVerifiedMsg struct {
+	ChannelID uint64
+	Verified  bool
+}
+
+// checkContactKey computes the safety number of an encrypted DM and compares
+// the other party's key with the one we saw before. Anonymous users have no
+// identity to remember keys for, so only the safety number is shown for them.
+func (m *Model) checkContactKey(dm *DMChannel) tea.Cmd {
+	safetyNumber, err := crypto.SafetyNumber(m.encryptionKeyPub, dm.OtherPubKey)
+	if err != nil {
+		if m.logger != nil {
+			m.logger.Printf("[E2E] Failed to compute safety number of DM %d: %v", dm.ChannelID, err)
+		}
+		return nil
+	}
+	dm.SafetyNumber = safetyNumber
+	if dm.OtherUserID == nil {
+		return nil
+	}
+
+	serverAddress := m.conn.GetAddress()
+	knownKey, verified, err := m.state.GetContactKey(serverAddress, *dm.OtherUserID)
+	if err != nil {
+		return m.setError(fmt.Sprintf("Failed to check %s's encryption key: %v", dm.OtherNickname, err))
+	}
+	if err := m.state.SaveContactKey(serverAddress, *dm.OtherUserID, dm.OtherPubKey); err != nil {
+		return m.setError(fmt.Sprintf("Failed to remember %s's encryption key: %v", dm.OtherNickname, err))
+	}
+
+	if knownKey == nil || bytes.Equal(knownKey, dm.OtherPubKey) {
+		dm.KeyVerified = verified
+		return nil
+	}
+
+	// The key changed, so it's no longer verified
+	if m.logger != nil {
+		m.logger.Printf("[E2E] Encryption key of %s changed (verified=%v)", dm.OtherNickname, verified)
+	}
+	if verified {
+		m.modalStack.Push(modal.NewErrorModal(
+			"⚠ Safety number changed",
+			fmt.Sprintf("%s's encryption key is different from the one you verified.\n\n"+
+				"They may have reinstalled or started using a new device, but the server "+
+				"could also be reading your messages. Verify the new safety number with "+
+				"%s before sending anything sensitive.", dm.OtherNickname, dm.OtherNickname),
+			nil,
+		))
+		return nil
+	}
+	return m.setError(fmt.Sprintf("%s's encryption key changed. Verify the new safety number with Ctrl+Y.", dm.OtherNickname))
+}
+
+// currentDM returns the DM channel that is currently open, if any
+func (m *Model) currentDM() *DMChannel {
+	if m.currentChannel == nil {
+		return nil
+	}
+	for i := range m.dmChannels {
+		if m.dmChannels[i].ChannelID == m.currentChannel.ID {
+			return &m.dmChannels[i]
+		}
+	}
+	return nil
+}
+
+// showVerifyKeyModal shows the safety number of the current DM
+func (m *Model) showVerifyKeyModal() {
+	dm := m.currentDM()
+	if dm == nil || dm.SafetyNumber == "" {
+		return
+	}
+	channelID := dm.ChannelID
+	m.modalStack.Push(modal.NewVerifyKeyModal(dm.OtherNickname, dm.SafetyNumber, dm.KeyVerified, func(verified bool) tea.Cmd {
+		// Return a message so Update applies it to the current model
+		return func() tea.Msg {
+			return KeyVerifiedMsg{ChannelID: channelID, Verified: verified}
+		}
+	}))
+}
+
+// handleKeyVerified stores whether the user verified a DM partner's key
+func (m Model) handleKeyVerified(msg KeyVerifiedMsg) (tea.Model, tea.Cmd) {
+	for i := range m.dmChannels {
+		dm := &m.dmChannels[i]
+		if dm.ChannelID != msg.ChannelID {
+			continue
+		}
+		if dm.OtherUserID == nil {
+			return m, m.setError("Keys of anonymous users can't be remembered")
+		}
+		if err := m.state.SetContactKeyVerified(m.conn.GetAddress(), *dm.OtherUserID, msg.Verified); err != nil {
+			return m, m.setError(fmt.Sprintf("Failed to save verification: %v", err))
+		}
+		dm.KeyVerified = msg.Verified
+		if msg.Verified {
+			return m, m.setStatus(fmt.Sprintf("Marked %s's key as verified", dm.OtherNickname))
+		}
+		return m, m.setStatus(fmt.Sprintf("Marked %s's key as not verified", dm.OtherNickname))
+	}
+	return m, nil
+}
+
+// renderDMHeader renders the line above an encrypted DM showing its safety
+// number and whether it was verified
+func (m Model) renderDMHeader() string {
+	dm := m.currentDM()
+	if dm == nil || dm.SafetyNumber == "" {
+		return ""
+	}
+	header := "🔒 " + dm.OtherNickname + " · Safety number: " + dm.SafetyNumber + " · "
+	if dm.KeyVerified {
+		return MutedTextStyle.Render(header) + SuccessStyle.Render("✓ Verified")
+	}
+	return MutedTextStyle.Render(header) + WarningStyle.Render("Not verified (Ctrl+Y)")
+}
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestDMSafetyNumber(t *testing.T) {
+	myKeys, _ := crypto.GenerateX25519KeyPair()
+	bobKeys, _ := crypto.GenerateX25519KeyPair()
+	conn := client.NewMockConnection("localhost:6465")
+	conn.Connect()
+	m := NewTestModelWithMocks(conn, client.NewMockState())
+	m.encryptionKeyPub = myKeys.PublicKey[:]
+	m.encryptionKeyPriv = myKeys.PrivateKey[:]
+
+	bobID := uint64(7)
+	dmReady := func(t *testing.T, m Model, bobKey [32]byte) Model {
+		t.Helper()
+		payload, err := (&protocol.DMReadyMessage{
+			ChannelID: 5, OtherUserID: &bobID, OtherNickname: "bob", IsEncrypted: true, OtherPublicKey: bobKey,
+		}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		updated, _ := m.handleDMReady(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeDMReady, Payload: payload})
+		return updated.(Model)
+	}
+
+	m = dmReady(t, m, bobKeys.PublicKey)
+	m.currentChannel = &protocol.Channel{ID: 5, Name: "bob"}
+	want, _ := crypto.SafetyNumber(myKeys.PublicKey[:], bobKeys.PublicKey[:])
+	dm := m.currentDM()
+	if dm.SafetyNumber != want || dm.KeyVerified {
+		t.Fatalf("expected an unverified DM with safety number %q, got %q (verified=%v)", want, dm.SafetyNumber, dm.KeyVerified)
+	}
+	if header := m.renderDMHeader(); !strings.Contains(header, want) || !strings.Contains(header, "Not verified") {
+		t.Errorf("expected the header to show the unverified safety number, got %q", header)
+	}
+
+	// Mark the key verified from the modal
+	m.showVerifyKeyModal()
+	if m.modalStack.TopType() != modal.ModalVerifyKey {
+		t.Fatalf("expected the verify key modal, got %v", m.modalStack.TopType())
+	}
+	updated, _ := m.handleKeyVerified(KeyVerifiedMsg{ChannelID: 5, Verified: true})
+	m = updated.(Model)
+	if !m.currentDM().KeyVerified {
+		t.Fatal("expected the key to be verified")
+	}
+
+	// The same key stays verified when the DM is opened again
+	m.modalStack.RemoveByType(modal.ModalVerifyKey)
+	m = dmReady(t, m, bobKeys.PublicKey)
+	if !m.currentDM().KeyVerified {
+		t.Error("expected the key to stay verified")
+	}
+	if m.modalStack.TopType() != modal.ModalNone {
+		t.Errorf("expected no warning, got %v", m.modalStack.TopType())
+	}
+
+	// A different key for a verified contact is a loud warning
+	newKeys, _ := crypto.GenerateX25519KeyPair()
+	m = dmReady(t, m, newKeys.PublicKey)
+	if m.modalStack.TopType() != modal.ModalError {
+		t.Errorf("expected a key change warning, got %v", m.modalStack.TopType())
+	}
+	if dm := m.currentDM(); dm.KeyVerified || dm.SafetyNumber == want {
+		t.Error("expected a new, unverified safety number")
+	}
+}
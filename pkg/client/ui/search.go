@@ -0,0 +1,321 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// searchDateLayout is the date format accepted by after: and before: filters
+const searchDateLayout = "2006-01-02"
+
+// SearchResultSelectedMsg is sent when the user opens a hit from the search
+// or mentions modal
+type SearchResultSelectedMsg struct {
+	Result protocol.SearchResult
+}
+
+// parsedSearchQuery is a search query split into free text and filters
+type parsedSearchQuery struct {
+	Text    string
+	Author  *string
+	Channel *string // Channel name from in:#name
+	After   *time.Time
+	Before  *time.Time
+}
+
+// parseSearchQuery extracts from:, in:, after: and before: filters from a
+// search query. Dates are local calendar days; before: excludes the given day.
+func parseSearchQuery(input string) (parsedSearchQuery, error) {
+	var parsed parsedSearchQuery
+	var words []string
+
+	for _, field := range strings.Fields(input) {
+		key, value, found := strings.Cut(field, ":")
+		if !found || value == "" {
+			words = append(words, field)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "from":
+			author := strings.TrimLeft(value, "~$@")
+			parsed.Author = &author
+		case "in":
+			channel := strings.TrimPrefix(value, "#")
+			parsed.Channel = &channel
+		case "after", "before":
+			day, err := time.ParseInLocation(searchDateLayout, value, time.Local)
+			if err != nil {
+				return parsed, fmt.Errorf("invalid date %q (use YYYY-MM-DD)", value)
+			}
+			if strings.ToLower(key) == "after" {
+				parsed.After = &day
+			} else {
+				parsed.Before = &day
+			}
+		default:
+			words = append(words, field)
+		}
+	}
+
+	parsed.Text = strings.Join(words, " ")
+	if parsed.Text == "" {
+		return parsed, fmt.Errorf("enter at least one word to search for")
+	}
+	return parsed, nil
+}
+
+// buildSearchRequest turns the modal's query and scope into a SEARCH_MESSAGES request
+func (m *Model) buildSearchRequest(query string, scope modal.SearchScope, beforeID *uint64) (*protocol.SearchMessagesMessage, error) {
+	parsed, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &protocol.SearchMessagesMessage{
+		Query:        parsed.Text,
+		ChannelID:    scope.ChannelID,
+		SubchannelID: scope.SubchannelID,
+		ThreadID:     scope.ThreadID,
+		Author:       parsed.Author,
+		After:        parsed.After,
+		Before:       parsed.Before,
+		BeforeID:     beforeID,
+		Limit:        50,
+	}
+
+	// in:#name overrides the scope
+	if parsed.Channel != nil {
+		channel := m.findChannelByName(*parsed.Channel)
+		if channel == nil {
+			return nil, fmt.Errorf("unknown channel #%s", *parsed.Channel)
+		}
+		req.ChannelID = &channel.ID
+		req.SubchannelID = nil
+		req.ThreadID = nil
+	}
+	return req, nil
+}
+
+// findChannelByName looks up a channel from the channel list by name
+func (m *Model) findChannelByName(name string) *protocol.Channel {
+	for i := range m.channels {
+		if strings.EqualFold(m.channels[i].Name, name) {
+			return &m.channels[i]
+		}
+	}
+	return nil
+}
+
+// findChannelByID looks up a channel from the channel list by ID
+func (m *Model) findChannelByID(channelID uint64) *protocol.Channel {
+	for i := range m.channels {
+		if m.channels[i].ID == channelID {
+			return &m.channels[i]
+		}
+	}
+	return nil
+}
+
+// channelDisplayNames maps channel IDs to labels for result lists
+func (m *Model) channelDisplayNames() map[uint64]string {
+	channelNames := make(map[uint64]string, len(m.channels)+len(m.dmChannels))
+	for _, ch := range m.channels {
+		channelNames[ch.ID] = "#" + ch.Name
+	}
+	for _, dm := range m.dmChannels {
+		channelNames[dm.ChannelID] = "DM " + dm.OtherNickname
+	}
+	return channelNames
+}
+
+// showSearchModal opens the message search modal, offering the current
+// channel and thread as scopes
+func (m *Model) showSearchModal() {
+	scopes := []modal.SearchScope{{Label: "All channels"}}
+	if m.currentChannel != nil {
+		channelID := m.currentChannel.ID
+		scope := modal.SearchScope{Label: "#" + m.currentChannel.Name, ChannelID: &channelID}
+		// Subchannels are opened like channels; search them by subchannel ID
+		for _, sub := range m.subchannels {
+			if sub.ID == channelID {
+				scope.ChannelID = nil
+				scope.SubchannelID = &channelID
+				break
+			}
+		}
+		if m.isCurrentChannelDM() {
+			scope.Label = "This conversation"
+		}
+		scopes = append(scopes, scope)
+
+		if m.currentView == ViewThreadView && m.currentThread != nil {
+			threadID := m.currentThread.ID
+			scopes = append(scopes, modal.SearchScope{Label: "This thread", ThreadID: &threadID})
+		}
+	}
+
+	searchModal := modal.NewSearchModal(
+		scopes,
+		m.channelDisplayNames(),
+		func(query string, scope modal.SearchScope, beforeID *uint64) (tea.Cmd, error) {
+			return m.sendSearchMessages(query, scope, beforeID)
+		},
+		func(result protocol.SearchResult) tea.Cmd {
+			return func() tea.Msg {
+				return SearchResultSelectedMsg{Result: result}
+			}
+		},
+	)
+	m.modalStack.Push(searchModal)
+}
+
+// sendSearchMessages sends a SEARCH_MESSAGES request. Invalid filters are
+// reported without contacting the server.
+func (m *Model) sendSearchMessages(query string, scope modal.SearchScope, beforeID *uint64) (tea.Cmd, error) {
+	req, err := m.buildSearchRequest(query, scope, beforeID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := m.conn
+	return func() tea.Msg {
+		if err := conn.SendMessage(protocol.TypeSearchMessages, req); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}, nil
+}
+
+// handleSearchResults processes SEARCH_RESULTS
+func (m Model) handleSearchResults(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.SearchResultsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode search results: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if searchModal, ok := m.modalStack.Top().(*modal.SearchModal); ok {
+		searchModal.SetResults(msg.Results, msg.HasMore)
+	} else if m.logger != nil {
+		m.logger.Printf("[DEBUG] SearchModal not on top of stack, dropping %d results", len(msg.Results))
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// jumpToSearchResult opens the channel and thread containing a search hit and
+// selects the hit once the thread's replies have loaded
+func (m Model) jumpToSearchResult(result protocol.SearchResult) (tea.Model, tea.Cmd) {
+	hit := result.Message
+
+	var target *protocol.Channel
+	isDM := false
+	if ch := m.findChannelByID(hit.ChannelID); ch != nil {
+		target = ch
+	} else {
+		for _, dm := range m.dmChannels {
+			if dm.ChannelID == hit.ChannelID {
+				target = &protocol.Channel{ID: dm.ChannelID, Name: dm.OtherNickname, Type: 0}
+				isDM = true
+				break
+			}
+		}
+	}
+	if target == nil {
+		return m, m.setError("That channel is no longer available")
+	}
+
+	var cmds []tea.Cmd
+
+	// Switch channels if the hit is somewhere else
+	if m.currentChannel == nil || m.currentChannel.ID != target.ID {
+		if m.currentChannel != nil {
+			cmds = append(cmds,
+				m.sendLeaveChannel(m.currentChannel.ID, false),
+				m.sendUnsubscribeChannel(m.currentChannel.ID),
+			)
+			m.clearActiveChannel()
+		}
+		channel := *target
+		m.currentChannel = &channel
+		cmds = append(cmds,
+			m.sendJoinChannel(target.ID),
+			m.sendSubscribeChannel(target.ID),
+		)
+	} else if m.currentThread != nil {
+		cmds = append(cmds, m.sendUnsubscribeThread(m.currentThread.ID))
+	}
+
+	// Chat channels and DMs have no threads: open the conversation
+	if isDM || target.Type == 0 {
+		m.currentView = ViewChatChannel
+		m.loadingChat = true
+		m.chatMessages = nil
+		m.chatTextarea.Reset()
+		m.chatTextarea.Focus()
+		cmds = append(cmds, m.requestChatMessages(target.ID), textarea.Blink)
+		return m, tea.Batch(cmds...)
+	}
+
+	root := hit
+	if result.ThreadRoot != nil {
+		root = *result.ThreadRoot
+		m.searchJumpID = hit.ID
+	} else {
+		m.searchJumpID = 0
+	}
+
+	// Load the thread list too so Esc returns to the channel
+	m.loadingThreadList = true
+	m.threads = nil
+	m.threadCursor = 0
+	m.allThreadsLoaded = false
+
+	m.currentThread = &root
+	m.currentView = ViewThreadView
+	m.threadReplies = nil
+	m.replyCursor = 0
+	m.newMessageIDs = make(map[uint64]bool)
+	m.confirmingDelete = false
+	m.allRepliesLoaded = false
+	m.loadingThreadReplies = true
+	m.threadViewport.SetContent(m.buildThreadContent())
+	m.threadViewport.GotoTop()
+
+	cmds = append(cmds,
+		m.requestThreadList(target.ID),
+		m.requestThreadReplies(root.ID),
+		m.sendSubscribeThread(root.ID),
+	)
+	return m, tea.Batch(cmds...)
+}
+
+// applySearchJump selects the pending search hit in the loaded thread replies.
+// If the hit isn't loaded yet, the next page of replies is requested.
+func (m *Model) applySearchJump() tea.Cmd {
+	if m.searchJumpID == 0 || m.currentThread == nil {
+		return nil
+	}
+	for i, reply := range m.threadReplies {
+		if reply.ID == m.searchJumpID {
+			m.searchJumpID = 0
+			m.replyCursor = i + 1 // Cursor 0 is the thread root
+			m.threadViewport.SetContent(m.buildThreadContent())
+			m.scrollToKeepCursorVisible()
+			return nil
+		}
+	}
+	if m.allRepliesLoaded || len(m.threadReplies) == 0 {
+		// The hit was deleted or moved since the search ran
+		m.searchJumpID = 0
+		return nil
+	}
+	m.loadingMoreReplies = true
+	return m.loadMoreReplies()
+}
@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	parsed, err := parseSearchQuery("release decision from:~alice in:#general after:2024-03-01 before:2024-04-01")
+	if err != nil {
+		t.Fatalf("parseSearchQuery() error = %v", err)
+	}
+	if parsed.Text != "release decision" {
+		t.Errorf("Text = %q, want %q", parsed.Text, "release decision")
+	}
+	if parsed.Author == nil || *parsed.Author != "alice" {
+		t.Errorf("Author = %v, want alice", parsed.Author)
+	}
+	if parsed.Channel == nil || *parsed.Channel != "general" {
+		t.Errorf("Channel = %v, want general", parsed.Channel)
+	}
+	wantAfter := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	if parsed.After == nil || !parsed.After.Equal(wantAfter) {
+		t.Errorf("After = %v, want %v", parsed.After, wantAfter)
+	}
+	wantBefore := time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)
+	if parsed.Before == nil || !parsed.Before.Equal(wantBefore) {
+		t.Errorf("Before = %v, want %v", parsed.Before, wantBefore)
+	}
+
+	// Unknown prefixes and URLs are search text
+	parsed, err = parseSearchQuery("see http://example.com")
+	if err != nil {
+		t.Fatalf("parseSearchQuery() error = %v", err)
+	}
+	if parsed.Text != "see http://example.com" {
+		t.Errorf("Text = %q", parsed.Text)
+	}
+
+	if _, err := parseSearchQuery("after:yesterday deploy"); err == nil {
+		t.Error("expected error for invalid date")
+	}
+	if _, err := parseSearchQuery("from:alice"); err == nil {
+		t.Error("expected error for query without words")
+	}
+}
+
+func TestBuildSearchRequest(t *testing.T) {
+	m := NewModel(client.NewMockConnection("localhost:6465"), client.NewMockState(), "1.0.0", false, 0, log.New(io.Discard, "", 0), "", nil)
+	m.channels = []protocol.Channel{{ID: 3, Name: "general"}, {ID: 4, Name: "decisions", Type: 1}}
+
+	threadID := uint64(99)
+	beforeID := uint64(500)
+	req, err := m.buildSearchRequest("deploy", modal.SearchScope{ThreadID: &threadID}, &beforeID)
+	if err != nil {
+		t.Fatalf("buildSearchRequest() error = %v", err)
+	}
+	if req.ThreadID == nil || *req.ThreadID != threadID {
+		t.Errorf("ThreadID = %v, want %d", req.ThreadID, threadID)
+	}
+	if req.BeforeID == nil || *req.BeforeID != beforeID {
+		t.Errorf("BeforeID = %v, want %d", req.BeforeID, beforeID)
+	}
+
+	// in:#channel replaces the scope
+	req, err = m.buildSearchRequest("deploy in:#Decisions", modal.SearchScope{ThreadID: &threadID}, nil)
+	if err != nil {
+		t.Fatalf("buildSearchRequest() error = %v", err)
+	}
+	if req.ChannelID == nil || *req.ChannelID != 4 {
+		t.Errorf("ChannelID = %v, want 4", req.ChannelID)
+	}
+	if req.ThreadID != nil {
+		t.Errorf("ThreadID = %v, want nil", req.ThreadID)
+	}
+	if req.Query != "deploy" {
+		t.Errorf("Query = %q, want deploy", req.Query)
+	}
+
+	if _, err := m.buildSearchRequest("deploy in:#nope", modal.SearchScope{}, nil); err == nil {
+		t.Error("expected error for unknown channel")
+	}
+}
+
+func TestApplySearchJump(t *testing.T) {
+	m := NewModel(client.NewMockConnection("localhost:6465"), client.NewMockState(), "1.0.0", false, 0, log.New(io.Discard, "", 0), "", nil)
+	m.currentChannel = &protocol.Channel{ID: 1, Name: "general", Type: 1}
+	m.currentThread = &protocol.Message{ID: 10}
+	m.threadReplies = []protocol.Message{{ID: 11}, {ID: 12}, {ID: 13}}
+
+	m.searchJumpID = 12
+	if cmd := m.applySearchJump(); cmd != nil {
+		t.Error("expected no command when the hit is loaded")
+	}
+	if m.replyCursor != 2 {
+		t.Errorf("replyCursor = %d, want 2", m.replyCursor)
+	}
+	if m.searchJumpID != 0 {
+		t.Error("pending jump should be cleared")
+	}
+
+	// Hit not loaded yet: fetch the next page and keep waiting
+	m.searchJumpID = 42
+	if cmd := m.applySearchJump(); cmd == nil {
+		t.Error("expected a command to load more replies")
+	}
+	if m.searchJumpID != 42 || !m.loadingMoreReplies {
+		t.Error("jump should stay pending while more replies load")
+	}
+
+	// All replies loaded and the hit is gone: give up
+	m.allRepliesLoaded = true
+	if cmd := m.applySearchJump(); cmd != nil {
+		t.Error("expected no command once all replies are loaded")
+	}
+	if m.searchJumpID != 0 {
+		t.Error("pending jump should be cleared when the hit can't be found")
+	}
+}
@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Password logins ask the server for a resume token, which is kept in the
+// state DB for the server and nickname. When that nickname turns out to be
+// registered after connecting or reconnecting, we send RESUME_SESSION with
+// the token instead of asking for the password. A token the server doesn't
+// accept is forgotten and the password is asked for as before.
+
+// resumeSession logs in with the stored resume token for a nickname, or
+// returns nil if there is none
+func (m *Model) resumeSession(nickname string) tea.Cmd {
+	token, err := m.state.GetResumeToken(m.conn.GetAddress(), nickname)
+	if err != nil || token == "" {
+		return nil
+	}
+
+	m.resumingSession = true
+	m.authTargetNickname = nickname
+	if m.authState != AuthStateAuthenticated {
+		m.authState = AuthStateAuthenticating
+	}
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeResumeSession, &protocol.ResumeSessionMessage{Token: token}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// forgetResumeToken deletes the stored resume token for a nickname
+func (m *Model) forgetResumeToken(nickname string) {
+	if err := m.state.DeleteResumeToken(m.conn.GetAddress(), nickname); err != nil && m.logger != nil {
+		m.logger.Printf("[ERROR] Failed to delete resume token for %s: %v", nickname, err)
+	}
+}
+
+// handleResumeToken processes RESUME_TOKEN, sent after a password login
+func (m Model) handleResumeToken(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ResumeTokenMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode RESUME_TOKEN: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if err := m.state.SaveResumeToken(m.conn.GetAddress(), m.nickname, msg.Token); err != nil && m.logger != nil {
+		m.logger.Printf("[ERROR] Failed to save resume token: %v", err)
+	}
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// sendListSessions asks the server for our logins
+func (m Model) sendListSessions() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeListSessions, &protocol.ListSessionsMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// showSessionsModal lists our logins and lets us log out the other ones
+func (m *Model) showSessionsModal() tea.Cmd {
+	m.modalStack.Push(modal.NewSessionsModal(
+		func() tea.Cmd { return m.sendListSessions() },
+		func(sessionID uint64) tea.Cmd {
+			return func() tea.Msg {
+				if err := m.conn.SendMessage(protocol.TypeRevokeSession, &protocol.RevokeSessionMessage{SessionID: sessionID}); err != nil {
+					return ErrorMsg{Err: err}
+				}
+				return nil
+			}
+		}))
+	return m.sendListSessions()
+}
+
+// findSessionsModal returns the sessions modal if it's open
+func (m Model) findSessionsModal() *modal.SessionsModal {
+	var found *modal.SessionsModal
+	m.modalStack.ForEach(func(md modal.Modal) {
+		if sessionsModal, ok := md.(*modal.SessionsModal); ok {
+			found = sessionsModal
+		}
+	})
+	return found
+}
+
+// handleSessionList processes SESSION_LIST
+func (m Model) handleSessionList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.SessionListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode SESSION_LIST: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if sessionsModal := m.findSessionsModal(); sessionsModal != nil {
+		sessionsModal.SetSessions(msg.Sessions)
+	}
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleSessionRevoked processes SESSION_REVOKED
+func (m Model) handleSessionRevoked(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.SessionRevokedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode SESSION_REVOKED: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if sessionsModal := m.findSessionsModal(); sessionsModal != nil {
+		sessionsModal.RemoveSession(msg.SessionID)
+	}
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setStatus("Logged out the other session"))
+}
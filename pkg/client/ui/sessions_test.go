@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestResumeSession(t *testing.T) {
+	frame := func(t *testing.T, msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+		t.Helper()
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Version: protocol.ProtocolVersion, Type: msgType, Payload: payload}
+	}
+
+	conn := client.NewMockConnection("localhost:6465")
+	state := client.NewMockState()
+	m := NewTestModelWithMocks(conn, state)
+	m.nickname = "alice"
+
+	// The token from a password login is kept for the nickname
+	updated, _ := m.handleResumeToken(frame(t, protocol.TypeResumeToken, &protocol.ResumeTokenMessage{SessionID: 7, Token: "secret"}))
+	m = updated.(Model)
+	if token, _ := state.GetResumeToken("localhost:6465", "alice"); token != "secret" {
+		t.Fatalf("expected the token to be saved, got %q", token)
+	}
+
+	// When the nickname is registered, the token is used instead of asking
+	// for the password
+	updated, _ = m.handleUserInfo(frame(t, protocol.TypeUserInfo, &protocol.UserInfoMessage{Nickname: "alice", IsRegistered: true}))
+	m = updated.(Model)
+	if !m.resumingSession || m.authState != AuthStateAuthenticating {
+		t.Fatalf("expected to be resuming the session, got state %d", m.authState)
+	}
+	if m.modalStack.TopType() == modal.ModalPasswordAuth {
+		t.Fatal("expected no password prompt")
+	}
+	m.resumingSession = false
+	if cmd := m.resumeSession("alice"); cmd == nil || cmd() != nil {
+		t.Fatal("expected RESUME_SESSION to be sent")
+	}
+	sent, err := conn.GetLastSentMessage()
+	if resume, ok := sent.Msg.(*protocol.ResumeSessionMessage); err != nil || sent.Type != protocol.TypeResumeSession || !ok || resume.Token != "secret" {
+		t.Fatalf("expected RESUME_SESSION with the token, got %+v", sent)
+	}
+
+	// A rejected token is forgotten and the password is asked for, without
+	// counting as a failed attempt
+	updated, _ = m.handleAuthResponse(frame(t, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{Message: "Session expired, please log in again"}))
+	m = updated.(Model)
+	if m.resumingSession || m.authAttempts != 0 || m.modalStack.TopType() != modal.ModalPasswordAuth {
+		t.Errorf("expected a password prompt, got attempts %d and modal %v", m.authAttempts, m.modalStack.TopType())
+	}
+	if token, _ := state.GetResumeToken("localhost:6465", "alice"); token != "" {
+		t.Errorf("expected the token to be forgotten, got %q", token)
+	}
+	if m.resumeSession("alice") != nil {
+		t.Error("expected nothing to resume without a token")
+	}
+}
+
+func TestSessionsModal(t *testing.T) {
+	m := NewTestModelWithMocks(client.NewMockConnection("localhost:6465"), client.NewMockState())
+	m.showSessionsModal()
+	if m.modalStack.TopType() != modal.ModalSessions {
+		t.Fatalf("expected the sessions modal, got %v", m.modalStack.TopType())
+	}
+
+	sessions := []protocol.LoginSessionInfo{{SessionID: 7, Label: "192.0.2.1", Current: true}, {SessionID: 9, Label: "192.0.2.2", Online: true}}
+	payload, _ := (&protocol.SessionListMessage{Sessions: sessions}).Encode()
+	updated, _ := m.handleSessionList(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeSessionList, Payload: payload})
+	m = updated.(Model)
+	if view := m.findSessionsModal().Render(120, 40); !strings.Contains(view, "192.0.2.1 (this session)") || !strings.Contains(view, "192.0.2.2 (online)") {
+		t.Fatalf("expected both sessions to be listed, got:\n%s", view)
+	}
+
+	payload, _ = (&protocol.SessionRevokedMessage{SessionID: 9}).Encode()
+	updated, _ = m.handleSessionRevoked(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeSessionRevoked, Payload: payload})
+	m = updated.(Model)
+	if view := m.findSessionsModal().Render(120, 40); strings.Contains(view, "192.0.2.2") {
+		t.Error("expected the revoked session to be gone")
+	}
+	if m.statusMessage != "Logged out the other session" {
+		t.Errorf("expected a status, got %q", m.statusMessage)
+	}
+}
@@ -111,6 +111,9 @@ var (
 	MessageDepthStyle = BaseStyle.Copy().
 				Foreground(MutedColor)
 
+	PlusOneStyle = BaseStyle.Copy().
+			Foreground(WarningColor)
+
 	// Modal styles (exported for view package)
 	// Note: Width sets content width, border (2 chars) is added on top
 	ModalStyle = BaseStyle.Copy().
@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// When an account has an authenticator, the server answers a correct
+// password with an AUTH_RESPONSE challenge instead of logging in, and the
+// password modal asks for the code. Accounts the server requires two-factor
+// authentication for that haven't set it up are asked to enroll first; the
+// login finishes once enrollment is confirmed.
+
+// showTOTPCodeModal asks for the code from the authenticator app
+func (m *Model) showTOTPCodeModal(errorMessage string) {
+	targetNickname := m.authTargetNickname
+	m.modalStack.RemoveByType(modal.ModalPasswordAuth)
+	m.modalStack.Push(modal.NewTOTPCodeModal(
+		targetNickname,
+		errorMessage,
+		func(code string) tea.Cmd {
+			m.authState = AuthStateAuthenticating
+			return m.sendAuthTOTP(code)
+		},
+		func() tea.Cmd {
+			return func() tea.Msg {
+				return GoAnonymousMsg{TargetNickname: targetNickname}
+			}
+		},
+	))
+}
+
+// isTOTPCodeModalOpen returns true if the password modal is asking for a code
+func (m Model) isTOTPCodeModalOpen() bool {
+	open := false
+	m.modalStack.ForEach(func(md modal.Modal) {
+		if passwordModal, ok := md.(*modal.PasswordAuthModal); ok && passwordModal.IsCodePrompt() {
+			open = true
+		}
+	})
+	return open
+}
+
+// sendAuthTOTP answers a two-factor challenge
+func (m Model) sendAuthTOTP(code string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeAuthTOTP, &protocol.AuthTOTPMessage{Code: code}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// showTwoFactorSetupModal starts enrolling an authenticator. During a login
+// the server requires it for, cancelling browses anonymously instead.
+func (m *Model) showTwoFactorSetupModal(duringLogin bool) tea.Cmd {
+	var onCancel func() tea.Cmd
+	if duringLogin {
+		targetNickname := m.authTargetNickname
+		onCancel = func() tea.Cmd {
+			return func() tea.Msg {
+				return GoAnonymousMsg{TargetNickname: targetNickname}
+			}
+		}
+	}
+	m.modalStack.Push(modal.NewTwoFactorSetupModal(
+		func(code string) tea.Cmd {
+			return func() tea.Msg {
+				if err := m.conn.SendMessage(protocol.TypeConfirmTOTP, &protocol.ConfirmTOTPMessage{Code: code}); err != nil {
+					return ErrorMsg{Err: err}
+				}
+				return nil
+			}
+		},
+		onCancel))
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeSetupTOTP, &protocol.SetupTOTPMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// showTwoFactorDisableModal asks for a code to disable two-factor
+// authentication
+func (m *Model) showTwoFactorDisableModal() {
+	m.modalStack.Push(modal.NewTwoFactorDisableModal(func(code string) tea.Cmd {
+		return func() tea.Msg {
+			if err := m.conn.SendMessage(protocol.TypeDisableTOTP, &protocol.DisableTOTPMessage{Code: code}); err != nil {
+				return ErrorMsg{Err: err}
+			}
+			return nil
+		}
+	}))
+}
+
+// findTwoFactorModal returns the two-factor modal if it's open
+func (m Model) findTwoFactorModal() *modal.TwoFactorModal {
+	var found *modal.TwoFactorModal
+	m.modalStack.ForEach(func(md modal.Modal) {
+		if twoFactorModal, ok := md.(*modal.TwoFactorModal); ok {
+			found = twoFactorModal
+		}
+	})
+	return found
+}
+
+// handleTOTPSetup processes TOTP_SETUP
+func (m Model) handleTOTPSetup(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.TOTPSetupMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode TOTP_SETUP: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if twoFactorModal := m.findTwoFactorModal(); twoFactorModal != nil {
+		twoFactorModal.SetSecret(msg.Secret, msg.URI)
+	}
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleTOTPEnabled processes TOTP_ENABLED
+func (m Model) handleTOTPEnabled(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.TOTPEnabledMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode TOTP_ENABLED: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if twoFactorModal := m.findTwoFactorModal(); twoFactorModal != nil {
+		twoFactorModal.SetRecoveryCodes(msg.RecoveryCodes)
+	}
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setStatus("Two-factor authentication enabled"))
+}
+
+// handleTOTPDisabled processes TOTP_DISABLED
+func (m Model) handleTOTPDisabled(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	m.modalStack.RemoveByType(modal.ModalTwoFactor)
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setStatus("Two-factor authentication disabled"))
+}
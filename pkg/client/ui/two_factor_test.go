@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestTwoFactorChallenge(t *testing.T) {
+	frame := func(t *testing.T, msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+		t.Helper()
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return &protocol.Frame{Version: protocol.ProtocolVersion, Type: msgType, Payload: payload}
+	}
+
+	conn := client.NewMockConnection("localhost:6465")
+	m := NewTestModelWithMocks(conn, client.NewMockState())
+	m.authTargetNickname = "alice"
+	m.showPasswordModal()
+
+	// A correct password is answered with a challenge, and the password
+	// modal asks for the code instead
+	updated, _ := m.handleAuthResponse(frame(t, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{Message: "Enter the code from your authenticator app", Challenge: protocol.AuthChallengeTOTP}))
+	m = updated.(Model)
+	if m.modalStack.TopType() != modal.ModalPasswordAuth || !m.isTOTPCodeModalOpen() {
+		t.Fatalf("expected the code prompt, got %v", m.modalStack.TopType())
+	}
+	if m.authAttempts != 0 {
+		t.Errorf("expected the challenge not to count as a failed attempt, got %d", m.authAttempts)
+	}
+	if view := m.modalStack.Top().Render(120, 40); !strings.Contains(view, "authenticator app") || strings.Contains(view, "Enter password") {
+		t.Errorf("expected to be asked for a code, got:\n%s", view)
+	}
+
+	// A wrong code asks again with the error
+	updated, _ = m.handleAuthResponse(frame(t, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{Message: "Invalid code", Challenge: protocol.AuthChallengeTOTP}))
+	m = updated.(Model)
+	if view := m.modalStack.Top().Render(120, 40); !strings.Contains(view, "Invalid code") {
+		t.Errorf("expected the error to be shown, got:\n%s", view)
+	}
+
+	if cmd := m.sendAuthTOTP("123456"); cmd() != nil {
+		t.Fatal("expected AUTH_TOTP to be sent")
+	}
+	sent, err := conn.GetLastSentMessage()
+	if code, ok := sent.Msg.(*protocol.AuthTOTPMessage); err != nil || sent.Type != protocol.TypeAuthTOTP || !ok || code.Code != "123456" {
+		t.Fatalf("expected AUTH_TOTP with the code, got %+v", sent)
+	}
+
+	// The code closes the prompt like a password does
+	updated, _ = m.handleAuthResponse(frame(t, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{Success: true, UserID: 1, Nickname: "alice"}))
+	m = updated.(Model)
+	if m.authState != AuthStateAuthenticated || m.modalStack.TopType() == modal.ModalPasswordAuth {
+		t.Errorf("expected to be logged in, got state %d and modal %v", m.authState, m.modalStack.TopType())
+	}
+}
+
+func TestTwoFactorSetup(t *testing.T) {
+	m := NewTestModelWithMocks(client.NewMockConnection("localhost:6465"), client.NewMockState())
+	m.authTargetNickname = "root"
+	m.showPasswordModal()
+
+	// Accounts the server requires it for are asked to enroll during login
+	payload, _ := (&protocol.AuthResponseMessage{Message: "Set up an authenticator app to continue", Challenge: protocol.AuthChallengeTOTPSetup}).Encode()
+	updated, _ := m.handleAuthResponse(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeAuthResponse, Payload: payload})
+	m = updated.(Model)
+	if m.modalStack.TopType() != modal.ModalTwoFactor {
+		t.Fatalf("expected the two-factor setup modal, got %v", m.modalStack.TopType())
+	}
+
+	payload, _ = (&protocol.TOTPSetupMessage{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/test"}).Encode()
+	updated, _ = m.handleTOTPSetup(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeTOTPSetup, Payload: payload})
+	m = updated.(Model)
+	if view := m.findTwoFactorModal().Render(120, 40); !strings.Contains(view, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("expected the secret to be shown, got:\n%s", view)
+	}
+
+	// Errors from CONFIRM_TOTP are shown in the modal
+	payload, _ = (&protocol.ErrorMessage{ErrorCode: protocol.ErrCodeInvalidInput, Message: "Invalid code"}).Encode()
+	updated, _ = m.handleError(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeError, Payload: payload})
+	m = updated.(Model)
+	if view := m.findTwoFactorModal().Render(120, 40); !strings.Contains(view, "Invalid code") {
+		t.Errorf("expected the error in the modal, got:\n%s", view)
+	}
+
+	payload, _ = (&protocol.TOTPEnabledMessage{RecoveryCodes: []string{"abcde-fghij", "klmno-pqrst"}}).Encode()
+	updated, _ = m.handleTOTPEnabled(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeTOTPEnabled, Payload: payload})
+	m = updated.(Model)
+	if view := m.findTwoFactorModal().Render(120, 40); !strings.Contains(view, "abcde-fghij") || !strings.Contains(view, "klmno-pqrst") {
+		t.Errorf("expected the recovery codes to be shown, got:\n%s", view)
+	}
+	if m.statusMessage != "Two-factor authentication enabled" {
+		t.Errorf("expected a status, got %q", m.statusMessage)
+	}
+}
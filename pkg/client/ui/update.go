@@ -427,6 +427,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.startDMWithUser(msg.UserID, msg.Nickname)
 		return m, nil
 
+	case KeyVerifiedMsg:
+		return m.handleKeyVerified(msg)
+
+	case KeyBackupEncryptedMsg:
+		return m.handleKeyBackupEncrypted(msg)
+
+	case KeysRestoredMsg:
+		return m.handleKeysRestored(msg)
+
+	case SearchResultSelectedMsg:
+		// User opened a search hit - the modal has already closed itself
+		return m.jumpToSearchResult(msg.Result)
+
 	default:
 		// Always update spinner (it manages its own tick messages)
 		var cmd tea.Cmd
@@ -950,6 +963,9 @@ func (m Model) handleChatChannelKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.dmChannels = newDMs
 			// Also remove encryption key if present
 			delete(m.dmChannelKeys, channelID)
+			delete(m.dmRestoredKeys, channelID)
+			delete(m.dmRatchets, channelID)
+			delete(m.deviceDMs, channelID)
 
 			// Return to channel list and send permanent leave
 			m.currentView = ViewChannelList
@@ -1015,15 +1031,11 @@ func (m Model) sendChatMessageWithContent(content string) (Model, tea.Cmd) {
 	}
 
 	channelID := m.currentChannel.ID
-	messageContent := content
 
-	// Check if this channel has encryption enabled
-	if key, ok := m.dmChannelKeys[channelID]; ok {
-		encrypted, err := crypto.EncryptMessage(key, []byte(content))
-		if err != nil {
-			return m, m.setError(fmt.Sprintf("Failed to encrypt message: %v", err))
-		}
-		messageContent = string(encrypted)
+	// Encrypt if this is an encrypted DM or private channel
+	messageContent, err := m.encryptContent(channelID, content)
+	if err != nil {
+		return m, m.setError(fmt.Sprintf("Failed to encrypt message: %v", err))
 	}
 
 	// Send POST_MESSAGE
@@ -1100,6 +1112,8 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m.handleServerList(frame)
 	case protocol.TypeChannelCreated:
 		return m.handleChannelCreated(frame)
+	case protocol.TypeChannelUpdated:
+		return m.handleChannelUpdated(frame)
 	case protocol.TypeSubchannelCreated:
 		return m.handleSubchannelCreated(frame)
 	case protocol.TypeChannelDeleted:
@@ -1140,6 +1154,20 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m.handleIPUnbanned(frame)
 	case protocol.TypeBanList:
 		return m.handleBanList(frame)
+	case protocol.TypeIncomingWebhookCreated:
+		return m.handleIncomingWebhookCreated(frame)
+	case protocol.TypeIncomingWebhookList:
+		return m.handleIncomingWebhookList(frame)
+	case protocol.TypeIncomingWebhookRevoked:
+		return m.handleIncomingWebhookRevoked(frame)
+	case protocol.TypeAdminActionList:
+		return m.handleAdminActionList(frame)
+	case protocol.TypeUserKicked:
+		return m.handleUserKicked(frame)
+	case protocol.TypeUserMuted:
+		return m.handleUserMuted(frame)
+	case protocol.TypeUserUnmuted:
+		return m.handleUserUnmuted(frame)
 	case protocol.TypeUserList:
 		return m.handleUserList(frame)
 	case protocol.TypeUserDeleted:
@@ -1167,6 +1195,46 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m.handleDMParticipantLeft(frame)
 	case protocol.TypeDMDeclined:
 		return m.handleDMDeclined(frame)
+	case protocol.TypeSearchResults:
+		return m.handleSearchResults(frame)
+	case protocol.TypePlusOneUpdate:
+		return m.handlePlusOneUpdate(frame)
+	case protocol.TypeMentionNotification:
+		return m.handleMentionNotification(frame)
+	case protocol.TypeMentionList:
+		return m.handleMentionList(frame)
+	case protocol.TypeMessageHistory:
+		return m.handleMessageHistory(frame)
+	case protocol.TypePrivateChannelList:
+		return m.handlePrivateChannelList(frame)
+	case protocol.TypeChannelInvited:
+		return m.handleChannelInvited(frame)
+	case protocol.TypeRemovedFromChannel:
+		return m.handleRemovedFromChannel(frame)
+	case protocol.TypeChannelMembershipChanged:
+		return m.handleChannelMembershipChanged(frame)
+	case protocol.TypeChannelKeys:
+		return m.handleChannelKeys(frame)
+	case protocol.TypeKeyBackup:
+		return m.handleKeyBackup(frame)
+	case protocol.TypeKeyBackupStored:
+		return m.handleKeyBackupStored(frame)
+	case protocol.TypeChannelRekeyRequired:
+		return m.handleChannelRekeyRequired(frame)
+	case protocol.TypeDeviceRevoked:
+		return m.handleDeviceRevoked(frame)
+	case protocol.TypeResumeToken:
+		return m.handleResumeToken(frame)
+	case protocol.TypeSessionList:
+		return m.handleSessionList(frame)
+	case protocol.TypeSessionRevoked:
+		return m.handleSessionRevoked(frame)
+	case protocol.TypeTOTPSetup:
+		return m.handleTOTPSetup(frame)
+	case protocol.TypeTOTPEnabled:
+		return m.handleTOTPEnabled(frame)
+	case protocol.TypeTOTPDisabled:
+		return m.handleTOTPDisabled(frame)
 	}
 
 	// Continue listening
@@ -1315,19 +1383,25 @@ func (m Model) handleUserInfo(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		m.logger.Printf("[DEBUG] Current nickname=%s, pending=%s", m.nickname, m.pendingNickname)
 	}
 
+	// Remember the user's devices for multi-device DMs
+	m.updateUserDevices(msg)
+
 	// Update our tracking of whether this nickname is registered
 	// Only update if this is info about our current or pending nickname
+	var resumeCmd tea.Cmd
 	if msg.Nickname == m.nickname || msg.Nickname == m.pendingNickname {
 		m.nicknameIsRegistered = msg.IsRegistered
 		if m.logger != nil {
 			m.logger.Printf("[DEBUG] Updated nicknameIsRegistered=%v", m.nicknameIsRegistered)
 		}
 
-		// If this nickname is registered and we're not authenticated, show password modal
-		if msg.IsRegistered && m.authState != AuthStateAuthenticated && !m.directoryMode {
+		// If this nickname is registered and we're not authenticated, log in
+		// with the resume token if we have one, or show password modal
+		if msg.IsRegistered && m.authState != AuthStateAuthenticated && !m.directoryMode && !m.resumingSession {
+			resumeCmd = m.resumeSession(msg.Nickname)
 			// Show password modal if this is our current or pending nickname
 			// (handles race condition where USER_INFO arrives before NICKNAME_RESPONSE)
-			if msg.Nickname == m.nickname || msg.Nickname == m.pendingNickname {
+			if resumeCmd == nil {
 				m.authTargetNickname = msg.Nickname
 				m.authErrorMessage = ""
 				m.authAttempts = 0
@@ -1344,6 +1418,7 @@ func (m Model) handleUserInfo(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(
 		listenForServerFrames(m.conn, m.connGeneration),
 		func() tea.Msg { return ForceRenderMsg{} },
+		resumeCmd,
 	)
 }
 
@@ -1368,6 +1443,8 @@ func (m Model) handleAuthResponse(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	if m.logger != nil {
 		m.logger.Printf("[DEBUG] AUTH_RESPONSE: Success=%v, UserID=%d, Nickname='%s', Message='%s'", msg.Success, msg.UserID, msg.Nickname, msg.Message)
 	}
+	resumed := m.resumingSession
+	m.resumingSession = false
 
 	if msg.Success {
 		// Successfully authenticated
@@ -1404,15 +1481,47 @@ func (m Model) handleAuthResponse(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		m.state.SetUserID(&msg.UserID)
 
 		// Try to load any stored encryption key for this user
-		m.loadEncryptionKey()
+		var keyCmd tea.Cmd
+		if m.loadEncryptionKey() {
+			keyCmd = m.advertiseEncryptionKey()
+		}
 
 		// Close password modal if it's open
 		m.modalStack.RemoveByType(modal.ModalPasswordAuth)
 
-		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
+		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd, keyCmd)
 	}
 
 	m.userFlags = 0
+	// The password was right, but the server wants a two-factor code, or an
+	// authenticator set up, before logging in
+	switch msg.Challenge {
+	case protocol.AuthChallengeTOTP:
+		m.authState = AuthStatePrompting
+		errorMessage := ""
+		if m.isTOTPCodeModalOpen() {
+			errorMessage = msg.Message
+		}
+		m.showTOTPCodeModal(errorMessage)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	case protocol.AuthChallengeTOTPSetup:
+		m.authState = AuthStatePrompting
+		m.modalStack.RemoveByType(modal.ModalPasswordAuth)
+		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setStatus(msg.Message), m.showTwoFactorSetupModal(true))
+	}
+
+	// The resume token wasn't accepted (expired, revoked, or the password
+	// changed), so forget it and ask for the password without counting it as
+	// a failed attempt
+	if resumed {
+		m.forgetResumeToken(m.authTargetNickname)
+		m.authState = AuthStatePrompting
+		m.authErrorMessage = msg.Message
+		m.modalStack.RemoveByType(modal.ModalPasswordAuth)
+		m.showPasswordModal()
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
 	// Authentication failed
 	m.authState = AuthStatePrompting
 	m.authAttempts++
@@ -1477,7 +1586,8 @@ func (m Model) handleChannelList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode channel list: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
 	}
 
-	m.channels = msg.Channels
+	// Keep the private channels, which come from PRIVATE_CHANNEL_LIST
+	m.channels = append(msg.Channels, m.privateChannels...)
 	statusCmd := m.setStatus(fmt.Sprintf("Loaded %d channels", len(m.channels)))
 
 	// Request unread counts for all channels
@@ -1686,6 +1796,38 @@ func (m Model) handleChannelDeleted(frame *protocol.Frame) (tea.Model, tea.Cmd)
 	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
 }
 
+// handleChannelUpdated processes CHANNEL_UPDATED (response + broadcast)
+func (m Model) handleChannelUpdated(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ChannelUpdatedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode channel updated: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if !msg.Success {
+		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setError(msg.Message))
+	}
+
+	apply := func(ch *protocol.Channel) {
+		ch.Description = msg.Description
+		ch.Type = msg.Type
+		ch.RetentionHours = msg.RetentionHours
+		ch.Archived = msg.Archived
+		ch.Modes = msg.Modes
+	}
+	for i := range m.channels {
+		if m.channels[i].ID == msg.ChannelID {
+			apply(&m.channels[i])
+			break
+		}
+	}
+	if m.currentChannel != nil && m.currentChannel.ID == msg.ChannelID {
+		apply(m.currentChannel)
+	}
+
+	// Broadcasts of other people's changes are shown quietly in the channel list
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
 // handleJoinResponse processes JOIN_RESPONSE
 func (m Model) handleJoinResponse(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	msg := &protocol.JoinResponseMessage{}
@@ -1737,20 +1879,8 @@ func (m Model) handleMessageList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	}
 
 	// Decrypt messages if channel has encryption enabled
-	if m.currentChannel != nil {
-		if key, ok := m.dmChannelKeys[m.currentChannel.ID]; ok {
-			for i := range msg.Messages {
-				decrypted, err := crypto.DecryptMessage(key, []byte(msg.Messages[i].Content))
-				if err != nil {
-					if m.logger != nil {
-						m.logger.Printf("[DM] Failed to decrypt message %d: %v", msg.Messages[i].ID, err)
-					}
-					msg.Messages[i].Content = "[Encrypted message - decryption failed]"
-				} else {
-					msg.Messages[i].Content = string(decrypted)
-				}
-			}
-		}
+	for i := range msg.Messages {
+		m.decryptContent(&msg.Messages[i])
 	}
 
 	var statusCmd tea.Cmd
@@ -1852,6 +1982,11 @@ func (m Model) handleMessageList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 
 		// Update viewport to show loaded replies
 		m.threadViewport.SetContent(m.buildThreadContent())
+
+		// Select the search hit we jumped to, loading more replies if needed
+		if jumpCmd := m.applySearchJump(); jumpCmd != nil {
+			return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd, jumpCmd)
+		}
 	}
 
 	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
@@ -1893,18 +2028,7 @@ func (m Model) handleNewMessage(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	newMsg := protocol.Message(*msg)
 
 	// Decrypt content if this channel has encryption enabled
-	if key, ok := m.dmChannelKeys[newMsg.ChannelID]; ok {
-		decrypted, err := crypto.DecryptMessage(key, []byte(newMsg.Content))
-		if err != nil {
-			if m.logger != nil {
-				m.logger.Printf("[DM] Failed to decrypt message %d: %v", newMsg.ID, err)
-			}
-			// Show encrypted indicator instead of garbage
-			newMsg.Content = "[Encrypted message - decryption failed]"
-		} else {
-			newMsg.Content = string(decrypted)
-		}
-	}
+	m.decryptContent(&newMsg)
 
 	// Add to appropriate list
 	if m.currentChannel != nil && newMsg.ChannelID == m.currentChannel.ID {
@@ -2077,6 +2201,16 @@ func (m Model) handleError(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode error: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
 	}
 
+	// Show search, mention list and two-factor errors in their modals
+	switch top := m.modalStack.Top().(type) {
+	case *modal.SearchModal:
+		top.SetError(msg.Message)
+	case *modal.MentionsModal:
+		top.SetError(msg.Message)
+	case *modal.TwoFactorModal:
+		top.SetError(msg.Message)
+	}
+
 	return m, tea.Batch(m.setError(fmt.Sprintf("Error %d: %s", msg.ErrorCode, msg.Message)), listenForServerFrames(m.conn, m.connGeneration))
 }
 
@@ -2225,8 +2359,9 @@ func (m Model) sendAuthRequest(password []byte) tea.Cmd {
 		passwordHash := auth.HashPassword(string(password), targetNickname)
 
 		msg := &protocol.AuthRequestMessage{
-			Nickname: targetNickname,
-			Password: passwordHash,
+			Nickname:     targetNickname,
+			Password:     passwordHash,
+			RequestToken: true,
 		}
 		if err := m.conn.SendMessage(protocol.TypeAuthRequest, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -2302,6 +2437,12 @@ func (m Model) requestChannelList() tea.Cmd {
 		if err := m.conn.SendMessage(protocol.TypeListChannels, msg); err != nil {
 			return ErrorMsg{Err: err}
 		}
+		// Private channels are listed separately, for registered users only
+		if m.userID != nil {
+			if err := m.conn.SendMessage(protocol.TypeListPrivateChannels, &protocol.ListPrivateChannelsMessage{}); err != nil {
+				return ErrorMsg{Err: err}
+			}
+		}
 		return nil
 	}
 }
@@ -2641,16 +2782,11 @@ func (m Model) requestThreadRepliesAfter(threadID uint64, afterID uint64) tea.Cm
 
 func (m Model) sendPostMessage(channelID uint64, parentID *uint64, content string) tea.Cmd {
 	return func() tea.Msg {
-		messageContent := content
-
-		// Check if this channel has encryption enabled
-		if key, ok := m.dmChannelKeys[channelID]; ok {
-			encrypted, err := crypto.EncryptMessage(key, []byte(content))
-			if err != nil {
-				return ErrorMsg{Err: fmt.Errorf("failed to encrypt message: %w", err)}
-			}
-			// Store encrypted bytes as string (will be binary data)
-			messageContent = string(encrypted)
+		// Encrypt if this is an encrypted DM or private channel. Encrypted
+		// bytes are stored as a string (will be binary data)
+		messageContent, err := m.encryptContent(channelID, content)
+		if err != nil {
+			return ErrorMsg{Err: fmt.Errorf("failed to encrypt message: %w", err)}
 		}
 
 		msg := &protocol.PostMessageMessage{
@@ -2789,8 +2925,11 @@ func (m Model) handleReconnected() (tea.Model, tea.Cmd) {
 		cmds = append(cmds, m.sendSetNickname())
 		cmds = append(cmds, m.sendGetUserInfo(m.nickname))
 	} else if m.authState == AuthStateAuthenticated {
-		// Already authenticated (e.g., SSH), just query user info
+		// Already authenticated (e.g., SSH), just query user info. The new
+		// connection starts anonymous, so log in again with the resume token
+		// of a password login.
 		cmds = append(cmds, m.sendGetUserInfo(m.nickname))
+		cmds = append(cmds, m.resumeSession(m.nickname))
 	}
 
 	// Re-request channel list
@@ -2825,6 +2964,11 @@ func (m Model) handleReconnected() (tea.Model, tea.Cmd) {
 
 // handleServerSelected processes server selection from the server selector modal
 func (m Model) handleServerSelected(server protocol.ServerInfo) (tea.Model, tea.Cmd) {
+	if m.remote {
+		m.modalStack.Pop()
+		return m, m.setError("Switching servers is not available over SSH. Connect with the SuperChat client instead.")
+	}
+
 	// Store server info for connection
 	serverAddr := fmt.Sprintf("%s:%d", server.Hostname, server.Port)
 
@@ -2907,6 +3051,7 @@ func (m Model) handleServerSelected(server protocol.ServerInfo) (tea.Model, tea.
 		conn.SetLogger(m.logger)
 		m.logger.Printf("Connecting to server: %s", conn.GetAddress())
 	}
+	conn.SetCertificatePinStore(m.state)
 
 	// Apply bandwidth throttling if requested (using concrete type methods)
 	if m.throttle > 0 {
@@ -3078,6 +3223,8 @@ func (m Model) handleTryDifferentMethod() (tea.Model, tea.Cmd) {
 	switch connType {
 	case "tcp":
 		failedMethod = modal.MethodTCP
+	case "tls":
+		failedMethod = modal.MethodTLS
 	case "ssh":
 		failedMethod = modal.MethodSSH
 	case "websocket":
@@ -3104,6 +3251,10 @@ func (m Model) handleConnectionMethodSelected(msg modal.ConnectionMethodSelected
 	// Close the connection method modal
 	m.modalStack.Pop()
 
+	if m.remote {
+		return m, m.setError("Changing the connection method is not available over SSH")
+	}
+
 	// Set flag to prevent showing "CONNECTION LOST" modal from old connection cleanup
 	m.switchingMethod = true
 
@@ -3124,6 +3275,14 @@ func (m Model) handleConnectionMethodSelected(msg modal.ConnectionMethodSelected
 	switch msg.Method {
 	case modal.MethodTCP:
 		address = "sc://" + rawAddr
+	case modal.MethodTLS:
+		// Strip port from rawAddr for TLS (it will use default 6467)
+		host, _, err := net.SplitHostPort(rawAddr)
+		if err != nil {
+			address = "sc+tls://" + rawAddr
+		} else {
+			address = "sc+tls://" + host
+		}
 	case modal.MethodSSH:
 		// Strip port from rawAddr for SSH (it will use default 6466)
 		host, _, err := net.SplitHostPort(rawAddr)
@@ -3159,6 +3318,7 @@ func (m Model) handleConnectionMethodSelected(msg modal.ConnectionMethodSelected
 	if m.logger != nil {
 		conn.SetLogger(m.logger)
 	}
+	conn.SetCertificatePinStore(m.state)
 
 	// Close the old connection before replacing it
 	// This prevents stray DisconnectedMsg from the old connection
@@ -3388,6 +3548,33 @@ func (m Model) sendListBans(includeExpired bool) tea.Cmd {
 	}
 }
 
+func (m Model) sendCreateIncomingWebhook(msg *protocol.CreateIncomingWebhookMessage) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeCreateIncomingWebhook, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendListIncomingWebhooks() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeListIncomingWebhooks, &protocol.ListIncomingWebhooksMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendRevokeIncomingWebhook(msg *protocol.RevokeIncomingWebhookMessage) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeRevokeIncomingWebhook, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 func (m Model) sendListUsers(includeOffline bool) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.ListUsersMessage{
@@ -3410,6 +3597,8 @@ func (m Model) sendStartDM(targetType uint8, targetUserID uint64, targetNickname
 			TargetUserID:     targetUserID,
 			TargetNickname:   targetNickname,
 			AllowUnencrypted: allowUnencrypted,
+			Ratchet:          true,
+			MultiDevice:      true,
 		}
 		if err := m.conn.SendMessage(protocol.TypeStartDM, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -3421,9 +3610,11 @@ func (m Model) sendStartDM(targetType uint8, targetUserID uint64, targetNickname
 func (m Model) sendProvidePublicKey(keyType uint8, publicKey [32]byte, label string) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.ProvidePublicKeyMessage{
-			KeyType:   keyType,
-			PublicKey: publicKey,
-			Label:     label,
+			KeyType:     keyType,
+			PublicKey:   publicKey,
+			Label:       label,
+			Ratchet:     true,
+			MultiDevice: true,
 		}
 		if err := m.conn.SendMessage(protocol.TypeProvidePublicKey, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -3577,6 +3768,73 @@ func (m Model) handleBanList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	return m, listenForServerFrames(m.conn, m.connGeneration)
 }
 
+// findIncomingWebhooksModal returns the incoming webhooks modal if it's open
+func (m Model) findIncomingWebhooksModal() *modal.IncomingWebhooksModal {
+	var found *modal.IncomingWebhooksModal
+	m.modalStack.ForEach(func(md modal.Modal) {
+		if webhooksModal, ok := md.(*modal.IncomingWebhooksModal); ok {
+			found = webhooksModal
+		}
+	})
+	return found
+}
+
+func (m Model) handleIncomingWebhookCreated(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.IncomingWebhookCreatedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.statusMessage = "" // Clear in-progress status
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode INCOMING_WEBHOOK_CREATED: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if !msg.Success {
+		m.statusMessage = "" // Clear in-progress status
+		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setError(msg.Message))
+	}
+
+	// The token is only sent once, so show it in the webhooks modal
+	cmds := []tea.Cmd{listenForServerFrames(m.conn, m.connGeneration), m.setStatus(msg.Message)}
+	if webhooksModal := m.findIncomingWebhooksModal(); webhooksModal != nil {
+		webhooksModal.SetCreatedToken(msg.Message, msg.Token)
+		cmds = append(cmds, m.sendListIncomingWebhooks())
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m Model) handleIncomingWebhookList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.IncomingWebhookListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode INCOMING_WEBHOOK_LIST: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	if webhooksModal := m.findIncomingWebhooksModal(); webhooksModal != nil {
+		webhooksModal.SetWebhooks(msg.Webhooks)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+func (m Model) handleIncomingWebhookRevoked(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.IncomingWebhookRevokedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.statusMessage = "" // Clear in-progress status
+		return m, tea.Batch(m.setError(fmt.Sprintf("Failed to decode INCOMING_WEBHOOK_REVOKED: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+	}
+
+	cmds := []tea.Cmd{listenForServerFrames(m.conn, m.connGeneration)}
+	if msg.Success {
+		cmds = append(cmds, m.setStatus(msg.Message))
+		if m.findIncomingWebhooksModal() != nil {
+			cmds = append(cmds, m.sendListIncomingWebhooks())
+		}
+	} else {
+		m.statusMessage = "" // Clear in-progress status
+		cmds = append(cmds, m.setError(msg.Message))
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
 func (m Model) handleUserList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	msg := &protocol.UserListMessage{}
 	if err := msg.Decode(frame.Payload); err != nil {
@@ -3715,7 +3973,7 @@ func (m Model) handleServerPresence(frame *protocol.Frame) (tea.Model, tea.Cmd)
 		Nickname:     msg.Nickname,
 		IsRegistered: msg.IsRegistered,
 		UserID:       cloneUint64Ptr(msg.UserID),
-		UserFlags:    msg.UserFlags,
+		UserFlags:    msg.UserFlags &^ protocol.UserFlagModerator, // Moderator is a channel role
 	}
 
 	if msg.Online {
@@ -3783,6 +4041,14 @@ func (m Model) handleKeyRequired(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		m.logger.Printf("[DM] KEY_REQUIRED received: %s (channel: %v)", msg.Reason, msg.DMChannelID)
 	}
 
+	// The SSH terminal has no keys of its own to offer
+	if m.remote {
+		return m, tea.Batch(
+			m.setError("This DM needs encryption, which isn't available over SSH. Use the SuperChat client to join it."),
+			listenForServerFrames(m.conn, m.connGeneration),
+		)
+	}
+
 	// Server is asking us to provide an encryption key
 	// Show the encryption setup modal
 	m.showEncryptionSetupModal(msg.DMChannelID, msg.Reason)
@@ -3797,8 +4063,8 @@ func (m Model) handleDMReady(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	}
 
 	if m.logger != nil {
-		m.logger.Printf("[DM] DM_READY: channel=%d, other=%s, encrypted=%v",
-			msg.ChannelID, msg.OtherNickname, msg.IsEncrypted)
+		m.logger.Printf("[DM] DM_READY: channel=%d, other=%s, encrypted=%v, ratchet=%v, multi_device=%v",
+			msg.ChannelID, msg.OtherNickname, msg.IsEncrypted, msg.Ratchet, msg.MultiDevice)
 	}
 
 	// Add the DM channel to our list
@@ -3810,7 +4076,41 @@ func (m Model) handleDMReady(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		UnreadCount:   0,
 	}
 
-	if msg.IsEncrypted {
+	var devicesCmd tea.Cmd
+	if msg.IsEncrypted && msg.MultiDevice {
+		dmChannel.OtherPubKey = msg.OtherPublicKey[:]
+
+		// Ratchets with each device are loaded as they're needed. Both
+		// users' device lists say which devices to encrypt for.
+		if m.encryptionKeyPriv == nil {
+			return m, tea.Batch(m.setError("Cannot set up encrypted DM: no encryption key available"), listenForServerFrames(m.conn, m.connGeneration))
+		}
+		if _, ok := m.deviceDMs[msg.ChannelID]; !ok {
+			m.deviceDMs[msg.ChannelID] = &deviceDM{
+				otherNickname:  msg.OtherNickname,
+				otherPublicKey: append([]byte(nil), msg.OtherPublicKey[:]...),
+				ratchets:       make(map[string]*crypto.Ratchet),
+			}
+		}
+		delete(m.dmRatchets, msg.ChannelID)
+		delete(m.dmChannelKeys, msg.ChannelID)
+		delete(m.dmRestoredKeys, msg.ChannelID)
+		devicesCmd = m.requestUserDevices(msg.OtherNickname, m.nickname)
+	} else if msg.IsEncrypted && msg.Ratchet {
+		dmChannel.OtherPubKey = msg.OtherPublicKey[:]
+
+		// Pick up the DM's ratchet where we left off
+		if m.encryptionKeyPriv == nil {
+			return m, tea.Batch(m.setError("Cannot set up encrypted DM: no encryption key available"), listenForServerFrames(m.conn, m.connGeneration))
+		}
+		r, err := m.loadRatchet(msg.ChannelID, msg.OtherPublicKey[:])
+		if err != nil {
+			return m, tea.Batch(m.setError(fmt.Sprintf("Failed to start DM ratchet: %v", err)), listenForServerFrames(m.conn, m.connGeneration))
+		}
+		m.dmRatchets[msg.ChannelID] = r
+		delete(m.dmChannelKeys, msg.ChannelID)
+		delete(m.dmRestoredKeys, msg.ChannelID)
+	} else if msg.IsEncrypted {
 		dmChannel.OtherPubKey = msg.OtherPublicKey[:]
 
 		// Derive the channel encryption key
@@ -3826,6 +4126,7 @@ func (m Model) handleDMReady(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 			}
 
 			m.dmChannelKeys[msg.ChannelID] = channelKey
+			m.dmRestoredKeys[msg.ChannelID] = m.deriveRestoredDMKeys(msg.ChannelID, msg.OtherPublicKey[:])
 			if m.logger != nil {
 				m.logger.Printf("[DM] Derived channel key for channel %d", msg.ChannelID)
 			}
@@ -3834,6 +4135,12 @@ func (m Model) handleDMReady(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	// Show the safety number and warn if the other party's key changed
+	var keyCmd tea.Cmd
+	if msg.IsEncrypted {
+		keyCmd = m.checkContactKey(&dmChannel)
+	}
+
 	// Check if this DM already exists, update if so
 	found := false
 	for i, existing := range m.dmChannels {
@@ -3867,7 +4174,7 @@ func (m Model) handleDMReady(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 
 	statusCmd := m.setStatus(fmt.Sprintf("DM with %s is ready", msg.OtherNickname))
 
-	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd)
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), statusCmd, keyCmd, devicesCmd)
 }
 
 func (m Model) handleDMPending(frame *protocol.Frame) (tea.Model, tea.Cmd) {
@@ -4075,16 +4382,18 @@ func (m *Model) generateAndSendEncryptionKey(dmChannelID *uint64) tea.Cmd {
 		// Determine key type based on user registration status
 		// Anonymous users can only use ephemeral (session-only) keys
 		var keyType uint8 = protocol.KeyTypeGenerated
-		label := "generated"
+		label := deviceLabel()
 		if m.userID == nil {
 			keyType = protocol.KeyTypeEphemeral
 			label = "ephemeral"
 		}
 
 		msg := &protocol.ProvidePublicKeyMessage{
-			KeyType:   keyType,
-			PublicKey: kp.PublicKey,
-			Label:     label,
+			KeyType:     keyType,
+			PublicKey:   kp.PublicKey,
+			Label:       label,
+			Ratchet:     true,
+			MultiDevice: true,
 		}
 		if err := m.conn.SendMessage(protocol.TypeProvidePublicKey, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -4150,6 +4459,12 @@ func (m Model) sendDesktopNotification(msg protocol.Message) {
 
 	body := fmt.Sprintf("%s: %s", msg.AuthorNickname, content)
 
+	// A desktop notification would show up on the server
+	if m.remote {
+		writeTerminalNotification(m.terminalOut, title, body)
+		return
+	}
+
 	// Send notification (best-effort, don't fail if it doesn't work)
 	err := beeep.Notify(title, body, m.notificationIconPath)
 	if err != nil && m.logger != nil {
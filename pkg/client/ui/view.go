@@ -470,6 +470,9 @@ func (m Model) renderHeader() string {
 		if m.onlineUsers > 0 {
 			status += fmt.Sprintf("  %d users", m.onlineUsers)
 		}
+		if m.unreadMentions > 0 {
+			status += lipgloss.NewStyle().Foreground(WarningColor).Render(fmt.Sprintf("  @%d", m.unreadMentions))
+		}
 
 		// Add traffic counter
 		sent := client.FormatBytes(m.conn.GetBytesSent())
@@ -755,6 +758,18 @@ func (m Model) buildChannelPaneContentString(availableWidth int) string {
 				rightIndicators = append(rightIndicators, MutedTextStyle.Render(subCountStr))
 			}
 
+			if m.isPrivateChannel(channel.ID) {
+				rightIndicators = append(rightIndicators, MutedTextStyle.Render("private"))
+			}
+
+			if channel.Archived {
+				rightIndicators = append(rightIndicators, MutedTextStyle.Render("archived"))
+			}
+
+			if modes := channel.Modes.String(); modes != "" {
+				rightIndicators = append(rightIndicators, MutedTextStyle.Render(modes))
+			}
+
 			// Get unread count for this channel
 			unreadCount := m.unreadCounts[channel.ID]
 			if unreadCount > 0 {
@@ -1011,6 +1026,9 @@ func (m Model) formatThreadItem(thread protocol.Message) string {
 	}
 	authorRendered := authorStyle.Render(author)
 	metadataRendered := MessageTimeStyle.Render(timeStr) + MutedTextStyle.Render(replyCount)
+	if plusOnes := client.FormatPlusOnes(thread.PlusOneCount, thread.PlusOneAnonCount); plusOnes != "" {
+		metadataRendered += "  " + PlusOneStyle.Render(plusOnes)
+	}
 
 	// Use lipgloss.Width to get actual rendered width (accounting for ANSI codes)
 	authorWidth := lipgloss.Width(authorRendered)
@@ -1072,6 +1090,12 @@ func (m Model) formatMessage(msg protocol.Message, depth int, selected bool) str
 		editedIndicator = "  " + MessageTimeStyle.Render("(edited)")
 	}
 
+	// Add +1 counts
+	plusOneIndicator := ""
+	if plusOnes := client.FormatPlusOnes(msg.PlusOneCount, msg.PlusOneAnonCount); plusOnes != "" {
+		plusOneIndicator = "  " + PlusOneStyle.Render(plusOnes)
+	}
+
 	// Add NEW indicator if message is unread
 	newIndicator := ""
 	if m.newMessageIDs[msg.ID] {
@@ -1084,7 +1108,7 @@ func (m Model) formatMessage(msg protocol.Message, depth int, selected bool) str
 		depthIndicator = "  " + MessageDepthStyle.Render(fmt.Sprintf("[%d]", depth))
 	}
 
-	header := author + "  " + timestamp + editedIndicator + newIndicator + depthIndicator
+	header := author + "  " + timestamp + editedIndicator + plusOneIndicator + newIndicator + depthIndicator
 
 	// Calculate available width for content (viewport width minus borders, padding, indent, and indicator)
 	// Viewport width = m.width - 2 (border)
@@ -1226,8 +1250,17 @@ func (m Model) renderChatContent() string {
 	contentHeight := m.height - 2
 	layout := flexbox.New(m.width, contentHeight)
 
-	// Build message area content
+	// Build message area content, with the safety number above encrypted DMs
 	messageContent := m.chatViewport.View()
+	if dmHeader := m.renderDMHeader(); dmHeader != "" {
+		chatViewport := m.chatViewport
+		atBottom := chatViewport.AtBottom()
+		chatViewport.Height--
+		if atBottom {
+			chatViewport.GotoBottom()
+		}
+		messageContent = lipgloss.NewStyle().MaxWidth(m.chatViewport.Width).Render(dmHeader) + "\n" + chatViewport.View()
+	}
 
 	// Build input field content
 	inputContent := m.buildChatInputField()
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStaleChannelKeyEpoch is returned when a channel key is saved for an
+// epoch other than the one after the channel's current epoch
+var ErrStaleChannelKeyEpoch = errors.New("channel key epoch is not the next epoch")
+
+// ChannelKey is a private channel key wrapped for one member. The server
+// can't unwrap it; only the member's X25519 private key can.
+type ChannelKey struct {
+	ChannelID  int64
+	Epoch      uint32
+	UserID     int64
+	PublicKey  []byte // The member's X25519 public key the key was wrapped for
+	WrappedKey []byte
+	CreatedBy  string // Nickname of the member who distributed the key
+	CreatedAt  int64  // Unix timestamp in milliseconds
+}
+
+func scanChannelKey(row rowScanner) (*ChannelKey, error) {
+	key := &ChannelKey{}
+	if err := row.Scan(&key.ChannelID, &key.Epoch, &key.UserID, &key.PublicKey, &key.WrappedKey, &key.CreatedBy, &key.CreatedAt); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SaveChannelKeys stores a new epoch of wrapped keys for a private channel.
+// The epoch must follow the channel's current epoch, so two members rekeying
+// at once can't both win.
+func (db *DB) SaveChannelKeys(channelID int64, epoch uint32, createdBy string, keys []*ChannelKey) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current uint32
+	if err := tx.QueryRow(`
+		SELECT COALESCE(MAX(epoch), 0) FROM ChannelKey WHERE channel_id = ?
+	`, channelID).Scan(&current); err != nil {
+		return err
+	}
+	if epoch != current+1 {
+		return ErrStaleChannelKeyEpoch
+	}
+
+	now := nowMillis()
+	for _, key := range keys {
+		if _, err := tx.Exec(`
+			INSERT INTO ChannelKey (channel_id, epoch, user_id, public_key, wrapped_key, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, channelID, epoch, key.UserID, key.PublicKey, key.WrappedKey, createdBy, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetChannelKeyEpoch returns a private channel's current key epoch (0 if no
+// key has been distributed yet)
+func (db *DB) GetChannelKeyEpoch(channelID int64) (uint32, error) {
+	var epoch uint32
+	err := db.conn.QueryRow(`
+		SELECT COALESCE(MAX(epoch), 0) FROM ChannelKey WHERE channel_id = ?
+	`, channelID).Scan(&epoch)
+	return epoch, err
+}
+
+// ListChannelKeysForUser returns the keys of a private channel wrapped for a
+// user, oldest epoch first
+func (db *DB) ListChannelKeysForUser(channelID, userID int64) ([]*ChannelKey, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id, epoch, user_id, public_key, wrapped_key, created_by, created_at
+		FROM ChannelKey
+		WHERE channel_id = ? AND user_id = ?
+		ORDER BY epoch
+	`, channelID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*ChannelKey{}
+	for rows.Next() {
+		key, err := scanChannelKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ListChannelKeyRecipients returns the wrapped keys of one epoch of a private
+// channel, one per member it was distributed to
+func (db *DB) ListChannelKeyRecipients(channelID int64, epoch uint32) ([]*ChannelKey, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id, epoch, user_id, public_key, wrapped_key, created_by, created_at
+		FROM ChannelKey
+		WHERE channel_id = ? AND epoch = ?
+		ORDER BY user_id
+	`, channelID, epoch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*ChannelKey{}
+	for rows.Next() {
+		key, err := scanChannelKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Wrapped keys are only read when members connect or rekey, so MemDB doesn't
+// cache them.
+
+func (m *MemDB) SaveChannelKeys(channelID int64, epoch uint32, createdBy string, keys []*ChannelKey) error {
+	return m.sqliteDB.SaveChannelKeys(channelID, epoch, createdBy, keys)
+}
+
+func (m *MemDB) GetChannelKeyEpoch(channelID int64) (uint32, error) {
+	return m.sqliteDB.GetChannelKeyEpoch(channelID)
+}
+
+func (m *MemDB) ListChannelKeysForUser(channelID, userID int64) ([]*ChannelKey, error) {
+	return m.sqliteDB.ListChannelKeysForUser(channelID, userID)
+}
+
+func (m *MemDB) ListChannelKeyRecipients(channelID int64, epoch uint32) ([]*ChannelKey, error) {
+	return m.sqliteDB.ListChannelKeyRecipients(channelID, epoch)
+}
+
+// SaveChannelKeys stores a new epoch of wrapped keys for a private channel.
+// The epoch must follow the channel's current epoch, so two members rekeying
+// at once can't both win.
+func (db *PostgresDB) SaveChannelKeys(channelID int64, epoch uint32, createdBy string, keys []*ChannelKey) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise rekeys of the same channel
+	if _, err := tx.Exec(`SELECT id FROM Channel WHERE id = $1 FOR UPDATE`, channelID); err != nil {
+		return err
+	}
+
+	var current uint32
+	if err := tx.QueryRow(`
+		SELECT COALESCE(MAX(epoch), 0) FROM ChannelKey WHERE channel_id = $1
+	`, channelID).Scan(&current); err != nil {
+		return err
+	}
+	if epoch != current+1 {
+		return ErrStaleChannelKeyEpoch
+	}
+
+	now := nowMillis()
+	for _, key := range keys {
+		if _, err := tx.Exec(`
+			INSERT INTO ChannelKey (channel_id, epoch, user_id, public_key, wrapped_key, created_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, channelID, epoch, key.UserID, key.PublicKey, key.WrappedKey, createdBy, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetChannelKeyEpoch returns a private channel's current key epoch (0 if no
+// key has been distributed yet)
+func (db *PostgresDB) GetChannelKeyEpoch(channelID int64) (uint32, error) {
+	var epoch uint32
+	err := db.conn.QueryRow(`
+		SELECT COALESCE(MAX(epoch), 0) FROM ChannelKey WHERE channel_id = $1
+	`, channelID).Scan(&epoch)
+	return epoch, err
+}
+
+// ListChannelKeysForUser returns the keys of a private channel wrapped for a
+// user, oldest epoch first
+func (db *PostgresDB) ListChannelKeysForUser(channelID, userID int64) ([]*ChannelKey, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id, epoch, user_id, public_key, wrapped_key, created_by, created_at
+		FROM ChannelKey
+		WHERE channel_id = $1 AND user_id = $2
+		ORDER BY epoch
+	`, channelID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*ChannelKey{}
+	for rows.Next() {
+		key, err := scanChannelKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ListChannelKeyRecipients returns the wrapped keys of one epoch of a private
+// channel, one per member it was distributed to
+func (db *PostgresDB) ListChannelKeyRecipients(channelID int64, epoch uint32) ([]*ChannelKey, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id, epoch, user_id, public_key, wrapped_key, created_by, created_at
+		FROM ChannelKey
+		WHERE channel_id = $1 AND epoch = $2
+		ORDER BY user_id
+	`, channelID, epoch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*ChannelKey{}
+	for rows.Next() {
+		key, err := scanChannelKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
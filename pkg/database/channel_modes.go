@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Channel grants. Invites let users into invite-only channels; voice lets
+// them post in moderated channels.
+const (
+	ChannelGrantInvite = "invite"
+	ChannelGrantVoice  = "voice"
+)
+
+// SetChannelModes replaces a channel's mode bitfield
+func (db *DB) SetChannelModes(channelID int64, modes uint8) error {
+	_, err := db.writeConn.Exec(`UPDATE Channel SET modes = ? WHERE id = ?`, modes, channelID)
+	return err
+}
+
+// HasChannelGrant reports whether a user holds a grant in a channel
+func (db *DB) HasChannelGrant(channelID, userID int64, kind string) (bool, error) {
+	var exists int
+	err := db.conn.QueryRow(`
+		SELECT 1 FROM ChannelGrant WHERE channel_id = ? AND user_id = ? AND kind = ?
+	`, channelID, userID, kind).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetChannelGrant gives (granted = true) or takes away a user's grant in a channel
+func (db *DB) SetChannelGrant(channelID, userID int64, kind string, granted bool, grantedBy string) error {
+	if !granted {
+		_, err := db.writeConn.Exec(`
+			DELETE FROM ChannelGrant WHERE channel_id = ? AND user_id = ? AND kind = ?
+		`, channelID, userID, kind)
+		return err
+	}
+	_, err := db.writeConn.Exec(`
+		INSERT INTO ChannelGrant (channel_id, user_id, kind, granted_by, granted_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (channel_id, user_id, kind) DO NOTHING
+	`, channelID, userID, kind, grantedBy, nowMillis())
+	return err
+}
+
+// SetChannelModes updates a channel's modes in SQLite and the cache
+func (m *MemDB) SetChannelModes(channelID int64, modes uint8) error {
+	if err := m.sqliteDB.SetChannelModes(channelID, modes); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if ch, exists := m.channels[channelID]; exists {
+		// Replace rather than mutate, GetChannel copies are taken without the lock held
+		updated := *ch
+		updated.Modes = modes
+		m.channels[channelID] = &updated
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Grants are looked up only for channels with modes set, so MemDB doesn't cache them.
+
+func (m *MemDB) HasChannelGrant(channelID, userID int64, kind string) (bool, error) {
+	return m.sqliteDB.HasChannelGrant(channelID, userID, kind)
+}
+
+func (m *MemDB) SetChannelGrant(channelID, userID int64, kind string, granted bool, grantedBy string) error {
+	return m.sqliteDB.SetChannelGrant(channelID, userID, kind, granted, grantedBy)
+}
+
+// SetChannelModes replaces a channel's mode bitfield
+func (db *PostgresDB) SetChannelModes(channelID int64, modes uint8) error {
+	_, err := db.conn.Exec(`UPDATE Channel SET modes = $1 WHERE id = $2`, modes, channelID)
+	return err
+}
+
+// HasChannelGrant reports whether a user holds a grant in a channel
+func (db *PostgresDB) HasChannelGrant(channelID, userID int64, kind string) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM ChannelGrant WHERE channel_id = $1 AND user_id = $2 AND kind = $3)
+	`, channelID, userID, kind).Scan(&exists)
+	return exists, err
+}
+
+// SetChannelGrant gives (granted = true) or takes away a user's grant in a channel
+func (db *PostgresDB) SetChannelGrant(channelID, userID int64, kind string, granted bool, grantedBy string) error {
+	if !granted {
+		_, err := db.conn.Exec(`
+			DELETE FROM ChannelGrant WHERE channel_id = $1 AND user_id = $2 AND kind = $3
+		`, channelID, userID, kind)
+		return err
+	}
+	_, err := db.conn.Exec(`
+		INSERT INTO ChannelGrant (channel_id, user_id, kind, granted_by, granted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (channel_id, user_id, kind) DO NOTHING
+	`, channelID, userID, kind, grantedBy, nowMillis())
+	return err
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Channel roles. Users without a stored role are members.
+const (
+	ChannelRoleMember    = ""
+	ChannelRoleModerator = "moderator"
+	ChannelRoleOwner     = "owner"
+)
+
+// ChannelRole is an owner or moderator of a top-level channel
+type ChannelRole struct {
+	ChannelID int64
+	UserID    int64
+	Nickname  string // Current nickname of the user
+	Role      string // ChannelRoleOwner or ChannelRoleModerator
+	GrantedBy string // Nickname of who granted the role (empty for channel creators)
+	GrantedAt int64  // Unix timestamp in milliseconds
+}
+
+func scanChannelRole(row rowScanner) (*ChannelRole, error) {
+	role := &ChannelRole{}
+	if err := row.Scan(&role.ChannelID, &role.UserID, &role.Nickname, &role.Role, &role.GrantedBy, &role.GrantedAt); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// GetChannelRole returns the user's role in a channel (ChannelRoleMember if none)
+func (db *DB) GetChannelRole(channelID, userID int64) (string, error) {
+	var role string
+	err := db.conn.QueryRow(`
+		SELECT role FROM ChannelRole WHERE channel_id = ? AND user_id = ?
+	`, channelID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChannelRoleMember, nil
+	}
+	return role, err
+}
+
+// SetChannelRole gives a user a role in a channel. ChannelRoleMember removes
+// their role.
+func (db *DB) SetChannelRole(channelID, userID int64, role, grantedBy string) error {
+	if role == ChannelRoleMember {
+		_, err := db.writeConn.Exec(`DELETE FROM ChannelRole WHERE channel_id = ? AND user_id = ?`, channelID, userID)
+		return err
+	}
+	_, err := db.writeConn.Exec(`
+		INSERT INTO ChannelRole (channel_id, user_id, role, granted_by, granted_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (channel_id, user_id) DO UPDATE SET
+			role = excluded.role, granted_by = excluded.granted_by, granted_at = excluded.granted_at
+	`, channelID, userID, role, grantedBy, nowMillis())
+	return err
+}
+
+// HasChannelRole reports whether a user owns or moderates any channel
+func (db *DB) HasChannelRole(userID int64) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM ChannelRole WHERE user_id = ?)
+	`, userID).Scan(&exists)
+	return exists, err
+}
+
+// ListChannelRoles returns the owners and moderators of a channel, owners first
+func (db *DB) ListChannelRoles(channelID int64) ([]*ChannelRole, error) {
+	rows, err := db.conn.Query(`
+		SELECT r.channel_id, r.user_id, u.nickname, r.role, r.granted_by, r.granted_at
+		FROM ChannelRole r
+		JOIN User u ON u.id = r.user_id
+		WHERE r.channel_id = ?
+		ORDER BY r.role = 'owner' DESC, u.nickname COLLATE NOCASE
+	`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*ChannelRole{}
+	for rows.Next() {
+		role, err := scanChannelRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// Channel roles are small and change rarely, so MemDB doesn't cache them.
+
+func (m *MemDB) GetChannelRole(channelID, userID int64) (string, error) {
+	return m.sqliteDB.GetChannelRole(channelID, userID)
+}
+
+func (m *MemDB) SetChannelRole(channelID, userID int64, role, grantedBy string) error {
+	return m.sqliteDB.SetChannelRole(channelID, userID, role, grantedBy)
+}
+
+func (m *MemDB) HasChannelRole(userID int64) (bool, error) {
+	return m.sqliteDB.HasChannelRole(userID)
+}
+
+func (m *MemDB) ListChannelRoles(channelID int64) ([]*ChannelRole, error) {
+	return m.sqliteDB.ListChannelRoles(channelID)
+}
+
+// GetChannelRole returns the user's role in a channel (ChannelRoleMember if none)
+func (db *PostgresDB) GetChannelRole(channelID, userID int64) (string, error) {
+	var role string
+	err := db.conn.QueryRow(`
+		SELECT role FROM ChannelRole WHERE channel_id = $1 AND user_id = $2
+	`, channelID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChannelRoleMember, nil
+	}
+	return role, err
+}
+
+// SetChannelRole gives a user a role in a channel. ChannelRoleMember removes
+// their role.
+func (db *PostgresDB) SetChannelRole(channelID, userID int64, role, grantedBy string) error {
+	if role == ChannelRoleMember {
+		_, err := db.conn.Exec(`DELETE FROM ChannelRole WHERE channel_id = $1 AND user_id = $2`, channelID, userID)
+		return err
+	}
+	_, err := db.conn.Exec(`
+		INSERT INTO ChannelRole (channel_id, user_id, role, granted_by, granted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (channel_id, user_id) DO UPDATE SET
+			role = EXCLUDED.role, granted_by = EXCLUDED.granted_by, granted_at = EXCLUDED.granted_at
+	`, channelID, userID, role, grantedBy, nowMillis())
+	return err
+}
+
+// HasChannelRole reports whether a user owns or moderates any channel
+func (db *PostgresDB) HasChannelRole(userID int64) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM ChannelRole WHERE user_id = $1)`, userID).Scan(&exists)
+	return exists, err
+}
+
+// ListChannelRoles returns the owners and moderators of a channel, owners first
+func (db *PostgresDB) ListChannelRoles(channelID int64) ([]*ChannelRole, error) {
+	rows, err := db.conn.Query(`
+		SELECT r.channel_id, r.user_id, u.nickname, r.role, r.granted_by, r.granted_at
+		FROM ChannelRole r
+		JOIN "User" u ON u.id = r.user_id
+		WHERE r.channel_id = $1
+		ORDER BY (r.role = 'owner') DESC, LOWER(u.nickname)
+	`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*ChannelRole{}
+	for rows.Next() {
+		role, err := scanChannelRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
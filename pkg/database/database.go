@@ -27,6 +27,7 @@ type DB struct {
 	writeConn   *sql.DB // Dedicated write connection (1 connection)
 	snowflake   *Snowflake
 	WriteBuffer *WriteBuffer
+	path        string // Database file path, used to place the MemDB write-ahead log
 }
 
 // Open opens a connection to the SQLite database at the given path
@@ -118,6 +119,7 @@ func Open(path string) (*DB, error) {
 		conn:      conn,
 		writeConn: writeConn,
 		snowflake: snowflake,
+		path:      path,
 	}
 
 	// Run migrations first (before schema init)
@@ -227,6 +229,8 @@ type Channel struct {
 	IsPrivate             bool
 	ParentID              *int64 // V3: NULL for top-level channels, populated for subchannels
 	IsDM                  bool   // V3: true for direct message channels
+	Archived              bool   // V4: read-only and exempt from retention cleanup
+	Modes                 uint8  // V4: protocol.ChannelModes bitfield (moderated, invite-only, ...)
 }
 
 // Session represents an active connection
@@ -269,7 +273,8 @@ type ChannelAccess struct {
 	CreatedAt int64 // Unix timestamp in milliseconds
 }
 
-// ChannelParticipant tracks DM channel membership for both registered and anonymous users
+// ChannelParticipant tracks DM channel membership for both registered and anonymous users,
+// and private channel membership for registered users
 type ChannelParticipant struct {
 	ID        int64
 	ChannelID int64
@@ -304,6 +309,10 @@ type Message struct {
 	EditedAt       *int64
 	DeletedAt      *int64
 	ReplyCount     atomic.Uint32 // Cached reply count (in-memory only, not persisted to SQLite)
+
+	// V4: +1 reactions. Counts only go up.
+	PlusOneCount     uint32 // Registered users, one per user (deduped via UserPlusOne)
+	PlusOneAnonCount uint32 // Anonymous users, unlimited
 }
 
 // MessageVersion represents a version history entry
@@ -321,22 +330,23 @@ func nowMillis() int64 {
 	return time.Now().UnixMilli()
 }
 
+// defaultChannels are created on first start by SeedDefaultChannels
+var defaultChannels = []struct {
+	name           string
+	displayName    string
+	description    string
+	channelType    uint8  // 0=chat, 1=forum
+	retentionHours uint32 // hours
+}{
+	{"chat", ">chat", "General chat (linear conversation)", 0, 24},        // Chat channel, 24h retention
+	{"general", "#general", "General discussion", 1, 168},                 // Forum, 7 days
+	{"tech", "#tech", "Technical topics", 1, 168},                         // Forum, 7 days
+	{"random", "#random", "Off-topic chat", 1, 168},                       // Forum, 7 days
+	{"feedback", "#feedback", "Bug reports and feature requests", 1, 168}, // Forum, 7 days
+}
+
 // SeedDefaultChannels creates the default channels if they don't exist
 func (db *DB) SeedDefaultChannels() error {
-	defaultChannels := []struct {
-		name           string
-		displayName    string
-		description    string
-		channelType    uint8  // 0=chat, 1=forum
-		retentionHours uint32 // hours
-	}{
-		{"chat", ">chat", "General chat (linear conversation)", 0, 24},        // Chat channel, 24h retention
-		{"general", "#general", "General discussion", 1, 168},                  // Forum, 7 days
-		{"tech", "#tech", "Technical topics", 1, 168},                          // Forum, 7 days
-		{"random", "#random", "Off-topic chat", 1, 168},                        // Forum, 7 days
-		{"feedback", "#feedback", "Bug reports and feature requests", 1, 168}, // Forum, 7 days
-	}
-
 	for _, ch := range defaultChannels {
 		if _, err := db.CreateChannel(ch.name, ch.displayName, &ch.description, ch.channelType, ch.retentionHours, nil); err != nil {
 			return fmt.Errorf("failed to seed channel %s: %w", ch.name, err)
@@ -376,16 +386,33 @@ func (db *DB) CreateChannel(name, displayName string, description *string, chann
 		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
 	}
 
+	// The creator owns the channel
+	if inserted, err := result.RowsAffected(); err == nil && inserted > 0 && createdBy != nil {
+		if err := db.SetChannelRole(channelID, *createdBy, ChannelRoleOwner, ""); err != nil {
+			return 0, fmt.Errorf("failed to set channel owner: %w", err)
+		}
+	}
+
 	elapsed := time.Since(start)
 	log.Printf("DB: CreateChannel took %v", elapsed)
 
 	return channelID, nil
 }
 
+// CountChannels returns the number of public channels
+func (db *DB) CountChannels() uint32 {
+	var count uint32
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM Channel WHERE is_private = 0`).Scan(&count); err != nil {
+		log.Printf("DB: CountChannels failed: %v", err)
+		return 0
+	}
+	return count
+}
+
 // ListChannels returns all public top-level channels (not subchannels, not DMs)
 func (db *DB) ListChannels() ([]*Channel, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, parent_id, is_dm
+		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, parent_id, is_dm, archived, modes
 		FROM Channel
 		WHERE is_private = 0 AND parent_id IS NULL AND is_dm = 0
 		ORDER BY name ASC
@@ -414,6 +441,8 @@ func (db *DB) ListChannels() ([]*Channel, error) {
 			&ch.IsPrivate,
 			&parentID,
 			&ch.IsDM,
+			&ch.Archived,
+			&ch.Modes,
 		)
 		if err != nil {
 			return nil, err
@@ -443,7 +472,7 @@ func (db *DB) GetChannel(id int64) (*Channel, error) {
 	var parentID sql.NullInt64
 
 	err := db.conn.QueryRow(`
-		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, parent_id, is_dm
+		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, parent_id, is_dm, archived, modes
 		FROM Channel
 		WHERE id = ?
 	`, id).Scan(
@@ -458,6 +487,8 @@ func (db *DB) GetChannel(id int64) (*Channel, error) {
 		&ch.IsPrivate,
 		&parentID,
 		&ch.IsDM,
+		&ch.Archived,
+		&ch.Modes,
 	)
 
 	if err != nil {
@@ -515,7 +546,7 @@ func (db *DB) CreateSubchannel(parentID int64, name, displayName string, descrip
 // GetSubchannels returns all subchannels for a given parent channel
 func (db *DB) GetSubchannels(parentID int64) ([]*Channel, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, parent_id, is_dm
+		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, parent_id, is_dm, archived, modes
 		FROM Channel
 		WHERE parent_id = ?
 		ORDER BY name ASC
@@ -544,6 +575,8 @@ func (db *DB) GetSubchannels(parentID int64) ([]*Channel, error) {
 			&ch.IsPrivate,
 			&parentIDVal,
 			&ch.IsDM,
+			&ch.Archived,
+			&ch.Modes,
 		)
 		if err != nil {
 			return nil, err
@@ -651,19 +684,36 @@ func (db *DB) DeleteSession(sessionID int64) error {
 }
 
 // PostMessage creates a new message and its initial version
-func (db *DB) PostMessage(channelID int64, subchannelID, parentID, authorUserID *int64, authorNickname, content string) (int64, error) {
+func (db *DB) PostMessage(channelID int64, subchannelID, parentID, authorUserID *int64, authorNickname, content string) (int64, *Message, error) {
 	// Begin transaction
 	tx, err := db.conn.Begin()
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	defer tx.Rollback()
 
 	// Generate Snowflake ID
 	messageID := db.snowflake.NextID()
 
+	// Replies inherit the parent's thread root; top-level messages are their own root
+	threadRootID := &messageID
+	if parentID != nil {
+		var parentRoot sql.NullInt64
+		err := tx.QueryRow(`SELECT thread_root_id FROM Message WHERE id = ?`, *parentID).Scan(&parentRoot)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil, fmt.Errorf("parent message not found")
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		threadRootID = nil
+		if parentRoot.Valid {
+			threadRootID = &parentRoot.Int64
+		}
+	}
+
 	// Insert message
-	var subchannelIDVal, parentIDVal, authorUserIDVal sql.NullInt64
+	var subchannelIDVal, parentIDVal, threadRootIDVal, authorUserIDVal sql.NullInt64
 	if subchannelID != nil {
 		subchannelIDVal.Valid = true
 		subchannelIDVal.Int64 = *subchannelID
@@ -672,6 +722,10 @@ func (db *DB) PostMessage(channelID int64, subchannelID, parentID, authorUserID
 		parentIDVal.Valid = true
 		parentIDVal.Int64 = *parentID
 	}
+	if threadRootID != nil {
+		threadRootIDVal.Valid = true
+		threadRootIDVal.Int64 = *threadRootID
+	}
 	if authorUserID != nil {
 		authorUserIDVal.Valid = true
 		authorUserIDVal.Int64 = *authorUserID
@@ -679,12 +733,12 @@ func (db *DB) PostMessage(channelID int64, subchannelID, parentID, authorUserID
 
 	now := nowMillis()
 	_, err = tx.Exec(`
-		INSERT INTO Message (id, channel_id, subchannel_id, parent_id, author_user_id, author_nickname, content, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, messageID, channelID, subchannelIDVal, parentIDVal, authorUserIDVal, authorNickname, content, now)
+		INSERT INTO Message (id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, messageID, channelID, subchannelIDVal, parentIDVal, threadRootIDVal, authorUserIDVal, authorNickname, content, now)
 
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	// Insert initial version
@@ -694,23 +748,33 @@ func (db *DB) PostMessage(channelID int64, subchannelID, parentID, authorUserID
 	`, messageID, content, authorNickname, now)
 
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	return messageID, nil
+	return messageID, &Message{
+		ID:             messageID,
+		ChannelID:      channelID,
+		SubchannelID:   subchannelID,
+		ParentID:       parentID,
+		ThreadRootID:   threadRootID,
+		AuthorUserID:   authorUserID,
+		AuthorNickname: authorNickname,
+		Content:        content,
+		CreatedAt:      now,
+	}, nil
 }
 
 // CreateSystemMessage creates a system message (empty author) in a channel
 // Used for DM events like "user has left the conversation"
-func (db *DB) CreateSystemMessage(channelID int64, content string) (int64, error) {
+func (db *DB) CreateSystemMessage(channelID int64, content string) (int64, *Message, error) {
 	tx, err := db.writeConn.Begin()
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	defer tx.Rollback()
 
@@ -723,7 +787,7 @@ func (db *DB) CreateSystemMessage(channelID int64, content string) (int64, error
 	`, messageID, channelID, content, now)
 
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	// Insert version record
@@ -733,14 +797,20 @@ func (db *DB) CreateSystemMessage(channelID int64, content string) (int64, error
 	`, messageID, content, now)
 
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	return messageID, nil
+	return messageID, &Message{
+		ID:             messageID,
+		ChannelID:      channelID,
+		AuthorNickname: "",
+		Content:        content,
+		CreatedAt:      now,
+	}, nil
 }
 
 // ListRootMessages returns root messages (parent_id = null) for a channel
@@ -754,7 +824,7 @@ func (db *DB) ListRootMessages(channelID int64, subchannelID *int64, limit uint1
 
 	query := `
 		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname,
-		       content, created_at, edited_at, deleted_at
+		       content, created_at, edited_at, deleted_at, plus_one_count, plus_one_anon_count
 		FROM Message
 		WHERE channel_id = ?
 		  AND (subchannel_id IS ? OR (subchannel_id IS NULL AND ? IS NULL))
@@ -793,7 +863,7 @@ func (db *DB) ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64,
 		WITH RECURSIVE thread_tree AS (
 			-- Base case: direct replies to parent
 			SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname,
-			       content, created_at, edited_at, deleted_at,
+			       content, created_at, edited_at, deleted_at, plus_one_count, plus_one_anon_count,
 			       printf('%010d', created_at) AS path
 			FROM Message
 			WHERE parent_id = ?
@@ -803,13 +873,13 @@ func (db *DB) ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64,
 			-- Recursive case: replies to replies
 			-- Build path by concatenating parent path with current message's timestamp
 			SELECT m.id, m.channel_id, m.subchannel_id, m.parent_id, m.thread_root_id, m.author_user_id, m.author_nickname,
-			       m.content, m.created_at, m.edited_at, m.deleted_at,
+			       m.content, m.created_at, m.edited_at, m.deleted_at, m.plus_one_count, m.plus_one_anon_count,
 			       tt.path || '.' || printf('%010d', m.created_at)
 			FROM Message m
 			INNER JOIN thread_tree tt ON m.parent_id = tt.id
 		)
 		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname,
-		       content, created_at, edited_at, deleted_at
+		       content, created_at, edited_at, deleted_at, plus_one_count, plus_one_anon_count
 		FROM thread_tree
 	`
 
@@ -848,13 +918,13 @@ func (db *DB) ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64,
 }
 
 // GetMessage returns a single message by ID
-func (db *DB) GetMessage(messageID uint64) (*Message, error) {
+func (db *DB) GetMessage(messageID int64) (*Message, error) {
 	msg := &Message{}
 	var subchannelID, parentID, threadRootID, authorUserID, editedAt, deletedAt sql.NullInt64
 
 	err := db.conn.QueryRow(`
 		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname,
-		       content, created_at, edited_at, deleted_at
+		       content, created_at, edited_at, deleted_at, plus_one_count, plus_one_anon_count
 		FROM Message
 		WHERE id = ?
 	`, messageID).Scan(
@@ -869,6 +939,8 @@ func (db *DB) GetMessage(messageID uint64) (*Message, error) {
 		&msg.CreatedAt,
 		&editedAt,
 		&deletedAt,
+		&msg.PlusOneCount,
+		&msg.PlusOneAnonCount,
 	)
 
 	if err != nil {
@@ -1294,6 +1366,7 @@ func (db *DB) CleanupExpiredMessages() (int64, error) {
 			FROM Message m
 			INNER JOIN Channel c ON m.channel_id = c.id
 			WHERE m.parent_id IS NULL
+			  AND c.archived = 0
 			  AND m.created_at < (? - (c.message_retention_hours * 3600000))
 		)
 	`, nowMillis())
@@ -1349,6 +1422,8 @@ func scanMessages(rows *sql.Rows) ([]*Message, error) {
 			&msg.CreatedAt,
 			&editedAt,
 			&deletedAt,
+			&msg.PlusOneCount,
+			&msg.PlusOneAnonCount,
 		)
 
 		if err != nil {
@@ -2236,6 +2311,7 @@ func (db *DB) ListBans(includeExpired bool) ([]*Ban, error) {
 // AdminAction represents an admin action audit log entry
 type AdminAction struct {
 	ID               int64
+	AdminUserID      *int64 // NULL for entries logged before migration 020
 	AdminNickname    string
 	ActionType       string  // "ban_user", "unban_user", "ban_ip", "unban_ip", "delete_message", "edit_message", "delete_channel", "delete_user"
 	TargetType       string  // "user", "ip", "message", "channel"
@@ -2256,12 +2332,98 @@ func (db *DB) LogAdminAction(adminUserID uint64, adminNickname, actionType, deta
 	return err
 }
 
+// AdminActionFilter narrows an audit log query. Zero values leave a field
+// unfiltered.
+type AdminActionFilter struct {
+	AdminNickname string // Exact nickname, case-insensitive
+	ActionType    string // Exact action type, case-insensitive ("ban_user" matches "BAN_USER")
+	Since         int64  // Only actions performed at or after this time (Unix ms)
+	Until         int64  // Only actions performed before this time (Unix ms)
+	BeforeID      int64  // Only actions older than this entry, for paging
+	Limit         int    // Maximum entries to return (0 = no limit)
+}
+
+// ListAdminActions returns audit log entries matching the filter, newest first
+func (db *DB) ListAdminActions(filter AdminActionFilter) ([]*AdminAction, error) {
+	query := `
+		SELECT id, admin_user_id, admin_nickname, action_type, target_type, target_id,
+		       target_identifier, details, performed_at, ip_address
+		FROM AdminAction
+		WHERE 1 = 1
+	`
+
+	args := []interface{}{}
+	if filter.AdminNickname != "" {
+		query += ` AND admin_nickname = ? COLLATE NOCASE`
+		args = append(args, filter.AdminNickname)
+	}
+	if filter.ActionType != "" {
+		query += ` AND action_type = ? COLLATE NOCASE`
+		args = append(args, filter.ActionType)
+	}
+	if filter.Since > 0 {
+		query += ` AND performed_at >= ?`
+		args = append(args, filter.Since)
+	}
+	if filter.Until > 0 {
+		query += ` AND performed_at < ?`
+		args = append(args, filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		query += ` AND id < ?`
+		args = append(args, filter.BeforeID)
+	}
+
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []*AdminAction
+	for rows.Next() {
+		action := &AdminAction{}
+		var targetIdentifierVal, detailsVal sql.NullString
+
+		err := rows.Scan(
+			&action.ID, &action.AdminUserID, &action.AdminNickname, &action.ActionType, &action.TargetType,
+			&action.TargetID, &targetIdentifierVal, &detailsVal, &action.PerformedAt, &action.IPAddress,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		action.TargetIdentifier = targetIdentifierVal.String
+		action.Details = detailsVal.String
+		actions = append(actions, action)
+	}
+
+	return actions, rows.Err()
+}
+
 // DeleteChannel deletes a channel and all associated data (cascades to messages, subchannels, subscriptions)
 func (db *DB) DeleteChannel(channelID uint64) error {
 	_, err := db.writeConn.Exec(`DELETE FROM Channel WHERE id = ?`, channelID)
 	return err
 }
 
+// UpdateChannel replaces the editable settings of a channel. The name is
+// fixed at creation since clients use it in URLs.
+func (db *DB) UpdateChannel(channelID int64, displayName string, description *string, channelType uint8, retentionHours uint32, archived bool) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE Channel
+		SET display_name = ?, description = ?, channel_type = ?, message_retention_hours = ?, archived = ?
+		WHERE id = ?
+	`, displayName, description, channelType, retentionHours, archived, channelID)
+	return err
+}
+
 // DeleteUser deletes a user account and anonymizes their messages
 // Messages are preserved but author_user_id is set to NULL (becomes anonymous)
 // Returns the nickname of the deleted user
@@ -2476,7 +2638,7 @@ func (db *DB) CreateDMChannel(user1ID, user2ID int64, isEncrypted bool) (int64,
 func (db *DB) GetDMChannels(userID int64) ([]*Channel, error) {
 	rows, err := db.conn.Query(`
 		SELECT c.id, c.name, c.display_name, c.description, c.channel_type,
-		       c.message_retention_hours, c.created_by, c.created_at, c.is_private, c.parent_id, c.is_dm
+		       c.message_retention_hours, c.created_by, c.created_at, c.is_private, c.parent_id, c.is_dm, c.archived, c.modes
 		FROM Channel c
 		INNER JOIN ChannelAccess ca ON c.id = ca.channel_id
 		WHERE ca.user_id = ? AND c.is_dm = 1
@@ -2506,6 +2668,8 @@ func (db *DB) GetDMChannels(userID int64) ([]*Channel, error) {
 			&ch.IsPrivate,
 			&parentID,
 			&ch.IsDM,
+			&ch.Archived,
+			&ch.Modes,
 		)
 		if err != nil {
 			return nil, err
@@ -2533,7 +2697,7 @@ func (db *DB) GetDMChannelBetweenUsers(user1ID, user2ID int64) (*Channel, error)
 	// Find a channel where both users have access and it's a DM
 	row := db.conn.QueryRow(`
 		SELECT c.id, c.name, c.display_name, c.description, c.channel_type,
-		       c.message_retention_hours, c.created_by, c.created_at, c.is_private, c.parent_id, c.is_dm
+		       c.message_retention_hours, c.created_by, c.created_at, c.is_private, c.parent_id, c.is_dm, c.archived, c.modes
 		FROM Channel c
 		INNER JOIN ChannelAccess ca1 ON c.id = ca1.channel_id AND ca1.user_id = ?
 		INNER JOIN ChannelAccess ca2 ON c.id = ca2.channel_id AND ca2.user_id = ?
@@ -2558,6 +2722,8 @@ func (db *DB) GetDMChannelBetweenUsers(user1ID, user2ID int64) (*Channel, error)
 		&ch.IsPrivate,
 		&parentID,
 		&ch.IsDM,
+		&ch.Archived,
+		&ch.Modes,
 	)
 
 	if err == sql.ErrNoRows {
@@ -2600,12 +2766,13 @@ func (db *DB) GetDMOtherUser(channelID, currentUserID int64) (*User, error) {
 	return &user, nil
 }
 
-// UserHasAccessToChannel checks if a user has access to a specific channel
+// UserHasAccessToChannel checks if a user has access to a DM or private channel
 func (db *DB) UserHasAccessToChannel(userID, channelID int64) (bool, error) {
 	var count int
 	err := db.conn.QueryRow(`
-		SELECT COUNT(*) FROM ChannelAccess WHERE user_id = ? AND channel_id = ?
-	`, userID, channelID).Scan(&count)
+		SELECT (SELECT COUNT(*) FROM ChannelAccess WHERE user_id = ? AND channel_id = ?)
+		     + (SELECT COUNT(*) FROM ChannelParticipant WHERE user_id = ? AND channel_id = ?)
+	`, userID, channelID, userID, channelID).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func newTestDB(t *testing.T) *DB {
@@ -32,7 +33,7 @@ func TestSoftDeleteMessageSuccess(t *testing.T) {
 
 	channelID := mustChannelID(t, db)
 
-	messageID, err := db.PostMessage(channelID, nil, nil, nil, "alice", "hello world")
+	messageID, _, err := db.PostMessage(channelID, nil, nil, nil, "alice", "hello world")
 	if err != nil {
 		t.Fatalf("failed to create message: %v", err)
 	}
@@ -50,7 +51,7 @@ func TestSoftDeleteMessageSuccess(t *testing.T) {
 		t.Fatalf("expected deleted_at to be set")
 	}
 
-	stored, err := db.GetMessage(uint64(messageID))
+	stored, err := db.GetMessage(messageID)
 	if err != nil {
 		t.Fatalf("failed to load message: %v", err)
 	}
@@ -75,7 +76,7 @@ func TestSoftDeleteMessageNotOwned(t *testing.T) {
 	defer db.Close()
 
 	channelID := mustChannelID(t, db)
-	messageID, err := db.PostMessage(channelID, nil, nil, nil, "alice", "hello")
+	messageID, _, err := db.PostMessage(channelID, nil, nil, nil, "alice", "hello")
 	if err != nil {
 		t.Fatalf("failed to create message: %v", err)
 	}
@@ -91,7 +92,7 @@ func TestSoftDeleteMessageAlreadyDeleted(t *testing.T) {
 	defer db.Close()
 
 	channelID := mustChannelID(t, db)
-	messageID, err := db.PostMessage(channelID, nil, nil, nil, "alice", "hello")
+	messageID, _, err := db.PostMessage(channelID, nil, nil, nil, "alice", "hello")
 	if err != nil {
 		t.Fatalf("failed to create message: %v", err)
 	}
@@ -150,7 +151,7 @@ func TestCleanupExpiredMessages(t *testing.T) {
 	}
 
 	// Create a recent message (30 minutes ago)
-	recentID, err := db.PostMessage(shortChannelID, nil, nil, nil, "bob", "recent message")
+	recentID, _, err := db.PostMessage(shortChannelID, nil, nil, nil, "bob", "recent message")
 	if err != nil {
 		t.Fatalf("failed to create recent message: %v", err)
 	}
@@ -167,7 +168,7 @@ func TestCleanupExpiredMessages(t *testing.T) {
 	}
 
 	// Verify recent message still exists
-	_, err = db.GetMessage(uint64(recentID))
+	_, err = db.GetMessage(recentID)
 	if err != nil {
 		t.Fatalf("recent message should still exist: %v", err)
 	}
@@ -248,6 +249,117 @@ func TestCleanupExpiredMessagesWithReplies(t *testing.T) {
 	}
 }
 
+func TestUpdateChannel(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("archive", "#archive", nil, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	desc := "Old announcements"
+	if err := memDB.UpdateChannel(channelID, "Archive", &desc, 0, 24, true); err != nil {
+		t.Fatalf("UpdateChannel: %v", err)
+	}
+
+	// Both the cache and SQLite see the change
+	for name, store := range map[string]Store{"memdb": memDB, "sqlite": db} {
+		ch, err := store.GetChannel(channelID)
+		if err != nil {
+			t.Fatalf("%s: GetChannel: %v", name, err)
+		}
+		if ch.Name != "archive" || ch.DisplayName != "Archive" || ch.Description == nil || *ch.Description != desc ||
+			ch.ChannelType != 0 || ch.MessageRetentionHours != 24 || !ch.Archived {
+			t.Errorf("%s: unexpected channel after update %+v", name, ch)
+		}
+	}
+
+	// Archived channels keep their messages past retention
+	twoDaysAgo := nowMillis() - 48*3600*1000
+	if _, err := db.conn.Exec(`
+		INSERT INTO Message (channel_id, parent_id, author_nickname, content, created_at)
+		VALUES (?, NULL, 'alice', 'old message', ?)
+	`, channelID, twoDaysAgo); err != nil {
+		t.Fatalf("failed to create old message: %v", err)
+	}
+	count, err := db.CleanupExpiredMessages()
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected archived channel to be skipped, %d messages deleted", count)
+	}
+
+	if err := db.UpdateChannel(channelID, "Archive", nil, 0, 24, false); err != nil {
+		t.Fatalf("UpdateChannel: %v", err)
+	}
+	if count, err := db.CleanupExpiredMessages(); err != nil || count != 1 {
+		t.Fatalf("expected 1 message deleted after unarchiving, got %d (%v)", count, err)
+	}
+}
+
+func TestListAdminActions(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	nickname := "mallory"
+	if _, err := db.CreateUserBan(nil, &nickname, "spam", false, nil, "alice", "10.0.0.1"); err != nil {
+		t.Fatalf("CreateUserBan: %v", err)
+	}
+	if err := db.LogAdminAction(1, "alice", "DELETE_CHANNEL", "Deleted channel #old"); err != nil {
+		t.Fatalf("LogAdminAction: %v", err)
+	}
+	if err := db.LogAdminAction(2, "bob", "UPDATE_CHANNEL", "Archived #news"); err != nil {
+		t.Fatalf("LogAdminAction: %v", err)
+	}
+
+	all, err := db.ListAdminActions(AdminActionFilter{})
+	if err != nil {
+		t.Fatalf("ListAdminActions: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 actions, got %d", len(all))
+	}
+	// Newest first
+	if all[0].ActionType != "UPDATE_CHANNEL" || all[0].AdminUserID == nil || *all[0].AdminUserID != 2 {
+		t.Errorf("unexpected newest action %+v", all[0])
+	}
+	if all[2].ActionType != "ban_user" || all[2].TargetIdentifier != "mallory" || all[2].AdminUserID != nil {
+		t.Errorf("unexpected ban action %+v", all[2])
+	}
+
+	byAdmin, err := db.ListAdminActions(AdminActionFilter{AdminNickname: "ALICE"})
+	if err != nil || len(byAdmin) != 2 {
+		t.Fatalf("expected 2 actions by alice, got %d (%v)", len(byAdmin), err)
+	}
+
+	byType, err := db.ListAdminActions(AdminActionFilter{ActionType: "delete_channel"})
+	if err != nil || len(byType) != 1 || byType[0].Details != "Deleted channel #old" {
+		t.Fatalf("expected the delete action, got %v (%v)", byType, err)
+	}
+
+	future, err := db.ListAdminActions(AdminActionFilter{Since: nowMillis() + 60000})
+	if err != nil || len(future) != 0 {
+		t.Fatalf("expected no actions in the future, got %d (%v)", len(future), err)
+	}
+
+	// Paging continues from the oldest entry of the previous page
+	page, err := db.ListAdminActions(AdminActionFilter{Limit: 2})
+	if err != nil || len(page) != 2 {
+		t.Fatalf("expected a page of 2, got %d (%v)", len(page), err)
+	}
+	rest, err := db.ListAdminActions(AdminActionFilter{BeforeID: page[1].ID, Limit: 2})
+	if err != nil || len(rest) != 1 || rest[0].ID != all[2].ID {
+		t.Fatalf("expected the last action on the second page, got %v (%v)", rest, err)
+	}
+}
+
 func TestCleanupIdleSessions(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
@@ -293,3 +405,49 @@ func TestCleanupIdleSessions(t *testing.T) {
 		t.Fatalf("expected 1 recent session, got %d", recentCount)
 	}
 }
+
+func TestMaintenance(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	mustChannelID(t, db)
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.FileSize == 0 || stats.PageSize == 0 || stats.PageCount == 0 {
+		t.Errorf("expected the file and page sizes, got %+v", stats)
+	}
+	channels := int64(-1)
+	for _, table := range stats.Tables {
+		if table.Name == "Channel" {
+			channels = table.Rows
+		}
+	}
+	if channels != 1 {
+		t.Errorf("expected 1 channel row, got %d", channels)
+	}
+
+	problems, err := db.IntegrityCheck()
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("expected a healthy database, got %v (%v)", problems, err)
+	}
+
+	// Foreign key violations are reported, not fixed
+	if _, err := db.writeConn.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		t.Fatalf("PRAGMA: %v", err)
+	}
+	if _, err := db.writeConn.Exec(`DELETE FROM Channel`); err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	if _, err := db.writeConn.Exec(`INSERT INTO ChannelRole (channel_id, user_id, role, granted_by, granted_at) VALUES (999, 999, 'owner', '', 0)`); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+	if problems, err := db.IntegrityCheck(); err != nil || len(problems) == 0 {
+		t.Errorf("expected the orphaned role to be reported, got %v (%v)", problems, err)
+	}
+
+	if err := db.Vacuum(); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+}
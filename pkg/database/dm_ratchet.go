@@ -0,0 +1,88 @@
+package database
+
+// Ratcheted DMs (V4). A DM channel is ratcheted if both users' clients
+// supported the Double Ratchet when it was created; it never changes after
+// that, so older DMs keep using the static channel key.
+
+// SetUserRatchetSupport records whether a user's client supports ratcheted
+// DMs. The latest client to provide the user's encryption key decides.
+func (db *DB) SetUserRatchetSupport(userID int64, supported bool) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE User SET encryption_ratchet = ? WHERE id = ?
+	`, supported, userID)
+	return err
+}
+
+// GetUserRatchetSupport reports whether a user's client supports ratcheted
+// DMs
+func (db *DB) GetUserRatchetSupport(userID int64) (bool, error) {
+	var supported bool
+	err := db.conn.QueryRow(`
+		SELECT encryption_ratchet FROM User WHERE id = ?
+	`, userID).Scan(&supported)
+	return supported, err
+}
+
+// SetDMRatchet marks a new DM channel as ratcheted
+func (db *DB) SetDMRatchet(channelID int64) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE Channel SET dm_ratchet = 1 WHERE id = ? AND is_dm = 1
+	`, channelID)
+	return err
+}
+
+// IsDMRatchet reports whether a DM channel is ratcheted
+func (db *DB) IsDMRatchet(channelID int64) (bool, error) {
+	var ratchet bool
+	err := db.conn.QueryRow(`
+		SELECT dm_ratchet FROM Channel WHERE id = ?
+	`, channelID).Scan(&ratchet)
+	return ratchet, err
+}
+
+// Ratchet flags are only read when a DM is started, so MemDB doesn't cache
+// them.
+
+func (m *MemDB) SetUserRatchetSupport(userID int64, supported bool) error {
+	return m.sqliteDB.SetUserRatchetSupport(userID, supported)
+}
+
+func (m *MemDB) GetUserRatchetSupport(userID int64) (bool, error) {
+	return m.sqliteDB.GetUserRatchetSupport(userID)
+}
+
+func (m *MemDB) SetDMRatchet(channelID int64) error {
+	return m.sqliteDB.SetDMRatchet(channelID)
+}
+
+func (m *MemDB) IsDMRatchet(channelID int64) (bool, error) {
+	return m.sqliteDB.IsDMRatchet(channelID)
+}
+
+// SetUserRatchetSupport records whether a user's client supports ratcheted
+// DMs. The latest client to provide the user's encryption key decides.
+func (db *PostgresDB) SetUserRatchetSupport(userID int64, supported bool) error {
+	_, err := db.conn.Exec(`UPDATE "User" SET encryption_ratchet = $1 WHERE id = $2`, supported, userID)
+	return err
+}
+
+// GetUserRatchetSupport reports whether a user's client supports ratcheted
+// DMs
+func (db *PostgresDB) GetUserRatchetSupport(userID int64) (bool, error) {
+	var supported bool
+	err := db.conn.QueryRow(`SELECT encryption_ratchet FROM "User" WHERE id = $1`, userID).Scan(&supported)
+	return supported, err
+}
+
+// SetDMRatchet marks a new DM channel as ratcheted
+func (db *PostgresDB) SetDMRatchet(channelID int64) error {
+	_, err := db.conn.Exec(`UPDATE Channel SET dm_ratchet = TRUE WHERE id = $1 AND is_dm = TRUE`, channelID)
+	return err
+}
+
+// IsDMRatchet reports whether a DM channel is ratcheted
+func (db *PostgresDB) IsDMRatchet(channelID int64) (bool, error) {
+	var ratchet bool
+	err := db.conn.QueryRow(`SELECT dm_ratchet FROM Channel WHERE id = $1`, channelID).Scan(&ratchet)
+	return ratchet, err
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrIncomingWebhookNotFound indicates no incoming webhook matches the ID or token.
+var ErrIncomingWebhookNotFound = errors.New("incoming webhook not found")
+
+// IncomingWebhook is a token that lets HTTP clients post into a channel
+type IncomingWebhook struct {
+	ID           int64
+	TokenHash    string // Hex SHA-256 of the token
+	Name         string // Integration identity shown as the message author
+	ChannelID    int64
+	SubchannelID *int64
+	ParentID     *int64 // Message to reply to (nil = post a new thread)
+	CreatedBy    string
+	CreatedAt    int64  // Unix timestamp in milliseconds
+	LastUsedAt   *int64 // Unix timestamp in milliseconds
+}
+
+const incomingWebhookColumns = `id, token_hash, name, channel_id, subchannel_id, parent_id, created_by, created_at, last_used_at`
+
+func scanIncomingWebhook(row rowScanner) (*IncomingWebhook, error) {
+	hook := &IncomingWebhook{}
+	err := row.Scan(&hook.ID, &hook.TokenHash, &hook.Name, &hook.ChannelID, &hook.SubchannelID,
+		&hook.ParentID, &hook.CreatedBy, &hook.CreatedAt, &hook.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIncomingWebhookNotFound
+	}
+	return hook, err
+}
+
+func scanIncomingWebhooks(rows *sql.Rows) ([]*IncomingWebhook, error) {
+	var hooks []*IncomingWebhook
+	for rows.Next() {
+		hook, err := scanIncomingWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// CreateIncomingWebhook stores an incoming webhook and sets its ID
+func (db *DB) CreateIncomingWebhook(hook *IncomingWebhook) error {
+	result, err := db.writeConn.Exec(`
+		INSERT INTO IncomingWebhook (token_hash, name, channel_id, subchannel_id, parent_id, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, hook.TokenHash, hook.Name, hook.ChannelID, hook.SubchannelID, hook.ParentID, hook.CreatedBy, hook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create incoming webhook: %w", err)
+	}
+	hook.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetIncomingWebhookByTokenHash looks up an incoming webhook by its token hash
+func (db *DB) GetIncomingWebhookByTokenHash(tokenHash string) (*IncomingWebhook, error) {
+	return scanIncomingWebhook(db.conn.QueryRow(`
+		SELECT `+incomingWebhookColumns+` FROM IncomingWebhook WHERE token_hash = ?
+	`, tokenHash))
+}
+
+// ListIncomingWebhooks returns all incoming webhooks, oldest first
+func (db *DB) ListIncomingWebhooks() ([]*IncomingWebhook, error) {
+	rows, err := db.conn.Query(`SELECT ` + incomingWebhookColumns + ` FROM IncomingWebhook ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incoming webhooks: %w", err)
+	}
+	defer rows.Close()
+	return scanIncomingWebhooks(rows)
+}
+
+// DeleteIncomingWebhook revokes an incoming webhook
+func (db *DB) DeleteIncomingWebhook(id int64) error {
+	result, err := db.writeConn.Exec(`DELETE FROM IncomingWebhook WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrIncomingWebhookNotFound
+	}
+	return nil
+}
+
+// TouchIncomingWebhook records when an incoming webhook was last used
+func (db *DB) TouchIncomingWebhook(id int64, usedAt int64) error {
+	_, err := db.writeConn.Exec(`UPDATE IncomingWebhook SET last_used_at = ? WHERE id = ?`, usedAt, id)
+	return err
+}
+
+// Incoming webhooks are rare admin data, so MemDB doesn't cache them.
+
+func (m *MemDB) CreateIncomingWebhook(hook *IncomingWebhook) error {
+	return m.sqliteDB.CreateIncomingWebhook(hook)
+}
+
+func (m *MemDB) GetIncomingWebhookByTokenHash(tokenHash string) (*IncomingWebhook, error) {
+	return m.sqliteDB.GetIncomingWebhookByTokenHash(tokenHash)
+}
+
+func (m *MemDB) ListIncomingWebhooks() ([]*IncomingWebhook, error) {
+	return m.sqliteDB.ListIncomingWebhooks()
+}
+
+func (m *MemDB) DeleteIncomingWebhook(id int64) error {
+	return m.sqliteDB.DeleteIncomingWebhook(id)
+}
+
+func (m *MemDB) TouchIncomingWebhook(id int64, usedAt int64) error {
+	return m.sqliteDB.TouchIncomingWebhook(id, usedAt)
+}
+
+// CreateIncomingWebhook stores an incoming webhook and sets its ID
+func (db *PostgresDB) CreateIncomingWebhook(hook *IncomingWebhook) error {
+	err := db.conn.QueryRow(`
+		INSERT INTO IncomingWebhook (token_hash, name, channel_id, subchannel_id, parent_id, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, hook.TokenHash, hook.Name, hook.ChannelID, hook.SubchannelID, hook.ParentID, hook.CreatedBy, hook.CreatedAt).Scan(&hook.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create incoming webhook: %w", err)
+	}
+	return nil
+}
+
+// GetIncomingWebhookByTokenHash looks up an incoming webhook by its token hash
+func (db *PostgresDB) GetIncomingWebhookByTokenHash(tokenHash string) (*IncomingWebhook, error) {
+	return scanIncomingWebhook(db.conn.QueryRow(`
+		SELECT `+incomingWebhookColumns+` FROM IncomingWebhook WHERE token_hash = $1
+	`, tokenHash))
+}
+
+// ListIncomingWebhooks returns all incoming webhooks, oldest first
+func (db *PostgresDB) ListIncomingWebhooks() ([]*IncomingWebhook, error) {
+	rows, err := db.conn.Query(`SELECT ` + incomingWebhookColumns + ` FROM IncomingWebhook ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incoming webhooks: %w", err)
+	}
+	defer rows.Close()
+	return scanIncomingWebhooks(rows)
+}
+
+// DeleteIncomingWebhook revokes an incoming webhook
+func (db *PostgresDB) DeleteIncomingWebhook(id int64) error {
+	result, err := db.conn.Exec(`DELETE FROM IncomingWebhook WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrIncomingWebhookNotFound
+	}
+	return nil
+}
+
+// TouchIncomingWebhook records when an incoming webhook was last used
+func (db *PostgresDB) TouchIncomingWebhook(id int64, usedAt int64) error {
+	_, err := db.conn.Exec(`UPDATE IncomingWebhook SET last_used_at = $1 WHERE id = $2`, usedAt, id)
+	return err
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIncomingWebhooks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		channelID, err := store.CreateChannel("deploys", "#deploys", nil, 0, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+
+		parentID := int64(1234)
+		first := &IncomingWebhook{TokenHash: "hash-a", Name: "deploy-bot", ChannelID: channelID, CreatedBy: "admin", CreatedAt: nowMillis()}
+		second := &IncomingWebhook{TokenHash: "hash-b", Name: "ci", ChannelID: channelID, ParentID: &parentID, CreatedBy: "admin", CreatedAt: nowMillis()}
+		for _, hook := range []*IncomingWebhook{first, second} {
+			if err := store.CreateIncomingWebhook(hook); err != nil {
+				t.Fatalf("CreateIncomingWebhook: %v", err)
+			}
+			if hook.ID == 0 {
+				t.Fatal("CreateIncomingWebhook did not set ID")
+			}
+		}
+
+		if err := store.CreateIncomingWebhook(&IncomingWebhook{TokenHash: "hash-a", Name: "dup", ChannelID: channelID, CreatedBy: "admin", CreatedAt: nowMillis()}); err == nil {
+			t.Error("CreateIncomingWebhook with duplicate token hash succeeded")
+		}
+
+		hook, err := store.GetIncomingWebhookByTokenHash("hash-b")
+		if err != nil {
+			t.Fatalf("GetIncomingWebhookByTokenHash: %v", err)
+		}
+		if hook.ID != second.ID || hook.Name != "ci" || hook.ParentID == nil || *hook.ParentID != parentID || hook.LastUsedAt != nil {
+			t.Errorf("GetIncomingWebhookByTokenHash = %+v", hook)
+		}
+		if _, err := store.GetIncomingWebhookByTokenHash("missing"); !errors.Is(err, ErrIncomingWebhookNotFound) {
+			t.Errorf("GetIncomingWebhookByTokenHash(missing) error = %v, want ErrIncomingWebhookNotFound", err)
+		}
+
+		if err := store.TouchIncomingWebhook(first.ID, 42); err != nil {
+			t.Fatalf("TouchIncomingWebhook: %v", err)
+		}
+		hooks, err := store.ListIncomingWebhooks()
+		if err != nil {
+			t.Fatalf("ListIncomingWebhooks: %v", err)
+		}
+		if len(hooks) != 2 || hooks[0].ID != first.ID || hooks[0].LastUsedAt == nil || *hooks[0].LastUsedAt != 42 {
+			t.Fatalf("ListIncomingWebhooks = %+v", hooks)
+		}
+
+		if err := store.DeleteIncomingWebhook(first.ID); err != nil {
+			t.Fatalf("DeleteIncomingWebhook: %v", err)
+		}
+		if err := store.DeleteIncomingWebhook(first.ID); !errors.Is(err, ErrIncomingWebhookNotFound) {
+			t.Errorf("repeat DeleteIncomingWebhook error = %v, want ErrIncomingWebhookNotFound", err)
+		}
+		if _, err := store.GetIncomingWebhookByTokenHash("hash-a"); !errors.Is(err, ErrIncomingWebhookNotFound) {
+			t.Errorf("revoked webhook still found: %v", err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package database
+
+import "database/sql"
+
+// Key backups (V4). Each registered user can store one backup of their DM
+// encryption keys. It's encrypted on the client, so it's just bytes here.
+
+// SetKeyBackup stores a user's key backup, replacing any earlier one, and
+// returns when it was stored (Unix milliseconds)
+func (db *DB) SetKeyBackup(userID int64, backup []byte) (int64, error) {
+	now := nowMillis()
+	_, err := db.writeConn.Exec(`
+		INSERT INTO KeyBackup (user_id, backup, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET backup = excluded.backup, updated_at = excluded.updated_at
+	`, userID, backup, now)
+	return now, err
+}
+
+// GetKeyBackup returns a user's key backup and when it was stored, or nil
+// if they have none
+func (db *DB) GetKeyBackup(userID int64) ([]byte, int64, error) {
+	var backup []byte
+	var updatedAt int64
+	err := db.conn.QueryRow(`
+		SELECT backup, updated_at FROM KeyBackup WHERE user_id = ?
+	`, userID).Scan(&backup, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	return backup, updatedAt, err
+}
+
+// DeleteKeyBackup deletes a user's key backup
+func (db *DB) DeleteKeyBackup(userID int64) error {
+	_, err := db.writeConn.Exec(`DELETE FROM KeyBackup WHERE user_id = ?`, userID)
+	return err
+}
+
+// Key backups are only read when a user restores one, so MemDB doesn't cache
+// them.
+
+func (m *MemDB) SetKeyBackup(userID int64, backup []byte) (int64, error) {
+	return m.sqliteDB.SetKeyBackup(userID, backup)
+}
+
+func (m *MemDB) GetKeyBackup(userID int64) ([]byte, int64, error) {
+	return m.sqliteDB.GetKeyBackup(userID)
+}
+
+func (m *MemDB) DeleteKeyBackup(userID int64) error {
+	return m.sqliteDB.DeleteKeyBackup(userID)
+}
+
+// SetKeyBackup stores a user's key backup, replacing any earlier one, and
+// returns when it was stored (Unix milliseconds)
+func (db *PostgresDB) SetKeyBackup(userID int64, backup []byte) (int64, error) {
+	now := nowMillis()
+	_, err := db.conn.Exec(`
+		INSERT INTO KeyBackup (user_id, backup, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET backup = EXCLUDED.backup, updated_at = EXCLUDED.updated_at
+	`, userID, backup, now)
+	return now, err
+}
+
+// GetKeyBackup returns a user's key backup and when it was stored, or nil
+// if they have none
+func (db *PostgresDB) GetKeyBackup(userID int64) ([]byte, int64, error) {
+	var backup []byte
+	var updatedAt int64
+	err := db.conn.QueryRow(`SELECT backup, updated_at FROM KeyBackup WHERE user_id = $1`, userID).Scan(&backup, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	return backup, updatedAt, err
+}
+
+// DeleteKeyBackup deletes a user's key backup
+func (db *PostgresDB) DeleteKeyBackup(userID int64) error {
+	_, err := db.conn.Exec(`DELETE FROM KeyBackup WHERE user_id = $1`, userID)
+	return err
+}
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"errors"
+	"os"
+)
+
+// A running server keeps a SQLite database in MemDB and only writes it back
+// in snapshots, so changes another process makes to the file are lost or
+// overwritten. The server holds an exclusive lock on <database>.lock while it
+// runs, and the offline tools take the same lock before they write.
+
+// ErrDatabaseLocked is returned when another process holds a database's lock
+var ErrDatabaseLocked = errors.New("database is in use by a running server")
+
+// LockDatabase takes the exclusive lock on a SQLite database, failing with
+// ErrDatabaseLocked if another process holds it. The lock is released when
+// the returned file is closed or the process exits.
+func LockDatabase(dbPath string) (*os.File, error) {
+	return lockFile(dbPath + ".lock")
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	lock, err := LockDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("LockDatabase: %v", err)
+	}
+	if _, err := LockDatabase(dbPath); !errors.Is(err, ErrDatabaseLocked) {
+		t.Fatalf("expected ErrDatabaseLocked while the lock is held, got %v", err)
+	}
+	lock.Close()
+
+	lock, err = LockDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("expected the lock to be free after Close, got %v", err)
+	}
+	lock.Close()
+}
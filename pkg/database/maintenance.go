@@ -0,0 +1,138 @@
+package database
+
+import (
+	"fmt"
+	"os"
+)
+
+// Maintenance for the SQLite database, used by `scd db` while the server is
+// stopped. PostgreSQL deployments use their own tooling for this.
+
+// DBStats describes the size of a SQLite database
+type DBStats struct {
+	Path      string
+	FileSize  int64 // Bytes in the database file
+	WALSize   int64 // Bytes in the write-ahead log (0 if there is none)
+	PageSize  int64
+	PageCount int64
+	FreePages int64 // Pages VACUUM would give back
+	Tables    []TableStats
+}
+
+// TableStats is the row count of one table
+type TableStats struct {
+	Name string
+	Rows int64
+}
+
+// Stats returns the file size, page usage and row count of every table
+func (db *DB) Stats() (*DBStats, error) {
+	stats := &DBStats{Path: db.path}
+	if info, err := os.Stat(db.path); err == nil {
+		stats.FileSize = info.Size()
+	}
+	if info, err := os.Stat(db.path + "-wal"); err == nil {
+		stats.WALSize = info.Size()
+	}
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreePages,
+	} {
+		if err := db.conn.QueryRow("PRAGMA " + pragma).Scan(dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma, err)
+		}
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		table := TableStats{Name: name}
+		if err := db.conn.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, name)).Scan(&table.Rows); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", name, err)
+		}
+		stats.Tables = append(stats.Tables, table)
+	}
+	return stats, nil
+}
+
+// IntegrityCheck runs SQLite's integrity and foreign key checks and returns
+// the problems found (none if the database is healthy)
+func (db *DB) IntegrityCheck() ([]string, error) {
+	var problems []string
+
+	rows, err := db.conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.conn.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, parent string
+		var rowID *int64
+		var fkID int64
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return nil, err
+		}
+		if rowID != nil {
+			problems = append(problems, fmt.Sprintf("%s row %d references a missing %s", table, *rowID, parent))
+		} else {
+			problems = append(problems, fmt.Sprintf("%s references a missing %s", table, parent))
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Vacuum folds the write-ahead log into the database file and rebuilds it,
+// giving free pages back to the filesystem
+func (db *DB) Vacuum() error {
+	if _, err := db.writeConn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	if _, err := db.writeConn.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+	// In WAL mode the rebuilt pages land in the log first
+	if _, err := db.writeConn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	return nil
+}
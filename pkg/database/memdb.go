@@ -26,8 +26,20 @@ type MemDB struct {
 	messagesByThread  map[int64][]int64        // threadRootID -> sorted messageIDs
 	sessionsByUserID  map[int64]map[int64]bool // userID -> set of sessionIDs
 
+	// +1 dedupe for registered users
+	plusOnes map[int64]map[int64]bool // messageID -> set of userIDs
+
+	// @mentions of registered users
+	mentionsByUser map[int64][]int64 // userID -> sorted messageIDs mentioning them
+
 	// Dirty tracking for incremental snapshots
-	dirtyMessages map[int64]bool // Messages modified since last snapshot
+	dirtyMessages map[int64]bool   // Messages modified since last snapshot
+	dirtyPlusOnes []UserPlusOne    // UserPlusOne rows added since last snapshot
+	dirtyMentions []Mention        // Mention rows added since last snapshot
+	dirtyVersions []MessageVersion // MessageVersion rows added since last snapshot
+
+	// Write-ahead log of changes since the last snapshot (nil for in-memory databases)
+	wal *memWAL
 
 	// Underlying SQLite DB for snapshots
 	sqliteDB         *DB
@@ -46,17 +58,40 @@ func NewMemDB(sqliteDB *DB, snapshotInterval time.Duration) (*MemDB, error) {
 		messagesByParent:  make(map[int64][]int64),
 		messagesByThread:  make(map[int64][]int64),
 		sessionsByUserID:  make(map[int64]map[int64]bool),
+		plusOnes:          make(map[int64]map[int64]bool),
+		mentionsByUser:    make(map[int64][]int64),
 		dirtyMessages:     make(map[int64]bool),
 		sqliteDB:          sqliteDB,
 		snapshotInterval:  snapshotInterval,
 		shutdown:          make(chan struct{}),
 	}
 
-	// Load initial state from SQLite
-	if err := m.loadFromSQLite(); err != nil {
+	if dir := walDirForDB(sqliteDB.path); dir != "" {
+		wal, err := openMemWAL(dir)
+		if err != nil {
+			return nil, err
+		}
+		m.wal = wal
+	}
+
+	// Load initial state from SQLite, replaying the WAL on top
+	replayed, err := m.loadFromSQLite()
+	if err != nil {
 		return nil, fmt.Errorf("failed to load from SQLite: %w", err)
 	}
 
+	if m.wal != nil {
+		if err := m.wal.start(); err != nil {
+			return nil, err
+		}
+		// Persist what was recovered right away so the old segments can go
+		if replayed > 0 {
+			if err := m.snapshot(); err != nil {
+				log.Printf("MemDB: snapshot after WAL replay failed: %v", err)
+			}
+		}
+	}
+
 	// Start background snapshot goroutine
 	m.wg.Add(1)
 	go m.snapshotLoop()
@@ -67,20 +102,29 @@ func NewMemDB(sqliteDB *DB, snapshotInterval time.Duration) (*MemDB, error) {
 	return m, nil
 }
 
-// loadFromSQLite loads all data from SQLite into memory
-func (m *MemDB) loadFromSQLite() error {
+// loadFromSQLite loads all data from SQLite into memory, then replays changes
+// from the WAL that hadn't been snapshotted. Returns the number of WAL records
+// replayed.
+func (m *MemDB) loadFromSQLite() (int, error) {
 	startTotal := time.Now()
 
 	// Load channels
 	startChannels := time.Now()
 	channels, err := m.sqliteDB.ListChannels()
 	if err != nil {
-		return fmt.Errorf("failed to load channels: %w", err)
+		return 0, fmt.Errorf("failed to load channels: %w", err)
 	}
 	for _, ch := range channels {
 		m.channels[ch.ID] = ch
 	}
-	log.Printf("MemDB: loaded %d channels in %v", len(channels), time.Since(startChannels))
+	privateChannels, err := m.sqliteDB.listPrivateChannels()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load private channels: %w", err)
+	}
+	for _, ch := range privateChannels {
+		m.channels[ch.ID] = ch
+	}
+	log.Printf("MemDB: loaded %d channels in %v", len(channels)+len(privateChannels), time.Since(startChannels))
 
 	// Load ALL messages in one query instead of per-channel recursive queries
 	startMessages := time.Now()
@@ -88,13 +132,14 @@ func (m *MemDB) loadFromSQLite() error {
 	// Query all messages directly from SQLite
 	rows, err := m.sqliteDB.conn.Query(`
 		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id,
-		       author_nickname, content, created_at, edited_at, deleted_at
+		       author_nickname, content, created_at, edited_at, deleted_at,
+		       plus_one_count, plus_one_anon_count
 		FROM Message
 		WHERE deleted_at IS NULL
 		ORDER BY created_at ASC
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to load messages: %w", err)
+		return 0, fmt.Errorf("failed to load messages: %w", err)
 	}
 	defer rows.Close()
 
@@ -108,6 +153,7 @@ func (m *MemDB) loadFromSQLite() error {
 		err := rows.Scan(
 			&msg.ID, &msg.ChannelID, &subchannelID, &parentID, &threadRootID, &authorUserID,
 			&msg.AuthorNickname, &msg.Content, &msg.CreatedAt, &editedAt, &deletedAt,
+			&msg.PlusOneCount, &msg.PlusOneAnonCount,
 		)
 		if err != nil {
 			log.Printf("MemDB: failed to scan message: %v", err)
@@ -151,11 +197,25 @@ func (m *MemDB) loadFromSQLite() error {
 	}
 
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating messages: %w", err)
+		return 0, fmt.Errorf("error iterating messages: %w", err)
 	}
 
 	log.Printf("MemDB: loaded %d root messages and %d replies in %v", totalRootMessages, totalReplies, time.Since(startMessages))
 
+	// Replay changes that didn't make it into a snapshot before the last exit
+	var walRecords []walRecord
+	if m.wal != nil {
+		startReplay := time.Now()
+		walRecords, err = m.wal.readAll()
+		if err != nil {
+			return 0, err
+		}
+		m.replayWAL(walRecords)
+		if len(walRecords) > 0 {
+			log.Printf("MemDB: replayed %d WAL records in %v", len(walRecords), time.Since(startReplay))
+		}
+	}
+
 	// Sort all message indexes by timestamp
 	startSort := time.Now()
 	for channelID := range m.messagesByChannel {
@@ -176,11 +236,21 @@ func (m *MemDB) loadFromSQLite() error {
 	}
 	log.Printf("MemDB: computed reply counts in %v", time.Since(startCounts))
 
+	// Load +1 dedupe set
+	if err := m.loadPlusOnes(); err != nil {
+		return 0, err
+	}
+
+	// Load mentions
+	if err := m.loadMentions(); err != nil {
+		return 0, err
+	}
+
 	// Note: Sessions are NOT loaded - they're ephemeral connections
 	// Users reconnect and create new sessions on startup
 
 	log.Printf("MemDB: total load time %v (%d total messages)", time.Since(startTotal), len(m.messages))
-	return nil
+	return len(walRecords), nil
 }
 
 // sortMessagesByTimestamp sorts message IDs by their timestamps
@@ -246,12 +316,27 @@ func (m *MemDB) snapshot() error {
 	messagesWritten := 0
 	messagesSkipped := 0
 
-	// Collect dirty IDs and message data under read lock
-	m.mu.RLock()
+	// Take the dirty set under the write lock. Messages changed while the
+	// snapshot is being written are marked dirty again for the next one.
+	m.mu.Lock()
 	dirtyIDs := make([]int64, 0, len(m.dirtyMessages))
 	for id := range m.dirtyMessages {
 		dirtyIDs = append(dirtyIDs, id)
 	}
+	m.dirtyMessages = make(map[int64]bool)
+
+	// Changes from here on go to a new WAL segment; the closed ones are
+	// covered by this snapshot
+	var walSeq uint64
+	if m.wal != nil {
+		seq, err := m.wal.rotate()
+		if err != nil {
+			m.restoreDirtyLocked(dirtyIDs)
+			m.mu.Unlock()
+			return err
+		}
+		walSeq = seq
+	}
 
 	// Filter out messages to skip and collect messages to write
 	messagesToWrite := make([]*Message, 0, len(dirtyIDs))
@@ -271,7 +356,10 @@ func (m *MemDB) snapshot() error {
 
 		messagesToWrite = append(messagesToWrite, msg)
 	}
-	m.mu.RUnlock()
+	plusOnesToWrite := append([]UserPlusOne(nil), m.dirtyPlusOnes...)
+	mentionsToWrite := append([]Mention(nil), m.dirtyMentions...)
+	versionsToWrite := append([]MessageVersion(nil), m.dirtyVersions...)
+	m.mu.Unlock()
 
 	// Sort by ID (ascending) - O(n log n) but much faster than recursion for large n
 	sort.Slice(messagesToWrite, func(i, j int) bool {
@@ -283,28 +371,76 @@ func (m *MemDB) snapshot() error {
 	if len(messagesToWrite) > 0 {
 		if err := m.batchInsertMessages(messagesToWrite); err != nil {
 			log.Printf("MemDB: snapshot failed to batch insert: %v", err)
+			m.restoreDirty(dirtyIDs)
 			return err
 		}
 		messagesWritten = len(messagesToWrite)
 	}
 
-	// Clear dirty flags after successful write (requires write lock)
-	m.mu.Lock()
-	for _, id := range dirtyIDs {
-		delete(m.dirtyMessages, id)
+	// +1, mention and version rows go after messages so their message rows exist
+	if len(plusOnesToWrite) > 0 {
+		if err := m.insertUserPlusOnes(plusOnesToWrite); err != nil {
+			log.Printf("MemDB: snapshot failed to insert +1s: %v", err)
+			m.restoreDirty(dirtyIDs)
+			return err
+		}
+	}
+	if len(mentionsToWrite) > 0 {
+		if err := m.sqliteDB.RecordMentions(mentionsToWrite); err != nil {
+			log.Printf("MemDB: snapshot failed to insert mentions: %v", err)
+			m.restoreDirty(dirtyIDs)
+			return err
+		}
+	}
+	if len(versionsToWrite) > 0 {
+		if err := m.sqliteDB.RecordMessageVersions(versionsToWrite); err != nil {
+			log.Printf("MemDB: snapshot failed to insert message versions: %v", err)
+			m.restoreDirty(dirtyIDs)
+			return err
+		}
 	}
+
+	// Rows added during the write stay queued for the next snapshot
+	m.mu.Lock()
+	m.dirtyPlusOnes = m.dirtyPlusOnes[len(plusOnesToWrite):]
+	m.dirtyMentions = m.dirtyMentions[len(mentionsToWrite):]
+	m.dirtyVersions = m.dirtyVersions[len(versionsToWrite):]
 	m.mu.Unlock()
 
+	// Everything in the closed WAL segments is now in SQLite
+	if m.wal != nil {
+		if err := m.wal.truncate(walSeq); err != nil {
+			log.Printf("MemDB: failed to truncate WAL: %v", err)
+		}
+	}
+
 	log.Printf("MemDB: snapshot completed - %d messages written, %d old messages skipped (will be deleted) in %v",
 		messagesWritten, messagesSkipped, time.Since(start))
 	return nil
 }
 
-// batchInsertMessages performs a batched INSERT OR REPLACE for messages
+// restoreDirty marks messages dirty again after a failed snapshot
+func (m *MemDB) restoreDirty(ids []int64) {
+	m.mu.Lock()
+	m.restoreDirtyLocked(ids)
+	m.mu.Unlock()
+}
+
+func (m *MemDB) restoreDirtyLocked(ids []int64) {
+	for _, id := range ids {
+		if _, exists := m.messages[id]; exists {
+			m.dirtyMessages[id] = true
+		}
+	}
+}
+
+// batchInsertMessages performs a batched upsert for messages.
+// An upsert rather than INSERT OR REPLACE: REPLACE deletes the existing row
+// first, which cascades to replies, versions and +1s of that message.
 // SQLite 3.32.0+ has a parameter limit of 32766, but optimal batch size is smaller
 // due to query building and parsing overhead (string concatenation + SQL parse)
 func (m *MemDB) batchInsertMessages(messages []*Message) error {
-	const fieldsPerMessage = 11
+	const fieldsPerMessage = 13
 	// Optimal batch size balances:
 	// - Fewer SQL statements (larger batches)
 	// - Less string building overhead (smaller batches)
@@ -326,11 +462,12 @@ func (m *MemDB) batchInsertMessages(messages []*Message) error {
 		batch := messages[i:end]
 
 		// Build multi-row INSERT statement
-		// INSERT OR REPLACE INTO Message (...) VALUES (?,?,...), (?,?,...), ...
+		// INSERT INTO Message (...) VALUES (?,?,...), (?,?,...), ... ON CONFLICT DO UPDATE
 		var queryBuilder strings.Builder
-		queryBuilder.WriteString(`INSERT OR REPLACE INTO Message
+		queryBuilder.WriteString(`INSERT INTO Message
 			(id, channel_id, subchannel_id, parent_id, thread_root_id,
-			 author_user_id, author_nickname, content, created_at, edited_at, deleted_at)
+			 author_user_id, author_nickname, content, created_at, edited_at, deleted_at,
+			 plus_one_count, plus_one_anon_count)
 			VALUES `)
 
 		args := make([]interface{}, 0, len(batch)*fieldsPerMessage)
@@ -338,14 +475,21 @@ func (m *MemDB) batchInsertMessages(messages []*Message) error {
 			if j > 0 {
 				queryBuilder.WriteString(", ")
 			}
-			queryBuilder.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			queryBuilder.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 
 			args = append(args,
 				msg.ID, msg.ChannelID, msg.SubchannelID, msg.ParentID, msg.ThreadRootID,
 				msg.AuthorUserID, msg.AuthorNickname, msg.Content, msg.CreatedAt,
-				msg.EditedAt, msg.DeletedAt,
+				msg.EditedAt, msg.DeletedAt, msg.PlusOneCount, msg.PlusOneAnonCount,
 			)
 		}
+		queryBuilder.WriteString(` ON CONFLICT(id) DO UPDATE SET
+			channel_id = excluded.channel_id, subchannel_id = excluded.subchannel_id,
+			parent_id = excluded.parent_id, thread_root_id = excluded.thread_root_id,
+			author_user_id = excluded.author_user_id, author_nickname = excluded.author_nickname,
+			content = excluded.content, created_at = excluded.created_at,
+			edited_at = excluded.edited_at, deleted_at = excluded.deleted_at,
+			plus_one_count = excluded.plus_one_count, plus_one_anon_count = excluded.plus_one_anon_count`)
 
 		// Execute batch
 		if _, err := tx.Exec(queryBuilder.String(), args...); err != nil {
@@ -353,6 +497,11 @@ func (m *MemDB) batchInsertMessages(messages []*Message) error {
 		}
 	}
 
+	// Keep the full-text search index in sync in the same transaction
+	if err := indexMessagesForSearch(tx, messages); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -386,6 +535,7 @@ func (m *MemDB) hardDeleteOldMessages() int {
 
 		// Remove from main map
 		delete(m.messages, msgID)
+		delete(m.plusOnes, msgID)
 
 		// Remove from channel index
 		channelMsgs := m.messagesByChannel[msg.ChannelID]
@@ -424,10 +574,13 @@ func (m *MemDB) hardDeleteOldMessages() int {
 	return deletedCount
 }
 
-// Close shuts down the background snapshot goroutine
+// Close shuts down the background snapshot goroutine after a final snapshot
 func (m *MemDB) Close() error {
 	close(m.shutdown)
 	m.wg.Wait()
+	if m.wal != nil {
+		return m.wal.close()
+	}
 	return nil
 }
 
@@ -550,7 +703,7 @@ func (m *MemDB) GetActiveSessions(withinSeconds int64) ([]Session, error) {
 
 // === Channel Operations ===
 
-// ListChannels returns all channels
+// ListChannels returns all public top-level channels
 func (m *MemDB) ListChannels() ([]*Channel, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -561,6 +714,10 @@ func (m *MemDB) ListChannels() ([]*Channel, error) {
 		if ch.ParentID != nil {
 			continue
 		}
+		// DMs and private channels are only listed for their members
+		if ch.IsPrivate {
+			continue
+		}
 		// Return copies to prevent external mutation
 		chCopy := *ch
 		channels = append(channels, &chCopy)
@@ -574,11 +731,18 @@ func (m *MemDB) ListChannels() ([]*Channel, error) {
 	return channels, nil
 }
 
-// CountChannels returns the number of channels
+// CountChannels returns the number of public channels
 func (m *MemDB) CountChannels() uint32 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return uint32(len(m.channels))
+
+	var count uint32
+	for _, ch := range m.channels {
+		if !ch.IsPrivate {
+			count++
+		}
+	}
+	return count
 }
 
 // GetChannel retrieves a channel by ID
@@ -659,6 +823,7 @@ func (m *MemDB) PostMessage(channelID int64, subchannelID, parentID, authorUserI
 	if threadRootID != nil {
 		m.messagesByThread[*threadRootID] = append(m.messagesByThread[*threadRootID], messageID)
 	}
+	m.logWAL(walRecord{Op: walOpPostMessage, Message: newWALMessage(message)})
 	m.mu.Unlock()
 
 	return messageID, message, nil
@@ -690,6 +855,7 @@ func (m *MemDB) CreateSystemMessage(channelID int64, content string) (int64, *Me
 
 	// Update channel index
 	m.messagesByChannel[channelID] = append(m.messagesByChannel[channelID], messageID)
+	m.logWAL(walRecord{Op: walOpPostMessage, Message: newWALMessage(message)})
 	m.mu.Unlock()
 
 	return messageID, message, nil
@@ -709,6 +875,29 @@ func (m *MemDB) GetMessage(messageID int64) (*Message, error) {
 	return &messageCopy, nil
 }
 
+// copyMessage copies a cached message so it can be returned after the lock is
+// released. ReplyCount is atomic, so it is loaded rather than copied with the
+// struct.
+func copyMessage(msg *Message) *Message {
+	msgCopy := &Message{
+		ID:               msg.ID,
+		ChannelID:        msg.ChannelID,
+		SubchannelID:     msg.SubchannelID,
+		ParentID:         msg.ParentID,
+		ThreadRootID:     msg.ThreadRootID,
+		AuthorUserID:     msg.AuthorUserID,
+		AuthorNickname:   msg.AuthorNickname,
+		Content:          msg.Content,
+		CreatedAt:        msg.CreatedAt,
+		EditedAt:         msg.EditedAt,
+		DeletedAt:        msg.DeletedAt,
+		PlusOneCount:     msg.PlusOneCount,
+		PlusOneAnonCount: msg.PlusOneAnonCount,
+	}
+	msgCopy.ReplyCount.Store(msg.ReplyCount.Load())
+	return msgCopy
+}
+
 // GetRootMessages retrieves top-level messages in a channel (no parent)
 func (m *MemDB) GetRootMessages(channelID int64, fromMessageID int64, limit int) ([]Message, error) {
 	m.mu.RLock()
@@ -933,8 +1122,9 @@ func (m *MemDB) SoftDeleteMessage(messageID uint64, nickname string) (*Message,
 		return nil, fmt.Errorf("message already deleted")
 	}
 
-	// Mark as deleted
+	// Mark as deleted, keeping the deleted content in the version history
 	now := nowMillis()
+	version := m.recordVersion(msg, "deleted", now)
 	msg.DeletedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
 
@@ -944,6 +1134,7 @@ func (m *MemDB) SoftDeleteMessage(messageID uint64, nickname string) (*Message,
 			parent.ReplyCount.Add(^uint32(0)) // Atomic decrement (two's complement of 0 = -1)
 		}
 	}
+	m.logWAL(walRecord{Op: walOpDeleteMessage, Message: newWALMessage(msg), Version: &version})
 
 	return msg, nil
 }
@@ -963,8 +1154,9 @@ func (m *MemDB) AdminSoftDeleteMessage(messageID uint64, adminNickname string) (
 		return nil, fmt.Errorf("message already deleted")
 	}
 
-	// Mark as deleted
+	// Mark as deleted, keeping the deleted content in the version history
 	now := nowMillis()
+	version := m.recordVersion(msg, "deleted", now)
 	msg.DeletedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
 
@@ -974,6 +1166,7 @@ func (m *MemDB) AdminSoftDeleteMessage(messageID uint64, adminNickname string) (
 			parent.ReplyCount.Add(^uint32(0)) // Atomic decrement (two's complement of 0 = -1)
 		}
 	}
+	m.logWAL(walRecord{Op: walOpDeleteMessage, Message: newWALMessage(msg), Version: &version})
 
 	return msg, nil
 }
@@ -999,11 +1192,13 @@ func (m *MemDB) UpdateMessage(messageID uint64, userID uint64, newContent string
 		return nil, errors.New("cannot edit deleted message")
 	}
 
-	// Update content and edited_at timestamp
+	// Update content and edited_at timestamp, keeping the old content in the version history
 	now := nowMillis()
+	version := m.recordVersion(msg, "edited", now)
 	msg.Content = newContent
 	msg.EditedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
+	m.logWAL(walRecord{Op: walOpEditMessage, Message: newWALMessage(msg), Version: &version})
 
 	return msg, nil
 }
@@ -1027,11 +1222,13 @@ func (m *MemDB) AdminUpdateMessage(messageID uint64, userID uint64, newContent s
 		return nil, errors.New("cannot edit deleted message")
 	}
 
-	// Update content and edited_at timestamp
+	// Update content and edited_at timestamp, keeping the old content in the version history
 	now := nowMillis()
+	version := m.recordVersion(msg, "edited", now)
 	msg.Content = newContent
 	msg.EditedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
+	m.logWAL(walRecord{Op: walOpEditMessage, Message: newWALMessage(msg), Version: &version})
 
 	return msg, nil
 }
@@ -1288,6 +1485,11 @@ func (m *MemDB) LogAdminAction(adminUserID uint64, adminNickname, actionType, de
 	return m.sqliteDB.LogAdminAction(adminUserID, adminNickname, actionType, details)
 }
 
+// ListAdminActions queries the audit log (passthrough to SQLite)
+func (m *MemDB) ListAdminActions(filter AdminActionFilter) ([]*AdminAction, error) {
+	return m.sqliteDB.ListAdminActions(filter)
+}
+
 // ===== Channel Deletion =====
 
 // DeleteChannel deletes a channel from both SQLite and in-memory cache
@@ -1315,6 +1517,28 @@ func (m *MemDB) DeleteChannel(channelID uint64) error {
 	return nil
 }
 
+// UpdateChannel updates a channel's settings in SQLite and the cache
+func (m *MemDB) UpdateChannel(channelID int64, displayName string, description *string, channelType uint8, retentionHours uint32, archived bool) error {
+	if err := m.sqliteDB.UpdateChannel(channelID, displayName, description, channelType, retentionHours, archived); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if ch, exists := m.channels[channelID]; exists {
+		// Replace rather than mutate, GetChannel copies are taken without the lock held
+		updated := *ch
+		updated.DisplayName = displayName
+		updated.Description = description
+		updated.ChannelType = channelType
+		updated.MessageRetentionHours = retentionHours
+		updated.Archived = archived
+		m.channels[channelID] = &updated
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
 // DeleteUser deletes a user account and anonymizes their messages
 // Also removes all in-memory sessions for this user
 // Returns the nickname of the deleted user
@@ -1343,6 +1567,7 @@ func (m *MemDB) DeleteUser(userID uint64) (string, error) {
 			m.dirtyMessages[msg.ID] = true
 		}
 	}
+	m.logWAL(walRecord{Op: walOpDeleteUser, UserID: int64(userID), Nickname: nickname})
 	m.mu.Unlock()
 
 	log.Printf("MemDB: deleted user and anonymized messages: id=%d, nickname=%s", userID, nickname)
@@ -388,7 +388,7 @@ func TestNestedRepliesSnapshot(t *testing.T) {
 
 		// Verify all messages exist
 		for _, id := range []int64{rootID, reply1ID, reply2ID, reply3ID} {
-			msg, err := db.GetMessage(uint64(id))
+			msg, err := db.GetMessage(id)
 			if err != nil {
 				t.Fatalf("failed to get message %d: %v", id, err)
 			}
@@ -398,17 +398,17 @@ func TestNestedRepliesSnapshot(t *testing.T) {
 		}
 
 		// Verify parent relationships
-		msg2, _ := db.GetMessage(uint64(reply1ID))
+		msg2, _ := db.GetMessage(reply1ID)
 		if msg2.ParentID == nil || *msg2.ParentID != rootID {
 			t.Errorf("reply1 parent should be %d", rootID)
 		}
 
-		msg3, _ := db.GetMessage(uint64(reply2ID))
+		msg3, _ := db.GetMessage(reply2ID)
 		if msg3.ParentID == nil || *msg3.ParentID != reply1ID {
 			t.Errorf("reply2 parent should be %d", reply1ID)
 		}
 
-		msg4, _ := db.GetMessage(uint64(reply3ID))
+		msg4, _ := db.GetMessage(reply3ID)
 		if msg4.ParentID == nil || *msg4.ParentID != reply2ID {
 			t.Errorf("reply3 parent should be %d", reply2ID)
 		}
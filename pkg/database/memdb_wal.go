@@ -0,0 +1,454 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// walSyncInterval is how often appended WAL records are fsynced. Records are
+// written to the file immediately, so a crashed or killed process loses
+// nothing; only an OS crash or power loss can lose the last interval.
+const walSyncInterval = 50 * time.Millisecond
+
+// walSegmentSuffix is the file extension of WAL segments
+const walSegmentSuffix = ".wal"
+
+// walOp identifies the MemDB change a WAL record describes
+type walOp uint8
+
+const (
+	walOpPostMessage walOp = iota + 1
+	walOpEditMessage
+	walOpDeleteMessage
+	walOpPlusOne
+	walOpMentions
+	walOpDeleteUser
+)
+
+// walRecord is one change to MemDB that hasn't reached SQLite yet.
+// Message changes carry the full resulting message, so replaying a record
+// is an upsert and replaying it twice is harmless.
+type walRecord struct {
+	Op       walOp           `json:"op"`
+	Message  *walMessage     `json:"message,omitempty"`
+	PlusOne  *UserPlusOne    `json:"plus_one,omitempty"`
+	Mentions []Mention       `json:"mentions,omitempty"`
+	Version  *MessageVersion `json:"version,omitempty"`
+	UserID   int64           `json:"user_id,omitempty"`
+	Nickname string          `json:"nickname,omitempty"`
+}
+
+// walMessage is the persisted state of a message
+type walMessage struct {
+	ID               int64  `json:"id"`
+	ChannelID        int64  `json:"channel_id"`
+	SubchannelID     *int64 `json:"subchannel_id,omitempty"`
+	ParentID         *int64 `json:"parent_id,omitempty"`
+	ThreadRootID     *int64 `json:"thread_root_id,omitempty"`
+	AuthorUserID     *int64 `json:"author_user_id,omitempty"`
+	AuthorNickname   string `json:"author_nickname"`
+	Content          string `json:"content"`
+	CreatedAt        int64  `json:"created_at"`
+	EditedAt         *int64 `json:"edited_at,omitempty"`
+	DeletedAt        *int64 `json:"deleted_at,omitempty"`
+	PlusOneCount     uint32 `json:"plus_one_count,omitempty"`
+	PlusOneAnonCount uint32 `json:"plus_one_anon_count,omitempty"`
+}
+
+func newWALMessage(msg *Message) *walMessage {
+	return &walMessage{
+		ID:               msg.ID,
+		ChannelID:        msg.ChannelID,
+		SubchannelID:     msg.SubchannelID,
+		ParentID:         msg.ParentID,
+		ThreadRootID:     msg.ThreadRootID,
+		AuthorUserID:     msg.AuthorUserID,
+		AuthorNickname:   msg.AuthorNickname,
+		Content:          msg.Content,
+		CreatedAt:        msg.CreatedAt,
+		EditedAt:         msg.EditedAt,
+		DeletedAt:        msg.DeletedAt,
+		PlusOneCount:     msg.PlusOneCount,
+		PlusOneAnonCount: msg.PlusOneAnonCount,
+	}
+}
+
+// memWAL is an append-only log of MemDB changes made since the last snapshot.
+// It is split into numbered segments: each snapshot starts a new segment and,
+// once the snapshot is in SQLite, deletes the segments it covered.
+//
+// Each record is framed as [u32 length][u32 CRC-32][JSON payload]. A record
+// cut short by a crash fails its length or checksum and ends replay of that
+// segment.
+type memWAL struct {
+	mu       sync.Mutex
+	dir      string
+	seq      uint64   // Active segment number
+	file     *os.File // Active segment
+	unsynced bool     // Records appended since the last fsync
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// walDirForDB returns the WAL directory for a database path, or "" for
+// in-memory databases, which have nothing to recover
+func walDirForDB(dbPath string) string {
+	if dbPath == "" || dbPath == ":memory:" || strings.HasPrefix(dbPath, "file:") {
+		return ""
+	}
+	return dbPath + ".memwal"
+}
+
+// openMemWAL opens the WAL directory, creating it if needed. Existing
+// segments are left for replay; call start before appending.
+func openMemWAL(dir string) (*memWAL, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+	w := &memWAL{
+		dir:      dir,
+		shutdown: make(chan struct{}),
+	}
+	segments, err := w.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		w.seq = segments[len(segments)-1]
+	}
+	return w, nil
+}
+
+// segments returns the numbers of the segments on disk in ascending order
+func (w *memWAL) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WAL segments: %w", err)
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, walSegmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, walSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (w *memWAL) segmentPath(seq uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%016d%s", seq, walSegmentSuffix))
+}
+
+// readAll returns the records of every segment on disk, oldest first
+func (w *memWAL) readAll() ([]walRecord, error) {
+	segments, err := w.segments()
+	if err != nil {
+		return nil, err
+	}
+	var records []walRecord
+	for _, seq := range segments {
+		data, err := os.ReadFile(w.segmentPath(seq))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WAL segment %d: %w", seq, err)
+		}
+		segmentRecords, err := decodeWALRecords(data)
+		if err != nil {
+			// Expected after a crash mid-append: keep what was complete
+			log.Printf("MemDB: WAL segment %d: %v (kept %d records)", seq, err, len(segmentRecords))
+		}
+		records = append(records, segmentRecords...)
+	}
+	return records, nil
+}
+
+// decodeWALRecords parses framed records, stopping at the first incomplete
+// or corrupt one
+func decodeWALRecords(data []byte) ([]walRecord, error) {
+	var records []walRecord
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return records, errors.New("truncated record header")
+		}
+		length := binary.BigEndian.Uint32(data[0:4])
+		checksum := binary.BigEndian.Uint32(data[4:8])
+		if uint64(len(data)-8) < uint64(length) {
+			return records, errors.New("truncated record")
+		}
+		payload := data[8 : 8+length]
+		if crc32.ChecksumIEEE(payload) != checksum {
+			return records, errors.New("record checksum mismatch")
+		}
+		var rec walRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return records, fmt.Errorf("invalid record: %w", err)
+		}
+		records = append(records, rec)
+		data = data[8+length:]
+	}
+	return records, nil
+}
+
+// start opens a new active segment after the existing ones and starts the
+// background fsync loop
+func (w *memWAL) start() error {
+	w.mu.Lock()
+	err := w.openSegmentLocked(w.seq + 1)
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go w.syncLoop()
+	return nil
+}
+
+func (w *memWAL) openSegmentLocked(seq uint64) error {
+	file, err := os.OpenFile(w.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open WAL segment: %w", err)
+	}
+	w.file = file
+	w.seq = seq
+	w.unsynced = false
+	return nil
+}
+
+// append writes a record to the active segment. The record reaches the OS
+// immediately and is fsynced by the next sync tick.
+func (w *memWAL) append(rec walRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode WAL record: %w", err)
+	}
+	frame := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	copy(frame[8:], payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return errors.New("WAL is closed")
+	}
+	// One write per record, so a crash can only cut off the last record
+	if _, err := w.file.Write(frame); err != nil {
+		return fmt.Errorf("failed to append WAL record: %w", err)
+	}
+	w.unsynced = true
+	return nil
+}
+
+// rotate syncs and closes the active segment and opens the next one.
+// Returns the number of the closed segment: once a snapshot taken at the
+// time of the rotation is in SQLite, segments up to it can be removed.
+func (w *memWAL) rotate() (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, errors.New("WAL is closed")
+	}
+	if err := w.file.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to sync WAL segment: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close WAL segment: %w", err)
+	}
+	closed := w.seq
+	if err := w.openSegmentLocked(closed + 1); err != nil {
+		w.file = nil
+		return 0, err
+	}
+	return closed, nil
+}
+
+// truncate removes every segment up to and including seq
+func (w *memWAL) truncate(seq uint64) error {
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if s > seq {
+			break
+		}
+		if err := os.Remove(w.segmentPath(s)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove WAL segment %d: %w", s, err)
+		}
+	}
+	return nil
+}
+
+// syncLoop fsyncs appended records in batches
+func (w *memWAL) syncLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(walSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.sync(); err != nil {
+				log.Printf("MemDB: WAL sync failed: %v", err)
+			}
+		case <-w.shutdown:
+			return
+		}
+	}
+}
+
+func (w *memWAL) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil || !w.unsynced {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.unsynced = false
+	return nil
+}
+
+// close stops the sync loop and closes the active segment after a final
+// fsync. An empty active segment is removed.
+func (w *memWAL) close() error {
+	close(w.shutdown)
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	info, statErr := w.file.Stat()
+	err := w.file.Close()
+	w.file = nil
+	if statErr == nil && info.Size() == 0 {
+		os.Remove(w.segmentPath(w.seq))
+	}
+	return err
+}
+
+// logWAL appends a record for a change just made in memory. Must be called
+// with m.mu held for writing, so records are ordered like the changes and a
+// snapshot's rotation falls cleanly between them.
+// A failed append is logged rather than failing the operation: the change is
+// already visible and the next snapshot still persists it.
+func (m *MemDB) logWAL(rec walRecord) {
+	if m.wal == nil {
+		return
+	}
+	if err := m.wal.append(rec); err != nil {
+		log.Printf("MemDB: %v", err)
+	}
+}
+
+// replayWAL applies records left by a previous run that never reached
+// SQLite. Called from loadFromSQLite after messages are loaded and before
+// indexes are sorted; replayed changes are marked dirty for the next snapshot.
+func (m *MemDB) replayWAL(records []walRecord) {
+	for _, rec := range records {
+		switch rec.Op {
+		case walOpPostMessage, walOpEditMessage, walOpDeleteMessage, walOpPlusOne:
+			if rec.Message != nil {
+				m.replayMessage(rec.Message)
+			}
+			if rec.Version != nil {
+				if _, exists := m.messages[rec.Version.MessageID]; exists {
+					m.dirtyVersions = append(m.dirtyVersions, *rec.Version)
+				}
+			}
+			if rec.PlusOne != nil {
+				if _, exists := m.messages[rec.PlusOne.MessageID]; exists {
+					users := m.plusOnes[rec.PlusOne.MessageID]
+					if users == nil {
+						users = make(map[int64]bool)
+						m.plusOnes[rec.PlusOne.MessageID] = users
+					}
+					users[rec.PlusOne.UserID] = true
+					m.dirtyPlusOnes = append(m.dirtyPlusOnes, *rec.PlusOne)
+				}
+			}
+
+		case walOpMentions:
+			for _, mention := range rec.Mentions {
+				if _, exists := m.messages[mention.MessageID]; !exists {
+					continue
+				}
+				m.addMention(mention.UserID, mention.MessageID)
+				m.dirtyMentions = append(m.dirtyMentions, mention)
+			}
+
+		case walOpDeleteUser:
+			// SQLite already anonymized what it had; catch up replayed messages
+			for _, msg := range m.messages {
+				if msg.AuthorUserID != nil && *msg.AuthorUserID == rec.UserID {
+					msg.AuthorUserID = nil
+					msg.AuthorNickname = rec.Nickname
+					m.dirtyMessages[msg.ID] = true
+				}
+			}
+			delete(m.mentionsByUser, rec.UserID)
+
+		default:
+			log.Printf("MemDB: skipping unknown WAL record op %d", rec.Op)
+		}
+	}
+}
+
+// replayMessage upserts a message from the WAL into memory
+func (m *MemDB) replayMessage(wm *walMessage) {
+	// Messages of channels deleted after the record was written stay deleted
+	if _, exists := m.channels[wm.ChannelID]; !exists {
+		return
+	}
+
+	msg, exists := m.messages[wm.ID]
+	if !exists {
+		msg = &Message{ID: wm.ID}
+		m.messages[wm.ID] = msg
+		m.messagesByChannel[wm.ChannelID] = append(m.messagesByChannel[wm.ChannelID], wm.ID)
+		if wm.ParentID != nil {
+			m.messagesByParent[*wm.ParentID] = append(m.messagesByParent[*wm.ParentID], wm.ID)
+		}
+		if wm.ThreadRootID != nil {
+			m.messagesByThread[*wm.ThreadRootID] = append(m.messagesByThread[*wm.ThreadRootID], wm.ID)
+		}
+	}
+
+	msg.ChannelID = wm.ChannelID
+	msg.SubchannelID = wm.SubchannelID
+	msg.ParentID = wm.ParentID
+	msg.ThreadRootID = wm.ThreadRootID
+	msg.AuthorUserID = wm.AuthorUserID
+	msg.AuthorNickname = wm.AuthorNickname
+	msg.Content = wm.Content
+	msg.CreatedAt = wm.CreatedAt
+	msg.EditedAt = wm.EditedAt
+	msg.DeletedAt = wm.DeletedAt
+	msg.PlusOneCount = wm.PlusOneCount
+	msg.PlusOneAnonCount = wm.PlusOneAnonCount
+	m.dirtyMessages[wm.ID] = true
+}
@@ -0,0 +1,419 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// crashMemDB abandons a MemDB the way a killed process would: the WAL file
+// is closed but nothing is snapshotted
+func crashMemDB(t *testing.T, memDB *MemDB) {
+	t.Helper()
+	if memDB.wal == nil {
+		t.Fatal("MemDB has no WAL")
+	}
+	if err := memDB.wal.close(); err != nil {
+		t.Fatalf("closing WAL: %v", err)
+	}
+}
+
+func walSegmentCount(t *testing.T, dbPath string) int {
+	t.Helper()
+	entries, err := os.ReadDir(walDirForDB(dbPath))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	return len(entries)
+}
+
+func TestMemDBWALReplay(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	rootID, _, err := memDB.PostMessage(channelID, nil, nil, &aliceID, "", "Original root")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	replyID, _, err := memDB.PostMessage(channelID, nil, &rootID, nil, "carol", "Reply")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	doomedID, _, err := memDB.PostMessage(channelID, nil, &rootID, nil, "dave", "Oops")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	bobMsgID, _, err := memDB.PostMessage(channelID, nil, nil, &bobID, "", "Bob was here")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if _, err := memDB.UpdateMessage(uint64(rootID), uint64(aliceID), "Edited root"); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if _, err := memDB.SoftDeleteMessage(uint64(doomedID), "dave"); err != nil {
+		t.Fatalf("SoftDeleteMessage: %v", err)
+	}
+	if _, _, err := memDB.PlusOneMessage(uint64(replyID), &aliceID); err != nil {
+		t.Fatalf("PlusOneMessage: %v", err)
+	}
+	if err := memDB.RecordMentions([]Mention{{UserID: aliceID, MessageID: replyID, ChannelID: channelID}}); err != nil {
+		t.Fatalf("RecordMentions: %v", err)
+	}
+	if _, err := memDB.DeleteUser(uint64(bobID)); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	crashMemDB(t, memDB)
+
+	recovered, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to recover MemDB: %v", err)
+	}
+	defer recovered.Close()
+
+	root, err := recovered.GetMessage(rootID)
+	if err != nil {
+		t.Fatalf("root lost: %v", err)
+	}
+	if root.Content != "Edited root" || root.EditedAt == nil {
+		t.Errorf("root = %q (edited=%v), want the edit replayed", root.Content, root.EditedAt != nil)
+	}
+	if root.ReplyCount.Load() != 1 {
+		t.Errorf("root ReplyCount = %d, want 1", root.ReplyCount.Load())
+	}
+
+	reply, err := recovered.GetMessage(replyID)
+	if err != nil {
+		t.Fatalf("reply lost: %v", err)
+	}
+	if reply.PlusOneCount != 1 {
+		t.Errorf("reply PlusOneCount = %d, want 1", reply.PlusOneCount)
+	}
+	if _, counted, err := recovered.PlusOneMessage(uint64(replyID), &aliceID); err != nil || counted {
+		t.Errorf("duplicate +1 after replay: counted=%v err=%v", counted, err)
+	}
+
+	doomed, err := recovered.GetMessage(doomedID)
+	if err != nil {
+		t.Fatalf("deleted message lost: %v", err)
+	}
+	if doomed.DeletedAt == nil {
+		t.Error("delete was not replayed")
+	}
+
+	bobMsg, err := recovered.GetMessage(bobMsgID)
+	if err != nil {
+		t.Fatalf("bob's message lost: %v", err)
+	}
+	if bobMsg.AuthorUserID != nil || bobMsg.AuthorNickname != "bob" {
+		t.Errorf("bob's message author = %v/%q, want anonymized", bobMsg.AuthorUserID, bobMsg.AuthorNickname)
+	}
+
+	mentions, err := recovered.ListMentions(aliceID, nil, 10)
+	if err != nil {
+		t.Fatalf("ListMentions: %v", err)
+	}
+	if len(mentions) != 1 || mentions[0].ID != replyID {
+		t.Errorf("mentions = %d, want the reply", len(mentions))
+	}
+
+	// The replay was snapshotted at startup, so SQLite has everything and
+	// the old segments are gone
+	if _, err := db.GetMessage(replyID); err != nil {
+		t.Errorf("replayed reply not in SQLite: %v", err)
+	}
+	if n := walSegmentCount(t, dbPath); n != 1 {
+		t.Errorf("WAL segments after startup snapshot = %d, want only the active one", n)
+	}
+}
+
+func TestMemDBWALTruncatedAfterSnapshot(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	if _, _, err := memDB.PostMessage(channelID, nil, nil, nil, "alice", "Snapshotted"); err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	records, err := memDB.wal.readAll()
+	if err != nil {
+		t.Fatalf("readAll: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("WAL has %d records after snapshot, want 0", len(records))
+	}
+
+	// Changes after the snapshot stay in the WAL
+	afterID, _, err := memDB.PostMessage(channelID, nil, nil, nil, "alice", "After snapshot")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	records, err = memDB.wal.readAll()
+	if err != nil {
+		t.Fatalf("readAll: %v", err)
+	}
+	if len(records) != 1 || records[0].Message == nil || records[0].Message.ID != afterID {
+		t.Fatalf("WAL records = %+v, want the post after the snapshot", records)
+	}
+	crashMemDB(t, memDB)
+
+	recovered, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to recover MemDB: %v", err)
+	}
+	defer recovered.Close()
+	if _, err := recovered.GetMessage(afterID); err != nil {
+		t.Errorf("post after snapshot lost: %v", err)
+	}
+}
+
+func TestMemDBWALTornRecord(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	keptID, _, err := memDB.PostMessage(channelID, nil, nil, nil, "alice", "Complete")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	segment := memDB.wal.segmentPath(memDB.wal.seq)
+	crashMemDB(t, memDB)
+
+	// A record header promising more bytes than were written
+	f, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 0xde, 0xad, 0xbe, 0xef, '{', '"', 'o'})
+	f.Close()
+
+	recovered, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to recover MemDB: %v", err)
+	}
+	defer recovered.Close()
+	if _, err := recovered.GetMessage(keptID); err != nil {
+		t.Errorf("complete record before the torn one was lost: %v", err)
+	}
+}
+
+func TestDecodeWALRecordsCorrupt(t *testing.T) {
+	w := &memWAL{dir: t.TempDir(), shutdown: make(chan struct{})}
+	if err := w.start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.append(walRecord{Op: walOpDeleteUser, UserID: int64(i + 1)}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	segment := w.segmentPath(w.seq)
+	if err := w.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	data, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	records, err := decodeWALRecords(data)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("decode intact segment: %d records, err=%v", len(records), err)
+	}
+
+	// Flip a payload byte in the last record
+	data[len(data)-2] ^= 0xff
+	records, err = decodeWALRecords(data)
+	if err == nil {
+		t.Error("expected a checksum error")
+	}
+	if len(records) != 2 {
+		t.Errorf("decoded %d records before the corrupt one, want 2", len(records))
+	}
+}
+
+// walCrashEnv hands the database path to the writer subprocess
+const walCrashEnv = "SUPERCHAT_WAL_CRASH_DB"
+
+// runWALCrashWriter posts and edits messages until the process is killed,
+// printing each ID and its final content once the changes have returned
+func runWALCrashWriter(dbPath string) {
+	db, err := Open(dbPath)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	channels, err := memDB.ListChannels()
+	if err != nil || len(channels) == 0 {
+		fmt.Println("error: no channel")
+		os.Exit(1)
+	}
+	channelID := channels[0].ID
+	writer, err := memDB.GetUserByNickname("writer")
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
+	for i := 0; ; i++ {
+		content := fmt.Sprintf("message %d", i)
+		id, _, err := memDB.PostMessage(channelID, nil, nil, &writer.ID, "", content)
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		if i%5 == 0 {
+			content = fmt.Sprintf("edited %d", i)
+			if _, err := memDB.UpdateMessage(uint64(id), uint64(writer.ID), content); err != nil {
+				fmt.Println("error:", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("posted %d %s\n", id, strings.ReplaceAll(content, " ", "_"))
+
+		// Some of the history goes through a snapshot, the rest only the WAL
+		if i == 100 {
+			if err := memDB.snapshot(); err != nil {
+				fmt.Println("error:", err)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func TestMemDBWALCrashRecovery(t *testing.T) {
+	if dbPath := os.Getenv(walCrashEnv); dbPath != "" {
+		runWALCrashWriter(dbPath)
+		return
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "crash.db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	if _, err := db.CreateChannel("general", "General", nil, 1, 168, nil); err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	if _, err := db.CreateUser("writer", "hash", 0); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	db.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMemDBWALCrashRecovery$")
+	cmd.Env = append(os.Environ(), walCrashEnv+"="+dbPath)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting writer: %v", err)
+	}
+
+	// Kill the writer while it is still posting
+	posted := make(map[int64]string)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && len(posted) < 500 {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "error:") {
+			cmd.Process.Kill()
+			cmd.Wait()
+			t.Fatalf("writer failed: %s", line)
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "posted" {
+			continue
+		}
+		id, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			t.Fatalf("bad writer output %q", line)
+		}
+		posted[id] = strings.ReplaceAll(fields[2], "_", " ")
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatalf("killing writer: %v", err)
+	}
+	cmd.Wait()
+	if len(posted) < 500 {
+		t.Fatalf("writer exited after %d posts", len(posted))
+	}
+
+	db, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen DB: %v", err)
+	}
+	defer db.Close()
+	recovered, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to recover MemDB: %v", err)
+	}
+	defer recovered.Close()
+
+	for id, content := range posted {
+		msg, err := recovered.GetMessage(id)
+		if err != nil {
+			t.Fatalf("message %d lost in crash: %v", id, err)
+		}
+		if msg.Content != content {
+			t.Errorf("message %d content = %q, want %q", id, msg.Content, content)
+		}
+	}
+}
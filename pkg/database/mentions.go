@@ -0,0 +1,193 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Mention records that a registered user was @mentioned in a message
+type Mention struct {
+	UserID    int64
+	MessageID int64
+	ChannelID int64
+	CreatedAt int64
+}
+
+// GetUsersByNicknames looks up registered users by nickname (case-insensitive).
+// Nicknames without a registered user are skipped.
+func (db *DB) GetUsersByNicknames(nicknames []string) ([]*User, error) {
+	if len(nicknames) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(nicknames))
+	for i, nickname := range nicknames {
+		args[i] = nickname
+	}
+	rows, err := db.conn.Query(`
+		SELECT id, nickname, user_flags, password_hash, created_at, last_seen, encryption_public_key
+		FROM User
+		WHERE nickname COLLATE NOCASE IN (?`+strings.Repeat(`, ?`, len(nicknames)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		user := &User{}
+		if err := rows.Scan(&user.ID, &user.Nickname, &user.UserFlags, &user.PasswordHash, &user.CreatedAt, &user.LastSeen, &user.EncryptionPublicKey); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// RecordMentions stores mentions. Mentions whose message or user no longer
+// exists, and mentions already stored, are skipped.
+func (db *DB) RecordMentions(mentions []Mention) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO Mention (user_id, message_id, channel_id, created_at)
+		SELECT ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM Message WHERE id = ?)
+		  AND EXISTS (SELECT 1 FROM User WHERE id = ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare mention insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, mention := range mentions {
+		if _, err := stmt.Exec(mention.UserID, mention.MessageID, mention.ChannelID, mention.CreatedAt, mention.MessageID, mention.UserID); err != nil {
+			return fmt.Errorf("failed to insert mention of user %d: %w", mention.UserID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListMentions returns messages that mention a user, newest first.
+// beforeID is an optional pagination cursor.
+func (db *DB) ListMentions(userID int64, beforeID *int64, limit int) ([]*Message, error) {
+	query := `
+		SELECT m.id, m.channel_id, m.subchannel_id, m.parent_id, m.thread_root_id, m.author_user_id,
+		       m.author_nickname, m.content, m.created_at, m.edited_at, m.deleted_at,
+		       m.plus_one_count, m.plus_one_anon_count
+		FROM Mention mn
+		INNER JOIN Message m ON m.id = mn.message_id
+		WHERE mn.user_id = ?
+		  AND m.deleted_at IS NULL`
+	args := []interface{}{userID}
+	if beforeID != nil {
+		query += ` AND m.id < ?`
+		args = append(args, *beforeID)
+	}
+	query += ` ORDER BY m.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mentions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// GetUsersByNicknames looks up registered users by nickname (case-insensitive)
+func (m *MemDB) GetUsersByNicknames(nicknames []string) ([]*User, error) {
+	return m.sqliteDB.GetUsersByNicknames(nicknames)
+}
+
+// RecordMentions stores mentions in memory. They are written to SQLite with
+// the next snapshot, after the messages they refer to.
+func (m *MemDB) RecordMentions(mentions []Mention) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, mention := range mentions {
+		if _, exists := m.messages[mention.MessageID]; !exists {
+			return ErrMessageNotFound
+		}
+	}
+	var added []Mention
+	for _, mention := range mentions {
+		if m.addMention(mention.UserID, mention.MessageID) {
+			added = append(added, mention)
+		}
+	}
+	if len(added) > 0 {
+		m.dirtyMentions = append(m.dirtyMentions, added...)
+		m.logWAL(walRecord{Op: walOpMentions, Mentions: added})
+	}
+	return nil
+}
+
+// addMention inserts a message ID into a user's sorted mention list.
+// Returns false if it was already there. Caller must hold m.mu.
+func (m *MemDB) addMention(userID, messageID int64) bool {
+	ids := m.mentionsByUser[userID]
+	idx := sort.Search(len(ids), func(i int) bool { return ids[i] >= messageID })
+	if idx < len(ids) && ids[idx] == messageID {
+		return false
+	}
+	ids = append(ids, 0)
+	copy(ids[idx+1:], ids[idx:])
+	ids[idx] = messageID
+	m.mentionsByUser[userID] = ids
+	return true
+}
+
+// ListMentions returns messages that mention a user, newest first.
+// beforeID is an optional pagination cursor.
+func (m *MemDB) ListMentions(userID int64, beforeID *int64, limit int) ([]*Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := m.mentionsByUser[userID]
+	var result []*Message
+	for i := len(ids) - 1; i >= 0 && len(result) < limit; i-- {
+		if beforeID != nil && ids[i] >= *beforeID {
+			continue
+		}
+		msg, exists := m.messages[ids[i]]
+		if !exists || msg.DeletedAt != nil {
+			continue
+		}
+		result = append(result, copyMessage(msg))
+	}
+	return result, nil
+}
+
+// loadMentions loads mentions of messages held in memory
+func (m *MemDB) loadMentions() error {
+	rows, err := m.sqliteDB.conn.Query(`SELECT user_id, message_id FROM Mention ORDER BY message_id`)
+	if err != nil {
+		return fmt.Errorf("failed to load mentions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, messageID int64
+		if err := rows.Scan(&userID, &messageID); err != nil {
+			return fmt.Errorf("failed to scan mention: %w", err)
+		}
+		if _, exists := m.messages[messageID]; !exists {
+			continue
+		}
+		m.addMention(userID, messageID)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetUsersByNicknames(t *testing.T) {
+	memDB, _ := newSearchTestDB(t)
+
+	aliceID, err := memDB.CreateUser("Alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	users, err := memDB.GetUsersByNicknames([]string{"alice", "nobody"})
+	if err != nil {
+		t.Fatalf("GetUsersByNicknames: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != aliceID {
+		t.Fatalf("expected only Alice, got %d users", len(users))
+	}
+}
+
+func TestMemDBMentions(t *testing.T) {
+	db, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	aliceID, err := memDB.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	var ids []int64
+	for _, content := range []string{"@alice one", "@alice two", "@alice three"} {
+		id, _, err := memDB.PostMessage(channelID, nil, nil, nil, "bob", content)
+		if err != nil {
+			t.Fatalf("PostMessage: %v", err)
+		}
+		if err := memDB.RecordMentions([]Mention{{UserID: aliceID, MessageID: id, ChannelID: channelID, CreatedAt: nowMillis()}}); err != nil {
+			t.Fatalf("RecordMentions: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	// Recording the same mention twice is a no-op
+	if err := memDB.RecordMentions([]Mention{{UserID: aliceID, MessageID: ids[0], ChannelID: channelID}}); err != nil {
+		t.Fatalf("RecordMentions: %v", err)
+	}
+
+	mentions, err := memDB.ListMentions(aliceID, nil, 2)
+	if err != nil {
+		t.Fatalf("ListMentions: %v", err)
+	}
+	if len(mentions) != 2 || mentions[0].ID != ids[2] || mentions[1].ID != ids[1] {
+		t.Fatalf("expected newest two mentions first, got %v", mentions)
+	}
+	mentions, err = memDB.ListMentions(aliceID, &ids[1], 10)
+	if err != nil {
+		t.Fatalf("ListMentions: %v", err)
+	}
+	if len(mentions) != 1 || mentions[0].ID != ids[0] {
+		t.Fatalf("expected only the oldest mention before %d", ids[1])
+	}
+
+	// Deleted messages drop out of the list
+	if _, err := memDB.SoftDeleteMessage(uint64(ids[2]), "bob"); err != nil {
+		t.Fatalf("SoftDeleteMessage: %v", err)
+	}
+	if mentions, _ := memDB.ListMentions(aliceID, nil, 10); len(mentions) != 2 {
+		t.Errorf("expected 2 mentions after delete, got %d", len(mentions))
+	}
+
+	// Mentions survive a snapshot and reload
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	memDB.Close()
+
+	stored, err := db.ListMentions(aliceID, nil, 10)
+	if err != nil {
+		t.Fatalf("DB.ListMentions: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Errorf("expected 2 stored mentions, got %d", len(stored))
+	}
+
+	reloaded, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to reload MemDB: %v", err)
+	}
+	defer reloaded.Close()
+
+	mentions, err = reloaded.ListMentions(aliceID, nil, 10)
+	if err != nil {
+		t.Fatalf("ListMentions: %v", err)
+	}
+	if len(mentions) != 2 || mentions[0].ID != ids[1] {
+		t.Errorf("expected 2 reloaded mentions, got %d", len(mentions))
+	}
+}
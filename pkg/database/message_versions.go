@@ -0,0 +1,105 @@
+package database
+
+import "fmt"
+
+// GetMessageVersions returns the MessageVersion rows of a message, oldest first.
+// "edited" and "deleted" rows hold the content as it was before the change.
+func (db *DB) GetMessageVersions(messageID int64) ([]MessageVersion, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, message_id, content, author_nickname, created_at, version_type
+		FROM MessageVersion
+		WHERE message_id = ?
+		ORDER BY created_at, id
+	`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []MessageVersion
+	for rows.Next() {
+		var v MessageVersion
+		if err := rows.Scan(&v.ID, &v.MessageID, &v.Content, &v.AuthorNickname, &v.CreatedAt, &v.VersionType); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// RecordMessageVersions stores version rows. Rows whose message no longer
+// exists are skipped.
+func (db *DB) RecordMessageVersions(versions []MessageVersion) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO MessageVersion (message_id, content, author_nickname, created_at, version_type)
+		SELECT ?, ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM Message WHERE id = ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare version insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, v := range versions {
+		if _, err := stmt.Exec(v.MessageID, v.Content, v.AuthorNickname, v.CreatedAt, v.VersionType, v.MessageID); err != nil {
+			return fmt.Errorf("failed to insert version of message %d: %w", v.MessageID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetMessageVersions returns the version rows of a message, oldest first,
+// including rows that haven't been snapshotted yet
+func (m *MemDB) GetMessageVersions(messageID int64) ([]MessageVersion, error) {
+	m.mu.RLock()
+	var pending []MessageVersion
+	for _, v := range m.dirtyVersions {
+		if v.MessageID == messageID {
+			pending = append(pending, v)
+		}
+	}
+	m.mu.RUnlock()
+
+	versions, err := m.sqliteDB.GetMessageVersions(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A snapshot running concurrently may already have written pending rows
+	for _, v := range pending {
+		stored := false
+		for _, existing := range versions {
+			if existing.CreatedAt == v.CreatedAt && existing.VersionType == v.VersionType && existing.Content == v.Content {
+				stored = true
+				break
+			}
+		}
+		if !stored {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// recordVersion queues a version row for the next snapshot. Caller must hold m.mu.
+func (m *MemDB) recordVersion(msg *Message, versionType string, createdAt int64) MessageVersion {
+	v := MessageVersion{
+		MessageID:      msg.ID,
+		Content:        msg.Content,
+		AuthorNickname: msg.AuthorNickname,
+		CreatedAt:      createdAt,
+		VersionType:    versionType,
+	}
+	m.dirtyVersions = append(m.dirtyVersions, v)
+	return v
+}
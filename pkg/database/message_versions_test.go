@@ -0,0 +1,128 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func versionTypes(versions []MessageVersion) []string {
+	types := make([]string, len(versions))
+	for i, v := range versions {
+		types[i] = v.VersionType
+	}
+	return types
+}
+
+func TestGetMessageVersions(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	msgID, _, err := db.PostMessage(channelID, nil, nil, &aliceID, "alice", "helo")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if _, err := db.UpdateMessage(uint64(msgID), uint64(aliceID), "hello"); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if _, err := db.SoftDeleteMessage(uint64(msgID), "alice"); err != nil {
+		t.Fatalf("SoftDeleteMessage: %v", err)
+	}
+
+	versions, err := db.GetMessageVersions(msgID)
+	if err != nil {
+		t.Fatalf("GetMessageVersions: %v", err)
+	}
+	if got := versionTypes(versions); len(got) != 3 || got[0] != "created" || got[1] != "edited" || got[2] != "deleted" {
+		t.Fatalf("unexpected versions %v", got)
+	}
+	if versions[1].Content != "helo" || versions[2].Content != "hello" {
+		t.Errorf("expected versions to hold the content before each change, got %q and %q", versions[1].Content, versions[2].Content)
+	}
+}
+
+func TestMemDBMessageVersions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	msgID, _, err := memDB.PostMessage(channelID, nil, nil, &aliceID, "alice", "one")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	for _, content := range []string{"two", "three"} {
+		if _, err := memDB.UpdateMessage(uint64(msgID), uint64(aliceID), content); err != nil {
+			t.Fatalf("UpdateMessage: %v", err)
+		}
+	}
+
+	// Edits are visible before they are snapshotted...
+	versions, err := memDB.GetMessageVersions(msgID)
+	if err != nil {
+		t.Fatalf("GetMessageVersions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Content != "one" || versions[1].Content != "two" {
+		t.Fatalf("unexpected versions before snapshot %+v", versions)
+	}
+
+	// ...and aren't duplicated afterwards
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	versions, err = memDB.GetMessageVersions(msgID)
+	if err != nil {
+		t.Fatalf("GetMessageVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions after snapshot, got %d", len(versions))
+	}
+
+	// Versions written after the snapshot survive a crash through the WAL
+	if _, err := memDB.SoftDeleteMessage(uint64(msgID), "alice"); err != nil {
+		t.Fatalf("SoftDeleteMessage: %v", err)
+	}
+	crashMemDB(t, memDB)
+
+	recovered, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to reopen MemDB: %v", err)
+	}
+	defer recovered.Close()
+
+	versions, err = recovered.GetMessageVersions(msgID)
+	if err != nil {
+		t.Fatalf("GetMessageVersions: %v", err)
+	}
+	if got := versionTypes(versions); len(got) != 3 || got[2] != "deleted" || versions[2].Content != "three" {
+		t.Fatalf("unexpected versions after recovery %+v", versions)
+	}
+}
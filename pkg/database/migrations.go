@@ -48,7 +48,12 @@ func getCurrentVersion(db *sql.DB) (int, error) {
 
 // loadMigrations loads all migration files from the embedded filesystem
 func loadMigrations() ([]Migration, error) {
-	entries, err := migrationFiles.ReadDir("migrations")
+	return loadMigrationsFrom(migrationFiles, "migrations")
+}
+
+// loadMigrationsFrom loads the versioned migration files in dir
+func loadMigrationsFrom(files embed.FS, dir string) ([]Migration, error) {
+	entries, err := files.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
 	}
@@ -76,7 +81,7 @@ func loadMigrations() ([]Migration, error) {
 		migrationName := strings.TrimSuffix(parts[1], ".sql")
 
 		// Read the SQL content
-		content, err := migrationFiles.ReadFile("migrations/" + name)
+		content, err := files.ReadFile(dir + "/" + name)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
 		}
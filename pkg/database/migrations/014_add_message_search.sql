@@ -0,0 +1,13 @@
+-- Migration 014: Add full-text search index over message content
+-- MessageSearch rowid is the Message id. The index is maintained by the MemDB
+-- snapshot (batchInsertMessages), which is the only writer of Message rows
+-- at runtime. Deleted messages are removed from the index when snapshotted.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS MessageSearch USING fts5(
+    content,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- Index existing messages
+INSERT INTO MessageSearch (rowid, content)
+SELECT id, content FROM Message WHERE deleted_at IS NULL;
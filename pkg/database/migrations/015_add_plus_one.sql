@@ -0,0 +1,18 @@
+-- Migration 015: Add plus-one reactions (V4)
+-- Registered users can +1 a message once (tracked in UserPlusOne); anonymous
+-- +1s are unlimited and only counted. Counts only ever go up.
+
+ALTER TABLE Message ADD COLUMN plus_one_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Message ADD COLUMN plus_one_anon_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS UserPlusOne (
+    user_id INTEGER NOT NULL,
+    message_id INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,  -- Unix timestamp (milliseconds)
+
+    PRIMARY KEY (user_id, message_id),
+    FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES Message(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_plus_one_message ON UserPlusOne(message_id);
@@ -0,0 +1,17 @@
+-- Migration 016: Add @mentions (V4)
+-- One row per registered user mentioned in a message, so users can catch up
+-- on mentions after being away.
+
+CREATE TABLE IF NOT EXISTS Mention (
+    user_id INTEGER NOT NULL,      -- Mentioned user
+    message_id INTEGER NOT NULL,
+    channel_id INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,   -- Unix timestamp (milliseconds)
+
+    PRIMARY KEY (user_id, message_id),
+    FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES Message(id) ON DELETE CASCADE,
+    FOREIGN KEY (channel_id) REFERENCES Channel(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mention_message ON Mention(message_id);
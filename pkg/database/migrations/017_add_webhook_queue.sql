@@ -0,0 +1,16 @@
+-- Migration 017: Add outgoing webhook delivery queue
+-- Events waiting to be POSTed to configured webhooks. Rows are deleted once
+-- delivered (or after the last retry), so the queue survives restarts.
+
+CREATE TABLE IF NOT EXISTS WebhookDelivery (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_url TEXT NOT NULL,        -- Target webhook (matched against config on delivery)
+    event TEXT NOT NULL,              -- e.g. 'message.created'
+    payload TEXT NOT NULL,            -- JSON request body
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL, -- Unix timestamp (milliseconds)
+    last_error TEXT,
+    created_at INTEGER NOT NULL       -- Unix timestamp (milliseconds)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON WebhookDelivery(next_attempt_at);
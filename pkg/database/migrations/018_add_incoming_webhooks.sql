@@ -0,0 +1,15 @@
+-- Migration 018: Add incoming webhooks
+-- Tokens for POST /hooks/{token}, which posts a message as a named integration.
+-- Only a SHA-256 hash of each token is stored.
+
+CREATE TABLE IF NOT EXISTS IncomingWebhook (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE,  -- Hex SHA-256 of the token
+    name TEXT NOT NULL,               -- Integration identity shown as the author
+    channel_id INTEGER NOT NULL,      -- Channel to post into
+    subchannel_id INTEGER,            -- Optional subchannel
+    parent_id INTEGER,                -- Optional message to reply to (thread)
+    created_by TEXT NOT NULL,         -- Admin nickname
+    created_at INTEGER NOT NULL,      -- Unix timestamp (milliseconds)
+    last_used_at INTEGER              -- Unix timestamp (milliseconds)
+);
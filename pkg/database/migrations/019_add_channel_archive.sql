@@ -0,0 +1,4 @@
+-- Migration 019: Add archived channels (V4)
+-- Archived channels are read-only and exempt from retention cleanup.
+
+ALTER TABLE Channel ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,31 @@
+-- Migration 020: Align AdminAction with how it is written
+-- LogAdminAction records the admin's user ID and has no target or IP address
+-- to record, which the columns from migration 007 did not allow. This brings
+-- the table in line with the PostgreSQL schema so the audit log can be queried.
+
+-- SQLite doesn't support ALTER COLUMN, so we recreate the table
+CREATE TABLE IF NOT EXISTS AdminAction_new (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  admin_user_id INTEGER,           -- NULL for actions logged before this migration
+  admin_nickname TEXT NOT NULL,
+  action_type TEXT NOT NULL,
+  target_type TEXT NOT NULL DEFAULT '',
+  target_id INTEGER,
+  target_identifier TEXT,
+  details TEXT,
+  performed_at INTEGER NOT NULL,
+  ip_address TEXT
+);
+
+-- Copy existing entries
+INSERT INTO AdminAction_new (id, admin_nickname, action_type, target_type, target_id, target_identifier, details, performed_at, ip_address)
+SELECT id, admin_nickname, action_type, target_type, target_id, target_identifier, details, performed_at, ip_address FROM AdminAction;
+
+-- Drop old table and rename new one
+DROP TABLE AdminAction;
+ALTER TABLE AdminAction_new RENAME TO AdminAction;
+
+-- Recreate indexes
+CREATE INDEX IF NOT EXISTS idx_admin_action_admin ON AdminAction(admin_nickname, performed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_action_target ON AdminAction(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_admin_action_time ON AdminAction(performed_at DESC);
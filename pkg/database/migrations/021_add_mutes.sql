@@ -0,0 +1,17 @@
+-- Migration 021: Add timed mutes (V4)
+-- A muted user can still read but not post, everywhere or in one channel.
+-- Expiry works like Ban: NULL muted_until is permanent, expired rows are ignored.
+
+CREATE TABLE IF NOT EXISTS Mute (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES User(id) ON DELETE CASCADE,       -- NULL for anonymous users
+    nickname TEXT NOT NULL,                                      -- Nickname at time of mute
+    channel_id INTEGER REFERENCES Channel(id) ON DELETE CASCADE, -- NULL = all channels
+    reason TEXT NOT NULL,
+    muted_at INTEGER NOT NULL,       -- Unix timestamp (milliseconds)
+    muted_until INTEGER,             -- NULL = permanent, Unix timestamp (milliseconds)
+    muted_by TEXT NOT NULL           -- Admin nickname
+);
+
+CREATE INDEX IF NOT EXISTS idx_mute_user ON Mute(user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mute_nickname ON Mute(nickname) WHERE user_id IS NULL;
@@ -0,0 +1,23 @@
+-- Migration 022: Add per-channel roles (V4)
+-- Owners and moderators are stored; everyone else is a member.
+-- Roles are set on top-level channels and cover their subchannels.
+-- Existing channels are owned by their creator.
+
+CREATE TABLE IF NOT EXISTS ChannelRole (
+    channel_id INTEGER NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES User(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'moderator')),
+    granted_by TEXT NOT NULL DEFAULT '', -- Nickname of who granted the role (empty for channel creators)
+    granted_at INTEGER NOT NULL,         -- Unix timestamp (milliseconds)
+    PRIMARY KEY (channel_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_role_user ON ChannelRole(user_id);
+
+INSERT OR IGNORE INTO ChannelRole (channel_id, user_id, role, granted_at)
+SELECT id, created_by, 'owner', created_at
+FROM Channel
+WHERE created_by IS NOT NULL
+  AND is_dm = 0
+  AND parent_id IS NULL
+  AND created_by IN (SELECT id FROM User);
@@ -0,0 +1,18 @@
+-- Migration 023: Add channel modes (V4)
+-- modes is a bitfield: 0x01 moderated, 0x02 read-only, 0x04 registered-only,
+-- 0x08 invite-only. Subchannels use their parent channel's modes.
+-- ChannelGrant records who is invited to invite-only channels and who is
+-- voiced (may post) in moderated channels.
+
+ALTER TABLE Channel ADD COLUMN modes INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS ChannelGrant (
+    channel_id INTEGER NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES User(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('invite', 'voice')),
+    granted_by TEXT NOT NULL DEFAULT '', -- Nickname of who made the grant
+    granted_at INTEGER NOT NULL,         -- Unix timestamp (milliseconds)
+    PRIMARY KEY (channel_id, user_id, kind)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_grant_user ON ChannelGrant(user_id);
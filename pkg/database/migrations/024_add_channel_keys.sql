@@ -0,0 +1,18 @@
+-- Migration 024: Add private channel keys (V4)
+-- Private channel messages are end-to-end encrypted with a random channel key.
+-- A member wraps each new key for every member's X25519 public key; the server
+-- only stores the wrapped copies. Epochs count up from 1 and a new one is made
+-- whenever the channel's members change.
+
+CREATE TABLE IF NOT EXISTS ChannelKey (
+    channel_id INTEGER NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    epoch INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES User(id) ON DELETE CASCADE,
+    public_key BLOB NOT NULL,            -- The member's X25519 public key the channel key was wrapped for
+    wrapped_key BLOB NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '', -- Nickname of the member who distributed the key
+    created_at INTEGER NOT NULL,         -- Unix timestamp (milliseconds)
+    PRIMARY KEY (channel_id, epoch, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_key_user ON ChannelKey(channel_id, user_id);
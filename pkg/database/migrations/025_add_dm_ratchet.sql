@@ -0,0 +1,8 @@
+-- Migration 025: Add ratcheted DMs (V4)
+-- encryption_ratchet records whether the user's latest client supports the
+-- Double Ratchet. dm_ratchet is decided once when a DM channel is created:
+-- DMs created before this migration (or with an older client) keep using
+-- the static channel key.
+
+ALTER TABLE User ADD COLUMN encryption_ratchet INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Channel ADD COLUMN dm_ratchet INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- Migration 026: Add key backups (V4)
+-- A registered user can keep one backup of their DM encryption keys on the
+-- server, so another machine can restore it after logging in. The backup is
+-- encrypted with a passphrase on the client; the server can't read it.
+
+CREATE TABLE IF NOT EXISTS KeyBackup (
+    user_id INTEGER PRIMARY KEY REFERENCES User(id) ON DELETE CASCADE,
+    backup BLOB NOT NULL,
+    updated_at INTEGER NOT NULL          -- Unix timestamp (milliseconds)
+);
@@ -0,0 +1,26 @@
+-- Migration 027: Add user devices (V4)
+-- Every client a registered user logs in with has its own X25519 key. Each
+-- key is a device; User.encryption_public_key stays the newest device's key
+-- for older clients. Revoked devices are kept so they can't come back by
+-- providing their key again. dm_multi_device is decided once when a DM
+-- channel is created, like dm_ratchet.
+
+CREATE TABLE IF NOT EXISTS UserDevice (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES User(id) ON DELETE CASCADE,
+    public_key BLOB NOT NULL,            -- X25519 public key (32 bytes)
+    label TEXT NOT NULL DEFAULT '',      -- Label from PROVIDE_PUBLIC_KEY (e.g., "laptop")
+    multi_device INTEGER NOT NULL DEFAULT 0, -- The device's client supports multi-device DMs
+    created_at INTEGER NOT NULL,         -- Unix timestamp (milliseconds)
+    last_seen_at INTEGER NOT NULL,       -- Unix timestamp (milliseconds) the key was last provided
+    revoked_at INTEGER,                  -- Unix timestamp (milliseconds), NULL if active
+    UNIQUE (user_id, public_key)
+);
+
+ALTER TABLE Channel ADD COLUMN dm_multi_device INTEGER NOT NULL DEFAULT 0;
+
+-- Existing keys become their user's first device
+INSERT OR IGNORE INTO UserDevice (user_id, public_key, created_at, last_seen_at)
+SELECT id, encryption_public_key, last_seen, last_seen
+FROM User
+WHERE encryption_public_key IS NOT NULL AND length(encryption_public_key) = 32;
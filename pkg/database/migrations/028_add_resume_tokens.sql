@@ -0,0 +1,16 @@
+-- Migration 028: Add resume tokens (V4)
+-- A registered user who logs in with a password can ask for a resume token,
+-- and later authenticate with RESUME_SESSION instead of sending the password
+-- again. Only the SHA-256 of each token is stored. Each token is one of the
+-- user's logins, listed by LIST_SESSIONS and deleted by REVOKE_SESSION.
+
+CREATE TABLE IF NOT EXISTS ResumeToken (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES User(id) ON DELETE CASCADE,
+    token_hash BLOB NOT NULL UNIQUE,     -- SHA-256 of the token
+    label TEXT NOT NULL DEFAULT '',      -- IP address the login came from
+    created_at INTEGER NOT NULL,         -- Unix timestamp (milliseconds)
+    last_used_at INTEGER NOT NULL        -- Unix timestamp (milliseconds) the token was last used
+);
+
+CREATE INDEX IF NOT EXISTS idx_resume_token_user ON ResumeToken(user_id);
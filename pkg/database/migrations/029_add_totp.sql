@@ -0,0 +1,18 @@
+-- Migration 029: Add TOTP two-factor authentication (V4)
+-- A user who logs in with a password can enroll an RFC 6238 authenticator.
+-- Once enrolled, a password login also needs a current code or one of the
+-- recovery codes handed out at enrollment. Only the SHA-256 of each recovery
+-- code is stored, and each one works once.
+
+CREATE TABLE IF NOT EXISTS UserTOTP (
+    user_id INTEGER PRIMARY KEY REFERENCES User(id) ON DELETE CASCADE,
+    secret BLOB NOT NULL,                -- Shared HMAC-SHA1 secret
+    last_step INTEGER NOT NULL DEFAULT 0, -- Last accepted time step, so codes can't be replayed
+    enabled_at INTEGER NOT NULL          -- Unix timestamp (milliseconds)
+);
+
+CREATE TABLE IF NOT EXISTS TOTPRecoveryCode (
+    user_id INTEGER NOT NULL REFERENCES User(id) ON DELETE CASCADE,
+    code_hash BLOB NOT NULL,             -- SHA-256 of the recovery code
+    PRIMARY KEY (user_id, code_hash)
+);
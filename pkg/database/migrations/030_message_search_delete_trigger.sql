@@ -0,0 +1,13 @@
+-- Migration 030: Remove search index rows with their messages
+-- Retention cleanup, channel deletion (via CASCADE) and other hard deletes
+-- remove Message rows without going through the MemDB snapshot, which left
+-- their MessageSearch rows behind forever. Foreign key cascades fire
+-- triggers, so this covers every path.
+
+CREATE TRIGGER IF NOT EXISTS message_search_delete AFTER DELETE ON Message
+BEGIN
+    DELETE FROM MessageSearch WHERE rowid = old.id;
+END;
+
+-- Drop rows already orphaned
+DELETE FROM MessageSearch WHERE rowid NOT IN (SELECT id FROM Message);
@@ -0,0 +1,214 @@
+-- Migration 001: Initial PostgreSQL schema
+-- Equivalent to SQLite migrations 001-016. Timestamps are Unix milliseconds
+-- (BIGINT) like in SQLite; message and session IDs are Snowflake IDs.
+-- "User" is a reserved word in PostgreSQL and must always be quoted.
+
+CREATE TABLE IF NOT EXISTS "User" (
+    id BIGSERIAL PRIMARY KEY,
+    nickname TEXT UNIQUE NOT NULL,
+    user_flags SMALLINT NOT NULL DEFAULT 0,  -- Bit flags: 0x01=admin, 0x02=moderator
+    password_hash TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL,
+    last_seen BIGINT NOT NULL,
+    encryption_public_key BYTEA            -- X25519 public key for DMs
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_nickname_lower ON "User"(lower(nickname));
+
+CREATE TABLE IF NOT EXISTS Channel (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    display_name TEXT NOT NULL,
+    description TEXT,
+    channel_type SMALLINT NOT NULL DEFAULT 1,
+    message_retention_hours INTEGER NOT NULL DEFAULT 168,
+    created_by BIGINT REFERENCES "User"(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
+    parent_id BIGINT REFERENCES Channel(id) ON DELETE CASCADE,
+    is_dm BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_parent ON Channel(parent_id) WHERE parent_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS Session (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT,
+    nickname TEXT NOT NULL,
+    connection_type TEXT NOT NULL,
+    connected_at BIGINT NOT NULL,
+    last_activity BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_activity ON Session(last_activity);
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON Session(user_id) WHERE user_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS Message (
+    id BIGINT PRIMARY KEY,
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    subchannel_id BIGINT,
+    parent_id BIGINT REFERENCES Message(id) ON DELETE CASCADE,
+    thread_root_id BIGINT REFERENCES Message(id) ON DELETE CASCADE,
+    author_user_id BIGINT,
+    author_nickname TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    edited_at BIGINT,
+    deleted_at BIGINT,
+    plus_one_count INTEGER NOT NULL DEFAULT 0,
+    plus_one_anon_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_channel ON Message(channel_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_parent ON Message(parent_id) WHERE parent_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_thread_root ON Message(thread_root_id) WHERE thread_root_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_retention ON Message(created_at, parent_id);
+CREATE INDEX IF NOT EXISTS idx_messages_author ON Message(author_user_id) WHERE author_user_id IS NOT NULL;
+
+-- Full-text search (replaces the SQLite FTS5 MessageSearch table)
+CREATE INDEX IF NOT EXISTS idx_messages_search ON Message
+    USING GIN (to_tsvector('simple', content)) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS MessageVersion (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES Message(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    author_nickname TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    version_type TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_version_message ON MessageVersion(message_id);
+
+CREATE TABLE IF NOT EXISTS DiscoveredServer (
+    id BIGSERIAL PRIMARY KEY,
+    hostname TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    max_users BIGINT NOT NULL DEFAULT 0,
+    is_public BOOLEAN NOT NULL DEFAULT TRUE,
+    user_count BIGINT NOT NULL DEFAULT 0,
+    uptime_seconds BIGINT NOT NULL DEFAULT 0,
+    channel_count BIGINT NOT NULL DEFAULT 0,
+    last_heartbeat BIGINT NOT NULL,
+    heartbeat_interval BIGINT NOT NULL DEFAULT 300,
+    discovered_via TEXT NOT NULL CHECK(discovered_via IN ('registration', 'gossip')),
+    source_ip TEXT,
+    created_at BIGINT NOT NULL,
+    UNIQUE(hostname, port)
+);
+
+CREATE INDEX IF NOT EXISTS idx_discovered_server_last_heartbeat ON DiscoveredServer(last_heartbeat DESC);
+
+CREATE TABLE IF NOT EXISTS SSHKey (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    fingerprint TEXT UNIQUE NOT NULL,
+    public_key TEXT NOT NULL,
+    key_type TEXT NOT NULL,
+    label TEXT,
+    added_at BIGINT NOT NULL,
+    last_used_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_ssh_user ON SSHKey(user_id);
+
+CREATE TABLE IF NOT EXISTS Ban (
+    id BIGSERIAL PRIMARY KEY,
+    ban_type TEXT NOT NULL CHECK(ban_type IN ('user', 'ip')),
+    user_id BIGINT REFERENCES "User"(id) ON DELETE CASCADE,
+    nickname TEXT,
+    ip_cidr TEXT,
+    reason TEXT NOT NULL,
+    shadowban BOOLEAN NOT NULL DEFAULT FALSE,
+    banned_at BIGINT NOT NULL,
+    banned_until BIGINT,                   -- NULL = permanent
+    banned_by TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ban_user ON Ban(user_id) WHERE ban_type = 'user';
+CREATE INDEX IF NOT EXISTS idx_ban_ip ON Ban(ip_cidr) WHERE ban_type = 'ip';
+
+CREATE TABLE IF NOT EXISTS AdminAction (
+    id BIGSERIAL PRIMARY KEY,
+    admin_user_id BIGINT,
+    admin_nickname TEXT NOT NULL,
+    action_type TEXT NOT NULL,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id BIGINT,
+    target_identifier TEXT,
+    details TEXT,
+    performed_at BIGINT NOT NULL,
+    ip_address TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_action_time ON AdminAction(performed_at DESC);
+
+CREATE TABLE IF NOT EXISTS UserChannelState (
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    subchannel_id BIGINT NOT NULL DEFAULT 0,  -- 0 for main channel
+    last_read_at BIGINT NOT NULL DEFAULT 0,
+    updated_at BIGINT NOT NULL,
+    PRIMARY KEY (user_id, channel_id, subchannel_id)
+);
+
+CREATE TABLE IF NOT EXISTS ChannelAccess (
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    PRIMARY KEY (channel_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_access_user ON ChannelAccess(user_id);
+
+CREATE TABLE IF NOT EXISTS DMInvite (
+    id BIGSERIAL PRIMARY KEY,
+    initiator_user_id BIGINT REFERENCES "User"(id) ON DELETE CASCADE,
+    target_user_id BIGINT REFERENCES "User"(id) ON DELETE CASCADE,
+    initiator_session_id BIGINT,
+    target_session_id BIGINT,
+    is_encrypted BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at BIGINT NOT NULL,
+    CHECK (initiator_user_id IS NOT NULL OR initiator_session_id IS NOT NULL),
+    CHECK (target_user_id IS NOT NULL OR target_session_id IS NOT NULL),
+    UNIQUE(initiator_user_id, target_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dm_invite_target ON DMInvite(target_user_id);
+
+CREATE TABLE IF NOT EXISTS ChannelParticipant (
+    id BIGSERIAL PRIMARY KEY,
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES "User"(id) ON DELETE CASCADE,  -- NULL for anonymous
+    session_id BIGINT,
+    nickname TEXT NOT NULL,
+    joined_at BIGINT NOT NULL,
+    CHECK (user_id IS NOT NULL OR session_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_participant_channel ON ChannelParticipant(channel_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_participant_unique_user
+    ON ChannelParticipant(channel_id, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_participant_unique_session
+    ON ChannelParticipant(channel_id, session_id) WHERE user_id IS NULL AND session_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS UserPlusOne (
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    message_id BIGINT NOT NULL REFERENCES Message(id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_plus_one_message ON UserPlusOne(message_id);
+
+CREATE TABLE IF NOT EXISTS Mention (
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    message_id BIGINT NOT NULL REFERENCES Message(id) ON DELETE CASCADE,
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mention_message ON Mention(message_id);
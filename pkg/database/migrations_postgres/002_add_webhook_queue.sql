@@ -0,0 +1,15 @@
+-- Migration 002: Add outgoing webhook delivery queue
+-- Equivalent to SQLite migration 017.
+
+CREATE TABLE IF NOT EXISTS WebhookDelivery (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_url TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at BIGINT NOT NULL,
+    last_error TEXT,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON WebhookDelivery(next_attempt_at);
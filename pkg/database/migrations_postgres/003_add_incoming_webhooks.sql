@@ -0,0 +1,14 @@
+-- Migration 003: Add incoming webhooks
+-- Equivalent to SQLite migration 018.
+
+CREATE TABLE IF NOT EXISTS IncomingWebhook (
+    id BIGSERIAL PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    channel_id BIGINT NOT NULL,
+    subchannel_id BIGINT,
+    parent_id BIGINT,
+    created_by TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    last_used_at BIGINT
+);
@@ -0,0 +1,4 @@
+-- Migration 004: Add archived channels
+-- Equivalent to SQLite migration 019.
+
+ALTER TABLE Channel ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- Migration 005: Index the audit log by admin
+-- Equivalent to the index recreated by SQLite migration 020.
+
+CREATE INDEX IF NOT EXISTS idx_admin_action_admin ON AdminAction(admin_nickname, performed_at DESC);
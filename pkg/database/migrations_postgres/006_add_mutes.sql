@@ -0,0 +1,16 @@
+-- Migration 006: Add timed mutes
+-- Equivalent to SQLite migration 021.
+
+CREATE TABLE IF NOT EXISTS Mute (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES "User"(id) ON DELETE CASCADE,
+    nickname TEXT NOT NULL,
+    channel_id BIGINT REFERENCES Channel(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    muted_at BIGINT NOT NULL,
+    muted_until BIGINT,                    -- NULL = permanent
+    muted_by TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_mute_user ON Mute(user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mute_nickname ON Mute(LOWER(nickname)) WHERE user_id IS NULL;
@@ -0,0 +1,22 @@
+-- Migration 007: Add per-channel roles
+-- Equivalent to SQLite migration 022.
+
+CREATE TABLE IF NOT EXISTS ChannelRole (
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'moderator')),
+    granted_by TEXT NOT NULL DEFAULT '',
+    granted_at BIGINT NOT NULL,
+    PRIMARY KEY (channel_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_role_user ON ChannelRole(user_id);
+
+INSERT INTO ChannelRole (channel_id, user_id, role, granted_at)
+SELECT id, created_by, 'owner', created_at
+FROM Channel
+WHERE created_by IS NOT NULL
+  AND NOT is_dm
+  AND parent_id IS NULL
+  AND created_by IN (SELECT id FROM "User")
+ON CONFLICT DO NOTHING;
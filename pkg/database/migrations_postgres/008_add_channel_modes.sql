@@ -0,0 +1,15 @@
+-- Migration 008: Add channel modes
+-- Equivalent to SQLite migration 023.
+
+ALTER TABLE Channel ADD COLUMN IF NOT EXISTS modes SMALLINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS ChannelGrant (
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('invite', 'voice')),
+    granted_by TEXT NOT NULL DEFAULT '',
+    granted_at BIGINT NOT NULL,
+    PRIMARY KEY (channel_id, user_id, kind)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_grant_user ON ChannelGrant(user_id);
@@ -0,0 +1,15 @@
+-- Migration 009: Add private channel keys
+-- Equivalent to SQLite migration 024.
+
+CREATE TABLE IF NOT EXISTS ChannelKey (
+    channel_id BIGINT NOT NULL REFERENCES Channel(id) ON DELETE CASCADE,
+    epoch BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    public_key BYTEA NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL,
+    PRIMARY KEY (channel_id, epoch, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_key_user ON ChannelKey(channel_id, user_id);
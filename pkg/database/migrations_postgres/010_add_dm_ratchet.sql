@@ -0,0 +1,5 @@
+-- Migration 010: Add ratcheted DMs
+-- Equivalent to SQLite migration 025.
+
+ALTER TABLE "User" ADD COLUMN IF NOT EXISTS encryption_ratchet BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE Channel ADD COLUMN IF NOT EXISTS dm_ratchet BOOLEAN NOT NULL DEFAULT FALSE;
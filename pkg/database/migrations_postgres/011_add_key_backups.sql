@@ -0,0 +1,8 @@
+-- Migration 011: Add key backups
+-- Equivalent to SQLite migration 026.
+
+CREATE TABLE IF NOT EXISTS KeyBackup (
+    user_id BIGINT PRIMARY KEY REFERENCES "User"(id) ON DELETE CASCADE,
+    backup BYTEA NOT NULL,
+    updated_at BIGINT NOT NULL
+);
@@ -0,0 +1,22 @@
+-- Migration 012: Add user devices
+-- Equivalent to SQLite migration 027.
+
+CREATE TABLE IF NOT EXISTS UserDevice (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    public_key BYTEA NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    multi_device BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at BIGINT NOT NULL,
+    last_seen_at BIGINT NOT NULL,
+    revoked_at BIGINT,
+    UNIQUE (user_id, public_key)
+);
+
+ALTER TABLE Channel ADD COLUMN IF NOT EXISTS dm_multi_device BOOLEAN NOT NULL DEFAULT FALSE;
+
+INSERT INTO UserDevice (user_id, public_key, created_at, last_seen_at)
+SELECT id, encryption_public_key, last_seen, last_seen
+FROM "User"
+WHERE encryption_public_key IS NOT NULL AND length(encryption_public_key) = 32
+ON CONFLICT (user_id, public_key) DO NOTHING;
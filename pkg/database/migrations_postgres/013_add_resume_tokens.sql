@@ -0,0 +1,13 @@
+-- Migration 013: Add resume tokens
+-- Equivalent to SQLite migration 028.
+
+CREATE TABLE IF NOT EXISTS ResumeToken (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    token_hash BYTEA NOT NULL UNIQUE,
+    label TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL,
+    last_used_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_resume_token_user ON ResumeToken(user_id);
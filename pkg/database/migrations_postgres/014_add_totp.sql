@@ -0,0 +1,15 @@
+-- Migration 014: Add TOTP two-factor authentication
+-- Equivalent to SQLite migration 029.
+
+CREATE TABLE IF NOT EXISTS UserTOTP (
+    user_id BIGINT PRIMARY KEY REFERENCES "User"(id) ON DELETE CASCADE,
+    secret BYTEA NOT NULL,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    enabled_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS TOTPRecoveryCode (
+    user_id BIGINT NOT NULL REFERENCES "User"(id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Mute stops a user from posting, everywhere or in one channel, while they
+// can still read. Expiry works like Ban.
+type Mute struct {
+	ID         int64
+	UserID     *int64 // NULL for anonymous users
+	Nickname   string // Nickname at time of mute
+	ChannelID  *int64 // NULL = all channels
+	Reason     string
+	MutedAt    int64  // Unix timestamp in milliseconds
+	MutedUntil *int64 // NULL = permanent, Unix timestamp in milliseconds for timed mutes
+	MutedBy    string // Admin nickname who created the mute
+}
+
+const muteColumns = `id, user_id, nickname, channel_id, reason, muted_at, muted_until, muted_by`
+
+func scanMute(row rowScanner) (*Mute, error) {
+	mute := &Mute{}
+	err := row.Scan(&mute.ID, &mute.UserID, &mute.Nickname, &mute.ChannelID, &mute.Reason,
+		&mute.MutedAt, &mute.MutedUntil, &mute.MutedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil // Not muted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mute, nil
+}
+
+// muteUntil returns the expiry for a mute created now (nil = permanent)
+func muteUntil(now int64, durationSeconds *uint64) *int64 {
+	if durationSeconds == nil {
+		return nil
+	}
+	until := now + int64(*durationSeconds)*1000
+	return &until
+}
+
+// CreateMute mutes a user, everywhere (channelID nil) or in one channel.
+// Registered users are matched by user ID, anonymous users by nickname.
+func (db *DB) CreateMute(userID *int64, nickname string, channelID *int64, reason string, durationSeconds *uint64, adminNickname string) (int64, error) {
+	now := nowMillis()
+	result, err := db.writeConn.Exec(`
+		INSERT INTO Mute (user_id, nickname, channel_id, reason, muted_at, muted_until, muted_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, userID, nickname, channelID, reason, now, muteUntil(now, durationSeconds), adminNickname)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetActiveMute returns the longest-running unexpired mute that stops the user
+// posting in the channel, or nil if they may post
+func (db *DB) GetActiveMute(userID *int64, nickname string, channelID int64) (*Mute, error) {
+	return scanMute(db.conn.QueryRow(`
+		SELECT `+muteColumns+` FROM Mute
+		WHERE (muted_until IS NULL OR muted_until > ?)
+		  AND (channel_id IS NULL OR channel_id = ?)
+		  AND (user_id = ? OR (user_id IS NULL AND nickname = ? COLLATE NOCASE))
+		ORDER BY muted_until IS NULL DESC, muted_until DESC
+		LIMIT 1
+	`, nowMillis(), channelID, userID, nickname))
+}
+
+// DeleteMutes lifts a user's mutes for one scope: everywhere (channelID nil)
+// or one channel. Returns the number of mutes removed.
+func (db *DB) DeleteMutes(userID *int64, nickname string, channelID *int64) (int64, error) {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM Mute
+		WHERE channel_id IS ?
+		  AND (user_id = ? OR (user_id IS NULL AND nickname = ? COLLATE NOCASE))
+	`, channelID, userID, nickname)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Mutes are rare admin data, so MemDB doesn't cache them.
+
+func (m *MemDB) CreateMute(userID *int64, nickname string, channelID *int64, reason string, durationSeconds *uint64, adminNickname string) (int64, error) {
+	return m.sqliteDB.CreateMute(userID, nickname, channelID, reason, durationSeconds, adminNickname)
+}
+
+func (m *MemDB) GetActiveMute(userID *int64, nickname string, channelID int64) (*Mute, error) {
+	return m.sqliteDB.GetActiveMute(userID, nickname, channelID)
+}
+
+func (m *MemDB) DeleteMutes(userID *int64, nickname string, channelID *int64) (int64, error) {
+	return m.sqliteDB.DeleteMutes(userID, nickname, channelID)
+}
+
+// CreateMute mutes a user, everywhere (channelID nil) or in one channel
+func (db *PostgresDB) CreateMute(userID *int64, nickname string, channelID *int64, reason string, durationSeconds *uint64, adminNickname string) (int64, error) {
+	now := nowMillis()
+	var muteID int64
+	err := db.conn.QueryRow(`
+		INSERT INTO Mute (user_id, nickname, channel_id, reason, muted_at, muted_until, muted_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, userID, nickname, channelID, reason, now, muteUntil(now, durationSeconds), adminNickname).Scan(&muteID)
+	return muteID, err
+}
+
+// GetActiveMute returns the longest-running unexpired mute that stops the user
+// posting in the channel, or nil if they may post
+func (db *PostgresDB) GetActiveMute(userID *int64, nickname string, channelID int64) (*Mute, error) {
+	return scanMute(db.conn.QueryRow(`
+		SELECT `+muteColumns+` FROM Mute
+		WHERE (muted_until IS NULL OR muted_until > $1)
+		  AND (channel_id IS NULL OR channel_id = $2)
+		  AND (user_id = $3 OR (user_id IS NULL AND LOWER(nickname) = LOWER($4)))
+		ORDER BY (muted_until IS NULL) DESC, muted_until DESC
+		LIMIT 1
+	`, nowMillis(), channelID, userID, nickname))
+}
+
+// DeleteMutes lifts a user's mutes for one scope. Returns the number removed.
+func (db *PostgresDB) DeleteMutes(userID *int64, nickname string, channelID *int64) (int64, error) {
+	result, err := db.conn.Exec(`
+		DELETE FROM Mute
+		WHERE channel_id IS NOT DISTINCT FROM $1
+		  AND (user_id = $2 OR (user_id IS NULL AND LOWER(nickname) = LOWER($3)))
+	`, channelID, userID, nickname)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
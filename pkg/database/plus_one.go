@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// UserPlusOne records that a registered user +1'd a message.
+// Registered users can +1 a message once; anonymous +1s are not deduplicated.
+type UserPlusOne struct {
+	UserID    int64
+	MessageID int64
+	CreatedAt int64
+}
+
+// PlusOneMessage adds a +1 to a message. userID is nil for anonymous users.
+// Returns the message with its updated counts and whether the +1 was counted
+// (false when a registered user already +1'd the message).
+func (db *DB) PlusOneMessage(messageID uint64, userID *int64) (*Message, bool, error) {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var deletedAt sql.NullInt64
+	err = tx.QueryRow(`SELECT deleted_at FROM Message WHERE id = ?`, messageID).Scan(&deletedAt)
+	if errors.Is(err, sql.ErrNoRows) || deletedAt.Valid {
+		return nil, false, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	counted := true
+	if userID != nil {
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO UserPlusOne (user_id, message_id, created_at)
+			VALUES (?, ?, ?)
+		`, *userID, messageID, nowMillis())
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to record +1: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, false, err
+		}
+		counted = rows > 0
+		if counted {
+			_, err = tx.Exec(`UPDATE Message SET plus_one_count = plus_one_count + 1 WHERE id = ?`, messageID)
+		}
+	} else {
+		_, err = tx.Exec(`UPDATE Message SET plus_one_anon_count = plus_one_anon_count + 1 WHERE id = ?`, messageID)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update +1 count: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	msg, err := db.GetMessage(int64(messageID))
+	if err != nil {
+		return nil, false, err
+	}
+	return msg, counted, nil
+}
+
+// PlusOneMessage adds a +1 to a message. userID is nil for anonymous users.
+// The count is persisted with the message on the next snapshot; the dedupe
+// row for registered users is queued alongside it.
+func (m *MemDB) PlusOneMessage(messageID uint64, userID *int64) (*Message, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg, exists := m.messages[int64(messageID)]
+	if !exists || msg.DeletedAt != nil {
+		return nil, false, ErrMessageNotFound
+	}
+
+	counted := true
+	var dedupe *UserPlusOne
+	if userID != nil {
+		users := m.plusOnes[msg.ID]
+		if users == nil {
+			users = make(map[int64]bool)
+			m.plusOnes[msg.ID] = users
+		}
+		if users[*userID] {
+			counted = false
+		} else {
+			users[*userID] = true
+			msg.PlusOneCount++
+			dedupe = &UserPlusOne{
+				UserID:    *userID,
+				MessageID: msg.ID,
+				CreatedAt: nowMillis(),
+			}
+			m.dirtyPlusOnes = append(m.dirtyPlusOnes, *dedupe)
+		}
+	} else {
+		msg.PlusOneAnonCount++
+	}
+
+	if counted {
+		m.dirtyMessages[msg.ID] = true // Mark as dirty for next snapshot
+		m.logWAL(walRecord{Op: walOpPlusOne, Message: newWALMessage(msg), PlusOne: dedupe})
+	}
+
+	return copyMessage(msg), counted, nil
+}
+
+// loadPlusOnes loads the +1 dedupe set for messages held in memory
+func (m *MemDB) loadPlusOnes() error {
+	rows, err := m.sqliteDB.conn.Query(`SELECT user_id, message_id FROM UserPlusOne`)
+	if err != nil {
+		return fmt.Errorf("failed to load +1s: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, messageID int64
+		if err := rows.Scan(&userID, &messageID); err != nil {
+			return fmt.Errorf("failed to scan +1: %w", err)
+		}
+		if _, exists := m.messages[messageID]; !exists {
+			continue
+		}
+		users := m.plusOnes[messageID]
+		if users == nil {
+			users = make(map[int64]bool)
+			m.plusOnes[messageID] = users
+		}
+		users[userID] = true
+	}
+	return rows.Err()
+}
+
+// insertUserPlusOnes persists queued +1 dedupe rows. Rows whose message or
+// user no longer exists are dropped.
+func (m *MemDB) insertUserPlusOnes(plusOnes []UserPlusOne) error {
+	tx, err := m.sqliteDB.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO UserPlusOne (user_id, message_id, created_at)
+		SELECT ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM Message WHERE id = ?)
+		  AND EXISTS (SELECT 1 FROM User WHERE id = ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare +1 insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range plusOnes {
+		if _, err := stmt.Exec(p.UserID, p.MessageID, p.CreatedAt, p.MessageID, p.UserID); err != nil {
+			return fmt.Errorf("failed to insert +1 for message %d: %w", p.MessageID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
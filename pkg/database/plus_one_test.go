@@ -0,0 +1,155 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemDBPlusOneDedupe(t *testing.T) {
+	memDB, channelID := newSearchTestDB(t)
+
+	aliceID, err := memDB.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	msgID, _, err := memDB.PostMessage(channelID, nil, nil, nil, "bob", "Ship it?")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+
+	msg, counted, err := memDB.PlusOneMessage(uint64(msgID), &aliceID)
+	if err != nil || !counted {
+		t.Fatalf("first +1: counted=%v err=%v", counted, err)
+	}
+	if msg.PlusOneCount != 1 {
+		t.Errorf("PlusOneCount = %d, want 1", msg.PlusOneCount)
+	}
+
+	// Registered users only count once
+	msg, counted, err = memDB.PlusOneMessage(uint64(msgID), &aliceID)
+	if err != nil {
+		t.Fatalf("second +1: %v", err)
+	}
+	if counted || msg.PlusOneCount != 1 {
+		t.Errorf("duplicate +1: counted=%v PlusOneCount=%d, want false/1", counted, msg.PlusOneCount)
+	}
+
+	// Anonymous +1s are unlimited
+	for i := 0; i < 3; i++ {
+		if msg, counted, err = memDB.PlusOneMessage(uint64(msgID), nil); err != nil || !counted {
+			t.Fatalf("anonymous +1: counted=%v err=%v", counted, err)
+		}
+	}
+	if msg.PlusOneCount != 1 || msg.PlusOneAnonCount != 3 {
+		t.Errorf("counts = %d/%d, want 1/3", msg.PlusOneCount, msg.PlusOneAnonCount)
+	}
+
+	if _, _, err := memDB.PlusOneMessage(999999, nil); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("unknown message: err = %v, want ErrMessageNotFound", err)
+	}
+}
+
+func TestMemDBPlusOneSurvivesSnapshot(t *testing.T) {
+	db, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	aliceID, err := memDB.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	rootID, _, err := memDB.PostMessage(channelID, nil, nil, nil, "bob", "Root")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	replyID, _, err := memDB.PostMessage(channelID, nil, &rootID, nil, "carol", "Reply")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	// Re-snapshotting the root must not cascade-delete its replies
+	if _, _, err := memDB.PlusOneMessage(uint64(rootID), &aliceID); err != nil {
+		t.Fatalf("PlusOneMessage: %v", err)
+	}
+	if _, _, err := memDB.PlusOneMessage(uint64(rootID), nil); err != nil {
+		t.Fatalf("PlusOneMessage: %v", err)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	memDB.Close()
+
+	if _, err := db.GetMessage(replyID); err != nil {
+		t.Fatalf("reply lost after root was re-snapshotted: %v", err)
+	}
+
+	reloaded, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to reload MemDB: %v", err)
+	}
+	defer reloaded.Close()
+
+	root, err := reloaded.GetMessage(rootID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if root.PlusOneCount != 1 || root.PlusOneAnonCount != 1 {
+		t.Errorf("reloaded counts = %d/%d, want 1/1", root.PlusOneCount, root.PlusOneAnonCount)
+	}
+
+	// The dedupe set is reloaded too
+	if _, counted, err := reloaded.PlusOneMessage(uint64(rootID), &aliceID); err != nil || counted {
+		t.Errorf("duplicate +1 after reload: counted=%v err=%v", counted, err)
+	}
+}
+
+func TestDBPlusOneMessage(t *testing.T) {
+	db, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	msgID, _, err := db.PostMessage(channelID, nil, nil, nil, "bob", "Hello")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+
+	if _, counted, err := db.PlusOneMessage(uint64(msgID), &aliceID); err != nil || !counted {
+		t.Fatalf("first +1: counted=%v err=%v", counted, err)
+	}
+	if _, counted, err := db.PlusOneMessage(uint64(msgID), &aliceID); err != nil || counted {
+		t.Fatalf("duplicate +1: counted=%v err=%v", counted, err)
+	}
+	msg, _, err := db.PlusOneMessage(uint64(msgID), nil)
+	if err != nil {
+		t.Fatalf("anonymous +1: %v", err)
+	}
+	if msg.PlusOneCount != 1 || msg.PlusOneAnonCount != 1 {
+		t.Errorf("counts = %d/%d, want 1/1", msg.PlusOneCount, msg.PlusOneAnonCount)
+	}
+}
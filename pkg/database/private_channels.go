@@ -0,0 +1,211 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Private channels are group channels (is_private = 1, is_dm = 0) whose
+// members are tracked in ChannelParticipant, like DMs. Only registered users
+// can be members.
+
+// CreatePrivateChannel creates a private channel with its creator as the only
+// member and owner
+func (db *DB) CreatePrivateChannel(name, displayName string, description *string, channelType uint8, retentionHours uint32, ownerID int64, ownerNickname string) (int64, error) {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := nowMillis()
+
+	result, err := tx.Exec(`
+		INSERT INTO Channel (name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1)
+	`, name, displayName, description, channelType, retentionHours, ownerID, now)
+	if err != nil {
+		return 0, err
+	}
+
+	channelID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get channel ID: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO ChannelParticipant (channel_id, user_id, nickname, joined_at)
+		VALUES (?, ?, ?, ?)
+	`, channelID, ownerID, ownerNickname, now); err != nil {
+		return 0, fmt.Errorf("failed to add owner as participant: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO ChannelRole (channel_id, user_id, role, granted_by, granted_at)
+		VALUES (?, ?, ?, '', ?)
+	`, channelID, ownerID, ChannelRoleOwner, now); err != nil {
+		return 0, fmt.Errorf("failed to set channel owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return channelID, nil
+}
+
+// AddChannelParticipant adds a registered user to a private channel. Adding
+// an existing member does nothing.
+func (db *DB) AddChannelParticipant(channelID, userID int64, nickname string) error {
+	_, err := db.writeConn.Exec(`
+		INSERT OR IGNORE INTO ChannelParticipant (channel_id, user_id, nickname, joined_at)
+		VALUES (?, ?, ?, ?)
+	`, channelID, userID, nickname, nowMillis())
+	return err
+}
+
+// GetPrivateChannelsForUser returns the private channels a user is a member of
+func (db *DB) GetPrivateChannelsForUser(userID int64) ([]*Channel, error) {
+	rows, err := db.conn.Query(`
+		SELECT c.id, c.name, c.display_name, c.description, c.channel_type,
+		       c.message_retention_hours, c.created_by, c.created_at, c.is_private, c.parent_id, c.is_dm, c.archived, c.modes
+		FROM Channel c
+		INNER JOIN ChannelParticipant cp ON cp.channel_id = c.id
+		WHERE cp.user_id = ? AND c.is_private = 1 AND c.is_dm = 0
+		ORDER BY c.name ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanChannels(rows)
+}
+
+// listPrivateChannels returns every private channel, for loading the MemDB cache
+func (db *DB) listPrivateChannels() ([]*Channel, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, parent_id, is_dm, archived, modes
+		FROM Channel
+		WHERE is_private = 1 AND is_dm = 0
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return scanChannels(rows)
+}
+
+// scanChannels scans and closes rows selected with the pgChannelColumns column list
+func scanChannels(rows *sql.Rows) ([]*Channel, error) {
+	defer rows.Close()
+
+	channels := []*Channel{}
+	for rows.Next() {
+		ch, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+// CreatePrivateChannel creates the channel in SQLite and adds it to the cache
+func (m *MemDB) CreatePrivateChannel(name, displayName string, description *string, channelType uint8, retentionHours uint32, ownerID int64, ownerNickname string) (int64, error) {
+	channelID, err := m.sqliteDB.CreatePrivateChannel(name, displayName, description, channelType, retentionHours, ownerID, ownerNickname)
+	if err != nil {
+		return 0, err
+	}
+
+	createdBy := ownerID
+	ch := &Channel{
+		ID:                    channelID,
+		Name:                  name,
+		DisplayName:           displayName,
+		Description:           description,
+		ChannelType:           channelType,
+		MessageRetentionHours: retentionHours,
+		CreatedBy:             &createdBy,
+		CreatedAt:             time.Now().UnixMilli(),
+		IsPrivate:             true,
+	}
+
+	m.mu.Lock()
+	m.channels[channelID] = ch
+	m.mu.Unlock()
+
+	log.Printf("MemDB: added private channel to cache: id=%d, name=%s", channelID, name)
+	return channelID, nil
+}
+
+// Membership is delegated to SQLite, like DM participants.
+
+func (m *MemDB) AddChannelParticipant(channelID, userID int64, nickname string) error {
+	return m.sqliteDB.AddChannelParticipant(channelID, userID, nickname)
+}
+
+func (m *MemDB) GetPrivateChannelsForUser(userID int64) ([]*Channel, error) {
+	return m.sqliteDB.GetPrivateChannelsForUser(userID)
+}
+
+// CreatePrivateChannel creates a private channel with its creator as the only
+// member and owner
+func (db *PostgresDB) CreatePrivateChannel(name, displayName string, description *string, channelType uint8, retentionHours uint32, ownerID int64, ownerNickname string) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := nowMillis()
+
+	var channelID int64
+	if err := tx.QueryRow(`
+		INSERT INTO Channel (name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, TRUE)
+		RETURNING id
+	`, name, displayName, description, channelType, retentionHours, ownerID, now).Scan(&channelID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO ChannelParticipant (channel_id, user_id, nickname, joined_at)
+		VALUES ($1, $2, $3, $4)
+	`, channelID, ownerID, ownerNickname, now); err != nil {
+		return 0, fmt.Errorf("failed to add owner as participant: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO ChannelRole (channel_id, user_id, role, granted_by, granted_at)
+		VALUES ($1, $2, $3, '', $4)
+	`, channelID, ownerID, ChannelRoleOwner, now); err != nil {
+		return 0, fmt.Errorf("failed to set channel owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return channelID, nil
+}
+
+// AddChannelParticipant adds a registered user to a private channel. Adding
+// an existing member does nothing.
+func (db *PostgresDB) AddChannelParticipant(channelID, userID int64, nickname string) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO ChannelParticipant (channel_id, user_id, nickname, joined_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (channel_id, user_id) WHERE user_id IS NOT NULL DO NOTHING
+	`, channelID, userID, nickname, nowMillis())
+	return err
+}
+
+// GetPrivateChannelsForUser returns the private channels a user is a member of
+func (db *PostgresDB) GetPrivateChannelsForUser(userID int64) ([]*Channel, error) {
+	return db.queryChannels(`
+		SELECT c.id, c.name, c.display_name, c.description, c.channel_type,
+		       c.message_retention_hours, c.created_by, c.created_at, c.is_private, c.parent_id, c.is_dm, c.archived, c.modes
+		FROM Channel c
+		INNER JOIN ChannelParticipant cp ON cp.channel_id = c.id
+		WHERE cp.user_id = $1 AND c.is_private AND NOT c.is_dm
+		ORDER BY c.name ASC
+	`, userID)
+}
@@ -0,0 +1,227 @@
+package database
+
+// Resume tokens (V4). A registered user who logs in with a password can get
+// a resume token and authenticate with it later instead of sending the
+// password again. Only the SHA-256 of a token is stored. Each token is one
+// of the user's logins, which they can list and revoke.
+
+// ResumeToken is one of a registered user's logins
+type ResumeToken struct {
+	ID         int64
+	UserID     int64
+	TokenHash  []byte // SHA-256 of the token
+	Label      string
+	CreatedAt  int64 // Unix timestamp in milliseconds
+	LastUsedAt int64 // Unix timestamp in milliseconds
+}
+
+func scanResumeToken(row rowScanner) (*ResumeToken, error) {
+	token := &ResumeToken{}
+	if err := row.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.Label, &token.CreatedAt, &token.LastUsedAt); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// CreateResumeToken stores the hash of a new resume token for a user
+func (db *DB) CreateResumeToken(userID int64, tokenHash []byte, label string) (int64, error) {
+	now := nowMillis()
+	result, err := db.writeConn.Exec(`
+		INSERT INTO ResumeToken (user_id, token_hash, label, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, tokenHash, label, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetResumeToken looks up a resume token by its hash. Returns sql.ErrNoRows
+// if there is none.
+func (db *DB) GetResumeToken(tokenHash []byte) (*ResumeToken, error) {
+	return scanResumeToken(db.conn.QueryRow(`
+		SELECT id, user_id, token_hash, label, created_at, last_used_at
+		FROM ResumeToken WHERE token_hash = ?
+	`, tokenHash))
+}
+
+// TouchResumeToken marks a resume token as used now
+func (db *DB) TouchResumeToken(tokenID int64) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE ResumeToken SET last_used_at = ? WHERE id = ?
+	`, nowMillis(), tokenID)
+	return err
+}
+
+// ListResumeTokens returns a user's resume tokens, most recently used first
+func (db *DB) ListResumeTokens(userID int64) ([]*ResumeToken, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, user_id, token_hash, label, created_at, last_used_at
+		FROM ResumeToken
+		WHERE user_id = ?
+		ORDER BY last_used_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*ResumeToken
+	for rows.Next() {
+		token, err := scanResumeToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteResumeToken deletes one of a user's resume tokens. Returns false if
+// the user has no such token.
+func (db *DB) DeleteResumeToken(userID, tokenID int64) (bool, error) {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM ResumeToken WHERE id = ? AND user_id = ?
+	`, tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// DeleteUserResumeTokens deletes all of a user's resume tokens except one
+// (pass 0 to keep none), returning how many were deleted
+func (db *DB) DeleteUserResumeTokens(userID, exceptTokenID int64) (int64, error) {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM ResumeToken WHERE user_id = ? AND id != ?
+	`, userID, exceptTokenID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CleanupExpiredResumeTokens deletes resume tokens unused since before a
+// Unix timestamp in milliseconds, returning how many were deleted
+func (db *DB) CleanupExpiredResumeTokens(unusedSince int64) (int64, error) {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM ResumeToken WHERE last_used_at < ?
+	`, unusedSince)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Resume tokens are only read when users log in or manage their sessions, so
+// MemDB doesn't cache them.
+
+func (m *MemDB) CreateResumeToken(userID int64, tokenHash []byte, label string) (int64, error) {
+	return m.sqliteDB.CreateResumeToken(userID, tokenHash, label)
+}
+
+func (m *MemDB) GetResumeToken(tokenHash []byte) (*ResumeToken, error) {
+	return m.sqliteDB.GetResumeToken(tokenHash)
+}
+
+func (m *MemDB) TouchResumeToken(tokenID int64) error {
+	return m.sqliteDB.TouchResumeToken(tokenID)
+}
+
+func (m *MemDB) ListResumeTokens(userID int64) ([]*ResumeToken, error) {
+	return m.sqliteDB.ListResumeTokens(userID)
+}
+
+func (m *MemDB) DeleteResumeToken(userID, tokenID int64) (bool, error) {
+	return m.sqliteDB.DeleteResumeToken(userID, tokenID)
+}
+
+func (m *MemDB) DeleteUserResumeTokens(userID, exceptTokenID int64) (int64, error) {
+	return m.sqliteDB.DeleteUserResumeTokens(userID, exceptTokenID)
+}
+
+func (m *MemDB) CleanupExpiredResumeTokens(unusedSince int64) (int64, error) {
+	return m.sqliteDB.CleanupExpiredResumeTokens(unusedSince)
+}
+
+// CreateResumeToken stores the hash of a new resume token for a user
+func (db *PostgresDB) CreateResumeToken(userID int64, tokenHash []byte, label string) (int64, error) {
+	var id int64
+	err := db.conn.QueryRow(`
+		INSERT INTO ResumeToken (user_id, token_hash, label, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING id
+	`, userID, tokenHash, label, nowMillis()).Scan(&id)
+	return id, err
+}
+
+// GetResumeToken looks up a resume token by its hash. Returns sql.ErrNoRows
+// if there is none.
+func (db *PostgresDB) GetResumeToken(tokenHash []byte) (*ResumeToken, error) {
+	return scanResumeToken(db.conn.QueryRow(`
+		SELECT id, user_id, token_hash, label, created_at, last_used_at
+		FROM ResumeToken WHERE token_hash = $1
+	`, tokenHash))
+}
+
+// TouchResumeToken marks a resume token as used now
+func (db *PostgresDB) TouchResumeToken(tokenID int64) error {
+	_, err := db.conn.Exec(`UPDATE ResumeToken SET last_used_at = $1 WHERE id = $2`, nowMillis(), tokenID)
+	return err
+}
+
+// ListResumeTokens returns a user's resume tokens, most recently used first
+func (db *PostgresDB) ListResumeTokens(userID int64) ([]*ResumeToken, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, user_id, token_hash, label, created_at, last_used_at
+		FROM ResumeToken
+		WHERE user_id = $1
+		ORDER BY last_used_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*ResumeToken
+	for rows.Next() {
+		token, err := scanResumeToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteResumeToken deletes one of a user's resume tokens. Returns false if
+// the user has no such token.
+func (db *PostgresDB) DeleteResumeToken(userID, tokenID int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM ResumeToken WHERE id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// DeleteUserResumeTokens deletes all of a user's resume tokens except one
+// (pass 0 to keep none), returning how many were deleted
+func (db *PostgresDB) DeleteUserResumeTokens(userID, exceptTokenID int64) (int64, error) {
+	result, err := db.conn.Exec(`DELETE FROM ResumeToken WHERE user_id = $1 AND id != $2`, userID, exceptTokenID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CleanupExpiredResumeTokens deletes resume tokens unused since before a
+// Unix timestamp in milliseconds, returning how many were deleted
+func (db *PostgresDB) CleanupExpiredResumeTokens(unusedSince int64) (int64, error) {
+	result, err := db.conn.Exec(`DELETE FROM ResumeToken WHERE last_used_at < $1`, unusedSince)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
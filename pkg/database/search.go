@@ -0,0 +1,333 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchFilter narrows a full-text message search. Nil fields are ignored.
+type SearchFilter struct {
+	ChannelID      *int64
+	SubchannelID   *int64
+	ThreadID       *int64  // Root message ID; matches the root and all its replies
+	AuthorNickname *string // Case-insensitive match on the stored author nickname
+	After          *int64  // Unix millis, inclusive
+	Before         *int64  // Unix millis, exclusive
+	BeforeID       *int64  // Pagination cursor: only messages with a smaller ID
+
+	// PrivateChannelIDs lists the DM and private channels the searcher may see.
+	// Messages in any other DM or private channel are excluded.
+	PrivateChannelIDs []int64
+
+	// HiddenChannelIDs lists public channels the searcher is kept out of by
+	// channel modes (invite-only, registered-only)
+	HiddenChannelIDs []int64
+
+	Limit int
+}
+
+// searchTerms splits a user query into lowercase words, the same way the
+// unicode61 tokenizer does. A trailing * on a word means prefix match.
+func searchTerms(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '*'
+	})
+	var terms []string
+	for _, field := range fields {
+		prefix := strings.HasSuffix(field, "*")
+		term := strings.ReplaceAll(field, "*", "")
+		if term == "" {
+			continue
+		}
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// buildMatchQuery turns search terms into an FTS5 MATCH expression.
+// Every term is quoted so user input can't inject FTS5 query syntax.
+func buildMatchQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		if strings.HasSuffix(term, "*") {
+			parts[i] = `"` + strings.ReplaceAll(strings.TrimSuffix(term, "*"), `"`, `""`) + `"*`
+		} else {
+			parts[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// matchesSearchTerms reports whether content contains every term.
+// Used for messages that haven't been indexed yet.
+func matchesSearchTerms(content string, terms []string) bool {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, term := range terms {
+		prefix := strings.HasSuffix(term, "*")
+		term = strings.TrimSuffix(term, "*")
+		found := false
+		for _, word := range words {
+			if word == term || (prefix && strings.HasPrefix(word, term)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matches applies the filter to a message (and the channel it belongs to)
+func (f *SearchFilter) matches(msg *Message, channel *Channel) bool {
+	if msg.DeletedAt != nil {
+		return false
+	}
+	if f.ChannelID != nil && msg.ChannelID != *f.ChannelID {
+		return false
+	}
+	if f.SubchannelID != nil && (msg.SubchannelID == nil || *msg.SubchannelID != *f.SubchannelID) {
+		return false
+	}
+	if f.ThreadID != nil && msg.ID != *f.ThreadID && (msg.ThreadRootID == nil || *msg.ThreadRootID != *f.ThreadID) {
+		return false
+	}
+	if f.AuthorNickname != nil && !strings.EqualFold(msg.AuthorNickname, *f.AuthorNickname) {
+		return false
+	}
+	if f.After != nil && msg.CreatedAt < *f.After {
+		return false
+	}
+	if f.Before != nil && msg.CreatedAt >= *f.Before {
+		return false
+	}
+	if f.BeforeID != nil && msg.ID >= *f.BeforeID {
+		return false
+	}
+	if channel == nil || slices.Contains(f.HiddenChannelIDs, channel.ID) {
+		return false
+	}
+	if channel.IsPrivate {
+		for _, id := range f.PrivateChannelIDs {
+			if id == channel.ID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// indexMessagesForSearch replaces the search index entries for messages.
+// Deleted messages are removed from the index.
+func indexMessagesForSearch(tx *sql.Tx, messages []*Message) error {
+	deleteStmt, err := tx.Prepare(`DELETE FROM MessageSearch WHERE rowid = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare search index delete: %w", err)
+	}
+	defer deleteStmt.Close()
+
+	insertStmt, err := tx.Prepare(`INSERT INTO MessageSearch (rowid, content) VALUES (?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare search index insert: %w", err)
+	}
+	defer insertStmt.Close()
+
+	for _, msg := range messages {
+		if _, err := deleteStmt.Exec(msg.ID); err != nil {
+			return fmt.Errorf("failed to remove message %d from search index: %w", msg.ID, err)
+		}
+		if msg.DeletedAt != nil {
+			continue
+		}
+		if _, err := insertStmt.Exec(msg.ID, msg.Content); err != nil {
+			return fmt.Errorf("failed to index message %d: %w", msg.ID, err)
+		}
+	}
+	return nil
+}
+
+// SearchMessages runs a full-text search against the MessageSearch index.
+// Results are ordered newest first.
+func (db *DB) SearchMessages(query string, filter SearchFilter) ([]*Message, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`
+		SELECT m.id, m.channel_id, m.subchannel_id, m.parent_id, m.thread_root_id, m.author_user_id,
+		       m.author_nickname, m.content, m.created_at, m.edited_at, m.deleted_at
+		FROM MessageSearch s
+		INNER JOIN Message m ON m.id = s.rowid
+		INNER JOIN Channel c ON c.id = m.channel_id
+		WHERE MessageSearch MATCH ?
+		  AND m.deleted_at IS NULL`)
+	args := []interface{}{buildMatchQuery(terms)}
+
+	if filter.ChannelID != nil {
+		sb.WriteString(` AND m.channel_id = ?`)
+		args = append(args, *filter.ChannelID)
+	}
+	if filter.SubchannelID != nil {
+		sb.WriteString(` AND m.subchannel_id = ?`)
+		args = append(args, *filter.SubchannelID)
+	}
+	if filter.ThreadID != nil {
+		sb.WriteString(` AND (m.id = ? OR m.thread_root_id = ?)`)
+		args = append(args, *filter.ThreadID, *filter.ThreadID)
+	}
+	if filter.AuthorNickname != nil {
+		sb.WriteString(` AND m.author_nickname = ? COLLATE NOCASE`)
+		args = append(args, *filter.AuthorNickname)
+	}
+	if filter.After != nil {
+		sb.WriteString(` AND m.created_at >= ?`)
+		args = append(args, *filter.After)
+	}
+	if filter.Before != nil {
+		sb.WriteString(` AND m.created_at < ?`)
+		args = append(args, *filter.Before)
+	}
+	if filter.BeforeID != nil {
+		sb.WriteString(` AND m.id < ?`)
+		args = append(args, *filter.BeforeID)
+	}
+
+	// DM and private channels are only searchable by their participants
+	if len(filter.PrivateChannelIDs) > 0 {
+		sb.WriteString(` AND (c.is_private = 0 OR m.channel_id IN (?` + strings.Repeat(`, ?`, len(filter.PrivateChannelIDs)-1) + `))`)
+		for _, id := range filter.PrivateChannelIDs {
+			args = append(args, id)
+		}
+	} else {
+		sb.WriteString(` AND c.is_private = 0`)
+	}
+	if len(filter.HiddenChannelIDs) > 0 {
+		sb.WriteString(` AND m.channel_id NOT IN (?` + strings.Repeat(`, ?`, len(filter.HiddenChannelIDs)-1) + `)`)
+		for _, id := range filter.HiddenChannelIDs {
+			args = append(args, id)
+		}
+	}
+
+	sb.WriteString(` ORDER BY m.id DESC`)
+	if filter.Limit > 0 {
+		sb.WriteString(` LIMIT ?`)
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.conn.Query(sb.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		msg := &Message{}
+		var subchannelID, parentID, threadRootID, authorUserID, editedAt, deletedAt sql.NullInt64
+		if err := rows.Scan(
+			&msg.ID, &msg.ChannelID, &subchannelID, &parentID, &threadRootID, &authorUserID,
+			&msg.AuthorNickname, &msg.Content, &msg.CreatedAt, &editedAt, &deletedAt,
+		); err != nil {
+			return nil, err
+		}
+		if subchannelID.Valid {
+			msg.SubchannelID = &subchannelID.Int64
+		}
+		if parentID.Valid {
+			msg.ParentID = &parentID.Int64
+		}
+		if threadRootID.Valid {
+			msg.ThreadRootID = &threadRootID.Int64
+		}
+		if authorUserID.Valid {
+			msg.AuthorUserID = &authorUserID.Int64
+		}
+		if editedAt.Valid {
+			msg.EditedAt = &editedAt.Int64
+		}
+		if deletedAt.Valid {
+			msg.DeletedAt = &deletedAt.Int64
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// SearchMessages runs a full-text search. Messages already snapshotted are
+// found through the SQLite FTS index; messages changed since the last
+// snapshot are matched in memory so new posts and edits are searchable
+// immediately. Results are ordered newest first.
+func (m *MemDB) SearchMessages(query string, filter SearchFilter) ([]*Message, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	dirtyCount := len(m.dirtyMessages)
+	m.mu.RUnlock()
+
+	// Over-fetch to make up for indexed hits whose in-memory version has changed
+	sqlFilter := filter
+	if filter.Limit > 0 {
+		sqlFilter.Limit = filter.Limit + dirtyCount
+	}
+	indexed, err := m.sqliteDB.SearchMessages(query, sqlFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	results := make([]*Message, 0, len(indexed))
+	seen := make(map[int64]bool, len(indexed))
+	for _, hit := range indexed {
+		// Pending changes are matched below against the in-memory copy
+		if m.dirtyMessages[hit.ID] {
+			continue
+		}
+		msg, exists := m.messages[hit.ID]
+		if !exists || msg.DeletedAt != nil {
+			continue
+		}
+		results = append(results, msg)
+		seen[msg.ID] = true
+	}
+	for id := range m.dirtyMessages {
+		msg, exists := m.messages[id]
+		if !exists || seen[id] {
+			continue
+		}
+		if !filter.matches(msg, m.channels[msg.ChannelID]) || !matchesSearchTerms(msg.Content, terms) {
+			continue
+		}
+		results = append(results, msg)
+	}
+
+	// Copy before releasing the lock (same as GetMessage)
+	copies := make([]*Message, len(results))
+	for i, msg := range results {
+		copies[i] = copyMessage(msg)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(copies, func(i, j int) bool {
+		return copies[i].ID > copies[j].ID
+	})
+	if filter.Limit > 0 && len(copies) > filter.Limit {
+		copies = copies[:filter.Limit]
+	}
+	return copies, nil
+}
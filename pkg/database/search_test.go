@@ -0,0 +1,239 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+// newSearchTestDB creates a MemDB with one public channel and a long snapshot
+// interval, so tests control when messages are indexed
+func newSearchTestDB(t *testing.T) (*MemDB, int64) {
+	t.Helper()
+
+	db, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	channelID, err := db.CreateChannel("decisions", "Decisions", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	t.Cleanup(func() { memDB.Close() })
+
+	return memDB, channelID
+}
+
+func searchIDs(t *testing.T, memDB *MemDB, query string, filter SearchFilter) []int64 {
+	t.Helper()
+	results, err := memDB.SearchMessages(query, filter)
+	if err != nil {
+		t.Fatalf("SearchMessages(%q): %v", query, err)
+	}
+	ids := make([]int64, len(results))
+	for i, msg := range results {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestSearchMessagesIndexedAndPending(t *testing.T) {
+	memDB, channelID := newSearchTestDB(t)
+
+	aliceID, err := memDB.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	rootID, _, err := memDB.PostMessage(channelID, nil, nil, &aliceID, "alice", "We decided to use PostgreSQL for the billing service")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	replyID, _, err := memDB.PostMessage(channelID, nil, &rootID, nil, "bob", "Agreed, postgres it is")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+
+	// Not yet snapshotted: matched in memory
+	if ids := searchIDs(t, memDB, "postgresql", SearchFilter{Limit: 10}); len(ids) != 1 || ids[0] != rootID {
+		t.Fatalf("pending search = %v, want [%d]", ids, rootID)
+	}
+
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	// Snapshotted: found through the FTS index, newest first, prefix match
+	ids := searchIDs(t, memDB, "postgres*", SearchFilter{Limit: 10})
+	if len(ids) != 2 || ids[0] != replyID || ids[1] != rootID {
+		t.Fatalf("indexed search = %v, want [%d %d]", ids, replyID, rootID)
+	}
+
+	// Edits are visible before and after the next snapshot
+	if _, err := memDB.UpdateMessage(uint64(rootID), uint64(aliceID), "We decided to use MySQL for the billing service"); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+	if ids := searchIDs(t, memDB, "postgresql", SearchFilter{Limit: 10}); len(ids) != 0 {
+		t.Errorf("edited message still matches old content: %v", ids)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if ids := searchIDs(t, memDB, "mysql", SearchFilter{Limit: 10}); len(ids) != 1 || ids[0] != rootID {
+		t.Errorf("search after edit = %v, want [%d]", ids, rootID)
+	}
+
+	// Deleted messages drop out of the results
+	if _, err := memDB.SoftDeleteMessage(uint64(replyID), "bob"); err != nil {
+		t.Fatalf("SoftDeleteMessage: %v", err)
+	}
+	if ids := searchIDs(t, memDB, "agreed", SearchFilter{Limit: 10}); len(ids) != 0 {
+		t.Errorf("deleted message still found: %v", ids)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	var indexed int
+	if err := memDB.sqliteDB.conn.QueryRow(`SELECT COUNT(*) FROM MessageSearch WHERE rowid = ?`, replyID).Scan(&indexed); err != nil {
+		t.Fatalf("count index rows: %v", err)
+	}
+	if indexed != 0 {
+		t.Error("deleted message should be removed from the search index")
+	}
+}
+
+func TestSearchIndexFollowsHardDeletes(t *testing.T) {
+	memDB, channelID := newSearchTestDB(t)
+
+	msgID, _, err := memDB.PostMessage(channelID, nil, nil, nil, "bob", "Retention will remove this one")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	countIndexed := func() int {
+		t.Helper()
+		var n int
+		if err := memDB.sqliteDB.conn.QueryRow(`SELECT COUNT(*) FROM MessageSearch WHERE rowid = ?`, msgID).Scan(&n); err != nil {
+			t.Fatalf("count index rows: %v", err)
+		}
+		return n
+	}
+	if countIndexed() != 1 {
+		t.Fatal("expected the message to be indexed")
+	}
+
+	// Deleting the channel cascades to its messages and their index rows
+	if err := memDB.sqliteDB.DeleteChannel(uint64(channelID)); err != nil {
+		t.Fatalf("DeleteChannel: %v", err)
+	}
+	if countIndexed() != 0 {
+		t.Error("expected the index row to be removed with the message")
+	}
+}
+
+func TestSearchMessagesFilters(t *testing.T) {
+	memDB, channelID := newSearchTestDB(t)
+
+	otherChannelID, err := memDB.CreateChannel("random", "Random", nil, 0, 168, nil)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	rootID, _, _ := memDB.PostMessage(channelID, nil, nil, nil, "alice", "deploy schedule for Q3")
+	replyID, _, _ := memDB.PostMessage(channelID, nil, &rootID, nil, "bob", "deploy on Thursdays")
+	otherRootID, _, _ := memDB.PostMessage(channelID, nil, nil, nil, "bob", "deploy freeze in December")
+	otherChannelMsgID, _, _ := memDB.PostMessage(otherChannelID, nil, nil, nil, "alice", "deploy the cat pictures")
+
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	bob := "BOB"
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		want   []int64
+	}{
+		{"channel", SearchFilter{ChannelID: &channelID}, []int64{otherRootID, replyID, rootID}},
+		{"other channel", SearchFilter{ChannelID: &otherChannelID}, []int64{otherChannelMsgID}},
+		{"thread", SearchFilter{ThreadID: &rootID}, []int64{replyID, rootID}},
+		{"author", SearchFilter{AuthorNickname: &bob}, []int64{otherRootID, replyID}},
+		{"before id", SearchFilter{ChannelID: &channelID, BeforeID: &otherRootID}, []int64{replyID, rootID}},
+		{"limit", SearchFilter{ChannelID: &channelID, Limit: 1}, []int64{otherRootID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := searchIDs(t, memDB, "deploy", tt.filter)
+			if len(ids) != len(tt.want) {
+				t.Fatalf("got %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+
+	// Date range
+	msg, err := memDB.GetMessage(replyID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	after := msg.CreatedAt + 1
+	for _, id := range searchIDs(t, memDB, "deploy", SearchFilter{After: &after}) {
+		if id == rootID {
+			t.Error("date filter should exclude older messages")
+		}
+	}
+}
+
+func TestSearchMessagesDMAccess(t *testing.T) {
+	memDB, channelID := newSearchTestDB(t)
+
+	aliceID, _ := memDB.CreateUser("alice", "hash", 0)
+	bobID, _ := memDB.CreateUser("bob", "hash", 0)
+	dmID, err := memDB.CreateDMChannel(aliceID, bobID, false)
+	if err != nil {
+		t.Fatalf("CreateDMChannel: %v", err)
+	}
+
+	publicID, _, _ := memDB.PostMessage(channelID, nil, nil, nil, "alice", "the secret handshake")
+	dmMsgID, _, _ := memDB.PostMessage(dmID, nil, nil, &aliceID, "alice", "the secret password")
+
+	check := func(stage string) {
+		if ids := searchIDs(t, memDB, "secret", SearchFilter{}); len(ids) != 1 || ids[0] != publicID {
+			t.Errorf("%s: outsider search = %v, want [%d]", stage, ids, publicID)
+		}
+		if ids := searchIDs(t, memDB, "secret", SearchFilter{PrivateChannelIDs: []int64{dmID}}); len(ids) != 2 || ids[0] != dmMsgID {
+			t.Errorf("%s: participant search = %v, want [%d %d]", stage, ids, dmMsgID, publicID)
+		}
+		if ids := searchIDs(t, memDB, "secret", SearchFilter{PrivateChannelIDs: []int64{dmID}, HiddenChannelIDs: []int64{channelID}}); len(ids) != 1 || ids[0] != dmMsgID {
+			t.Errorf("%s: search without the hidden channel = %v, want [%d]", stage, ids, dmMsgID)
+		}
+	}
+
+	check("pending")
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	check("indexed")
+}
+
+func TestBuildMatchQueryEscapesSyntax(t *testing.T) {
+	terms := searchTerms(`"drop" OR NEAR(x) -foo deploy*`)
+	got := buildMatchQuery(terms)
+	want := `"drop" AND "or" AND "near" AND "x" AND "foo" AND "deploy"*`
+	if got != want {
+		t.Errorf("buildMatchQuery = %s, want %s", got, want)
+	}
+}
@@ -22,14 +22,14 @@ const (
 	workerIDShift    = sequenceBits
 	timestampShift   = sequenceBits + workerIDBits
 	sequenceMask     = (1 << sequenceBits) - 1 // 4095
-	maxWorkerID      = (1 << workerIDBits) - 1 // 1023
+	MaxWorkerID      = (1 << workerIDBits) - 1 // 1023
 )
 
 // NewSnowflake creates a new Snowflake ID generator
 // epoch should be a timestamp in milliseconds (e.g., time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli())
 // workerID should be unique per server instance (0-1023)
 func NewSnowflake(epoch int64, workerID int64) *Snowflake {
-	if workerID < 0 || workerID > maxWorkerID {
+	if workerID < 0 || workerID > MaxWorkerID {
 		workerID = 0
 	}
 	return &Snowflake{
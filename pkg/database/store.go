@@ -0,0 +1,176 @@
+package database
+
+// Store is the storage interface used by the server. MemDB (in-memory with
+// SQLite snapshots), DB (SQLite) and PostgresDB implement it.
+type Store interface {
+	// Sessions
+	CreateSession(userID *int64, nickname, connType string) (int64, error)
+	GetSession(sessionID int64) (*Session, error)
+	UpdateSessionActivity(sessionID int64) error
+	UpdateSessionNickname(sessionID int64, nickname string) error
+	UpdateSessionUserID(sessionID, userID int64) error
+	DeleteSession(sessionID int64) error
+	CleanupIdleSessions(timeoutSeconds int64) (int64, error)
+
+	// Channels and subchannels
+	ListChannels() ([]*Channel, error)
+	CountChannels() uint32
+	GetChannel(channelID int64) (*Channel, error)
+	ChannelExists(channelID int64) (bool, error)
+	CreateChannel(name, displayName string, description *string, channelType uint8, retentionHours uint32, createdBy *int64) (int64, error)
+	DeleteChannel(channelID uint64) error
+	UpdateChannel(channelID int64, displayName string, description *string, channelType uint8, retentionHours uint32, archived bool) error
+	CreateSubchannel(parentID int64, name, displayName string, description *string, channelType uint8, retentionHours uint32, createdBy *int64) (int64, error)
+	GetSubchannels(parentID int64) ([]*Channel, error)
+	GetSubchannelCount(parentID int64) (int, error)
+	SubchannelExists(subchannelID int64) (bool, error)
+
+	// Messages
+	PostMessage(channelID int64, subchannelID, parentID, authorUserID *int64, authorNickname, content string) (int64, *Message, error)
+	CreateSystemMessage(channelID int64, content string) (int64, *Message, error)
+	GetMessage(messageID int64) (*Message, error)
+	MessageExists(messageID int64) (bool, error)
+	ListRootMessages(channelID int64, subchannelID *int64, limit uint16, beforeID *uint64, afterID *uint64) ([]*Message, error)
+	ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64, afterID *uint64) ([]*Message, error)
+	CountReplies(messageID int64) (uint32, error)
+	UpdateMessage(messageID uint64, userID uint64, newContent string) (*Message, error)
+	AdminUpdateMessage(messageID uint64, userID uint64, newContent string) (*Message, error)
+	SoftDeleteMessage(messageID uint64, nickname string) (*Message, error)
+	AdminSoftDeleteMessage(messageID uint64, adminNickname string) (*Message, error)
+	CleanupExpiredMessages() (int64, error)
+	SearchMessages(query string, filter SearchFilter) ([]*Message, error)
+	PlusOneMessage(messageID uint64, userID *int64) (*Message, bool, error)
+	RecordMentions(mentions []Mention) error
+	ListMentions(userID int64, beforeID *int64, limit int) ([]*Message, error)
+	GetMessageVersions(messageID int64) ([]MessageVersion, error)
+
+	// Read state
+	UpdateUserChannelState(userID uint64, channelID uint64, subchannelID *uint64, timestamp int64) error
+	GetUserChannelState(userID uint64, channelID uint64, subchannelID *uint64) (int64, error)
+	GetUnreadCountForChannel(channelID uint64, subchannelID *uint64, sinceTimestamp int64) (uint32, error)
+	GetUnreadCountForThread(threadID uint64, sinceTimestamp int64) (uint32, error)
+
+	// Users
+	CreateUser(nickname, passwordHash string, userFlags uint8) (int64, error)
+	GetUserByID(userID int64) (*User, error)
+	GetUserByNickname(nickname string) (*User, error)
+	GetUsersByNicknames(nicknames []string) ([]*User, error)
+	ListAllUsers(limit int) ([]*User, error)
+	UpdateUserLastSeen(userID int64) error
+	UpdateUserNickname(userID int64, newNickname string) error
+	UpdateUserPassword(userID int64, newPasswordHash string) error
+	UpdateUserFlags(userID int64, flags uint8) error
+	DeleteUser(userID uint64) (string, error)
+
+	// Resume tokens
+	CreateResumeToken(userID int64, tokenHash []byte, label string) (int64, error)
+	GetResumeToken(tokenHash []byte) (*ResumeToken, error)
+	TouchResumeToken(tokenID int64) error
+	ListResumeTokens(userID int64) ([]*ResumeToken, error)
+	DeleteResumeToken(userID, tokenID int64) (bool, error)
+	DeleteUserResumeTokens(userID, exceptTokenID int64) (int64, error)
+	CleanupExpiredResumeTokens(unusedSince int64) (int64, error)
+
+	// TOTP two-factor authentication
+	EnableTOTP(userID int64, secret []byte, recoveryCodeHashes [][]byte) error
+	GetTOTP(userID int64) (*UserTOTP, error)
+	DisableTOTP(userID int64) error
+	UseTOTPStep(userID, step int64) (bool, error)
+	UseTOTPRecoveryCode(userID int64, codeHash []byte) (bool, error)
+	CountTOTPRecoveryCodes(userID int64) (int, error)
+
+	// SSH keys
+	CreateSSHKey(key *SSHKey) error
+	GetSSHKeyByFingerprint(fingerprint string) (*SSHKey, error)
+	GetSSHKeysByUserID(userID int64) ([]SSHKey, error)
+	UpdateSSHKeyLastUsed(fingerprint string) error
+	UpdateSSHKeyLabel(keyID, userID int64, label string) error
+	DeleteSSHKey(keyID, userID int64) error
+
+	// Bans and admin actions
+	CreateUserBan(userID *int64, nickname *string, reason string, shadowban bool, durationSeconds *uint64, adminNickname, adminIP string) (int64, error)
+	CreateIPBan(ipCIDR string, reason string, durationSeconds *uint64, adminNickname, adminIP string) (int64, error)
+	DeleteUserBan(userID *int64, nickname *string, adminNickname, adminIP string) (int64, error)
+	DeleteIPBan(ipCIDR string, adminNickname, adminIP string) (int64, error)
+	GetActiveBanForUser(userID *int64, nickname *string) (*Ban, error)
+	ListBans(includeExpired bool) ([]*Ban, error)
+	LogAdminAction(adminUserID uint64, adminNickname, actionType, details string) error
+	ListAdminActions(filter AdminActionFilter) ([]*AdminAction, error)
+	CreateMute(userID *int64, nickname string, channelID *int64, reason string, durationSeconds *uint64, adminNickname string) (int64, error)
+	GetActiveMute(userID *int64, nickname string, channelID int64) (*Mute, error)
+	DeleteMutes(userID *int64, nickname string, channelID *int64) (int64, error)
+	GetChannelRole(channelID, userID int64) (string, error)
+	SetChannelRole(channelID, userID int64, role, grantedBy string) error
+	HasChannelRole(userID int64) (bool, error)
+	ListChannelRoles(channelID int64) ([]*ChannelRole, error)
+	SetChannelModes(channelID int64, modes uint8) error
+	HasChannelGrant(channelID, userID int64, kind string) (bool, error)
+	SetChannelGrant(channelID, userID int64, kind string, granted bool, grantedBy string) error
+
+	// Direct messages and private channels
+	SetUserEncryptionKey(userID int64, publicKey []byte) error
+	GetUserEncryptionKey(userID int64) ([]byte, error)
+	SetUserRatchetSupport(userID int64, supported bool) error
+	GetUserRatchetSupport(userID int64) (bool, error)
+	SetDMRatchet(channelID int64) error
+	IsDMRatchet(channelID int64) (bool, error)
+	RegisterUserDevice(userID int64, publicKey []byte, label string, multiDevice bool) (*UserDevice, bool, error)
+	ListUserDevices(userID int64) ([]*UserDevice, error)
+	RevokeUserDevice(userID, deviceID int64) (bool, error)
+	SetDMMultiDevice(channelID int64) error
+	IsDMMultiDevice(channelID int64) (bool, error)
+	CreateDMChannel(user1ID, user2ID int64, isEncrypted bool) (int64, error)
+	CreateDMChannelWithParticipants(user1ID *int64, session1ID int64, nickname1 string, user2ID *int64, session2ID int64, nickname2 string) (int64, error)
+	GetDMChannels(userID int64) ([]*Channel, error)
+	GetDMChannelBetweenUsers(user1ID, user2ID int64) (*Channel, error)
+	UserHasAccessToChannel(userID, channelID int64) (bool, error)
+	CreateDMInviteWithSessions(initiatorUserID, targetUserID *int64, initiatorSessionID, targetSessionID int64, isEncrypted bool) (int64, error)
+	GetDMInvite(inviteID int64) (*DMInvite, error)
+	GetPendingDMInvitesForUser(userID int64) ([]*DMInvite, error)
+	DeleteDMInvite(inviteID int64) error
+	GetChannelParticipants(channelID int64) ([]*ChannelParticipant, error)
+	IsChannelParticipant(channelID int64, userID *int64, sessionID int64) (bool, error)
+	GetDMChannelsForParticipant(userID *int64, sessionID int64) ([]int64, error)
+	RemoveParticipantByUserID(channelID, userID int64) error
+	RemoveParticipantBySessionID(channelID, sessionID int64) error
+	CreatePrivateChannel(name, displayName string, description *string, channelType uint8, retentionHours uint32, ownerID int64, ownerNickname string) (int64, error)
+	AddChannelParticipant(channelID, userID int64, nickname string) error
+	GetPrivateChannelsForUser(userID int64) ([]*Channel, error)
+	SaveChannelKeys(channelID int64, epoch uint32, createdBy string, keys []*ChannelKey) error
+	GetChannelKeyEpoch(channelID int64) (uint32, error)
+	ListChannelKeysForUser(channelID, userID int64) ([]*ChannelKey, error)
+	ListChannelKeyRecipients(channelID int64, epoch uint32) ([]*ChannelKey, error)
+	SetKeyBackup(userID int64, backup []byte) (int64, error)
+	GetKeyBackup(userID int64) ([]byte, int64, error)
+	DeleteKeyBackup(userID int64) error
+
+	// Server discovery
+	RegisterDiscoveredServer(hostname string, port uint16, name, description string, maxUsers uint32, isPublic bool, channelCount uint32, sourceIP, discoveredVia string) (int64, error)
+	UpdateHeartbeat(hostname string, port uint16, userCount uint32, uptimeSeconds uint64, channelCount uint32, newInterval uint32) error
+	ListDiscoveredServers(limit uint16) ([]*DiscoveredServer, error)
+	GetDiscoveredServer(hostname string, port uint16) (*DiscoveredServer, error)
+	CountDiscoveredServers() (uint32, error)
+
+	// Outgoing webhook queue
+	EnqueueWebhookDelivery(d *WebhookDelivery) error
+	ListDueWebhookDeliveries(now int64, limit int) ([]*WebhookDelivery, error)
+	RescheduleWebhookDelivery(id int64, attempts int, nextAttemptAt int64, lastError string) error
+	DeleteWebhookDelivery(id int64) error
+
+	// Incoming webhooks
+	CreateIncomingWebhook(hook *IncomingWebhook) error
+	GetIncomingWebhookByTokenHash(tokenHash string) (*IncomingWebhook, error)
+	ListIncomingWebhooks() ([]*IncomingWebhook, error)
+	DeleteIncomingWebhook(id int64) error
+	TouchIncomingWebhook(id int64, usedAt int64) error
+
+	// Close releases the store's resources
+	Close() error
+}
+
+// Compile-time checks that the backends implement Store
+var (
+	_ Store = (*MemDB)(nil)
+	_ Store = (*DB)(nil)
+	_ Store = (*PostgresDB)(nil)
+)
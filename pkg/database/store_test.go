@@ -0,0 +1,822 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forEachStore runs fn against every Store implementation. The PostgreSQL
+// backend only runs when SUPERCHAT_TEST_POSTGRES_DSN points at a scratch
+// database; its tables are truncated before each run.
+func forEachStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	t.Run("sqlite", func(t *testing.T) {
+		db := newTestDB(t)
+		defer db.Close()
+		fn(t, db)
+	})
+
+	t.Run("memdb", func(t *testing.T) {
+		db := newTestDB(t)
+		defer db.Close()
+		memDB, err := NewMemDB(db, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to create MemDB: %v", err)
+		}
+		defer memDB.Close()
+		fn(t, memDB)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv("SUPERCHAT_TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("SUPERCHAT_TEST_POSTGRES_DSN not set")
+		}
+		db, err := OpenPostgres(dsn, 0)
+		if err != nil {
+			t.Fatalf("failed to open PostgreSQL: %v", err)
+		}
+		defer db.Close()
+		if _, err := db.conn.Exec(`
+			TRUNCATE "User", Channel, Session, Message, MessageVersion, DiscoveredServer, SSHKey, Ban,
+				AdminAction, UserChannelState, ChannelAccess, DMInvite, ChannelParticipant, UserPlusOne, Mention, WebhookDelivery,
+				IncomingWebhook, Mute, ChannelRole, ChannelGrant, ChannelKey, KeyBackup, UserDevice, ResumeToken, UserTOTP, TOTPRecoveryCode
+			RESTART IDENTITY CASCADE
+		`); err != nil {
+			t.Fatalf("failed to reset PostgreSQL tables: %v", err)
+		}
+		fn(t, db)
+	})
+}
+
+func TestOpenPostgresWorkerIDRange(t *testing.T) {
+	for _, workerID := range []int64{-1, MaxWorkerID + 1} {
+		if _, err := OpenPostgres("postgres://localhost/unused", workerID); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("worker ID %d: expected out of range error, got %v", workerID, err)
+		}
+	}
+}
+
+func TestStoreChannels(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		desc := "Test channel"
+		channelID, err := store.CreateChannel("test", "#test", &desc, 1, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+
+		ch, err := store.GetChannel(channelID)
+		if err != nil {
+			t.Fatalf("GetChannel: %v", err)
+		}
+		if ch.Name != "test" || ch.Description == nil || *ch.Description != desc || ch.IsPrivate || ch.IsDM {
+			t.Errorf("GetChannel = %+v", ch)
+		}
+
+		if exists, err := store.ChannelExists(channelID); err != nil || !exists {
+			t.Errorf("ChannelExists = %v, %v; want true", exists, err)
+		}
+		if exists, err := store.ChannelExists(channelID + 1000); err != nil || exists {
+			t.Errorf("ChannelExists(missing) = %v, %v; want false", exists, err)
+		}
+
+		subID, err := store.CreateSubchannel(channelID, "sub", "#sub", nil, 0, 24, nil)
+		if err != nil {
+			t.Fatalf("CreateSubchannel: %v", err)
+		}
+		subs, err := store.GetSubchannels(channelID)
+		if err != nil {
+			t.Fatalf("GetSubchannels: %v", err)
+		}
+		if len(subs) != 1 || subs[0].ID != subID || subs[0].ParentID == nil || *subs[0].ParentID != channelID {
+			t.Errorf("GetSubchannels = %+v, want subchannel %d", subs, subID)
+		}
+		if count, err := store.GetSubchannelCount(channelID); err != nil || count != 1 {
+			t.Errorf("GetSubchannelCount = %d, %v; want 1", count, err)
+		}
+
+		if got := store.CountChannels(); got != 2 {
+			t.Errorf("CountChannels = %d, want 2", got)
+		}
+	})
+}
+
+func TestStoreThreads(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		channelID, err := store.CreateChannel("test", "#test", nil, 1, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+
+		rootID, root, err := store.PostMessage(channelID, nil, nil, nil, "alice", "root")
+		if err != nil {
+			t.Fatalf("PostMessage(root): %v", err)
+		}
+		if root.ThreadRootID == nil || *root.ThreadRootID != rootID {
+			t.Errorf("root ThreadRootID = %v, want %d", root.ThreadRootID, rootID)
+		}
+		replyID, _, err := store.PostMessage(channelID, nil, &rootID, nil, "bob", "reply")
+		if err != nil {
+			t.Fatalf("PostMessage(reply): %v", err)
+		}
+		nestedID, nested, err := store.PostMessage(channelID, nil, &replyID, nil, "alice", "nested")
+		if err != nil {
+			t.Fatalf("PostMessage(nested): %v", err)
+		}
+		if nested.ThreadRootID == nil || *nested.ThreadRootID != rootID {
+			t.Errorf("nested ThreadRootID = %v, want %d", nested.ThreadRootID, rootID)
+		}
+
+		missing := int64(1)
+		if _, _, err := store.PostMessage(channelID, nil, &missing, nil, "alice", "orphan"); err == nil {
+			t.Error("PostMessage with missing parent succeeded")
+		}
+
+		roots, err := store.ListRootMessages(channelID, nil, 50, nil, nil)
+		if err != nil {
+			t.Fatalf("ListRootMessages: %v", err)
+		}
+		if len(roots) != 1 || roots[0].ID != rootID {
+			t.Errorf("ListRootMessages = %v, want [%d]", messageIDs(roots), rootID)
+		}
+
+		replies, err := store.ListThreadReplies(uint64(rootID), 50, nil, nil)
+		if err != nil {
+			t.Fatalf("ListThreadReplies: %v", err)
+		}
+		if got := messageIDs(replies); len(got) != 2 || got[0] != replyID || got[1] != nestedID {
+			t.Errorf("ListThreadReplies = %v, want [%d %d]", got, replyID, nestedID)
+		}
+
+		if count, err := store.CountReplies(replyID); err != nil || count != 1 {
+			t.Errorf("CountReplies(reply) = %d, %v; want 1", count, err)
+		}
+
+		msg, err := store.GetMessage(nestedID)
+		if err != nil {
+			t.Fatalf("GetMessage: %v", err)
+		}
+		if msg.Content != "nested" || msg.ParentID == nil || *msg.ParentID != replyID {
+			t.Errorf("GetMessage = %+v", msg)
+		}
+	})
+}
+
+func TestStoreEditAndPlusOne(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		channelID, err := store.CreateChannel("test", "#test", nil, 1, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+		aliceID, err := store.CreateUser("Alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bobID, err := store.CreateUser("bob", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		users, err := store.GetUsersByNicknames([]string{"alice", "BOB", "carol"})
+		if err != nil {
+			t.Fatalf("GetUsersByNicknames: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("GetUsersByNicknames returned %d users, want 2", len(users))
+		}
+
+		msgID, _, err := store.PostMessage(channelID, nil, nil, &aliceID, "Alice", "first draft")
+		if err != nil {
+			t.Fatalf("PostMessage: %v", err)
+		}
+
+		if _, err := store.UpdateMessage(uint64(msgID), uint64(bobID), "hijacked"); !errors.Is(err, ErrMessageNotOwned) {
+			t.Errorf("UpdateMessage by non-author error = %v, want ErrMessageNotOwned", err)
+		}
+		edited, err := store.UpdateMessage(uint64(msgID), uint64(aliceID), "final")
+		if err != nil {
+			t.Fatalf("UpdateMessage: %v", err)
+		}
+		if edited.Content != "final" || edited.EditedAt == nil {
+			t.Errorf("UpdateMessage = %+v", edited)
+		}
+
+		if _, counted, err := store.PlusOneMessage(uint64(msgID), &bobID); err != nil || !counted {
+			t.Fatalf("PlusOneMessage = %v, %v; want counted", counted, err)
+		}
+		if _, counted, err := store.PlusOneMessage(uint64(msgID), &bobID); err != nil || counted {
+			t.Errorf("repeat PlusOneMessage = %v, %v; want not counted", counted, err)
+		}
+		msg, _, err := store.PlusOneMessage(uint64(msgID), nil)
+		if err != nil {
+			t.Fatalf("anonymous PlusOneMessage: %v", err)
+		}
+		if msg.PlusOneCount != 1 || msg.PlusOneAnonCount != 1 {
+			t.Errorf("counts = %d/%d, want 1/1", msg.PlusOneCount, msg.PlusOneAnonCount)
+		}
+	})
+}
+
+func messageIDs(messages []*Message) []int64 {
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestStoreMutes(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		userID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		general, err := store.CreateChannel("general", "#general", nil, 1, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+		random, err := store.CreateChannel("random", "#random", nil, 1, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+
+		// A channel mute only applies to that channel
+		hour := uint64(3600)
+		if _, err := store.CreateMute(&userID, "alice", &general, "flooding", &hour, "admin"); err != nil {
+			t.Fatalf("CreateMute: %v", err)
+		}
+		mute, err := store.GetActiveMute(&userID, "alice", general)
+		if err != nil || mute == nil || mute.Reason != "flooding" || mute.MutedUntil == nil {
+			t.Fatalf("expected a timed mute in #general, got %+v (%v)", mute, err)
+		}
+		if mute, err := store.GetActiveMute(&userID, "alice", random); err != nil || mute != nil {
+			t.Fatalf("expected no mute in #random, got %+v (%v)", mute, err)
+		}
+
+		// Anonymous users are matched by nickname, case-insensitively
+		if _, err := store.CreateMute(nil, "Guest", nil, "spam", nil, "admin"); err != nil {
+			t.Fatalf("CreateMute: %v", err)
+		}
+		if mute, err := store.GetActiveMute(nil, "guest", random); err != nil || mute == nil || mute.MutedUntil != nil {
+			t.Fatalf("expected a permanent global mute for guest, got %+v (%v)", mute, err)
+		}
+		if mute, err := store.GetActiveMute(&userID, "alice", random); err != nil || mute != nil {
+			t.Fatalf("expected alice to be unaffected by guest's mute, got %+v (%v)", mute, err)
+		}
+
+		// Expired mutes are ignored
+		zero := uint64(0)
+		if _, err := store.CreateMute(nil, "bob", nil, "old", &zero, "admin"); err != nil {
+			t.Fatalf("CreateMute: %v", err)
+		}
+		if mute, err := store.GetActiveMute(nil, "bob", general); err != nil || mute != nil {
+			t.Fatalf("expected the expired mute to be ignored, got %+v (%v)", mute, err)
+		}
+
+		// Unmuting only lifts mutes of the given scope
+		if n, err := store.DeleteMutes(&userID, "alice", nil); err != nil || n != 0 {
+			t.Fatalf("expected no global mutes to lift, got %d (%v)", n, err)
+		}
+		if n, err := store.DeleteMutes(&userID, "alice", &general); err != nil || n != 1 {
+			t.Fatalf("expected 1 channel mute lifted, got %d (%v)", n, err)
+		}
+		if mute, err := store.GetActiveMute(&userID, "alice", general); err != nil || mute != nil {
+			t.Fatalf("expected alice to be unmuted, got %+v (%v)", mute, err)
+		}
+	})
+}
+
+func TestStoreChannelRoles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bobID, err := store.CreateUser("Bob", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		general, err := store.CreateChannel("general", "#general", nil, 1, 168, &aliceID)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+
+		if role, err := store.GetChannelRole(general, bobID); err != nil || role != ChannelRoleMember {
+			t.Fatalf("expected bob to be a member, got %q (%v)", role, err)
+		}
+
+		// The creator owns the channel
+		if role, err := store.GetChannelRole(general, aliceID); err != nil || role != ChannelRoleOwner {
+			t.Fatalf("expected alice to own the channel, got %q (%v)", role, err)
+		}
+		if err := store.SetChannelRole(general, bobID, ChannelRoleModerator, "alice"); err != nil {
+			t.Fatalf("SetChannelRole: %v", err)
+		}
+		if role, err := store.GetChannelRole(general, bobID); err != nil || role != ChannelRoleModerator {
+			t.Fatalf("expected bob to be a moderator, got %q (%v)", role, err)
+		}
+
+		roles, err := store.ListChannelRoles(general)
+		if err != nil {
+			t.Fatalf("ListChannelRoles: %v", err)
+		}
+		if len(roles) != 2 || roles[0].Nickname != "alice" || roles[0].Role != ChannelRoleOwner ||
+			roles[1].Nickname != "Bob" || roles[1].GrantedBy != "alice" {
+			t.Fatalf("unexpected roles %+v", roles)
+		}
+
+		// Setting a role again replaces it; member removes it
+		if err := store.SetChannelRole(general, bobID, ChannelRoleOwner, "admin"); err != nil {
+			t.Fatalf("SetChannelRole: %v", err)
+		}
+		if role, _ := store.GetChannelRole(general, bobID); role != ChannelRoleOwner {
+			t.Errorf("expected bob to be promoted to owner, got %q", role)
+		}
+		if err := store.SetChannelRole(general, bobID, ChannelRoleMember, "admin"); err != nil {
+			t.Fatalf("SetChannelRole: %v", err)
+		}
+		if roles, err := store.ListChannelRoles(general); err != nil || len(roles) != 1 {
+			t.Fatalf("expected only alice left, got %+v (%v)", roles, err)
+		}
+		if has, err := store.HasChannelRole(aliceID); err != nil || !has {
+			t.Errorf("expected alice to hold a role, got %v (%v)", has, err)
+		}
+		if has, _ := store.HasChannelRole(bobID); has {
+			t.Error("expected bob to hold no role")
+		}
+	})
+}
+
+func TestStoreChannelModesAndGrants(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		general, err := store.CreateChannel("general", "#general", nil, 1, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+
+		if err := store.SetChannelModes(general, 0x09); err != nil {
+			t.Fatalf("SetChannelModes: %v", err)
+		}
+		if ch, err := store.GetChannel(general); err != nil || ch.Modes != 0x09 {
+			t.Fatalf("expected modes 0x09, got %+v (%v)", ch, err)
+		}
+
+		if ok, err := store.HasChannelGrant(general, aliceID, ChannelGrantInvite); err != nil || ok {
+			t.Fatalf("expected no invite yet, got %v (%v)", ok, err)
+		}
+		// Granting twice is harmless
+		for i := 0; i < 2; i++ {
+			if err := store.SetChannelGrant(general, aliceID, ChannelGrantInvite, true, "admin"); err != nil {
+				t.Fatalf("SetChannelGrant: %v", err)
+			}
+		}
+		if ok, err := store.HasChannelGrant(general, aliceID, ChannelGrantInvite); err != nil || !ok {
+			t.Fatalf("expected alice to be invited, got %v (%v)", ok, err)
+		}
+		if ok, _ := store.HasChannelGrant(general, aliceID, ChannelGrantVoice); ok {
+			t.Error("expected an invite not to voice alice")
+		}
+
+		if err := store.SetChannelGrant(general, aliceID, ChannelGrantInvite, false, "admin"); err != nil {
+			t.Fatalf("SetChannelGrant: %v", err)
+		}
+		if ok, _ := store.HasChannelGrant(general, aliceID, ChannelGrantInvite); ok {
+			t.Error("expected the invite to be withdrawn")
+		}
+	})
+}
+
+func TestStorePrivateChannels(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bobID, err := store.CreateUser("bob", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err := store.CreateChannel("general", "#general", nil, 1, 168, nil); err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+
+		secret, err := store.CreatePrivateChannel("secret", "#secret", nil, 0, 168, aliceID, "alice")
+		if err != nil {
+			t.Fatalf("CreatePrivateChannel: %v", err)
+		}
+		if ch, err := store.GetChannel(secret); err != nil || !ch.IsPrivate || ch.IsDM {
+			t.Fatalf("expected a private, non-DM channel, got %+v (%v)", ch, err)
+		}
+		if role, _ := store.GetChannelRole(secret, aliceID); role != ChannelRoleOwner {
+			t.Errorf("expected the creator to own the channel, got %q", role)
+		}
+
+		// Private channels are left out of everything public
+		channels, err := store.ListChannels()
+		if err != nil {
+			t.Fatalf("ListChannels: %v", err)
+		}
+		if len(channels) != 1 || channels[0].Name != "general" {
+			t.Errorf("expected only #general to be listed, got %d channels", len(channels))
+		}
+		if got := store.CountChannels(); got != 1 {
+			t.Errorf("CountChannels = %d, want 1", got)
+		}
+
+		if ok, _ := store.UserHasAccessToChannel(bobID, secret); ok {
+			t.Error("expected bob to have no access before being added")
+		}
+		// Adding twice is harmless
+		for i := 0; i < 2; i++ {
+			if err := store.AddChannelParticipant(secret, bobID, "bob"); err != nil {
+				t.Fatalf("AddChannelParticipant: %v", err)
+			}
+		}
+		if ok, err := store.UserHasAccessToChannel(bobID, secret); err != nil || !ok {
+			t.Fatalf("expected bob to have access, got %v (%v)", ok, err)
+		}
+		if participants, _ := store.GetChannelParticipants(secret); len(participants) != 2 {
+			t.Errorf("expected 2 members, got %d", len(participants))
+		}
+
+		mine, err := store.GetPrivateChannelsForUser(bobID)
+		if err != nil {
+			t.Fatalf("GetPrivateChannelsForUser: %v", err)
+		}
+		if len(mine) != 1 || mine[0].ID != secret {
+			t.Errorf("expected bob's private channels to be [%d], got %d channels", secret, len(mine))
+		}
+
+		if err := store.RemoveParticipantByUserID(secret, bobID); err != nil {
+			t.Fatalf("RemoveParticipantByUserID: %v", err)
+		}
+		if ok, _ := store.UserHasAccessToChannel(bobID, secret); ok {
+			t.Error("expected bob to lose access when removed")
+		}
+		if mine, _ := store.GetPrivateChannelsForUser(bobID); len(mine) != 0 {
+			t.Errorf("expected no private channels for bob, got %d", len(mine))
+		}
+	})
+}
+
+func TestStoreChannelKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bobID, err := store.CreateUser("bob", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		secret, err := store.CreatePrivateChannel("secret", "#secret", nil, 0, 168, aliceID, "alice")
+		if err != nil {
+			t.Fatalf("CreatePrivateChannel: %v", err)
+		}
+
+		if epoch, err := store.GetChannelKeyEpoch(secret); err != nil || epoch != 0 {
+			t.Fatalf("expected no key epoch yet, got %d (%v)", epoch, err)
+		}
+
+		keyFor := func(userID int64, b byte) *ChannelKey {
+			return &ChannelKey{UserID: userID, PublicKey: []byte{b, b}, WrappedKey: []byte{b, b, b}}
+		}
+		if err := store.SaveChannelKeys(secret, 2, "alice", []*ChannelKey{keyFor(aliceID, 1)}); !errors.Is(err, ErrStaleChannelKeyEpoch) {
+			t.Errorf("expected skipping epoch 1 to fail, got %v", err)
+		}
+		if err := store.SaveChannelKeys(secret, 1, "alice", []*ChannelKey{keyFor(aliceID, 1)}); err != nil {
+			t.Fatalf("SaveChannelKeys: %v", err)
+		}
+		if err := store.SaveChannelKeys(secret, 1, "bob", []*ChannelKey{keyFor(bobID, 9)}); !errors.Is(err, ErrStaleChannelKeyEpoch) {
+			t.Errorf("expected a second epoch 1 to fail, got %v", err)
+		}
+		if err := store.SaveChannelKeys(secret, 2, "alice", []*ChannelKey{keyFor(aliceID, 2), keyFor(bobID, 3)}); err != nil {
+			t.Fatalf("SaveChannelKeys: %v", err)
+		}
+		if epoch, _ := store.GetChannelKeyEpoch(secret); epoch != 2 {
+			t.Errorf("expected epoch 2, got %d", epoch)
+		}
+
+		aliceKeys, err := store.ListChannelKeysForUser(secret, aliceID)
+		if err != nil {
+			t.Fatalf("ListChannelKeysForUser: %v", err)
+		}
+		if len(aliceKeys) != 2 || aliceKeys[0].Epoch != 1 || aliceKeys[1].Epoch != 2 {
+			t.Fatalf("expected alice's keys for epochs 1 and 2, got %+v", aliceKeys)
+		}
+		if aliceKeys[1].WrappedKey[0] != 2 || aliceKeys[1].CreatedBy != "alice" {
+			t.Errorf("unexpected key %+v", aliceKeys[1])
+		}
+		if bobKeys, _ := store.ListChannelKeysForUser(secret, bobID); len(bobKeys) != 1 || bobKeys[0].Epoch != 2 {
+			t.Errorf("expected bob to only have the epoch 2 key, got %+v", bobKeys)
+		}
+
+		recipients, err := store.ListChannelKeyRecipients(secret, 2)
+		if err != nil {
+			t.Fatalf("ListChannelKeyRecipients: %v", err)
+		}
+		if len(recipients) != 2 || recipients[0].UserID != aliceID || recipients[1].UserID != bobID || recipients[1].PublicKey[0] != 3 {
+			t.Errorf("unexpected recipients %+v", recipients)
+		}
+
+		// Keys go with the channel
+		if err := store.DeleteChannel(uint64(secret)); err != nil {
+			t.Fatalf("DeleteChannel: %v", err)
+		}
+		if keys, _ := store.ListChannelKeysForUser(secret, aliceID); len(keys) != 0 {
+			t.Errorf("expected the keys to be deleted with the channel, got %d", len(keys))
+		}
+	})
+}
+
+func TestStoreDMRatchet(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bobID, err := store.CreateUser("bob", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		if supported, err := store.GetUserRatchetSupport(aliceID); err != nil || supported {
+			t.Fatalf("expected no ratchet support by default, got %v (%v)", supported, err)
+		}
+		if err := store.SetUserRatchetSupport(aliceID, true); err != nil {
+			t.Fatalf("SetUserRatchetSupport: %v", err)
+		}
+		if supported, _ := store.GetUserRatchetSupport(aliceID); !supported {
+			t.Error("expected alice to support the ratchet")
+		}
+		if err := store.SetUserRatchetSupport(aliceID, false); err != nil {
+			t.Fatalf("SetUserRatchetSupport: %v", err)
+		}
+		if supported, _ := store.GetUserRatchetSupport(aliceID); supported {
+			t.Error("expected an older client to turn ratchet support off")
+		}
+
+		dmID, err := store.CreateDMChannel(aliceID, bobID, true)
+		if err != nil {
+			t.Fatalf("CreateDMChannel: %v", err)
+		}
+		if ratchet, err := store.IsDMRatchet(dmID); err != nil || ratchet {
+			t.Fatalf("expected a static DM by default, got %v (%v)", ratchet, err)
+		}
+		if err := store.SetDMRatchet(dmID); err != nil {
+			t.Fatalf("SetDMRatchet: %v", err)
+		}
+		if ratchet, _ := store.IsDMRatchet(dmID); !ratchet {
+			t.Error("expected the DM to be ratcheted")
+		}
+
+		// Only DMs can be ratcheted
+		channelID, err := store.CreateChannel("general", "#general", nil, 1, 168, nil)
+		if err != nil {
+			t.Fatalf("CreateChannel: %v", err)
+		}
+		if err := store.SetDMRatchet(channelID); err != nil {
+			t.Fatalf("SetDMRatchet: %v", err)
+		}
+		if ratchet, _ := store.IsDMRatchet(channelID); ratchet {
+			t.Error("expected a regular channel not to be ratcheted")
+		}
+	})
+}
+
+func TestStoreKeyBackup(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		userID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		if backup, updatedAt, err := store.GetKeyBackup(userID); err != nil || backup != nil || updatedAt != 0 {
+			t.Fatalf("expected no backup, got %q at %d (%v)", backup, updatedAt, err)
+		}
+
+		if _, err := store.SetKeyBackup(userID, []byte("first")); err != nil {
+			t.Fatalf("SetKeyBackup: %v", err)
+		}
+		storedAt, err := store.SetKeyBackup(userID, []byte("second"))
+		if err != nil {
+			t.Fatalf("SetKeyBackup: %v", err)
+		}
+		backup, updatedAt, err := store.GetKeyBackup(userID)
+		if err != nil || string(backup) != "second" || updatedAt != storedAt {
+			t.Fatalf("expected the newest backup at %d, got %q at %d (%v)", storedAt, backup, updatedAt, err)
+		}
+
+		if err := store.DeleteKeyBackup(userID); err != nil {
+			t.Fatalf("DeleteKeyBackup: %v", err)
+		}
+		if backup, _, _ := store.GetKeyBackup(userID); backup != nil {
+			t.Errorf("expected the backup to be deleted, got %q", backup)
+		}
+	})
+}
+
+func TestStoreUserDevices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bobID, _ := store.CreateUser("bob", "hash", 0)
+		laptopKey := bytes.Repeat([]byte{1}, 32)
+		phoneKey := bytes.Repeat([]byte{2}, 32)
+
+		laptop, created, err := store.RegisterUserDevice(aliceID, laptopKey, "laptop", true)
+		if err != nil || !created || laptop.Label != "laptop" || !laptop.MultiDevice {
+			t.Fatalf("RegisterUserDevice = %+v, %v, %v", laptop, created, err)
+		}
+		// Providing the same key again updates the device instead of adding one
+		again, created, err := store.RegisterUserDevice(aliceID, laptopKey, "", false)
+		if err != nil || created || again.ID != laptop.ID || again.Label != "laptop" || again.MultiDevice {
+			t.Fatalf("RegisterUserDevice(again) = %+v, %v, %v", again, created, err)
+		}
+		phone, _, err := store.RegisterUserDevice(aliceID, phoneKey, "phone", true)
+		if err != nil {
+			t.Fatalf("RegisterUserDevice: %v", err)
+		}
+		// Devices belong to one user
+		if _, created, _ := store.RegisterUserDevice(bobID, laptopKey, "", true); !created {
+			t.Error("expected bob's device to be separate")
+		}
+
+		devices, err := store.ListUserDevices(aliceID)
+		if err != nil || len(devices) != 2 || devices[0].ID != laptop.ID || devices[1].ID != phone.ID {
+			t.Fatalf("ListUserDevices = %v, %v", devices, err)
+		}
+
+		// Only the owner can revoke, and only once
+		if revoked, _ := store.RevokeUserDevice(bobID, phone.ID); revoked {
+			t.Error("expected bob not to revoke alice's device")
+		}
+		if revoked, err := store.RevokeUserDevice(aliceID, phone.ID); err != nil || !revoked {
+			t.Fatalf("RevokeUserDevice = %v, %v", revoked, err)
+		}
+		if revoked, _ := store.RevokeUserDevice(aliceID, phone.ID); revoked {
+			t.Error("expected a revoked device not to be revoked again")
+		}
+		if devices, _ := store.ListUserDevices(aliceID); len(devices) != 1 || devices[0].ID != laptop.ID {
+			t.Errorf("expected only the laptop after revoking, got %v", devices)
+		}
+		if _, _, err := store.RegisterUserDevice(aliceID, phoneKey, "phone", true); !errors.Is(err, ErrDeviceRevoked) {
+			t.Errorf("RegisterUserDevice(revoked) error = %v, want ErrDeviceRevoked", err)
+		}
+
+		dmID, err := store.CreateDMChannel(aliceID, bobID, true)
+		if err != nil {
+			t.Fatalf("CreateDMChannel: %v", err)
+		}
+		if multiDevice, err := store.IsDMMultiDevice(dmID); err != nil || multiDevice {
+			t.Fatalf("expected a new DM not to be multi-device, got %v (%v)", multiDevice, err)
+		}
+		if err := store.SetDMMultiDevice(dmID); err != nil {
+			t.Fatalf("SetDMMultiDevice: %v", err)
+		}
+		if multiDevice, _ := store.IsDMMultiDevice(dmID); !multiDevice {
+			t.Error("expected the DM to be multi-device")
+		}
+	})
+}
+
+func TestStoreResumeTokens(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bobID, _ := store.CreateUser("bob", "hash", 0)
+		laptopHash := bytes.Repeat([]byte{1}, 32)
+		phoneHash := bytes.Repeat([]byte{2}, 32)
+
+		laptopID, err := store.CreateResumeToken(aliceID, laptopHash, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("CreateResumeToken: %v", err)
+		}
+		phoneID, _ := store.CreateResumeToken(aliceID, phoneHash, "192.0.2.2")
+		if _, err := store.CreateResumeToken(bobID, laptopHash, ""); err == nil {
+			t.Error("expected token hashes to be unique")
+		}
+
+		token, err := store.GetResumeToken(laptopHash)
+		if err != nil || token.ID != laptopID || token.UserID != aliceID || token.Label != "192.0.2.1" {
+			t.Fatalf("GetResumeToken = %+v, %v", token, err)
+		}
+		if _, err := store.GetResumeToken(bytes.Repeat([]byte{3}, 32)); err == nil {
+			t.Error("expected an unknown token not to be found")
+		}
+
+		// The most recently used token comes first
+		time.Sleep(2 * time.Millisecond)
+		if err := store.TouchResumeToken(laptopID); err != nil {
+			t.Fatalf("TouchResumeToken: %v", err)
+		}
+		tokens, err := store.ListResumeTokens(aliceID)
+		if err != nil || len(tokens) != 2 || tokens[0].ID != laptopID || tokens[1].ID != phoneID {
+			t.Fatalf("ListResumeTokens = %v, %v", tokens, err)
+		}
+
+		// Only the owner can delete a token
+		if deleted, _ := store.DeleteResumeToken(bobID, phoneID); deleted {
+			t.Error("expected bob not to delete alice's token")
+		}
+		if deleted, err := store.DeleteResumeToken(aliceID, phoneID); err != nil || !deleted {
+			t.Fatalf("DeleteResumeToken = %v, %v", deleted, err)
+		}
+		if deleted, _ := store.DeleteResumeToken(aliceID, phoneID); deleted {
+			t.Error("expected a deleted token not to be deleted again")
+		}
+
+		// All other tokens go when the password changes
+		phoneID, _ = store.CreateResumeToken(aliceID, phoneHash, "")
+		if count, err := store.DeleteUserResumeTokens(aliceID, laptopID); err != nil || count != 1 {
+			t.Fatalf("DeleteUserResumeTokens = %d, %v", count, err)
+		}
+		if tokens, _ := store.ListResumeTokens(aliceID); len(tokens) != 1 || tokens[0].ID != laptopID {
+			t.Errorf("expected only the laptop token, got %v", tokens)
+		}
+
+		// Unused tokens expire
+		if count, err := store.CleanupExpiredResumeTokens(time.Now().Add(time.Hour).UnixMilli()); err != nil || count != 1 {
+			t.Fatalf("CleanupExpiredResumeTokens = %d, %v", count, err)
+		}
+		if _, err := store.GetResumeToken(laptopHash); err == nil {
+			t.Error("expected the expired token to be gone")
+		}
+	})
+}
+
+func TestStoreTOTP(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		aliceID, err := store.CreateUser("alice", "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if totp, err := store.GetTOTP(aliceID); err != nil || totp != nil {
+			t.Fatalf("expected no authenticator, got %+v, %v", totp, err)
+		}
+
+		secret := bytes.Repeat([]byte{7}, 20)
+		codes := [][]byte{bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)}
+		if err := store.EnableTOTP(aliceID, secret, codes); err != nil {
+			t.Fatalf("EnableTOTP: %v", err)
+		}
+		totp, err := store.GetTOTP(aliceID)
+		if err != nil || totp == nil || !bytes.Equal(totp.Secret, secret) || totp.LastStep != 0 {
+			t.Fatalf("GetTOTP = %+v, %v", totp, err)
+		}
+
+		// A time step is only accepted once, and never an earlier one
+		if used, err := store.UseTOTPStep(aliceID, 100); err != nil || !used {
+			t.Fatalf("UseTOTPStep = %v, %v", used, err)
+		}
+		if used, _ := store.UseTOTPStep(aliceID, 100); used {
+			t.Error("expected a replayed step to be refused")
+		}
+		if used, _ := store.UseTOTPStep(aliceID, 99); used {
+			t.Error("expected an earlier step to be refused")
+		}
+
+		// Recovery codes work once
+		if used, err := store.UseTOTPRecoveryCode(aliceID, codes[0]); err != nil || !used {
+			t.Fatalf("UseTOTPRecoveryCode = %v, %v", used, err)
+		}
+		if used, _ := store.UseTOTPRecoveryCode(aliceID, codes[0]); used {
+			t.Error("expected a used recovery code to be refused")
+		}
+		if count, err := store.CountTOTPRecoveryCodes(aliceID); err != nil || count != 1 {
+			t.Errorf("CountTOTPRecoveryCodes = %d, %v", count, err)
+		}
+
+		// Enrolling again replaces the secret and the recovery codes
+		if err := store.EnableTOTP(aliceID, secret, codes[:1]); err != nil {
+			t.Fatalf("EnableTOTP: %v", err)
+		}
+		if totp, _ := store.GetTOTP(aliceID); totp.LastStep != 0 {
+			t.Errorf("expected the used steps to be reset, got %d", totp.LastStep)
+		}
+		if used, _ := store.UseTOTPRecoveryCode(aliceID, codes[1]); used {
+			t.Error("expected the old recovery codes to be gone")
+		}
+
+		if err := store.DisableTOTP(aliceID); err != nil {
+			t.Fatalf("DisableTOTP: %v", err)
+		}
+		if totp, _ := store.GetTOTP(aliceID); totp != nil {
+			t.Error("expected the authenticator to be gone")
+		}
+		if count, _ := store.CountTOTPRecoveryCodes(aliceID); count != 0 {
+			t.Errorf("expected the recovery codes to be gone, got %d", count)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TOTP two-factor authentication (V4). A user who logs in with a password can
+// enroll an RFC 6238 authenticator, after which a password login also needs a
+// current code or one of the recovery codes handed out at enrollment. Only
+// the SHA-256 of each recovery code is stored.
+
+// UserTOTP is a user's enrolled authenticator
+type UserTOTP struct {
+	UserID    int64
+	Secret    []byte // Shared HMAC-SHA1 secret
+	LastStep  int64  // Last accepted time step
+	EnabledAt int64  // Unix timestamp in milliseconds
+}
+
+// EnableTOTP enrolls a user's authenticator with a fresh set of recovery
+// codes, replacing any earlier enrollment
+func (db *DB) EnableTOTP(userID int64, secret []byte, recoveryCodeHashes [][]byte) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO UserTOTP (user_id, secret, last_step, enabled_at) VALUES (?, ?, 0, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_step = 0, enabled_at = excluded.enabled_at
+	`, userID, secret, nowMillis()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM TOTPRecoveryCode WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO TOTPRecoveryCode (user_id, code_hash) VALUES (?, ?)
+		`, userID, codeHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTOTP returns a user's enrolled authenticator, or nil if they have none
+func (db *DB) GetTOTP(userID int64) (*UserTOTP, error) {
+	totp := &UserTOTP{}
+	err := db.conn.QueryRow(`
+		SELECT user_id, secret, last_step, enabled_at FROM UserTOTP WHERE user_id = ?
+	`, userID).Scan(&totp.UserID, &totp.Secret, &totp.LastStep, &totp.EnabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return totp, nil
+}
+
+// DisableTOTP removes a user's authenticator and recovery codes
+func (db *DB) DisableTOTP(userID int64) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM TOTPRecoveryCode WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM UserTOTP WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records that a code for a time step was accepted. Returns
+// false if that step or a later one was already used, so a code can't be
+// replayed.
+func (db *DB) UseTOTPStep(userID, step int64) (bool, error) {
+	result, err := db.writeConn.Exec(`
+		UPDATE UserTOTP SET last_step = ? WHERE user_id = ? AND last_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// UseTOTPRecoveryCode deletes one of a user's recovery codes. Returns false
+// if the user has no such code.
+func (db *DB) UseTOTPRecoveryCode(userID int64, codeHash []byte) (bool, error) {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM TOTPRecoveryCode WHERE user_id = ? AND code_hash = ?
+	`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// CountTOTPRecoveryCodes returns how many unused recovery codes a user has
+func (db *DB) CountTOTPRecoveryCodes(userID int64) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM TOTPRecoveryCode WHERE user_id = ?
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// Authenticators are only read when users log in or change their 2FA
+// settings, so MemDB doesn't cache them.
+
+func (m *MemDB) EnableTOTP(userID int64, secret []byte, recoveryCodeHashes [][]byte) error {
+	return m.sqliteDB.EnableTOTP(userID, secret, recoveryCodeHashes)
+}
+
+func (m *MemDB) GetTOTP(userID int64) (*UserTOTP, error) {
+	return m.sqliteDB.GetTOTP(userID)
+}
+
+func (m *MemDB) DisableTOTP(userID int64) error {
+	return m.sqliteDB.DisableTOTP(userID)
+}
+
+func (m *MemDB) UseTOTPStep(userID, step int64) (bool, error) {
+	return m.sqliteDB.UseTOTPStep(userID, step)
+}
+
+func (m *MemDB) UseTOTPRecoveryCode(userID int64, codeHash []byte) (bool, error) {
+	return m.sqliteDB.UseTOTPRecoveryCode(userID, codeHash)
+}
+
+func (m *MemDB) CountTOTPRecoveryCodes(userID int64) (int, error) {
+	return m.sqliteDB.CountTOTPRecoveryCodes(userID)
+}
+
+// EnableTOTP enrolls a user's authenticator with a fresh set of recovery
+// codes, replacing any earlier enrollment
+func (db *PostgresDB) EnableTOTP(userID int64, secret []byte, recoveryCodeHashes [][]byte) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO UserTOTP (user_id, secret, last_step, enabled_at) VALUES ($1, $2, 0, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, enabled_at = EXCLUDED.enabled_at
+	`, userID, secret, nowMillis()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM TOTPRecoveryCode WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO TOTPRecoveryCode (user_id, code_hash) VALUES ($1, $2)
+		`, userID, codeHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTOTP returns a user's enrolled authenticator, or nil if they have none
+func (db *PostgresDB) GetTOTP(userID int64) (*UserTOTP, error) {
+	totp := &UserTOTP{}
+	err := db.conn.QueryRow(`
+		SELECT user_id, secret, last_step, enabled_at FROM UserTOTP WHERE user_id = $1
+	`, userID).Scan(&totp.UserID, &totp.Secret, &totp.LastStep, &totp.EnabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return totp, nil
+}
+
+// DisableTOTP removes a user's authenticator and recovery codes
+func (db *PostgresDB) DisableTOTP(userID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM TOTPRecoveryCode WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM UserTOTP WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records that a code for a time step was accepted. Returns
+// false if that step or a later one was already used, so a code can't be
+// replayed.
+func (db *PostgresDB) UseTOTPStep(userID, step int64) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE UserTOTP SET last_step = $1 WHERE user_id = $2 AND last_step < $1
+	`, step, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// UseTOTPRecoveryCode deletes one of a user's recovery codes. Returns false
+// if the user has no such code.
+func (db *PostgresDB) UseTOTPRecoveryCode(userID int64, codeHash []byte) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM TOTPRecoveryCode WHERE user_id = $1 AND code_hash = $2`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// CountTOTPRecoveryCodes returns how many unused recovery codes a user has
+func (db *PostgresDB) CountTOTPRecoveryCodes(userID int64) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM TOTPRecoveryCode WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// User devices (V4). Each client a registered user provides an encryption
+// key from is a device, so users can read their DMs on more than one
+// machine. Revoked devices are kept so they can't register again.
+
+// ErrDeviceRevoked is returned when a revoked device provides its key again
+var ErrDeviceRevoked = errors.New("device has been revoked")
+
+// UserDevice is one of a registered user's X25519 encryption keys
+type UserDevice struct {
+	ID          int64
+	UserID      int64
+	PublicKey   []byte
+	Label       string
+	MultiDevice bool  // The device's client supports multi-device DMs
+	CreatedAt   int64 // Unix timestamp in milliseconds
+	LastSeenAt  int64 // Unix timestamp in milliseconds
+}
+
+func scanUserDevice(row rowScanner) (*UserDevice, error) {
+	device := &UserDevice{}
+	if err := row.Scan(&device.ID, &device.UserID, &device.PublicKey, &device.Label, &device.MultiDevice, &device.CreatedAt, &device.LastSeenAt); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// RegisterUserDevice records that a user provided an encryption key. A new
+// key becomes a new device; a known one is marked as seen, and keeps its
+// label unless a new one is given. Returns the device and whether it is new,
+// or ErrDeviceRevoked.
+func (db *DB) RegisterUserDevice(userID int64, publicKey []byte, label string, multiDevice bool) (*UserDevice, bool, error) {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := nowMillis()
+	var id int64
+	var revokedAt sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT id, revoked_at FROM UserDevice WHERE user_id = ? AND public_key = ?
+	`, userID, publicKey).Scan(&id, &revokedAt)
+	created := err == sql.ErrNoRows
+	switch {
+	case created:
+		result, err := tx.Exec(`
+			INSERT INTO UserDevice (user_id, public_key, label, multi_device, created_at, last_seen_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, userID, publicKey, label, multiDevice, now, now)
+		if err != nil {
+			return nil, false, err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return nil, false, err
+		}
+	case err != nil:
+		return nil, false, err
+	case revokedAt.Valid:
+		return nil, false, ErrDeviceRevoked
+	default:
+		if _, err := tx.Exec(`
+			UPDATE UserDevice
+			SET last_seen_at = ?, multi_device = ?, label = CASE WHEN ? = '' THEN label ELSE ? END
+			WHERE id = ?
+		`, now, multiDevice, label, label, id); err != nil {
+			return nil, false, err
+		}
+	}
+
+	device, err := scanUserDevice(tx.QueryRow(`
+		SELECT id, user_id, public_key, label, multi_device, created_at, last_seen_at
+		FROM UserDevice WHERE id = ?
+	`, id))
+	if err != nil {
+		return nil, false, err
+	}
+	return device, created, tx.Commit()
+}
+
+// ListUserDevices returns a user's devices that haven't been revoked, oldest
+// first
+func (db *DB) ListUserDevices(userID int64) ([]*UserDevice, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, user_id, public_key, label, multi_device, created_at, last_seen_at
+		FROM UserDevice
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*UserDevice
+	for rows.Next() {
+		device, err := scanUserDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// RevokeUserDevice revokes one of a user's devices. Returns false if the
+// user has no such active device.
+func (db *DB) RevokeUserDevice(userID, deviceID int64) (bool, error) {
+	result, err := db.writeConn.Exec(`
+		UPDATE UserDevice SET revoked_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, nowMillis(), deviceID, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// SetDMMultiDevice marks a new DM channel as multi-device
+func (db *DB) SetDMMultiDevice(channelID int64) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE Channel SET dm_multi_device = 1 WHERE id = ? AND is_dm = 1
+	`, channelID)
+	return err
+}
+
+// IsDMMultiDevice reports whether a DM channel is multi-device
+func (db *DB) IsDMMultiDevice(channelID int64) (bool, error) {
+	var multiDevice bool
+	err := db.conn.QueryRow(`
+		SELECT dm_multi_device FROM Channel WHERE id = ?
+	`, channelID).Scan(&multiDevice)
+	return multiDevice, err
+}
+
+// Devices are only read when keys are provided, DMs are started and user
+// info is requested, so MemDB doesn't cache them.
+
+func (m *MemDB) RegisterUserDevice(userID int64, publicKey []byte, label string, multiDevice bool) (*UserDevice, bool, error) {
+	return m.sqliteDB.RegisterUserDevice(userID, publicKey, label, multiDevice)
+}
+
+func (m *MemDB) ListUserDevices(userID int64) ([]*UserDevice, error) {
+	return m.sqliteDB.ListUserDevices(userID)
+}
+
+func (m *MemDB) RevokeUserDevice(userID, deviceID int64) (bool, error) {
+	return m.sqliteDB.RevokeUserDevice(userID, deviceID)
+}
+
+func (m *MemDB) SetDMMultiDevice(channelID int64) error {
+	return m.sqliteDB.SetDMMultiDevice(channelID)
+}
+
+func (m *MemDB) IsDMMultiDevice(channelID int64) (bool, error) {
+	return m.sqliteDB.IsDMMultiDevice(channelID)
+}
+
+// RegisterUserDevice records that a user provided an encryption key. A new
+// key becomes a new device; a known one is marked as seen, and keeps its
+// label unless a new one is given. Returns the device and whether it is new,
+// or ErrDeviceRevoked.
+func (db *PostgresDB) RegisterUserDevice(userID int64, publicKey []byte, label string, multiDevice bool) (*UserDevice, bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := nowMillis()
+	var id int64
+	var revokedAt sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT id, revoked_at FROM UserDevice WHERE user_id = $1 AND public_key = $2 FOR UPDATE
+	`, userID, publicKey).Scan(&id, &revokedAt)
+	created := err == sql.ErrNoRows
+	switch {
+	case created:
+		if err := tx.QueryRow(`
+			INSERT INTO UserDevice (user_id, public_key, label, multi_device, created_at, last_seen_at)
+			VALUES ($1, $2, $3, $4, $5, $5)
+			RETURNING id
+		`, userID, publicKey, label, multiDevice, now).Scan(&id); err != nil {
+			return nil, false, err
+		}
+	case err != nil:
+		return nil, false, err
+	case revokedAt.Valid:
+		return nil, false, ErrDeviceRevoked
+	default:
+		if _, err := tx.Exec(`
+			UPDATE UserDevice
+			SET last_seen_at = $1, multi_device = $2, label = CASE WHEN $3 = '' THEN label ELSE $3 END
+			WHERE id = $4
+		`, now, multiDevice, label, id); err != nil {
+			return nil, false, err
+		}
+	}
+
+	device, err := scanUserDevice(tx.QueryRow(`
+		SELECT id, user_id, public_key, label, multi_device, created_at, last_seen_at
+		FROM UserDevice WHERE id = $1
+	`, id))
+	if err != nil {
+		return nil, false, err
+	}
+	return device, created, tx.Commit()
+}
+
+// ListUserDevices returns a user's devices that haven't been revoked, oldest
+// first
+func (db *PostgresDB) ListUserDevices(userID int64) ([]*UserDevice, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, user_id, public_key, label, multi_device, created_at, last_seen_at
+		FROM UserDevice
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*UserDevice
+	for rows.Next() {
+		device, err := scanUserDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// RevokeUserDevice revokes one of a user's devices. Returns false if the
+// user has no such active device.
+func (db *PostgresDB) RevokeUserDevice(userID, deviceID int64) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE UserDevice SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`, nowMillis(), deviceID, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// SetDMMultiDevice marks a new DM channel as multi-device
+func (db *PostgresDB) SetDMMultiDevice(channelID int64) error {
+	_, err := db.conn.Exec(`UPDATE Channel SET dm_multi_device = TRUE WHERE id = $1 AND is_dm = TRUE`, channelID)
+	return err
+}
+
+// IsDMMultiDevice reports whether a DM channel is multi-device
+func (db *PostgresDB) IsDMMultiDevice(channelID int64) (bool, error) {
+	var multiDevice bool
+	err := db.conn.QueryRow(`SELECT dm_multi_device FROM Channel WHERE id = $1`, channelID).Scan(&multiDevice)
+	return multiDevice, err
+}
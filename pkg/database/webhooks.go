@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// WebhookDelivery is an outgoing webhook request waiting to be sent
+type WebhookDelivery struct {
+	ID            int64
+	WebhookURL    string
+	Event         string
+	Payload       string // JSON request body
+	Attempts      int
+	NextAttemptAt int64 // Unix timestamp in milliseconds
+	LastError     *string
+	CreatedAt     int64 // Unix timestamp in milliseconds
+}
+
+// EnqueueWebhookDelivery queues a delivery and sets its ID
+func (db *DB) EnqueueWebhookDelivery(d *WebhookDelivery) error {
+	result, err := db.writeConn.Exec(`
+		INSERT INTO WebhookDelivery (webhook_url, event, payload, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, d.WebhookURL, d.Event, d.Payload, d.Attempts, d.NextAttemptAt, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+	d.ID, err = result.LastInsertId()
+	return err
+}
+
+// ListDueWebhookDeliveries returns queued deliveries whose next attempt is at
+// or before now, oldest first
+func (db *DB) ListDueWebhookDeliveries(now int64, limit int) ([]*WebhookDelivery, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, webhook_url, event, payload, attempts, next_attempt_at, last_error, created_at
+		FROM WebhookDelivery
+		WHERE next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+	return scanWebhookDeliveries(rows)
+}
+
+// RescheduleWebhookDelivery records a failed attempt and when to try again
+func (db *DB) RescheduleWebhookDelivery(id int64, attempts int, nextAttemptAt int64, lastError string) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE WebhookDelivery SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?
+	`, attempts, nextAttemptAt, lastError, id)
+	return err
+}
+
+// DeleteWebhookDelivery removes a delivery from the queue
+func (db *DB) DeleteWebhookDelivery(id int64) error {
+	_, err := db.writeConn.Exec(`DELETE FROM WebhookDelivery WHERE id = ?`, id)
+	return err
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		if err := rows.Scan(&d.ID, &d.WebhookURL, &d.Event, &d.Payload, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// The webhook queue isn't cached: MemDB writes it straight to SQLite so
+// queued events survive a crash between snapshots.
+
+func (m *MemDB) EnqueueWebhookDelivery(d *WebhookDelivery) error {
+	return m.sqliteDB.EnqueueWebhookDelivery(d)
+}
+
+func (m *MemDB) ListDueWebhookDeliveries(now int64, limit int) ([]*WebhookDelivery, error) {
+	return m.sqliteDB.ListDueWebhookDeliveries(now, limit)
+}
+
+func (m *MemDB) RescheduleWebhookDelivery(id int64, attempts int, nextAttemptAt int64, lastError string) error {
+	return m.sqliteDB.RescheduleWebhookDelivery(id, attempts, nextAttemptAt, lastError)
+}
+
+func (m *MemDB) DeleteWebhookDelivery(id int64) error {
+	return m.sqliteDB.DeleteWebhookDelivery(id)
+}
+
+// EnqueueWebhookDelivery queues a delivery and sets its ID
+func (db *PostgresDB) EnqueueWebhookDelivery(d *WebhookDelivery) error {
+	err := db.conn.QueryRow(`
+		INSERT INTO WebhookDelivery (webhook_url, event, payload, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, d.WebhookURL, d.Event, d.Payload, d.Attempts, d.NextAttemptAt, d.CreatedAt).Scan(&d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDueWebhookDeliveries returns queued deliveries whose next attempt is at
+// or before now, oldest first
+func (db *PostgresDB) ListDueWebhookDeliveries(now int64, limit int) ([]*WebhookDelivery, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, webhook_url, event, payload, attempts, next_attempt_at, last_error, created_at
+		FROM WebhookDelivery
+		WHERE next_attempt_at <= $1
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+	return scanWebhookDeliveries(rows)
+}
+
+// RescheduleWebhookDelivery records a failed attempt and when to try again
+func (db *PostgresDB) RescheduleWebhookDelivery(id int64, attempts int, nextAttemptAt int64, lastError string) error {
+	_, err := db.conn.Exec(`
+		UPDATE WebhookDelivery SET attempts = $1, next_attempt_at = $2, last_error = $3 WHERE id = $4
+	`, attempts, nextAttemptAt, lastError, id)
+	return err
+}
+
+// DeleteWebhookDelivery removes a delivery from the queue
+func (db *PostgresDB) DeleteWebhookDelivery(id int64) error {
+	_, err := db.conn.Exec(`DELETE FROM WebhookDelivery WHERE id = $1`, id)
+	return err
+}
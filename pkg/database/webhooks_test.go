@@ -0,0 +1,89 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliveryQueue(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		now := nowMillis()
+		due := &WebhookDelivery{WebhookURL: "http://hooks.example/a", Event: "message.created", Payload: `{"n":1}`, NextAttemptAt: now, CreatedAt: now}
+		later := &WebhookDelivery{WebhookURL: "http://hooks.example/b", Event: "channel.created", Payload: `{"n":2}`, NextAttemptAt: now + 60000, CreatedAt: now}
+		for _, d := range []*WebhookDelivery{due, later} {
+			if err := store.EnqueueWebhookDelivery(d); err != nil {
+				t.Fatalf("EnqueueWebhookDelivery: %v", err)
+			}
+			if d.ID == 0 {
+				t.Fatal("EnqueueWebhookDelivery did not set ID")
+			}
+		}
+
+		deliveries, err := store.ListDueWebhookDeliveries(now, 10)
+		if err != nil {
+			t.Fatalf("ListDueWebhookDeliveries: %v", err)
+		}
+		if len(deliveries) != 1 || deliveries[0].ID != due.ID || deliveries[0].Payload != `{"n":1}` || deliveries[0].LastError != nil {
+			t.Fatalf("due deliveries = %+v, want only %d", deliveries, due.ID)
+		}
+
+		if err := store.RescheduleWebhookDelivery(due.ID, 1, now+120000, "HTTP 500"); err != nil {
+			t.Fatalf("RescheduleWebhookDelivery: %v", err)
+		}
+		deliveries, err = store.ListDueWebhookDeliveries(now+120000, 10)
+		if err != nil {
+			t.Fatalf("ListDueWebhookDeliveries: %v", err)
+		}
+		if len(deliveries) != 2 || deliveries[0].ID != later.ID || deliveries[1].ID != due.ID {
+			t.Fatalf("due deliveries = %+v, want [%d %d]", deliveries, later.ID, due.ID)
+		}
+		if deliveries[1].Attempts != 1 || deliveries[1].LastError == nil || *deliveries[1].LastError != "HTTP 500" {
+			t.Errorf("rescheduled delivery = %+v", deliveries[1])
+		}
+
+		if err := store.DeleteWebhookDelivery(later.ID); err != nil {
+			t.Fatalf("DeleteWebhookDelivery: %v", err)
+		}
+		deliveries, err = store.ListDueWebhookDeliveries(now+120000, 10)
+		if err != nil {
+			t.Fatalf("ListDueWebhookDeliveries: %v", err)
+		}
+		if len(deliveries) != 1 || deliveries[0].ID != due.ID {
+			t.Errorf("after delete = %+v, want only %d", deliveries, due.ID)
+		}
+	})
+}
+
+func TestWebhookQueueSurvivesRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("NewMemDB: %v", err)
+	}
+
+	now := nowMillis()
+	if err := memDB.EnqueueWebhookDelivery(&WebhookDelivery{WebhookURL: "http://hooks.example", Event: "user.registered", Payload: "{}", NextAttemptAt: now, CreatedAt: now}); err != nil {
+		t.Fatalf("EnqueueWebhookDelivery: %v", err)
+	}
+	memDB.Close()
+	db.Close()
+
+	db, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+
+	deliveries, err := db.ListDueWebhookDeliveries(now, 10)
+	if err != nil {
+		t.Fatalf("ListDueWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != "user.registered" {
+		t.Errorf("deliveries after restart = %+v", deliveries)
+	}
+}
@@ -0,0 +1,55 @@
+package protocol
+
+import "strings"
+
+// ChannelModes is a bitfield of IRC-style channel modes (V4).
+// Stored as uint8 with 4 bits currently used. Subchannels follow the modes
+// of their parent channel.
+type ChannelModes uint8
+
+const (
+	// ChannelModeModerated lets only voiced users and channel staff post (+m)
+	ChannelModeModerated ChannelModes = 1 << 0 // 0x01
+
+	// ChannelModeReadOnly lets only channel staff post, for announcements (+a)
+	ChannelModeReadOnly ChannelModes = 1 << 1 // 0x02
+
+	// ChannelModeRegisteredOnly keeps anonymous sessions out (+R)
+	ChannelModeRegisteredOnly ChannelModes = 1 << 2 // 0x04
+
+	// ChannelModeInviteOnly hides the channel from everyone who isn't invited (+i)
+	ChannelModeInviteOnly ChannelModes = 1 << 3 // 0x08
+
+	// ChannelModesAll is every mode this version knows about
+	ChannelModesAll = ChannelModeModerated | ChannelModeReadOnly | ChannelModeRegisteredOnly | ChannelModeInviteOnly
+)
+
+// channelModeLetters are the IRC-style letters for each mode, in display order
+var channelModeLetters = []struct {
+	mode   ChannelModes
+	letter byte
+}{
+	{ChannelModeInviteOnly, 'i'},
+	{ChannelModeModerated, 'm'},
+	{ChannelModeReadOnly, 'a'},
+	{ChannelModeRegisteredOnly, 'R'},
+}
+
+// Has returns true if every mode in mode is set
+func (m ChannelModes) Has(mode ChannelModes) bool {
+	return m&mode == mode
+}
+
+// String returns the modes as IRC-style letters (e.g. "+im"), or "" if none are set
+func (m ChannelModes) String() string {
+	var b strings.Builder
+	for _, l := range channelModeLetters {
+		if m.Has(l.mode) {
+			b.WriteByte(l.letter)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "+" + b.String()
+}
@@ -0,0 +1,39 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelModes_Has(t *testing.T) {
+	modes := ChannelModeModerated | ChannelModeInviteOnly
+
+	assert.True(t, modes.Has(ChannelModeModerated))
+	assert.True(t, modes.Has(ChannelModeInviteOnly))
+	assert.True(t, modes.Has(ChannelModeModerated|ChannelModeInviteOnly))
+	assert.False(t, modes.Has(ChannelModeReadOnly))
+	assert.False(t, modes.Has(ChannelModeModerated|ChannelModeReadOnly))
+}
+
+func TestChannelModes_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		modes ChannelModes
+		want  string
+	}{
+		{"no modes", ChannelModes(0), ""},
+		{"moderated", ChannelModeModerated, "+m"},
+		{"read-only", ChannelModeReadOnly, "+a"},
+		{"registered-only", ChannelModeRegisteredOnly, "+R"},
+		{"invite-only and moderated", ChannelModeModerated | ChannelModeInviteOnly, "+im"},
+		{"all modes", ChannelModesAll, "+imaR"},
+		{"unknown bits are ignored", ChannelModes(0x80), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.modes.String())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package protocol
+
+import "strings"
+
+// Nickname length limits for @mentions (same as SET_NICKNAME)
+const (
+	minMentionLength = 3
+	maxMentionLength = 20
+)
+
+// isNicknameByte reports whether b can appear in a nickname
+func isNicknameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_' || b == '-'
+}
+
+// ParseMentions returns the nicknames mentioned as @nickname in content, in
+// order of first appearance. Duplicates are dropped case-insensitively.
+// An @ preceded by a nickname character (as in an email address) is not a mention.
+func ParseMentions(content string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(content); i++ {
+		if content[i] != '@' || (i > 0 && isNicknameByte(content[i-1])) {
+			continue
+		}
+		end := i + 1
+		for end < len(content) && isNicknameByte(content[end]) {
+			end++
+		}
+		nickname := content[i+1 : end]
+		i = end - 1
+		if len(nickname) < minMentionLength || len(nickname) > maxMentionLength {
+			continue
+		}
+		key := strings.ToLower(nickname)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		mentions = append(mentions, nickname)
+	}
+	return mentions
+}
+
+// MentionsNickname reports whether content mentions nickname as @nickname
+// (case-insensitive)
+func MentionsNickname(content, nickname string) bool {
+	for _, mention := range ParseMentions(content) {
+		if strings.EqualFold(mention, nickname) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"single mention", "hey @alice, look", []string{"alice"}},
+		{"start of message", "@bob_2 ping", []string{"bob_2"}},
+		{"multiple and duplicates", "@alice @Bob @ALICE thoughts?", []string{"alice", "Bob"}},
+		{"email address", "mail alice@example.com", nil},
+		{"too short", "@al is not a nickname", nil},
+		{"too long", "@abcdefghijklmnopqrstuvwxyz", nil},
+		{"punctuation after", "thanks @carol-x!", []string{"carol-x"}},
+		{"bare at sign", "meet @ noon", nil},
+		{"no mentions", "plain text", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseMentions(tt.content))
+		})
+	}
+}
+
+func TestMentionsNickname(t *testing.T) {
+	assert.True(t, MentionsNickname("hi @Alice", "alice"))
+	assert.False(t, MentionsNickname("hi @alicebob", "alice"))
+	assert.False(t, MentionsNickname("hi alice", "alice"))
+}
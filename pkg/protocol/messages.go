@@ -49,6 +49,11 @@ const (
 	TypeAllowUnencrypted   = 0x1B // V3: Allow unencrypted DM
 	TypeUpdateReadState    = 0x1D
 	TypeDeclineDM          = 0x1E // V3: Decline incoming DM request
+	TypeSearchMessages     = 0x20 // V4: Full-text message search
+	TypePlusOne            = 0x21 // V4: +1 a message
+	TypeListMentions       = 0x22 // V4: List messages mentioning you
+	TypeGetMessageHistory  = 0x23 // V4: Edit history of a message
+	TypeUpdateChannel      = 0x24 // V4: Change channel settings
 	TypeSubscribeThread    = 0x51
 	TypeUnsubscribeThread  = 0x52
 	TypeSubscribeChannel   = 0x53
@@ -66,6 +71,33 @@ const (
 	TypeListBans      = 0x5D
 	TypeDeleteUser    = 0x5E
 	TypeDeleteChannel = 0x5F
+
+	TypeCreateIncomingWebhook = 0x60 // V4: Create an incoming webhook token
+	TypeListIncomingWebhooks  = 0x61 // V4: List incoming webhooks
+	TypeRevokeIncomingWebhook = 0x62 // V4: Revoke an incoming webhook token
+	TypeListAdminActions      = 0x63 // V4: Query the admin audit log
+	TypeKickUser              = 0x64 // V4: Disconnect all of a user's sessions
+	TypeMuteUser              = 0x65 // V4: Stop a user posting for a while
+	TypeUnmuteUser            = 0x66 // V4: Lift a mute
+	TypeSetChannelRole        = 0x67 // V4: Make a user a channel owner, moderator or member
+	TypeListChannelRoles      = 0x68 // V4: List a channel's owners and moderators
+	TypeSetChannelGrant       = 0x69 // V4: Invite or voice a user in a channel
+	TypeInviteToChannel       = 0x25 // V4: Add a user to a private channel
+	TypeRemoveFromChannel     = 0x26 // V4: Remove a user from a private channel
+	TypeLeavePrivateChannel   = 0x27 // V4: Leave a private channel
+	TypeListPrivateChannels   = 0x28 // V4: List your private channels
+	TypeDistributeChannelKey  = 0x29 // V4: Hand out a new private channel key
+	TypeGetChannelKeys        = 0x2A // V4: Fetch your private channel keys
+	TypeStoreKeyBackup        = 0x2B // V4: Store your encrypted key backup
+	TypeGetKeyBackup          = 0x2C // V4: Fetch your encrypted key backup
+	TypeRevokeDevice          = 0x2D // V4: Revoke one of your devices
+	TypeResumeSession         = 0x2E // V4: Authenticate with a resume token
+	TypeListSessions          = 0x2F // V4: List your logins
+	TypeRevokeSession         = 0x30 // V4: Log out one of your logins
+	TypeAuthTOTP              = 0x31 // V4: Answer a two-factor challenge
+	TypeSetupTOTP             = 0x32 // V4: Start enrolling an authenticator
+	TypeConfirmTOTP           = 0x33 // V4: Finish enrolling an authenticator
+	TypeDisableTOTP           = 0x34 // V4: Remove your authenticator
 )
 
 // Message type constants (Server → Client)
@@ -112,6 +144,12 @@ const (
 	TypeServerPresence      = 0xAD
 	TypeDMParticipantLeft   = 0xAE
 	TypeDMDeclined          = 0xAF
+	TypeSearchResults       = 0xB0 // V4: Response to SEARCH_MESSAGES
+	TypePlusOneUpdate       = 0xB1 // V4: +1 counts changed
+	TypeMentionNotification = 0xB2 // V4: You were mentioned
+	TypeMentionList         = 0xB3 // V4: Response to LIST_MENTIONS
+	TypeMessageHistory      = 0xB7 // V4: Response to GET_MESSAGE_HISTORY
+	TypeChannelUpdated      = 0xB8 // V4: Response to UPDATE_CHANNEL, also broadcast
 
 	// Admin responses (Server → Client)
 	TypeUserBanned = 0x9F
@@ -121,6 +159,42 @@ const (
 	TypeBanList        = 0xA8
 	TypeUserDeleted    = 0xA9
 	TypeChannelDeleted = 0xAA
+
+	TypeIncomingWebhookCreated = 0xB4 // V4: Response to CREATE_INCOMING_WEBHOOK
+	TypeIncomingWebhookList    = 0xB5 // V4: Response to LIST_INCOMING_WEBHOOKS
+	TypeIncomingWebhookRevoked = 0xB6 // V4: Response to REVOKE_INCOMING_WEBHOOK
+	TypeAdminActionList        = 0xB9 // V4: Response to LIST_ADMIN_ACTIONS
+	TypeUserKicked             = 0xBA // V4: Response to KICK_USER
+	TypeUserMuted              = 0xBB // V4: Response to MUTE_USER
+	TypeUserUnmuted            = 0xBC // V4: Response to UNMUTE_USER
+	TypeChannelRoleSet         = 0xBD // V4: Response to SET_CHANNEL_ROLE
+	TypeChannelRoleList        = 0xBE // V4: Response to LIST_CHANNEL_ROLES
+	TypeChannelGrantSet        = 0xBF // V4: Response to SET_CHANNEL_GRANT
+
+	// V4: Private channels
+	TypeChannelMembershipChanged = 0xC0 // Response to INVITE_TO_CHANNEL, REMOVE_FROM_CHANNEL and LEAVE_PRIVATE_CHANNEL
+	TypePrivateChannelList       = 0xC1 // Response to LIST_PRIVATE_CHANNELS
+	TypeChannelInvited           = 0xC2 // You were added to a private channel
+	TypeRemovedFromChannel       = 0xC3 // You are no longer in a private channel
+	TypeChannelKeys              = 0xC4 // Response to GET_CHANNEL_KEYS, also sent when a new key is distributed
+	TypeChannelRekeyRequired     = 0xC5 // Make and distribute a new private channel key
+
+	// V4: Key backups
+	TypeKeyBackup       = 0xC6 // Response to GET_KEY_BACKUP
+	TypeKeyBackupStored = 0xC7 // Response to STORE_KEY_BACKUP
+
+	// V4: Devices
+	TypeDeviceRevoked = 0xC8 // Response to REVOKE_DEVICE
+
+	// V4: Resume tokens
+	TypeResumeToken    = 0xC9 // Sent after AUTH_RESPONSE when a token was requested
+	TypeSessionList    = 0xCA // Response to LIST_SESSIONS
+	TypeSessionRevoked = 0xCB // Response to REVOKE_SESSION
+
+	// V4: Two-factor authentication
+	TypeTOTPSetup    = 0xCC // Response to SETUP_TOTP
+	TypeTOTPEnabled  = 0xCD // Response to CONFIRM_TOTP
+	TypeTOTPDisabled = 0xCE // Response to DISABLE_TOTP
 )
 
 // Error codes
@@ -135,6 +209,12 @@ const (
 
 	// Authorization errors (3xxx)
 	ErrCodePermissionDenied = 3000
+	ErrCodeChannelPrivate   = 3003 // Invite-only channel, not invited
+	ErrCodeChannelArchived  = 3004
+	ErrCodeMuted            = 3005
+	ErrCodeChannelModerated = 3006 // Moderated channel, not voiced
+	ErrCodeChannelReadOnly  = 3007 // Read-only channel, not channel staff
+	ErrCodeRegisteredOnly   = 3008 // Registered-only channel, anonymous session
 
 	// Resource errors (4xxx)
 	ErrCodeNotFound           = 4000
@@ -161,23 +241,28 @@ const (
 )
 
 var (
-	ErrNicknameTooShort = errors.New("nickname must be at least 3 characters")
-	ErrNicknameTooLong  = errors.New("nickname must be at most 20 characters")
-	ErrMessageTooLong   = errors.New("message content exceeds maximum length (4096 bytes)")
-	ErrEmptyContent     = errors.New("message content cannot be empty")
+	ErrNicknameTooShort  = errors.New("nickname must be at least 3 characters")
+	ErrNicknameTooLong   = errors.New("nickname must be at most 20 characters")
+	ErrMessageTooLong    = errors.New("message content exceeds maximum length (4096 bytes)")
+	ErrEmptyContent      = errors.New("message content cannot be empty")
+	ErrKeyBackupTooLarge = errors.New("key backup exceeds maximum size (64 KB)")
 )
 
 // AuthRequestMessage (0x01) - Authenticate with password
 type AuthRequestMessage struct {
-	Nickname string
-	Password string
+	Nickname     string
+	Password     string
+	RequestToken bool // V4: Send a RESUME_TOKEN after a successful login
 }
 
 func (m *AuthRequestMessage) EncodeTo(w io.Writer) error {
 	if err := WriteString(w, m.Nickname); err != nil {
 		return err
 	}
-	return WriteString(w, m.Password)
+	if err := WriteString(w, m.Password); err != nil {
+		return err
+	}
+	return WriteBool(w, m.RequestToken)
 }
 
 func (m *AuthRequestMessage) Encode() ([]byte, error) {
@@ -201,6 +286,13 @@ func (m *AuthRequestMessage) Decode(payload []byte) error {
 
 	m.Nickname = nickname
 	m.Password = password
+
+	// RequestToken is optional for backwards compatibility
+	requestToken, err := ReadBool(buf)
+	if err != nil {
+		requestToken = false
+	}
+	m.RequestToken = requestToken
 	return nil
 }
 
@@ -211,8 +303,17 @@ type AuthResponseMessage struct {
 	Nickname  string // Only present if success=true
 	Message   string
 	UserFlags *UserFlags // Optional: present when Success=true and server includes flags
+	Challenge uint8      // V4: present when Success=false, what the server wants next (AuthChallenge*)
 }
 
+// AUTH_RESPONSE challenges (V4). The password was right, but the login needs
+// another step before it succeeds.
+const (
+	AuthChallengeNone      = 0 // The login failed
+	AuthChallengeTOTP      = 1 // Send a code with AUTH_TOTP
+	AuthChallengeTOTPSetup = 2 // Enroll an authenticator with SETUP_TOTP and CONFIRM_TOTP
+)
+
 func (m *AuthResponseMessage) EncodeTo(w io.Writer) error {
 	if err := WriteBool(w, m.Success); err != nil {
 		return err
@@ -233,6 +334,11 @@ func (m *AuthResponseMessage) EncodeTo(w io.Writer) error {
 			return err
 		}
 	}
+	if !m.Success {
+		if err := WriteUint8(w, m.Challenge); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -253,6 +359,7 @@ func (m *AuthResponseMessage) Decode(payload []byte) error {
 
 	m.Success = success
 	m.UserFlags = nil
+	m.Challenge = AuthChallengeNone
 
 	if success {
 		userID, err := ReadUint64(buf)
@@ -283,6 +390,13 @@ func (m *AuthResponseMessage) Decode(payload []byte) error {
 		m.UserFlags = &f
 	}
 
+	// Challenge (optional, V4) - older servers don't send it
+	if !success {
+		if challenge, err := ReadUint8(buf); err == nil {
+			m.Challenge = challenge
+		}
+	}
+
 	return nil
 }
 
@@ -513,6 +627,9 @@ type Channel struct {
 	RetentionHours  uint32
 	HasSubchannels  bool   // V3: true if channel has subchannels
 	SubchannelCount uint16 // V3: number of subchannels
+	Archived        bool   // V4: channel is read-only
+	// V4: channel modes
+	Modes ChannelModes
 }
 
 // ChannelListMessage (0x84) - List of channels
@@ -556,6 +673,14 @@ func (m *ChannelListMessage) EncodeTo(w io.Writer) error {
 		if err := WriteUint16(w, ch.SubchannelCount); err != nil {
 			return err
 		}
+		// V4: archived flag
+		if err := WriteBool(w, ch.Archived); err != nil {
+			return err
+		}
+		// V4: channel modes
+		if err := WriteUint8(w, uint8(ch.Modes)); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -618,6 +743,16 @@ func (m *ChannelListMessage) Decode(payload []byte) error {
 		if err != nil {
 			return err
 		}
+		// V4: archived flag
+		archived, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		// V4: channel modes
+		modes, err := ReadUint8(buf)
+		if err != nil {
+			return err
+		}
 
 		m.Channels[i] = Channel{
 			ID:              id,
@@ -629,6 +764,8 @@ func (m *ChannelListMessage) Decode(payload []byte) error {
 			RetentionHours:  retention,
 			HasSubchannels:  hasSubchannels,
 			SubchannelCount: subchannelCount,
+			Archived:        archived,
+			Modes:           ChannelModes(modes),
 		}
 	}
 
@@ -836,6 +973,7 @@ type CreateChannelMessage struct {
 	Description    *string
 	ChannelType    uint8  // 1=forum, 2=chat (V2+ only supports forum)
 	RetentionHours uint32 // Message retention in hours
+	Private        bool   // V4: Invite-only channel that only its members can see
 }
 
 func (m *CreateChannelMessage) EncodeTo(w io.Writer) error {
@@ -851,7 +989,10 @@ func (m *CreateChannelMessage) EncodeTo(w io.Writer) error {
 	if err := WriteUint8(w, m.ChannelType); err != nil {
 		return err
 	}
-	return WriteUint32(w, m.RetentionHours)
+	if err := WriteUint32(w, m.RetentionHours); err != nil {
+		return err
+	}
+	return WriteBool(w, m.Private)
 }
 
 func (m *CreateChannelMessage) Encode() ([]byte, error) {
@@ -884,12 +1025,18 @@ func (m *CreateChannelMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
+	// Private is optional for backwards compatibility
+	private, err := ReadBool(buf)
+	if err != nil {
+		private = false
+	}
 
 	m.Name = name
 	m.DisplayName = displayName
 	m.Description = description
 	m.ChannelType = channelType
 	m.RetentionHours = retentionHours
+	m.Private = private
 	return nil
 }
 
@@ -1348,16 +1495,18 @@ func (m *ListMessagesMessage) Decode(payload []byte) error {
 
 // Message represents a single message
 type Message struct {
-	ID             uint64
-	ChannelID      uint64
-	SubchannelID   *uint64
-	ParentID       *uint64
-	AuthorUserID   *uint64
-	AuthorNickname string // Only populated for anonymous users (when AuthorUserID IS NULL)
-	Content        string
-	CreatedAt      time.Time
-	EditedAt       *time.Time
-	ReplyCount     uint32
+	ID               uint64
+	ChannelID        uint64
+	SubchannelID     *uint64
+	ParentID         *uint64
+	AuthorUserID     *uint64
+	AuthorNickname   string // Only populated for anonymous users (when AuthorUserID IS NULL)
+	Content          string
+	CreatedAt        time.Time
+	EditedAt         *time.Time
+	ReplyCount       uint32
+	PlusOneCount     uint32 // V4: +1s from registered users
+	PlusOneAnonCount uint32 // V4: +1s from anonymous users
 }
 
 // MessageListMessage (0x89) - List of messages
@@ -1382,35 +1531,8 @@ func (m *MessageListMessage) EncodeTo(w io.Writer) error {
 		return err
 	}
 
-	for _, msg := range m.Messages {
-		if err := WriteUint64(w, msg.ID); err != nil {
-			return err
-		}
-		if err := WriteUint64(w, msg.ChannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, msg.SubchannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, msg.ParentID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, msg.AuthorUserID); err != nil {
-			return err
-		}
-		if err := WriteString(w, msg.AuthorNickname); err != nil {
-			return err
-		}
-		if err := WriteString(w, msg.Content); err != nil {
-			return err
-		}
-		if err := WriteTimestamp(w, msg.CreatedAt); err != nil {
-			return err
-		}
-		if err := WriteOptionalTimestamp(w, msg.EditedAt); err != nil {
-			return err
-		}
-		if err := WriteUint32(w, msg.ReplyCount); err != nil {
+	for i := range m.Messages {
+		if err := writeMessage(w, &m.Messages[i]); err != nil {
 			return err
 		}
 	}
@@ -1452,59 +1574,11 @@ func (m *MessageListMessage) Decode(payload []byte) error {
 	m.Messages = make([]Message, count)
 
 	for i := uint16(0); i < count; i++ {
-		id, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		chID, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		subID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		parID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		authorID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		authorNick, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		content, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		createdAt, err := ReadTimestamp(buf)
-		if err != nil {
-			return err
-		}
-		editedAt, err := ReadOptionalTimestamp(buf)
+		msg, err := readMessage(buf)
 		if err != nil {
 			return err
 		}
-		replyCount, err := ReadUint32(buf)
-		if err != nil {
-			return err
-		}
-
-		m.Messages[i] = Message{
-			ID:             id,
-			ChannelID:      chID,
-			SubchannelID:   subID,
-			ParentID:       parID,
-			AuthorUserID:   authorID,
-			AuthorNickname: authorNick,
-			Content:        content,
-			CreatedAt:      createdAt,
-			EditedAt:       editedAt,
-			ReplyCount:     replyCount,
-		}
+		m.Messages[i] = msg
 	}
 
 	return nil
@@ -2041,6 +2115,28 @@ func (m *ServerConfigMessage) Decode(payload []byte) error {
 type NewMessageMessage Message
 
 func (m *NewMessageMessage) EncodeTo(w io.Writer) error {
+	return writeMessage(w, (*Message)(m))
+}
+
+func (m *NewMessageMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *NewMessageMessage) Decode(payload []byte) error {
+	msg, err := readMessage(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	*m = NewMessageMessage(msg)
+	return nil
+}
+
+// writeMessage writes a single Message in the MESSAGE_LIST / NEW_MESSAGE format
+func writeMessage(w io.Writer, m *Message) error {
 	if err := WriteUint64(w, m.ID); err != nil {
 		return err
 	}
@@ -2068,73 +2164,80 @@ func (m *NewMessageMessage) EncodeTo(w io.Writer) error {
 	if err := WriteOptionalTimestamp(w, m.EditedAt); err != nil {
 		return err
 	}
-	return WriteUint32(w, m.ReplyCount)
-}
-
-func (m *NewMessageMessage) Encode() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := m.EncodeTo(buf); err != nil {
-		return nil, err
+	if err := WriteUint32(w, m.ReplyCount); err != nil {
+		return err
 	}
-	return buf.Bytes(), nil
+	if err := WriteUint32(w, m.PlusOneCount); err != nil {
+		return err
+	}
+	return WriteUint32(w, m.PlusOneAnonCount)
 }
 
-func (m *NewMessageMessage) Decode(payload []byte) error {
-	buf := bytes.NewReader(payload)
-
-	id, err := ReadUint64(buf)
+// readMessage reads a single Message in the MESSAGE_LIST / NEW_MESSAGE format
+func readMessage(r io.Reader) (Message, error) {
+	id, err := ReadUint64(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	channelID, err := ReadUint64(buf)
+	channelID, err := ReadUint64(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	subchannelID, err := ReadOptionalUint64(buf)
+	subchannelID, err := ReadOptionalUint64(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	parentID, err := ReadOptionalUint64(buf)
+	parentID, err := ReadOptionalUint64(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	authorUserID, err := ReadOptionalUint64(buf)
+	authorUserID, err := ReadOptionalUint64(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	authorNickname, err := ReadString(buf)
+	authorNickname, err := ReadString(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	content, err := ReadString(buf)
+	content, err := ReadString(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	createdAt, err := ReadTimestamp(buf)
+	createdAt, err := ReadTimestamp(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	editedAt, err := ReadOptionalTimestamp(buf)
+	editedAt, err := ReadOptionalTimestamp(r)
 	if err != nil {
-		return err
+		return Message{}, err
 	}
-	replyCount, err := ReadUint32(buf)
+	replyCount, err := ReadUint32(r)
 	if err != nil {
-		return err
+		return Message{}, err
+	}
+	plusOneCount, err := ReadUint32(r)
+	if err != nil {
+		return Message{}, err
+	}
+	plusOneAnonCount, err := ReadUint32(r)
+	if err != nil {
+		return Message{}, err
 	}
 
-	m.ID = id
-	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
-	m.ParentID = parentID
-	m.AuthorUserID = authorUserID
-	m.AuthorNickname = authorNickname
-	m.Content = content
-	m.CreatedAt = createdAt
-	m.EditedAt = editedAt
-	m.ReplyCount = replyCount
-
-	return nil
+	return Message{
+		ID:               id,
+		ChannelID:        channelID,
+		SubchannelID:     subchannelID,
+		ParentID:         parentID,
+		AuthorUserID:     authorUserID,
+		AuthorNickname:   authorNickname,
+		Content:          content,
+		CreatedAt:        createdAt,
+		EditedAt:         editedAt,
+		ReplyCount:       replyCount,
+		PlusOneCount:     plusOneCount,
+		PlusOneAnonCount: plusOneAnonCount,
+	}, nil
 }
 
 // DisconnectMessage (0x11) - Graceful disconnect notification
@@ -2369,6 +2472,15 @@ type UserInfoMessage struct {
 	IsRegistered bool
 	UserID       *uint64 // Only present if IsRegistered = true
 	Online       bool
+	Devices      []DeviceInfo // V4: The user's encryption keys, one per device
+}
+
+// DeviceInfo is one of a registered user's devices and its encryption key
+type DeviceInfo struct {
+	DeviceID   uint64
+	PublicKey  [32]byte // X25519 public key
+	Label      string   // May be empty
+	LastSeenAt int64    // Unix milliseconds the device last provided its key
 }
 
 func (m *UserInfoMessage) EncodeTo(w io.Writer) error {
@@ -2381,7 +2493,27 @@ func (m *UserInfoMessage) EncodeTo(w io.Writer) error {
 	if err := WriteOptionalUint64(w, m.UserID); err != nil {
 		return err
 	}
-	return WriteBool(w, m.Online)
+	if err := WriteBool(w, m.Online); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Devices))); err != nil {
+		return err
+	}
+	for _, device := range m.Devices {
+		if err := WriteUint64(w, device.DeviceID); err != nil {
+			return err
+		}
+		if _, err := w.Write(device.PublicKey[:]); err != nil {
+			return err
+		}
+		if err := WriteString(w, device.Label); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, device.LastSeenAt); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (m *UserInfoMessage) Encode() ([]byte, error) {
@@ -2414,6 +2546,28 @@ func (m *UserInfoMessage) Decode(payload []byte) error {
 	m.IsRegistered = isRegistered
 	m.UserID = userID
 	m.Online = online
+
+	// Devices are optional for backwards compatibility
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return nil
+	}
+	m.Devices = make([]DeviceInfo, count)
+	for i := range m.Devices {
+		device := &m.Devices[i]
+		if device.DeviceID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(buf, device.PublicKey[:]); err != nil {
+			return err
+		}
+		if device.Label, err = ReadString(buf); err != nil {
+			return err
+		}
+		if device.LastSeenAt, err = ReadInt64(buf); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -4731,6 +4885,8 @@ type StartDMMessage struct {
 	TargetUserID     uint64 // Used when TargetType=0 or TargetType=2
 	TargetNickname   string // Used when TargetType=1
 	AllowUnencrypted bool   // If true, allow unencrypted DM
+	Ratchet          bool   // V4: The initiator's client supports ratcheted DMs
+	MultiDevice      bool   // V4: The initiator's client supports multi-device DMs
 }
 
 func (m *StartDMMessage) EncodeTo(w io.Writer) error {
@@ -4747,7 +4903,13 @@ func (m *StartDMMessage) EncodeTo(w io.Writer) error {
 			return err
 		}
 	}
-	return WriteBool(w, m.AllowUnencrypted)
+	if err := WriteBool(w, m.AllowUnencrypted); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.Ratchet); err != nil {
+		return err
+	}
+	return WriteBool(w, m.MultiDevice)
 }
 
 func (m *StartDMMessage) Encode() ([]byte, error) {
@@ -4786,14 +4948,30 @@ func (m *StartDMMessage) Decode(payload []byte) error {
 		return err
 	}
 	m.AllowUnencrypted = allowUnencrypted
+
+	// Ratchet is optional for backwards compatibility
+	ratchet, err := ReadBool(buf)
+	if err != nil {
+		ratchet = false
+	}
+	m.Ratchet = ratchet
+
+	// MultiDevice is optional for backwards compatibility
+	multiDevice, err := ReadBool(buf)
+	if err != nil {
+		multiDevice = false
+	}
+	m.MultiDevice = multiDevice
 	return nil
 }
 
 // ProvidePublicKeyMessage (0x1A) - Upload X25519 public key for encryption
 type ProvidePublicKeyMessage struct {
-	KeyType   uint8    // 0=derived, 1=generated, 2=ephemeral
-	PublicKey [32]byte // X25519 public key (32 bytes)
-	Label     string   // Optional label (e.g., "laptop", "phone")
+	KeyType     uint8    // 0=derived, 1=generated, 2=ephemeral
+	PublicKey   [32]byte // X25519 public key (32 bytes)
+	Label       string   // Optional label (e.g., "laptop", "phone")
+	Ratchet     bool     // V4: This client supports ratcheted DMs
+	MultiDevice bool     // V4: This client supports multi-device DMs
 }
 
 func (m *ProvidePublicKeyMessage) EncodeTo(w io.Writer) error {
@@ -4803,7 +4981,13 @@ func (m *ProvidePublicKeyMessage) EncodeTo(w io.Writer) error {
 	if _, err := w.Write(m.PublicKey[:]); err != nil {
 		return err
 	}
-	return WriteString(w, m.Label)
+	if err := WriteString(w, m.Label); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.Ratchet); err != nil {
+		return err
+	}
+	return WriteBool(w, m.MultiDevice)
 }
 
 func (m *ProvidePublicKeyMessage) Encode() ([]byte, error) {
@@ -4831,6 +5015,20 @@ func (m *ProvidePublicKeyMessage) Decode(payload []byte) error {
 		return err
 	}
 	m.Label = label
+
+	// Ratchet is optional for backwards compatibility
+	ratchet, err := ReadBool(buf)
+	if err != nil {
+		ratchet = false
+	}
+	m.Ratchet = ratchet
+
+	// MultiDevice is optional for backwards compatibility
+	multiDevice, err := ReadBool(buf)
+	if err != nil {
+		multiDevice = false
+	}
+	m.MultiDevice = multiDevice
 	return nil
 }
 
@@ -4915,6 +5113,8 @@ type DMReadyMessage struct {
 	OtherNickname  string   // Other user's nickname
 	IsEncrypted    bool     // Whether this DM uses encryption
 	OtherPublicKey [32]byte // Other party's X25519 public key (only if encrypted)
+	Ratchet        bool     // V4: Encrypted with a Double Ratchet instead of the static channel key
+	MultiDevice    bool     // V4: Encrypted for every device of both users, with a ratchet per pair of devices
 }
 
 func (m *DMReadyMessage) EncodeTo(w io.Writer) error {
@@ -4935,7 +5135,10 @@ func (m *DMReadyMessage) EncodeTo(w io.Writer) error {
 			return err
 		}
 	}
-	return nil
+	if err := WriteBool(w, m.Ratchet); err != nil {
+		return err
+	}
+	return WriteBool(w, m.MultiDevice)
 }
 
 func (m *DMReadyMessage) Encode() ([]byte, error) {
@@ -4977,6 +5180,20 @@ func (m *DMReadyMessage) Decode(payload []byte) error {
 			return err
 		}
 	}
+
+	// Ratchet is optional for backwards compatibility
+	ratchet, err := ReadBool(buf)
+	if err != nil {
+		ratchet = false
+	}
+	m.Ratchet = ratchet
+
+	// MultiDevice is optional for backwards compatibility
+	multiDevice, err := ReadBool(buf)
+	if err != nil {
+		multiDevice = false
+	}
+	m.MultiDevice = multiDevice
 	return nil
 }
 
@@ -5101,58 +5318,2844 @@ func (m *DMRequestMessage) Decode(payload []byte) error {
 	return nil
 }
 
-// Compile-time checks to ensure all message types implement the ProtocolMessage interface
-// This will cause a compile error if any message type is missing Encode(), EncodeTo(), or Decode()
-var (
-	// Client → Server messages
-	_ ProtocolMessage = (*AuthRequestMessage)(nil)
-	_ ProtocolMessage = (*SetNicknameMessage)(nil)
-	_ ProtocolMessage = (*RegisterUserMessage)(nil)
-	_ ProtocolMessage = (*LogoutMessage)(nil)
-	_ ProtocolMessage = (*ListChannelsMessage)(nil)
-	_ ProtocolMessage = (*JoinChannelMessage)(nil)
-	_ ProtocolMessage = (*LeaveChannelMessage)(nil)
-	_ ProtocolMessage = (*CreateChannelMessage)(nil)
-	_ ProtocolMessage = (*ListMessagesMessage)(nil)
-	_ ProtocolMessage = (*PostMessageMessage)(nil)
-	_ ProtocolMessage = (*EditMessageMessage)(nil)
-	_ ProtocolMessage = (*DeleteMessageMessage)(nil)
-	_ ProtocolMessage = (*PingMessage)(nil)
-	_ ProtocolMessage = (*DisconnectMessage)(nil)
-	_ ProtocolMessage = (*SubscribeThreadMessage)(nil)
-	_ ProtocolMessage = (*UnsubscribeThreadMessage)(nil)
-	_ ProtocolMessage = (*SubscribeChannelMessage)(nil)
-	_ ProtocolMessage = (*UnsubscribeChannelMessage)(nil)
-	_ ProtocolMessage = (*GetUserInfoMessage)(nil)
-	_ ProtocolMessage = (*ListUsersMessage)(nil)
-	_ ProtocolMessage = (*ListChannelUsersMessage)(nil)
-	_ ProtocolMessage = (*ListServersMessage)(nil)
-	_ ProtocolMessage = (*RegisterServerMessage)(nil)
-	_ ProtocolMessage = (*VerifyRegistrationMessage)(nil)
-	_ ProtocolMessage = (*HeartbeatMessage)(nil)
-	_ ProtocolMessage = (*ChangePasswordRequest)(nil)
-	_ ProtocolMessage = (*AddSSHKeyRequest)(nil)
-	_ ProtocolMessage = (*ListSSHKeysRequest)(nil)
-	_ ProtocolMessage = (*UpdateSSHKeyLabelRequest)(nil)
-	_ ProtocolMessage = (*DeleteSSHKeyRequest)(nil)
-	_ ProtocolMessage = (*BanUserMessage)(nil)
-	_ ProtocolMessage = (*BanIPMessage)(nil)
-	_ ProtocolMessage = (*UnbanUserMessage)(nil)
-	_ ProtocolMessage = (*UnbanIPMessage)(nil)
-	_ ProtocolMessage = (*ListBansMessage)(nil)
-	_ ProtocolMessage = (*DeleteUserMessage)(nil)
+// SearchMessagesMessage (0x20) - Full-text search over message content
+// All filters are optional and combine with AND.
+type SearchMessagesMessage struct {
+	Query        string
+	ChannelID    *uint64
+	SubchannelID *uint64
+	ThreadID     *uint64    // Root message ID; matches the root and all its replies
+	Author       *string    // Author nickname (without ~ or role prefix)
+	After        *time.Time // Only messages created at or after this time
+	Before       *time.Time // Only messages created before this time
+	BeforeID     *uint64    // Pagination: only messages older than this message ID
+	Limit        uint16
+}
 
-	// Server → Client messages
-	_ ProtocolMessage = (*AuthResponseMessage)(nil)
-	_ ProtocolMessage = (*NicknameResponseMessage)(nil)
-	_ ProtocolMessage = (*RegisterResponseMessage)(nil)
-	_ ProtocolMessage = (*ChannelListMessage)(nil)
-	_ ProtocolMessage = (*JoinResponseMessage)(nil)
-	_ ProtocolMessage = (*LeaveResponseMessage)(nil)
-	_ ProtocolMessage = (*ChannelCreatedMessage)(nil)
-	_ ProtocolMessage = (*MessageListMessage)(nil)
-	_ ProtocolMessage = (*MessagePostedMessage)(nil)
-	_ ProtocolMessage = (*MessageEditedMessage)(nil)
+func (m *SearchMessagesMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Query); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.ThreadID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Author); err != nil {
+		return err
+	}
+	if err := WriteOptionalTimestamp(w, m.After); err != nil {
+		return err
+	}
+	if err := WriteOptionalTimestamp(w, m.Before); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.BeforeID); err != nil {
+		return err
+	}
+	return WriteUint16(w, m.Limit)
+}
+
+func (m *SearchMessagesMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SearchMessagesMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	query, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	channelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	threadID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	author, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+	after, err := ReadOptionalTimestamp(buf)
+	if err != nil {
+		return err
+	}
+	before, err := ReadOptionalTimestamp(buf)
+	if err != nil {
+		return err
+	}
+	beforeID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	limit, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Query = query
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.ThreadID = threadID
+	m.Author = author
+	m.After = after
+	m.Before = before
+	m.BeforeID = beforeID
+	m.Limit = limit
+	return nil
+}
+
+// SearchResult is a single SEARCH_RESULTS hit. MENTION_NOTIFICATION and
+// MENTION_LIST use the same layout.
+type SearchResult struct {
+	Message    Message
+	ThreadRoot *Message // Root of the thread the hit belongs to (nil if the hit is a root message)
+}
+
+// writeSearchResult writes a message followed by its optional thread root
+func writeSearchResult(w io.Writer, result *SearchResult) error {
+	if err := writeMessage(w, &result.Message); err != nil {
+		return err
+	}
+	if err := WriteBool(w, result.ThreadRoot != nil); err != nil {
+		return err
+	}
+	if result.ThreadRoot != nil {
+		return writeMessage(w, result.ThreadRoot)
+	}
+	return nil
+}
+
+// readSearchResult reads a message followed by its optional thread root
+func readSearchResult(r io.Reader) (SearchResult, error) {
+	var result SearchResult
+	msg, err := readMessage(r)
+	if err != nil {
+		return result, err
+	}
+	result.Message = msg
+
+	hasRoot, err := ReadBool(r)
+	if err != nil {
+		return result, err
+	}
+	if hasRoot {
+		root, err := readMessage(r)
+		if err != nil {
+			return result, err
+		}
+		result.ThreadRoot = &root
+	}
+	return result, nil
+}
+
+// SearchResultsMessage (0xB0) - Response to SEARCH_MESSAGES, newest first
+type SearchResultsMessage struct {
+	Query   string
+	Results []SearchResult
+	HasMore bool // More (older) results are available; page with BeforeID = last result ID
+}
+
+func (m *SearchResultsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Query); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Results))); err != nil {
+		return err
+	}
+	for i := range m.Results {
+		if err := writeSearchResult(w, &m.Results[i]); err != nil {
+			return err
+		}
+	}
+	return WriteBool(w, m.HasMore)
+}
+
+func (m *SearchResultsMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SearchResultsMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	query, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	results := make([]SearchResult, count)
+	for i := range results {
+		result, err := readSearchResult(buf)
+		if err != nil {
+			return err
+		}
+		results[i] = result
+	}
+
+	hasMore, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Query = query
+	m.Results = results
+	m.HasMore = hasMore
+	return nil
+}
+
+// PlusOneMessage (0x21) - +1 a message.
+// Registered users count once per message; anonymous +1s are unlimited.
+type PlusOneMessage struct {
+	MessageID uint64
+}
+
+func (m *PlusOneMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.MessageID)
+}
+
+func (m *PlusOneMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *PlusOneMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	messageID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.MessageID = messageID
+	return nil
+}
+
+// PlusOneUpdateMessage (0xB1) - Current +1 counts for a message
+// Broadcast to channel and thread subscribers when a +1 is counted.
+type PlusOneUpdateMessage struct {
+	MessageID        uint64
+	PlusOneCount     uint32 // Registered users
+	PlusOneAnonCount uint32 // Anonymous users (+∞)
+}
+
+func (m *PlusOneUpdateMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.MessageID); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, m.PlusOneCount); err != nil {
+		return err
+	}
+	return WriteUint32(w, m.PlusOneAnonCount)
+}
+
+func (m *PlusOneUpdateMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *PlusOneUpdateMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	messageID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	plusOneCount, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	plusOneAnonCount, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	m.MessageID = messageID
+	m.PlusOneCount = plusOneCount
+	m.PlusOneAnonCount = plusOneAnonCount
+	return nil
+}
+
+// ListMentionsMessage (0x22) - List messages that mention the current user, newest first
+// Registered users only.
+type ListMentionsMessage struct {
+	BeforeID *uint64 // Pagination cursor: only mentions in messages with a smaller ID
+	Limit    uint16  // 0 = server default
+}
+
+func (m *ListMentionsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalUint64(w, m.BeforeID); err != nil {
+		return err
+	}
+	return WriteUint16(w, m.Limit)
+}
+
+func (m *ListMentionsMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListMentionsMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	beforeID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	limit, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.BeforeID = beforeID
+	m.Limit = limit
+	return nil
+}
+
+// MentionNotificationMessage (0xB2) - Pushed to every session of a mentioned user,
+// whether or not they are subscribed to the channel
+type MentionNotificationMessage struct {
+	Mention SearchResult
+}
+
+func (m *MentionNotificationMessage) EncodeTo(w io.Writer) error {
+	return writeSearchResult(w, &m.Mention)
+}
+
+func (m *MentionNotificationMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *MentionNotificationMessage) Decode(payload []byte) error {
+	mention, err := readSearchResult(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	m.Mention = mention
+	return nil
+}
+
+// MentionListMessage (0xB3) - Response to LIST_MENTIONS, newest first
+type MentionListMessage struct {
+	Mentions []SearchResult
+	HasMore  bool // More (older) mentions are available; page with BeforeID = last message ID
+}
+
+func (m *MentionListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Mentions))); err != nil {
+		return err
+	}
+	for i := range m.Mentions {
+		if err := writeSearchResult(w, &m.Mentions[i]); err != nil {
+			return err
+		}
+	}
+	return WriteBool(w, m.HasMore)
+}
+
+func (m *MentionListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *MentionListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	mentions := make([]SearchResult, count)
+	for i := range mentions {
+		mention, err := readSearchResult(buf)
+		if err != nil {
+			return err
+		}
+		mentions[i] = mention
+	}
+
+	hasMore, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Mentions = mentions
+	m.HasMore = hasMore
+	return nil
+}
+
+// CreateIncomingWebhookMessage (0x60) - Create a token for POST /hooks/{token} (admin only)
+type CreateIncomingWebhookMessage struct {
+	Name         string  // Integration identity shown as the message author
+	ChannelID    uint64  // Channel to post into
+	SubchannelID *uint64 // Optional subchannel of ChannelID
+	ParentID     *uint64 // Optional message to reply to (posts into its thread)
+}
+
+func (m *CreateIncomingWebhookMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Name); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.ParentID)
+}
+
+func (m *CreateIncomingWebhookMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *CreateIncomingWebhookMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	name, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	parentID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Name = name
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.ParentID = parentID
+	return nil
+}
+
+// IncomingWebhookCreatedMessage (0xB4) - Response to CREATE_INCOMING_WEBHOOK.
+// The token is only ever sent here; the server stores a hash of it.
+type IncomingWebhookCreatedMessage struct {
+	Success   bool
+	WebhookID uint64 // Only present if Success=true
+	Token     string // Only present if Success=true
+	Message   string
+}
+
+func (m *IncomingWebhookCreatedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteUint64(w, m.WebhookID); err != nil {
+			return err
+		}
+		if err := WriteString(w, m.Token); err != nil {
+			return err
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *IncomingWebhookCreatedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *IncomingWebhookCreatedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+
+	if success {
+		webhookID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		token, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		m.WebhookID = webhookID
+		m.Token = token
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+	return nil
+}
+
+// ListIncomingWebhooksMessage (0x61) - List incoming webhooks (admin only, empty payload)
+type ListIncomingWebhooksMessage struct{}
+
+func (m *ListIncomingWebhooksMessage) EncodeTo(w io.Writer) error {
+	return nil
+}
+
+func (m *ListIncomingWebhooksMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *ListIncomingWebhooksMessage) Decode(payload []byte) error {
+	return nil
+}
+
+// IncomingWebhookEntry is a single incoming webhook in INCOMING_WEBHOOK_LIST
+type IncomingWebhookEntry struct {
+	ID           uint64
+	Name         string
+	ChannelID    uint64
+	ChannelName  string
+	SubchannelID *uint64
+	ParentID     *uint64
+	CreatedBy    string // Admin nickname
+	CreatedAt    int64  // Unix milliseconds
+	LastUsedAt   *int64 // Unix milliseconds, NULL = never used
+}
+
+// IncomingWebhookListMessage (0xB5) - Response to LIST_INCOMING_WEBHOOKS
+type IncomingWebhookListMessage struct {
+	Webhooks []IncomingWebhookEntry
+}
+
+func (m *IncomingWebhookListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Webhooks))); err != nil {
+		return err
+	}
+
+	for _, hook := range m.Webhooks {
+		if err := WriteUint64(w, hook.ID); err != nil {
+			return err
+		}
+		if err := WriteString(w, hook.Name); err != nil {
+			return err
+		}
+		if err := WriteUint64(w, hook.ChannelID); err != nil {
+			return err
+		}
+		if err := WriteString(w, hook.ChannelName); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, hook.SubchannelID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, hook.ParentID); err != nil {
+			return err
+		}
+		if err := WriteString(w, hook.CreatedBy); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, hook.CreatedAt); err != nil {
+			return err
+		}
+		if err := WriteOptionalInt64(w, hook.LastUsedAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *IncomingWebhookListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *IncomingWebhookListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Webhooks = make([]IncomingWebhookEntry, count)
+
+	for i := uint16(0); i < count; i++ {
+		id, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		name, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		channelID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		channelName, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		subchannelID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		parentID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		createdBy, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		createdAt, err := ReadInt64(buf)
+		if err != nil {
+			return err
+		}
+		lastUsedAt, err := ReadOptionalInt64(buf)
+		if err != nil {
+			return err
+		}
+
+		m.Webhooks[i] = IncomingWebhookEntry{
+			ID:           id,
+			Name:         name,
+			ChannelID:    channelID,
+			ChannelName:  channelName,
+			SubchannelID: subchannelID,
+			ParentID:     parentID,
+			CreatedBy:    createdBy,
+			CreatedAt:    createdAt,
+			LastUsedAt:   lastUsedAt,
+		}
+	}
+
+	return nil
+}
+
+// RevokeIncomingWebhookMessage (0x62) - Revoke an incoming webhook token (admin only)
+type RevokeIncomingWebhookMessage struct {
+	WebhookID uint64
+}
+
+func (m *RevokeIncomingWebhookMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.WebhookID)
+}
+
+func (m *RevokeIncomingWebhookMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *RevokeIncomingWebhookMessage) Decode(payload []byte) error {
+	webhookID, err := ReadUint64(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	m.WebhookID = webhookID
+	return nil
+}
+
+// IncomingWebhookRevokedMessage (0xB6) - Response to REVOKE_INCOMING_WEBHOOK
+type IncomingWebhookRevokedMessage struct {
+	Success   bool
+	WebhookID uint64
+	Message   string
+}
+
+func (m *IncomingWebhookRevokedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.WebhookID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *IncomingWebhookRevokedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *IncomingWebhookRevokedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	webhookID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Success = success
+	m.WebhookID = webhookID
+	m.Message = message
+	return nil
+}
+
+// GetMessageHistoryMessage (0x23) - Request the edit history of a message
+type GetMessageHistoryMessage struct {
+	MessageID uint64
+}
+
+func (m *GetMessageHistoryMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.MessageID)
+}
+
+func (m *GetMessageHistoryMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *GetMessageHistoryMessage) Decode(payload []byte) error {
+	messageID, err := ReadUint64(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	m.MessageID = messageID
+	return nil
+}
+
+// Message revision kinds
+const (
+	RevisionCreated uint8 = 0
+	RevisionEdited  uint8 = 1
+	RevisionDeleted uint8 = 2
+)
+
+// MessageRevision is one version of a message in MESSAGE_HISTORY
+type MessageRevision struct {
+	Kind      uint8     // RevisionCreated, RevisionEdited or RevisionDeleted
+	Timestamp time.Time // When the message was posted, edited or deleted
+	Content   string    // Content from this revision on; for deletions, the content that was deleted
+	Hidden    bool      // Content withheld (deleted message, requester is not an admin)
+}
+
+// MessageHistoryMessage (0xB7) - Response to GET_MESSAGE_HISTORY, oldest revision first
+type MessageHistoryMessage struct {
+	Success   bool
+	MessageID uint64
+	Revisions []MessageRevision
+	Message   string // Error message if failed
+}
+
+func (m *MessageHistoryMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.MessageID); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteUint16(w, uint16(len(m.Revisions))); err != nil {
+			return err
+		}
+		for _, rev := range m.Revisions {
+			if err := WriteUint8(w, rev.Kind); err != nil {
+				return err
+			}
+			if err := WriteTimestamp(w, rev.Timestamp); err != nil {
+				return err
+			}
+			if err := WriteString(w, rev.Content); err != nil {
+				return err
+			}
+			if err := WriteBool(w, rev.Hidden); err != nil {
+				return err
+			}
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *MessageHistoryMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *MessageHistoryMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	messageID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	var revisions []MessageRevision
+	if success {
+		count, err := ReadUint16(buf)
+		if err != nil {
+			return err
+		}
+		revisions = make([]MessageRevision, count)
+		for i := range revisions {
+			kind, err := ReadUint8(buf)
+			if err != nil {
+				return err
+			}
+			timestamp, err := ReadTimestamp(buf)
+			if err != nil {
+				return err
+			}
+			content, err := ReadString(buf)
+			if err != nil {
+				return err
+			}
+			hidden, err := ReadBool(buf)
+			if err != nil {
+				return err
+			}
+			revisions[i] = MessageRevision{Kind: kind, Timestamp: timestamp, Content: content, Hidden: hidden}
+		}
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Success = success
+	m.MessageID = messageID
+	m.Revisions = revisions
+	m.Message = message
+	return nil
+}
+
+// UpdateChannelMessage (0x24) - Change a channel's settings (owner or admin).
+// Fields left nil keep their current value.
+type UpdateChannelMessage struct {
+	ChannelID      uint64
+	DisplayName    *string
+	Description    *string // Empty string clears the description
+	ChannelType    *uint8  // 0=chat, 1=forum
+	RetentionHours *uint32
+	Archived       *bool
+	Modes          *uint8 // ChannelModes bitfield, replaces the current modes
+}
+
+func (m *UpdateChannelMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.DisplayName); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Description); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint8(w, m.ChannelType); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint32(w, m.RetentionHours); err != nil {
+		return err
+	}
+	if err := WriteOptionalBool(w, m.Archived); err != nil {
+		return err
+	}
+	return WriteOptionalUint8(w, m.Modes)
+}
+
+func (m *UpdateChannelMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UpdateChannelMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	displayName, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+	description, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+	channelType, err := ReadOptionalUint8(buf)
+	if err != nil {
+		return err
+	}
+	retentionHours, err := ReadOptionalUint32(buf)
+	if err != nil {
+		return err
+	}
+	archived, err := ReadOptionalBool(buf)
+	if err != nil {
+		return err
+	}
+	modes, err := ReadOptionalUint8(buf)
+	if err != nil {
+		return err
+	}
+
+	m.ChannelID = channelID
+	m.DisplayName = displayName
+	m.Description = description
+	m.ChannelType = channelType
+	m.RetentionHours = retentionHours
+	m.Archived = archived
+	m.Modes = modes
+	return nil
+}
+
+// ChannelUpdatedMessage (0xB8) - Response to UPDATE_CHANNEL, also broadcast
+// to all other clients when a channel changes
+type ChannelUpdatedMessage struct {
+	Success        bool
+	ChannelID      uint64
+	Name           string       // Only present if Success=true
+	DisplayName    string       // Only present if Success=true
+	Description    string       // Only present if Success=true
+	Type           uint8        // Only present if Success=true
+	RetentionHours uint32       // Only present if Success=true
+	Archived       bool         // Only present if Success=true
+	Modes          ChannelModes // Only present if Success=true
+	Message        string
+}
+
+func (m *ChannelUpdatedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteString(w, m.Name); err != nil {
+			return err
+		}
+		if err := WriteString(w, m.DisplayName); err != nil {
+			return err
+		}
+		if err := WriteString(w, m.Description); err != nil {
+			return err
+		}
+		if err := WriteUint8(w, m.Type); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, m.RetentionHours); err != nil {
+			return err
+		}
+		if err := WriteBool(w, m.Archived); err != nil {
+			return err
+		}
+		if err := WriteUint8(w, uint8(m.Modes)); err != nil {
+			return err
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *ChannelUpdatedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelUpdatedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	*m = ChannelUpdatedMessage{Success: success, ChannelID: channelID}
+	if success {
+		if m.Name, err = ReadString(buf); err != nil {
+			return err
+		}
+		if m.DisplayName, err = ReadString(buf); err != nil {
+			return err
+		}
+		if m.Description, err = ReadString(buf); err != nil {
+			return err
+		}
+		if m.Type, err = ReadUint8(buf); err != nil {
+			return err
+		}
+		if m.RetentionHours, err = ReadUint32(buf); err != nil {
+			return err
+		}
+		if m.Archived, err = ReadBool(buf); err != nil {
+			return err
+		}
+		modes, err := ReadUint8(buf)
+		if err != nil {
+			return err
+		}
+		m.Modes = ChannelModes(modes)
+	}
+
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// ListAdminActionsMessage (0x63) - Query the admin audit log, newest first
+type ListAdminActionsMessage struct {
+	AdminNickname string  // Empty = any admin
+	ActionType    string  // Empty = any action, matched case-insensitively
+	Since         *int64  // Unix milliseconds, inclusive
+	Until         *int64  // Unix milliseconds, exclusive
+	BeforeID      *uint64 // Only entries older than this one (for paging)
+	Limit         uint16  // 0 = server default
+}
+
+func (m *ListAdminActionsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.AdminNickname); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.ActionType); err != nil {
+		return err
+	}
+	if err := WriteOptionalInt64(w, m.Since); err != nil {
+		return err
+	}
+	if err := WriteOptionalInt64(w, m.Until); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.BeforeID); err != nil {
+		return err
+	}
+	return WriteUint16(w, m.Limit)
+}
+
+func (m *ListAdminActionsMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListAdminActionsMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.AdminNickname, err = ReadString(buf); err != nil {
+		return err
+	}
+	if m.ActionType, err = ReadString(buf); err != nil {
+		return err
+	}
+	if m.Since, err = ReadOptionalInt64(buf); err != nil {
+		return err
+	}
+	if m.Until, err = ReadOptionalInt64(buf); err != nil {
+		return err
+	}
+	if m.BeforeID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	m.Limit, err = ReadUint16(buf)
+	return err
+}
+
+// AdminActionEntry is a single audit log entry in ADMIN_ACTION_LIST
+type AdminActionEntry struct {
+	ID               uint64
+	AdminUserID      *uint64 // NULL for entries without a recorded user ID
+	AdminNickname    string
+	ActionType       string
+	TargetType       string // "user", "ip", "message", "channel", or empty
+	TargetID         *uint64
+	TargetIdentifier string // Nickname, IP, channel name, etc.
+	Details          string
+	PerformedAt      int64   // Unix milliseconds
+	IPAddress        *string // Admin's IP address, if recorded
+}
+
+// AdminActionListMessage (0xB9) - Response to LIST_ADMIN_ACTIONS
+type AdminActionListMessage struct {
+	Actions []AdminActionEntry
+	HasMore bool // More entries are available before the last one
+}
+
+func (m *AdminActionListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Actions))); err != nil {
+		return err
+	}
+
+	for _, action := range m.Actions {
+		if err := WriteUint64(w, action.ID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, action.AdminUserID); err != nil {
+			return err
+		}
+		if err := WriteString(w, action.AdminNickname); err != nil {
+			return err
+		}
+		if err := WriteString(w, action.ActionType); err != nil {
+			return err
+		}
+		if err := WriteString(w, action.TargetType); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, action.TargetID); err != nil {
+			return err
+		}
+		if err := WriteString(w, action.TargetIdentifier); err != nil {
+			return err
+		}
+		if err := WriteString(w, action.Details); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, action.PerformedAt); err != nil {
+			return err
+		}
+		if err := WriteOptionalString(w, action.IPAddress); err != nil {
+			return err
+		}
+	}
+
+	return WriteBool(w, m.HasMore)
+}
+
+func (m *AdminActionListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *AdminActionListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Actions = make([]AdminActionEntry, count)
+
+	for i := range m.Actions {
+		action := &m.Actions[i]
+		if action.ID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+		if action.AdminUserID, err = ReadOptionalUint64(buf); err != nil {
+			return err
+		}
+		if action.AdminNickname, err = ReadString(buf); err != nil {
+			return err
+		}
+		if action.ActionType, err = ReadString(buf); err != nil {
+			return err
+		}
+		if action.TargetType, err = ReadString(buf); err != nil {
+			return err
+		}
+		if action.TargetID, err = ReadOptionalUint64(buf); err != nil {
+			return err
+		}
+		if action.TargetIdentifier, err = ReadString(buf); err != nil {
+			return err
+		}
+		if action.Details, err = ReadString(buf); err != nil {
+			return err
+		}
+		if action.PerformedAt, err = ReadInt64(buf); err != nil {
+			return err
+		}
+		if action.IPAddress, err = ReadOptionalString(buf); err != nil {
+			return err
+		}
+	}
+
+	m.HasMore, err = ReadBool(buf)
+	return err
+}
+
+// KickUserMessage (0x64) - Disconnect all of a user's sessions (admin only)
+type KickUserMessage struct {
+	UserID   *uint64 // Optional: user ID to kick (takes precedence if provided)
+	Nickname *string // Optional: nickname to kick (if user_id not provided)
+	Reason   string
+}
+
+func (m *KickUserMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Nickname); err != nil {
+		return err
+	}
+	return WriteString(w, m.Reason)
+}
+
+func (m *KickUserMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *KickUserMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.UserID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	if m.Nickname, err = ReadOptionalString(buf); err != nil {
+		return err
+	}
+	m.Reason, err = ReadString(buf)
+	return err
+}
+
+// UserKickedMessage (0xBA) - Response to KICK_USER
+type UserKickedMessage struct {
+	Success              bool
+	SessionsDisconnected uint32
+	Message              string
+}
+
+func (m *UserKickedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, m.SessionsDisconnected); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *UserKickedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UserKickedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.Success, err = ReadBool(buf); err != nil {
+		return err
+	}
+	if m.SessionsDisconnected, err = ReadUint32(buf); err != nil {
+		return err
+	}
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// MuteUserMessage (0x65) - Stop a user posting, everywhere or in one channel (admin only)
+type MuteUserMessage struct {
+	UserID          *uint64 // Optional: user ID to mute (takes precedence if provided)
+	Nickname        *string // Optional: nickname to mute (if user_id not provided)
+	ChannelID       *uint64 // NULL = all channels
+	Reason          string
+	DurationSeconds *uint64 // NULL = permanent mute
+}
+
+func (m *MuteUserMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Nickname); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Reason); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.DurationSeconds)
+}
+
+func (m *MuteUserMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *MuteUserMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.UserID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	if m.Nickname, err = ReadOptionalString(buf); err != nil {
+		return err
+	}
+	if m.ChannelID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	if m.Reason, err = ReadString(buf); err != nil {
+		return err
+	}
+	m.DurationSeconds, err = ReadOptionalUint64(buf)
+	return err
+}
+
+// UserMutedMessage (0xBB) - Response to MUTE_USER
+type UserMutedMessage struct {
+	Success bool
+	MuteID  uint64 // Only present if Success=true
+	Message string
+}
+
+func (m *UserMutedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteUint64(w, m.MuteID); err != nil {
+			return err
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *UserMutedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UserMutedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.Success, err = ReadBool(buf); err != nil {
+		return err
+	}
+	if m.Success {
+		if m.MuteID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+	}
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// UnmuteUserMessage (0x66) - Lift a user's mutes for one scope (admin only)
+type UnmuteUserMessage struct {
+	UserID    *uint64 // Optional: user ID to unmute (takes precedence if provided)
+	Nickname  *string // Optional: nickname to unmute (if user_id not provided)
+	ChannelID *uint64 // NULL = lift the global mute, otherwise the mute in this channel
+}
+
+func (m *UnmuteUserMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Nickname); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.ChannelID)
+}
+
+func (m *UnmuteUserMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UnmuteUserMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.UserID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	if m.Nickname, err = ReadOptionalString(buf); err != nil {
+		return err
+	}
+	m.ChannelID, err = ReadOptionalUint64(buf)
+	return err
+}
+
+// UserUnmutedMessage (0xBC) - Response to UNMUTE_USER
+type UserUnmutedMessage struct {
+	Success bool
+	Message string
+}
+
+func (m *UserUnmutedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *UserUnmutedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UserUnmutedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.Success, err = ReadBool(buf); err != nil {
+		return err
+	}
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// Channel roles (V4). Roles are set on top-level channels and cover their
+// subchannels.
+const (
+	ChannelRoleMember    uint8 = 0
+	ChannelRoleModerator uint8 = 1
+	ChannelRoleOwner     uint8 = 2
+)
+
+// SetChannelRoleMessage (0x67) - Make a user a channel owner, moderator or member
+type SetChannelRoleMessage struct {
+	ChannelID uint64
+	UserID    *uint64 // Registered user to change
+	Nickname  *string // Or their nickname
+	Role      uint8   // ChannelRoleMember removes the user's role
+}
+
+func (m *SetChannelRoleMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Nickname); err != nil {
+		return err
+	}
+	return WriteUint8(w, m.Role)
+}
+
+func (m *SetChannelRoleMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SetChannelRoleMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	if m.UserID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	if m.Nickname, err = ReadOptionalString(buf); err != nil {
+		return err
+	}
+	m.Role, err = ReadUint8(buf)
+	return err
+}
+
+// ChannelRoleSetMessage (0xBD) - Response to SET_CHANNEL_ROLE
+type ChannelRoleSetMessage struct {
+	Success   bool
+	ChannelID uint64
+	Message   string
+}
+
+func (m *ChannelRoleSetMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *ChannelRoleSetMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelRoleSetMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.Success, err = ReadBool(buf); err != nil {
+		return err
+	}
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// ListChannelRolesMessage (0x68) - List a channel's owners and moderators
+type ListChannelRolesMessage struct {
+	ChannelID uint64
+}
+
+func (m *ListChannelRolesMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.ChannelID)
+}
+
+func (m *ListChannelRolesMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListChannelRolesMessage) Decode(payload []byte) error {
+	var err error
+	m.ChannelID, err = ReadUint64(bytes.NewReader(payload))
+	return err
+}
+
+// ChannelRoleEntry is one owner or moderator in CHANNEL_ROLE_LIST
+type ChannelRoleEntry struct {
+	UserID    uint64
+	Nickname  string
+	Role      uint8
+	GrantedBy string // Empty for channel creators
+	GrantedAt int64  // Unix milliseconds
+}
+
+// ChannelRoleListMessage (0xBE) - Response to LIST_CHANNEL_ROLES
+type ChannelRoleListMessage struct {
+	ChannelID uint64
+	Roles     []ChannelRoleEntry
+}
+
+func (m *ChannelRoleListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Roles))); err != nil {
+		return err
+	}
+	for _, role := range m.Roles {
+		if err := WriteUint64(w, role.UserID); err != nil {
+			return err
+		}
+		if err := WriteString(w, role.Nickname); err != nil {
+			return err
+		}
+		if err := WriteUint8(w, role.Role); err != nil {
+			return err
+		}
+		if err := WriteString(w, role.GrantedBy); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, role.GrantedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ChannelRoleListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelRoleListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.Roles = make([]ChannelRoleEntry, count)
+	for i := range m.Roles {
+		role := &m.Roles[i]
+		if role.UserID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+		if role.Nickname, err = ReadString(buf); err != nil {
+			return err
+		}
+		if role.Role, err = ReadUint8(buf); err != nil {
+			return err
+		}
+		if role.GrantedBy, err = ReadString(buf); err != nil {
+			return err
+		}
+		if role.GrantedAt, err = ReadInt64(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Channel grants (V4). Invites let users into invite-only channels; voice
+// lets them post in moderated channels.
+const (
+	ChannelGrantInvite uint8 = 1
+	ChannelGrantVoice  uint8 = 2
+)
+
+// SetChannelGrantMessage (0x69) - Invite or voice a user in a channel, or take
+// the grant away
+type SetChannelGrantMessage struct {
+	ChannelID uint64
+	UserID    *uint64 // Registered user to change
+	Nickname  *string // Or their nickname
+	Grant     uint8   // ChannelGrantInvite or ChannelGrantVoice
+	Granted   bool    // false withdraws the grant
+}
+
+func (m *SetChannelGrantMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Nickname); err != nil {
+		return err
+	}
+	if err := WriteUint8(w, m.Grant); err != nil {
+		return err
+	}
+	return WriteBool(w, m.Granted)
+}
+
+func (m *SetChannelGrantMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SetChannelGrantMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	if m.UserID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	if m.Nickname, err = ReadOptionalString(buf); err != nil {
+		return err
+	}
+	if m.Grant, err = ReadUint8(buf); err != nil {
+		return err
+	}
+	m.Granted, err = ReadBool(buf)
+	return err
+}
+
+// ChannelGrantSetMessage (0xBF) - Response to SET_CHANNEL_GRANT
+type ChannelGrantSetMessage struct {
+	Success   bool
+	ChannelID uint64
+	Message   string
+}
+
+func (m *ChannelGrantSetMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *ChannelGrantSetMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelGrantSetMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.Success, err = ReadBool(buf); err != nil {
+		return err
+	}
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// ChannelMemberMessage (0x25, 0x26) - Add a registered user to a private
+// channel (INVITE_TO_CHANNEL) or remove them (REMOVE_FROM_CHANNEL)
+type ChannelMemberMessage struct {
+	ChannelID uint64
+	UserID    *uint64 // Registered user to add or remove
+	Nickname  *string // Or their nickname
+}
+
+func (m *ChannelMemberMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	return WriteOptionalString(w, m.Nickname)
+}
+
+func (m *ChannelMemberMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelMemberMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	if m.UserID, err = ReadOptionalUint64(buf); err != nil {
+		return err
+	}
+	m.Nickname, err = ReadOptionalString(buf)
+	return err
+}
+
+// LeavePrivateChannelMessage (0x27) - Give up membership of a private channel
+type LeavePrivateChannelMessage struct {
+	ChannelID uint64
+}
+
+func (m *LeavePrivateChannelMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.ChannelID)
+}
+
+func (m *LeavePrivateChannelMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *LeavePrivateChannelMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	m.ChannelID, err = ReadUint64(buf)
+	return err
+}
+
+// ListPrivateChannelsMessage (0x28) - List your private channels (empty payload).
+// The server answers with PRIVATE_CHANNEL_LIST, which has the same payload as
+// CHANNEL_LIST.
+type ListPrivateChannelsMessage struct{}
+
+func (m *ListPrivateChannelsMessage) EncodeTo(w io.Writer) error {
+	return nil
+}
+
+func (m *ListPrivateChannelsMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *ListPrivateChannelsMessage) Decode(payload []byte) error {
+	return nil
+}
+
+// ChannelMembershipChangedMessage (0xC0) - Response to INVITE_TO_CHANNEL,
+// REMOVE_FROM_CHANNEL and LEAVE_PRIVATE_CHANNEL
+type ChannelMembershipChangedMessage struct {
+	Success   bool
+	ChannelID uint64
+	Message   string
+}
+
+func (m *ChannelMembershipChangedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *ChannelMembershipChangedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelMembershipChangedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.Success, err = ReadBool(buf); err != nil {
+		return err
+	}
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// ChannelInvitedMessage (0xC2) - Sent to every session of a user who was
+// added to a private channel
+type ChannelInvitedMessage struct {
+	ChannelID      uint64
+	Name           string
+	Description    string
+	Type           uint8
+	RetentionHours uint32
+	InvitedBy      string
+}
+
+func (m *ChannelInvitedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Name); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Description); err != nil {
+		return err
+	}
+	if err := WriteUint8(w, m.Type); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, m.RetentionHours); err != nil {
+		return err
+	}
+	return WriteString(w, m.InvitedBy)
+}
+
+func (m *ChannelInvitedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelInvitedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	if m.Name, err = ReadString(buf); err != nil {
+		return err
+	}
+	if m.Description, err = ReadString(buf); err != nil {
+		return err
+	}
+	if m.Type, err = ReadUint8(buf); err != nil {
+		return err
+	}
+	if m.RetentionHours, err = ReadUint32(buf); err != nil {
+		return err
+	}
+	m.InvitedBy, err = ReadString(buf)
+	return err
+}
+
+// RemovedFromChannelMessage (0xC3) - Sent to every session of a user who was
+// removed from, or left, a private channel
+type RemovedFromChannelMessage struct {
+	ChannelID uint64
+	Message   string
+}
+
+func (m *RemovedFromChannelMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *RemovedFromChannelMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *RemovedFromChannelMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	m.Message, err = ReadString(buf)
+	return err
+}
+
+// Private channel keys (V4). Private channel messages are encrypted with a
+// random channel key that a member wraps for every member's X25519 public
+// key. Each key has an epoch; a new key with the next epoch is made whenever
+// someone joins or leaves. The server stores the wrapped keys but can't
+// unwrap them.
+
+// WrappedChannelKeySize is the size of a channel key wrapped for one member:
+// ephemeral X25519 public key (32) || nonce (12) || encrypted key (32) || tag (16)
+const WrappedChannelKeySize = 92
+
+// WrappedChannelKey is a channel key wrapped for one member
+type WrappedChannelKey struct {
+	UserID     uint64
+	WrappedKey [WrappedChannelKeySize]byte
+}
+
+// DistributeChannelKeyMessage (0x29) - Hand out a new key for a private
+// channel, wrapped for each of its current members
+type DistributeChannelKeyMessage struct {
+	ChannelID uint64
+	Epoch     uint32 // Must be the channel's current epoch + 1
+	Keys      []WrappedChannelKey
+}
+
+func (m *DistributeChannelKeyMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, m.Epoch); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Keys))); err != nil {
+		return err
+	}
+	for _, key := range m.Keys {
+		if err := WriteUint64(w, key.UserID); err != nil {
+			return err
+		}
+		if _, err := w.Write(key.WrappedKey[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *DistributeChannelKeyMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *DistributeChannelKeyMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	if m.Epoch, err = ReadUint32(buf); err != nil {
+		return err
+	}
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.Keys = make([]WrappedChannelKey, count)
+	for i := range m.Keys {
+		key := &m.Keys[i]
+		if key.UserID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(buf, key.WrappedKey[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetChannelKeysMessage (0x2A) - Fetch the keys of a private channel that
+// were wrapped for you
+type GetChannelKeysMessage struct {
+	ChannelID uint64
+}
+
+func (m *GetChannelKeysMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.ChannelID)
+}
+
+func (m *GetChannelKeysMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *GetChannelKeysMessage) Decode(payload []byte) error {
+	var err error
+	m.ChannelID, err = ReadUint64(bytes.NewReader(payload))
+	return err
+}
+
+// ChannelKeyEntry is one epoch's key in CHANNEL_KEYS
+type ChannelKeyEntry struct {
+	Epoch      uint32
+	WrappedKey [WrappedChannelKeySize]byte
+}
+
+// ChannelKeysMessage (0xC4) - Response to GET_CHANNEL_KEYS, and sent to every
+// member when a new key is distributed
+type ChannelKeysMessage struct {
+	ChannelID uint64
+	Keys      []ChannelKeyEntry // Oldest epoch first
+}
+
+func (m *ChannelKeysMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Keys))); err != nil {
+		return err
+	}
+	for _, key := range m.Keys {
+		if err := WriteUint32(w, key.Epoch); err != nil {
+			return err
+		}
+		if _, err := w.Write(key.WrappedKey[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ChannelKeysMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelKeysMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.Keys = make([]ChannelKeyEntry, count)
+	for i := range m.Keys {
+		key := &m.Keys[i]
+		if key.Epoch, err = ReadUint32(buf); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(buf, key.WrappedKey[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChannelKeyMember is a member a new channel key must be wrapped for
+type ChannelKeyMember struct {
+	UserID    uint64
+	Nickname  string
+	PublicKey [32]byte // X25519 public key
+}
+
+// ChannelRekeyRequiredMessage (0xC5) - Sent to one member when a private
+// channel's key no longer matches its members
+type ChannelRekeyRequiredMessage struct {
+	ChannelID uint64
+	Epoch     uint32 // The epoch the new key must use
+	Members   []ChannelKeyMember
+}
+
+func (m *ChannelRekeyRequiredMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, m.Epoch); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Members))); err != nil {
+		return err
+	}
+	for _, member := range m.Members {
+		if err := WriteUint64(w, member.UserID); err != nil {
+			return err
+		}
+		if err := WriteString(w, member.Nickname); err != nil {
+			return err
+		}
+		if _, err := w.Write(member.PublicKey[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ChannelRekeyRequiredMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelRekeyRequiredMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.ChannelID, err = ReadUint64(buf); err != nil {
+		return err
+	}
+	if m.Epoch, err = ReadUint32(buf); err != nil {
+		return err
+	}
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.Members = make([]ChannelKeyMember, count)
+	for i := range m.Members {
+		member := &m.Members[i]
+		if member.UserID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+		if member.Nickname, err = ReadString(buf); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(buf, member.PublicKey[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaxKeyBackupSize is the largest key backup the server stores
+const MaxKeyBackupSize = 64 * 1024
+
+// writeKeyBackup writes a key backup as a u32 length followed by its bytes
+func writeKeyBackup(w io.Writer, backup []byte) error {
+	if len(backup) > MaxKeyBackupSize {
+		return ErrKeyBackupTooLarge
+	}
+	if err := WriteUint32(w, uint32(len(backup))); err != nil {
+		return err
+	}
+	_, err := w.Write(backup)
+	return err
+}
+
+// readKeyBackup reads a key backup written by writeKeyBackup
+func readKeyBackup(r io.Reader) ([]byte, error) {
+	length, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > MaxKeyBackupSize {
+		return nil, ErrKeyBackupTooLarge
+	}
+	backup := make([]byte, length)
+	if _, err := io.ReadFull(r, backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// StoreKeyBackupMessage (0x2B) - Store an encrypted backup of your DM
+// encryption keys on the server, replacing any earlier one. The backup is
+// opaque to the server. An empty backup deletes the stored one.
+type StoreKeyBackupMessage struct {
+	Backup []byte
+}
+
+func (m *StoreKeyBackupMessage) EncodeTo(w io.Writer) error {
+	return writeKeyBackup(w, m.Backup)
+}
+
+func (m *StoreKeyBackupMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *StoreKeyBackupMessage) Decode(payload []byte) error {
+	var err error
+	m.Backup, err = readKeyBackup(bytes.NewReader(payload))
+	return err
+}
+
+// GetKeyBackupMessage (0x2C) - Fetch your stored key backup (empty payload).
+// The server answers with KEY_BACKUP.
+type GetKeyBackupMessage struct{}
+
+func (m *GetKeyBackupMessage) EncodeTo(w io.Writer) error {
+	return nil
+}
+
+func (m *GetKeyBackupMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *GetKeyBackupMessage) Decode(payload []byte) error {
+	return nil
+}
+
+// KeyBackupMessage (0xC6) - Response to GET_KEY_BACKUP. Backup is empty and
+// UpdatedAt nil if no backup is stored.
+type KeyBackupMessage struct {
+	Backup    []byte
+	UpdatedAt *time.Time
+}
+
+func (m *KeyBackupMessage) EncodeTo(w io.Writer) error {
+	if err := writeKeyBackup(w, m.Backup); err != nil {
+		return err
+	}
+	return WriteOptionalTimestamp(w, m.UpdatedAt)
+}
+
+func (m *KeyBackupMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *KeyBackupMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	var err error
+	if m.Backup, err = readKeyBackup(buf); err != nil {
+		return err
+	}
+	m.UpdatedAt, err = ReadOptionalTimestamp(buf)
+	return err
+}
+
+// KeyBackupStoredMessage (0xC7) - Response to STORE_KEY_BACKUP. UpdatedAt is
+// nil if the backup was deleted.
+type KeyBackupStoredMessage struct {
+	UpdatedAt *time.Time
+}
+
+func (m *KeyBackupStoredMessage) EncodeTo(w io.Writer) error {
+	return WriteOptionalTimestamp(w, m.UpdatedAt)
+}
+
+func (m *KeyBackupStoredMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *KeyBackupStoredMessage) Decode(payload []byte) error {
+	var err error
+	m.UpdatedAt, err = ReadOptionalTimestamp(bytes.NewReader(payload))
+	return err
+}
+
+// RevokeDeviceMessage (0x2D) - Revoke one of your devices
+type RevokeDeviceMessage struct {
+	DeviceID uint64
+}
+
+func (m *RevokeDeviceMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.DeviceID)
+}
+
+func (m *RevokeDeviceMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *RevokeDeviceMessage) Decode(payload []byte) error {
+	var err error
+	m.DeviceID, err = ReadUint64(bytes.NewReader(payload))
+	return err
+}
+
+// DeviceRevokedMessage (0xC8) - Response to REVOKE_DEVICE
+type DeviceRevokedMessage struct {
+	DeviceID uint64
+}
+
+func (m *DeviceRevokedMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.DeviceID)
+}
+
+func (m *DeviceRevokedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *DeviceRevokedMessage) Decode(payload []byte) error {
+	var err error
+	m.DeviceID, err = ReadUint64(bytes.NewReader(payload))
+	return err
+}
+
+// ResumeSessionMessage (0x2E) - Authenticate with a resume token from
+// RESUME_TOKEN instead of a password. Answered with AUTH_RESPONSE.
+type ResumeSessionMessage struct {
+	Token string
+}
+
+func (m *ResumeSessionMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.Token)
+}
+
+func (m *ResumeSessionMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ResumeSessionMessage) Decode(payload []byte) error {
+	var err error
+	m.Token, err = ReadString(bytes.NewReader(payload))
+	return err
+}
+
+// ResumeTokenMessage (0xC9) - A new resume token, sent after a successful
+// AUTH_RESPONSE to an AUTH_REQUEST that asked for one
+type ResumeTokenMessage struct {
+	SessionID uint64 // Identifies the login in SESSION_LIST
+	Token     string
+}
+
+func (m *ResumeTokenMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.SessionID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Token)
+}
+
+func (m *ResumeTokenMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ResumeTokenMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	sessionID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	token, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.SessionID = sessionID
+	m.Token = token
+	return nil
+}
+
+// ListSessionsMessage (0x2F) - List your logins
+type ListSessionsMessage struct{}
+
+func (m *ListSessionsMessage) EncodeTo(w io.Writer) error {
+	return nil
+}
+
+func (m *ListSessionsMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *ListSessionsMessage) Decode(payload []byte) error {
+	return nil
+}
+
+// SessionListMessage (0xCA) - Response to LIST_SESSIONS
+type SessionListMessage struct {
+	Sessions []LoginSessionInfo
+}
+
+// LoginSessionInfo is one of a registered user's logins with a resume token
+type LoginSessionInfo struct {
+	SessionID  uint64
+	Label      string // IP address the login came from
+	CreatedAt  int64  // Unix milliseconds
+	LastUsedAt int64  // Unix milliseconds the token was last used
+	Current    bool   // The login the request came from
+	Online     bool   // A connection is using the login now
+}
+
+func (m *SessionListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Sessions))); err != nil {
+		return err
+	}
+	for _, session := range m.Sessions {
+		if err := WriteUint64(w, session.SessionID); err != nil {
+			return err
+		}
+		if err := WriteString(w, session.Label); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, session.CreatedAt); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, session.LastUsedAt); err != nil {
+			return err
+		}
+		if err := WriteBool(w, session.Current); err != nil {
+			return err
+		}
+		if err := WriteBool(w, session.Online); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *SessionListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SessionListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.Sessions = make([]LoginSessionInfo, count)
+	for i := range m.Sessions {
+		session := &m.Sessions[i]
+		if session.SessionID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+		if session.Label, err = ReadString(buf); err != nil {
+			return err
+		}
+		if session.CreatedAt, err = ReadInt64(buf); err != nil {
+			return err
+		}
+		if session.LastUsedAt, err = ReadInt64(buf); err != nil {
+			return err
+		}
+		if session.Current, err = ReadBool(buf); err != nil {
+			return err
+		}
+		if session.Online, err = ReadBool(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeSessionMessage (0x30) - Log out one of your other logins
+type RevokeSessionMessage struct {
+	SessionID uint64
+}
+
+func (m *RevokeSessionMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.SessionID)
+}
+
+func (m *RevokeSessionMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *RevokeSessionMessage) Decode(payload []byte) error {
+	var err error
+	m.SessionID, err = ReadUint64(bytes.NewReader(payload))
+	return err
+}
+
+// SessionRevokedMessage (0xCB) - Response to REVOKE_SESSION
+type SessionRevokedMessage struct {
+	SessionID uint64
+}
+
+func (m *SessionRevokedMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.SessionID)
+}
+
+func (m *SessionRevokedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SessionRevokedMessage) Decode(payload []byte) error {
+	var err error
+	m.SessionID, err = ReadUint64(bytes.NewReader(payload))
+	return err
+}
+
+// AuthTOTPMessage (0x31) - Answer an AUTH_RESPONSE two-factor challenge with
+// a code from the authenticator or a recovery code. Answered with
+// AUTH_RESPONSE.
+type AuthTOTPMessage struct {
+	Code string
+}
+
+func (m *AuthTOTPMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.Code)
+}
+
+func (m *AuthTOTPMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *AuthTOTPMessage) Decode(payload []byte) error {
+	var err error
+	m.Code, err = ReadString(bytes.NewReader(payload))
+	return err
+}
+
+// SetupTOTPMessage (0x32) - Start enrolling an authenticator. Also allowed
+// while answering an AuthChallengeTOTPSetup challenge.
+type SetupTOTPMessage struct{}
+
+func (m *SetupTOTPMessage) EncodeTo(w io.Writer) error {
+	return nil
+}
+
+func (m *SetupTOTPMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *SetupTOTPMessage) Decode(payload []byte) error {
+	return nil
+}
+
+// TOTPSetupMessage (0xCC) - Response to SETUP_TOTP with a new secret to add
+// to an authenticator. Nothing changes until CONFIRM_TOTP.
+type TOTPSetupMessage struct {
+	Secret string // Base32, for typing in
+	URI    string // otpauth:// URI, for QR codes
+}
+
+func (m *TOTPSetupMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Secret); err != nil {
+		return err
+	}
+	return WriteString(w, m.URI)
+}
+
+func (m *TOTPSetupMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *TOTPSetupMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	secret, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	uri, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Secret = secret
+	m.URI = uri
+	return nil
+}
+
+// ConfirmTOTPMessage (0x33) - Finish enrolling with a code from the new
+// authenticator
+type ConfirmTOTPMessage struct {
+	Code string
+}
+
+func (m *ConfirmTOTPMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.Code)
+}
+
+func (m *ConfirmTOTPMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ConfirmTOTPMessage) Decode(payload []byte) error {
+	var err error
+	m.Code, err = ReadString(bytes.NewReader(payload))
+	return err
+}
+
+// TOTPEnabledMessage (0xCD) - Response to CONFIRM_TOTP with the recovery
+// codes, which are only ever sent this once
+type TOTPEnabledMessage struct {
+	RecoveryCodes []string
+}
+
+func (m *TOTPEnabledMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.RecoveryCodes))); err != nil {
+		return err
+	}
+	for _, code := range m.RecoveryCodes {
+		if err := WriteString(w, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *TOTPEnabledMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *TOTPEnabledMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.RecoveryCodes = make([]string, count)
+	for i := range m.RecoveryCodes {
+		if m.RecoveryCodes[i], err = ReadString(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisableTOTPMessage (0x34) - Remove your authenticator, confirmed with a
+// current code or a recovery code
+type DisableTOTPMessage struct {
+	Code string
+}
+
+func (m *DisableTOTPMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.Code)
+}
+
+func (m *DisableTOTPMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *DisableTOTPMessage) Decode(payload []byte) error {
+	var err error
+	m.Code, err = ReadString(bytes.NewReader(payload))
+	return err
+}
+
+// TOTPDisabledMessage (0xCE) - Response to DISABLE_TOTP
+type TOTPDisabledMessage struct{}
+
+func (m *TOTPDisabledMessage) EncodeTo(w io.Writer) error {
+	return nil
+}
+
+func (m *TOTPDisabledMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *TOTPDisabledMessage) Decode(payload []byte) error {
+	return nil
+}
+
+// Compile-time checks to ensure all message types implement the ProtocolMessage interface
+// This will cause a compile error if any message type is missing Encode(), EncodeTo(), or Decode()
+var (
+	// Client → Server messages
+	_ ProtocolMessage = (*AuthRequestMessage)(nil)
+	_ ProtocolMessage = (*SetNicknameMessage)(nil)
+	_ ProtocolMessage = (*RegisterUserMessage)(nil)
+	_ ProtocolMessage = (*LogoutMessage)(nil)
+	_ ProtocolMessage = (*ListChannelsMessage)(nil)
+	_ ProtocolMessage = (*JoinChannelMessage)(nil)
+	_ ProtocolMessage = (*LeaveChannelMessage)(nil)
+	_ ProtocolMessage = (*CreateChannelMessage)(nil)
+	_ ProtocolMessage = (*ListMessagesMessage)(nil)
+	_ ProtocolMessage = (*PostMessageMessage)(nil)
+	_ ProtocolMessage = (*EditMessageMessage)(nil)
+	_ ProtocolMessage = (*DeleteMessageMessage)(nil)
+	_ ProtocolMessage = (*PingMessage)(nil)
+	_ ProtocolMessage = (*DisconnectMessage)(nil)
+	_ ProtocolMessage = (*SubscribeThreadMessage)(nil)
+	_ ProtocolMessage = (*UnsubscribeThreadMessage)(nil)
+	_ ProtocolMessage = (*SubscribeChannelMessage)(nil)
+	_ ProtocolMessage = (*UnsubscribeChannelMessage)(nil)
+	_ ProtocolMessage = (*GetUserInfoMessage)(nil)
+	_ ProtocolMessage = (*ListUsersMessage)(nil)
+	_ ProtocolMessage = (*ListChannelUsersMessage)(nil)
+	_ ProtocolMessage = (*ListServersMessage)(nil)
+	_ ProtocolMessage = (*RegisterServerMessage)(nil)
+	_ ProtocolMessage = (*VerifyRegistrationMessage)(nil)
+	_ ProtocolMessage = (*HeartbeatMessage)(nil)
+	_ ProtocolMessage = (*ChangePasswordRequest)(nil)
+	_ ProtocolMessage = (*AddSSHKeyRequest)(nil)
+	_ ProtocolMessage = (*ListSSHKeysRequest)(nil)
+	_ ProtocolMessage = (*UpdateSSHKeyLabelRequest)(nil)
+	_ ProtocolMessage = (*DeleteSSHKeyRequest)(nil)
+	_ ProtocolMessage = (*BanUserMessage)(nil)
+	_ ProtocolMessage = (*BanIPMessage)(nil)
+	_ ProtocolMessage = (*UnbanUserMessage)(nil)
+	_ ProtocolMessage = (*UnbanIPMessage)(nil)
+	_ ProtocolMessage = (*ListBansMessage)(nil)
+	_ ProtocolMessage = (*DeleteUserMessage)(nil)
+
+	// Server → Client messages
+	_ ProtocolMessage = (*AuthResponseMessage)(nil)
+	_ ProtocolMessage = (*NicknameResponseMessage)(nil)
+	_ ProtocolMessage = (*RegisterResponseMessage)(nil)
+	_ ProtocolMessage = (*ChannelListMessage)(nil)
+	_ ProtocolMessage = (*JoinResponseMessage)(nil)
+	_ ProtocolMessage = (*LeaveResponseMessage)(nil)
+	_ ProtocolMessage = (*ChannelCreatedMessage)(nil)
+	_ ProtocolMessage = (*MessageListMessage)(nil)
+	_ ProtocolMessage = (*MessagePostedMessage)(nil)
+	_ ProtocolMessage = (*MessageEditedMessage)(nil)
 	_ ProtocolMessage = (*MessageDeletedMessage)(nil)
 	_ ProtocolMessage = (*PongMessage)(nil)
 	_ ProtocolMessage = (*ErrorMessage)(nil)
@@ -5190,4 +8193,66 @@ var (
 	_ ProtocolMessage = (*DMReadyMessage)(nil)
 	_ ProtocolMessage = (*DMPendingMessage)(nil)
 	_ ProtocolMessage = (*DMRequestMessage)(nil)
+
+	// V4 messages
+	_ ProtocolMessage = (*SearchMessagesMessage)(nil)
+	_ ProtocolMessage = (*SearchResultsMessage)(nil)
+	_ ProtocolMessage = (*PlusOneMessage)(nil)
+	_ ProtocolMessage = (*PlusOneUpdateMessage)(nil)
+	_ ProtocolMessage = (*ListMentionsMessage)(nil)
+	_ ProtocolMessage = (*MentionNotificationMessage)(nil)
+	_ ProtocolMessage = (*MentionListMessage)(nil)
+	_ ProtocolMessage = (*CreateIncomingWebhookMessage)(nil)
+	_ ProtocolMessage = (*IncomingWebhookCreatedMessage)(nil)
+	_ ProtocolMessage = (*ListIncomingWebhooksMessage)(nil)
+	_ ProtocolMessage = (*IncomingWebhookListMessage)(nil)
+	_ ProtocolMessage = (*RevokeIncomingWebhookMessage)(nil)
+	_ ProtocolMessage = (*IncomingWebhookRevokedMessage)(nil)
+	_ ProtocolMessage = (*GetMessageHistoryMessage)(nil)
+	_ ProtocolMessage = (*MessageHistoryMessage)(nil)
+	_ ProtocolMessage = (*UpdateChannelMessage)(nil)
+	_ ProtocolMessage = (*ChannelUpdatedMessage)(nil)
+	_ ProtocolMessage = (*ListAdminActionsMessage)(nil)
+	_ ProtocolMessage = (*AdminActionListMessage)(nil)
+	_ ProtocolMessage = (*KickUserMessage)(nil)
+	_ ProtocolMessage = (*UserKickedMessage)(nil)
+	_ ProtocolMessage = (*MuteUserMessage)(nil)
+	_ ProtocolMessage = (*UserMutedMessage)(nil)
+	_ ProtocolMessage = (*UnmuteUserMessage)(nil)
+	_ ProtocolMessage = (*UserUnmutedMessage)(nil)
+	_ ProtocolMessage = (*SetChannelRoleMessage)(nil)
+	_ ProtocolMessage = (*ChannelRoleSetMessage)(nil)
+	_ ProtocolMessage = (*ListChannelRolesMessage)(nil)
+	_ ProtocolMessage = (*ChannelRoleListMessage)(nil)
+	_ ProtocolMessage = (*SetChannelGrantMessage)(nil)
+	_ ProtocolMessage = (*ChannelGrantSetMessage)(nil)
+	_ ProtocolMessage = (*ChannelMemberMessage)(nil)
+	_ ProtocolMessage = (*LeavePrivateChannelMessage)(nil)
+	_ ProtocolMessage = (*ListPrivateChannelsMessage)(nil)
+	_ ProtocolMessage = (*ChannelMembershipChangedMessage)(nil)
+	_ ProtocolMessage = (*ChannelInvitedMessage)(nil)
+	_ ProtocolMessage = (*RemovedFromChannelMessage)(nil)
+	_ ProtocolMessage = (*DistributeChannelKeyMessage)(nil)
+	_ ProtocolMessage = (*GetChannelKeysMessage)(nil)
+	_ ProtocolMessage = (*ChannelKeysMessage)(nil)
+	_ ProtocolMessage = (*ChannelRekeyRequiredMessage)(nil)
+	_ ProtocolMessage = (*StoreKeyBackupMessage)(nil)
+	_ ProtocolMessage = (*GetKeyBackupMessage)(nil)
+	_ ProtocolMessage = (*KeyBackupMessage)(nil)
+	_ ProtocolMessage = (*KeyBackupStoredMessage)(nil)
+	_ ProtocolMessage = (*RevokeDeviceMessage)(nil)
+	_ ProtocolMessage = (*DeviceRevokedMessage)(nil)
+	_ ProtocolMessage = (*ResumeSessionMessage)(nil)
+	_ ProtocolMessage = (*ResumeTokenMessage)(nil)
+	_ ProtocolMessage = (*ListSessionsMessage)(nil)
+	_ ProtocolMessage = (*SessionListMessage)(nil)
+	_ ProtocolMessage = (*RevokeSessionMessage)(nil)
+	_ ProtocolMessage = (*SessionRevokedMessage)(nil)
+	_ ProtocolMessage = (*AuthTOTPMessage)(nil)
+	_ ProtocolMessage = (*SetupTOTPMessage)(nil)
+	_ ProtocolMessage = (*TOTPSetupMessage)(nil)
+	_ ProtocolMessage = (*ConfirmTOTPMessage)(nil)
+	_ ProtocolMessage = (*TOTPEnabledMessage)(nil)
+	_ ProtocolMessage = (*DisableTOTPMessage)(nil)
+	_ ProtocolMessage = (*TOTPDisabledMessage)(nil)
 )
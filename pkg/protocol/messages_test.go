@@ -872,16 +872,18 @@ func TestMessageListMessage(t *testing.T) {
 				ParentID:     &parentID,
 				Messages: []Message{
 					{
-						ID:             1,
-						ChannelID:      1,
-						SubchannelID:   &subchannelID,
-						ParentID:       nil,
-						AuthorUserID:   &authorUserID,
-						AuthorNickname: "alice",
-						Content:        "Root message",
-						CreatedAt:      now,
-						EditedAt:       nil,
-						ReplyCount:     2,
+						ID:               1,
+						ChannelID:        1,
+						SubchannelID:     &subchannelID,
+						ParentID:         nil,
+						AuthorUserID:     &authorUserID,
+						AuthorNickname:   "alice",
+						Content:          "Root message",
+						CreatedAt:        now,
+						EditedAt:         nil,
+						ReplyCount:       2,
+						PlusOneCount:     42,
+						PlusOneAnonCount: 1337,
 					},
 					{
 						ID:             2,
@@ -919,6 +921,8 @@ func TestMessageListMessage(t *testing.T) {
 				assert.Equal(t, msg.AuthorNickname, dec.AuthorNickname)
 				assert.Equal(t, msg.Content, dec.Content)
 				assert.Equal(t, msg.ReplyCount, dec.ReplyCount)
+				assert.Equal(t, msg.PlusOneCount, dec.PlusOneCount)
+				assert.Equal(t, msg.PlusOneAnonCount, dec.PlusOneAnonCount)
 
 				assert.InDelta(t, msg.CreatedAt.UnixMilli(), dec.CreatedAt.UnixMilli(), 1)
 
@@ -2468,6 +2472,14 @@ func TestStartDMMessage(t *testing.T) {
 				AllowUnencrypted: true,
 			},
 		},
+		{
+			name: "ratchet supported",
+			msg: StartDMMessage{
+				TargetType:   DMTargetByUserID,
+				TargetUserID: 12345,
+				Ratchet:      true,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -2481,6 +2493,7 @@ func TestStartDMMessage(t *testing.T) {
 
 			assert.Equal(t, tt.msg.TargetType, decoded.TargetType)
 			assert.Equal(t, tt.msg.AllowUnencrypted, decoded.AllowUnencrypted)
+			assert.Equal(t, tt.msg.Ratchet, decoded.Ratchet)
 
 			switch tt.msg.TargetType {
 			case DMTargetByUserID, DMTargetBySessionID:
@@ -2521,6 +2534,14 @@ func TestProvidePublicKeyMessage(t *testing.T) {
 				Label:     "session-only",
 			},
 		},
+		{
+			name: "ratchet supported",
+			msg: ProvidePublicKeyMessage{
+				KeyType:   KeyTypeGenerated,
+				PublicKey: [32]byte{7},
+				Ratchet:   true,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -2535,6 +2556,7 @@ func TestProvidePublicKeyMessage(t *testing.T) {
 			assert.Equal(t, tt.msg.KeyType, decoded.KeyType)
 			assert.Equal(t, tt.msg.PublicKey, decoded.PublicKey)
 			assert.Equal(t, tt.msg.Label, decoded.Label)
+			assert.Equal(t, tt.msg.Ratchet, decoded.Ratchet)
 		})
 	}
 }
@@ -2667,6 +2689,17 @@ func TestDMReadyMessage(t *testing.T) {
 				// OtherPublicKey is not sent for unencrypted DMs
 			},
 		},
+		{
+			name: "ratcheted DM",
+			msg: DMReadyMessage{
+				ChannelID:      777,
+				OtherUserID:    &userID,
+				OtherNickname:  "carol",
+				IsEncrypted:    true,
+				OtherPublicKey: [32]byte{9},
+				Ratchet:        true,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -2681,6 +2714,7 @@ func TestDMReadyMessage(t *testing.T) {
 			assert.Equal(t, tt.msg.ChannelID, decoded.ChannelID)
 			assert.Equal(t, tt.msg.OtherNickname, decoded.OtherNickname)
 			assert.Equal(t, tt.msg.IsEncrypted, decoded.IsEncrypted)
+			assert.Equal(t, tt.msg.Ratchet, decoded.Ratchet)
 
 			if tt.msg.OtherUserID != nil {
 				require.NotNil(t, decoded.OtherUserID)
@@ -2696,6 +2730,55 @@ func TestDMReadyMessage(t *testing.T) {
 	}
 }
 
+func TestRatchetFlagBackwardsCompatibility(t *testing.T) {
+	// Older peers don't send the trailing ratchet flag; it defaults to false
+	tests := []struct {
+		name    string
+		msg     ProtocolMessage
+		ratchet func(payload []byte) (bool, error)
+	}{
+		{
+			name: "START_DM",
+			msg:  &StartDMMessage{TargetType: DMTargetByNickname, TargetNickname: "alice", Ratchet: true},
+			ratchet: func(payload []byte) (bool, error) {
+				decoded := &StartDMMessage{}
+				err := decoded.Decode(payload)
+				return decoded.Ratchet, err
+			},
+		},
+		{
+			name: "PROVIDE_PUBLIC_KEY",
+			msg:  &ProvidePublicKeyMessage{KeyType: KeyTypeGenerated, Label: "laptop", Ratchet: true},
+			ratchet: func(payload []byte) (bool, error) {
+				decoded := &ProvidePublicKeyMessage{}
+				err := decoded.Decode(payload)
+				return decoded.Ratchet, err
+			},
+		},
+		{
+			name: "DM_READY",
+			msg:  &DMReadyMessage{ChannelID: 1, OtherNickname: "bob", IsEncrypted: true, Ratchet: true},
+			ratchet: func(payload []byte) (bool, error) {
+				decoded := &DMReadyMessage{}
+				err := decoded.Decode(payload)
+				return decoded.Ratchet, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			// Without the trailing ratchet and multi-device flags
+			ratchet, err := tt.ratchet(payload[:len(payload)-2])
+			require.NoError(t, err)
+			assert.False(t, ratchet, "ratchet should default to false for backwards compatibility")
+		})
+	}
+}
+
 func TestDMPendingMessage(t *testing.T) {
 	userID := uint64(42)
 	tests := []struct {
@@ -2881,3 +2964,681 @@ func TestDMDeclinedMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchMessagesMessage(t *testing.T) {
+	channelID := uint64(1)
+	threadID := uint64(500)
+	author := "alice"
+	after := time.UnixMilli(1700000000000)
+	before := time.UnixMilli(1700003600000)
+	beforeID := uint64(999)
+
+	tests := []struct {
+		name string
+		msg  SearchMessagesMessage
+	}{
+		{
+			name: "query only",
+			msg: SearchMessagesMessage{
+				Query: "release plan",
+				Limit: 50,
+			},
+		},
+		{
+			name: "all filters",
+			msg: SearchMessagesMessage{
+				Query:     "decision",
+				ChannelID: &channelID,
+				ThreadID:  &threadID,
+				Author:    &author,
+				After:     &after,
+				Before:    &before,
+				BeforeID:  &beforeID,
+				Limit:     20,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &SearchMessagesMessage{}
+			err = decoded.Decode(payload)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.msg.Query, decoded.Query)
+			assert.Equal(t, tt.msg.ChannelID, decoded.ChannelID)
+			assert.Equal(t, tt.msg.SubchannelID, decoded.SubchannelID)
+			assert.Equal(t, tt.msg.ThreadID, decoded.ThreadID)
+			assert.Equal(t, tt.msg.Author, decoded.Author)
+			assert.Equal(t, tt.msg.BeforeID, decoded.BeforeID)
+			assert.Equal(t, tt.msg.Limit, decoded.Limit)
+			if tt.msg.After != nil {
+				require.NotNil(t, decoded.After)
+				assert.Equal(t, tt.msg.After.UnixMilli(), decoded.After.UnixMilli())
+			} else {
+				assert.Nil(t, decoded.After)
+			}
+			if tt.msg.Before != nil {
+				require.NotNil(t, decoded.Before)
+				assert.Equal(t, tt.msg.Before.UnixMilli(), decoded.Before.UnixMilli())
+			} else {
+				assert.Nil(t, decoded.Before)
+			}
+		})
+	}
+}
+
+func TestSearchResultsMessage(t *testing.T) {
+	rootID := uint64(100)
+	userID := uint64(7)
+
+	root := Message{
+		ID:             100,
+		ChannelID:      1,
+		AuthorNickname: "~bob",
+		Content:        "We need a decision on the release",
+		CreatedAt:      time.UnixMilli(1699999000000),
+		ReplyCount:     1,
+	}
+
+	msg := SearchResultsMessage{
+		Query: "decision",
+		Results: []SearchResult{
+			{
+				Message: Message{
+					ID:             101,
+					ChannelID:      1,
+					ParentID:       &rootID,
+					AuthorUserID:   &userID,
+					AuthorNickname: "alice",
+					Content:        "The decision was to ship on Friday",
+					CreatedAt:      time.UnixMilli(1700000000000),
+				},
+				ThreadRoot: &root,
+			},
+			{
+				Message: root,
+			},
+		},
+		HasMore: true,
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &SearchResultsMessage{}
+	require.NoError(t, decoded.Decode(payload))
+
+	assert.Equal(t, msg.Query, decoded.Query)
+	assert.True(t, decoded.HasMore)
+	require.Len(t, decoded.Results, 2)
+	assert.Equal(t, uint64(101), decoded.Results[0].Message.ID)
+	assert.Equal(t, msg.Results[0].Message.Content, decoded.Results[0].Message.Content)
+	require.NotNil(t, decoded.Results[0].ThreadRoot)
+	assert.Equal(t, rootID, decoded.Results[0].ThreadRoot.ID)
+	assert.Equal(t, root.Content, decoded.Results[0].ThreadRoot.Content)
+	assert.Nil(t, decoded.Results[1].ThreadRoot)
+	assert.Equal(t, uint32(1), decoded.Results[1].Message.ReplyCount)
+}
+
+func TestPlusOneMessage(t *testing.T) {
+	msg := PlusOneMessage{MessageID: 12345}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &PlusOneMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, *decoded)
+
+	assert.Error(t, decoded.Decode([]byte{0x01}))
+}
+
+func TestPlusOneUpdateMessage(t *testing.T) {
+	msg := PlusOneUpdateMessage{
+		MessageID:        12345,
+		PlusOneCount:     42,
+		PlusOneAnonCount: 1337,
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+	assert.Len(t, payload, 16)
+
+	decoded := &PlusOneUpdateMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, *decoded)
+
+	assert.Error(t, decoded.Decode(payload[:12]))
+}
+
+func TestListMentionsMessage(t *testing.T) {
+	beforeID := uint64(500)
+	for _, msg := range []ListMentionsMessage{
+		{Limit: 0},
+		{BeforeID: &beforeID, Limit: 25},
+	} {
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &ListMentionsMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, *decoded)
+	}
+}
+
+func TestMentionNotificationMessage(t *testing.T) {
+	rootID := uint64(100)
+	root := Message{ID: rootID, ChannelID: 1, AuthorNickname: "bob", Content: "Release plan", CreatedAt: time.UnixMilli(1699999000000)}
+	msg := MentionNotificationMessage{
+		Mention: SearchResult{
+			Message: Message{
+				ID:             101,
+				ChannelID:      1,
+				ParentID:       &rootID,
+				AuthorNickname: "~carol",
+				Content:        "@alice can you review?",
+				CreatedAt:      time.UnixMilli(1700000000000),
+			},
+			ThreadRoot: &root,
+		},
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &MentionNotificationMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg.Mention.Message.Content, decoded.Mention.Message.Content)
+	require.NotNil(t, decoded.Mention.ThreadRoot)
+	assert.Equal(t, rootID, decoded.Mention.ThreadRoot.ID)
+}
+
+func TestMentionListMessage(t *testing.T) {
+	msg := MentionListMessage{
+		Mentions: []SearchResult{
+			{Message: Message{ID: 2, ChannelID: 1, AuthorNickname: "bob", Content: "@alice hi", CreatedAt: time.UnixMilli(1700000000000)}},
+			{Message: Message{ID: 1, ChannelID: 1, AuthorNickname: "bob", Content: "@alice hello", CreatedAt: time.UnixMilli(1699999000000)}},
+		},
+		HasMore: true,
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &MentionListMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	require.Len(t, decoded.Mentions, 2)
+	assert.Equal(t, uint64(2), decoded.Mentions[0].Message.ID)
+	assert.Nil(t, decoded.Mentions[0].ThreadRoot)
+	assert.True(t, decoded.HasMore)
+
+	empty := MentionListMessage{}
+	payload, err = empty.Encode()
+	require.NoError(t, err)
+	require.NoError(t, decoded.Decode(payload))
+	assert.Empty(t, decoded.Mentions)
+	assert.False(t, decoded.HasMore)
+}
+
+func TestCreateIncomingWebhookMessage(t *testing.T) {
+	subchannelID := uint64(7)
+	parentID := uint64(42)
+	for _, msg := range []CreateIncomingWebhookMessage{
+		{Name: "deploy-bot", ChannelID: 1},
+		{Name: "ci", ChannelID: 1, SubchannelID: &subchannelID, ParentID: &parentID},
+	} {
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &CreateIncomingWebhookMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, *decoded)
+	}
+}
+
+func TestIncomingWebhookCreatedMessage(t *testing.T) {
+	for _, msg := range []IncomingWebhookCreatedMessage{
+		{Success: true, WebhookID: 3, Token: "abc123", Message: "Webhook created"},
+		{Success: false, Message: "Channel not found"},
+	} {
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &IncomingWebhookCreatedMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, *decoded)
+	}
+}
+
+func TestIncomingWebhookListMessage(t *testing.T) {
+	parentID := uint64(42)
+	lastUsed := int64(1700000500000)
+	msg := IncomingWebhookListMessage{
+		Webhooks: []IncomingWebhookEntry{
+			{ID: 1, Name: "deploy-bot", ChannelID: 1, ChannelName: "general", CreatedBy: "admin", CreatedAt: 1700000000000},
+			{ID: 2, Name: "ci", ChannelID: 2, ChannelName: "dev", ParentID: &parentID, CreatedBy: "admin", CreatedAt: 1700000000000, LastUsedAt: &lastUsed},
+		},
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &IncomingWebhookListMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, *decoded)
+}
+
+func TestRevokeIncomingWebhookMessages(t *testing.T) {
+	revoke := RevokeIncomingWebhookMessage{WebhookID: 9}
+	payload, err := revoke.Encode()
+	require.NoError(t, err)
+	decodedRevoke := &RevokeIncomingWebhookMessage{}
+	require.NoError(t, decodedRevoke.Decode(payload))
+	assert.Equal(t, revoke, *decodedRevoke)
+
+	revoked := IncomingWebhookRevokedMessage{Success: true, WebhookID: 9, Message: "Webhook revoked"}
+	payload, err = revoked.Encode()
+	require.NoError(t, err)
+	decodedRevoked := &IncomingWebhookRevokedMessage{}
+	require.NoError(t, decodedRevoked.Decode(payload))
+	assert.Equal(t, revoked, *decodedRevoked)
+}
+
+func TestMessageHistoryMessages(t *testing.T) {
+	req := GetMessageHistoryMessage{MessageID: 42}
+	payload, err := req.Encode()
+	require.NoError(t, err)
+	decodedReq := &GetMessageHistoryMessage{}
+	require.NoError(t, decodedReq.Decode(payload))
+	assert.Equal(t, req, *decodedReq)
+
+	for _, msg := range []MessageHistoryMessage{
+		{Success: true, MessageID: 42, Revisions: []MessageRevision{
+			{Kind: RevisionCreated, Timestamp: time.UnixMilli(1700000000000), Content: "helo"},
+			{Kind: RevisionEdited, Timestamp: time.UnixMilli(1700000060000), Content: "hello"},
+			{Kind: RevisionDeleted, Timestamp: time.UnixMilli(1700000120000), Hidden: true},
+		}},
+		{Success: false, MessageID: 7, Message: "Message not found"},
+	} {
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &MessageHistoryMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg.Success, decoded.Success)
+		assert.Equal(t, msg.MessageID, decoded.MessageID)
+		assert.Equal(t, msg.Message, decoded.Message)
+		require.Len(t, decoded.Revisions, len(msg.Revisions))
+		for i, rev := range msg.Revisions {
+			assert.Equal(t, rev.Kind, decoded.Revisions[i].Kind)
+			assert.True(t, rev.Timestamp.Equal(decoded.Revisions[i].Timestamp))
+			assert.Equal(t, rev.Content, decoded.Revisions[i].Content)
+			assert.Equal(t, rev.Hidden, decoded.Revisions[i].Hidden)
+		}
+	}
+}
+
+func TestUpdateChannelMessages(t *testing.T) {
+	displayName := "Announcements"
+	archived := true
+	for _, req := range []UpdateChannelMessage{
+		{ChannelID: 3},
+		{ChannelID: 3, DisplayName: &displayName, Archived: &archived},
+	} {
+		payload, err := req.Encode()
+		require.NoError(t, err)
+		decoded := &UpdateChannelMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, req, *decoded)
+	}
+
+	for _, msg := range []ChannelUpdatedMessage{
+		{Success: true, ChannelID: 3, Name: "news", DisplayName: "Announcements", Description: "Read only", Type: 1, RetentionHours: 8760, Archived: true, Message: "Channel 'Announcements' updated"},
+		{Success: false, ChannelID: 3, Message: "Channel not found"},
+	} {
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		decoded := &ChannelUpdatedMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, *decoded)
+	}
+
+	// The archived flag round-trips through CHANNEL_LIST
+	list := ChannelListMessage{Channels: []Channel{{ID: 3, Name: "news", Archived: true}, {ID: 4, Name: "general"}}}
+	payload, err := list.Encode()
+	require.NoError(t, err)
+	decodedList := &ChannelListMessage{}
+	require.NoError(t, decodedList.Decode(payload))
+	assert.True(t, decodedList.Channels[0].Archived)
+	assert.False(t, decodedList.Channels[1].Archived)
+}
+
+func TestAdminActionMessages(t *testing.T) {
+	since := int64(1700000000000)
+	beforeID := uint64(42)
+	for _, req := range []ListAdminActionsMessage{
+		{},
+		{AdminNickname: "alice", ActionType: "ban_user", Since: &since, BeforeID: &beforeID, Limit: 50},
+	} {
+		payload, err := req.Encode()
+		require.NoError(t, err)
+		decoded := &ListAdminActionsMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, req, *decoded)
+	}
+
+	adminUserID := uint64(1)
+	targetID := uint64(9)
+	ip := "10.0.0.1"
+	list := AdminActionListMessage{
+		Actions: []AdminActionEntry{
+			{ID: 2, AdminUserID: &adminUserID, AdminNickname: "alice", ActionType: "DELETE_CHANNEL", Details: "Deleted channel #old", PerformedAt: since},
+			{ID: 1, AdminNickname: "alice", ActionType: "ban_user", TargetType: "user", TargetID: &targetID, TargetIdentifier: "mallory", Details: "spam", PerformedAt: since - 1000, IPAddress: &ip},
+		},
+		HasMore: true,
+	}
+	payload, err := list.Encode()
+	require.NoError(t, err)
+	decoded := &AdminActionListMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, list, *decoded)
+}
+
+func TestKickAndMuteMessages(t *testing.T) {
+	userID := uint64(7)
+	nickname := "mallory"
+	channelID := uint64(3)
+	hour := uint64(3600)
+
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	roundTrip(&KickUserMessage{UserID: &userID, Reason: "cool off"}, &KickUserMessage{})
+	roundTrip(&KickUserMessage{Nickname: &nickname}, &KickUserMessage{})
+	roundTrip(&UserKickedMessage{Success: true, SessionsDisconnected: 2, Message: "User mallory kicked"}, &UserKickedMessage{})
+
+	roundTrip(&MuteUserMessage{Nickname: &nickname, ChannelID: &channelID, Reason: "flooding", DurationSeconds: &hour}, &MuteUserMessage{})
+	roundTrip(&MuteUserMessage{UserID: &userID, Reason: "spam"}, &MuteUserMessage{})
+	roundTrip(&UserMutedMessage{Success: true, MuteID: 4, Message: "User mallory muted"}, &UserMutedMessage{})
+	roundTrip(&UserMutedMessage{Success: false, Message: "User not found"}, &UserMutedMessage{})
+
+	roundTrip(&UnmuteUserMessage{Nickname: &nickname, ChannelID: &channelID}, &UnmuteUserMessage{})
+	roundTrip(&UserUnmutedMessage{Success: true, Message: "User mallory unmuted"}, &UserUnmutedMessage{})
+}
+
+func TestChannelRoleMessages(t *testing.T) {
+	userID := uint64(7)
+	nickname := "bob"
+
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	roundTrip(&SetChannelRoleMessage{ChannelID: 3, Nickname: &nickname, Role: ChannelRoleModerator}, &SetChannelRoleMessage{})
+	roundTrip(&SetChannelRoleMessage{ChannelID: 3, UserID: &userID, Role: ChannelRoleMember}, &SetChannelRoleMessage{})
+	roundTrip(&ChannelRoleSetMessage{Success: true, ChannelID: 3, Message: "bob is now a moderator of #general"}, &ChannelRoleSetMessage{})
+	roundTrip(&ListChannelRolesMessage{ChannelID: 3}, &ListChannelRolesMessage{})
+	roundTrip(&ChannelRoleListMessage{
+		ChannelID: 3,
+		Roles: []ChannelRoleEntry{
+			{UserID: 1, Nickname: "alice", Role: ChannelRoleOwner, GrantedAt: 1700000000000},
+			{UserID: 7, Nickname: "bob", Role: ChannelRoleModerator, GrantedBy: "alice", GrantedAt: 1700000001000},
+		},
+	}, &ChannelRoleListMessage{})
+	roundTrip(&ChannelRoleListMessage{ChannelID: 3, Roles: []ChannelRoleEntry{}}, &ChannelRoleListMessage{})
+}
+
+func TestChannelModeMessages(t *testing.T) {
+	userID := uint64(7)
+	nickname := "bob"
+	modes := uint8(ChannelModeModerated | ChannelModeInviteOnly)
+
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	roundTrip(&UpdateChannelMessage{ChannelID: 3, Modes: &modes}, &UpdateChannelMessage{})
+	roundTrip(&ChannelUpdatedMessage{Success: true, ChannelID: 3, Name: "news", Modes: ChannelModeReadOnly, Message: "Channel 'news' updated"}, &ChannelUpdatedMessage{})
+	roundTrip(&ChannelListMessage{Channels: []Channel{{ID: 3, Name: "staff", Modes: ChannelModeInviteOnly | ChannelModeRegisteredOnly}}}, &ChannelListMessage{})
+	roundTrip(&SetChannelGrantMessage{ChannelID: 3, Nickname: &nickname, Grant: ChannelGrantVoice, Granted: true}, &SetChannelGrantMessage{})
+	roundTrip(&SetChannelGrantMessage{ChannelID: 3, UserID: &userID, Grant: ChannelGrantInvite}, &SetChannelGrantMessage{})
+	roundTrip(&ChannelGrantSetMessage{Success: true, ChannelID: 3, Message: "bob is now invited to #staff"}, &ChannelGrantSetMessage{})
+}
+
+func TestPrivateChannelMessages(t *testing.T) {
+	userID := uint64(7)
+	nickname := "bob"
+
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	roundTrip(&CreateChannelMessage{Name: "team", DisplayName: "#team", ChannelType: 1, RetentionHours: 168, Private: true}, &CreateChannelMessage{})
+	roundTrip(&ChannelMemberMessage{ChannelID: 3, Nickname: &nickname}, &ChannelMemberMessage{})
+	roundTrip(&ChannelMemberMessage{ChannelID: 3, UserID: &userID}, &ChannelMemberMessage{})
+	roundTrip(&LeavePrivateChannelMessage{ChannelID: 3}, &LeavePrivateChannelMessage{})
+	roundTrip(&ListPrivateChannelsMessage{}, &ListPrivateChannelsMessage{})
+	roundTrip(&ChannelMembershipChangedMessage{Success: true, ChannelID: 3, Message: "bob was added to #team"}, &ChannelMembershipChangedMessage{})
+	roundTrip(&ChannelInvitedMessage{ChannelID: 3, Name: "team", Description: "Team chat", Type: 1, RetentionHours: 168, InvitedBy: "alice"}, &ChannelInvitedMessage{})
+	roundTrip(&RemovedFromChannelMessage{ChannelID: 3, Message: "You were removed from #team by alice"}, &RemovedFromChannelMessage{})
+
+	// Clients that predate private channels don't send the flag
+	legacy, err := (&CreateChannelMessage{Name: "general", DisplayName: "#general", ChannelType: 1, RetentionHours: 168}).Encode()
+	require.NoError(t, err)
+	decoded := &CreateChannelMessage{}
+	require.NoError(t, decoded.Decode(legacy[:len(legacy)-1]))
+	assert.False(t, decoded.Private)
+	assert.Equal(t, uint32(168), decoded.RetentionHours)
+}
+
+func TestChannelKeyMessages(t *testing.T) {
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	var wrapped [WrappedChannelKeySize]byte
+	for i := range wrapped {
+		wrapped[i] = byte(i)
+	}
+	var publicKey [32]byte
+	publicKey[0] = 9
+
+	roundTrip(&DistributeChannelKeyMessage{ChannelID: 3, Epoch: 2, Keys: []WrappedChannelKey{{UserID: 1, WrappedKey: wrapped}, {UserID: 7, WrappedKey: wrapped}}}, &DistributeChannelKeyMessage{})
+	roundTrip(&GetChannelKeysMessage{ChannelID: 3}, &GetChannelKeysMessage{})
+	roundTrip(&ChannelKeysMessage{ChannelID: 3, Keys: []ChannelKeyEntry{{Epoch: 1, WrappedKey: wrapped}, {Epoch: 2, WrappedKey: wrapped}}}, &ChannelKeysMessage{})
+	roundTrip(&ChannelKeysMessage{ChannelID: 3, Keys: []ChannelKeyEntry{}}, &ChannelKeysMessage{})
+	roundTrip(&ChannelRekeyRequiredMessage{ChannelID: 3, Epoch: 2, Members: []ChannelKeyMember{{UserID: 1, Nickname: "alice", PublicKey: publicKey}}}, &ChannelRekeyRequiredMessage{})
+
+	// A truncated wrapped key is rejected
+	payload, err := (&DistributeChannelKeyMessage{ChannelID: 3, Epoch: 1, Keys: []WrappedChannelKey{{UserID: 1, WrappedKey: wrapped}}}).Encode()
+	require.NoError(t, err)
+	assert.Error(t, (&DistributeChannelKeyMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestKeyBackupMessages(t *testing.T) {
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	updatedAt := time.UnixMilli(1700000000000)
+	backup := []byte("SCKB opaque backup")
+
+	roundTrip(&StoreKeyBackupMessage{Backup: backup}, &StoreKeyBackupMessage{})
+	roundTrip(&StoreKeyBackupMessage{Backup: []byte{}}, &StoreKeyBackupMessage{})
+	roundTrip(&GetKeyBackupMessage{}, &GetKeyBackupMessage{})
+	roundTrip(&KeyBackupMessage{Backup: backup, UpdatedAt: &updatedAt}, &KeyBackupMessage{})
+	roundTrip(&KeyBackupMessage{Backup: []byte{}}, &KeyBackupMessage{})
+	roundTrip(&KeyBackupStoredMessage{UpdatedAt: &updatedAt}, &KeyBackupStoredMessage{})
+	roundTrip(&KeyBackupStoredMessage{}, &KeyBackupStoredMessage{})
+
+	// Backups are limited in size both ways
+	_, err := (&StoreKeyBackupMessage{Backup: make([]byte, MaxKeyBackupSize+1)}).Encode()
+	assert.ErrorIs(t, err, ErrKeyBackupTooLarge)
+	payload := []byte{0x00, 0x01, 0x00, 0x01} // Length 65537
+	assert.ErrorIs(t, (&StoreKeyBackupMessage{}).Decode(payload), ErrKeyBackupTooLarge)
+
+	// A truncated backup is rejected
+	payload, err = (&StoreKeyBackupMessage{Backup: backup}).Encode()
+	require.NoError(t, err)
+	assert.Error(t, (&StoreKeyBackupMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestDeviceMessages(t *testing.T) {
+	userID := uint64(42)
+	info := &UserInfoMessage{
+		Nickname:     "alice",
+		IsRegistered: true,
+		UserID:       &userID,
+		Online:       true,
+		Devices: []DeviceInfo{
+			{DeviceID: 1, PublicKey: [32]byte{1}, Label: "laptop", LastSeenAt: 1700000000000},
+			{DeviceID: 3, PublicKey: [32]byte{3}, LastSeenAt: 1700000001000},
+		},
+	}
+	payload, err := info.Encode()
+	require.NoError(t, err)
+	decoded := &UserInfoMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, info, decoded)
+
+	// Older servers don't send devices
+	decoded = &UserInfoMessage{}
+	require.NoError(t, decoded.Decode(payload[:len("alice")+2+1+9+1]))
+	assert.Empty(t, decoded.Devices)
+	assert.True(t, decoded.Online)
+
+	revoke := &RevokeDeviceMessage{DeviceID: 3}
+	payload, err = revoke.Encode()
+	require.NoError(t, err)
+	decodedRevoke := &RevokeDeviceMessage{}
+	require.NoError(t, decodedRevoke.Decode(payload))
+	assert.Equal(t, revoke, decodedRevoke)
+
+	revoked := &DeviceRevokedMessage{DeviceID: 3}
+	payload, err = revoked.Encode()
+	require.NoError(t, err)
+	decodedRevoked := &DeviceRevokedMessage{}
+	require.NoError(t, decodedRevoked.Decode(payload))
+	assert.Equal(t, revoked, decodedRevoked)
+
+	// The multi-device flag follows the ratchet flag and defaults to false
+	ready := &DMReadyMessage{ChannelID: 1, OtherNickname: "bob", IsEncrypted: true, Ratchet: true, MultiDevice: true}
+	payload, err = ready.Encode()
+	require.NoError(t, err)
+	decodedReady := &DMReadyMessage{}
+	require.NoError(t, decodedReady.Decode(payload))
+	assert.True(t, decodedReady.MultiDevice)
+	decodedReady = &DMReadyMessage{}
+	require.NoError(t, decodedReady.Decode(payload[:len(payload)-1]))
+	assert.True(t, decodedReady.Ratchet)
+	assert.False(t, decodedReady.MultiDevice)
+
+	provide := &ProvidePublicKeyMessage{KeyType: KeyTypeGenerated, Ratchet: true, MultiDevice: true}
+	payload, err = provide.Encode()
+	require.NoError(t, err)
+	decodedProvide := &ProvidePublicKeyMessage{}
+	require.NoError(t, decodedProvide.Decode(payload))
+	assert.Equal(t, provide, decodedProvide)
+
+	start := &StartDMMessage{TargetType: DMTargetByNickname, TargetNickname: "bob", MultiDevice: true}
+	payload, err = start.Encode()
+	require.NoError(t, err)
+	decodedStart := &StartDMMessage{}
+	require.NoError(t, decodedStart.Decode(payload))
+	assert.Equal(t, start, decodedStart)
+}
+
+func TestResumeSessionMessages(t *testing.T) {
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	roundTrip(&AuthRequestMessage{Nickname: "alice", Password: "hash", RequestToken: true}, &AuthRequestMessage{})
+	roundTrip(&ResumeSessionMessage{Token: "c2VjcmV0"}, &ResumeSessionMessage{})
+	roundTrip(&ResumeTokenMessage{SessionID: 7, Token: "c2VjcmV0"}, &ResumeTokenMessage{})
+	roundTrip(&ListSessionsMessage{}, &ListSessionsMessage{})
+	roundTrip(&SessionListMessage{Sessions: []LoginSessionInfo{
+		{SessionID: 7, Label: "192.0.2.1", CreatedAt: 1700000000000, LastUsedAt: 1700000001000, Current: true, Online: true},
+		{SessionID: 9, CreatedAt: 1700000002000, LastUsedAt: 1700000002000},
+	}}, &SessionListMessage{})
+	roundTrip(&RevokeSessionMessage{SessionID: 9}, &RevokeSessionMessage{})
+	roundTrip(&SessionRevokedMessage{SessionID: 9}, &SessionRevokedMessage{})
+
+	// Older clients don't ask for a token
+	payload, err := (&AuthRequestMessage{Nickname: "alice", Password: "hash", RequestToken: true}).Encode()
+	require.NoError(t, err)
+	decoded := &AuthRequestMessage{}
+	require.NoError(t, decoded.Decode(payload[:len(payload)-1]))
+	assert.Equal(t, "hash", decoded.Password)
+	assert.False(t, decoded.RequestToken)
+
+	// A truncated session list is rejected
+	payload, err = (&SessionListMessage{Sessions: []LoginSessionInfo{{SessionID: 7}}}).Encode()
+	require.NoError(t, err)
+	assert.Error(t, (&SessionListMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestTOTPMessages(t *testing.T) {
+	roundTrip := func(msg, decoded ProtocolMessage) {
+		t.Helper()
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	}
+
+	roundTrip(&AuthResponseMessage{Message: "Enter your two-factor code", Challenge: AuthChallengeTOTP}, &AuthResponseMessage{})
+	roundTrip(&AuthTOTPMessage{Code: "123456"}, &AuthTOTPMessage{})
+	roundTrip(&SetupTOTPMessage{}, &SetupTOTPMessage{})
+	roundTrip(&TOTPSetupMessage{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/superchat:alice?secret=JBSWY3DPEHPK3PXP"}, &TOTPSetupMessage{})
+	roundTrip(&ConfirmTOTPMessage{Code: "123456"}, &ConfirmTOTPMessage{})
+	roundTrip(&TOTPEnabledMessage{RecoveryCodes: []string{"abcde-fghij", "klmno-pqrst"}}, &TOTPEnabledMessage{})
+	roundTrip(&DisableTOTPMessage{Code: "abcde-fghij"}, &DisableTOTPMessage{})
+	roundTrip(&TOTPDisabledMessage{}, &TOTPDisabledMessage{})
+
+	// Older servers don't send a challenge
+	payload, err := (&AuthResponseMessage{Message: "Invalid credentials", Challenge: AuthChallengeTOTP}).Encode()
+	require.NoError(t, err)
+	decoded := &AuthResponseMessage{Challenge: AuthChallengeTOTPSetup}
+	require.NoError(t, decoded.Decode(payload[:len(payload)-1]))
+	assert.Equal(t, "Invalid credentials", decoded.Message)
+	assert.Equal(t, uint8(AuthChallengeNone), decoded.Challenge)
+
+	// A truncated recovery code list is rejected
+	payload, err = (&TOTPEnabledMessage{RecoveryCodes: []string{"abcde-fghij"}}).Encode()
+	require.NoError(t, err)
+	assert.Error(t, (&TOTPEnabledMessage{}).Decode(payload[:len(payload)-1]))
+}
@@ -282,3 +282,96 @@ func ReadOptionalInt64(r io.Reader) (*int64, error) {
 	}
 	return &value, nil
 }
+
+// WriteOptionalUint8 writes an optional 8-bit unsigned integer
+// Format: [Present (bool)][Value (uint8) if present]
+func WriteOptionalUint8(w io.Writer, v *uint8) error {
+	if v == nil {
+		return WriteBool(w, false)
+	}
+
+	if err := WriteBool(w, true); err != nil {
+		return err
+	}
+	return WriteUint8(w, *v)
+}
+
+// ReadOptionalUint8 reads an optional 8-bit unsigned integer
+func ReadOptionalUint8(r io.Reader) (*uint8, error) {
+	present, err := ReadBool(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !present {
+		return nil, nil
+	}
+
+	value, err := ReadUint8(r)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// WriteOptionalUint32 writes an optional 32-bit unsigned integer
+// Format: [Present (bool)][Value (uint32) if present]
+func WriteOptionalUint32(w io.Writer, v *uint32) error {
+	if v == nil {
+		return WriteBool(w, false)
+	}
+
+	if err := WriteBool(w, true); err != nil {
+		return err
+	}
+	return WriteUint32(w, *v)
+}
+
+// ReadOptionalUint32 reads an optional 32-bit unsigned integer
+func ReadOptionalUint32(r io.Reader) (*uint32, error) {
+	present, err := ReadBool(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !present {
+		return nil, nil
+	}
+
+	value, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// WriteOptionalBool writes an optional boolean
+// Format: [Present (bool)][Value (bool) if present]
+func WriteOptionalBool(w io.Writer, v *bool) error {
+	if v == nil {
+		return WriteBool(w, false)
+	}
+
+	if err := WriteBool(w, true); err != nil {
+		return err
+	}
+	return WriteBool(w, *v)
+}
+
+// ReadOptionalBool reads an optional boolean
+func ReadOptionalBool(r io.Reader) (*bool, error) {
+	present, err := ReadBool(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !present {
+		return nil, nil
+	}
+
+	value, err := ReadBool(r)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
@@ -257,6 +257,68 @@ func TestWriteReadOptionalUint64(t *testing.T) {
 	})
 }
 
+func TestWriteReadOptionalSmallValues(t *testing.T) {
+	t.Run("nil values", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+
+		require.NoError(t, WriteOptionalUint8(buf, nil))
+		require.NoError(t, WriteOptionalUint32(buf, nil))
+		require.NoError(t, WriteOptionalBool(buf, nil))
+
+		u8, err := ReadOptionalUint8(buf)
+		require.NoError(t, err)
+		assert.Nil(t, u8)
+		u32, err := ReadOptionalUint32(buf)
+		require.NoError(t, err)
+		assert.Nil(t, u32)
+		b, err := ReadOptionalBool(buf)
+		require.NoError(t, err)
+		assert.Nil(t, b)
+		assert.Equal(t, 0, buf.Len())
+	})
+
+	t.Run("zero values are present", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		u8, u32, b := uint8(0), uint32(0), false
+
+		require.NoError(t, WriteOptionalUint8(buf, &u8))
+		require.NoError(t, WriteOptionalUint32(buf, &u32))
+		require.NoError(t, WriteOptionalBool(buf, &b))
+
+		gotU8, err := ReadOptionalUint8(buf)
+		require.NoError(t, err)
+		require.NotNil(t, gotU8)
+		assert.Equal(t, u8, *gotU8)
+		gotU32, err := ReadOptionalUint32(buf)
+		require.NoError(t, err)
+		require.NotNil(t, gotU32)
+		assert.Equal(t, u32, *gotU32)
+		gotB, err := ReadOptionalBool(buf)
+		require.NoError(t, err)
+		require.NotNil(t, gotB)
+		assert.Equal(t, b, *gotB)
+	})
+
+	t.Run("present values", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		u8, u32, b := uint8(7), uint32(8760), true
+
+		require.NoError(t, WriteOptionalUint8(buf, &u8))
+		require.NoError(t, WriteOptionalUint32(buf, &u32))
+		require.NoError(t, WriteOptionalBool(buf, &b))
+
+		gotU8, err := ReadOptionalUint8(buf)
+		require.NoError(t, err)
+		assert.Equal(t, u8, *gotU8)
+		gotU32, err := ReadOptionalUint32(buf)
+		require.NoError(t, err)
+		assert.Equal(t, u32, *gotU32)
+		gotB, err := ReadOptionalBool(buf)
+		require.NoError(t, err)
+		assert.Equal(t, b, *gotB)
+	})
+}
+
 func TestWriteReadOptionalTimestamp(t *testing.T) {
 	t.Run("nil value", func(t *testing.T) {
 		buf := new(bytes.Buffer)
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// Private channel messages are end-to-end encrypted with a group key. The
+// server never sees the key: a member generates it, wraps it for every
+// member's X25519 public key and sends the wrapped copies in
+// DISTRIBUTE_CHANNEL_KEY. The server stores them by epoch and hands them out.
+//
+// Whenever the members with encryption keys no longer match the ones the
+// current epoch was wrapped for (someone joined, left, or changed their
+// key), the server sends CHANNEL_REKEY_REQUIRED to one online member, who
+// distributes the next epoch.
+
+// handleDistributeChannelKey handles DISTRIBUTE_CHANNEL_KEY
+func (s *Server) handleDistributeChannelKey(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.DistributeChannelKeyMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || !channel.IsPrivate || channel.IsDM {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Private channel not found")
+	}
+	if !s.canAccessPrivateChannel(sess, channel.ID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
+	epoch, members, _, err := s.channelKeyState(channel.ID)
+	if err != nil {
+		return s.dbError(sess, "channelKeyState", err)
+	}
+	if msg.Epoch != epoch+1 {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, fmt.Sprintf("Stale channel key: the next epoch is %d", epoch+1))
+	}
+
+	// The new key must reach every member with an encryption key, and nobody
+	// else
+	publicKeys := make(map[uint64][]byte, len(members))
+	for _, member := range members {
+		publicKeys[member.UserID] = member.PublicKey[:]
+	}
+	keys := make([]*database.ChannelKey, 0, len(msg.Keys))
+	for _, key := range msg.Keys {
+		publicKey, ok := publicKeys[key.UserID]
+		if !ok {
+			return s.sendError(sess, protocol.ErrCodeInvalidInput, "Channel keys must be wrapped for exactly the current members")
+		}
+		delete(publicKeys, key.UserID)
+		keys = append(keys, &database.ChannelKey{
+			UserID:     int64(key.UserID),
+			PublicKey:  publicKey,
+			WrappedKey: key.WrappedKey[:],
+		})
+	}
+	if len(publicKeys) > 0 {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Channel keys must be wrapped for exactly the current members")
+	}
+
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+
+	if err := s.db.SaveChannelKeys(channel.ID, msg.Epoch, nickname, keys); err != nil {
+		if errors.Is(err, database.ErrStaleChannelKeyEpoch) {
+			return s.sendError(sess, protocol.ErrCodeInvalidInput, "Stale channel key: another member rekeyed first")
+		}
+		return s.dbError(sess, "SaveChannelKeys", err)
+	}
+	log.Printf("%s distributed key epoch %d for #%s to %d members", nickname, msg.Epoch, channel.Name, len(keys))
+
+	for _, key := range msg.Keys {
+		s.sendToUserSessions(int64(key.UserID), protocol.TypeChannelKeys, &protocol.ChannelKeysMessage{
+			ChannelID: msg.ChannelID,
+			Keys:      []protocol.ChannelKeyEntry{{Epoch: msg.Epoch, WrappedKey: key.WrappedKey}},
+		})
+	}
+	return nil
+}
+
+// handleGetChannelKeys handles GET_CHANNEL_KEYS. Members get every key that
+// was wrapped for them; if the channel needs a new key, they may be asked to
+// make it.
+func (s *Server) handleGetChannelKeys(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.GetChannelKeysMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || !channel.IsPrivate || channel.IsDM {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Private channel not found")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil || !s.canAccessPrivateChannel(sess, channel.ID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
+	dbKeys, err := s.db.ListChannelKeysForUser(channel.ID, *userID)
+	if err != nil {
+		return s.dbError(sess, "ListChannelKeysForUser", err)
+	}
+	keys := make([]protocol.ChannelKeyEntry, 0, len(dbKeys))
+	for _, dbKey := range dbKeys {
+		entry := protocol.ChannelKeyEntry{Epoch: dbKey.Epoch}
+		if copy(entry.WrappedKey[:], dbKey.WrappedKey) != protocol.WrappedChannelKeySize {
+			log.Printf("Skipping malformed key epoch %d of channel %d for user %d", dbKey.Epoch, channel.ID, *userID)
+			continue
+		}
+		keys = append(keys, entry)
+	}
+
+	if err := s.sendMessage(sess, protocol.TypeChannelKeys, &protocol.ChannelKeysMessage{
+		ChannelID: msg.ChannelID,
+		Keys:      keys,
+	}); err != nil {
+		return err
+	}
+
+	s.requestChannelRekey(channel.ID, sess)
+	return nil
+}
+
+// channelKeyState returns a private channel's current key epoch, the members
+// a new key must be wrapped for (those with an encryption key), and whether
+// the current epoch is stale: missing, or wrapped for a different set of
+// members or public keys.
+func (s *Server) channelKeyState(channelID int64) (uint32, []protocol.ChannelKeyMember, bool, error) {
+	participants, err := s.db.GetChannelParticipants(channelID)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	members := make([]protocol.ChannelKeyMember, 0, len(participants))
+	for _, p := range participants {
+		if p.UserID == nil {
+			continue
+		}
+		publicKey, err := s.db.GetUserEncryptionKey(*p.UserID)
+		if err != nil || len(publicKey) != 32 {
+			continue
+		}
+		member := protocol.ChannelKeyMember{UserID: uint64(*p.UserID), Nickname: p.Nickname}
+		copy(member.PublicKey[:], publicKey)
+		members = append(members, member)
+	}
+
+	epoch, err := s.db.GetChannelKeyEpoch(channelID)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	if len(members) == 0 {
+		return epoch, members, false, nil
+	}
+	if epoch == 0 {
+		return epoch, members, true, nil
+	}
+
+	recipients, err := s.db.ListChannelKeyRecipients(channelID, epoch)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	if len(recipients) != len(members) {
+		return epoch, members, true, nil
+	}
+	wrappedFor := make(map[int64][]byte, len(recipients))
+	for _, r := range recipients {
+		wrappedFor[r.UserID] = r.PublicKey
+	}
+	for _, member := range members {
+		if !bytes.Equal(wrappedFor[int64(member.UserID)], member.PublicKey[:]) {
+			return epoch, members, true, nil
+		}
+	}
+	return epoch, members, false, nil
+}
+
+// requestChannelRekey asks one online member to distribute a new key if the
+// channel's current key is stale. The preferred session is asked if it
+// belongs to a member with an encryption key; otherwise any such member's
+// session is. If none is online, the next member to fetch their keys is
+// asked.
+func (s *Server) requestChannelRekey(channelID int64, preferred *Session) {
+	epoch, members, stale, err := s.channelKeyState(channelID)
+	if err != nil {
+		log.Printf("Failed to check the key of channel %d: %v", channelID, err)
+		return
+	}
+	if !stale {
+		return
+	}
+
+	isMember := func(sess *Session) bool {
+		sess.mu.RLock()
+		userID := sess.UserID
+		sess.mu.RUnlock()
+		if userID == nil {
+			return false
+		}
+		for _, member := range members {
+			if member.UserID == uint64(*userID) {
+				return true
+			}
+		}
+		return false
+	}
+
+	target := preferred
+	if target == nil || !isMember(target) {
+		target = nil
+		for _, other := range s.sessions.GetAllSessions() {
+			if isMember(other) {
+				target = other
+				break
+			}
+		}
+	}
+	if target == nil {
+		return
+	}
+
+	if err := s.sendMessage(target, protocol.TypeChannelRekeyRequired, &protocol.ChannelRekeyRequiredMessage{
+		ChannelID: uint64(channelID),
+		Epoch:     epoch + 1,
+		Members:   members,
+	}); err != nil {
+		log.Printf("Failed to send CHANNEL_REKEY_REQUIRED to session %d: %v", target.ID, err)
+	}
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client/crypto"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestChannelKeys(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	type member struct {
+		sess    *Session
+		conn    *mockConn
+		keys    *crypto.X25519KeyPair
+		keyring *crypto.GroupKeyring
+	}
+	register := func(nickname string) *member {
+		t.Helper()
+		userID, err := srv.db.CreateUser(nickname, "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		keys, err := crypto.GenerateX25519KeyPair()
+		if err != nil {
+			t.Fatalf("GenerateX25519KeyPair: %v", err)
+		}
+		if err := srv.db.SetUserEncryptionKey(userID, keys.PublicKey[:]); err != nil {
+			t.Fatalf("SetUserEncryptionKey: %v", err)
+		}
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(&userID, nickname, "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return &member{sess: sess, conn: conn, keys: keys, keyring: crypto.NewGroupKeyring()}
+	}
+	alice, bob, carol := register("alice"), register("bob"), register("carol")
+
+	// readFrame skips broadcasts until it finds a frame of the given type
+	readFrame := func(t *testing.T, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("no 0x%02X frame: %v", msgType, err)
+			}
+			if resp.Type != msgType {
+				continue
+			}
+			if err := msg.Decode(resp.Payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return
+		}
+	}
+	// hasFrame reports whether a frame of the given type was written
+	hasFrame := func(conn *mockConn, msgType uint8) bool {
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				return false
+			}
+			if resp.Type == msgType {
+				return true
+			}
+		}
+	}
+	expectError := func(t *testing.T, conn *mockConn, code uint16, handle func() error) {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := handle(); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		readFrame(t, conn, protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != code {
+			t.Errorf("expected error %d, got %d (%s)", code, errMsg.ErrorCode, errMsg.Message)
+		}
+	}
+	// receiveKeys unwraps the keys in a CHANNEL_KEYS frame into the
+	// member's keyring
+	receiveKeys := func(t *testing.T, m *member) []uint32 {
+		t.Helper()
+		msg := &protocol.ChannelKeysMessage{}
+		readFrame(t, m.conn, protocol.TypeChannelKeys, msg)
+		epochs := []uint32{}
+		for _, entry := range msg.Keys {
+			key, err := crypto.UnwrapChannelKey(entry.WrappedKey[:], m.keys.PrivateKey[:], msg.ChannelID, entry.Epoch)
+			if err != nil {
+				t.Fatalf("UnwrapChannelKey: %v", err)
+			}
+			if err := m.keyring.Add(entry.Epoch, key); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			epochs = append(epochs, entry.Epoch)
+		}
+		return epochs
+	}
+	// distribute wraps a new channel key for the given recipients
+	distribute := func(t *testing.T, from *member, channelID uint64, epoch uint32, recipients ...*member) func() error {
+		t.Helper()
+		channelKey, err := crypto.GenerateChannelKey()
+		if err != nil {
+			t.Fatalf("GenerateChannelKey: %v", err)
+		}
+		msg := &protocol.DistributeChannelKeyMessage{ChannelID: channelID, Epoch: epoch}
+		for _, m := range recipients {
+			wrapped, err := crypto.WrapChannelKey(channelKey, m.keys.PublicKey[:], channelID, epoch)
+			if err != nil {
+				t.Fatalf("WrapChannelKey: %v", err)
+			}
+			key := protocol.WrappedChannelKey{UserID: uint64(*m.sess.UserID)}
+			copy(key.WrappedKey[:], wrapped)
+			msg.Keys = append(msg.Keys, key)
+		}
+		return func() error {
+			return srv.handleDistributeChannelKey(from.sess, encodeAdminFrame(t, protocol.TypeDistributeChannelKey, msg))
+		}
+	}
+	rekeyRequired := func(t *testing.T, m *member) *protocol.ChannelRekeyRequiredMessage {
+		t.Helper()
+		msg := &protocol.ChannelRekeyRequiredMessage{}
+		readFrame(t, m.conn, protocol.TypeChannelRekeyRequired, msg)
+		return msg
+	}
+	nicknames := func(msg *protocol.ChannelRekeyRequiredMessage) []string {
+		result := []string{}
+		for _, m := range msg.Members {
+			result = append(result, m.Nickname)
+		}
+		return result
+	}
+	changeMembership := func(t *testing.T, msgType uint8, channelID uint64, nickname string) {
+		t.Helper()
+		var err error
+		if msgType == protocol.TypeInviteToChannel {
+			err = srv.handleInviteToChannel(alice.sess, encodeAdminFrame(t, msgType, &protocol.ChannelMemberMessage{ChannelID: channelID, Nickname: &nickname}))
+		} else {
+			err = srv.handleRemoveFromChannel(alice.sess, encodeAdminFrame(t, msgType, &protocol.ChannelMemberMessage{ChannelID: channelID, Nickname: &nickname}))
+		}
+		if err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+	}
+	getKeys := func(t *testing.T, m *member, channelID uint64) func() error {
+		return func() error {
+			return srv.handleGetChannelKeys(m.sess, encodeAdminFrame(t, protocol.TypeGetChannelKeys, &protocol.GetChannelKeysMessage{ChannelID: channelID}))
+		}
+	}
+
+	alice.conn.writeBuf.Reset()
+	if err := srv.handleCreateChannel(alice.sess, encodeAdminFrame(t, protocol.TypeCreateChannel, &protocol.CreateChannelMessage{
+		Name: "team", DisplayName: "#team", ChannelType: 1, RetentionHours: 168, Private: true,
+	})); err != nil {
+		t.Fatalf("handleCreateChannel: %v", err)
+	}
+	created := &protocol.ChannelCreatedMessage{}
+	readFrame(t, alice.conn, protocol.TypeChannelCreated, created)
+	channelID := created.ChannelID
+
+	t.Run("the creator makes the first key", func(t *testing.T) {
+		rekey := rekeyRequired(t, alice)
+		if rekey.ChannelID != channelID || rekey.Epoch != 1 || len(rekey.Members) != 1 || rekey.Members[0].PublicKey != alice.keys.PublicKey {
+			t.Fatalf("unexpected CHANNEL_REKEY_REQUIRED %+v", rekey)
+		}
+
+		alice.conn.writeBuf.Reset()
+		if err := distribute(t, alice, channelID, 1, alice)(); err != nil {
+			t.Fatalf("handleDistributeChannelKey: %v", err)
+		}
+		if epochs := receiveKeys(t, alice); len(epochs) != 1 || epochs[0] != 1 {
+			t.Errorf("expected alice to receive epoch 1, got %v", epochs)
+		}
+	})
+
+	t.Run("inviting members asks for a new key", func(t *testing.T) {
+		alice.conn.writeBuf.Reset()
+		changeMembership(t, protocol.TypeInviteToChannel, channelID, "bob")
+		changeMembership(t, protocol.TypeInviteToChannel, channelID, "carol")
+
+		// Each invite asks the inviter for epoch 2; the last one counts
+		rekey := rekeyRequired(t, alice)
+		rekey = rekeyRequired(t, alice)
+		if got := nicknames(rekey); rekey.Epoch != 2 || len(got) != 3 {
+			t.Fatalf("expected epoch 2 for alice, bob and carol, got epoch %d for %v", rekey.Epoch, got)
+		}
+
+		// Leaving someone out, or repeating an epoch, is rejected
+		expectError(t, alice.conn, protocol.ErrCodeInvalidInput, distribute(t, alice, channelID, 2, alice, bob))
+		expectError(t, alice.conn, protocol.ErrCodeInvalidInput, distribute(t, alice, channelID, 1, alice, bob, carol))
+
+		bob.conn.writeBuf.Reset()
+		carol.conn.writeBuf.Reset()
+		if err := distribute(t, alice, channelID, 2, alice, bob, carol)(); err != nil {
+			t.Fatalf("handleDistributeChannelKey: %v", err)
+		}
+		receiveKeys(t, bob)
+		receiveKeys(t, carol)
+
+		// Fetching returns every key wrapped for the member
+		alice.conn.writeBuf.Reset()
+		if err := getKeys(t, alice, channelID)(); err != nil {
+			t.Fatalf("handleGetChannelKeys: %v", err)
+		}
+		if epochs := receiveKeys(t, alice); len(epochs) != 2 || epochs[0] != 1 || epochs[1] != 2 {
+			t.Errorf("expected alice to have epochs 1 and 2, got %v", epochs)
+		}
+		if hasFrame(alice.conn, protocol.TypeChannelRekeyRequired) {
+			t.Error("expected no rekey request when the key is current")
+		}
+	})
+
+	t.Run("removed members can't read new messages", func(t *testing.T) {
+		before, err := bob.keyring.Encrypt([]byte("hi all"))
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+
+		alice.conn.writeBuf.Reset()
+		changeMembership(t, protocol.TypeRemoveFromChannel, channelID, "carol")
+		rekey := rekeyRequired(t, alice)
+		if got := nicknames(rekey); rekey.Epoch != 3 || len(got) != 2 {
+			t.Fatalf("expected epoch 3 for alice and bob, got epoch %d for %v", rekey.Epoch, got)
+		}
+
+		expectError(t, alice.conn, protocol.ErrCodeInvalidInput, distribute(t, alice, channelID, 3, alice, bob, carol))
+		expectError(t, carol.conn, protocol.ErrCodeChannelPrivate, distribute(t, carol, channelID, 3, carol))
+
+		bob.conn.writeBuf.Reset()
+		carol.conn.writeBuf.Reset()
+		if err := distribute(t, alice, channelID, 3, alice, bob)(); err != nil {
+			t.Fatalf("handleDistributeChannelKey: %v", err)
+		}
+		receiveKeys(t, bob)
+		if hasFrame(carol.conn, protocol.TypeChannelKeys) {
+			t.Error("expected carol not to receive the new key")
+		}
+		expectError(t, carol.conn, protocol.ErrCodeChannelPrivate, getKeys(t, carol, channelID))
+
+		after, err := bob.keyring.Encrypt([]byte("carol can't see this"))
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		if _, err := carol.keyring.Decrypt(after); !errors.Is(err, crypto.ErrUnknownEpoch) {
+			t.Errorf("expected carol to be unable to decrypt, got %v", err)
+		}
+		if plaintext, err := carol.keyring.Decrypt(before); err != nil || string(plaintext) != "hi all" {
+			t.Errorf("expected carol to still read older messages, got %q (%v)", plaintext, err)
+		}
+	})
+
+	t.Run("changing your encryption key asks for a new key", func(t *testing.T) {
+		keys, err := crypto.GenerateX25519KeyPair()
+		if err != nil {
+			t.Fatalf("GenerateX25519KeyPair: %v", err)
+		}
+		bob.conn.writeBuf.Reset()
+		if err := srv.handleProvidePublicKey(bob.sess, encodeAdminFrame(t, protocol.TypeProvidePublicKey, &protocol.ProvidePublicKeyMessage{
+			KeyType: protocol.KeyTypeGenerated, PublicKey: keys.PublicKey,
+		})); err != nil {
+			t.Fatalf("handleProvidePublicKey: %v", err)
+		}
+		rekey := rekeyRequired(t, bob)
+		if rekey.Epoch != 4 {
+			t.Errorf("expected bob to be asked for epoch 4, got %d", rekey.Epoch)
+		}
+		for _, m := range rekey.Members {
+			if m.Nickname == "bob" && m.PublicKey != keys.PublicKey {
+				t.Error("expected bob's new public key in the member list")
+			}
+		}
+	})
+}
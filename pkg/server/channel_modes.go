@@ -0,0 +1,209 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// channelModes returns the modes that apply to a channel: its own, or its
+// parent's for subchannels
+func (s *Server) channelModes(channel *database.Channel) protocol.ChannelModes {
+	if channel.ParentID != nil {
+		if parent, err := s.db.GetChannel(*channel.ParentID); err == nil {
+			return protocol.ChannelModes(parent.Modes)
+		}
+	}
+	return protocol.ChannelModes(channel.Modes)
+}
+
+// hasGrant reports whether a session's user holds a grant in a channel.
+// Anonymous users never do.
+func (s *Server) hasGrant(sess *Session, channelID int64, kind string) bool {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+	if userID == nil {
+		return false
+	}
+
+	ok, err := s.db.HasChannelGrant(roleChannelID(s.db, channelID), *userID, kind)
+	if err != nil {
+		log.Printf("Session %d: failed to look up channel grant: %v", sess.ID, err)
+		return false
+	}
+	return ok
+}
+
+// channelAccessDenied checks DM and private channel membership and the modes
+// that keep users out of a channel (registered-only, invite-only). It returns
+// the error to send, or 0 if the session may see the channel. Every path that
+// reads or subscribes to a channel goes through it. Admins and channel staff
+// are never kept out by modes, but only members see private channels.
+func (s *Server) channelAccessDenied(sess *Session, channel *database.Channel) (uint16, string) {
+	if code, message := s.privateChannelDenied(sess, channel); code != 0 {
+		return code, message
+	}
+
+	modes := s.channelModes(channel)
+	if !modes.Has(protocol.ChannelModeRegisteredOnly) && !modes.Has(protocol.ChannelModeInviteOnly) {
+		return 0, ""
+	}
+	if s.can(sess, channel.ID, permInviteUsers) {
+		return 0, ""
+	}
+
+	sess.mu.RLock()
+	anonymous := sess.UserID == nil
+	sess.mu.RUnlock()
+
+	if modes.Has(protocol.ChannelModeRegisteredOnly) && anonymous {
+		return protocol.ErrCodeRegisteredOnly, "Channel is for registered users only. Register or log in to join."
+	}
+	if modes.Has(protocol.ChannelModeInviteOnly) && !s.hasGrant(sess, channel.ID, database.ChannelGrantInvite) {
+		return protocol.ErrCodeChannelPrivate, "Channel is invite-only"
+	}
+	return 0, ""
+}
+
+// userChannelAccessDenied is channelAccessDenied for a registered user who
+// may not be connected, such as someone mentioned in a message
+func (s *Server) userChannelAccessDenied(user *database.User, channel *database.Channel) (uint16, string) {
+	return s.channelAccessDenied(&Session{UserID: &user.ID, Nickname: user.Nickname}, channel)
+}
+
+// hiddenChannelIDs lists the public channels a session is kept out of by
+// channel modes, for searches across all channels
+func (s *Server) hiddenChannelIDs(sess *Session) ([]int64, error) {
+	channels, err := s.db.ListChannels()
+	if err != nil {
+		return nil, err
+	}
+	var hidden []int64
+	for _, channel := range channels {
+		if channel.IsPrivate {
+			continue
+		}
+		modes := s.channelModes(channel)
+		if !modes.Has(protocol.ChannelModeRegisteredOnly) && !modes.Has(protocol.ChannelModeInviteOnly) {
+			continue
+		}
+		if code, _ := s.channelAccessDenied(sess, channel); code != 0 {
+			hidden = append(hidden, channel.ID)
+		}
+	}
+	return hidden, nil
+}
+
+// channelPostDenied checks every mode that stops a session posting in a
+// channel. It returns the error to send, or 0 if the session may post.
+func (s *Server) channelPostDenied(sess *Session, channel *database.Channel) (uint16, string) {
+	if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+		return code, message
+	}
+
+	modes := s.channelModes(channel)
+	if !modes.Has(protocol.ChannelModeReadOnly) && !modes.Has(protocol.ChannelModeModerated) {
+		return 0, ""
+	}
+	if s.can(sess, channel.ID, permPostRestricted) {
+		return 0, ""
+	}
+
+	if modes.Has(protocol.ChannelModeReadOnly) {
+		return protocol.ErrCodeChannelReadOnly, "Channel is read-only: only moderators can post"
+	}
+	if !s.hasGrant(sess, channel.ID, database.ChannelGrantVoice) {
+		return protocol.ErrCodeChannelModerated, "Channel is moderated: only voiced users can post"
+	}
+	return 0, ""
+}
+
+// handleSetChannelGrant handles SET_CHANNEL_GRANT. Channel owners and
+// moderators invite users to invite-only channels and voice them in
+// moderated ones.
+func (s *Server) handleSetChannelGrant(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.SetChannelGrantMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeChannelGrantSet, &protocol.ChannelGrantSetMessage{
+			Success:   false,
+			ChannelID: msg.ChannelID,
+			Message:   message,
+		})
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || channel.IsDM {
+		return fail("Channel not found")
+	}
+	if channel.ParentID != nil {
+		return fail("Invites and voice are set on the parent channel")
+	}
+
+	var kind string
+	var perm channelPermission
+	switch msg.Grant {
+	case protocol.ChannelGrantInvite:
+		kind, perm = database.ChannelGrantInvite, permInviteUsers
+	case protocol.ChannelGrantVoice:
+		kind, perm = database.ChannelGrantVoice, permVoiceUsers
+	default:
+		return fail("Invalid grant")
+	}
+
+	if !s.can(sess, channel.ID, perm) {
+		return fail("Permission denied: only channel owners, moderators or admins can do this")
+	}
+
+	targetID, targetNickname, err := s.resolveModerationTarget(msg.UserID, msg.Nickname)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if targetID == nil {
+		return fail("Only registered users can be invited or voiced")
+	}
+
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if err := s.db.SetChannelGrant(channel.ID, *targetID, kind, msg.Granted, nickname); err != nil {
+		return s.dbError(sess, "SetChannelGrant", err)
+	}
+
+	message := grantMessage(targetNickname, kind, msg.Granted, channel.Name)
+	log.Printf("%s: %s", nickname, message)
+
+	// Changes to someone else's channel are moderation
+	if s.channelRole(sess, channel.ID) == database.ChannelRoleMember {
+		if err := s.db.LogAdminAction(uint64(*userID), nickname, "SET_CHANNEL_GRANT", message); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypeChannelGrantSet, &protocol.ChannelGrantSetMessage{
+		Success:   true,
+		ChannelID: msg.ChannelID,
+		Message:   message,
+	})
+}
+
+func grantMessage(nickname, kind string, granted bool, channelName string) string {
+	switch {
+	case kind == database.ChannelGrantInvite && granted:
+		return fmt.Sprintf("%s is now invited to #%s", nickname, channelName)
+	case kind == database.ChannelGrantInvite:
+		return fmt.Sprintf("%s is no longer invited to #%s", nickname, channelName)
+	case granted:
+		return fmt.Sprintf("%s is now voiced in #%s", nickname, channelName)
+	default:
+		return fmt.Sprintf("%s is no longer voiced in #%s", nickname, channelName)
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestChannelModes(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	register := func(nickname string) (int64, *Session, *mockConn) {
+		t.Helper()
+		userID, err := srv.db.CreateUser(nickname, "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(&userID, nickname, "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return userID, sess, conn
+	}
+	ownerID, owner, ownerConn := register("alice")
+	_, member, memberConn := register("bob")
+
+	anonConn := newMockConn()
+	anon, err := srv.sessions.CreateSession(nil, "guest", "tcp", anonConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	channelID, err := srv.db.CreateChannel("staff", "Staff", nil, 1, 168, &ownerID)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	// readFrame skips broadcasts until it finds a frame of the given type
+	readFrame := func(t *testing.T, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("no 0x%02X frame: %v", msgType, err)
+			}
+			if resp.Type != msgType {
+				continue
+			}
+			if err := msg.Decode(resp.Payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return
+		}
+	}
+	setModes := func(t *testing.T, modes protocol.ChannelModes) *protocol.ChannelUpdatedMessage {
+		t.Helper()
+		ownerConn.writeBuf.Reset()
+		value := uint8(modes)
+		if err := srv.handleUpdateChannel(owner, encodeAdminFrame(t, protocol.TypeUpdateChannel, &protocol.UpdateChannelMessage{ChannelID: uint64(channelID), Modes: &value})); err != nil {
+			t.Fatalf("handleUpdateChannel: %v", err)
+		}
+		resp := &protocol.ChannelUpdatedMessage{}
+		readFrame(t, ownerConn, protocol.TypeChannelUpdated, resp)
+		return resp
+	}
+	setGrant := func(t *testing.T, grant uint8, granted bool) *protocol.ChannelGrantSetMessage {
+		t.Helper()
+		ownerConn.writeBuf.Reset()
+		nickname := "bob"
+		if err := srv.handleSetChannelGrant(owner, encodeAdminFrame(t, protocol.TypeSetChannelGrant, &protocol.SetChannelGrantMessage{ChannelID: uint64(channelID), Nickname: &nickname, Grant: grant, Granted: granted})); err != nil {
+			t.Fatalf("handleSetChannelGrant: %v", err)
+		}
+		resp := &protocol.ChannelGrantSetMessage{}
+		readFrame(t, ownerConn, protocol.TypeChannelGrantSet, resp)
+		return resp
+	}
+	// expectError runs a handler and checks that it answered with the error code
+	expectError := func(t *testing.T, conn *mockConn, code uint16, handle func() error) {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := handle(); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		readFrame(t, conn, protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != code {
+			t.Errorf("expected error %d, got %d (%s)", code, errMsg.ErrorCode, errMsg.Message)
+		}
+	}
+	join := func(t *testing.T, sess *Session) func() error {
+		return func() error {
+			return srv.handleJoinChannel(sess, encodeAdminFrame(t, protocol.TypeJoinChannel, &protocol.JoinChannelMessage{ChannelID: uint64(channelID)}))
+		}
+	}
+	subscribe := func(t *testing.T, sess *Session) func() error {
+		return func() error {
+			return srv.handleSubscribeChannel(sess, encodeAdminFrame(t, protocol.TypeSubscribeChannel, &protocol.SubscribeChannelMessage{ChannelID: uint64(channelID)}))
+		}
+	}
+	post := func(t *testing.T, sess *Session) func() error {
+		return func() error {
+			return srv.handlePostMessage(sess, encodeAdminFrame(t, protocol.TypePostMessage, &protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: "hello"}))
+		}
+	}
+	listed := func(t *testing.T, sess *Session, conn *mockConn) bool {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := srv.handleListChannels(sess, encodeAdminFrame(t, protocol.TypeListChannels, &protocol.ListChannelsMessage{})); err != nil {
+			t.Fatalf("handleListChannels: %v", err)
+		}
+		list := &protocol.ChannelListMessage{}
+		readFrame(t, conn, protocol.TypeChannelList, list)
+		for _, ch := range list.Channels {
+			if ch.ID == uint64(channelID) {
+				return true
+			}
+		}
+		return false
+	}
+	posted := func(t *testing.T, sess *Session, conn *mockConn) {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := post(t, sess)(); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+		resp := &protocol.MessagePostedMessage{}
+		readFrame(t, conn, protocol.TypeMessagePosted, resp)
+		if !resp.Success {
+			t.Fatalf("expected the post to succeed: %+v", resp)
+		}
+	}
+
+	t.Run("only known modes can be set", func(t *testing.T) {
+		if resp := setModes(t, protocol.ChannelModes(0x80)); resp.Success {
+			t.Error("expected unknown mode bits to be rejected")
+		}
+	})
+
+	t.Run("invite-only channels are hidden until invited", func(t *testing.T) {
+		if resp := setModes(t, protocol.ChannelModeInviteOnly); !resp.Success || resp.Modes != protocol.ChannelModeInviteOnly {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+
+		if listed(t, member, memberConn) {
+			t.Error("expected the channel to be hidden from uninvited users")
+		}
+		if !listed(t, owner, ownerConn) {
+			t.Error("expected the owner to still see the channel")
+		}
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, join(t, member))
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, subscribe(t, member))
+
+		// Nothing in the channel can be read by other means either
+		rootID, _, err := srv.db.PostMessage(channelID, nil, nil, &ownerID, "alice", "staff meeting notes")
+		if err != nil {
+			t.Fatalf("PostMessage: %v", err)
+		}
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, func() error {
+			return srv.handleListMessages(member, encodeAdminFrame(t, protocol.TypeListMessages, &protocol.ListMessagesMessage{ChannelID: uint64(channelID), Limit: 50}))
+		})
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, func() error {
+			return srv.handleSubscribeThread(member, encodeAdminFrame(t, protocol.TypeSubscribeThread, &protocol.SubscribeThreadMessage{ThreadID: uint64(rootID)}))
+		})
+		channelFilter := uint64(channelID)
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, func() error {
+			return srv.handleSearchMessages(member, encodeAdminFrame(t, protocol.TypeSearchMessages, &protocol.SearchMessagesMessage{Query: "meeting", ChannelID: &channelFilter}))
+		})
+
+		memberConn.writeBuf.Reset()
+		if err := srv.handleSearchMessages(member, encodeAdminFrame(t, protocol.TypeSearchMessages, &protocol.SearchMessagesMessage{Query: "meeting"})); err != nil {
+			t.Fatalf("handleSearchMessages: %v", err)
+		}
+		results := &protocol.SearchResultsMessage{}
+		readFrame(t, memberConn, protocol.TypeSearchResults, results)
+		if len(results.Results) != 0 {
+			t.Errorf("expected a search across all channels to skip the channel, got %d results", len(results.Results))
+		}
+
+		memberConn.writeBuf.Reset()
+		if err := srv.handleGetMessageHistory(member, encodeAdminFrame(t, protocol.TypeGetMessageHistory, &protocol.GetMessageHistoryMessage{MessageID: uint64(rootID)})); err != nil {
+			t.Fatalf("handleGetMessageHistory: %v", err)
+		}
+		history := &protocol.MessageHistoryMessage{}
+		readFrame(t, memberConn, protocol.TypeMessageHistory, history)
+		if history.Success {
+			t.Error("expected the edit history to be refused")
+		}
+
+		if resp := setGrant(t, protocol.ChannelGrantInvite, true); !resp.Success || resp.Message != "bob is now invited to #staff" {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		if !listed(t, member, memberConn) {
+			t.Error("expected the channel to be listed once invited")
+		}
+		memberConn.writeBuf.Reset()
+		if err := join(t, member)(); err != nil {
+			t.Fatalf("handleJoinChannel: %v", err)
+		}
+		joined := &protocol.JoinResponseMessage{}
+		readFrame(t, memberConn, protocol.TypeJoinResponse, joined)
+		if !joined.Success {
+			t.Errorf("expected the invited user to join: %+v", joined)
+		}
+	})
+
+	t.Run("registered-only channels keep anonymous users out", func(t *testing.T) {
+		setModes(t, protocol.ChannelModeRegisteredOnly)
+
+		expectError(t, anonConn, protocol.ErrCodeRegisteredOnly, join(t, anon))
+		expectError(t, anonConn, protocol.ErrCodeRegisteredOnly, post(t, anon))
+		posted(t, member, memberConn)
+	})
+
+	t.Run("read-only channels are for staff", func(t *testing.T) {
+		setModes(t, protocol.ChannelModeReadOnly)
+
+		expectError(t, memberConn, protocol.ErrCodeChannelReadOnly, post(t, member))
+		posted(t, owner, ownerConn)
+	})
+
+	t.Run("moderated channels need voice", func(t *testing.T) {
+		setModes(t, protocol.ChannelModeModerated)
+
+		expectError(t, memberConn, protocol.ErrCodeChannelModerated, post(t, member))
+		if resp := setGrant(t, protocol.ChannelGrantVoice, true); !resp.Success {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		posted(t, member, memberConn)
+
+		if resp := setGrant(t, protocol.ChannelGrantVoice, false); !resp.Success || resp.Message != "bob is no longer voiced in #staff" {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		expectError(t, memberConn, protocol.ErrCodeChannelModerated, post(t, member))
+	})
+
+	t.Run("members cannot hand out grants", func(t *testing.T) {
+		memberConn.writeBuf.Reset()
+		nickname := "bob"
+		if err := srv.handleSetChannelGrant(member, encodeAdminFrame(t, protocol.TypeSetChannelGrant, &protocol.SetChannelGrantMessage{ChannelID: uint64(channelID), Nickname: &nickname, Grant: protocol.ChannelGrantVoice, Granted: true})); err != nil {
+			t.Fatalf("handleSetChannelGrant: %v", err)
+		}
+		resp := &protocol.ChannelGrantSetMessage{}
+		readFrame(t, memberConn, protocol.TypeChannelGrantSet, resp)
+		if resp.Success {
+			t.Error("expected a member voicing themselves to fail")
+		}
+	})
+}
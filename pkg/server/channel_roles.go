@@ -0,0 +1,295 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// channelPermission is something only some users may do in a channel
+type channelPermission int
+
+const (
+	permEditOthersMessages channelPermission = iota
+	permDeleteOthersMessages
+	permCreateSubchannel
+	permMuteInChannel
+	permInviteUsers
+	permVoiceUsers
+	permPostRestricted // Post in moderated and read-only channels
+	permManageModerators
+)
+
+// rolePermits reports whether a channel role grants a permission. Owners may
+// do everything; moderators look after messages and users but can't change
+// the channel's structure or staff.
+func rolePermits(role string, perm channelPermission) bool {
+	switch role {
+	case database.ChannelRoleOwner:
+		return true
+	case database.ChannelRoleModerator:
+		return perm != permCreateSubchannel && perm != permManageModerators
+	default:
+		return false
+	}
+}
+
+// roleChannelID returns the channel whose roles apply to a channel: the
+// channel itself, or its parent for subchannels
+func roleChannelID(db database.Store, channelID int64) int64 {
+	if channel, err := db.GetChannel(channelID); err == nil && channel.ParentID != nil {
+		return *channel.ParentID
+	}
+	return channelID
+}
+
+// channelRole returns a session's role in a channel. Anonymous users and
+// lookup failures count as members.
+func (s *Server) channelRole(sess *Session, channelID int64) string {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+	if userID == nil {
+		return database.ChannelRoleMember
+	}
+
+	role, err := s.db.GetChannelRole(roleChannelID(s.db, channelID), *userID)
+	if err != nil {
+		log.Printf("Session %d: failed to look up channel role: %v", sess.ID, err)
+		return database.ChannelRoleMember
+	}
+	return role
+}
+
+// can is the permission check for channel moderation. Server admins may do
+// everything in every channel.
+func (s *Server) can(sess *Session, channelID int64, perm channelPermission) bool {
+	return s.isAdmin(sess) || rolePermits(s.channelRole(sess, channelID), perm)
+}
+
+// channelUserFlags returns the flags to show for a user in a channel. The
+// moderator flag is set for the channel's owners and moderators only.
+func channelUserFlags(db database.Store, channelID int64, userID *int64, flags uint8) protocol.UserFlags {
+	return newChannelRoleCache(db).userFlags(channelID, userID, flags)
+}
+
+// channelRoleCache loads each channel's roles once, for converting a list of
+// messages or users without two lookups per entry. It lives for one list and
+// isn't safe for concurrent use.
+type channelRoleCache struct {
+	db           database.Store
+	roleChannels map[int64]int64            // channelID -> channel whose roles apply
+	roles        map[int64]map[int64]string // role channelID -> userID -> role
+}
+
+func newChannelRoleCache(db database.Store) *channelRoleCache {
+	return &channelRoleCache{
+		db:           db,
+		roleChannels: make(map[int64]int64),
+		roles:        make(map[int64]map[int64]string),
+	}
+}
+
+// role returns a user's role in a channel. Lookup failures count as members.
+func (c *channelRoleCache) role(channelID, userID int64) string {
+	roleChannel, ok := c.roleChannels[channelID]
+	if !ok {
+		roleChannel = roleChannelID(c.db, channelID)
+		c.roleChannels[channelID] = roleChannel
+	}
+	roles, ok := c.roles[roleChannel]
+	if !ok {
+		roles = make(map[int64]string)
+		list, err := c.db.ListChannelRoles(roleChannel)
+		if err != nil {
+			log.Printf("Failed to list roles of channel %d: %v", roleChannel, err)
+		}
+		for _, r := range list {
+			roles[r.UserID] = r.Role
+		}
+		c.roles[roleChannel] = roles
+	}
+	return roles[userID]
+}
+
+// userFlags is channelUserFlags using the cache
+func (c *channelRoleCache) userFlags(channelID int64, userID *int64, flags uint8) protocol.UserFlags {
+	userFlags := protocol.UserFlags(flags) &^ protocol.UserFlagModerator
+	if userID != nil && c.role(channelID, *userID) != database.ChannelRoleMember {
+		userFlags |= protocol.UserFlagModerator
+	}
+	return userFlags
+}
+
+func channelRoleToProtocol(role string) uint8 {
+	switch role {
+	case database.ChannelRoleOwner:
+		return protocol.ChannelRoleOwner
+	case database.ChannelRoleModerator:
+		return protocol.ChannelRoleModerator
+	default:
+		return protocol.ChannelRoleMember
+	}
+}
+
+func channelRoleFromProtocol(role uint8) (string, bool) {
+	switch role {
+	case protocol.ChannelRoleOwner:
+		return database.ChannelRoleOwner, true
+	case protocol.ChannelRoleModerator:
+		return database.ChannelRoleModerator, true
+	case protocol.ChannelRoleMember:
+		return database.ChannelRoleMember, true
+	default:
+		return "", false
+	}
+}
+
+// handleSetChannelRole handles SET_CHANNEL_ROLE. Channel owners appoint
+// moderators; only admins can change who owns a channel.
+func (s *Server) handleSetChannelRole(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.SetChannelRoleMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeChannelRoleSet, &protocol.ChannelRoleSetMessage{
+			Success:   false,
+			ChannelID: msg.ChannelID,
+			Message:   message,
+		})
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || channel.IsDM {
+		return fail("Channel not found")
+	}
+	if channel.ParentID != nil {
+		return fail("Roles are set on the parent channel")
+	}
+
+	role, ok := channelRoleFromProtocol(msg.Role)
+	if !ok {
+		return fail("Invalid role")
+	}
+
+	isAdmin := s.isAdmin(sess)
+	if !isAdmin && !s.can(sess, channel.ID, permManageModerators) {
+		return fail("Permission denied: only the channel owner or an admin can change roles")
+	}
+
+	targetID, targetNickname, err := s.resolveModerationTarget(msg.UserID, msg.Nickname)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if targetID == nil {
+		return fail("Only registered users can hold channel roles")
+	}
+
+	currentRole, err := s.db.GetChannelRole(channel.ID, *targetID)
+	if err != nil {
+		return s.dbError(sess, "GetChannelRole", err)
+	}
+	if !isAdmin && (role == database.ChannelRoleOwner || currentRole == database.ChannelRoleOwner) {
+		return fail("Permission denied: only an admin can change channel owners")
+	}
+
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if err := s.db.SetChannelRole(channel.ID, *targetID, role, nickname); err != nil {
+		return s.dbError(sess, "SetChannelRole", err)
+	}
+
+	message := fmt.Sprintf("%s is now %s of #%s", targetNickname, roleLabel(role), channel.Name)
+	log.Printf("%s: %s", nickname, message)
+
+	// Changes to someone else's channel are moderation
+	if isAdmin && s.channelRole(sess, channel.ID) != database.ChannelRoleOwner {
+		if err := s.db.LogAdminAction(uint64(*userID), nickname, "SET_CHANNEL_ROLE", message); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	if err := s.sendMessage(sess, protocol.TypeChannelRoleSet, &protocol.ChannelRoleSetMessage{
+		Success:   true,
+		ChannelID: msg.ChannelID,
+		Message:   message,
+	}); err != nil {
+		return err
+	}
+
+	// Refresh the rosters of everyone in the channel so the @ prefix follows the role
+	s.broadcastChannelUserList(channel.ID)
+	return nil
+}
+
+// handleListChannelRoles handles LIST_CHANNEL_ROLES
+func (s *Server) handleListChannelRoles(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ListChannelRolesMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || channel.IsDM {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel not found")
+	}
+	if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+		return s.sendError(sess, code, message)
+	}
+
+	roles, err := s.db.ListChannelRoles(roleChannelID(s.db, channel.ID))
+	if err != nil {
+		return s.dbError(sess, "ListChannelRoles", err)
+	}
+
+	entries := make([]protocol.ChannelRoleEntry, len(roles))
+	for i, role := range roles {
+		entries[i] = protocol.ChannelRoleEntry{
+			UserID:    uint64(role.UserID),
+			Nickname:  role.Nickname,
+			Role:      channelRoleToProtocol(role.Role),
+			GrantedBy: role.GrantedBy,
+			GrantedAt: role.GrantedAt,
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypeChannelRoleList, &protocol.ChannelRoleListMessage{
+		ChannelID: msg.ChannelID,
+		Roles:     entries,
+	})
+}
+
+// broadcastChannelUserList sends a fresh CHANNEL_USER_LIST to everyone in a channel
+func (s *Server) broadcastChannelUserList(channelID int64) {
+	msg := &protocol.ChannelUserListMessage{
+		ChannelID: uint64(channelID),
+		Users:     s.channelUserEntries(channelID),
+	}
+	for _, entry := range msg.Users {
+		target, ok := s.sessions.GetSession(entry.SessionID)
+		if !ok {
+			continue
+		}
+		if err := s.sendMessage(target, protocol.TypeChannelUserList, msg); err != nil {
+			log.Printf("Failed to send CHANNEL_USER_LIST to session %d: %v", target.ID, err)
+		}
+	}
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case database.ChannelRoleOwner:
+		return "an owner"
+	case database.ChannelRoleModerator:
+		return "a moderator"
+	default:
+		return "a member"
+	}
+}
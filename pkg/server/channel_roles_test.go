@@ -0,0 +1,254 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestRolePermits(t *testing.T) {
+	tests := []struct {
+		role string
+		perm channelPermission
+		want bool
+	}{
+		{database.ChannelRoleOwner, permCreateSubchannel, true},
+		{database.ChannelRoleOwner, permManageModerators, true},
+		{database.ChannelRoleModerator, permDeleteOthersMessages, true},
+		{database.ChannelRoleModerator, permEditOthersMessages, true},
+		{database.ChannelRoleModerator, permMuteInChannel, true},
+		{database.ChannelRoleModerator, permCreateSubchannel, false},
+		{database.ChannelRoleModerator, permManageModerators, false},
+		{database.ChannelRoleMember, permDeleteOthersMessages, false},
+		{database.ChannelRoleMember, permMuteInChannel, false},
+	}
+	for _, tt := range tests {
+		if got := rolePermits(tt.role, tt.perm); got != tt.want {
+			t.Errorf("rolePermits(%q, %d) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestChannelRoles(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	register := func(nickname string) (int64, *Session, *mockConn) {
+		t.Helper()
+		userID, err := srv.db.CreateUser(nickname, "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(&userID, nickname, "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return userID, sess, conn
+	}
+	ownerID, owner, ownerConn := register("alice")
+	modID, mod, modConn := register("bob")
+	memberID, member, memberConn := register("carol")
+
+	generalID, err := srv.db.CreateChannel("general", "General", nil, 1, 168, &ownerID)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+	randomID, err := srv.db.CreateChannel("random", "Random", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	// readFrame skips broadcasts until it finds a frame of the given type
+	readFrame := func(t *testing.T, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("no 0x%02X frame: %v", msgType, err)
+			}
+			if resp.Type != msgType {
+				continue
+			}
+			if err := msg.Decode(resp.Payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return
+		}
+	}
+	setRole := func(t *testing.T, sess *Session, conn *mockConn, channelID int64, nickname string, role uint8) *protocol.ChannelRoleSetMessage {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := srv.handleSetChannelRole(sess, encodeAdminFrame(t, protocol.TypeSetChannelRole, &protocol.SetChannelRoleMessage{ChannelID: uint64(channelID), Nickname: &nickname, Role: role})); err != nil {
+			t.Fatalf("handleSetChannelRole: %v", err)
+		}
+		resp := &protocol.ChannelRoleSetMessage{}
+		readFrame(t, conn, protocol.TypeChannelRoleSet, resp)
+		return resp
+	}
+	postBy := func(t *testing.T, userID int64, nickname string) int64 {
+		t.Helper()
+		msgID, _, err := srv.db.PostMessage(generalID, nil, nil, &userID, nickname, "hello")
+		if err != nil {
+			t.Fatalf("PostMessage: %v", err)
+		}
+		return msgID
+	}
+
+	t.Run("creator owns the channel", func(t *testing.T) {
+		if role := srv.channelRole(owner, generalID); role != database.ChannelRoleOwner {
+			t.Errorf("expected the creator to own the channel, got %q", role)
+		}
+		if role := srv.channelRole(owner, randomID); role != database.ChannelRoleMember {
+			t.Errorf("expected no role in another channel, got %q", role)
+		}
+	})
+
+	t.Run("members cannot grant roles", func(t *testing.T) {
+		if resp := setRole(t, member, memberConn, generalID, "carol", protocol.ChannelRoleModerator); resp.Success {
+			t.Error("expected a member granting a role to fail")
+		}
+	})
+
+	t.Run("owner appoints a moderator", func(t *testing.T) {
+		// Everyone in the channel gets a fresh roster with the new moderator flagged
+		for _, sess := range []*Session{owner, mod, member} {
+			if err := srv.sessions.SetJoinedChannel(sess.ID, &generalID); err != nil {
+				t.Fatalf("SetJoinedChannel: %v", err)
+			}
+		}
+		memberConn.writeBuf.Reset()
+
+		resp := setRole(t, owner, ownerConn, generalID, "bob", protocol.ChannelRoleModerator)
+		if !resp.Success || resp.Message != "bob is now a moderator of #general" {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+
+		roster := &protocol.ChannelUserListMessage{}
+		readFrame(t, memberConn, protocol.TypeChannelUserList, roster)
+		for _, user := range roster.Users {
+			isModerator := user.UserFlags.IsModerator()
+			if want := user.Nickname == "alice" || user.Nickname == "bob"; isModerator != want {
+				t.Errorf("%s: moderator flag = %v, want %v", user.Nickname, isModerator, want)
+			}
+		}
+
+		// The flag is per channel
+		if channelUserFlags(srv.db, randomID, &modID, 0).IsModerator() {
+			t.Error("expected no moderator flag outside the channel")
+		}
+	})
+
+	t.Run("moderators cannot change staff or structure", func(t *testing.T) {
+		if resp := setRole(t, mod, modConn, generalID, "carol", protocol.ChannelRoleModerator); resp.Success {
+			t.Error("expected a moderator granting a role to fail")
+		}
+		if resp := setRole(t, owner, ownerConn, generalID, "bob", protocol.ChannelRoleOwner); resp.Success {
+			t.Error("expected only admins to be able to appoint owners")
+		}
+		if srv.can(mod, generalID, permCreateSubchannel) {
+			t.Error("expected moderators not to create subchannels")
+		}
+	})
+
+	t.Run("moderators edit and delete other messages", func(t *testing.T) {
+		msgID := postBy(t, memberID, "carol")
+
+		modConn.writeBuf.Reset()
+		if err := srv.handleEditMessage(mod, encodeAdminFrame(t, protocol.TypeEditMessage, &protocol.EditMessageMessage{MessageID: uint64(msgID), NewContent: "[removed link]"})); err != nil {
+			t.Fatalf("handleEditMessage: %v", err)
+		}
+		edited := &protocol.MessageEditedMessage{}
+		readFrame(t, modConn, protocol.TypeMessageEdited, edited)
+		if !edited.Success {
+			t.Fatalf("expected the moderator edit to succeed: %+v", edited)
+		}
+
+		modConn.writeBuf.Reset()
+		if err := srv.handleDeleteMessage(mod, encodeAdminFrame(t, protocol.TypeDeleteMessage, &protocol.DeleteMessageMessage{MessageID: uint64(msgID)})); err != nil {
+			t.Fatalf("handleDeleteMessage: %v", err)
+		}
+		deleted := &protocol.MessageDeletedMessage{}
+		readFrame(t, modConn, protocol.TypeMessageDeleted, deleted)
+		if !deleted.Success {
+			t.Fatalf("expected the moderator delete to succeed: %+v", deleted)
+		}
+	})
+
+	t.Run("members cannot edit other messages", func(t *testing.T) {
+		msgID := postBy(t, modID, "bob")
+
+		memberConn.writeBuf.Reset()
+		if err := srv.handleEditMessage(member, encodeAdminFrame(t, protocol.TypeEditMessage, &protocol.EditMessageMessage{MessageID: uint64(msgID), NewContent: "edited"})); err != nil {
+			t.Fatalf("handleEditMessage: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		readFrame(t, memberConn, protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+			t.Errorf("expected permission denied, got %d", errMsg.ErrorCode)
+		}
+	})
+
+	t.Run("moderators mute in their channel only", func(t *testing.T) {
+		mute := func(t *testing.T, channelID int64, nickname string) *protocol.UserMutedMessage {
+			t.Helper()
+			modConn.writeBuf.Reset()
+			channel := uint64(channelID)
+			if err := srv.handleMuteUser(mod, encodeAdminFrame(t, protocol.TypeMuteUser, &protocol.MuteUserMessage{Nickname: &nickname, ChannelID: &channel})); err != nil {
+				t.Fatalf("handleMuteUser: %v", err)
+			}
+			resp := &protocol.UserMutedMessage{}
+			readFrame(t, modConn, protocol.TypeUserMuted, resp)
+			return resp
+		}
+
+		if resp := mute(t, generalID, "carol"); !resp.Success {
+			t.Fatalf("expected the moderator mute to succeed: %+v", resp)
+		}
+		if resp := mute(t, randomID, "carol"); resp.Success {
+			t.Error("expected muting outside the moderator's channel to fail")
+		}
+		if resp := mute(t, generalID, "alice"); resp.Success {
+			t.Error("expected muting the channel owner to fail")
+		}
+	})
+
+	t.Run("demoted moderators lose their permissions", func(t *testing.T) {
+		if resp := setRole(t, owner, ownerConn, generalID, "bob", protocol.ChannelRoleMember); !resp.Success {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		if srv.can(mod, generalID, permDeleteOthersMessages) {
+			t.Error("expected the demoted moderator to lose delete permission")
+		}
+	})
+
+	t.Run("staff of invite-only channels are hidden from outsiders", func(t *testing.T) {
+		if err := srv.db.SetChannelModes(generalID, uint8(protocol.ChannelModeInviteOnly)); err != nil {
+			t.Fatalf("SetChannelModes: %v", err)
+		}
+		listRoles := func(sess *Session) error {
+			return srv.handleListChannelRoles(sess, encodeAdminFrame(t, protocol.TypeListChannelRoles, &protocol.ListChannelRolesMessage{ChannelID: uint64(generalID)}))
+		}
+
+		memberConn.writeBuf.Reset()
+		if err := listRoles(member); err != nil {
+			t.Fatalf("handleListChannelRoles: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		readFrame(t, memberConn, protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != protocol.ErrCodeChannelPrivate {
+			t.Errorf("expected error %d, got %d (%s)", protocol.ErrCodeChannelPrivate, errMsg.ErrorCode, errMsg.Message)
+		}
+
+		ownerConn.writeBuf.Reset()
+		if err := listRoles(owner); err != nil {
+			t.Fatalf("handleListChannelRoles: %v", err)
+		}
+		list := &protocol.ChannelRoleListMessage{}
+		readFrame(t, ownerConn, protocol.TypeChannelRoleList, list)
+		if len(list.Roles) != 1 || list.Roles[0].Nickname != "alice" {
+			t.Errorf("unexpected roles %+v", list.Roles)
+		}
+	})
+}
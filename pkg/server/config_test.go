@@ -2,6 +2,8 @@ package server
 
 import (
 	"os"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -235,3 +237,165 @@ func TestAdminUsersEnvVarWithWhitespace(t *testing.T) {
 		}
 	}
 }
+
+func TestDatabaseURLEnvVar(t *testing.T) {
+	t.Setenv("SUPERCHAT_SERVER_DATABASE_URL", "postgres://superchat@localhost/superchat")
+
+	config := applyEnvOverrides(TOMLConfig{})
+	if config.Server.DatabaseURL != "postgres://superchat@localhost/superchat" {
+		t.Errorf("Expected database URL from env, got %q", config.Server.DatabaseURL)
+	}
+
+	serverCfg := config.ToServerConfig()
+	if serverCfg.DatabaseURL != config.Server.DatabaseURL {
+		t.Errorf("ServerConfig: expected database URL %q, got %q", config.Server.DatabaseURL, serverCfg.DatabaseURL)
+	}
+}
+
+func TestWorkerIDEnvVar(t *testing.T) {
+	t.Setenv("SUPERCHAT_SERVER_WORKER_ID", "7")
+
+	config := applyEnvOverrides(TOMLConfig{})
+	serverCfg := config.ToServerConfig()
+	if serverCfg.WorkerID == nil || *serverCfg.WorkerID != 7 {
+		t.Fatalf("Expected worker ID 7 from env, got %v", serverCfg.WorkerID)
+	}
+	if err := validateWorkerID("postgres://superchat@localhost/superchat", serverCfg.WorkerID); err != nil {
+		t.Errorf("validateWorkerID: %v", err)
+	}
+}
+
+func TestWorkerIDRequiredWithDatabaseURL(t *testing.T) {
+	if err := validateWorkerID("postgres://superchat@localhost/superchat", nil); err == nil {
+		t.Error("expected an error for PostgreSQL without a worker ID")
+	}
+	if err := validateWorkerID("", nil); err != nil {
+		t.Errorf("SQLite needs no worker ID, got %v", err)
+	}
+}
+
+func TestLoadConfigWebhooks(t *testing.T) {
+	path := t.TempDir() + "/config.toml"
+	content := `
+[[webhooks]]
+url = " https://ci.example.com/hook "
+secret = "s3cret"
+events = ["message.created"]
+channels = ["general"]
+
+[[webhooks]]
+url = "https://audit.example.com/hook"
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	hooks := config.ToServerConfig().Webhooks
+	if len(hooks) != 2 {
+		t.Fatalf("expected 2 webhooks, got %d", len(hooks))
+	}
+	if hooks[0].URL != "https://ci.example.com/hook" || hooks[0].Secret != "s3cret" ||
+		len(hooks[0].Events) != 1 || len(hooks[0].Channels) != 1 {
+		t.Errorf("unexpected first webhook %+v", hooks[0])
+	}
+	if hooks[1].URL != "https://audit.example.com/hook" || len(hooks[1].Events) != 0 {
+		t.Errorf("unexpected second webhook %+v", hooks[1])
+	}
+	if err := validateWebhooks(hooks); err != nil {
+		t.Errorf("validateWebhooks: %v", err)
+	}
+}
+
+func TestRequire2FAEnvVar(t *testing.T) {
+	t.Setenv("SUPERCHAT_SERVER_REQUIRE_2FA", "admin, moderator")
+
+	config := applyEnvOverrides(TOMLConfig{})
+	serverCfg := config.ToServerConfig()
+	if len(serverCfg.Require2FA) != 2 || serverCfg.Require2FA[0] != "admin" || serverCfg.Require2FA[1] != "moderator" {
+		t.Errorf("Expected admin and moderator, got %v", serverCfg.Require2FA)
+	}
+	if err := validateRequire2FA(serverCfg.Require2FA); err != nil {
+		t.Errorf("validateRequire2FA: %v", err)
+	}
+}
+
+func TestSetConfigAdminUser(t *testing.T) {
+	adminUsers := func(t *testing.T, path string) []string {
+		t.Helper()
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		return config.Server.AdminUsers
+	}
+	set := func(t *testing.T, path, nickname string, admin, wantChanged bool) {
+		t.Helper()
+		changed, err := SetConfigAdminUser(path, nickname, admin)
+		if err != nil {
+			t.Fatalf("SetConfigAdminUser(%s, %v): %v", nickname, admin, err)
+		}
+		if changed != wantChanged {
+			t.Errorf("SetConfigAdminUser(%s, %v) changed = %v, want %v", nickname, admin, changed, wantChanged)
+		}
+	}
+
+	// The default config only has a commented out example
+	path := t.TempDir() + "/config.toml"
+	if err := writeDefaultConfig(path, DefaultTOMLConfig()); err != nil {
+		t.Fatalf("writeDefaultConfig: %v", err)
+	}
+	set(t, path, "root", true, true)
+	set(t, path, "root", true, false)
+	set(t, path, "dave", true, true)
+	if users := adminUsers(t, path); !slices.Equal(users, []string{"root", "dave"}) {
+		t.Fatalf("admin_users = %v, want [root dave]", users)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# admin_users = [\"alice\", \"bob\"]\nadmin_users = [\"root\", \"dave\"]\n") ||
+		!strings.Contains(string(data), "# Port for TCP connections") {
+		t.Errorf("expected the setting next to the example and the comments kept, got:\n%s", data)
+	}
+	set(t, path, "root", false, true)
+	set(t, path, "dave", false, true)
+	set(t, path, "dave", false, false)
+	if users := adminUsers(t, path); len(users) != 0 {
+		t.Errorf("admin_users = %v, want none", users)
+	}
+
+	// An array over several lines is replaced whole
+	content := `[server]
+tcp_port = 7000
+admin_users = [
+  "root", # the owner
+  "dave",
+]
+
+[limits]
+message_rate_limit = 5
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	set(t, path, "dave", false, true)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !slices.Equal(config.Server.AdminUsers, []string{"root"}) || config.Server.TCPPort != 7000 || config.Limits.MessageRateLimit != 5 {
+		t.Errorf("unexpected config after demoting: %+v", config)
+	}
+
+	// A config without a [server] section gets one
+	if err := os.WriteFile(path, []byte("[limits]\nmessage_rate_limit = 5"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	set(t, path, "carol", true, true)
+	if users := adminUsers(t, path); !slices.Equal(users, []string{"carol"}) {
+		t.Errorf("admin_users = %v, want [carol]", users)
+	}
+}
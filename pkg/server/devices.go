@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"log"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// deviceInfos lists a registered user's devices for USER_INFO
+func (s *Server) deviceInfos(userID int64) []protocol.DeviceInfo {
+	devices, err := s.db.ListUserDevices(userID)
+	if err != nil {
+		log.Printf("[WARN] Failed to list devices of user %d: %v", userID, err)
+		return nil
+	}
+	infos := make([]protocol.DeviceInfo, 0, len(devices))
+	for _, device := range devices {
+		info := protocol.DeviceInfo{
+			DeviceID:   uint64(device.ID),
+			Label:      device.Label,
+			LastSeenAt: device.LastSeenAt,
+		}
+		copy(info.PublicKey[:], device.PublicKey)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Helper: mark a new encrypted DM as multi-device if the initiator's client
+// and every device of both users support it. Returns whether it is.
+func (s *Server) negotiateDMMultiDevice(channelID int64, initiatorSupports bool, initiatorUserID, targetUserID int64) bool {
+	if !initiatorSupports {
+		return false
+	}
+	for _, userID := range []int64{initiatorUserID, targetUserID} {
+		devices, err := s.db.ListUserDevices(userID)
+		if err != nil || len(devices) == 0 {
+			return false
+		}
+		for _, device := range devices {
+			if !device.MultiDevice {
+				return false
+			}
+		}
+	}
+	if err := s.db.SetDMMultiDevice(channelID); err != nil {
+		log.Printf("[ERROR] Failed to mark DM %d as multi-device: %v", channelID, err)
+		return false
+	}
+	return true
+}
+
+// handleRevokeDevice revokes one of the user's devices. Senders stop
+// encrypting for it once they see the new device list, and its key can't be
+// provided again.
+func (s *Server) handleRevokeDevice(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required")
+	}
+
+	msg := &protocol.RevokeDeviceMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	revoked, err := s.db.RevokeUserDevice(*userID, int64(msg.DeviceID))
+	if err != nil {
+		return s.dbError(sess, "RevokeUserDevice", err)
+	}
+	if !revoked {
+		return s.sendError(sess, protocol.ErrCodeNotFound, "Device not found")
+	}
+
+	// The user's key for older clients and private channels moves to their
+	// newest remaining device
+	devices, err := s.db.ListUserDevices(*userID)
+	if err != nil {
+		return s.dbError(sess, "ListUserDevices", err)
+	}
+	var newest []byte
+	if len(devices) > 0 {
+		newest = devices[len(devices)-1].PublicKey
+	}
+	if current, err := s.db.GetUserEncryptionKey(*userID); err == nil && !bytes.Equal(current, newest) {
+		if err := s.db.SetUserEncryptionKey(*userID, newest); err != nil {
+			return s.dbError(sess, "SetUserEncryptionKey", err)
+		}
+		if channels, err := s.db.GetPrivateChannelsForUser(*userID); err == nil {
+			for _, channel := range channels {
+				s.requestChannelRekey(channel.ID, nil)
+			}
+		}
+	}
+
+	// Every session of the user learns about it, including the revoked one,
+	// and so do the other users of their multi-device DMs
+	revokedMsg := &protocol.DeviceRevokedMessage{DeviceID: msg.DeviceID}
+	s.sendToUserSessions(*userID, protocol.TypeDeviceRevoked, revokedMsg)
+	for _, partnerID := range s.multiDeviceDMPartners(*userID) {
+		s.sendToUserSessions(partnerID, protocol.TypeDeviceRevoked, revokedMsg)
+	}
+	return nil
+}
+
+// multiDeviceDMPartners lists the users a user has multi-device DMs with
+func (s *Server) multiDeviceDMPartners(userID int64) []int64 {
+	dms, err := s.db.GetDMChannels(userID)
+	if err != nil {
+		log.Printf("[WARN] Failed to list DMs of user %d: %v", userID, err)
+		return nil
+	}
+	var partners []int64
+	for _, dm := range dms {
+		if multiDevice, err := s.db.IsDMMultiDevice(dm.ID); err != nil || !multiDevice {
+			continue
+		}
+		participants, err := s.db.GetChannelParticipants(dm.ID)
+		if err != nil {
+			continue
+		}
+		for _, participant := range participants {
+			if participant.UserID != nil && *participant.UserID != userID {
+				partners = append(partners, *participant.UserID)
+			}
+		}
+	}
+	return partners
+}
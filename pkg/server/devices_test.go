@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestUserDevices(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := srv.db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bobID, err := srv.db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	workConn, homeConn, bobConn := newMockConn(), newMockConn(), newMockConn()
+	work, err := srv.sessions.CreateSession(&aliceID, "alice", "tcp", workConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	home, err := srv.sessions.CreateSession(&aliceID, "alice", "tcp", homeConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	bob, err := srv.sessions.CreateSession(&bobID, "bob", "tcp", bobConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	handle := func(t *testing.T, sess *Session, msgType uint8, msg protocol.ProtocolMessage, handle func(*Session, *protocol.Frame) error) {
+		t.Helper()
+		if err := handle(sess, encodeAdminFrame(t, msgType, msg)); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+	}
+	// next decodes the next frame written to a connection
+	next := func(t *testing.T, conn *mockConn) *protocol.Frame {
+		t.Helper()
+		frame, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		return frame
+	}
+	provide := func(t *testing.T, sess *Session, key byte, label string) {
+		t.Helper()
+		msg := &protocol.ProvidePublicKeyMessage{KeyType: protocol.KeyTypeGenerated, Label: label, Ratchet: true, MultiDevice: true}
+		msg.PublicKey[0] = key
+		handle(t, sess, protocol.TypeProvidePublicKey, msg, srv.handleProvidePublicKey)
+	}
+	userKey := func(t *testing.T) byte {
+		t.Helper()
+		key, err := srv.db.GetUserEncryptionKey(aliceID)
+		if err != nil || len(key) != 32 {
+			t.Fatalf("GetUserEncryptionKey: %v", err)
+		}
+		return key[0]
+	}
+
+	// Each machine becomes a device. The newest one's key is the user's key,
+	// even when the older one connects again.
+	provide(t, work, 1, "work")
+	provide(t, home, 2, "home")
+	provide(t, work, 1, "")
+	provide(t, bob, 3, "")
+	if key := userKey(t); key != 2 {
+		t.Errorf("expected the home key to be the user's key, got %d", key)
+	}
+
+	bobConn.writeBuf.Reset()
+	handle(t, bob, protocol.TypeGetUserInfo, &protocol.GetUserInfoMessage{Nickname: "alice"}, srv.handleGetUserInfo)
+	info := &protocol.UserInfoMessage{}
+	if frame := next(t, bobConn); frame.Type != protocol.TypeUserInfo || info.Decode(frame.Payload) != nil {
+		t.Fatalf("expected USER_INFO, got 0x%02X", frame.Type)
+	}
+	if len(info.Devices) != 2 || info.Devices[0].Label != "work" || info.Devices[1].Label != "home" {
+		t.Fatalf("expected the work and home devices, got %+v", info.Devices)
+	}
+	workDevice := info.Devices[0].DeviceID
+
+	// A DM between users whose devices all support it is multi-device, and
+	// every session of both users hears about it
+	workConn.writeBuf.Reset()
+	homeConn.writeBuf.Reset()
+	bobConn.writeBuf.Reset()
+	handle(t, work, protocol.TypeStartDM, &protocol.StartDMMessage{TargetType: protocol.DMTargetByNickname, TargetNickname: "bob", Ratchet: true, MultiDevice: true}, srv.handleStartDM)
+	for name, conn := range map[string]*mockConn{"work": workConn, "home": homeConn, "bob": bobConn} {
+		ready := &protocol.DMReadyMessage{}
+		if frame := next(t, conn); frame.Type != protocol.TypeDMReady || ready.Decode(frame.Payload) != nil {
+			t.Fatalf("expected DM_READY for %s, got 0x%02X", name, frame.Type)
+		}
+		if !ready.IsEncrypted || !ready.MultiDevice {
+			t.Errorf("expected an encrypted multi-device DM for %s, got %+v", name, ready)
+		}
+	}
+
+	// Revoking the home device moves the user's key back to the work one
+	// and tells every session, and bob's so they stop encrypting for it
+	workConn.writeBuf.Reset()
+	homeConn.writeBuf.Reset()
+	bobConn.writeBuf.Reset()
+	handle(t, work, protocol.TypeRevokeDevice, &protocol.RevokeDeviceMessage{DeviceID: info.Devices[1].DeviceID}, srv.handleRevokeDevice)
+	for name, conn := range map[string]*mockConn{"work": workConn, "home": homeConn, "bob": bobConn} {
+		revoked := &protocol.DeviceRevokedMessage{}
+		if frame := next(t, conn); frame.Type != protocol.TypeDeviceRevoked || revoked.Decode(frame.Payload) != nil || revoked.DeviceID != info.Devices[1].DeviceID {
+			t.Errorf("expected DEVICE_REVOKED for %s, got 0x%02X", name, frame.Type)
+		}
+	}
+	if key := userKey(t); key != 1 {
+		t.Errorf("expected the work key to be the user's key, got %d", key)
+	}
+	if devices := srv.deviceInfos(aliceID); len(devices) != 1 || devices[0].DeviceID != workDevice {
+		t.Errorf("expected only the work device, got %+v", devices)
+	}
+
+	// The revoked key can't be provided again
+	homeConn.writeBuf.Reset()
+	provide(t, home, 2, "home")
+	errMsg := &protocol.ErrorMessage{}
+	if frame := next(t, homeConn); frame.Type != protocol.TypeError || errMsg.Decode(frame.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+		t.Errorf("expected a permission denied error, got 0x%02X", frame.Type)
+	}
+	if key := userKey(t); key != 1 {
+		t.Errorf("expected the revoked key not to return, got %d", key)
+	}
+
+	// Another user's device can't be revoked
+	bobConn.writeBuf.Reset()
+	handle(t, bob, protocol.TypeRevokeDevice, &protocol.RevokeDeviceMessage{DeviceID: workDevice}, srv.handleRevokeDevice)
+	if frame := next(t, bobConn); frame.Type != protocol.TypeError || errMsg.Decode(frame.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodeNotFound {
+		t.Errorf("expected a not found error, got 0x%02X", frame.Type)
+	}
+}
+
+func TestMultiDeviceNegotiation(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, _ := srv.db.CreateUser("alice", "hash", 0)
+	bobID, _ := srv.db.CreateUser("bob", "hash", 0)
+	register := func(userID int64, key byte, multiDevice bool) {
+		t.Helper()
+		if _, _, err := srv.db.RegisterUserDevice(userID, bytes.Repeat([]byte{key}, 32), "", multiDevice); err != nil {
+			t.Fatalf("RegisterUserDevice: %v", err)
+		}
+	}
+	register(aliceID, 1, true)
+	register(bobID, 2, true)
+	register(bobID, 3, false)
+
+	channelID, err := srv.db.CreateDMChannel(aliceID, bobID, true)
+	if err != nil {
+		t.Fatalf("CreateDMChannel: %v", err)
+	}
+	// One of bob's devices has an older client that couldn't read it
+	if srv.negotiateDMMultiDevice(channelID, true, aliceID, bobID) {
+		t.Error("expected no multi-device DM with an older device")
+	}
+	register(bobID, 3, true)
+	if srv.negotiateDMMultiDevice(channelID, false, aliceID, bobID) {
+		t.Error("expected no multi-device DM for an older initiator")
+	}
+	if !srv.negotiateDMMultiDevice(channelID, true, aliceID, bobID) {
+		t.Error("expected a multi-device DM")
+	}
+	if multiDevice, err := srv.db.IsDMMultiDevice(channelID); err != nil || !multiDevice {
+		t.Errorf("expected the DM to be stored as multi-device: %v", err)
+	}
+}
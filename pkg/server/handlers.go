@@ -74,6 +74,22 @@ func optionalUint64FromInt64Ptr(v *int64) *uint64 {
 	return &converted
 }
 
+func int64PtrFromUint64(v *uint64) *int64 {
+	if v == nil {
+		return nil
+	}
+	converted := int64(*v)
+	return &converted
+}
+
+func uint64PtrFromInt64(v *int64) *uint64 {
+	if v == nil {
+		return nil
+	}
+	converted := uint64(*v)
+	return &converted
+}
+
 func (s *Server) buildServerPresenceMessage(sess *Session, online bool) *protocol.ServerPresenceMessage {
 	sess.mu.RLock()
 	nickname := sess.Nickname
@@ -89,7 +105,7 @@ func (s *Server) buildServerPresenceMessage(sess *Session, online bool) *protoco
 		SessionID:    sess.ID,
 		Nickname:     nickname,
 		IsRegistered: userID != nil,
-		UserFlags:    protocol.UserFlags(userFlags),
+		UserFlags:    protocol.UserFlags(userFlags) &^ protocol.UserFlagModerator, // Moderator is a channel role
 		Online:       online,
 	}
 
@@ -147,7 +163,7 @@ func (s *Server) buildChannelPresenceMessage(channelID int64, subchannelID *uint
 		SessionID:    sess.ID,
 		Nickname:     nickname,
 		IsRegistered: userID != nil,
-		UserFlags:    protocol.UserFlags(userFlags),
+		UserFlags:    channelUserFlags(s.db, channelID, userID, userFlags),
 		Joined:       joined,
 	}
 
@@ -223,6 +239,23 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 		return s.sendMessage(sess, protocol.TypeAuthResponse, resp)
 	}
 
+	// Accounts with an authenticator, or that must have one, need a second
+	// step before they're logged in
+	challenge, err := s.loginChallenge(user)
+	if err != nil {
+		return s.dbError(sess, "loginChallenge", err)
+	}
+	if challenge != protocol.AuthChallengeNone {
+		return s.challengeLogin(sess, user, challenge, msg.RequestToken)
+	}
+
+	return s.completeLogin(sess, user, 0, msg.RequestToken)
+}
+
+// completeLogin logs a session in as a user who proved who they are, with
+// their password or a resume token. resumeTokenID is the token they used, if
+// any; issueToken sends them a new one.
+func (s *Server) completeLogin(sess *Session, user *database.User, resumeTokenID int64, issueToken bool) error {
 	// Check if user is banned
 	ban, err := s.db.GetActiveBanForUser(&user.ID, &user.Nickname)
 	if err != nil {
@@ -270,6 +303,8 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 	sess.Nickname = user.Nickname
 	sess.UserFlags = user.UserFlags
 	sess.Shadowbanned = ban != nil && ban.Shadowban // Mark session as shadowbanned
+	sess.ResumeTokenID = resumeTokenID
+	sess.pendingLogin = nil
 	sess.mu.Unlock()
 
 	// Update database session
@@ -283,7 +318,7 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 	}
 
 	// Send success response
-	log.Printf("Session %d: AUTH_REQUEST succeeded for user %s (id=%d)", sess.ID, user.Nickname, user.ID)
+	log.Printf("Session %d: login succeeded for user %s (id=%d)", sess.ID, user.Nickname, user.ID)
 	flags := protocol.UserFlags(user.UserFlags)
 	resp := &protocol.AuthResponseMessage{
 		Success:   true,
@@ -295,6 +330,11 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 	if err := s.sendMessage(sess, protocol.TypeAuthResponse, resp); err != nil {
 		return err
 	}
+	if issueToken {
+		if err := s.issueResumeToken(sess, user.ID); err != nil {
+			return err
+		}
+	}
 	s.sendServerPresenceSnapshot(sess)
 	s.notifyServerPresence(sess, true)
 	return nil
@@ -349,7 +389,7 @@ func (s *Server) handleRegisterUser(sess *Session, frame *protocol.Frame) error
 	userID, err := s.db.CreateUser(nickname, string(hashedPassword), userFlags)
 	if err != nil {
 		// Check for unique constraint violation
-		if strings.Contains(err.Error(), "UNIQUE constraint") {
+		if database.IsUniqueViolation(err) {
 			resp := &protocol.RegisterResponseMessage{
 				Success: false,
 				Message: "Nickname already registered",
@@ -373,6 +413,8 @@ func (s *Server) handleRegisterUser(sess *Session, frame *protocol.Frame) error
 	// Broadcast updated presence to all clients
 	s.notifyServerPresence(sess, true)
 
+	s.webhooks.enqueue(webhookEventUserRegistered, "", webhookUser{ID: userID, Nickname: nickname})
+
 	// Send success response
 	resp := &protocol.RegisterResponseMessage{
 		Success: true,
@@ -396,9 +438,19 @@ func (s *Server) handleLogout(sess *Session, frame *protocol.Frame) error {
 	// Clear the session's authentication
 	sess.mu.Lock()
 	oldUserID := sess.UserID
+	resumeTokenID := sess.ResumeTokenID
 	sess.UserID = nil
+	sess.ResumeTokenID = 0
+	sess.pendingLogin = nil
 	sess.mu.Unlock()
 
+	// Logging out ends the login, so its resume token can't be used again
+	if oldUserID != nil && resumeTokenID != 0 {
+		if _, err := s.db.DeleteResumeToken(*oldUserID, resumeTokenID); err != nil {
+			log.Printf("Session %d: failed to delete resume token %d: %v", sess.ID, resumeTokenID, err)
+		}
+	}
+
 	if oldUserID != nil {
 		log.Printf("Session %d: Logged out (was user_id=%d), now anonymous with nickname %s", sess.ID, *oldUserID, sess.Nickname)
 	} else {
@@ -514,8 +566,8 @@ func (s *Server) handleListChannels(sess *Session, frame *protocol.Frame) error
 	// Apply pagination
 	channelList := make([]protocol.Channel, 0, len(dbChannels))
 	for _, dbCh := range dbChannels {
-		// Skip DM channels - they're handled separately
-		if dbCh.IsDM {
+		// Skip DM and private channels - they're handled separately
+		if dbCh.IsDM || dbCh.IsPrivate {
 			continue
 		}
 
@@ -524,6 +576,13 @@ func (s *Server) handleListChannels(sess *Session, frame *protocol.Frame) error
 			continue
 		}
 
+		// Invite-only channels are hidden from everyone who isn't invited
+		if protocol.ChannelModes(dbCh.Modes).Has(protocol.ChannelModeInviteOnly) {
+			if code, _ := s.channelAccessDenied(sess, dbCh); code != 0 {
+				continue
+			}
+		}
+
 		channelSub := ChannelSubscription{ChannelID: uint64(dbCh.ID)}
 		userCount := uint32(len(s.sessions.GetChannelSubscribers(channelSub)))
 
@@ -541,6 +600,8 @@ func (s *Server) handleListChannels(sess *Session, frame *protocol.Frame) error
 			RetentionHours:  dbCh.MessageRetentionHours,
 			HasSubchannels:  subchannelCount > 0,
 			SubchannelCount: uint16(subchannelCount),
+			Archived:        dbCh.Archived,
+			Modes:           protocol.ChannelModes(dbCh.Modes),
 		}
 		channelList = append(channelList, ch)
 
@@ -599,6 +660,10 @@ func (s *Server) handleJoinChannel(sess *Session, frame *protocol.Frame) error {
 		}
 	}
 
+	if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+		return s.sendError(sess, code, message)
+	}
+
 	sess.mu.RLock()
 	previousJoined := sess.JoinedChannel
 	sess.mu.RUnlock()
@@ -801,11 +866,26 @@ func (s *Server) handleCreateChannel(sess *Session, frame *protocol.Frame) error
 		})
 	}
 
-	// Create channel in database
-	channelID, err := s.db.CreateChannel(msg.Name, msg.DisplayName, msg.Description, msg.ChannelType, msg.RetentionHours, userID)
+	// Enforce channel creation rate limit
+	if ok, err := s.checkChannelCreateRateLimit(sess); !ok {
+		return err
+	}
+
+	// Create channel in database. Private channels start with their creator
+	// as the only member.
+	var channelID int64
+	var err error
+	if msg.Private {
+		sess.mu.RLock()
+		nickname := sess.Nickname
+		sess.mu.RUnlock()
+		channelID, err = s.db.CreatePrivateChannel(msg.Name, msg.DisplayName, msg.Description, msg.ChannelType, msg.RetentionHours, *userID, nickname)
+	} else {
+		channelID, err = s.db.CreateChannel(msg.Name, msg.DisplayName, msg.Description, msg.ChannelType, msg.RetentionHours, userID)
+	}
 	if err != nil {
 		// Check if it's a duplicate name error
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") || strings.Contains(err.Error(), "already exists") {
+		if database.IsUniqueViolation(err) || strings.Contains(err.Error(), "already exists") {
 			return s.sendMessage(sess, protocol.TypeChannelCreated, &protocol.ChannelCreatedMessage{
 				Success: false,
 				Message: "Channel name already exists",
@@ -830,6 +910,13 @@ func (s *Server) handleCreateChannel(sess *Session, frame *protocol.Frame) error
 		return err
 	}
 
+	// Nobody else hears about private channels until they are invited. The
+	// creator makes the first channel key.
+	if msg.Private {
+		s.requestChannelRekey(channelID, sess)
+		return nil
+	}
+
 	// Construct channel object for broadcast (we have all the data)
 	now := time.Now().UnixMilli()
 	createdChannel := &database.Channel{
@@ -850,6 +937,132 @@ func (s *Server) handleCreateChannel(sess *Session, frame *protocol.Frame) error
 	return nil
 }
 
+// handleUpdateChannel handles UPDATE_CHANNEL message (channel creator or admin)
+func (s *Server) handleUpdateChannel(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.UpdateChannelMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendMessage(sess, protocol.TypeChannelUpdated, &protocol.ChannelUpdatedMessage{
+			Success: false,
+			Message: "Invalid request format",
+		})
+	}
+
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeChannelUpdated, &protocol.ChannelUpdatedMessage{
+			Success:   false,
+			ChannelID: msg.ChannelID,
+			Message:   message,
+		})
+	}
+
+	// DM channels have no settings to change
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || channel.IsDM {
+		return fail("Channel not found")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+
+	isOwner := s.channelRole(sess, channel.ID) == database.ChannelRoleOwner
+	if !isOwner && !s.isAdmin(sess) {
+		return fail("Permission denied: only the channel owner or an admin can change this channel")
+	}
+
+	// Start from the current settings and apply the fields that were sent,
+	// with the same limits as CREATE_CHANNEL
+	displayName := channel.DisplayName
+	if msg.DisplayName != nil {
+		if len(*msg.DisplayName) < 1 || len(*msg.DisplayName) > 100 {
+			return fail("Display name must be 1-100 characters")
+		}
+		displayName = *msg.DisplayName
+	}
+
+	description := channel.Description
+	if msg.Description != nil {
+		if len(*msg.Description) > 500 {
+			return fail("Description must be at most 500 characters")
+		}
+		description = msg.Description
+		if *msg.Description == "" {
+			description = nil
+		}
+	}
+
+	channelType := channel.ChannelType
+	if msg.ChannelType != nil {
+		if *msg.ChannelType != 0 && *msg.ChannelType != 1 {
+			return fail("Invalid channel type (must be 0=chat or 1=forum)")
+		}
+		channelType = *msg.ChannelType
+	}
+
+	retentionHours := channel.MessageRetentionHours
+	if msg.RetentionHours != nil {
+		if *msg.RetentionHours < 1 || *msg.RetentionHours > 8760 {
+			return fail("Retention hours must be between 1 and 8760 (1 year)")
+		}
+		retentionHours = *msg.RetentionHours
+	}
+
+	archived := channel.Archived
+	if msg.Archived != nil {
+		archived = *msg.Archived
+	}
+
+	modes := protocol.ChannelModes(channel.Modes)
+	if msg.Modes != nil {
+		if channel.ParentID != nil {
+			return fail("Modes are set on the parent channel")
+		}
+		if protocol.ChannelModes(*msg.Modes)&^protocol.ChannelModesAll != 0 {
+			return fail("Unknown channel mode")
+		}
+		modes = protocol.ChannelModes(*msg.Modes)
+	}
+
+	if err := s.db.UpdateChannel(channel.ID, displayName, description, channelType, retentionHours, archived); err != nil {
+		return s.dbError(sess, "UpdateChannel", err)
+	}
+	if uint8(modes) != channel.Modes {
+		if err := s.db.SetChannelModes(channel.ID, uint8(modes)); err != nil {
+			return s.dbError(sess, "SetChannelModes", err)
+		}
+	}
+
+	// Changes to someone else's channel are moderation
+	if !isOwner {
+		if err := s.db.LogAdminAction(uint64(*userID), nickname, "UPDATE_CHANNEL",
+			fmt.Sprintf("channel_id=%d name=%s archived=%t modes=%s", channel.ID, channel.Name, archived, modes)); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	resp := &protocol.ChannelUpdatedMessage{
+		Success:        true,
+		ChannelID:      msg.ChannelID,
+		Name:           channel.Name,
+		DisplayName:    displayName,
+		Description:    safeDeref(description, ""),
+		Type:           channelType,
+		RetentionHours: retentionHours,
+		Archived:       archived,
+		Modes:          modes,
+		Message:        fmt.Sprintf("Channel '%s' updated", displayName),
+	}
+
+	if err := s.sendMessage(sess, protocol.TypeChannelUpdated, resp); err != nil {
+		return err
+	}
+
+	s.broadcastChannelUpdated(resp, sess.ID)
+
+	return nil
+}
+
 // handleCreateSubchannel handles CREATE_SUBCHANNEL message
 func (s *Server) handleCreateSubchannel(sess *Session, frame *protocol.Frame) error {
 	msg := &protocol.CreateSubchannelMessage{}
@@ -882,15 +1095,21 @@ func (s *Server) handleCreateSubchannel(sess *Session, frame *protocol.Frame) er
 	}
 
 	// Check permission: must be channel owner or server admin
-	isOwner := parentChannel.CreatedBy != nil && *parentChannel.CreatedBy == *userID
-	isAdmin := s.isAdmin(sess)
-	if !isOwner && !isAdmin {
+	if !s.can(sess, parentChannel.ID, permCreateSubchannel) {
 		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
 			Success: false,
 			Message: "Only the channel owner or admins can create subchannels",
 		})
 	}
 
+	// Private channels don't have subchannels
+	if parentChannel.IsPrivate {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Private channels cannot have subchannels",
+		})
+	}
+
 	// Don't allow creating sub-subchannels (only one level of nesting)
 	if parentChannel.ParentID != nil {
 		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
@@ -931,6 +1150,11 @@ func (s *Server) handleCreateSubchannel(sess *Session, frame *protocol.Frame) er
 		})
 	}
 
+	// Enforce channel creation rate limit (shared with top-level channels)
+	if ok, err := s.checkChannelCreateRateLimit(sess); !ok {
+		return err
+	}
+
 	// Create subchannel display name (prefix with parent name)
 	displayName := fmt.Sprintf("%s/%s", parentChannel.DisplayName, msg.Name)
 
@@ -942,7 +1166,7 @@ func (s *Server) handleCreateSubchannel(sess *Session, frame *protocol.Frame) er
 	subchannelID, err := s.db.CreateSubchannel(int64(msg.ChannelID), msg.Name, displayName, descPtr, msg.Type, msg.RetentionHours, userID)
 	if err != nil {
 		// Check if it's a duplicate name error
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") || strings.Contains(err.Error(), "already exists") {
+		if database.IsUniqueViolation(err) || strings.Contains(err.Error(), "already exists") {
 			return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
 				Success: false,
 				Message: "Subchannel name already exists in this channel",
@@ -1036,6 +1260,20 @@ func (s *Server) handleListMessages(sess *Session, frame *protocol.Frame) error
 		return s.sendError(sess, 1000, "Invalid message format")
 	}
 
+	// Only members may read DMs and private channels. Thread replies are
+	// checked against the channel of the message they reply to.
+	channelID := int64(msg.ChannelID)
+	if msg.ParentID != nil {
+		if parent, err := s.db.GetMessage(int64(*msg.ParentID)); err == nil {
+			channelID = parent.ChannelID
+		}
+	}
+	if channel, err := s.db.GetChannel(channelID); err == nil {
+		if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+			return s.sendError(sess, code, message)
+		}
+	}
+
 	var messages []protocol.Message
 
 	if msg.ParentID != nil {
@@ -1071,6 +1309,286 @@ func (s *Server) handleListMessages(sess *Session, frame *protocol.Frame) error
 	return s.sendMessage(sess, protocol.TypeMessageList, resp)
 }
 
+// Search result page sizes
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+	maxSearchQueryLen  = 256
+)
+
+// handleSearchMessages handles SEARCH_MESSAGES message
+func (s *Server) handleSearchMessages(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.SearchMessagesMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	query := strings.TrimSpace(msg.Query)
+	if query == "" {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Search query is empty")
+	}
+	if len(query) > maxSearchQueryLen {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, fmt.Sprintf("Search query too long (max %d bytes)", maxSearchQueryLen))
+	}
+
+	limit := int(msg.Limit)
+	if limit == 0 {
+		limit = defaultSearchLimit
+	} else if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	// Searching a specific channel: give a clear error for missing or inaccessible channels
+	if msg.ChannelID != nil {
+		channel, err := s.db.GetChannel(int64(*msg.ChannelID))
+		if err != nil || channel == nil {
+			return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel not found")
+		}
+		if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+			return s.sendError(sess, code, message)
+		}
+	}
+
+	privateChannelIDs, err := s.accessiblePrivateChannelIDs(sess)
+	if err != nil {
+		return s.dbError(sess, "accessiblePrivateChannelIDs", err)
+	}
+	hiddenChannelIDs, err := s.hiddenChannelIDs(sess)
+	if err != nil {
+		return s.dbError(sess, "hiddenChannelIDs", err)
+	}
+
+	filter := database.SearchFilter{
+		ChannelID:         int64PtrFromUint64(msg.ChannelID),
+		SubchannelID:      int64PtrFromUint64(msg.SubchannelID),
+		ThreadID:          int64PtrFromUint64(msg.ThreadID),
+		BeforeID:          int64PtrFromUint64(msg.BeforeID),
+		PrivateChannelIDs: privateChannelIDs,
+		HiddenChannelIDs:  hiddenChannelIDs,
+		Limit:             limit + 1, // One extra to detect whether there are more results
+	}
+	if msg.Author != nil {
+		// Accept nicknames as displayed (~anonymous, $admin, @moderator)
+		author := strings.TrimLeft(strings.TrimSpace(*msg.Author), "~$@")
+		if author != "" {
+			filter.AuthorNickname = &author
+		}
+	}
+	if msg.After != nil {
+		after := msg.After.UnixMilli()
+		filter.After = &after
+	}
+	if msg.Before != nil {
+		before := msg.Before.UnixMilli()
+		filter.Before = &before
+	}
+
+	dbMessages, err := s.db.SearchMessages(query, filter)
+	if err != nil {
+		return s.dbError(sess, "SearchMessages", err)
+	}
+
+	hasMore := len(dbMessages) > limit
+	if hasMore {
+		dbMessages = dbMessages[:limit]
+	}
+
+	resp := &protocol.SearchResultsMessage{
+		Query:   msg.Query,
+		Results: s.searchResultsWithRoots(dbMessages),
+		HasMore: hasMore,
+	}
+	return s.sendMessage(sess, protocol.TypeSearchResults, resp)
+}
+
+// searchResultsWithRoots converts messages to search results, including each
+// message's thread root so clients can open the thread directly
+func (s *Server) searchResultsWithRoots(dbMessages []*database.Message) []protocol.SearchResult {
+	roots := make(map[int64]*protocol.Message)
+	roles := newChannelRoleCache(s.db)
+	results := make([]protocol.SearchResult, len(dbMessages))
+	for i, dbMsg := range dbMessages {
+		results[i].Message = *convertDBMessage(dbMsg, s.db, roles)
+		if dbMsg.ThreadRootID == nil || *dbMsg.ThreadRootID == dbMsg.ID {
+			continue
+		}
+		rootID := *dbMsg.ThreadRootID
+		root, cached := roots[rootID]
+		if !cached {
+			if dbRoot, err := s.db.GetMessage(rootID); err == nil {
+				root = convertDBMessage(dbRoot, s.db, roles)
+			}
+			roots[rootID] = root
+		}
+		results[i].ThreadRoot = root
+	}
+	return results
+}
+
+// Mention list page sizes
+const (
+	defaultMentionLimit = 50
+	maxMentionLimit     = 200
+)
+
+// handleListMentions handles LIST_MENTIONS message
+func (s *Server) handleListMentions(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ListMentionsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required. Register to receive mentions.")
+	}
+
+	limit := int(msg.Limit)
+	if limit == 0 {
+		limit = defaultMentionLimit
+	} else if limit > maxMentionLimit {
+		limit = maxMentionLimit
+	}
+
+	// One extra to detect whether there are more mentions
+	dbMessages, err := s.db.ListMentions(*userID, int64PtrFromUint64(msg.BeforeID), limit+1)
+	if err != nil {
+		return s.dbError(sess, "ListMentions", err)
+	}
+
+	hasMore := len(dbMessages) > limit
+	if hasMore {
+		dbMessages = dbMessages[:limit]
+	}
+
+	resp := &protocol.MentionListMessage{
+		Mentions: s.searchResultsWithRoots(dbMessages),
+		HasMore:  hasMore,
+	}
+	return s.sendMessage(sess, protocol.TypeMentionList, resp)
+}
+
+// notifyMentions records @mentions of registered users in a new message and
+// pushes MENTION_NOTIFICATION to every online session of the mentioned users.
+// Authors don't mention themselves, and DM mentions only reach participants.
+func (s *Server) notifyMentions(dbMsg *database.Message, channel *database.Channel) {
+	nicknames := protocol.ParseMentions(dbMsg.Content)
+	if len(nicknames) == 0 {
+		return
+	}
+
+	users, err := s.db.GetUsersByNicknames(nicknames)
+	if err != nil {
+		errorLog.Printf("Failed to resolve mentions in message %d: %v", dbMsg.ID, err)
+		return
+	}
+
+	var mentions []database.Mention
+	for _, user := range users {
+		if dbMsg.AuthorUserID != nil && *dbMsg.AuthorUserID == user.ID {
+			continue
+		}
+		if code, _ := s.userChannelAccessDenied(user, channel); code != 0 {
+			continue
+		}
+		mentions = append(mentions, database.Mention{
+			UserID:    user.ID,
+			MessageID: dbMsg.ID,
+			ChannelID: dbMsg.ChannelID,
+			CreatedAt: dbMsg.CreatedAt,
+		})
+	}
+	if len(mentions) == 0 {
+		return
+	}
+
+	if err := s.db.RecordMentions(mentions); err != nil {
+		errorLog.Printf("Failed to record mentions in message %d: %v", dbMsg.ID, err)
+		return
+	}
+
+	notification := &protocol.MentionNotificationMessage{
+		Mention: s.searchResultsWithRoots([]*database.Message{dbMsg})[0],
+	}
+	for _, mention := range mentions {
+		s.sendToUserSessions(mention.UserID, protocol.TypeMentionNotification, notification)
+	}
+}
+
+// canAccessPrivateChannel reports whether the session's user (or anonymous
+// session) is a member of a DM or private channel
+func (s *Server) canAccessPrivateChannel(sess *Session, channelID int64) bool {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sessionID := sess.DBSessionID
+	sess.mu.RUnlock()
+
+	if userID != nil {
+		return s.userCanAccessPrivateChannel(*userID, channelID)
+	}
+	ok, err := s.db.IsChannelParticipant(channelID, nil, sessionID)
+	return err == nil && ok
+}
+
+// userCanAccessPrivateChannel reports whether a registered user is a member of
+// a DM or private channel
+func (s *Server) userCanAccessPrivateChannel(userID, channelID int64) bool {
+	if ok, err := s.db.UserHasAccessToChannel(userID, channelID); err == nil && ok {
+		return true
+	}
+	ok, err := s.db.IsChannelParticipant(channelID, &userID, 0)
+	return err == nil && ok
+}
+
+// privateChannelDenied checks that the session is a member of a DM or private
+// channel. It returns the error to send, or 0 if the session may read it.
+// Public channels are never denied here.
+func (s *Server) privateChannelDenied(sess *Session, channel *database.Channel) (uint16, string) {
+	if !channel.IsPrivate || s.canAccessPrivateChannel(sess, channel.ID) {
+		return 0, ""
+	}
+	if channel.IsDM {
+		return protocol.ErrCodePermissionDenied, "Not a participant in this DM"
+	}
+	return protocol.ErrCodeChannelPrivate, "Channel is private"
+}
+
+// accessiblePrivateChannelIDs lists the DM and private channels the session may read
+func (s *Server) accessiblePrivateChannelIDs(sess *Session) ([]int64, error) {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sessionID := sess.DBSessionID
+	sess.mu.RUnlock()
+
+	channelIDs, err := s.db.GetDMChannelsForParticipant(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Older DMs track membership in ChannelAccess rather than ChannelParticipant
+	if userID != nil {
+		channels, err := s.db.GetDMChannels(*userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range channels {
+			channelIDs = append(channelIDs, ch.ID)
+		}
+
+		private, err := s.db.GetPrivateChannelsForUser(*userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range private {
+			channelIDs = append(channelIDs, ch.ID)
+		}
+	}
+	return channelIDs, nil
+}
+
 // handlePostMessage handles POST_MESSAGE message
 func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 	// Decode message
@@ -1094,6 +1612,11 @@ func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 		return s.sendError(sess, 6001, fmt.Sprintf("Message too long (max %d bytes)", s.config.MaxMessageLength))
 	}
 
+	// Enforce message rate limit
+	if ok, err := s.checkMessageRateLimit(sess); !ok {
+		return err
+	}
+
 	// Convert IDs
 	var subchannelID, parentID *int64
 	if msg.SubchannelID != nil {
@@ -1125,6 +1648,18 @@ func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 		}
 	}
 
+	if s.channelArchived(channel, subchannelID) {
+		return s.sendError(sess, protocol.ErrCodeChannelArchived, "Channel is archived and read-only")
+	}
+
+	if code, message := s.channelPostDenied(sess, channel); code != 0 {
+		return s.sendError(sess, code, message)
+	}
+
+	if mute := s.activeMute(sess, channel.ID); mute != nil {
+		return s.sendError(sess, protocol.ErrCodeMuted, muteNotice(mute))
+	}
+
 	if channel.ChannelType == 0 && parentID != nil {
 		return s.sendError(sess, 6000, "Chat channels do not support threaded replies")
 	}
@@ -1170,6 +1705,14 @@ func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 		fmt.Printf("Failed to broadcast new message: %v\n", err)
 	}
 
+	// Shadowbanned messages are invisible to others, so they don't notify anyone
+	sess.mu.RLock()
+	isShadowbanned := sess.Shadowbanned
+	sess.mu.RUnlock()
+	if !isShadowbanned {
+		s.notifyMentions(dbMsg, channel)
+	}
+
 	return nil
 }
 
@@ -1195,15 +1738,26 @@ func (s *Server) handleEditMessage(sess *Session, frame *protocol.Frame) error {
 		return s.sendError(sess, protocol.ErrCodeMessageTooLong, fmt.Sprintf("Message too long (max %d bytes)", s.config.MaxMessageLength))
 	}
 
-	// Check if user is admin - admins can edit any message
-	isAdmin := s.isAdmin(sess)
+	// Archived channels are read-only, for admins too, and muted users can't
+	// change what they already posted. Admins and the channel's owners and
+	// moderators can edit any message.
+	canEditOthers := false
+	if existing, err := s.db.GetMessage(int64(msg.MessageID)); err == nil {
+		if channel, err := s.db.GetChannel(existing.ChannelID); err == nil && s.channelArchived(channel, existing.SubchannelID) {
+			return s.sendError(sess, protocol.ErrCodeChannelArchived, "Channel is archived and read-only")
+		}
+		if mute := s.activeMute(sess, existing.ChannelID); mute != nil {
+			return s.sendError(sess, protocol.ErrCodeMuted, muteNotice(mute))
+		}
+		canEditOthers = s.can(sess, existing.ChannelID, permEditOthersMessages)
+	}
 
 	// Update message in database
 	var dbMsg *database.Message
 	var err error
 
-	if isAdmin {
-		// Admin edit: bypass ownership check
+	if canEditOthers {
+		// Moderator edit: bypass ownership check
 		dbMsg, err = s.db.AdminUpdateMessage(msg.MessageID, uint64(*userID), msg.NewContent)
 	} else {
 		// Regular edit: check ownership
@@ -1246,6 +1800,187 @@ func (s *Server) handleEditMessage(sess *Session, frame *protocol.Frame) error {
 		log.Printf("Failed to broadcast message edit: %v", err)
 	}
 
+	s.authorMessageEvent(webhookEventMessageEdited, dbMsg, nil)
+
+	return nil
+}
+
+// handleGetMessageHistory handles GET_MESSAGE_HISTORY message
+func (s *Server) handleGetMessageHistory(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.GetMessageHistoryMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	notFound := &protocol.MessageHistoryMessage{MessageID: msg.MessageID, Message: "Message not found"}
+
+	dbMsg, err := s.db.GetMessage(int64(msg.MessageID))
+	if err != nil {
+		return s.sendMessage(sess, protocol.TypeMessageHistory, notFound)
+	}
+	channel, err := s.db.GetChannel(dbMsg.ChannelID)
+	if err != nil {
+		return s.sendMessage(sess, protocol.TypeMessageHistory, notFound)
+	}
+	if code, _ := s.channelAccessDenied(sess, channel); code != 0 {
+		return s.sendMessage(sess, protocol.TypeMessageHistory, notFound)
+	}
+
+	versions, err := s.db.GetMessageVersions(dbMsg.ID)
+	if err != nil {
+		return s.dbError(sess, "GetMessageVersions", err)
+	}
+
+	// Content of deleted messages is only shown to admins, for moderation
+	hidden := dbMsg.DeletedAt != nil && !s.isAdmin(sess)
+
+	resp := &protocol.MessageHistoryMessage{
+		Success:   true,
+		MessageID: msg.MessageID,
+		Revisions: messageRevisions(dbMsg, versions, hidden),
+	}
+	return s.sendMessage(sess, protocol.TypeMessageHistory, resp)
+}
+
+// messageRevisions turns MessageVersion rows into the content of the message
+// after each change. Edit and delete rows store the content from before the
+// change, so each revision's content comes from the row after it, and the
+// last one from the message itself. "created" rows only repeat the original
+// content and aren't needed.
+func messageRevisions(msg *database.Message, versions []database.MessageVersion, hidden bool) []protocol.MessageRevision {
+	var changes []database.MessageVersion
+	var deleted *database.MessageVersion
+	for i := range versions {
+		switch versions[i].VersionType {
+		case "edited":
+			changes = append(changes, versions[i])
+		case "deleted":
+			deleted = &versions[i]
+		}
+	}
+
+	// Content before the first change, then after each one
+	contents := make([]string, 0, len(changes)+1)
+	for _, change := range changes {
+		contents = append(contents, change.Content)
+	}
+	switch {
+	case deleted != nil:
+		contents = append(contents, deleted.Content)
+	case msg.DeletedAt != nil:
+		contents = append(contents, "") // Deleted before deletions were versioned
+	default:
+		contents = append(contents, msg.Content)
+	}
+
+	revisions := make([]protocol.MessageRevision, 0, len(contents)+1)
+	revisions = append(revisions, protocol.MessageRevision{
+		Kind:      protocol.RevisionCreated,
+		Timestamp: time.UnixMilli(msg.CreatedAt),
+		Content:   contents[0],
+	})
+	for i, change := range changes {
+		revisions = append(revisions, protocol.MessageRevision{
+			Kind:      protocol.RevisionEdited,
+			Timestamp: time.UnixMilli(change.CreatedAt),
+			Content:   contents[i+1],
+		})
+	}
+	if msg.DeletedAt != nil {
+		revisions = append(revisions, protocol.MessageRevision{
+			Kind:      protocol.RevisionDeleted,
+			Timestamp: time.UnixMilli(*msg.DeletedAt),
+			Content:   contents[len(contents)-1],
+		})
+	}
+
+	if hidden {
+		for i := range revisions {
+			revisions[i].Content = ""
+			revisions[i].Hidden = true
+		}
+	}
+	return revisions
+}
+
+// handlePlusOne handles PLUS_ONE message
+func (s *Server) handlePlusOne(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.PlusOneMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	nickname := sess.Nickname
+	isShadowbanned := sess.Shadowbanned
+	sess.mu.RUnlock()
+
+	if nickname == "" {
+		return s.sendError(sess, protocol.ErrCodeNicknameRequired, "Nickname required. Use SET_NICKNAME first.")
+	}
+
+	dbMsg, err := s.db.GetMessage(int64(msg.MessageID))
+	if err != nil || dbMsg.DeletedAt != nil {
+		return s.sendError(sess, protocol.ErrCodeMessageNotFound, "Message not found")
+	}
+
+	channel, err := s.db.GetChannel(dbMsg.ChannelID)
+	if err != nil {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel not found")
+	}
+	if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+		return s.sendError(sess, code, message)
+	}
+
+	// Shadowbanned users see their own +1 counted, but nobody else does
+	if isShadowbanned {
+		update := &protocol.PlusOneUpdateMessage{
+			MessageID:        msg.MessageID,
+			PlusOneCount:     dbMsg.PlusOneCount,
+			PlusOneAnonCount: dbMsg.PlusOneAnonCount,
+		}
+		if userID != nil {
+			update.PlusOneCount++
+		} else {
+			update.PlusOneAnonCount++
+		}
+		return s.sendMessage(sess, protocol.TypePlusOneUpdate, update)
+	}
+
+	dbMsg, counted, err := s.db.PlusOneMessage(msg.MessageID, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrMessageNotFound) {
+			return s.sendError(sess, protocol.ErrCodeMessageNotFound, "Message not found")
+		}
+		return s.dbError(sess, "PlusOneMessage", err)
+	}
+
+	update := &protocol.PlusOneUpdateMessage{
+		MessageID:        msg.MessageID,
+		PlusOneCount:     dbMsg.PlusOneCount,
+		PlusOneAnonCount: dbMsg.PlusOneAnonCount,
+	}
+
+	// Confirm to the sender even if they aren't subscribed
+	if err := s.sendMessage(sess, protocol.TypePlusOneUpdate, update); err != nil {
+		return err
+	}
+
+	// Repeat +1 from a registered user: nothing changed
+	if !counted {
+		return nil
+	}
+
+	// Thread viewers see the root and its replies; the root's own ID is the thread ID
+	threadRootID := dbMsg.ID
+	if dbMsg.ThreadRootID != nil {
+		threadRootID = *dbMsg.ThreadRootID
+	}
+	if err := s.broadcastToChannelAndThread(dbMsg.ChannelID, &threadRootID, protocol.TypePlusOneUpdate, update); err != nil {
+		log.Printf("Failed to broadcast +1 update: %v", err)
+	}
+
 	return nil
 }
 
@@ -1264,14 +1999,17 @@ func (s *Server) handleDeleteMessage(sess *Session, frame *protocol.Frame) error
 		return s.sendError(sess, protocol.ErrCodeNicknameRequired, "Nickname required. Use SET_NICKNAME first.")
 	}
 
-	// Check if user is admin - admins can delete any message
-	isAdmin := s.isAdmin(sess)
+	// Admins and the channel's owners and moderators can delete any message
+	canDeleteOthers := false
+	if existing, err := s.db.GetMessage(int64(msg.MessageID)); err == nil {
+		canDeleteOthers = s.can(sess, existing.ChannelID, permDeleteOthersMessages)
+	}
 
 	var dbMsg *database.Message
 	var err error
 
-	if isAdmin {
-		// Admin delete: bypass ownership check
+	if canDeleteOthers {
+		// Moderator delete: bypass ownership check
 		dbMsg, err = s.db.AdminSoftDeleteMessage(msg.MessageID, nickname)
 	} else {
 		// Regular delete: check ownership
@@ -1309,6 +2047,8 @@ func (s *Server) handleDeleteMessage(sess *Session, frame *protocol.Frame) error
 		log.Printf("Failed to broadcast message deletion: %v", err)
 	}
 
+	s.authorMessageEvent(webhookEventMessageDeleted, dbMsg, &deletedAtMs)
+
 	return nil
 }
 
@@ -1362,6 +2102,11 @@ func (s *Server) handleChangePassword(sess *Session, frame *protocol.Frame) erro
 			return s.sendError(sess, protocol.ErrCodeDatabaseError, "Failed to remove password")
 		}
 
+		// Resume tokens come from password logins, so none of them stay
+		if _, err := s.db.DeleteUserResumeTokens(*userID, 0); err != nil {
+			log.Printf("Failed to delete resume tokens for user %d: %v", *userID, err)
+		}
+
 		sess.mu.RLock()
 		nickname := sess.Nickname
 		sess.mu.RUnlock()
@@ -1391,8 +2136,14 @@ func (s *Server) handleChangePassword(sess *Session, frame *protocol.Frame) erro
 
 	sess.mu.RLock()
 	nickname := sess.Nickname
+	resumeTokenID := sess.ResumeTokenID
 	sess.mu.RUnlock()
 
+	// Other logins have to use the new password
+	if _, err := s.db.DeleteUserResumeTokens(*userID, resumeTokenID); err != nil {
+		log.Printf("Failed to delete resume tokens for user %d: %v", *userID, err)
+	}
+
 	log.Printf("User %s (ID: %d) changed password", nickname, *userID)
 	return s.sendPasswordChanged(sess, true, "")
 }
@@ -1715,6 +2466,12 @@ func (s *Server) sendMessage(sess *Session, msgType uint8, msg interface{}) erro
 
 // broadcastToChannel sends a message to all sessions in a channel
 func (s *Server) broadcastToChannel(channelID int64, msgType uint8, msg interface{}) error {
+	return s.broadcastToChannelAndThread(channelID, nil, msgType, msg)
+}
+
+// broadcastToChannelAndThread is broadcastToChannel plus the subscribers of a
+// thread, for updates to messages shown in thread views. threadRootID may be nil.
+func (s *Server) broadcastToChannelAndThread(channelID int64, threadRootID *int64, msgType uint8, msg interface{}) error {
 	// Encode message payload
 	var payload []byte
 	var err error
@@ -1770,6 +2527,13 @@ func (s *Server) broadcastToChannel(channelID int64, msgType uint8, msg interfac
 		targetSessionsMap[sess.ID] = sess
 	}
 
+	// 3. Get sessions subscribed to the thread
+	if threadRootID != nil {
+		for _, sess := range s.sessions.GetThreadSubscribers(uint64(*threadRootID)) {
+			targetSessionsMap[sess.ID] = sess
+		}
+	}
+
 	// Convert map to slice
 	targetSessions := make([]*Session, 0, len(targetSessionsMap))
 	for _, sess := range targetSessionsMap {
@@ -1853,7 +2617,8 @@ func (s *Server) broadcastToSessionsVersionAware(sessions []*Session, v1Bytes, v
 }
 
 // broadcastNewMessage sends a NEW_MESSAGE to subscribed sessions only (subscription-aware)
-// If authorSess is shadowbanned, the message is only sent to the author and admins
+// If authorSess is shadowbanned, the message is only sent to the author and admins.
+// authorSess is nil for messages that don't come from a session (incoming webhooks).
 func (s *Server) broadcastNewMessage(authorSess *Session, msg *protocol.NewMessageMessage, threadRootID *uint64) error {
 	startTime := time.Now()
 
@@ -1916,10 +2681,13 @@ func (s *Server) broadcastNewMessage(authorSess *Session, msg *protocol.NewMessa
 		debugLog.Printf("WARNING: Reply message %d has no threadRootID - will not be broadcast!", msg.ID)
 	}
 
-	// Filter recipients if author is shadowbanned
-	authorSess.mu.RLock()
-	isShadowbanned := authorSess.Shadowbanned
-	authorSess.mu.RUnlock()
+	// Filter recipients if author is shadowbanned (authorSess is nil for incoming webhooks)
+	isShadowbanned := false
+	if authorSess != nil {
+		authorSess.mu.RLock()
+		isShadowbanned = authorSess.Shadowbanned
+		authorSess.mu.RUnlock()
+	}
 
 	if isShadowbanned {
 		// Shadowbanned: only send to author and admins
@@ -1953,20 +2721,32 @@ func (s *Server) broadcastNewMessage(authorSess *Session, msg *protocol.NewMessa
 		s.metrics.RecordBroadcastDuration(broadcastType, time.Since(startTime).Seconds())
 	}
 
+	// Shadowbanned messages stay on the server
+	if !isShadowbanned {
+		s.webhooks.messageEvent(webhookEventMessageCreated, (*protocol.Message)(msg), nil)
+	}
+
 	return nil
 }
 
 // convertDBMessagesToProtocol converts database messages to protocol messages
-func convertDBMessagesToProtocol(dbMessages []*database.Message, db *database.MemDB) []protocol.Message {
+func convertDBMessagesToProtocol(dbMessages []*database.Message, db database.Store) []protocol.Message {
 	messages := make([]protocol.Message, len(dbMessages))
+	roles := newChannelRoleCache(db)
 	for i, dbMsg := range dbMessages {
-		messages[i] = *convertDBMessageToProtocol(dbMsg, db)
+		messages[i] = *convertDBMessage(dbMsg, db, roles)
 	}
 	return messages
 }
 
 // convertDBMessageToProtocol converts a database message to protocol message
-func convertDBMessageToProtocol(dbMsg *database.Message, db *database.MemDB) *protocol.Message {
+func convertDBMessageToProtocol(dbMsg *database.Message, db database.Store) *protocol.Message {
+	return convertDBMessage(dbMsg, db, newChannelRoleCache(db))
+}
+
+// convertDBMessage converts a database message, looking up the author's
+// channel role in roles
+func convertDBMessage(dbMsg *database.Message, db database.Store, roles *channelRoleCache) *protocol.Message {
 	var subchannelID, parentID, authorUserID *uint64
 	var editedAt *time.Time
 
@@ -1993,7 +2773,7 @@ func convertDBMessageToProtocol(dbMsg *database.Message, db *database.MemDB) *pr
 		// Registered user - lookup and apply prefix based on flags
 		user, err := db.GetUserByID(*dbMsg.AuthorUserID)
 		if err == nil {
-			prefix := protocol.UserFlags(user.UserFlags).DisplayPrefix()
+			prefix := roles.userFlags(dbMsg.ChannelID, &user.ID, user.UserFlags).DisplayPrefix()
 			nickname = prefix + user.Nickname
 		} else {
 			// Fallback if user lookup fails (shouldn't happen)
@@ -2014,16 +2794,18 @@ func convertDBMessageToProtocol(dbMsg *database.Message, db *database.MemDB) *pr
 	}
 
 	return &protocol.Message{
-		ID:             uint64(dbMsg.ID),
-		ChannelID:      uint64(dbMsg.ChannelID),
-		SubchannelID:   subchannelID,
-		ParentID:       parentID,
-		AuthorUserID:   authorUserID,
-		AuthorNickname: nickname, // Prefixed for registered users, as-is for anonymous
-		Content:        dbMsg.Content,
-		CreatedAt:      time.UnixMilli(dbMsg.CreatedAt),
-		EditedAt:       editedAt,
-		ReplyCount:     replyCount,
+		ID:               uint64(dbMsg.ID),
+		ChannelID:        uint64(dbMsg.ChannelID),
+		SubchannelID:     subchannelID,
+		ParentID:         parentID,
+		AuthorUserID:     authorUserID,
+		AuthorNickname:   nickname, // Prefixed for registered users, as-is for anonymous
+		Content:          dbMsg.Content,
+		CreatedAt:        time.UnixMilli(dbMsg.CreatedAt),
+		EditedAt:         editedAt,
+		ReplyCount:       replyCount,
+		PlusOneCount:     dbMsg.PlusOneCount,
+		PlusOneAnonCount: dbMsg.PlusOneAnonCount,
 	}
 }
 
@@ -2048,6 +2830,11 @@ func (s *Server) handleSubscribeThread(sess *Session, frame *protocol.Frame) err
 	if err != nil {
 		return s.dbError(sess, "GetMessage", err)
 	}
+	if channel, err := s.db.GetChannel(threadMsg.ChannelID); err == nil {
+		if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+			return s.sendError(sess, code, message)
+		}
+	}
 
 	var subchannelID *uint64
 	if threadMsg.SubchannelID != nil {
@@ -2107,6 +2894,12 @@ func (s *Server) handleSubscribeChannel(sess *Session, frame *protocol.Frame) er
 		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel does not exist")
 	}
 
+	if channel, err := s.db.GetChannel(int64(msg.ChannelID)); err == nil {
+		if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+			return s.sendError(sess, code, message)
+		}
+	}
+
 	// Validate subchannel if provided (still uses DB - subchannels not cached yet)
 	if msg.SubchannelID != nil {
 		exists, err := s.db.SubchannelExists(int64(*msg.SubchannelID))
@@ -2210,6 +3003,9 @@ func (s *Server) handleGetUserInfo(sess *Session, frame *protocol.Frame) error {
 		UserID:       userID,
 		Online:       online,
 	}
+	if isRegistered {
+		resp.Devices = s.deviceInfos(user.ID)
+	}
 	log.Printf("Session %d: Sending USER_INFO response: nickname=%s, is_registered=%v, online=%v", sess.ID, msg.Nickname, isRegistered, online)
 	return s.sendMessage(sess, protocol.TypeUserInfo, resp)
 }
@@ -2337,6 +3133,12 @@ func (s *Server) handleListChannelUsers(sess *Session, frame *protocol.Frame) er
 		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel does not exist")
 	}
 
+	if channel, err := s.db.GetChannel(channelID); err == nil {
+		if code, message := s.channelAccessDenied(sess, channel); code != 0 {
+			return s.sendError(sess, code, message)
+		}
+	}
+
 	if msg.SubchannelID != nil {
 		subExists, err := s.db.SubchannelExists(int64(*msg.SubchannelID))
 		if err != nil {
@@ -2347,8 +3149,22 @@ func (s *Server) handleListChannelUsers(sess *Session, frame *protocol.Frame) er
 		}
 	}
 
+	resp := &protocol.ChannelUserListMessage{
+		ChannelID:    msg.ChannelID,
+		SubchannelID: msg.SubchannelID,
+		Users:        s.channelUserEntries(channelID),
+	}
+	return s.sendMessage(sess, protocol.TypeChannelUserList, resp)
+}
+
+// channelUserEntries lists the sessions joined to a channel, with the
+// moderator flag set for users who hold a role there. Private channels also
+// list their members who aren't in the channel, with a session ID of 0.
+func (s *Server) channelUserEntries(channelID int64) []protocol.ChannelUserEntry {
 	allSessions := s.sessions.GetAllSessions()
+	roles := newChannelRoleCache(s.db)
 	users := make([]protocol.ChannelUserEntry, 0)
+	present := make(map[int64]bool)
 	for _, other := range allSessions {
 		other.mu.RLock()
 		joined := other.JoinedChannel
@@ -2365,20 +3181,38 @@ func (s *Server) handleListChannelUsers(sess *Session, frame *protocol.Frame) er
 			SessionID:    other.ID,
 			Nickname:     nickname,
 			IsRegistered: userID != nil,
-			UserFlags:    protocol.UserFlags(userFlags),
+			UserFlags:    roles.userFlags(channelID, userID, userFlags),
 		}
 		if userID != nil {
 			entry.UserID = optionalUint64FromInt64Ptr(userID)
+			present[*userID] = true
 		}
 		users = append(users, entry)
 	}
 
-	resp := &protocol.ChannelUserListMessage{
-		ChannelID:    msg.ChannelID,
-		SubchannelID: msg.SubchannelID,
-		Users:        users,
+	if channel, err := s.db.GetChannel(channelID); err == nil && channel.IsPrivate && !channel.IsDM {
+		participants, err := s.db.GetChannelParticipants(channelID)
+		if err != nil {
+			log.Printf("Failed to list members of channel %d: %v", channelID, err)
+			return users
+		}
+		for _, p := range participants {
+			if p.UserID == nil || present[*p.UserID] {
+				continue
+			}
+			var flags uint8
+			if user, err := s.db.GetUserByID(*p.UserID); err == nil {
+				flags = user.UserFlags
+			}
+			users = append(users, protocol.ChannelUserEntry{
+				Nickname:     p.Nickname,
+				IsRegistered: true,
+				UserID:       optionalUint64FromInt64Ptr(p.UserID),
+				UserFlags:    roles.userFlags(channelID, p.UserID, flags),
+			})
+		}
 	}
-	return s.sendMessage(sess, protocol.TypeChannelUserList, resp)
+	return users
 }
 
 // broadcastChannelCreated broadcasts a CHANNEL_CREATED message to all connected users (except creator)
@@ -2403,6 +3237,39 @@ func (s *Server) broadcastChannelCreated(ch *database.Channel, creatorSessionID
 			log.Printf("Failed to broadcast CHANNEL_CREATED to session %d: %v", sess.ID, err)
 		}
 	}
+
+	s.webhooks.channelCreated(ch)
+}
+
+// broadcastChannelUpdated broadcasts a CHANNEL_UPDATED message to all connected users (except the one who made the change)
+func (s *Server) broadcastChannelUpdated(msg *protocol.ChannelUpdatedMessage, updaterSessionID uint64) {
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	private := err == nil && channel.IsPrivate
+	for _, sess := range s.sessions.GetAllSessions() {
+		if sess.ID == updaterSessionID {
+			continue // They already received the response
+		}
+		if private && !s.canAccessPrivateChannel(sess, channel.ID) {
+			continue // Private channels are only announced to their members
+		}
+		if err := s.sendMessage(sess, protocol.TypeChannelUpdated, msg); err != nil {
+			log.Printf("Failed to broadcast CHANNEL_UPDATED to session %d: %v", sess.ID, err)
+		}
+	}
+}
+
+// channelArchived reports whether a channel, or the subchannel being posted
+// to, is archived and therefore read-only
+func (s *Server) channelArchived(channel *database.Channel, subchannelID *int64) bool {
+	if channel.Archived {
+		return true
+	}
+	if subchannelID != nil {
+		if sub, err := s.db.GetChannel(*subchannelID); err == nil && sub.Archived {
+			return true
+		}
+	}
+	return false
 }
 
 // broadcastToAll broadcasts a message to all connected clients
@@ -2843,6 +3710,17 @@ func (s *Server) handleBanUser(sess *Session, frame *protocol.Frame) error {
 	log.Printf("Admin %s banned user %s (ban_id=%d, reason=%s, shadowban=%v)",
 		adminNickname, targetIdentifier, banID, msg.Reason, msg.Shadowban)
 
+	s.webhooks.enqueue(webhookEventBanCreated, "", webhookBan{
+		ID:              banID,
+		Type:            "user",
+		UserID:          msg.UserID,
+		Nickname:        msg.Nickname,
+		Reason:          msg.Reason,
+		Shadowban:       msg.Shadowban,
+		DurationSeconds: msg.DurationSeconds,
+		BannedBy:        adminNickname,
+	})
+
 	// Send success response
 	return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
 		Success: true,
@@ -2893,6 +3771,15 @@ func (s *Server) handleBanIP(sess *Session, frame *protocol.Frame) error {
 
 	log.Printf("Admin %s banned IP %s (ban_id=%d, reason=%s)", adminNickname, msg.IPCIDR, banID, msg.Reason)
 
+	s.webhooks.enqueue(webhookEventBanCreated, "", webhookBan{
+		ID:              banID,
+		Type:            "ip",
+		IPCIDR:          msg.IPCIDR,
+		Reason:          msg.Reason,
+		DurationSeconds: msg.DurationSeconds,
+		BannedBy:        adminNickname,
+	})
+
 	// Send success response
 	return s.sendMessage(sess, protocol.TypeIPBanned, &protocol.IPBannedMessage{
 		Success: true,
@@ -3077,6 +3964,382 @@ func (s *Server) handleListBans(sess *Session, frame *protocol.Frame) error {
 	return s.sendMessage(sess, protocol.TypeBanList, resp)
 }
 
+// Page size limits for LIST_ADMIN_ACTIONS
+const (
+	defaultAdminActionLimit = 50
+	maxAdminActionLimit     = 200
+)
+
+// handleListAdminActions handles LIST_ADMIN_ACTIONS message (admin only)
+func (s *Server) handleListAdminActions(sess *Session, frame *protocol.Frame) error {
+	// Check admin permissions
+	if !s.isAdmin(sess) {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "Permission denied: admin access required")
+	}
+
+	// Decode message
+	msg := &protocol.ListAdminActionsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	limit := int(msg.Limit)
+	if limit == 0 {
+		limit = defaultAdminActionLimit
+	}
+	limit = min(limit, maxAdminActionLimit)
+
+	filter := database.AdminActionFilter{
+		AdminNickname: msg.AdminNickname,
+		ActionType:    msg.ActionType,
+		Limit:         limit + 1, // One extra to tell whether there is another page
+	}
+	if msg.Since != nil {
+		filter.Since = *msg.Since
+	}
+	if msg.Until != nil {
+		filter.Until = *msg.Until
+	}
+	if msg.BeforeID != nil {
+		filter.BeforeID = int64(*msg.BeforeID)
+	}
+
+	actions, err := s.db.ListAdminActions(filter)
+	if err != nil {
+		return s.dbError(sess, "ListAdminActions", err)
+	}
+
+	hasMore := len(actions) > limit
+	if hasMore {
+		actions = actions[:limit]
+	}
+
+	entries := make([]protocol.AdminActionEntry, len(actions))
+	for i, action := range actions {
+		entries[i] = protocol.AdminActionEntry{
+			ID:               uint64(action.ID),
+			AdminUserID:      uint64PtrFromInt64(action.AdminUserID),
+			AdminNickname:    action.AdminNickname,
+			ActionType:       action.ActionType,
+			TargetType:       action.TargetType,
+			TargetID:         uint64PtrFromInt64(action.TargetID),
+			TargetIdentifier: action.TargetIdentifier,
+			Details:          action.Details,
+			PerformedAt:      action.PerformedAt,
+			IPAddress:        action.IPAddress,
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypeAdminActionList, &protocol.AdminActionListMessage{
+		Actions: entries,
+		HasMore: hasMore,
+	})
+}
+
+// handleKickUser handles KICK_USER message (admin only)
+func (s *Server) handleKickUser(sess *Session, frame *protocol.Frame) error {
+	// Check admin permissions
+	if !s.isAdmin(sess) {
+		return s.sendMessage(sess, protocol.TypeUserKicked, &protocol.UserKickedMessage{
+			Success: false,
+			Message: "Permission denied: admin access required",
+		})
+	}
+
+	// Decode message
+	msg := &protocol.KickUserMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// Validate: must provide either UserID or Nickname
+	if msg.UserID == nil && msg.Nickname == nil {
+		return s.sendMessage(sess, protocol.TypeUserKicked, &protocol.UserKickedMessage{
+			Success: false,
+			Message: "Must provide either UserID or Nickname",
+		})
+	}
+
+	// Get admin info for audit log
+	sess.mu.RLock()
+	adminNickname := sess.Nickname
+	adminUserID := sess.UserID
+	sess.mu.RUnlock()
+
+	// Find the sessions to disconnect
+	var targetSessions []*Session
+	targetNickname := ""
+	for _, targetSess := range s.sessions.GetAllSessions() {
+		targetSess.mu.RLock()
+		matches := false
+		if msg.UserID != nil {
+			matches = targetSess.UserID != nil && uint64(*targetSess.UserID) == *msg.UserID
+		} else {
+			matches = strings.EqualFold(targetSess.Nickname, *msg.Nickname)
+		}
+		if matches {
+			targetSessions = append(targetSessions, targetSess)
+			targetNickname = targetSess.Nickname
+		}
+		targetSess.mu.RUnlock()
+	}
+
+	if len(targetSessions) == 0 {
+		return s.sendMessage(sess, protocol.TypeUserKicked, &protocol.UserKickedMessage{
+			Success: false,
+			Message: "User is not connected",
+		})
+	}
+	for _, targetSess := range targetSessions {
+		if targetSess.ID == sess.ID {
+			return s.sendMessage(sess, protocol.TypeUserKicked, &protocol.UserKickedMessage{
+				Success: false,
+				Message: "Cannot kick yourself",
+			})
+		}
+	}
+
+	// Tell each session why before closing it
+	reason := fmt.Sprintf("Kicked by %s", adminNickname)
+	if msg.Reason != "" {
+		reason += ": " + msg.Reason
+	}
+	for _, targetSess := range targetSessions {
+		log.Printf("Disconnecting session %d for kicked user %s", targetSess.ID, targetNickname)
+		if err := s.sendMessage(targetSess, protocol.TypeDisconnect, &protocol.DisconnectMessage{Reason: &reason}); err != nil {
+			log.Printf("Failed to send DISCONNECT to session %d: %v", targetSess.ID, err)
+		}
+		s.removeSession(targetSess.ID)
+	}
+
+	// Log admin action
+	if adminUserID != nil {
+		if err := s.db.LogAdminAction(uint64(*adminUserID), adminNickname, "KICK_USER",
+			fmt.Sprintf("nickname=%s sessions=%d reason=%s", targetNickname, len(targetSessions), msg.Reason)); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	log.Printf("Admin %s kicked user %s (%d sessions, reason=%s)", adminNickname, targetNickname, len(targetSessions), msg.Reason)
+
+	return s.sendMessage(sess, protocol.TypeUserKicked, &protocol.UserKickedMessage{
+		Success:              true,
+		SessionsDisconnected: uint32(len(targetSessions)),
+		Message:              fmt.Sprintf("User %s kicked (%d sessions disconnected)", targetNickname, len(targetSessions)),
+	})
+}
+
+// resolveModerationTarget turns a user ID or nickname from a moderation
+// request into the user ID (nil for anonymous users) and nickname to act on
+func (s *Server) resolveModerationTarget(userID *uint64, nickname *string) (*int64, string, error) {
+	if userID != nil {
+		user, err := s.db.GetUserByID(int64(*userID))
+		if err != nil {
+			return nil, "", errors.New("User not found")
+		}
+		return &user.ID, user.Nickname, nil
+	}
+	if nickname == nil || *nickname == "" {
+		return nil, "", errors.New("Must provide either UserID or Nickname")
+	}
+	if user, err := s.db.GetUserByNickname(*nickname); err == nil {
+		return &user.ID, user.Nickname, nil
+	}
+	return nil, *nickname, nil
+}
+
+// moderationScope resolves an optional channel ID into the channel to act on
+// and a description such as "in #general" or "in all channels"
+func (s *Server) moderationScope(channelID *uint64) (*int64, string, error) {
+	if channelID == nil {
+		return nil, "in all channels", nil
+	}
+	channel, err := s.db.GetChannel(int64(*channelID))
+	if err != nil {
+		return nil, "", errors.New("Channel not found")
+	}
+	return &channel.ID, "in #" + channel.Name, nil
+}
+
+// mayMute reports whether a session may mute users in a scope: admins
+// everywhere, channel owners and moderators in their channel
+func (s *Server) mayMute(sess *Session, channelID *uint64) bool {
+	return s.isAdmin(sess) || (channelID != nil && s.can(sess, int64(*channelID), permMuteInChannel))
+}
+
+// handleMuteUser handles MUTE_USER message (admins, or channel moderators for their channel)
+func (s *Server) handleMuteUser(sess *Session, frame *protocol.Frame) error {
+	// Decode message
+	msg := &protocol.MuteUserMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// Check permissions
+	if !s.mayMute(sess, msg.ChannelID) {
+		return s.sendMessage(sess, protocol.TypeUserMuted, &protocol.UserMutedMessage{
+			Success: false,
+			Message: "Permission denied: admin or channel moderator access required",
+		})
+	}
+
+	userID, nickname, err := s.resolveModerationTarget(msg.UserID, msg.Nickname)
+	if err != nil {
+		return s.sendMessage(sess, protocol.TypeUserMuted, &protocol.UserMutedMessage{Success: false, Message: err.Error()})
+	}
+	channelID, scope, err := s.moderationScope(msg.ChannelID)
+	if err != nil {
+		return s.sendMessage(sess, protocol.TypeUserMuted, &protocol.UserMutedMessage{Success: false, Message: err.Error()})
+	}
+
+	// Moderators can only mute members of their channel
+	if !s.isAdmin(sess) && channelID != nil {
+		isStaff := s.isAdminNickname(nickname)
+		if userID != nil && !isStaff {
+			role, err := s.db.GetChannelRole(roleChannelID(s.db, *channelID), *userID)
+			isStaff = err != nil || role != database.ChannelRoleMember
+		}
+		if isStaff {
+			return s.sendMessage(sess, protocol.TypeUserMuted, &protocol.UserMutedMessage{
+				Success: false,
+				Message: "Permission denied: cannot mute channel staff",
+			})
+		}
+	}
+
+	// Get admin info for audit log
+	sess.mu.RLock()
+	adminNickname := sess.Nickname
+	adminUserID := sess.UserID
+	sess.mu.RUnlock()
+
+	if adminUserID != nil && userID != nil && *adminUserID == *userID {
+		return s.sendMessage(sess, protocol.TypeUserMuted, &protocol.UserMutedMessage{
+			Success: false,
+			Message: "Cannot mute yourself",
+		})
+	}
+
+	muteID, err := s.db.CreateMute(userID, nickname, channelID, msg.Reason, msg.DurationSeconds, adminNickname)
+	if err != nil {
+		log.Printf("Failed to create mute: %v", err)
+		return s.sendMessage(sess, protocol.TypeUserMuted, &protocol.UserMutedMessage{
+			Success: false,
+			Message: "Failed to create mute",
+		})
+	}
+
+	duration := "permanently"
+	if msg.DurationSeconds != nil {
+		duration = fmt.Sprintf("for %s", time.Duration(*msg.DurationSeconds)*time.Second)
+	}
+
+	// Log admin action
+	if adminUserID != nil {
+		if err := s.db.LogAdminAction(uint64(*adminUserID), adminNickname, "MUTE_USER",
+			fmt.Sprintf("mute_id=%d nickname=%s %s %s reason=%s", muteID, nickname, scope, duration, msg.Reason)); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	log.Printf("Admin %s muted user %s %s %s (mute_id=%d, reason=%s)", adminNickname, nickname, scope, duration, muteID, msg.Reason)
+
+	return s.sendMessage(sess, protocol.TypeUserMuted, &protocol.UserMutedMessage{
+		Success: true,
+		MuteID:  uint64(muteID),
+		Message: fmt.Sprintf("User %s muted %s %s", nickname, scope, duration),
+	})
+}
+
+// handleUnmuteUser handles UNMUTE_USER message (admins, or channel moderators for their channel)
+func (s *Server) handleUnmuteUser(sess *Session, frame *protocol.Frame) error {
+	// Decode message
+	msg := &protocol.UnmuteUserMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// Check permissions
+	if !s.mayMute(sess, msg.ChannelID) {
+		return s.sendMessage(sess, protocol.TypeUserUnmuted, &protocol.UserUnmutedMessage{
+			Success: false,
+			Message: "Permission denied: admin or channel moderator access required",
+		})
+	}
+
+	userID, nickname, err := s.resolveModerationTarget(msg.UserID, msg.Nickname)
+	if err != nil {
+		return s.sendMessage(sess, protocol.TypeUserUnmuted, &protocol.UserUnmutedMessage{Success: false, Message: err.Error()})
+	}
+	channelID, scope, err := s.moderationScope(msg.ChannelID)
+	if err != nil {
+		return s.sendMessage(sess, protocol.TypeUserUnmuted, &protocol.UserUnmutedMessage{Success: false, Message: err.Error()})
+	}
+
+	removed, err := s.db.DeleteMutes(userID, nickname, channelID)
+	if err != nil {
+		return s.dbError(sess, "DeleteMutes", err)
+	}
+	if removed == 0 {
+		return s.sendMessage(sess, protocol.TypeUserUnmuted, &protocol.UserUnmutedMessage{
+			Success: false,
+			Message: fmt.Sprintf("No mute found for %s %s", nickname, scope),
+		})
+	}
+
+	// Log admin action
+	sess.mu.RLock()
+	adminNickname := sess.Nickname
+	adminUserID := sess.UserID
+	sess.mu.RUnlock()
+	if adminUserID != nil {
+		if err := s.db.LogAdminAction(uint64(*adminUserID), adminNickname, "UNMUTE_USER",
+			fmt.Sprintf("nickname=%s %s", nickname, scope)); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	log.Printf("Admin %s unmuted user %s %s", adminNickname, nickname, scope)
+
+	return s.sendMessage(sess, protocol.TypeUserUnmuted, &protocol.UserUnmutedMessage{
+		Success: true,
+		Message: fmt.Sprintf("User %s unmuted %s", nickname, scope),
+	})
+}
+
+// activeMute returns the mute that stops the session posting in a channel,
+// or nil. Lookup failures don't block posting.
+func (s *Server) activeMute(sess *Session, channelID int64) *database.Mute {
+	sess.mu.RLock()
+	userID := sess.UserID
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+
+	mute, err := s.db.GetActiveMute(userID, nickname, channelID)
+	if err != nil {
+		log.Printf("Session %d: failed to check mute status: %v", sess.ID, err)
+		return nil
+	}
+	return mute
+}
+
+// muteNotice tells a muted user why their message was rejected
+func muteNotice(mute *database.Mute) string {
+	scope := "You are muted"
+	if mute.ChannelID != nil {
+		scope = "You are muted in this channel"
+	}
+	until := "permanently"
+	if mute.MutedUntil != nil {
+		until = fmt.Sprintf("until %s", time.UnixMilli(*mute.MutedUntil).Format(time.RFC3339))
+	}
+	notice := fmt.Sprintf("%s %s", scope, until)
+	if mute.Reason != "" {
+		notice += ". Reason: " + mute.Reason
+	}
+	return notice
+}
+
 // handleDeleteUser handles DELETE_USER message (admin only)
 func (s *Server) handleDeleteUser(sess *Session, frame *protocol.Frame) error {
 	// Check admin permissions
@@ -3456,6 +4719,8 @@ func (s *Server) handleStartDM(sess *Session, frame *protocol.Frame) error {
 			log.Printf("[ERROR] Failed to create DM channel: %v", err)
 			return s.sendError(sess, protocol.ErrCodeDatabaseError, "Failed to create DM channel")
 		}
+		ratchet := s.negotiateDMRatchet(channelID, msg.Ratchet, *targetUserID)
+		multiDevice := s.negotiateDMMultiDevice(channelID, msg.MultiDevice, *initiatorUserID, *targetUserID)
 
 		// Send DM_READY to initiator
 		var targetPubKeyArr [32]byte
@@ -3466,8 +4731,13 @@ func (s *Server) handleStartDM(sess *Session, frame *protocol.Frame) error {
 			OtherNickname:  targetNickname,
 			IsEncrypted:    true,
 			OtherPublicKey: targetPubKeyArr,
+			Ratchet:        ratchet,
+			MultiDevice:    multiDevice,
 		}
-		if err := s.sendMessage(sess, protocol.TypeDMReady, initiatorReady); err != nil {
+		if multiDevice {
+			// Every device of both users can read a multi-device DM
+			s.sendToUserSessions(*initiatorUserID, protocol.TypeDMReady, initiatorReady)
+		} else if err := s.sendMessage(sess, protocol.TypeDMReady, initiatorReady); err != nil {
 			return err
 		}
 
@@ -3481,8 +4751,14 @@ func (s *Server) handleStartDM(sess *Session, frame *protocol.Frame) error {
 				OtherNickname:  initiatorNickname,
 				IsEncrypted:    true,
 				OtherPublicKey: initiatorPubKeyArr,
+				Ratchet:        ratchet,
+				MultiDevice:    multiDevice,
+			}
+			if multiDevice {
+				s.sendToUserSessions(*targetUserID, protocol.TypeDMReady, targetReady)
+			} else {
+				s.sendToUserOrSession(targetUserID, targetSession, protocol.TypeDMReady, targetReady)
 			}
-			s.sendToUserOrSession(targetUserID, targetSession, protocol.TypeDMReady, targetReady)
 		}
 
 		return nil
@@ -3571,11 +4847,37 @@ func (s *Server) handleProvidePublicKey(sess *Session, frame *protocol.Frame) er
 		return nil
 	}
 
-	// Store key for registered user
-	if err := s.db.SetUserEncryptionKey(*userID, msg.PublicKey[:]); err != nil {
-		log.Printf("[ERROR] Failed to store encryption key: %v", err)
+	// Every key of a registered user is one of their devices. The newest
+	// device's key is also the user's key, for older clients and private
+	// channels, so logging in from an older device doesn't switch it back.
+	_, created, err := s.db.RegisterUserDevice(*userID, msg.PublicKey[:], msg.Label, msg.MultiDevice)
+	if errors.Is(err, database.ErrDeviceRevoked) {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "This encryption key was revoked, generate a new one to use encrypted DMs on this device")
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to register device: %v", err)
 		return s.sendError(sess, protocol.ErrCodeDatabaseError, "Failed to store encryption key")
 	}
+	currentKey, _ := s.db.GetUserEncryptionKey(*userID)
+	if created || len(currentKey) != 32 {
+		if err := s.db.SetUserEncryptionKey(*userID, msg.PublicKey[:]); err != nil {
+			log.Printf("[ERROR] Failed to store encryption key: %v", err)
+			return s.sendError(sess, protocol.ErrCodeDatabaseError, "Failed to store encryption key")
+		}
+		currentKey = msg.PublicKey[:]
+	}
+	if bytes.Equal(currentKey, msg.PublicKey[:]) {
+		if err := s.db.SetUserRatchetSupport(*userID, msg.Ratchet); err != nil {
+			log.Printf("[WARN] Failed to store ratchet support: %v", err)
+		}
+	}
+
+	// Private channel keys wrapped for the old key need replacing
+	if channels, err := s.db.GetPrivateChannelsForUser(*userID); err == nil {
+		for _, channel := range channels {
+			s.requestChannelRekey(channel.ID, sess)
+		}
+	}
 
 	// Check for pending DM invites where this user is the target
 	invites, err := s.db.GetPendingDMInvitesForUser(*userID)
@@ -3769,6 +5071,8 @@ func (s *Server) sendExistingDMReady(sess *Session, dm *database.Channel, otherU
 
 	var otherPubKey [32]byte
 	isEncrypted := false
+	ratchet := false
+	multiDevice := false
 
 	if otherUser != nil && currentUserID != nil {
 		// Get encryption keys if both users have them
@@ -3777,6 +5081,8 @@ func (s *Server) sendExistingDMReady(sess *Session, dm *database.Channel, otherU
 		if len(currentKey) == 32 && len(otherKey) == 32 {
 			isEncrypted = true
 			copy(otherPubKey[:], otherKey)
+			ratchet, _ = s.db.IsDMRatchet(dm.ID)
+			multiDevice, _ = s.db.IsDMMultiDevice(dm.ID)
 		}
 	}
 
@@ -3794,6 +5100,8 @@ func (s *Server) sendExistingDMReady(sess *Session, dm *database.Channel, otherU
 		OtherNickname:  otherNickname,
 		IsEncrypted:    isEncrypted,
 		OtherPublicKey: otherPubKey,
+		Ratchet:        ratchet,
+		MultiDevice:    multiDevice,
 	}
 	return s.sendMessage(sess, protocol.TypeDMReady, ready)
 }
@@ -3820,6 +5128,18 @@ func (s *Server) sendToUser(userID int64, msgType byte, msg protocol.ProtocolMes
 	}
 }
 
+// Helper: send message to every session of a user
+func (s *Server) sendToUserSessions(userID int64, msgType byte, msg protocol.ProtocolMessage) {
+	for _, session := range s.sessions.GetAllSessions() {
+		session.mu.RLock()
+		match := session.UserID != nil && *session.UserID == userID
+		session.mu.RUnlock()
+		if match {
+			s.sendMessage(session, msgType, msg)
+		}
+	}
+}
+
 // Helper: send message to user by ID or session
 func (s *Server) sendToUserOrSession(userID *int64, targetSession *Session, msgType byte, msg protocol.ProtocolMessage) {
 	if targetSession != nil {
@@ -3840,6 +5160,22 @@ func toUint64Ptr(i *int64) *uint64 {
 	return &u
 }
 
+// Helper: mark a new encrypted DM as ratcheted if the initiator's client
+// and the target's latest client both support it. Returns whether it is.
+func (s *Server) negotiateDMRatchet(channelID int64, initiatorSupports bool, targetUserID int64) bool {
+	if !initiatorSupports {
+		return false
+	}
+	if targetSupports, err := s.db.GetUserRatchetSupport(targetUserID); err != nil || !targetSupports {
+		return false
+	}
+	if err := s.db.SetDMRatchet(channelID); err != nil {
+		log.Printf("[ERROR] Failed to mark DM %d as ratcheted: %v", channelID, err)
+		return false
+	}
+	return true
+}
+
 // Helper: process pending invite after user provides key
 func (s *Server) processPendingInviteAfterKey(sess *Session, invite *database.DMInvite) {
 	// Encrypted DMs only apply to registered users
@@ -3862,6 +5198,10 @@ func (s *Server) processPendingInviteAfterKey(sess *Session, invite *database.DM
 		// Delete the invite
 		s.db.DeleteDMInvite(invite.ID)
 
+		initiatorRatchet, _ := s.db.GetUserRatchetSupport(*invite.InitiatorUserID)
+		ratchet := s.negotiateDMRatchet(channelID, initiatorRatchet, *invite.TargetUserID)
+		multiDevice := s.negotiateDMMultiDevice(channelID, true, *invite.InitiatorUserID, *invite.TargetUserID)
+
 		// Get user info
 		initiatorUser, _ := s.db.GetUserByID(*invite.InitiatorUserID)
 		targetUser, _ := s.db.GetUserByID(*invite.TargetUserID)
@@ -3878,8 +5218,14 @@ func (s *Server) processPendingInviteAfterKey(sess *Session, invite *database.DM
 				OtherNickname:  initiatorUser.Nickname,
 				IsEncrypted:    true,
 				OtherPublicKey: initiatorPubKey,
+				Ratchet:        ratchet,
+				MultiDevice:    multiDevice,
+			}
+			if multiDevice {
+				s.sendToUserSessions(*invite.TargetUserID, protocol.TypeDMReady, targetReady)
+			} else {
+				s.sendMessage(sess, protocol.TypeDMReady, targetReady)
 			}
-			s.sendMessage(sess, protocol.TypeDMReady, targetReady)
 		}
 
 		if initiatorUser != nil {
@@ -3889,8 +5235,14 @@ func (s *Server) processPendingInviteAfterKey(sess *Session, invite *database.DM
 				OtherNickname:  targetUser.Nickname,
 				IsEncrypted:    true,
 				OtherPublicKey: targetPubKey,
+				Ratchet:        ratchet,
+				MultiDevice:    multiDevice,
+			}
+			if multiDevice {
+				s.sendToUserSessions(*invite.InitiatorUserID, protocol.TypeDMReady, initiatorReady)
+			} else {
+				s.sendToUser(*invite.InitiatorUserID, protocol.TypeDMReady, initiatorReady)
 			}
-			s.sendToUser(*invite.InitiatorUserID, protocol.TypeDMReady, initiatorReady)
 		}
 	}
 }
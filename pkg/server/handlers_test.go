@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -56,7 +57,7 @@ func testServer(t *testing.T) (*Server, *database.DB) {
 }
 
 // createTestChannel helper - creates a channel via database
-func createTestChannel(t *testing.T, db *database.DB, name, displayName string) int64 {
+func createTestChannel(t *testing.T, db database.Store, name, displayName string) int64 {
 	channelID, err := db.CreateChannel(name, displayName, nil, 1, 168, nil)
 	if err != nil {
 		t.Fatalf("Failed to create test channel: %v", err)
@@ -65,8 +66,8 @@ func createTestChannel(t *testing.T, db *database.DB, name, displayName string)
 }
 
 // postTestMessage helper - posts a message via database
-func postTestMessage(t *testing.T, db *database.DB, channelID int64, parentID *int64, nickname, content string) int64 {
-	msgID, err := db.PostMessage(channelID, nil, parentID, nil, nickname, content)
+func postTestMessage(t *testing.T, db database.Store, channelID int64, parentID *int64, nickname, content string) int64 {
+	msgID, _, err := db.PostMessage(channelID, nil, parentID, nil, nickname, content)
 	if err != nil {
 		t.Fatalf("Failed to post test message: %v", err)
 	}
@@ -612,6 +613,52 @@ func TestHandleListMessages(t *testing.T) {
 	})
 }
 
+func TestHandleListMessagesWithMockDB(t *testing.T) {
+	initTestLoggers(t)
+
+	mock := newMockDB()
+	mock.AddChannel(1, "general", "General")
+	rootID, _, _ := mock.PostMessage(1, nil, nil, nil, "alice", "Root message")
+	mock.PostMessage(1, nil, &rootID, nil, "bob", "Reply message")
+
+	srv := &Server{
+		db:       mock,
+		sessions: NewSessionManager(mock, 120),
+		config:   DefaultConfig(),
+	}
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(nil, "viewer", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	frame, err := encodeListMessagesMessage(&protocol.ListMessagesMessage{ChannelID: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	if err := srv.handleListMessages(sess, frame); err != nil {
+		t.Fatalf("handleListMessages failed: %v", err)
+	}
+
+	resp, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	if resp.Type != protocol.TypeMessageList {
+		t.Fatalf("Expected MESSAGE_LIST, got type 0x%02X", resp.Type)
+	}
+	list := &protocol.MessageListMessage{}
+	if err := list.Decode(resp.Payload); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(list.Messages) != 1 {
+		t.Fatalf("Expected 1 root message, got %d", len(list.Messages))
+	}
+	if list.Messages[0].ID != uint64(rootID) || list.Messages[0].ReplyCount != 1 {
+		t.Errorf("Expected root %d with 1 reply, got %+v", rootID, list.Messages[0])
+	}
+}
+
 func TestConvertDBMessageToProtocol(t *testing.T) {
 	srv, db := testServer(t)
 	defer db.Close()
@@ -1265,7 +1312,7 @@ func TestBroadcastNewMessage(t *testing.T) {
 		replyID := postTestMessage(t, db, channelID, &threadID, "testuser", "Reply to thread")
 
 		// Get the message directly from DB (don't reload MemDB to preserve subscriptions!)
-		dbMsg, err := db.GetMessage(uint64(replyID))
+		dbMsg, err := db.GetMessage(replyID)
 		if err != nil {
 			t.Fatalf("Failed to get message: %v", err)
 		}
@@ -1314,7 +1361,7 @@ func TestBroadcastNewMessage(t *testing.T) {
 		rootID := postTestMessage(t, db, channelID, nil, "testuser", "New root message")
 
 		// Get the message directly from DB (don't reload MemDB to preserve subscriptions!)
-		msg, err := db.GetMessage(uint64(rootID))
+		msg, err := db.GetMessage(rootID)
 		if err != nil {
 			t.Fatalf("Failed to get message: %v", err)
 		}
@@ -1516,3 +1563,1044 @@ func hashPasswordForTest(password, nickname string) string {
 func verifyPasswordHash(storedHash, clientHash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(clientHash))
 }
+
+// encodeSearchMessagesMessage helper
+func encodeSearchMessagesMessage(msg *protocol.SearchMessagesMessage) (*protocol.Frame, error) {
+	var buf bytes.Buffer
+	if err := msg.EncodeTo(&buf); err != nil {
+		return nil, err
+	}
+	return &protocol.Frame{
+		Version: protocol.ProtocolVersion,
+		Type:    protocol.TypeSearchMessages,
+		Flags:   0,
+		Payload: buf.Bytes(),
+	}, nil
+}
+
+func TestHandleSearchMessages(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	rootID, _, err := srv.db.PostMessage(channelID, nil, nil, nil, "carol", "Should we migrate the billing database?")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	replyID, _, err := srv.db.PostMessage(channelID, nil, &rootID, nil, "dave", "Decision: migrate billing in March")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	dmID, err := srv.db.CreateDMChannel(aliceID, bobID, false)
+	if err != nil {
+		t.Fatalf("CreateDMChannel: %v", err)
+	}
+	if _, _, err := srv.db.PostMessage(dmID, nil, nil, &aliceID, "alice", "private billing numbers"); err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+
+	search := func(sess *Session, conn *mockConn, msg *protocol.SearchMessagesMessage) *protocol.Frame {
+		t.Helper()
+		conn.writeBuf.Reset()
+		frame, err := encodeSearchMessagesMessage(msg)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := srv.handleSearchMessages(sess, frame); err != nil {
+			t.Fatalf("handleSearchMessages: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		return resp
+	}
+	results := func(frame *protocol.Frame) *protocol.SearchResultsMessage {
+		t.Helper()
+		if frame.Type != protocol.TypeSearchResults {
+			t.Fatalf("expected SEARCH_RESULTS, got 0x%02X", frame.Type)
+		}
+		resp := &protocol.SearchResultsMessage{}
+		if err := resp.Decode(frame.Payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(nil, "", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	t.Run("anonymous user doesn't see DMs", func(t *testing.T) {
+		resp := results(search(sess, conn, &protocol.SearchMessagesMessage{Query: "billing"}))
+		if len(resp.Results) != 2 {
+			t.Fatalf("expected 2 results, got %d", len(resp.Results))
+		}
+		if resp.Results[0].Message.ID != uint64(replyID) {
+			t.Errorf("expected newest hit first, got %d", resp.Results[0].Message.ID)
+		}
+		if resp.Results[0].ThreadRoot == nil || resp.Results[0].ThreadRoot.ID != uint64(rootID) {
+			t.Errorf("expected reply to carry thread root %d", rootID)
+		}
+		if resp.HasMore {
+			t.Error("expected no more results")
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		resp := results(search(sess, conn, &protocol.SearchMessagesMessage{Query: "billing", Limit: 1}))
+		if len(resp.Results) != 1 || !resp.HasMore {
+			t.Fatalf("expected 1 result with more available, got %d (hasMore=%v)", len(resp.Results), resp.HasMore)
+		}
+		beforeID := resp.Results[0].Message.ID
+		resp = results(search(sess, conn, &protocol.SearchMessagesMessage{Query: "billing", Limit: 1, BeforeID: &beforeID}))
+		if len(resp.Results) != 1 || resp.Results[0].Message.ID != uint64(rootID) {
+			t.Fatalf("expected second page to contain root message")
+		}
+	})
+
+	t.Run("author filter accepts display prefix", func(t *testing.T) {
+		author := "~carol"
+		resp := results(search(sess, conn, &protocol.SearchMessagesMessage{Query: "billing", Author: &author}))
+		if len(resp.Results) != 1 || resp.Results[0].Message.ID != uint64(rootID) {
+			t.Fatalf("expected only carol's message, got %d results", len(resp.Results))
+		}
+	})
+
+	t.Run("DM channel denied for non-participants", func(t *testing.T) {
+		dm := uint64(dmID)
+		frame := search(sess, conn, &protocol.SearchMessagesMessage{Query: "billing", ChannelID: &dm})
+		if frame.Type != protocol.TypeError {
+			t.Fatalf("expected ERROR, got 0x%02X", frame.Type)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		errMsg.Decode(frame.Payload)
+		if errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+			t.Errorf("error code = %d, want %d", errMsg.ErrorCode, protocol.ErrCodePermissionDenied)
+		}
+	})
+
+	t.Run("DM participant sees DM hits", func(t *testing.T) {
+		aliceConn := newMockConn()
+		aliceSess, err := srv.sessions.CreateSession(&aliceID, "alice", "tcp", aliceConn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		resp := results(search(aliceSess, aliceConn, &protocol.SearchMessagesMessage{Query: "billing"}))
+		if len(resp.Results) != 3 {
+			t.Fatalf("expected 3 results including the DM, got %d", len(resp.Results))
+		}
+	})
+
+	t.Run("empty query rejected", func(t *testing.T) {
+		frame := search(sess, conn, &protocol.SearchMessagesMessage{Query: "   "})
+		if frame.Type != protocol.TypeError {
+			t.Fatalf("expected ERROR, got 0x%02X", frame.Type)
+		}
+	})
+}
+
+func TestHandlePlusOne(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	rootID, _, err := srv.db.PostMessage(channelID, nil, nil, nil, "carol", "Friday deploys are fine")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	replyID, _, err := srv.db.PostMessage(channelID, nil, &rootID, nil, "dave", "Agreed")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+
+	plusOne := func(sess *Session, conn *mockConn, messageID int64) *protocol.Frame {
+		t.Helper()
+		conn.writeBuf.Reset()
+		payload, err := (&protocol.PlusOneMessage{MessageID: uint64(messageID)}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypePlusOne, Payload: payload}
+		if err := srv.handlePlusOne(sess, frame); err != nil {
+			t.Fatalf("handlePlusOne: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		return resp
+	}
+	update := func(frame *protocol.Frame) *protocol.PlusOneUpdateMessage {
+		t.Helper()
+		if frame.Type != protocol.TypePlusOneUpdate {
+			t.Fatalf("expected PLUS_ONE_UPDATE, got 0x%02X", frame.Type)
+		}
+		resp := &protocol.PlusOneUpdateMessage{}
+		if err := resp.Decode(frame.Payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	aliceConn := newMockConn()
+	aliceSess, err := srv.sessions.CreateSession(&aliceID, "alice", "tcp", aliceConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	anonConn := newMockConn()
+	anonSess, err := srv.sessions.CreateSession(nil, "bob", "tcp", anonConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	// A session viewing the thread receives updates for replies
+	viewerConn := newMockConn()
+	viewerSess, err := srv.sessions.CreateSession(nil, "eve", "tcp", viewerConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	srv.sessions.SubscribeToThread(viewerSess, uint64(rootID), ChannelSubscription{ChannelID: uint64(channelID)})
+
+	t.Run("registered +1 is broadcast to thread subscribers", func(t *testing.T) {
+		resp := update(plusOne(aliceSess, aliceConn, replyID))
+		if resp.MessageID != uint64(replyID) || resp.PlusOneCount != 1 || resp.PlusOneAnonCount != 0 {
+			t.Errorf("update = %+v, want 1/0 for message %d", resp, replyID)
+		}
+		viewerFrame, err := protocol.DecodeFrame(viewerConn.writeBuf)
+		if err != nil {
+			t.Fatalf("thread subscriber got no update: %v", err)
+		}
+		if got := update(viewerFrame); got.PlusOneCount != 1 {
+			t.Errorf("subscriber PlusOneCount = %d, want 1", got.PlusOneCount)
+		}
+	})
+
+	t.Run("repeat registered +1 is not counted", func(t *testing.T) {
+		viewerConn.writeBuf.Reset()
+		resp := update(plusOne(aliceSess, aliceConn, replyID))
+		if resp.PlusOneCount != 1 {
+			t.Errorf("PlusOneCount = %d, want 1", resp.PlusOneCount)
+		}
+		if viewerConn.writeBuf.Len() != 0 {
+			t.Error("unchanged counts should not be broadcast")
+		}
+	})
+
+	t.Run("anonymous +1s are unlimited", func(t *testing.T) {
+		plusOne(anonSess, anonConn, replyID)
+		resp := update(plusOne(anonSess, anonConn, replyID))
+		if resp.PlusOneCount != 1 || resp.PlusOneAnonCount != 2 {
+			t.Errorf("counts = %d/%d, want 1/2", resp.PlusOneCount, resp.PlusOneAnonCount)
+		}
+	})
+
+	t.Run("counts are included in message lists", func(t *testing.T) {
+		dbMsg, err := srv.db.GetMessage(replyID)
+		if err != nil {
+			t.Fatalf("GetMessage: %v", err)
+		}
+		msg := convertDBMessageToProtocol(dbMsg, srv.db)
+		if msg.PlusOneCount != 1 || msg.PlusOneAnonCount != 2 {
+			t.Errorf("protocol counts = %d/%d, want 1/2", msg.PlusOneCount, msg.PlusOneAnonCount)
+		}
+	})
+
+	t.Run("unknown message", func(t *testing.T) {
+		frame := plusOne(aliceSess, aliceConn, 999999)
+		if frame.Type != protocol.TypeError {
+			t.Fatalf("expected ERROR, got 0x%02X", frame.Type)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		errMsg.Decode(frame.Payload)
+		if errMsg.ErrorCode != protocol.ErrCodeMessageNotFound {
+			t.Errorf("error code = %d, want %d", errMsg.ErrorCode, protocol.ErrCodeMessageNotFound)
+		}
+	})
+}
+
+func TestHandleMentions(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	// Alice is online in two sessions and subscribed to nothing
+	aliceConn1 := newMockConn()
+	aliceSess1, err := srv.sessions.CreateSession(&aliceID, "alice", "tcp", aliceConn1)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	aliceConn2 := newMockConn()
+	if _, err := srv.sessions.CreateSession(&aliceID, "alice", "tcp", aliceConn2); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	bobConn := newMockConn()
+	bobSess, err := srv.sessions.CreateSession(&bobID, "bob", "tcp", bobConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	post := func(sess *Session, content string) {
+		t.Helper()
+		frame, err := encodePostMessageMessage(&protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: content})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := srv.handlePostMessage(sess, frame); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+	}
+	mentionFrames := func(conn *mockConn) []*protocol.MentionNotificationMessage {
+		t.Helper()
+		var notifications []*protocol.MentionNotificationMessage
+		for conn.writeBuf.Len() > 0 {
+			frame, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("DecodeFrame: %v", err)
+			}
+			if frame.Type != protocol.TypeMentionNotification {
+				continue
+			}
+			msg := &protocol.MentionNotificationMessage{}
+			if err := msg.Decode(frame.Payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			notifications = append(notifications, msg)
+		}
+		return notifications
+	}
+
+	t.Run("mentioned user is notified on every session", func(t *testing.T) {
+		post(bobSess, "ping @Alice, and @nobody-here")
+		for i, conn := range []*mockConn{aliceConn1, aliceConn2} {
+			got := mentionFrames(conn)
+			if len(got) != 1 {
+				t.Fatalf("session %d got %d notifications, want 1", i+1, len(got))
+			}
+			if got[0].Mention.Message.Content != "ping @Alice, and @nobody-here" {
+				t.Errorf("content = %q", got[0].Mention.Message.Content)
+			}
+		}
+	})
+
+	t.Run("self mentions are ignored", func(t *testing.T) {
+		bobConn.writeBuf.Reset()
+		post(bobSess, "note to self @bob")
+		if got := mentionFrames(bobConn); len(got) != 0 {
+			t.Errorf("got %d notifications for a self mention", len(got))
+		}
+	})
+
+	t.Run("list mentions", func(t *testing.T) {
+		post(bobSess, "@alice second")
+		aliceConn1.writeBuf.Reset()
+
+		payload, err := (&protocol.ListMentionsMessage{Limit: 1}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeListMentions, Payload: payload}
+		if err := srv.handleListMentions(aliceSess1, frame); err != nil {
+			t.Fatalf("handleListMentions: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(aliceConn1.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		if resp.Type != protocol.TypeMentionList {
+			t.Fatalf("expected MENTION_LIST, got 0x%02X", resp.Type)
+		}
+		list := &protocol.MentionListMessage{}
+		if err := list.Decode(resp.Payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(list.Mentions) != 1 || !list.HasMore {
+			t.Fatalf("got %d mentions (hasMore=%v), want 1 with more", len(list.Mentions), list.HasMore)
+		}
+		if list.Mentions[0].Message.Content != "@alice second" {
+			t.Errorf("newest mention = %q, want %q", list.Mentions[0].Message.Content, "@alice second")
+		}
+	})
+
+	t.Run("anonymous users can't list mentions", func(t *testing.T) {
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(nil, "guest", "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		payload, _ := (&protocol.ListMentionsMessage{}).Encode()
+		frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeListMentions, Payload: payload}
+		if err := srv.handleListMentions(sess, frame); err != nil {
+			t.Fatalf("handleListMentions: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		errMsg.Decode(resp.Payload)
+		if resp.Type != protocol.TypeError || errMsg.ErrorCode != protocol.ErrCodeAuthRequired {
+			t.Errorf("got type 0x%02X code %d, want auth required error", resp.Type, errMsg.ErrorCode)
+		}
+	})
+}
+
+func TestHandleGetMessageHistory(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	msgID, _, err := srv.db.PostMessage(channelID, nil, nil, &aliceID, "alice", "helo wrld")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	for _, content := range []string{"hello wrld", "hello world"} {
+		if _, err := srv.db.UpdateMessage(uint64(msgID), uint64(aliceID), content); err != nil {
+			t.Fatalf("UpdateMessage: %v", err)
+		}
+	}
+
+	getHistory := func(sess *Session, conn *mockConn, messageID uint64) *protocol.MessageHistoryMessage {
+		t.Helper()
+		conn.writeBuf.Reset()
+		payload, err := (&protocol.GetMessageHistoryMessage{MessageID: messageID}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeGetMessageHistory, Payload: payload}
+		if err := srv.handleGetMessageHistory(sess, frame); err != nil {
+			t.Fatalf("handleGetMessageHistory: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		if resp.Type != protocol.TypeMessageHistory {
+			t.Fatalf("expected MESSAGE_HISTORY, got 0x%02X", resp.Type)
+		}
+		history := &protocol.MessageHistoryMessage{}
+		if err := history.Decode(resp.Payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return history
+	}
+
+	guestConn := newMockConn()
+	guest, err := srv.sessions.CreateSession(nil, "guest", "tcp", guestConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	t.Run("edits are listed oldest first", func(t *testing.T) {
+		history := getHistory(guest, guestConn, uint64(msgID))
+		if !history.Success {
+			t.Fatalf("request failed: %s", history.Message)
+		}
+		want := []struct {
+			kind    uint8
+			content string
+		}{
+			{protocol.RevisionCreated, "helo wrld"},
+			{protocol.RevisionEdited, "hello wrld"},
+			{protocol.RevisionEdited, "hello world"},
+		}
+		if len(history.Revisions) != len(want) {
+			t.Fatalf("got %d revisions, want %d", len(history.Revisions), len(want))
+		}
+		for i, rev := range history.Revisions {
+			if rev.Kind != want[i].kind || rev.Content != want[i].content || rev.Hidden {
+				t.Errorf("revision %d = %+v, want kind %d %q", i, rev, want[i].kind, want[i].content)
+			}
+		}
+	})
+
+	t.Run("deleted content is only shown to admins", func(t *testing.T) {
+		if _, err := srv.db.SoftDeleteMessage(uint64(msgID), "alice"); err != nil {
+			t.Fatalf("SoftDeleteMessage: %v", err)
+		}
+
+		history := getHistory(guest, guestConn, uint64(msgID))
+		if len(history.Revisions) != 4 || history.Revisions[3].Kind != protocol.RevisionDeleted {
+			t.Fatalf("expected a deletion revision, got %+v", history.Revisions)
+		}
+		for _, rev := range history.Revisions {
+			if !rev.Hidden || rev.Content != "" {
+				t.Errorf("non-admin saw deleted content: %+v", rev)
+			}
+		}
+
+		admin, adminConn := adminSession(t, srv)
+		history = getHistory(admin, adminConn, uint64(msgID))
+		if len(history.Revisions) != 4 || history.Revisions[3].Content != "hello world" || history.Revisions[0].Content != "helo wrld" {
+			t.Errorf("admin got %+v", history.Revisions)
+		}
+	})
+
+	t.Run("unknown message", func(t *testing.T) {
+		if history := getHistory(guest, guestConn, 999999); history.Success {
+			t.Error("expected failure for unknown message")
+		}
+	})
+}
+
+func TestHandleUpdateChannel(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := srv.db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bobID, err := srv.db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	channelID, err := srv.db.CreateChannel("news", "News", nil, 1, 168, &aliceID)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	aliceConn := newMockConn()
+	alice, err := srv.sessions.CreateSession(&aliceID, "alice", "tcp", aliceConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	bobConn := newMockConn()
+	bob, err := srv.sessions.CreateSession(&bobID, "bob", "tcp", bobConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	readUpdated := func(conn *mockConn) *protocol.ChannelUpdatedMessage {
+		t.Helper()
+		resp, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		if resp.Type != protocol.TypeChannelUpdated {
+			t.Fatalf("expected CHANNEL_UPDATED, got 0x%02X", resp.Type)
+		}
+		msg := &protocol.ChannelUpdatedMessage{}
+		if err := msg.Decode(resp.Payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return msg
+	}
+	update := func(sess *Session, conn *mockConn, msg *protocol.UpdateChannelMessage) *protocol.ChannelUpdatedMessage {
+		t.Helper()
+		aliceConn.writeBuf.Reset()
+		bobConn.writeBuf.Reset()
+		if err := srv.handleUpdateChannel(sess, encodeAdminFrame(t, protocol.TypeUpdateChannel, msg)); err != nil {
+			t.Fatalf("handleUpdateChannel: %v", err)
+		}
+		return readUpdated(conn)
+	}
+
+	displayName := "Announcements"
+	archived := true
+
+	t.Run("only the creator or an admin can update", func(t *testing.T) {
+		resp := update(bob, bobConn, &protocol.UpdateChannelMessage{ChannelID: uint64(channelID), DisplayName: &displayName})
+		if resp.Success {
+			t.Fatal("expected bob to be rejected")
+		}
+		if ch, _ := srv.db.GetChannel(channelID); ch.DisplayName != "News" {
+			t.Errorf("channel changed to %q", ch.DisplayName)
+		}
+	})
+
+	t.Run("invalid settings are rejected", func(t *testing.T) {
+		retention := uint32(0)
+		if resp := update(alice, aliceConn, &protocol.UpdateChannelMessage{ChannelID: uint64(channelID), RetentionHours: &retention}); resp.Success {
+			t.Error("expected zero retention to be rejected")
+		}
+	})
+
+	t.Run("creator archives the channel", func(t *testing.T) {
+		resp := update(alice, aliceConn, &protocol.UpdateChannelMessage{ChannelID: uint64(channelID), DisplayName: &displayName, Archived: &archived})
+		if !resp.Success || resp.DisplayName != displayName || !resp.Archived || resp.RetentionHours != 168 {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+
+		// Everyone else hears about it
+		if broadcast := readUpdated(bobConn); !broadcast.Success || !broadcast.Archived {
+			t.Errorf("unexpected broadcast %+v", broadcast)
+		}
+
+		ch, err := srv.db.GetChannel(channelID)
+		if err != nil {
+			t.Fatalf("GetChannel: %v", err)
+		}
+		if ch.DisplayName != displayName || !ch.Archived || ch.ChannelType != 1 {
+			t.Errorf("unexpected channel after update %+v", ch)
+		}
+	})
+
+	t.Run("archived channels reject posts", func(t *testing.T) {
+		bobConn.writeBuf.Reset()
+		payload, err := (&protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: "hello"}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := srv.handlePostMessage(bob, &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypePostMessage, Payload: payload}); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(bobConn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		if resp.Type != protocol.TypeError || errMsg.Decode(resp.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodeChannelArchived {
+			t.Fatalf("expected a channel archived error, got 0x%02X", resp.Type)
+		}
+	})
+
+	t.Run("admins can update other channels", func(t *testing.T) {
+		admin, adminConn := adminSession(t, srv)
+		archived := false
+		resp := update(admin, adminConn, &protocol.UpdateChannelMessage{ChannelID: uint64(channelID), Archived: &archived})
+		if !resp.Success || resp.Archived || resp.DisplayName != displayName {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+	})
+}
+
+func TestHandleListAdminActions(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	admin, adminConn := adminSession(t, srv)
+	for i := 0; i < 3; i++ {
+		if err := srv.db.LogAdminAction(uint64(*admin.UserID), "admin", "DELETE_CHANNEL", fmt.Sprintf("Deleted channel #c%d", i)); err != nil {
+			t.Fatalf("LogAdminAction: %v", err)
+		}
+	}
+	if err := srv.db.LogAdminAction(uint64(*admin.UserID), "admin", "UPDATE_CHANNEL", "Archived #news"); err != nil {
+		t.Fatalf("LogAdminAction: %v", err)
+	}
+
+	list := func(msg *protocol.ListAdminActionsMessage) *protocol.AdminActionListMessage {
+		t.Helper()
+		adminConn.writeBuf.Reset()
+		if err := srv.handleListAdminActions(admin, encodeAdminFrame(t, protocol.TypeListAdminActions, msg)); err != nil {
+			t.Fatalf("handleListAdminActions: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(adminConn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		if resp.Type != protocol.TypeAdminActionList {
+			t.Fatalf("expected ADMIN_ACTION_LIST, got 0x%02X", resp.Type)
+		}
+		result := &protocol.AdminActionListMessage{}
+		if err := result.Decode(resp.Payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return result
+	}
+
+	// Filter by action type and page through the results
+	page := list(&protocol.ListAdminActionsMessage{ActionType: "delete_channel", Limit: 2})
+	if len(page.Actions) != 2 || !page.HasMore {
+		t.Fatalf("expected a full first page with more to come, got %d (more=%v)", len(page.Actions), page.HasMore)
+	}
+	if page.Actions[0].Details != "Deleted channel #c2" || page.Actions[0].AdminUserID == nil {
+		t.Errorf("unexpected newest entry %+v", page.Actions[0])
+	}
+	beforeID := page.Actions[1].ID
+	page = list(&protocol.ListAdminActionsMessage{ActionType: "delete_channel", BeforeID: &beforeID, Limit: 2})
+	if len(page.Actions) != 1 || page.HasMore || page.Actions[0].Details != "Deleted channel #c0" {
+		t.Fatalf("expected the last entry on the second page, got %+v (more=%v)", page.Actions, page.HasMore)
+	}
+
+	if page := list(&protocol.ListAdminActionsMessage{}); len(page.Actions) != 4 || page.HasMore {
+		t.Errorf("expected all 4 entries unfiltered, got %d", len(page.Actions))
+	}
+
+	// Non-admins are refused
+	userID, err := srv.db.CreateUser("user", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	userConn := newMockConn()
+	user, err := srv.sessions.CreateSession(&userID, "user", "tcp", userConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := srv.handleListAdminActions(user, encodeAdminFrame(t, protocol.TypeListAdminActions, &protocol.ListAdminActionsMessage{})); err != nil {
+		t.Fatalf("handleListAdminActions: %v", err)
+	}
+	resp, err := protocol.DecodeFrame(userConn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	if resp.Type != protocol.TypeError {
+		t.Errorf("expected ERROR for a non-admin, got 0x%02X", resp.Type)
+	}
+}
+
+func TestHandleKickAndMuteUser(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	admin, adminConn := adminSession(t, srv)
+	bobID, err := srv.db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	generalID, err := srv.db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+	randomID, err := srv.db.CreateChannel("random", "Random", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	connect := func(t *testing.T) (*Session, *mockConn) {
+		t.Helper()
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(&bobID, "bob", "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return sess, conn
+	}
+	// readFrame skips presence broadcasts until it finds a frame of the given type
+	readFrame := func(t *testing.T, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("no 0x%02X frame: %v", msgType, err)
+			}
+			if resp.Type != msgType {
+				continue
+			}
+			if err := msg.Decode(resp.Payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return
+		}
+	}
+	post := func(t *testing.T, sess *Session, conn *mockConn, channelID int64) *protocol.Frame {
+		t.Helper()
+		conn.writeBuf.Reset()
+		payload, err := (&protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: "hello"}).Encode()
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := srv.handlePostMessage(sess, &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypePostMessage, Payload: payload}); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("kick disconnects every session with the reason", func(t *testing.T) {
+		bob1, bob1Conn := connect(t)
+		bob2, bob2Conn := connect(t)
+
+		nickname := "Bob"
+		adminConn.writeBuf.Reset()
+		if err := srv.handleKickUser(admin, encodeAdminFrame(t, protocol.TypeKickUser, &protocol.KickUserMessage{Nickname: &nickname, Reason: "cool off"})); err != nil {
+			t.Fatalf("handleKickUser: %v", err)
+		}
+		resp := &protocol.UserKickedMessage{}
+		readFrame(t, adminConn, protocol.TypeUserKicked, resp)
+		if !resp.Success || resp.SessionsDisconnected != 2 {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+
+		for _, conn := range []*mockConn{bob1Conn, bob2Conn} {
+			disconnect := &protocol.DisconnectMessage{}
+			readFrame(t, conn, protocol.TypeDisconnect, disconnect)
+			if disconnect.Reason == nil || *disconnect.Reason != "Kicked by admin: cool off" {
+				t.Errorf("unexpected disconnect reason %v", disconnect.Reason)
+			}
+		}
+		for _, sess := range []*Session{bob1, bob2} {
+			if _, ok := srv.sessions.GetSession(sess.ID); ok {
+				t.Errorf("session %d still connected", sess.ID)
+			}
+		}
+
+		// Nobody left to kick
+		adminConn.writeBuf.Reset()
+		if err := srv.handleKickUser(admin, encodeAdminFrame(t, protocol.TypeKickUser, &protocol.KickUserMessage{Nickname: &nickname})); err != nil {
+			t.Fatalf("handleKickUser: %v", err)
+		}
+		readFrame(t, adminConn, protocol.TypeUserKicked, resp)
+		if resp.Success {
+			t.Error("expected kicking a disconnected user to fail")
+		}
+	})
+
+	t.Run("channel mute blocks posting in that channel only", func(t *testing.T) {
+		bob, bobConn := connect(t)
+		userID := uint64(bobID)
+		channelID := uint64(generalID)
+		duration := uint64(3600)
+
+		adminConn.writeBuf.Reset()
+		if err := srv.handleMuteUser(admin, encodeAdminFrame(t, protocol.TypeMuteUser, &protocol.MuteUserMessage{UserID: &userID, ChannelID: &channelID, Reason: "spam", DurationSeconds: &duration})); err != nil {
+			t.Fatalf("handleMuteUser: %v", err)
+		}
+		resp := &protocol.UserMutedMessage{}
+		readFrame(t, adminConn, protocol.TypeUserMuted, resp)
+		if !resp.Success || resp.MuteID == 0 {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+
+		frame := post(t, bob, bobConn, generalID)
+		errMsg := &protocol.ErrorMessage{}
+		if frame.Type != protocol.TypeError || errMsg.Decode(frame.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodeMuted {
+			t.Fatalf("expected a muted error, got 0x%02X", frame.Type)
+		}
+		if !strings.Contains(errMsg.Message, "spam") {
+			t.Errorf("expected the reason in %q", errMsg.Message)
+		}
+		if frame := post(t, bob, bobConn, randomID); frame.Type != protocol.TypeMessagePosted {
+			t.Errorf("expected posting elsewhere to succeed, got 0x%02X", frame.Type)
+		}
+
+		// Lifting the mute lets bob post again
+		adminConn.writeBuf.Reset()
+		if err := srv.handleUnmuteUser(admin, encodeAdminFrame(t, protocol.TypeUnmuteUser, &protocol.UnmuteUserMessage{UserID: &userID, ChannelID: &channelID})); err != nil {
+			t.Fatalf("handleUnmuteUser: %v", err)
+		}
+		unmuted := &protocol.UserUnmutedMessage{}
+		readFrame(t, adminConn, protocol.TypeUserUnmuted, unmuted)
+		if !unmuted.Success {
+			t.Fatalf("unexpected response %+v", unmuted)
+		}
+		if frame := post(t, bob, bobConn, generalID); frame.Type != protocol.TypeMessagePosted {
+			t.Errorf("expected posting to succeed after unmute, got 0x%02X", frame.Type)
+		}
+	})
+
+	t.Run("expired mutes no longer apply", func(t *testing.T) {
+		bob, bobConn := connect(t)
+		duration := uint64(0)
+		nickname := "bob"
+		adminConn.writeBuf.Reset()
+		if err := srv.handleMuteUser(admin, encodeAdminFrame(t, protocol.TypeMuteUser, &protocol.MuteUserMessage{Nickname: &nickname, DurationSeconds: &duration})); err != nil {
+			t.Fatalf("handleMuteUser: %v", err)
+		}
+		if frame := post(t, bob, bobConn, generalID); frame.Type != protocol.TypeMessagePosted {
+			t.Errorf("expected an expired mute to be ignored, got 0x%02X", frame.Type)
+		}
+	})
+
+	t.Run("non-admins are refused", func(t *testing.T) {
+		bob, bobConn := connect(t)
+		nickname := "admin"
+		if err := srv.handleMuteUser(bob, encodeAdminFrame(t, protocol.TypeMuteUser, &protocol.MuteUserMessage{Nickname: &nickname})); err != nil {
+			t.Fatalf("handleMuteUser: %v", err)
+		}
+		resp := &protocol.UserMutedMessage{}
+		readFrame(t, bobConn, protocol.TypeUserMuted, resp)
+		if resp.Success {
+			t.Error("expected a non-admin mute to fail")
+		}
+	})
+
+	t.Run("actions are logged", func(t *testing.T) {
+		actions, err := srv.db.ListAdminActions(database.AdminActionFilter{})
+		if err != nil {
+			t.Fatalf("ListAdminActions: %v", err)
+		}
+		var types []string
+		for _, action := range actions {
+			types = append(types, action.ActionType)
+		}
+		if got := strings.Join(types, ","); got != "MUTE_USER,UNMUTE_USER,MUTE_USER,KICK_USER" {
+			t.Errorf("unexpected audit log %s", got)
+		}
+	})
+}
+
+func TestHandleEditMessageWhileMuted(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	bobID, err := srv.db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	channelID, err := srv.db.CreateChannel("general", "General", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+	messageID, _, err := srv.db.PostMessage(channelID, nil, nil, &bobID, "bob", "hello")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if _, err := srv.db.CreateMute(&bobID, "bob", &channelID, "spam", nil, "admin"); err != nil {
+		t.Fatalf("CreateMute: %v", err)
+	}
+
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(&bobID, "bob", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	conn.writeBuf.Reset()
+	if err := srv.handleEditMessage(sess, encodeAdminFrame(t, protocol.TypeEditMessage, &protocol.EditMessageMessage{MessageID: uint64(messageID), NewContent: "buy now"})); err != nil {
+		t.Fatalf("handleEditMessage: %v", err)
+	}
+
+	frame, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	errMsg := &protocol.ErrorMessage{}
+	if frame.Type != protocol.TypeError || errMsg.Decode(frame.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodeMuted {
+		t.Fatalf("expected a muted error, got 0x%02X", frame.Type)
+	}
+	if !strings.Contains(errMsg.Message, "spam") {
+		t.Errorf("expected the reason in %q", errMsg.Message)
+	}
+	stored, err := srv.db.GetMessage(messageID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if stored.Content != "hello" || stored.EditedAt != nil {
+		t.Errorf("expected the message to be unchanged, got %q", stored.Content)
+	}
+}
+func TestHandleStartDMRatchet(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	type user struct {
+		sess *Session
+		conn *mockConn
+	}
+	// register connects a user whose client provides an encryption key,
+	// with or without ratchet support
+	register := func(t *testing.T, nickname string, ratchet bool) *user {
+		t.Helper()
+		userID, err := srv.db.CreateUser(nickname, "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(&userID, nickname, "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		if err := srv.handleProvidePublicKey(sess, encodeAdminFrame(t, protocol.TypeProvidePublicKey, &protocol.ProvidePublicKeyMessage{
+			KeyType: protocol.KeyTypeGenerated, PublicKey: [32]byte{byte(userID), 9}, Ratchet: ratchet,
+		})); err != nil {
+			t.Fatalf("handleProvidePublicKey: %v", err)
+		}
+		return &user{sess: sess, conn: conn}
+	}
+	ready := func(t *testing.T, conn *mockConn) *protocol.DMReadyMessage {
+		t.Helper()
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("no DM_READY frame: %v", err)
+			}
+			if resp.Type != protocol.TypeDMReady {
+				continue
+			}
+			msg := &protocol.DMReadyMessage{}
+			if err := msg.Decode(resp.Payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return msg
+		}
+	}
+	startDM := func(t *testing.T, from, to *user, ratchet bool) (*protocol.DMReadyMessage, *protocol.DMReadyMessage) {
+		t.Helper()
+		from.conn.writeBuf.Reset()
+		to.conn.writeBuf.Reset()
+		to.sess.mu.RLock()
+		nickname := to.sess.Nickname
+		to.sess.mu.RUnlock()
+		if err := srv.handleStartDM(from.sess, encodeAdminFrame(t, protocol.TypeStartDM, &protocol.StartDMMessage{
+			TargetType: protocol.DMTargetByNickname, TargetNickname: nickname, Ratchet: ratchet,
+		})); err != nil {
+			t.Fatalf("handleStartDM: %v", err)
+		}
+		return ready(t, from.conn), ready(t, to.conn)
+	}
+
+	alice := register(t, "alice", true)
+	bob := register(t, "bob", true)
+	carol := register(t, "carol", false)
+
+	t.Run("both clients support the ratchet", func(t *testing.T) {
+		aliceReady, bobReady := startDM(t, alice, bob, true)
+		if !aliceReady.IsEncrypted || !aliceReady.Ratchet || !bobReady.Ratchet {
+			t.Fatalf("expected a ratcheted DM, got %+v and %+v", aliceReady, bobReady)
+		}
+		if ratchet, _ := srv.db.IsDMRatchet(int64(aliceReady.ChannelID)); !ratchet {
+			t.Error("expected the DM to be stored as ratcheted")
+		}
+
+		// Reopening the DM keeps its mode, even from an older client
+		bob.conn.writeBuf.Reset()
+		if err := srv.handleStartDM(bob.sess, encodeAdminFrame(t, protocol.TypeStartDM, &protocol.StartDMMessage{
+			TargetType: protocol.DMTargetByNickname, TargetNickname: "alice",
+		})); err != nil {
+			t.Fatalf("handleStartDM: %v", err)
+		}
+		if again := ready(t, bob.conn); again.ChannelID != aliceReady.ChannelID || !again.Ratchet {
+			t.Errorf("expected the existing ratcheted DM, got %+v", again)
+		}
+	})
+
+	t.Run("older clients get static keys", func(t *testing.T) {
+		aliceReady, carolReady := startDM(t, alice, carol, true)
+		if !aliceReady.IsEncrypted || aliceReady.Ratchet || carolReady.Ratchet {
+			t.Errorf("expected a static-key DM with carol's older client, got %+v and %+v", aliceReady, carolReady)
+		}
+
+		dave := register(t, "dave", true)
+		daveReady, bobReady := startDM(t, dave, bob, false)
+		if daveReady.Ratchet || bobReady.Ratchet {
+			t.Errorf("expected a static-key DM from an older initiator, got %+v and %+v", daveReady, bobReady)
+		}
+	})
+}
@@ -0,0 +1,348 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// Largest request body accepted by POST /hooks/{token}
+const incomingWebhookMaxBody = 64 * 1024
+
+// newIncomingWebhookToken returns a random URL-safe token
+func newIncomingWebhookToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashIncomingWebhookToken returns the stored form of a token
+func hashIncomingWebhookToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateIncomingWebhookTarget checks that a webhook can post where it's
+// configured to. Returns a user-facing error message, or "" if it's valid.
+func (s *Server) validateIncomingWebhookTarget(msg *protocol.CreateIncomingWebhookMessage) string {
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || channel.ParentID != nil {
+		return "Channel not found"
+	}
+	if channel.IsDM {
+		return "Webhooks cannot post into DMs"
+	}
+
+	if msg.SubchannelID != nil {
+		sub, err := s.db.GetChannel(int64(*msg.SubchannelID))
+		if err != nil || sub.ParentID == nil || *sub.ParentID != channel.ID {
+			return "Subchannel not found"
+		}
+	}
+
+	if msg.ParentID != nil {
+		if channel.ChannelType == 0 {
+			return "Chat channels do not support threaded replies"
+		}
+		parent, err := s.db.GetMessage(int64(*msg.ParentID))
+		if err != nil || parent.DeletedAt != nil || parent.ChannelID != channel.ID {
+			return "Message not found"
+		}
+		parentSub := int64PtrFromUint64(msg.SubchannelID)
+		if (parent.SubchannelID == nil) != (parentSub == nil) ||
+			(parentSub != nil && *parent.SubchannelID != *parentSub) {
+			return "Message is not in that subchannel"
+		}
+	}
+
+	return ""
+}
+
+// handleCreateIncomingWebhook handles CREATE_INCOMING_WEBHOOK message (admin only)
+func (s *Server) handleCreateIncomingWebhook(sess *Session, frame *protocol.Frame) error {
+	// Check admin permissions
+	if !s.isAdmin(sess) {
+		return s.sendMessage(sess, protocol.TypeIncomingWebhookCreated, &protocol.IncomingWebhookCreatedMessage{
+			Success: false,
+			Message: "Permission denied: admin access required",
+		})
+	}
+
+	// Decode message
+	msg := &protocol.CreateIncomingWebhookMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// The name is shown as the author, so it follows the nickname rules
+	if !nicknameRegex.MatchString(msg.Name) {
+		return s.sendMessage(sess, protocol.TypeIncomingWebhookCreated, &protocol.IncomingWebhookCreatedMessage{
+			Success: false,
+			Message: "Invalid name. Must be 3-20 characters, alphanumeric plus - and _",
+		})
+	}
+
+	if problem := s.validateIncomingWebhookTarget(msg); problem != "" {
+		return s.sendMessage(sess, protocol.TypeIncomingWebhookCreated, &protocol.IncomingWebhookCreatedMessage{
+			Success: false,
+			Message: problem,
+		})
+	}
+
+	token, err := newIncomingWebhookToken()
+	if err != nil {
+		log.Printf("Failed to generate webhook token: %v", err)
+		return s.sendError(sess, 9000, "Failed to generate token")
+	}
+
+	sess.mu.RLock()
+	adminNickname := sess.Nickname
+	adminUserID := sess.UserID
+	sess.mu.RUnlock()
+
+	hook := &database.IncomingWebhook{
+		TokenHash:    hashIncomingWebhookToken(token),
+		Name:         msg.Name,
+		ChannelID:    int64(msg.ChannelID),
+		SubchannelID: int64PtrFromUint64(msg.SubchannelID),
+		ParentID:     int64PtrFromUint64(msg.ParentID),
+		CreatedBy:    adminNickname,
+		CreatedAt:    time.Now().UnixMilli(),
+	}
+	if err := s.db.CreateIncomingWebhook(hook); err != nil {
+		log.Printf("Failed to create incoming webhook: %v", err)
+		return s.sendMessage(sess, protocol.TypeIncomingWebhookCreated, &protocol.IncomingWebhookCreatedMessage{
+			Success: false,
+			Message: "Failed to create webhook",
+		})
+	}
+
+	// Log admin action
+	if adminUserID != nil {
+		if err := s.db.LogAdminAction(uint64(*adminUserID), adminNickname, "CREATE_INCOMING_WEBHOOK",
+			fmt.Sprintf("webhook_id=%d name=%s channel_id=%d", hook.ID, hook.Name, hook.ChannelID)); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	log.Printf("Admin %s created incoming webhook %d (%s) for channel %d", adminNickname, hook.ID, hook.Name, hook.ChannelID)
+
+	return s.sendMessage(sess, protocol.TypeIncomingWebhookCreated, &protocol.IncomingWebhookCreatedMessage{
+		Success:   true,
+		WebhookID: uint64(hook.ID),
+		Token:     token,
+		Message:   fmt.Sprintf("Webhook '%s' created", hook.Name),
+	})
+}
+
+// handleListIncomingWebhooks handles LIST_INCOMING_WEBHOOKS message (admin only)
+func (s *Server) handleListIncomingWebhooks(sess *Session, frame *protocol.Frame) error {
+	// Check admin permissions
+	if !s.isAdmin(sess) {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "Permission denied: admin access required")
+	}
+
+	hooks, err := s.db.ListIncomingWebhooks()
+	if err != nil {
+		return s.dbError(sess, "ListIncomingWebhooks", err)
+	}
+
+	entries := make([]protocol.IncomingWebhookEntry, len(hooks))
+	for i, hook := range hooks {
+		channelName := ""
+		if channel, err := s.db.GetChannel(hook.ChannelID); err == nil {
+			channelName = channel.Name
+		}
+
+		entries[i] = protocol.IncomingWebhookEntry{
+			ID:           uint64(hook.ID),
+			Name:         hook.Name,
+			ChannelID:    uint64(hook.ChannelID),
+			ChannelName:  channelName,
+			SubchannelID: uint64PtrFromInt64(hook.SubchannelID),
+			ParentID:     uint64PtrFromInt64(hook.ParentID),
+			CreatedBy:    hook.CreatedBy,
+			CreatedAt:    hook.CreatedAt,
+			LastUsedAt:   hook.LastUsedAt,
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypeIncomingWebhookList, &protocol.IncomingWebhookListMessage{
+		Webhooks: entries,
+	})
+}
+
+// handleRevokeIncomingWebhook handles REVOKE_INCOMING_WEBHOOK message (admin only)
+func (s *Server) handleRevokeIncomingWebhook(sess *Session, frame *protocol.Frame) error {
+	// Check admin permissions
+	if !s.isAdmin(sess) {
+		return s.sendMessage(sess, protocol.TypeIncomingWebhookRevoked, &protocol.IncomingWebhookRevokedMessage{
+			Success: false,
+			Message: "Permission denied: admin access required",
+		})
+	}
+
+	msg := &protocol.RevokeIncomingWebhookMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	if err := s.db.DeleteIncomingWebhook(int64(msg.WebhookID)); err != nil {
+		message := "Failed to revoke webhook"
+		if errors.Is(err, database.ErrIncomingWebhookNotFound) {
+			message = "Webhook not found"
+		} else {
+			log.Printf("Failed to revoke incoming webhook %d: %v", msg.WebhookID, err)
+		}
+		return s.sendMessage(sess, protocol.TypeIncomingWebhookRevoked, &protocol.IncomingWebhookRevokedMessage{
+			Success:   false,
+			WebhookID: msg.WebhookID,
+			Message:   message,
+		})
+	}
+
+	sess.mu.RLock()
+	adminNickname := sess.Nickname
+	adminUserID := sess.UserID
+	sess.mu.RUnlock()
+
+	if adminUserID != nil {
+		if err := s.db.LogAdminAction(uint64(*adminUserID), adminNickname, "REVOKE_INCOMING_WEBHOOK",
+			fmt.Sprintf("webhook_id=%d", msg.WebhookID)); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	log.Printf("Admin %s revoked incoming webhook %d", adminNickname, msg.WebhookID)
+
+	return s.sendMessage(sess, protocol.TypeIncomingWebhookRevoked, &protocol.IncomingWebhookRevokedMessage{
+		Success:   true,
+		WebhookID: msg.WebhookID,
+		Message:   "Webhook revoked",
+	})
+}
+
+// IncomingWebhookHandler serves POST /hooks/{token}. The body is either JSON
+// ({"content": "..."}) or plain text, and is posted as the webhook's name into
+// its channel, subchannel or thread.
+func (s *Server) IncomingWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, err := s.db.GetIncomingWebhookByTokenHash(hashIncomingWebhookToken(r.PathValue("token")))
+	if errors.Is(err, database.ErrIncomingWebhookNotFound) {
+		http.Error(w, "Unknown webhook", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up incoming webhook: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	content, err := readIncomingWebhookContent(w, r)
+	if err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if content == "" {
+		http.Error(w, "Message content is empty", http.StatusBadRequest)
+		return
+	}
+	if uint32(len(content)) > s.config.MaxMessageLength {
+		http.Error(w, fmt.Sprintf("Message too long (max %d bytes)", s.config.MaxMessageLength), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Webhooks share the message rate limit, with a bucket per webhook
+	if ok, wait := s.messageLimiter.allow(fmt.Sprintf("webhook:%d", hook.ID)); !ok {
+		if s.metrics != nil {
+			s.metrics.RecordRateLimitRejection("message")
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, fmt.Sprintf("Message rate limit exceeded (max %d per minute)", s.config.MessageRateLimit), http.StatusTooManyRequests)
+		return
+	}
+
+	channel, err := s.db.GetChannel(hook.ChannelID)
+	if err != nil {
+		http.Error(w, "Channel no longer exists", http.StatusGone)
+		return
+	}
+	if s.channelArchived(channel, hook.SubchannelID) {
+		http.Error(w, "Channel is archived and read-only", http.StatusForbidden)
+		return
+	}
+
+	messageID, dbMsg, err := s.db.PostMessage(hook.ChannelID, hook.SubchannelID, hook.ParentID, nil, hook.Name, content)
+	if err != nil {
+		log.Printf("Incoming webhook %d: PostMessage failed: %v", hook.ID, err)
+		if hook.ParentID != nil {
+			// Most likely the thread was deleted or expired
+			http.Error(w, "Thread no longer exists", http.StatusGone)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.db.TouchIncomingWebhook(hook.ID, time.Now().UnixMilli()); err != nil {
+		log.Printf("Incoming webhook %d: failed to record use: %v", hook.ID, err)
+	}
+
+	// Same broadcast path as PostMessage from a client
+	var threadRootID *uint64
+	if dbMsg.ThreadRootID != nil {
+		id := uint64(*dbMsg.ThreadRootID)
+		threadRootID = &id
+	}
+	broadcastMsg := (*protocol.NewMessageMessage)(convertDBMessageToProtocol(dbMsg, s.db))
+	if err := s.broadcastNewMessage(nil, broadcastMsg, threadRootID); err != nil {
+		log.Printf("Incoming webhook %d: failed to broadcast message: %v", hook.ID, err)
+	}
+	s.notifyMentions(dbMsg, channel)
+
+	debugLog.Printf("Incoming webhook %d (%s) posted message %d to channel %d", hook.ID, hook.Name, messageID, hook.ChannelID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"message_id": messageID,
+	}); err != nil {
+		log.Printf("Error encoding webhook response: %v", err)
+	}
+}
+
+// readIncomingWebhookContent extracts the message text from a webhook request
+func readIncomingWebhookContent(w http.ResponseWriter, r *http.Request) (string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, incomingWebhookMaxBody))
+	if err != nil {
+		return "", err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return strings.TrimSpace(string(body)), nil
+	}
+
+	var payload struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(payload.Content), nil
+}
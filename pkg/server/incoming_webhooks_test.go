@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// adminSession creates a registered admin session with a mock connection
+func adminSession(t *testing.T, srv *Server) (*Session, *mockConn) {
+	t.Helper()
+	userID, err := srv.db.CreateUser("admin", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	srv.config.AdminUsers = []string{"admin"}
+
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(&userID, "admin", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return sess, conn
+}
+
+func encodeAdminFrame(t *testing.T, msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+	t.Helper()
+	payload, err := msg.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	return &protocol.Frame{Version: protocol.ProtocolVersion, Type: msgType, Payload: payload}
+}
+
+// createIncomingWebhook creates a webhook through the admin handler and returns its ID and token
+func createIncomingWebhook(t *testing.T, srv *Server, sess *Session, conn *mockConn, msg *protocol.CreateIncomingWebhookMessage) (uint64, string) {
+	t.Helper()
+	conn.writeBuf.Reset()
+	if err := srv.handleCreateIncomingWebhook(sess, encodeAdminFrame(t, protocol.TypeCreateIncomingWebhook, msg)); err != nil {
+		t.Fatalf("handleCreateIncomingWebhook: %v", err)
+	}
+	resp, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	created := &protocol.IncomingWebhookCreatedMessage{}
+	if resp.Type != protocol.TypeIncomingWebhookCreated || created.Decode(resp.Payload) != nil {
+		t.Fatalf("Expected INCOMING_WEBHOOK_CREATED, got type 0x%02X", resp.Type)
+	}
+	if !created.Success {
+		t.Fatalf("Webhook creation failed: %s", created.Message)
+	}
+	return created.WebhookID, created.Token
+}
+
+func postIncomingWebhook(srv *Server, token, contentType, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/{token}", srv.IncomingWebhookHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/hooks/"+token, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIncomingWebhookPostsMessage(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "#general")
+	reloadMemDB(t, srv, db)
+
+	sess, conn := adminSession(t, srv)
+	_, token := createIncomingWebhook(t, srv, sess, conn, &protocol.CreateIncomingWebhookMessage{
+		Name:      "ci-bot",
+		ChannelID: uint64(channelID),
+	})
+
+	rec := postIncomingWebhook(srv, token, "application/json", `{"content": "build passed"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&resp); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+
+	msg, err := srv.db.GetMessage(resp.MessageID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if msg.ChannelID != channelID || msg.AuthorNickname != "ci-bot" || msg.AuthorUserID != nil || msg.Content != "build passed" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+	// Plain text bodies are accepted too
+	rec = postIncomingWebhook(srv, token, "text/plain", "deploy finished\n")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("plain text POST returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	hooks, err := srv.db.ListIncomingWebhooks()
+	if err != nil {
+		t.Fatalf("ListIncomingWebhooks: %v", err)
+	}
+	if len(hooks) != 1 || hooks[0].LastUsedAt == nil {
+		t.Errorf("expected last use to be recorded, got %+v", hooks)
+	}
+}
+
+func TestIncomingWebhookPostsIntoThread(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "alerts", "#alerts")
+	rootID := postTestMessage(t, db, channelID, nil, "alice", "Incidents")
+	reloadMemDB(t, srv, db)
+
+	sess, conn := adminSession(t, srv)
+	parentID := uint64(rootID)
+	_, token := createIncomingWebhook(t, srv, sess, conn, &protocol.CreateIncomingWebhookMessage{
+		Name:      "pager",
+		ChannelID: uint64(channelID),
+		ParentID:  &parentID,
+	})
+
+	if rec := postIncomingWebhook(srv, token, "", "disk full"); rec.Code != http.StatusOK {
+		t.Fatalf("POST returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	replies, err := srv.db.ListThreadReplies(parentID, 10, nil, nil)
+	if err != nil {
+		t.Fatalf("ListThreadReplies: %v", err)
+	}
+	if len(replies) != 1 || replies[0].AuthorNickname != "pager" || replies[0].Content != "disk full" {
+		t.Errorf("unexpected replies %+v", replies)
+	}
+}
+
+func TestIncomingWebhookRejectsBadRequests(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "#general")
+	reloadMemDB(t, srv, db)
+
+	sess, conn := adminSession(t, srv)
+	webhookID, token := createIncomingWebhook(t, srv, sess, conn, &protocol.CreateIncomingWebhookMessage{
+		Name:      "ci-bot",
+		ChannelID: uint64(channelID),
+	})
+
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"unknown token", "not-a-token", "", "hello", http.StatusNotFound},
+		{"empty body", token, "", "   ", http.StatusBadRequest},
+		{"invalid json", token, "application/json", "{", http.StatusBadRequest},
+		{"too long", token, "", strings.Repeat("x", int(srv.config.MaxMessageLength)+1), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if rec := postIncomingWebhook(srv, tt.token, tt.contentType, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	// Revoked tokens stop working
+	conn.writeBuf.Reset()
+	frame := encodeAdminFrame(t, protocol.TypeRevokeIncomingWebhook, &protocol.RevokeIncomingWebhookMessage{WebhookID: webhookID})
+	if err := srv.handleRevokeIncomingWebhook(sess, frame); err != nil {
+		t.Fatalf("handleRevokeIncomingWebhook: %v", err)
+	}
+	resp, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	revoked := &protocol.IncomingWebhookRevokedMessage{}
+	if resp.Type != protocol.TypeIncomingWebhookRevoked || revoked.Decode(resp.Payload) != nil || !revoked.Success {
+		t.Fatalf("revoke failed: type 0x%02X %+v", resp.Type, revoked)
+	}
+	if rec := postIncomingWebhook(srv, token, "", "hello"); rec.Code != http.StatusNotFound {
+		t.Errorf("revoked token: got %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestCreateIncomingWebhookValidation(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "#general")
+	reloadMemDB(t, srv, db)
+
+	// Non-admins are refused
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(nil, "mallory", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	frame := encodeAdminFrame(t, protocol.TypeCreateIncomingWebhook, &protocol.CreateIncomingWebhookMessage{
+		Name:      "bot",
+		ChannelID: uint64(channelID),
+	})
+	if err := srv.handleCreateIncomingWebhook(sess, frame); err != nil {
+		t.Fatalf("handleCreateIncomingWebhook: %v", err)
+	}
+	resp, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	created := &protocol.IncomingWebhookCreatedMessage{}
+	if err := created.Decode(resp.Payload); err != nil || created.Success {
+		t.Errorf("non-admin created a webhook: %+v", created)
+	}
+
+	admin, adminConn := adminSession(t, srv)
+	missing := uint64(999999)
+	invalid := []*protocol.CreateIncomingWebhookMessage{
+		{Name: "x", ChannelID: uint64(channelID)},
+		{Name: "bot", ChannelID: missing},
+		{Name: "bot", ChannelID: uint64(channelID), ParentID: &missing},
+	}
+	for _, msg := range invalid {
+		adminConn.writeBuf.Reset()
+		if err := srv.handleCreateIncomingWebhook(admin, encodeAdminFrame(t, protocol.TypeCreateIncomingWebhook, msg)); err != nil {
+			t.Fatalf("handleCreateIncomingWebhook: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(adminConn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		created := &protocol.IncomingWebhookCreatedMessage{}
+		if err := created.Decode(resp.Payload); err != nil || created.Success {
+			t.Errorf("created webhook for invalid target %+v", msg)
+		}
+	}
+
+	hooks, err := srv.db.ListIncomingWebhooks()
+	if err != nil {
+		t.Fatalf("ListIncomingWebhooks: %v", err)
+	}
+	if len(hooks) != 0 {
+		t.Errorf("expected no webhooks, got %d", len(hooks))
+	}
+}
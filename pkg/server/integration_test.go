@@ -2,15 +2,18 @@ package server
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/aeolun/superchat/pkg/database"
 	"github.com/aeolun/superchat/pkg/protocol"
 )
 
@@ -66,6 +69,24 @@ func connectTCPClient(t *testing.T, addr string) net.Conn {
 	return conn
 }
 
+// waitForConnectionsReleased waits until the server has noticed that every
+// client disconnected, so later subtests start with free connection slots
+func waitForConnectionsReleased(t *testing.T, srv *Server) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		srv.connectionLimiter.mu.Lock()
+		open := len(srv.connectionLimiter.counts)
+		srv.connectionLimiter.mu.Unlock()
+		if open == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("connections were not released")
+}
+
 // sendProtocolMessage sends a protocol message over TCP
 func sendProtocolMessage(t *testing.T, conn net.Conn, frame *protocol.Frame) {
 	t.Helper()
@@ -341,6 +362,10 @@ func TestServerIntegration(t *testing.T) {
 		if sessionCount < uint32(numClients) {
 			t.Logf("Note: Expected at least %d active sessions, got %d (clients may have already disconnected)", numClients, sessionCount)
 		}
+
+		// All clients share one IP, so wait for their connection slots to be
+		// freed before the next subtest connects
+		waitForConnectionsReleased(t, srv)
 	})
 
 	t.Run("session_cleanup/inactive_session", func(t *testing.T) {
@@ -572,6 +597,16 @@ func TestServerIntegration(t *testing.T) {
 		}
 	})
 
+	t.Run("storage/database_locked_while_running", func(t *testing.T) {
+		dbPath := strings.TrimSuffix(srv.dbLock.Name(), ".lock")
+		if _, err := NewServer(dbPath, DefaultConfig(), ""); !errors.Is(err, database.ErrDatabaseLocked) {
+			t.Fatalf("Expected a second server on the same database to fail with ErrDatabaseLocked, got %v", err)
+		}
+		if _, err := database.LockDatabase(dbPath); !errors.Is(err, database.ErrDatabaseLocked) {
+			t.Fatalf("Expected offline tools to find the database locked, got %v", err)
+		}
+	})
+
 	// Note: graceful_shutdown test is NOT included here because it would stop the server
 	// and break other tests. It should be in a separate test function.
 }
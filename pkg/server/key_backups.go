@@ -0,0 +1,67 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// handleStoreKeyBackup stores or deletes a registered user's encrypted key
+// backup. The server can't read it; it only keeps it for the user's other
+// machines.
+func (s *Server) handleStoreKeyBackup(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required. Register to back up your keys.")
+	}
+
+	msg := &protocol.StoreKeyBackupMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		if errors.Is(err, protocol.ErrKeyBackupTooLarge) {
+			return s.sendError(sess, protocol.ErrCodeInvalidInput, "Key backup is too large")
+		}
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	if len(msg.Backup) == 0 {
+		if err := s.db.DeleteKeyBackup(*userID); err != nil {
+			return s.dbError(sess, "DeleteKeyBackup", err)
+		}
+		return s.sendMessage(sess, protocol.TypeKeyBackupStored, &protocol.KeyBackupStoredMessage{})
+	}
+
+	updatedAtMs, err := s.db.SetKeyBackup(*userID, msg.Backup)
+	if err != nil {
+		return s.dbError(sess, "SetKeyBackup", err)
+	}
+	updatedAt := time.UnixMilli(updatedAtMs)
+	return s.sendMessage(sess, protocol.TypeKeyBackupStored, &protocol.KeyBackupStoredMessage{UpdatedAt: &updatedAt})
+}
+
+// handleGetKeyBackup sends a registered user their stored key backup
+func (s *Server) handleGetKeyBackup(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required. Register to back up your keys.")
+	}
+
+	backup, updatedAtMs, err := s.db.GetKeyBackup(*userID)
+	if err != nil {
+		return s.dbError(sess, "GetKeyBackup", err)
+	}
+
+	resp := &protocol.KeyBackupMessage{Backup: []byte{}}
+	if backup != nil {
+		updatedAt := time.UnixMilli(updatedAtMs)
+		resp.Backup = backup
+		resp.UpdatedAt = &updatedAt
+	}
+	return s.sendMessage(sess, protocol.TypeKeyBackup, resp)
+}
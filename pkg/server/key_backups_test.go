@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestKeyBackup(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	userID, err := srv.db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(&userID, "alice", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	// send handles a request and decodes the response frame
+	send := func(t *testing.T, sess *Session, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage, handle func(*Session, *protocol.Frame) error) *protocol.Frame {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := handle(sess, encodeAdminFrame(t, msgType, msg)); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		resp, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		return resp
+	}
+	getBackup := func(t *testing.T) *protocol.KeyBackupMessage {
+		t.Helper()
+		resp := send(t, sess, conn, protocol.TypeGetKeyBackup, &protocol.GetKeyBackupMessage{}, srv.handleGetKeyBackup)
+		if resp.Type != protocol.TypeKeyBackup {
+			t.Fatalf("expected KEY_BACKUP, got 0x%02X", resp.Type)
+		}
+		msg := &protocol.KeyBackupMessage{}
+		if err := msg.Decode(resp.Payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return msg
+	}
+
+	if backup := getBackup(t); len(backup.Backup) != 0 || backup.UpdatedAt != nil {
+		t.Fatalf("expected no backup, got %d bytes", len(backup.Backup))
+	}
+
+	// Store a backup, then fetch it from another session
+	resp := send(t, sess, conn, protocol.TypeStoreKeyBackup, &protocol.StoreKeyBackupMessage{Backup: []byte("opaque")}, srv.handleStoreKeyBackup)
+	stored := &protocol.KeyBackupStoredMessage{}
+	if resp.Type != protocol.TypeKeyBackupStored || stored.Decode(resp.Payload) != nil || stored.UpdatedAt == nil {
+		t.Fatalf("expected KEY_BACKUP_STORED with a timestamp, got 0x%02X", resp.Type)
+	}
+	secondConn := newMockConn()
+	sess, err = srv.sessions.CreateSession(&userID, "alice", "tcp", secondConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	conn = secondConn
+	backup := getBackup(t)
+	if !bytes.Equal(backup.Backup, []byte("opaque")) || backup.UpdatedAt == nil || !backup.UpdatedAt.Equal(*stored.UpdatedAt) {
+		t.Errorf("expected the stored backup, got %q", backup.Backup)
+	}
+
+	// An empty backup deletes it
+	send(t, sess, conn, protocol.TypeStoreKeyBackup, &protocol.StoreKeyBackupMessage{Backup: []byte{}}, srv.handleStoreKeyBackup)
+	if backup := getBackup(t); len(backup.Backup) != 0 {
+		t.Errorf("expected the backup to be deleted, got %q", backup.Backup)
+	}
+
+	// Anonymous users have nowhere to keep a backup
+	anonConn := newMockConn()
+	anon, err := srv.sessions.CreateSession(nil, "guest", "tcp", anonConn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	for _, tc := range []struct {
+		msgType uint8
+		msg     protocol.ProtocolMessage
+		handle  func(*Session, *protocol.Frame) error
+	}{
+		{protocol.TypeStoreKeyBackup, &protocol.StoreKeyBackupMessage{Backup: []byte("opaque")}, srv.handleStoreKeyBackup},
+		{protocol.TypeGetKeyBackup, &protocol.GetKeyBackupMessage{}, srv.handleGetKeyBackup},
+	} {
+		resp := send(t, anon, anonConn, tc.msgType, tc.msg, tc.handle)
+		errMsg := &protocol.ErrorMessage{}
+		if resp.Type != protocol.TypeError || errMsg.Decode(resp.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodeAuthRequired {
+			t.Errorf("expected an auth required error for 0x%02X, got 0x%02X", tc.msgType, resp.Type)
+		}
+	}
+}
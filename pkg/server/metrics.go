@@ -30,6 +30,9 @@ type Metrics struct {
 
 	// Performance metrics
 	broadcastDuration *prometheus.HistogramVec
+
+	// Abuse limit metrics
+	rateLimitRejections *prometheus.CounterVec // by limit type
 }
 
 // NewMetrics creates a new metrics instance
@@ -110,6 +113,13 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"type"},
 		),
+		rateLimitRejections: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "superchat_rate_limit_rejections_total",
+				Help: "Total number of requests rejected by rate or connection limits",
+			},
+			[]string{"limit"}, // "message", "channel_create" or "connection"
+		),
 	}
 }
 
@@ -172,6 +182,11 @@ func (m *Metrics) RecordMessageSent(messageType string) {
 	m.messagesSent.WithLabelValues(messageType).Inc()
 }
 
+// RecordRateLimitRejection increments the rejection counter for a limit type
+func (m *Metrics) RecordRateLimitRejection(limit string) {
+	m.rateLimitRejections.WithLabelValues(limit).Inc()
+}
+
 // Helper to convert uint64 to string for labels
 func uint64ToString(n uint64) string {
 	if n == 0 {
@@ -201,6 +216,68 @@ func messageTypeToString(msgType uint8) string {
 		return "LEAVE_CHANNEL"
 	case protocol.TypeListMessages:
 		return "LIST_MESSAGES"
+	case protocol.TypeSearchMessages:
+		return "SEARCH_MESSAGES"
+	case protocol.TypePlusOne:
+		return "PLUS_ONE"
+	case protocol.TypeListMentions:
+		return "LIST_MENTIONS"
+	case protocol.TypeGetMessageHistory:
+		return "GET_MESSAGE_HISTORY"
+	case protocol.TypeUpdateChannel:
+		return "UPDATE_CHANNEL"
+	case protocol.TypeCreateIncomingWebhook:
+		return "CREATE_INCOMING_WEBHOOK"
+	case protocol.TypeListIncomingWebhooks:
+		return "LIST_INCOMING_WEBHOOKS"
+	case protocol.TypeRevokeIncomingWebhook:
+		return "REVOKE_INCOMING_WEBHOOK"
+	case protocol.TypeListAdminActions:
+		return "LIST_ADMIN_ACTIONS"
+	case protocol.TypeKickUser:
+		return "KICK_USER"
+	case protocol.TypeMuteUser:
+		return "MUTE_USER"
+	case protocol.TypeUnmuteUser:
+		return "UNMUTE_USER"
+	case protocol.TypeSetChannelRole:
+		return "SET_CHANNEL_ROLE"
+	case protocol.TypeListChannelRoles:
+		return "LIST_CHANNEL_ROLES"
+	case protocol.TypeSetChannelGrant:
+		return "SET_CHANNEL_GRANT"
+	case protocol.TypeInviteToChannel:
+		return "INVITE_TO_CHANNEL"
+	case protocol.TypeRemoveFromChannel:
+		return "REMOVE_FROM_CHANNEL"
+	case protocol.TypeLeavePrivateChannel:
+		return "LEAVE_PRIVATE_CHANNEL"
+	case protocol.TypeListPrivateChannels:
+		return "LIST_PRIVATE_CHANNELS"
+	case protocol.TypeDistributeChannelKey:
+		return "DISTRIBUTE_CHANNEL_KEY"
+	case protocol.TypeGetChannelKeys:
+		return "GET_CHANNEL_KEYS"
+	case protocol.TypeStoreKeyBackup:
+		return "STORE_KEY_BACKUP"
+	case protocol.TypeGetKeyBackup:
+		return "GET_KEY_BACKUP"
+	case protocol.TypeRevokeDevice:
+		return "REVOKE_DEVICE"
+	case protocol.TypeResumeSession:
+		return "RESUME_SESSION"
+	case protocol.TypeListSessions:
+		return "LIST_SESSIONS"
+	case protocol.TypeRevokeSession:
+		return "REVOKE_SESSION"
+	case protocol.TypeAuthTOTP:
+		return "AUTH_TOTP"
+	case protocol.TypeSetupTOTP:
+		return "SETUP_TOTP"
+	case protocol.TypeConfirmTOTP:
+		return "CONFIRM_TOTP"
+	case protocol.TypeDisableTOTP:
+		return "DISABLE_TOTP"
 	case protocol.TypePostMessage:
 		return "POST_MESSAGE"
 	case protocol.TypeDeleteMessage:
@@ -237,6 +314,68 @@ func messageTypeToString(msgType uint8) string {
 		return "MESSAGE_POSTED"
 	case protocol.TypeNewMessage:
 		return "NEW_MESSAGE"
+	case protocol.TypeSearchResults:
+		return "SEARCH_RESULTS"
+	case protocol.TypePlusOneUpdate:
+		return "PLUS_ONE_UPDATE"
+	case protocol.TypeMentionNotification:
+		return "MENTION_NOTIFICATION"
+	case protocol.TypeMentionList:
+		return "MENTION_LIST"
+	case protocol.TypeMessageHistory:
+		return "MESSAGE_HISTORY"
+	case protocol.TypeChannelUpdated:
+		return "CHANNEL_UPDATED"
+	case protocol.TypeIncomingWebhookCreated:
+		return "INCOMING_WEBHOOK_CREATED"
+	case protocol.TypeIncomingWebhookList:
+		return "INCOMING_WEBHOOK_LIST"
+	case protocol.TypeIncomingWebhookRevoked:
+		return "INCOMING_WEBHOOK_REVOKED"
+	case protocol.TypeAdminActionList:
+		return "ADMIN_ACTION_LIST"
+	case protocol.TypeUserKicked:
+		return "USER_KICKED"
+	case protocol.TypeUserMuted:
+		return "USER_MUTED"
+	case protocol.TypeUserUnmuted:
+		return "USER_UNMUTED"
+	case protocol.TypeChannelRoleSet:
+		return "CHANNEL_ROLE_SET"
+	case protocol.TypeChannelRoleList:
+		return "CHANNEL_ROLE_LIST"
+	case protocol.TypeChannelGrantSet:
+		return "CHANNEL_GRANT_SET"
+	case protocol.TypeChannelMembershipChanged:
+		return "CHANNEL_MEMBERSHIP_CHANGED"
+	case protocol.TypePrivateChannelList:
+		return "PRIVATE_CHANNEL_LIST"
+	case protocol.TypeChannelInvited:
+		return "CHANNEL_INVITED"
+	case protocol.TypeRemovedFromChannel:
+		return "REMOVED_FROM_CHANNEL"
+	case protocol.TypeChannelKeys:
+		return "CHANNEL_KEYS"
+	case protocol.TypeChannelRekeyRequired:
+		return "CHANNEL_REKEY_REQUIRED"
+	case protocol.TypeKeyBackup:
+		return "KEY_BACKUP"
+	case protocol.TypeKeyBackupStored:
+		return "KEY_BACKUP_STORED"
+	case protocol.TypeDeviceRevoked:
+		return "DEVICE_REVOKED"
+	case protocol.TypeResumeToken:
+		return "RESUME_TOKEN"
+	case protocol.TypeSessionList:
+		return "SESSION_LIST"
+	case protocol.TypeSessionRevoked:
+		return "SESSION_REVOKED"
+	case protocol.TypeTOTPSetup:
+		return "TOTP_SETUP"
+	case protocol.TypeTOTPEnabled:
+		return "TOTP_ENABLED"
+	case protocol.TypeTOTPDisabled:
+		return "TOTP_DISABLED"
 	case protocol.TypeMessageDeleted:
 		return "MESSAGE_DELETED"
 	case protocol.TypeServerConfig:
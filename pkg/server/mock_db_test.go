@@ -7,23 +7,50 @@ import (
 	"github.com/aeolun/superchat/pkg/database"
 )
 
-// mockDB is a simple in-memory mock database for testing
+// mockDB is a simple in-memory mock database for testing.
+// It implements the parts of database.Store that the message handlers use;
+// calling any other Store method panics on the nil embedded interface.
 type mockDB struct {
-	mu       sync.RWMutex
-	channels map[int64]*database.Channel
-	messages map[int64]*database.Message
-	nextMsgID int64
+	database.Store
+
+	mu            sync.RWMutex
+	channels      map[int64]*database.Channel
+	messages      map[int64]*database.Message
+	nextMsgID     int64
+	nextSessionID int64
 }
 
+var _ database.Store = (*mockDB)(nil)
+
 // newMockDB creates a new mock database
 func newMockDB() *mockDB {
 	return &mockDB{
-		channels: make(map[int64]*database.Channel),
-		messages: make(map[int64]*database.Message),
-		nextMsgID: 1,
+		channels:      make(map[int64]*database.Channel),
+		messages:      make(map[int64]*database.Message),
+		nextMsgID:     1,
+		nextSessionID: 1,
 	}
 }
 
+// CreateSession hands out sequential session IDs
+func (m *mockDB) CreateSession(userID *int64, nickname, connType string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextSessionID
+	m.nextSessionID++
+	return id, nil
+}
+
+// DeleteSession is a no-op
+func (m *mockDB) DeleteSession(sessionID int64) error {
+	return nil
+}
+
+// UpdateSessionActivity is a no-op
+func (m *mockDB) UpdateSessionActivity(sessionID int64) error {
+	return nil
+}
+
 // AddChannel adds a channel to the mock database
 func (m *mockDB) AddChannel(id int64, name, description string) {
 	m.mu.Lock()
@@ -56,6 +83,18 @@ func (m *mockDB) ChannelExists(channelID int64) (bool, error) {
 	return ok, nil
 }
 
+// GetChannel retrieves a channel by ID
+func (m *mockDB) GetChannel(channelID int64) (*database.Channel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ch, ok := m.channels[channelID]
+	if !ok {
+		return nil, fmt.Errorf("channel not found")
+	}
+	return ch, nil
+}
+
 // SubchannelExists always returns false for now (V1 doesn't use subchannels)
 func (m *mockDB) SubchannelExists(subchannelID int64) (bool, error) {
 	return false, nil
@@ -82,8 +121,8 @@ func (m *mockDB) ListRootMessages(channelID int64, subchannelID *int64, limit ui
 	return messages, nil
 }
 
-// ListThreadReplies returns replies to a message
-func (m *mockDB) ListThreadReplies(parentID uint64, afterID *uint64) ([]*database.Message, error) {
+// ListThreadReplies returns direct replies to a message
+func (m *mockDB) ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64, afterID *uint64) ([]*database.Message, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -91,7 +130,10 @@ func (m *mockDB) ListThreadReplies(parentID uint64, afterID *uint64) ([]*databas
 	parentIDInt := int64(parentID)
 	for _, msg := range m.messages {
 		if msg.ParentID != nil && *msg.ParentID == parentIDInt {
-			// Apply afterID filter if specified
+			// Apply beforeID/afterID filters if specified
+			if beforeID != nil && uint64(msg.ID) >= *beforeID {
+				continue
+			}
 			if afterID != nil && uint64(msg.ID) <= *afterID {
 				continue
 			}
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// Private channels are invite-only group channels. Their members are kept in
+// ChannelParticipant, like DMs, and only registered users can be members.
+// They never appear in CHANNEL_LIST; members get them from
+// LIST_PRIVATE_CHANNELS instead. Their messages are end-to-end encrypted with
+// a group key that is replaced whenever the members change (see
+// channel_keys.go).
+
+// handleInviteToChannel handles INVITE_TO_CHANNEL. Channel owners and
+// moderators add registered users to their private channels.
+func (s *Server) handleInviteToChannel(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ChannelMemberMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeChannelMembershipChanged, &protocol.ChannelMembershipChangedMessage{
+			Success:   false,
+			ChannelID: msg.ChannelID,
+			Message:   message,
+		})
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || !channel.IsPrivate || channel.IsDM {
+		return fail("Private channel not found")
+	}
+	if !s.can(sess, channel.ID, permInviteUsers) {
+		return fail("Permission denied: only channel owners, moderators or admins can do this")
+	}
+
+	targetID, targetNickname, err := s.resolveModerationTarget(msg.UserID, msg.Nickname)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if targetID == nil {
+		return fail("Only registered users can be members of private channels")
+	}
+	if s.userCanAccessPrivateChannel(*targetID, channel.ID) {
+		return fail(fmt.Sprintf("%s is already a member of #%s", targetNickname, channel.Name))
+	}
+
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if err := s.db.AddChannelParticipant(channel.ID, *targetID, targetNickname); err != nil {
+		return s.dbError(sess, "AddChannelParticipant", err)
+	}
+
+	message := fmt.Sprintf("%s was added to #%s by %s", targetNickname, channel.Name, nickname)
+	log.Printf("%s", message)
+
+	// Admins managing a channel they aren't a member of is moderation
+	if !s.canAccessPrivateChannel(sess, channel.ID) {
+		if err := s.db.LogAdminAction(uint64(*userID), nickname, "INVITE_TO_CHANNEL", message); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	s.sendToUserSessions(*targetID, protocol.TypeChannelInvited, &protocol.ChannelInvitedMessage{
+		ChannelID:      uint64(channel.ID),
+		Name:           channel.Name,
+		Description:    safeDeref(channel.Description, ""),
+		Type:           channel.ChannelType,
+		RetentionHours: channel.MessageRetentionHours,
+		InvitedBy:      nickname,
+	})
+
+	if err := s.sendMessage(sess, protocol.TypeChannelMembershipChanged, &protocol.ChannelMembershipChangedMessage{
+		Success:   true,
+		ChannelID: msg.ChannelID,
+		Message:   message,
+	}); err != nil {
+		return err
+	}
+
+	s.announceMembershipChange(channel.ID, message)
+	s.requestChannelRekey(channel.ID, sess)
+	return nil
+}
+
+// handleRemoveFromChannel handles REMOVE_FROM_CHANNEL. Channel owners and
+// moderators remove members; only owners remove moderators, and only admins
+// remove owners.
+func (s *Server) handleRemoveFromChannel(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ChannelMemberMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeChannelMembershipChanged, &protocol.ChannelMembershipChangedMessage{
+			Success:   false,
+			ChannelID: msg.ChannelID,
+			Message:   message,
+		})
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || !channel.IsPrivate || channel.IsDM {
+		return fail("Private channel not found")
+	}
+	if !s.can(sess, channel.ID, permInviteUsers) {
+		return fail("Permission denied: only channel owners, moderators or admins can do this")
+	}
+
+	targetID, targetNickname, err := s.resolveModerationTarget(msg.UserID, msg.Nickname)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if targetID == nil || !s.userCanAccessPrivateChannel(*targetID, channel.ID) {
+		return fail(fmt.Sprintf("%s is not a member of #%s", targetNickname, channel.Name))
+	}
+
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID != nil && *targetID == *userID {
+		return fail("Use LEAVE_PRIVATE_CHANNEL to leave a channel")
+	}
+
+	targetRole, err := s.db.GetChannelRole(channel.ID, *targetID)
+	if err != nil {
+		return s.dbError(sess, "GetChannelRole", err)
+	}
+	switch {
+	case targetRole == database.ChannelRoleOwner && !s.isAdmin(sess):
+		return fail("Permission denied: only an admin can remove a channel owner")
+	case targetRole == database.ChannelRoleModerator && !s.can(sess, channel.ID, permManageModerators):
+		return fail("Permission denied: only the channel owner or an admin can remove a moderator")
+	}
+
+	message := fmt.Sprintf("%s was removed from #%s by %s", targetNickname, channel.Name, nickname)
+	log.Printf("%s", message)
+
+	// Check before removing anyone: the remover may be acting as an admin
+	isMember := s.canAccessPrivateChannel(sess, channel.ID)
+
+	if err := s.removeChannelMember(channel, *targetID, targetRole, nickname,
+		fmt.Sprintf("You were removed from #%s by %s", channel.Name, nickname)); err != nil {
+		return s.dbError(sess, "RemoveParticipantByUserID", err)
+	}
+
+	if !isMember {
+		if err := s.db.LogAdminAction(uint64(*userID), nickname, "REMOVE_FROM_CHANNEL", message); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+	}
+
+	if err := s.sendMessage(sess, protocol.TypeChannelMembershipChanged, &protocol.ChannelMembershipChangedMessage{
+		Success:   true,
+		ChannelID: msg.ChannelID,
+		Message:   message,
+	}); err != nil {
+		return err
+	}
+
+	s.announceMembershipChange(channel.ID, message)
+	s.requestChannelRekey(channel.ID, sess)
+	return nil
+}
+
+// handleLeavePrivateChannel handles LEAVE_PRIVATE_CHANNEL. The last owner
+// can't leave while others remain; when the last member leaves, the channel
+// is deleted.
+func (s *Server) handleLeavePrivateChannel(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.LeavePrivateChannelMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeChannelMembershipChanged, &protocol.ChannelMembershipChangedMessage{
+			Success:   false,
+			ChannelID: msg.ChannelID,
+			Message:   message,
+		})
+	}
+
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || !channel.IsPrivate || channel.IsDM {
+		return fail("Private channel not found")
+	}
+	if userID == nil || !s.userCanAccessPrivateChannel(*userID, channel.ID) {
+		return fail(fmt.Sprintf("You are not a member of #%s", channel.Name))
+	}
+
+	role, err := s.db.GetChannelRole(channel.ID, *userID)
+	if err != nil {
+		return s.dbError(sess, "GetChannelRole", err)
+	}
+	if role == database.ChannelRoleOwner {
+		lastOwner, others, err := s.soleOwner(channel.ID)
+		if err != nil {
+			return s.dbError(sess, "GetChannelParticipants", err)
+		}
+		if lastOwner && others {
+			return fail("You are the only owner: make someone else an owner before leaving")
+		}
+	}
+
+	if err := s.removeChannelMember(channel, *userID, role, nickname,
+		fmt.Sprintf("You left #%s", channel.Name)); err != nil {
+		return s.dbError(sess, "RemoveParticipantByUserID", err)
+	}
+
+	if err := s.sendMessage(sess, protocol.TypeChannelMembershipChanged, &protocol.ChannelMembershipChangedMessage{
+		Success:   true,
+		ChannelID: msg.ChannelID,
+		Message:   fmt.Sprintf("Left #%s", channel.Name),
+	}); err != nil {
+		return err
+	}
+
+	s.announceMembershipChange(channel.ID, fmt.Sprintf("%s left #%s", nickname, channel.Name))
+	s.requestChannelRekey(channel.ID, nil)
+	return nil
+}
+
+// handleListPrivateChannels handles LIST_PRIVATE_CHANNELS. Anonymous users
+// get an empty list.
+func (s *Server) handleListPrivateChannels(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	channelList := make([]protocol.Channel, 0)
+	if userID != nil {
+		dbChannels, err := s.db.GetPrivateChannelsForUser(*userID)
+		if err != nil {
+			return s.dbError(sess, "GetPrivateChannelsForUser", err)
+		}
+		for _, dbCh := range dbChannels {
+			channelSub := ChannelSubscription{ChannelID: uint64(dbCh.ID)}
+			channelList = append(channelList, protocol.Channel{
+				ID:             uint64(dbCh.ID),
+				Name:           dbCh.Name,
+				Description:    safeDeref(dbCh.Description, ""),
+				UserCount:      uint32(len(s.sessions.GetChannelSubscribers(channelSub))),
+				Type:           dbCh.ChannelType,
+				RetentionHours: dbCh.MessageRetentionHours,
+				Archived:       dbCh.Archived,
+				Modes:          protocol.ChannelModes(dbCh.Modes),
+			})
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypePrivateChannelList, &protocol.ChannelListMessage{
+		Channels: channelList,
+	})
+}
+
+// removeChannelMember takes a user out of a private channel: their
+// membership and role go, their sessions leave the channel and are told why,
+// and an empty channel is deleted
+func (s *Server) removeChannelMember(channel *database.Channel, userID int64, role, removedBy, notice string) error {
+	if err := s.db.RemoveParticipantByUserID(channel.ID, userID); err != nil {
+		return err
+	}
+	if role != database.ChannelRoleMember {
+		if err := s.db.SetChannelRole(channel.ID, userID, database.ChannelRoleMember, removedBy); err != nil {
+			log.Printf("Failed to clear role of user %d in channel %d: %v", userID, channel.ID, err)
+		}
+	}
+
+	for _, other := range s.sessions.GetAllSessions() {
+		other.mu.RLock()
+		match := other.UserID != nil && *other.UserID == userID
+		joined := other.JoinedChannel
+		other.mu.RUnlock()
+		if !match {
+			continue
+		}
+
+		s.sessions.UnsubscribeFromChannelEverywhere(other, uint64(channel.ID))
+		if joined != nil && *joined == channel.ID {
+			if err := s.sessions.SetJoinedChannel(other.ID, nil); err == nil {
+				s.notifyChannelPresence(channel.ID, other, false)
+			}
+		}
+		if err := s.sendMessage(other, protocol.TypeRemovedFromChannel, &protocol.RemovedFromChannelMessage{
+			ChannelID: uint64(channel.ID),
+			Message:   notice,
+		}); err != nil {
+			log.Printf("Failed to send REMOVED_FROM_CHANNEL to session %d: %v", other.ID, err)
+		}
+	}
+
+	remaining, err := s.db.GetChannelParticipants(channel.ID)
+	if err == nil && len(remaining) == 0 {
+		if err := s.db.DeleteChannel(uint64(channel.ID)); err != nil {
+			log.Printf("Failed to delete empty private channel %d: %v", channel.ID, err)
+		} else {
+			log.Printf("Deleted empty private channel %d (%s)", channel.ID, channel.Name)
+		}
+	}
+	return nil
+}
+
+// soleOwner reports whether a private channel has exactly one owner, and
+// whether it has other members who would be left without one
+func (s *Server) soleOwner(channelID int64) (bool, bool, error) {
+	roles, err := s.db.ListChannelRoles(channelID)
+	if err != nil {
+		return false, false, err
+	}
+	owners := 0
+	for _, role := range roles {
+		if role.Role == database.ChannelRoleOwner {
+			owners++
+		}
+	}
+
+	participants, err := s.db.GetChannelParticipants(channelID)
+	if err != nil {
+		return false, false, err
+	}
+	return owners == 1, len(participants) > 1, nil
+}
+
+// announceMembershipChange records a membership change in the channel's
+// history and refreshes the member list of everyone in it. Deleted channels
+// are skipped.
+func (s *Server) announceMembershipChange(channelID int64, content string) {
+	if exists, err := s.db.ChannelExists(channelID); err != nil || !exists {
+		return
+	}
+
+	_, dbMsg, err := s.db.CreateSystemMessage(channelID, content)
+	if err != nil {
+		log.Printf("Failed to record membership change in channel %d: %v", channelID, err)
+	} else {
+		broadcastMsg := (*protocol.NewMessageMessage)(convertDBMessageToProtocol(dbMsg, s.db))
+		if err := s.broadcastNewMessage(nil, broadcastMsg, nil); err != nil {
+			log.Printf("Failed to broadcast membership change in channel %d: %v", channelID, err)
+		}
+	}
+
+	s.broadcastChannelUserList(channelID)
+}
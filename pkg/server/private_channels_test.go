@@ -0,0 +1,270 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestPrivateChannels(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	register := func(nickname string) (*Session, *mockConn) {
+		t.Helper()
+		userID, err := srv.db.CreateUser(nickname, "hash", 0)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(&userID, nickname, "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return sess, conn
+	}
+	owner, ownerConn := register("alice")
+	member, memberConn := register("bob")
+	register("carol")
+
+	// readFrame skips broadcasts until it finds a frame of the given type
+	readFrame := func(t *testing.T, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("no 0x%02X frame: %v", msgType, err)
+			}
+			if resp.Type != msgType {
+				continue
+			}
+			if err := msg.Decode(resp.Payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return
+		}
+	}
+	// hasFrame reports whether a frame of the given type was written
+	hasFrame := func(conn *mockConn, msgType uint8) bool {
+		for {
+			resp, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				return false
+			}
+			if resp.Type == msgType {
+				return true
+			}
+		}
+	}
+	expectError := func(t *testing.T, conn *mockConn, code uint16, handle func() error) {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := handle(); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		readFrame(t, conn, protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != code {
+			t.Errorf("expected error %d, got %d (%s)", code, errMsg.ErrorCode, errMsg.Message)
+		}
+	}
+
+	ownerConn.writeBuf.Reset()
+	memberConn.writeBuf.Reset()
+	if err := srv.handleCreateChannel(owner, encodeAdminFrame(t, protocol.TypeCreateChannel, &protocol.CreateChannelMessage{
+		Name: "team", DisplayName: "#team", ChannelType: 1, RetentionHours: 168, Private: true,
+	})); err != nil {
+		t.Fatalf("handleCreateChannel: %v", err)
+	}
+	created := &protocol.ChannelCreatedMessage{}
+	readFrame(t, ownerConn, protocol.TypeChannelCreated, created)
+	if !created.Success {
+		t.Fatalf("expected the private channel to be created: %+v", created)
+	}
+	channelID := created.ChannelID
+
+	changeMembership := func(t *testing.T, sess *Session, conn *mockConn, msgType uint8, nickname string) *protocol.ChannelMembershipChangedMessage {
+		t.Helper()
+		conn.writeBuf.Reset()
+		var err error
+		switch msgType {
+		case protocol.TypeInviteToChannel:
+			err = srv.handleInviteToChannel(sess, encodeAdminFrame(t, msgType, &protocol.ChannelMemberMessage{ChannelID: channelID, Nickname: &nickname}))
+		case protocol.TypeRemoveFromChannel:
+			err = srv.handleRemoveFromChannel(sess, encodeAdminFrame(t, msgType, &protocol.ChannelMemberMessage{ChannelID: channelID, Nickname: &nickname}))
+		default:
+			err = srv.handleLeavePrivateChannel(sess, encodeAdminFrame(t, msgType, &protocol.LeavePrivateChannelMessage{ChannelID: channelID}))
+		}
+		if err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		resp := &protocol.ChannelMembershipChangedMessage{}
+		readFrame(t, conn, protocol.TypeChannelMembershipChanged, resp)
+		return resp
+	}
+	privateChannels := func(t *testing.T, sess *Session, conn *mockConn) []protocol.Channel {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := srv.handleListPrivateChannels(sess, encodeAdminFrame(t, protocol.TypeListPrivateChannels, &protocol.ListPrivateChannelsMessage{})); err != nil {
+			t.Fatalf("handleListPrivateChannels: %v", err)
+		}
+		list := &protocol.ChannelListMessage{}
+		readFrame(t, conn, protocol.TypePrivateChannelList, list)
+		return list.Channels
+	}
+	join := func(t *testing.T, sess *Session) func() error {
+		return func() error {
+			return srv.handleJoinChannel(sess, encodeAdminFrame(t, protocol.TypeJoinChannel, &protocol.JoinChannelMessage{ChannelID: channelID}))
+		}
+	}
+	post := func(t *testing.T, sess *Session) func() error {
+		return func() error {
+			return srv.handlePostMessage(sess, encodeAdminFrame(t, protocol.TypePostMessage, &protocol.PostMessageMessage{ChannelID: channelID, Content: "hello"}))
+		}
+	}
+
+	t.Run("private channels are not announced or listed", func(t *testing.T) {
+		if hasFrame(memberConn, protocol.TypeChannelCreated) {
+			t.Error("expected no CHANNEL_CREATED broadcast for a private channel")
+		}
+
+		ownerConn.writeBuf.Reset()
+		if err := srv.handleListChannels(owner, encodeAdminFrame(t, protocol.TypeListChannels, &protocol.ListChannelsMessage{})); err != nil {
+			t.Fatalf("handleListChannels: %v", err)
+		}
+		list := &protocol.ChannelListMessage{}
+		readFrame(t, ownerConn, protocol.TypeChannelList, list)
+		for _, ch := range list.Channels {
+			if ch.ID == channelID {
+				t.Error("expected the private channel to be left out of CHANNEL_LIST")
+			}
+		}
+		if count := srv.db.CountChannels(); count != 0 {
+			t.Errorf("expected no public channels to be counted, got %d", count)
+		}
+
+		if channels := privateChannels(t, owner, ownerConn); len(channels) != 1 || channels[0].ID != channelID {
+			t.Errorf("expected the owner's private channel list to hold #team, got %+v", channels)
+		}
+		if channels := privateChannels(t, member, memberConn); len(channels) != 0 {
+			t.Errorf("expected bob to have no private channels, got %+v", channels)
+		}
+	})
+
+	t.Run("non-members are kept out", func(t *testing.T) {
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, join(t, member))
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, post(t, member))
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, func() error {
+			return srv.handleListMessages(member, encodeAdminFrame(t, protocol.TypeListMessages, &protocol.ListMessagesMessage{ChannelID: channelID, Limit: 50}))
+		})
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, func() error {
+			return srv.handleListChannelUsers(member, encodeAdminFrame(t, protocol.TypeListChannelUsers, &protocol.ListChannelUsersMessage{ChannelID: channelID}))
+		})
+
+		if resp := changeMembership(t, member, memberConn, protocol.TypeInviteToChannel, "bob"); resp.Success {
+			t.Error("expected a non-member inviting themselves to fail")
+		}
+	})
+
+	t.Run("invited members can join and post", func(t *testing.T) {
+		memberConn.writeBuf.Reset()
+		resp := changeMembership(t, owner, ownerConn, protocol.TypeInviteToChannel, "bob")
+		if !resp.Success || resp.Message != "bob was added to #team by alice" {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		invited := &protocol.ChannelInvitedMessage{}
+		readFrame(t, memberConn, protocol.TypeChannelInvited, invited)
+		if invited.ChannelID != channelID || invited.InvitedBy != "alice" {
+			t.Errorf("unexpected CHANNEL_INVITED %+v", invited)
+		}
+
+		if resp := changeMembership(t, owner, ownerConn, protocol.TypeInviteToChannel, "bob"); resp.Success {
+			t.Error("expected inviting an existing member to fail")
+		}
+		if resp := changeMembership(t, owner, ownerConn, protocol.TypeInviteToChannel, "nobody"); resp.Success {
+			t.Error("expected inviting an unregistered nickname to fail")
+		}
+
+		memberConn.writeBuf.Reset()
+		if err := join(t, member)(); err != nil {
+			t.Fatalf("handleJoinChannel: %v", err)
+		}
+		joined := &protocol.JoinResponseMessage{}
+		readFrame(t, memberConn, protocol.TypeJoinResponse, joined)
+		if !joined.Success {
+			t.Fatalf("expected bob to join: %+v", joined)
+		}
+
+		memberConn.writeBuf.Reset()
+		if err := post(t, member)(); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+		posted := &protocol.MessagePostedMessage{}
+		readFrame(t, memberConn, protocol.TypeMessagePosted, posted)
+		if !posted.Success {
+			t.Errorf("expected bob to post: %+v", posted)
+		}
+	})
+
+	t.Run("the member list includes members who are away", func(t *testing.T) {
+		if resp := changeMembership(t, owner, ownerConn, protocol.TypeInviteToChannel, "carol"); !resp.Success {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+
+		ownerConn.writeBuf.Reset()
+		if err := srv.handleListChannelUsers(owner, encodeAdminFrame(t, protocol.TypeListChannelUsers, &protocol.ListChannelUsersMessage{ChannelID: channelID})); err != nil {
+			t.Fatalf("handleListChannelUsers: %v", err)
+		}
+		list := &protocol.ChannelUserListMessage{}
+		readFrame(t, ownerConn, protocol.TypeChannelUserList, list)
+
+		sessions := map[string]uint64{}
+		for _, user := range list.Users {
+			sessions[user.Nickname] = user.SessionID
+		}
+		if len(sessions) != 3 {
+			t.Fatalf("expected alice, bob and carol, got %+v", list.Users)
+		}
+		if sessions["bob"] != member.ID {
+			t.Errorf("expected bob to be listed with his session, got %d", sessions["bob"])
+		}
+		if sessions["alice"] != 0 || sessions["carol"] != 0 {
+			t.Errorf("expected members who haven't joined to have session 0, got %+v", sessions)
+		}
+	})
+
+	t.Run("removed members lose access", func(t *testing.T) {
+		memberConn.writeBuf.Reset()
+		if resp := changeMembership(t, owner, ownerConn, protocol.TypeRemoveFromChannel, "bob"); !resp.Success {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		removed := &protocol.RemovedFromChannelMessage{}
+		readFrame(t, memberConn, protocol.TypeRemovedFromChannel, removed)
+		if removed.ChannelID != channelID || removed.Message != "You were removed from #team by alice" {
+			t.Errorf("unexpected REMOVED_FROM_CHANNEL %+v", removed)
+		}
+
+		member.mu.RLock()
+		joined := member.JoinedChannel
+		member.mu.RUnlock()
+		if joined != nil {
+			t.Error("expected bob's session to leave the channel")
+		}
+		expectError(t, memberConn, protocol.ErrCodeChannelPrivate, post(t, member))
+	})
+
+	t.Run("the last member leaving deletes the channel", func(t *testing.T) {
+		if resp := changeMembership(t, owner, ownerConn, protocol.TypeLeavePrivateChannel, ""); resp.Success {
+			t.Error("expected the only owner to be stopped from leaving while carol remains")
+		}
+		if resp := changeMembership(t, owner, ownerConn, protocol.TypeRemoveFromChannel, "carol"); !resp.Success {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		if resp := changeMembership(t, owner, ownerConn, protocol.TypeLeavePrivateChannel, ""); !resp.Success {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		if exists, _ := srv.db.ChannelExists(int64(channelID)); exists {
+			t.Error("expected the empty private channel to be deleted")
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// tokenBucket holds the state for a single rate limit key
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a set of token buckets keyed by session, user or IP.
+// Each bucket holds up to capacity tokens and refills completely over window.
+// A nil *rateLimiter allows everything.
+type rateLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*tokenBucket
+	capacity float64
+	window   time.Duration
+	now      func() time.Time
+}
+
+// newRateLimiter creates a limiter allowing limit events per window.
+// Returns nil (unlimited) when limit is 0.
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	if limit <= 0 || window <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		buckets:  make(map[string]*tokenBucket),
+		capacity: float64(limit),
+		window:   window,
+		now:      time.Now,
+	}
+}
+
+// allow consumes one token from the bucket of every key. If any bucket is
+// empty it consumes nothing and returns false and how long the caller has to
+// wait until all of them hold a token.
+func (rl *rateLimiter) allow(keys ...string) (bool, time.Duration) {
+	if rl == nil {
+		return true, 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	buckets := make([]*tokenBucket, len(keys))
+	var wait time.Duration
+	for i, key := range keys {
+		buckets[i] = rl.refill(key)
+		if buckets[i].tokens < 1 {
+			wait = max(wait, rl.wait(buckets[i]))
+		}
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return true, 0
+}
+
+// blocked reports how long key has to wait for a token, without consuming
+// one. It returns 0 if a token is available.
+func (rl *rateLimiter) blocked(key string) time.Duration {
+	if rl == nil {
+		return 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	bucket := rl.refill(key)
+	if bucket.tokens >= 1 {
+		return 0
+	}
+	return rl.wait(bucket)
+}
+
+// refill returns the bucket for key, topped up for the time elapsed since it
+// was last used. The caller must hold rl.mu.
+func (rl *rateLimiter) refill(key string) *tokenBucket {
+	now := rl.now()
+	bucket, exists := rl.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: rl.capacity, last: now}
+		rl.buckets[key] = bucket
+	}
+
+	// Refill based on elapsed time
+	rate := rl.capacity / rl.window.Seconds() // tokens per second
+	elapsed := now.Sub(bucket.last).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(rl.capacity, bucket.tokens+elapsed*rate)
+		bucket.last = now
+	}
+	return bucket
+}
+
+// wait returns how long until an empty bucket holds a token again
+func (rl *rateLimiter) wait(bucket *tokenBucket) time.Duration {
+	rate := rl.capacity / rl.window.Seconds()
+	return time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+}
+
+// prune drops buckets that have refilled completely, so idle keys don't
+// accumulate forever
+func (rl *rateLimiter) prune() {
+	if rl == nil {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rate := rl.capacity / rl.window.Seconds()
+	for key, bucket := range rl.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*rate >= rl.capacity {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// connectionLimiter caps simultaneous connections per IP address.
+// A nil *connectionLimiter allows everything.
+type connectionLimiter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	max    int
+}
+
+// newConnectionLimiter creates a limiter for max connections per IP.
+// Returns nil (unlimited) when max is 0.
+func newConnectionLimiter(max int) *connectionLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &connectionLimiter{
+		counts: make(map[string]int),
+		max:    max,
+	}
+}
+
+// acquire reserves a connection slot for ip, returning false if the IP is at its limit
+func (cl *connectionLimiter) acquire(ip string) bool {
+	if cl == nil {
+		return true
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.counts[ip] >= cl.max {
+		return false
+	}
+	cl.counts[ip]++
+	return true
+}
+
+// release frees a slot previously reserved with acquire
+func (cl *connectionLimiter) release(ip string) {
+	if cl == nil {
+		return
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.counts[ip] <= 1 {
+		delete(cl.counts, ip)
+		return
+	}
+	cl.counts[ip]--
+}
+
+// remoteIP extracts the host part of a remote address
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// rateLimitKeys lists the buckets a session's actions are charged to: the
+// session's own, its user's, shared across all their sessions, and its IP's,
+// shared by every session from that address so neither reconnecting nor
+// switching accounts resets the limit. An action is allowed only if every
+// bucket has a token.
+func rateLimitKeys(sess *Session) []string {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	keys := []string{fmt.Sprintf("session:%d", sess.ID)}
+	if userID != nil {
+		keys = append(keys, fmt.Sprintf("user:%d", *userID))
+	}
+	if ip := remoteIP(sess.RemoteAddr); ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+// formatRetryAfter renders a wait duration as a whole number of seconds (at least 1)
+func formatRetryAfter(d time.Duration) string {
+	secs := int(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return fmt.Sprintf("retry after %ds", secs)
+}
+
+// pruneRateLimiters drops idle token buckets
+func (s *Server) pruneRateLimiters() {
+	s.messageLimiter.prune()
+	s.channelCreateLimiter.prune()
+	s.totpLimiter.prune()
+}
+
+// checkMessageRateLimit charges a post to the session's buckets and sends
+// ERROR 5001 if the limit is exceeded. Returns false if the post must be rejected.
+func (s *Server) checkMessageRateLimit(sess *Session) (bool, error) {
+	ok, wait := s.messageLimiter.allow(rateLimitKeys(sess)...)
+	if ok {
+		return true, nil
+	}
+
+	if s.metrics != nil {
+		s.metrics.RecordRateLimitRejection("message")
+	}
+	debugLog.Printf("Session %d: message rate limit exceeded", sess.ID)
+	return false, s.sendError(sess, protocol.ErrCodeMessageRateLimit, fmt.Sprintf("Message rate limit exceeded (max %d per minute), %s",
+		s.config.MessageRateLimit, formatRetryAfter(wait)))
+}
+
+// checkChannelCreateRateLimit charges a channel/subchannel creation to the
+// session's buckets and sends ERROR 5000 if the limit is exceeded.
+// Returns false if the creation must be rejected.
+func (s *Server) checkChannelCreateRateLimit(sess *Session) (bool, error) {
+	ok, wait := s.channelCreateLimiter.allow(rateLimitKeys(sess)...)
+	if ok {
+		return true, nil
+	}
+
+	if s.metrics != nil {
+		s.metrics.RecordRateLimitRejection("channel_create")
+	}
+	debugLog.Printf("Session %d: channel creation rate limit exceeded", sess.ID)
+	return false, s.sendError(sess, protocol.ErrCodeRateLimitExceeded, fmt.Sprintf("Channel creation rate limit exceeded (max %d per hour), %s",
+		s.config.MaxChannelCreates, formatRetryAfter(wait)))
+}
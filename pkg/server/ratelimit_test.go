@@ -0,0 +1,321 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newRateLimiter(3, time.Minute)
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.allow("user:1"); !ok {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+
+	ok, wait := rl.allow("user:1")
+	if ok {
+		t.Fatal("4th request should be rejected")
+	}
+	if wait <= 0 || wait > 20*time.Second {
+		t.Errorf("expected retry-after within one refill interval (20s), got %v", wait)
+	}
+
+	// Other keys have their own bucket
+	if ok, _ := rl.allow("user:2"); !ok {
+		t.Error("different key should not be limited")
+	}
+
+	// One token refills every 20s
+	now = now.Add(20 * time.Second)
+	if ok, _ := rl.allow("user:1"); !ok {
+		t.Error("request should be allowed after refill")
+	}
+	if ok, _ := rl.allow("user:1"); ok {
+		t.Error("only one token should have refilled")
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newRateLimiter(2, time.Minute)
+	rl.now = func() time.Time { return now }
+
+	rl.allow("ip:10.0.0.1")
+	rl.allow("ip:10.0.0.2")
+	rl.allow("ip:10.0.0.2")
+
+	now = now.Add(30 * time.Second)
+	rl.prune()
+
+	if _, exists := rl.buckets["ip:10.0.0.1"]; exists {
+		t.Error("refilled bucket should be pruned")
+	}
+	if _, exists := rl.buckets["ip:10.0.0.2"]; !exists {
+		t.Error("partially drained bucket should be kept")
+	}
+}
+
+func TestRateLimiterNilAllowsAll(t *testing.T) {
+	rl := newRateLimiter(0, time.Minute)
+	if rl != nil {
+		t.Fatal("limit 0 should disable the limiter")
+	}
+	for i := 0; i < 100; i++ {
+		if ok, _ := rl.allow("user:1"); !ok {
+			t.Fatal("nil limiter should allow everything")
+		}
+	}
+	rl.prune()
+}
+
+func TestConnectionLimiter(t *testing.T) {
+	cl := newConnectionLimiter(2)
+
+	if !cl.acquire("10.0.0.1") || !cl.acquire("10.0.0.1") {
+		t.Fatal("first two connections should be allowed")
+	}
+	if cl.acquire("10.0.0.1") {
+		t.Error("third connection should be rejected")
+	}
+	if !cl.acquire("10.0.0.2") {
+		t.Error("other IPs should not be affected")
+	}
+
+	cl.release("10.0.0.1")
+	if !cl.acquire("10.0.0.1") {
+		t.Error("connection should be allowed after release")
+	}
+
+	cl.release("10.0.0.2")
+	if _, exists := cl.counts["10.0.0.2"]; exists {
+		t.Error("released IP with no connections should be removed")
+	}
+}
+
+func TestRateLimiterAllowKeys(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newRateLimiter(2, time.Minute)
+	rl.now = func() time.Time { return now }
+
+	// Two accounts on one IP drain the IP's bucket between them
+	if ok, _ := rl.allow("session:1", "user:1", "ip:10.0.0.1"); !ok {
+		t.Fatal("first post should be allowed")
+	}
+	if ok, _ := rl.allow("session:2", "user:2", "ip:10.0.0.1"); !ok {
+		t.Fatal("second post should be allowed")
+	}
+	ok, wait := rl.allow("session:3", "user:3", "ip:10.0.0.1")
+	if ok {
+		t.Fatal("third post from the same IP should be rejected")
+	}
+	if wait <= 0 || wait > 30*time.Second {
+		t.Errorf("expected retry-after within one refill interval (30s), got %v", wait)
+	}
+
+	// A rejected action consumes nothing from the other buckets
+	if bucket := rl.buckets["user:3"]; bucket.tokens != 2 {
+		t.Errorf("user:3 has %v tokens after a rejection, want 2", bucket.tokens)
+	}
+
+	// One user on several IPs is still limited by the user bucket
+	rl.allow("session:4", "user:4", "ip:10.0.0.2")
+	rl.allow("session:5", "user:4", "ip:10.0.0.3")
+	if ok, _ := rl.allow("session:6", "user:4", "ip:10.0.0.4"); ok {
+		t.Error("third post from the same user should be rejected")
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	sess := &Session{ID: 7, RemoteAddr: "192.0.2.1:5555"}
+	if keys := strings.Join(rateLimitKeys(sess), ","); keys != "session:7,ip:192.0.2.1" {
+		t.Errorf("anonymous session keys = %q, want session:7,ip:192.0.2.1", keys)
+	}
+
+	userID := int64(42)
+	sess.UserID = &userID
+	if keys := strings.Join(rateLimitKeys(sess), ","); keys != "session:7,user:42,ip:192.0.2.1" {
+		t.Errorf("registered session keys = %q, want session:7,user:42,ip:192.0.2.1", keys)
+	}
+
+	sess = &Session{ID: 7}
+	if keys := strings.Join(rateLimitKeys(sess), ","); keys != "session:7" {
+		t.Errorf("session without address keys = %q, want session:7", keys)
+	}
+}
+
+func TestHandlePostMessageRateLimited(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	reloadMemDB(t, srv, db)
+
+	srv.config.MessageRateLimit = 2
+	srv.messageLimiter = newRateLimiter(2, time.Minute)
+
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(nil, "", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	srv.sessions.UpdateNickname(sess.ID, "flooder")
+
+	post := func() {
+		frame, err := encodePostMessageMessage(&protocol.PostMessageMessage{
+			ChannelID: uint64(channelID),
+			Content:   "spam",
+		})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := srv.handlePostMessage(sess, frame); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+	}
+
+	post()
+	post()
+	conn.writeBuf.Reset()
+	post()
+
+	frame, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	if frame.Type != protocol.TypeError {
+		t.Fatalf("expected ERROR frame, got 0x%02X", frame.Type)
+	}
+	errMsg := &protocol.ErrorMessage{}
+	if err := errMsg.Decode(frame.Payload); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if errMsg.ErrorCode != protocol.ErrCodeMessageRateLimit {
+		t.Errorf("error code = %d, want %d", errMsg.ErrorCode, protocol.ErrCodeMessageRateLimit)
+	}
+	if !strings.Contains(errMsg.Message, "retry after") {
+		t.Errorf("expected retry-after hint in %q", errMsg.Message)
+	}
+
+	messages, err := srv.db.ListRootMessages(channelID, nil, 10, nil, nil)
+	if err != nil {
+		t.Fatalf("ListRootMessages: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Errorf("expected 2 stored messages, got %d", len(messages))
+	}
+}
+
+func TestHandleConnectionLimit(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	srv.config.MaxConnectionsPerIP = 1
+	srv.connectionLimiter = newConnectionLimiter(1)
+
+	// Occupy the only slot for the mock connection's IP
+	if !srv.connectionLimiter.acquire("127.0.0.1") {
+		t.Fatal("acquire should succeed")
+	}
+
+	conn := newMockConn()
+	srv.handleConnection(conn)
+
+	if len(srv.sessions.GetAllSessions()) != 0 {
+		t.Error("rejected connection should not create a session")
+	}
+
+	frame, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	if frame.Type != protocol.TypeError {
+		t.Fatalf("expected ERROR frame, got 0x%02X", frame.Type)
+	}
+	errMsg := &protocol.ErrorMessage{}
+	if err := errMsg.Decode(frame.Payload); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if errMsg.ErrorCode != protocol.ErrCodeRateLimitExceeded {
+		t.Errorf("error code = %d, want %d", errMsg.ErrorCode, protocol.ErrCodeRateLimitExceeded)
+	}
+}
+
+func TestWebSocketConnectionLimit(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	srv.config.MaxConnectionsPerIP = 1
+	srv.connectionLimiter = newConnectionLimiter(1)
+	if !srv.connectionLimiter.acquire("127.0.0.1") {
+		t.Fatal("acquire should succeed")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", srv.HandleWebSocket)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	client := newWSClient(t, strings.TrimPrefix(httpServer.URL, "http://"))
+	defer client.close()
+
+	frame := client.expect(t, protocol.TypeError, 5*time.Second)
+	errMsg := &protocol.ErrorMessage{}
+	if err := errMsg.Decode(frame.Payload); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if errMsg.ErrorCode != protocol.ErrCodeRateLimitExceeded {
+		t.Errorf("error code = %d, want %d", errMsg.ErrorCode, protocol.ErrCodeRateLimitExceeded)
+	}
+	if len(srv.sessions.GetAllSessions()) != 0 {
+		t.Error("rejected connection should not create a session")
+	}
+}
+
+func TestSSHConnectionLimit(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	srv.connectionLimiter = newConnectionLimiter(1)
+
+	signer := generateTestSSHKey(t)
+	sshConfig := &ssh.ServerConfig{NoClientAuth: true}
+	sshConfig.AddHostKey(signer)
+
+	// Reads the first line the server sends on a fresh connection
+	greeting := func() string {
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		srv.wg.Add(1)
+		go srv.handleSSHConnection(serverConn, sshConfig)
+
+		clientConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		line, _ := bufio.NewReader(clientConn).ReadString('\n')
+		return line
+	}
+
+	// net.Pipe connections all come from "pipe"
+	if !srv.connectionLimiter.acquire("pipe") {
+		t.Fatal("acquire should succeed")
+	}
+	if line := greeting(); line != "" {
+		t.Errorf("connection over the limit got %q, want it closed before the handshake", line)
+	}
+
+	srv.connectionLimiter.release("pipe")
+	if line := greeting(); !strings.HasPrefix(line, "SSH-2.0-") {
+		t.Errorf("connection under the limit got %q, want an SSH version line", line)
+	}
+	srv.wg.Wait()
+}
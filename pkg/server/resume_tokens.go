@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// resumeTokenLifetime is how long a resume token stays valid without being
+// used
+const resumeTokenLifetime = 30 * 24 * time.Hour
+
+// hashResumeToken returns the hash a resume token is stored as
+func hashResumeToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// issueResumeToken makes a new resume token for a session that just logged
+// in with a password and sends it to the client
+func (s *Server) issueResumeToken(sess *Session, userID int64) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInternalError, "Failed to create resume token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	tokenID, err := s.db.CreateResumeToken(userID, hashResumeToken(token), remoteIP(sess.RemoteAddr))
+	if err != nil {
+		return s.dbError(sess, "CreateResumeToken", err)
+	}
+	sess.mu.Lock()
+	sess.ResumeTokenID = tokenID
+	sess.mu.Unlock()
+
+	return s.sendMessage(sess, protocol.TypeResumeToken, &protocol.ResumeTokenMessage{
+		SessionID: uint64(tokenID),
+		Token:     token,
+	})
+}
+
+// handleResumeSession handles RESUME_SESSION, logging in with a resume token
+// instead of a password
+func (s *Server) handleResumeSession(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ResumeSessionMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	invalid := func(reason string) error {
+		log.Printf("Session %d: RESUME_SESSION failed - %s", sess.ID, reason)
+		return s.sendMessage(sess, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{
+			Success: false,
+			Message: "Session expired, please log in again",
+		})
+	}
+
+	token, err := s.db.GetResumeToken(hashResumeToken(msg.Token))
+	if err == sql.ErrNoRows {
+		return invalid("unknown token")
+	}
+	if err != nil {
+		return s.dbError(sess, "GetResumeToken", err)
+	}
+	if time.Since(time.UnixMilli(token.LastUsedAt)) > resumeTokenLifetime {
+		if _, err := s.db.DeleteResumeToken(token.UserID, token.ID); err != nil {
+			log.Printf("Session %d: failed to delete expired resume token %d: %v", sess.ID, token.ID, err)
+		}
+		return invalid("token expired")
+	}
+
+	user, err := s.db.GetUserByID(token.UserID)
+	if err == sql.ErrNoRows {
+		return invalid("user no longer exists")
+	}
+	if err != nil {
+		return s.dbError(sess, "GetUserByID", err)
+	}
+	if user.PasswordHash == "" {
+		return invalid("user requires SSH authentication")
+	}
+	// Tokens from before require_2fa applied to the user don't skip enrolling
+	if challenge, err := s.loginChallenge(user); err != nil {
+		return s.dbError(sess, "loginChallenge", err)
+	} else if challenge == protocol.AuthChallengeTOTPSetup {
+		return invalid("user must enroll two-factor authentication")
+	}
+
+	if err := s.db.TouchResumeToken(token.ID); err != nil {
+		log.Printf("Session %d: failed to update resume token %d: %v", sess.ID, token.ID, err)
+	}
+	log.Printf("Session %d: RESUME_SESSION for user %s (token %d)", sess.ID, user.Nickname, token.ID)
+	return s.completeLogin(sess, user, token.ID, false)
+}
+
+// handleListSessions handles LIST_SESSIONS, listing the user's logins that
+// have a resume token
+func (s *Server) handleListSessions(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	currentTokenID := sess.ResumeTokenID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required")
+	}
+
+	tokens, err := s.db.ListResumeTokens(*userID)
+	if err != nil {
+		return s.dbError(sess, "ListResumeTokens", err)
+	}
+	online := s.resumeTokenSessions(*userID)
+
+	resp := &protocol.SessionListMessage{Sessions: make([]protocol.LoginSessionInfo, 0, len(tokens))}
+	for _, token := range tokens {
+		resp.Sessions = append(resp.Sessions, protocol.LoginSessionInfo{
+			SessionID:  uint64(token.ID),
+			Label:      token.Label,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			Current:    token.ID == currentTokenID,
+			Online:     len(online[token.ID]) > 0,
+		})
+	}
+	return s.sendMessage(sess, protocol.TypeSessionList, resp)
+}
+
+// handleRevokeSession handles REVOKE_SESSION, deleting the resume token of
+// one of the user's other logins and disconnecting any session using it
+func (s *Server) handleRevokeSession(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	currentTokenID := sess.ResumeTokenID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required")
+	}
+
+	msg := &protocol.RevokeSessionMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+	tokenID := int64(msg.SessionID)
+	if tokenID == currentTokenID {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Use LOGOUT to end the current session")
+	}
+
+	revoked, err := s.db.DeleteResumeToken(*userID, tokenID)
+	if err != nil {
+		return s.dbError(sess, "DeleteResumeToken", err)
+	}
+	if !revoked {
+		return s.sendError(sess, protocol.ErrCodeNotFound, "Session not found")
+	}
+
+	if err := s.sendMessage(sess, protocol.TypeSessionRevoked, &protocol.SessionRevokedMessage{SessionID: msg.SessionID}); err != nil {
+		return err
+	}
+
+	reason := "Logged out from another session"
+	for _, other := range s.resumeTokenSessions(*userID)[tokenID] {
+		log.Printf("Disconnecting session %d for revoked resume token %d", other.ID, tokenID)
+		if err := s.sendMessage(other, protocol.TypeDisconnect, &protocol.DisconnectMessage{Reason: &reason}); err != nil {
+			log.Printf("Failed to send DISCONNECT to session %d: %v", other.ID, err)
+		}
+		s.removeSession(other.ID)
+	}
+	return nil
+}
+
+// resumeTokenSessions groups a user's connected sessions by the resume token
+// they logged in with
+func (s *Server) resumeTokenSessions(userID int64) map[int64][]*Session {
+	sessions := make(map[int64][]*Session)
+	for _, other := range s.sessions.GetAllSessions() {
+		other.mu.RLock()
+		if other.UserID != nil && *other.UserID == userID && other.ResumeTokenID != 0 {
+			sessions[other.ResumeTokenID] = append(sessions[other.ResumeTokenID], other)
+		}
+		other.mu.RUnlock()
+	}
+	return sessions
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestResumeSessions(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("client-hash"), bcrypt.MinCost)
+	aliceID, err := srv.db.CreateUser("alice", string(hash), 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	connect := func(t *testing.T) (*Session, *mockConn) {
+		t.Helper()
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(nil, "", "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return sess, conn
+	}
+	handle := func(t *testing.T, sess *Session, msgType uint8, msg protocol.ProtocolMessage, handle func(*Session, *protocol.Frame) error) {
+		t.Helper()
+		if err := handle(sess, encodeAdminFrame(t, msgType, msg)); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+	}
+	// expect decodes the next frame written to a connection as msg
+	expect := func(t *testing.T, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		frame, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		if frame.Type != msgType {
+			t.Fatalf("expected 0x%02X, got 0x%02X", msgType, frame.Type)
+		}
+		if err := msg.Decode(frame.Payload); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+	}
+	login := func(t *testing.T) (*Session, *mockConn, *protocol.ResumeTokenMessage) {
+		t.Helper()
+		sess, conn := connect(t)
+		handle(t, sess, protocol.TypeAuthRequest, &protocol.AuthRequestMessage{Nickname: "alice", Password: "client-hash", RequestToken: true}, srv.handleAuthRequest)
+		auth := &protocol.AuthResponseMessage{}
+		if expect(t, conn, protocol.TypeAuthResponse, auth); !auth.Success {
+			t.Fatalf("expected to log in, got %q", auth.Message)
+		}
+		token := &protocol.ResumeTokenMessage{}
+		expect(t, conn, protocol.TypeResumeToken, token)
+		return sess, conn, token
+	}
+	resume := func(t *testing.T, token string) (*Session, *mockConn, bool) {
+		t.Helper()
+		sess, conn := connect(t)
+		handle(t, sess, protocol.TypeResumeSession, &protocol.ResumeSessionMessage{Token: token}, srv.handleResumeSession)
+		auth := &protocol.AuthResponseMessage{}
+		expect(t, conn, protocol.TypeAuthResponse, auth)
+		return sess, conn, auth.Success
+	}
+
+	// A password login that asks for a token gets one, and a new connection
+	// can log in with it instead of the password
+	work, workConn, workToken := login(t)
+	home, _, homeToken := login(t)
+	if workToken.Token == homeToken.Token || workToken.SessionID == homeToken.SessionID {
+		t.Fatal("expected each login to get its own token")
+	}
+	resumed, _, ok := resume(t, workToken.Token)
+	if !ok || resumed.UserID == nil || *resumed.UserID != aliceID {
+		t.Fatal("expected to resume the work login")
+	}
+	if _, _, ok := resume(t, "not-a-token"); ok {
+		t.Error("expected an unknown token to be rejected")
+	}
+
+	// Both logins are listed, with the one asking marked
+	workConn.writeBuf.Reset()
+	handle(t, work, protocol.TypeListSessions, &protocol.ListSessionsMessage{}, srv.handleListSessions)
+	list := &protocol.SessionListMessage{}
+	expect(t, workConn, protocol.TypeSessionList, list)
+	if len(list.Sessions) != 2 {
+		t.Fatalf("expected two logins, got %+v", list.Sessions)
+	}
+	for _, session := range list.Sessions {
+		if session.Current != (session.SessionID == workToken.SessionID) || !session.Online {
+			t.Errorf("unexpected login %+v", session)
+		}
+	}
+
+	// The current login can't be revoked, another one can, and its
+	// connection is closed and its token stops working
+	handle(t, work, protocol.TypeRevokeSession, &protocol.RevokeSessionMessage{SessionID: workToken.SessionID}, srv.handleRevokeSession)
+	errMsg := &protocol.ErrorMessage{}
+	if expect(t, workConn, protocol.TypeError, errMsg); errMsg.ErrorCode != protocol.ErrCodeInvalidInput {
+		t.Errorf("expected an invalid input error, got %d", errMsg.ErrorCode)
+	}
+	handle(t, work, protocol.TypeRevokeSession, &protocol.RevokeSessionMessage{SessionID: homeToken.SessionID}, srv.handleRevokeSession)
+	revoked := &protocol.SessionRevokedMessage{}
+	if expect(t, workConn, protocol.TypeSessionRevoked, revoked); revoked.SessionID != homeToken.SessionID {
+		t.Errorf("expected SESSION_REVOKED for the home login, got %d", revoked.SessionID)
+	}
+	if _, ok := srv.sessions.GetSession(home.ID); ok {
+		t.Error("expected the home session to be disconnected")
+	}
+	if _, _, ok := resume(t, homeToken.Token); ok {
+		t.Error("expected the revoked token to be rejected")
+	}
+
+	// Logging out ends the login for good
+	handle(t, resumed, protocol.TypeLogout, &protocol.LogoutMessage{}, srv.handleLogout)
+	if _, _, ok := resume(t, workToken.Token); ok {
+		t.Error("expected the logged out token to be rejected")
+	}
+}
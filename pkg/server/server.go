@@ -38,9 +38,11 @@ var (
 
 // Server represents the SuperChat server
 type Server struct {
-	db          *database.MemDB
+	db          database.Store
+	dbLock      *os.File // Lock on the SQLite database, so offline tools don't write behind our back (nil with PostgreSQL)
 	listener    net.Listener
 	sshListener net.Listener
+	tlsListener net.Listener
 	sessions    *SessionManager
 	config      ServerConfig
 	configPath  string
@@ -48,6 +50,7 @@ type Server struct {
 	wg          sync.WaitGroup
 	metrics     *Metrics
 	startTime   time.Time // Server start time for uptime calculation
+	version     string    // Shown in the header of the SSH TUI
 
 	// Connection deltas for periodic reporting
 	connectionsSinceReport    atomic.Int64
@@ -60,6 +63,15 @@ type Server struct {
 	discoveryRateLimitMu   sync.Mutex
 	autoRegisterMu         sync.Mutex
 	autoRegisterAttempts   map[string][]time.Time
+
+	// Abuse limits (nil = unlimited)
+	messageLimiter       *rateLimiter
+	channelCreateLimiter *rateLimiter
+	totpLimiter          *rateLimiter // Invalid two-factor codes per user
+	connectionLimiter    *connectionLimiter
+
+	// Outgoing webhooks (nil = none configured)
+	webhooks *webhookDispatcher
 }
 
 // ServerConfig holds server configuration
@@ -68,6 +80,10 @@ type ServerConfig struct {
 	SSHPort                 int
 	HTTPPort                int // Public HTTP port for /servers.json (default: 8080, 0 = disabled)
 	SSHHostKeyPath          string
+	TLSPort                 int    // TLS port for the binary protocol (0 = disabled)
+	TLSCertPath             string // PEM certificate chain (TLS disabled if empty)
+	TLSKeyPath              string // PEM private key
+	TLSClientCAPath         string // Optional CA bundle for client certificate auth
 	MaxConnectionsPerIP     uint8
 	MessageRateLimit        uint16
 	MaxChannelCreates       uint16
@@ -88,6 +104,14 @@ type ServerConfig struct {
 	// Admin configuration
 	AdminUsers    []string // List of admin user nicknames
 	AdminPassword string   // If set, reset the first admin user's password on boot
+	Require2FA    []string // Accounts that must use two-factor authentication ("admin", "moderator")
+
+	// Storage
+	DatabaseURL string // PostgreSQL connection URL (empty = SQLite at the database path)
+	WorkerID    *int64 // ID generator worker ID, unique per server sharing a PostgreSQL database (required with DatabaseURL)
+
+	// Outgoing webhooks
+	Webhooks []WebhookConfig
 }
 
 // DefaultConfig returns default server configuration
@@ -97,6 +121,7 @@ func DefaultConfig() ServerConfig {
 		SSHPort:                 6466,
 		HTTPPort:                8080, // Public HTTP server for /servers.json
 		SSHHostKeyPath:          "~/.superchat/ssh_host_key",
+		TLSPort:                 6467, // Only used when TLSCertPath/TLSKeyPath are set
 		MaxConnectionsPerIP:     10,
 		MessageRateLimit:        10,   // per minute
 		MaxChannelCreates:       5,    // per hour
@@ -118,28 +143,36 @@ func DefaultConfig() ServerConfig {
 
 // NewServer creates a new server instance
 func NewServer(dbPath string, config ServerConfig, configPath string) (*Server, error) {
-	// Open underlying SQLite database for snapshots
-	sqliteDB, err := database.Open(dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	if err := validateWebhooks(config.Webhooks); err != nil {
+		return nil, err
+	}
+	if err := validateRequire2FA(config.Require2FA); err != nil {
+		return nil, err
+	}
+	if err := validateWorkerID(config.DatabaseURL, config.WorkerID); err != nil {
+		return nil, err
 	}
 
-	// Seed default channels if they don't exist
-	if err := sqliteDB.SeedDefaultChannels(); err != nil {
-		sqliteDB.Close()
-		return nil, fmt.Errorf("failed to seed channels: %w", err)
+	// A running server holds the SQLite database's lock, so `scd` won't
+	// change it behind the in-memory cache's back
+	var dbLock *os.File
+	if config.DatabaseURL == "" {
+		lock, err := database.LockDatabase(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %s: %w", dbPath, err)
+		}
+		dbLock = lock
 	}
 
-	// Create in-memory database with 30-second snapshot interval
-	memDB, err := database.NewMemDB(sqliteDB, 30*time.Second)
+	db, err := openStore(dbPath, config.DatabaseURL, config.WorkerID)
 	if err != nil {
-		sqliteDB.Close()
-		return nil, fmt.Errorf("failed to create in-memory database: %w", err)
+		closeLock(dbLock)
+		return nil, err
 	}
 
 	// Reset first admin user's password if configured
 	if config.AdminPassword != "" && len(config.AdminUsers) > 0 {
-		if err := resetAdminPassword(sqliteDB, config.AdminUsers[0], config.AdminPassword); err != nil {
+		if err := ResetUserPassword(db, config.AdminUsers[0], config.AdminPassword); err != nil {
 			log.Printf("Warning: failed to reset admin password for %s: %v", config.AdminUsers[0], err)
 		} else {
 			log.Printf("Reset password for admin user %s", config.AdminUsers[0])
@@ -148,31 +181,93 @@ func NewServer(dbPath string, config ServerConfig, configPath string) (*Server,
 
 	// Initialize loggers
 	if err := initLoggers(); err != nil {
-		memDB.Close()
-		sqliteDB.Close()
+		db.Close()
+		closeLock(dbLock)
 		return nil, fmt.Errorf("failed to initialize loggers: %w", err)
 	}
 
 	metrics := NewMetrics()
-	sessions := NewSessionManager(memDB, config.SessionTimeoutSeconds)
+	sessions := NewSessionManager(db, config.SessionTimeoutSeconds)
 	sessions.SetMetrics(metrics)
 
 	server := &Server{
-		db:                     memDB,
+		db:                     db,
+		dbLock:                 dbLock,
 		sessions:               sessions,
 		config:                 config,
 		configPath:             configPath,
 		shutdown:               make(chan struct{}),
 		metrics:                metrics,
 		startTime:              time.Now(),
+		version:                "dev",
 		verificationChallenges: make(map[uint64]uint64),
 		discoveryRateLimits:    make(map[string]*discoveryRateLimiter),
 		autoRegisterAttempts:   make(map[string][]time.Time),
+		messageLimiter:         newRateLimiter(int(config.MessageRateLimit), time.Minute),
+		channelCreateLimiter:   newRateLimiter(int(config.MaxChannelCreates), time.Hour),
+		totpLimiter:            newRateLimiter(totpLockoutAttempts, totpLockoutWindow),
+		connectionLimiter:      newConnectionLimiter(int(config.MaxConnectionsPerIP)),
+		webhooks:               newWebhookDispatcher(db, config.Webhooks),
 	}
 
 	return server, nil
 }
 
+// openStore opens the server's storage backend. With a PostgreSQL URL the
+// server talks to PostgreSQL directly; otherwise it uses the SQLite file at
+// dbPath behind the in-memory MemDB.
+func openStore(dbPath, databaseURL string, workerID *int64) (database.Store, error) {
+	if databaseURL != "" {
+		pgDB, err := database.OpenPostgres(databaseURL, *workerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
+		}
+		if err := pgDB.SeedDefaultChannels(); err != nil {
+			pgDB.Close()
+			return nil, fmt.Errorf("failed to seed channels: %w", err)
+		}
+		return pgDB, nil
+	}
+
+	// Open underlying SQLite database for snapshots
+	sqliteDB, err := database.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Seed default channels if they don't exist
+	if err := sqliteDB.SeedDefaultChannels(); err != nil {
+		sqliteDB.Close()
+		return nil, fmt.Errorf("failed to seed channels: %w", err)
+	}
+
+	// Create in-memory database with 30-second snapshot interval
+	memDB, err := database.NewMemDB(sqliteDB, 30*time.Second)
+	if err != nil {
+		sqliteDB.Close()
+		return nil, fmt.Errorf("failed to create in-memory database: %w", err)
+	}
+	return memDB, nil
+}
+
+// validateWorkerID checks the worker_id setting at startup. Several servers
+// can share a PostgreSQL database, and each needs its own worker ID so they
+// don't generate the same message and session IDs. A SQLite database has a
+// single server, which uses worker 0.
+func validateWorkerID(databaseURL string, workerID *int64) error {
+	if databaseURL != "" && workerID == nil {
+		return fmt.Errorf("worker_id must be set when database_url is set (0-%d, different for every server sharing the database)", database.MaxWorkerID)
+	}
+	return nil
+}
+
+// closeLock releases the database lock, if the server holds one
+func closeLock(lock *os.File) {
+	if lock != nil {
+		lock.Close()
+	}
+}
+
 // getServerDataDir returns the server data directory, creating it if needed
 func getServerDataDir() (string, error) {
 	var dataDir string
@@ -232,6 +327,11 @@ func initLoggers() error {
 	return nil
 }
 
+// SetVersion sets the version shown to users of the SSH TUI
+func (s *Server) SetVersion(version string) {
+	s.version = version
+}
+
 // EnableDebugLogging enables debug logging to debug.log
 func (s *Server) EnableDebugLogging() {
 	// Get server data directory
@@ -293,6 +393,15 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to start SSH server: %w", err)
 	}
 
+	// Start TLS server
+	if err := s.startTLSServer(); err != nil {
+		s.listener.Close()
+		if s.sshListener != nil {
+			s.sshListener.Close()
+		}
+		return fmt.Errorf("failed to start TLS server: %w", err)
+	}
+
 	// Start metrics HTTP server (internal only - never expose publicly!)
 	go func() {
 		metricsMux := http.NewServeMux()
@@ -304,7 +413,7 @@ func (s *Server) Start() error {
 		}
 	}()
 
-	// Start public HTTP server for /servers.json and WebSocket (safe to expose publicly)
+	// Start public HTTP server for /servers.json, WebSocket and incoming webhooks (safe to expose publicly)
 	if s.config.HTTPPort > 0 {
 		go func() {
 			publicMux := http.NewServeMux()
@@ -312,11 +421,12 @@ func (s *Server) Start() error {
 				publicMux.HandleFunc("/servers.json", s.ServersJSONHandler)
 			}
 			publicMux.HandleFunc("/ws", s.HandleWebSocket)
+			publicMux.HandleFunc("POST /hooks/{token}", s.IncomingWebhookHandler)
 			addr := fmt.Sprintf(":%d", s.config.HTTPPort)
 
-			endpoints := "/ws"
+			endpoints := "/ws, /hooks"
 			if s.config.DirectoryEnabled {
-				endpoints = "/servers.json, /ws"
+				endpoints = "/servers.json, /ws, /hooks"
 			}
 			log.Printf("Public HTTP server listening on %s (%s)", addr, endpoints)
 
@@ -338,6 +448,15 @@ func (s *Server) Start() error {
 	s.wg.Add(1)
 	go s.retentionCleanupLoop()
 
+	// Start webhook delivery worker
+	if s.webhooks != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.webhooks.run(s.shutdown)
+		}()
+	}
+
 	// Start directory health checks (only when running as directory)
 	if s.config.DirectoryEnabled {
 		s.wg.Add(1)
@@ -376,6 +495,12 @@ func (s *Server) Stop() error {
 		log.Println("SSH listener closed")
 	}
 
+	if s.tlsListener != nil {
+		s.tlsListener.Close()
+		s.tlsListener = nil
+		log.Println("TLS listener closed")
+	}
+
 	// Notify all connected clients before closing connections
 	log.Println("Notifying connected clients of shutdown...")
 	s.notifyClientsOfShutdown()
@@ -390,7 +515,9 @@ func (s *Server) Stop() error {
 
 	// Close in-memory database (triggers final snapshot to SQLite)
 	log.Println("Flushing in-memory database to disk...")
-	if err := s.db.Close(); err != nil {
+	err := s.db.Close()
+	closeLock(s.dbLock)
+	if err != nil {
 		log.Printf("Error during database close: %v", err)
 		return err
 	}
@@ -462,19 +589,39 @@ func (s *Server) acceptLoop() {
 
 // handleConnection handles initial connection setup, then spawns message loop goroutine
 func (s *Server) handleConnection(conn net.Conn) {
-	startTime := time.Now()
-
 	// Disable Nagle's algorithm for immediate sends
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
 		tcpConn.SetNoDelay(true)
 	}
 
-	afterTCP := time.Now()
+	// Enforce per-IP connection limit before allocating a session
+	ip := remoteIP(conn.RemoteAddr().String())
+	if !s.connectionLimiter.acquire(ip) {
+		s.rejectConnection(conn, ip)
+		return
+	}
+
+	s.setupConnection(conn, "tcp", ip, nil, nil)
+}
+
+// setupConnection creates a session for a plain or TLS connection, sends
+// SERVER_CONFIG, then spawns the message loop. If user is set (client
+// certificate auth), the session starts authenticated. The caller holds a
+// per-IP connection slot for ip, which is released when the connection ends.
+func (s *Server) setupConnection(conn net.Conn, connType, ip string, user *database.User, ban *database.Ban) {
+	startTime := time.Now()
 
 	// Create session
-	sess, err := s.sessions.CreateSession(nil, "", "tcp", conn)
+	var userID *int64
+	var nickname string
+	if user != nil {
+		userID = &user.ID
+		nickname = user.Nickname
+	}
+	sess, err := s.sessions.CreateSession(userID, nickname, connType, conn)
 	if err != nil {
 		log.Printf("Failed to create session: %v", err)
+		s.connectionLimiter.release(ip)
 		conn.Close()
 		return
 	}
@@ -483,12 +630,13 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 	// Track connection for periodic metrics
 	s.connectionsSinceReport.Add(1)
-	debugLog.Printf("New connection from %s (session %d)", conn.RemoteAddr(), sess.ID)
+	debugLog.Printf("New %s connection from %s (session %d)", connType, conn.RemoteAddr(), sess.ID)
 
 	// Send SERVER_CONFIG immediately after connection
 	if err := s.sendServerConfig(sess); err != nil {
 		// Debug log already shows the send attempt, clean up and return
 		s.removeSession(sess.ID)
+		s.connectionLimiter.release(ip)
 		conn.Close()
 		return
 	}
@@ -498,16 +646,63 @@ func (s *Server) handleConnection(conn net.Conn) {
 	// Log timing if it took more than 100ms
 	totalTime := afterServerConfig.Sub(startTime)
 	if totalTime > 100*time.Millisecond {
-		debugLog.Printf("Session %d: SLOW connection setup: total=%v (tcp=%v, createSess=%v, sendConfig=%v)",
+		debugLog.Printf("Session %d: SLOW connection setup: total=%v (createSess=%v, sendConfig=%v)",
 			sess.ID,
 			totalTime,
-			afterTCP.Sub(startTime),
-			afterCreateSession.Sub(afterTCP),
+			afterCreateSession.Sub(startTime),
 			afterServerConfig.Sub(afterCreateSession))
 	}
 
+	// Client certificate users are logged in immediately (like SSH key users)
+	if user != nil {
+		if err := s.sendCertificateAuthResponse(sess, user, ban); err != nil {
+			s.removeSession(sess.ID)
+			s.connectionLimiter.release(ip)
+			conn.Close()
+			return
+		}
+	}
+
 	// Spawn goroutine for message loop (worker returns to pool)
-	go s.messageLoop(sess, conn)
+	go func() {
+		defer s.connectionLimiter.release(ip)
+		s.messageLoop(sess, conn)
+	}()
+}
+
+// rejectConnection tells a client it has too many open connections and closes it
+func (s *Server) rejectConnection(conn net.Conn, ip string) {
+	defer conn.Close()
+
+	s.recordConnectionRejection(ip)
+
+	msg := &protocol.ErrorMessage{
+		ErrorCode: protocol.ErrCodeRateLimitExceeded,
+		Message: fmt.Sprintf("Too many connections from your IP (max %d), retry after closing an existing connection",
+			s.config.MaxConnectionsPerIP),
+	}
+	payload, err := msg.Encode()
+	if err != nil {
+		return
+	}
+
+	frame := &protocol.Frame{
+		Version: protocol.ProtocolVersion,
+		Type:    protocol.TypeError,
+		Flags:   0,
+		Payload: payload,
+	}
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_ = protocol.EncodeFrame(conn, frame, protocol.ProtocolVersion)
+}
+
+// recordConnectionRejection logs and counts a connection refused by the
+// per-IP limit
+func (s *Server) recordConnectionRejection(ip string) {
+	if s.metrics != nil {
+		s.metrics.RecordRateLimitRejection("connection")
+	}
+	debugLog.Printf("Rejecting connection from %s: too many connections", ip)
 }
 
 // messageLoop handles messages for an established connection
@@ -587,12 +782,22 @@ func (s *Server) handleMessage(sess *Session, frame *protocol.Frame) error {
 		return s.handleLeaveChannel(sess, frame)
 	case protocol.TypeCreateChannel:
 		return s.handleCreateChannel(sess, frame)
+	case protocol.TypeUpdateChannel:
+		return s.handleUpdateChannel(sess, frame)
 	case protocol.TypeCreateSubchannel:
 		return s.handleCreateSubchannel(sess, frame)
 	case protocol.TypeGetSubchannels:
 		return s.handleGetSubchannels(sess, frame)
 	case protocol.TypeListMessages:
 		return s.handleListMessages(sess, frame)
+	case protocol.TypeSearchMessages:
+		return s.handleSearchMessages(sess, frame)
+	case protocol.TypePlusOne:
+		return s.handlePlusOne(sess, frame)
+	case protocol.TypeListMentions:
+		return s.handleListMentions(sess, frame)
+	case protocol.TypeGetMessageHistory:
+		return s.handleGetMessageHistory(sess, frame)
 	case protocol.TypePostMessage:
 		return s.handlePostMessage(sess, frame)
 	case protocol.TypeEditMessage:
@@ -655,6 +860,58 @@ func (s *Server) handleMessage(sess *Session, frame *protocol.Frame) error {
 		return s.handleDeleteUser(sess, frame)
 	case protocol.TypeDeleteChannel:
 		return s.handleDeleteChannel(sess, frame)
+	case protocol.TypeCreateIncomingWebhook:
+		return s.handleCreateIncomingWebhook(sess, frame)
+	case protocol.TypeListIncomingWebhooks:
+		return s.handleListIncomingWebhooks(sess, frame)
+	case protocol.TypeRevokeIncomingWebhook:
+		return s.handleRevokeIncomingWebhook(sess, frame)
+	case protocol.TypeListAdminActions:
+		return s.handleListAdminActions(sess, frame)
+	case protocol.TypeKickUser:
+		return s.handleKickUser(sess, frame)
+	case protocol.TypeMuteUser:
+		return s.handleMuteUser(sess, frame)
+	case protocol.TypeUnmuteUser:
+		return s.handleUnmuteUser(sess, frame)
+	case protocol.TypeSetChannelRole:
+		return s.handleSetChannelRole(sess, frame)
+	case protocol.TypeListChannelRoles:
+		return s.handleListChannelRoles(sess, frame)
+	case protocol.TypeSetChannelGrant:
+		return s.handleSetChannelGrant(sess, frame)
+	case protocol.TypeInviteToChannel:
+		return s.handleInviteToChannel(sess, frame)
+	case protocol.TypeRemoveFromChannel:
+		return s.handleRemoveFromChannel(sess, frame)
+	case protocol.TypeLeavePrivateChannel:
+		return s.handleLeavePrivateChannel(sess, frame)
+	case protocol.TypeListPrivateChannels:
+		return s.handleListPrivateChannels(sess, frame)
+	case protocol.TypeDistributeChannelKey:
+		return s.handleDistributeChannelKey(sess, frame)
+	case protocol.TypeGetChannelKeys:
+		return s.handleGetChannelKeys(sess, frame)
+	case protocol.TypeStoreKeyBackup:
+		return s.handleStoreKeyBackup(sess, frame)
+	case protocol.TypeGetKeyBackup:
+		return s.handleGetKeyBackup(sess, frame)
+	case protocol.TypeRevokeDevice:
+		return s.handleRevokeDevice(sess, frame)
+	case protocol.TypeResumeSession:
+		return s.handleResumeSession(sess, frame)
+	case protocol.TypeListSessions:
+		return s.handleListSessions(sess, frame)
+	case protocol.TypeRevokeSession:
+		return s.handleRevokeSession(sess, frame)
+	case protocol.TypeAuthTOTP:
+		return s.handleAuthTOTP(sess, frame)
+	case protocol.TypeSetupTOTP:
+		return s.handleSetupTOTP(sess, frame)
+	case protocol.TypeConfirmTOTP:
+		return s.handleConfirmTOTP(sess, frame)
+	case protocol.TypeDisableTOTP:
+		return s.handleDisableTOTP(sess, frame)
 
 	// V3 DM messages
 	case protocol.TypeStartDM:
@@ -758,10 +1015,11 @@ func (s *Server) sendError(sess *Session, code uint16, message string) error {
 	return sess.Conn.EncodeFrame(frame, sess.GetProtocolVersion())
 }
 
-// resetAdminPassword resets an admin user's password during server startup.
-// Replicates the client-side argon2id hash (nickname as salt) then bcrypts it,
-// matching the normal registration flow.
-func resetAdminPassword(db *database.DB, nickname, plaintext string) error {
+// ResetUserPassword sets a user's password, as the admin_password setting does
+// at startup and `scd users reset-password` does offline. Replicates the
+// client-side argon2id hash (nickname as salt) then bcrypts it, matching the
+// normal registration flow.
+func ResetUserPassword(db database.Store, nickname, plaintext string) error {
 	user, err := db.GetUserByNickname(nickname)
 	if err != nil {
 		return fmt.Errorf("user %q not found: %w", nickname, err)
@@ -837,6 +1095,7 @@ func (s *Server) sessionCleanupLoop() {
 			return
 		case <-ticker.C:
 			s.cleanupStaleSessions()
+			s.pruneRateLimiters()
 		}
 	}
 }
@@ -905,6 +1164,17 @@ func (s *Server) cleanupExpiredMessages() {
 	if sessionCount > 0 {
 		log.Printf("Cleaned up %d idle database sessions", sessionCount)
 	}
+
+	// And resume tokens nobody has used in a while
+	tokenCount, err := s.db.CleanupExpiredResumeTokens(time.Now().Add(-resumeTokenLifetime).UnixMilli())
+	if err != nil {
+		log.Printf("Error cleaning up expired resume tokens: %v", err)
+		return
+	}
+
+	if tokenCount > 0 {
+		log.Printf("Cleaned up %d expired resume tokens", tokenCount)
+	}
 }
 
 // directoryHealthCheckLoop periodically verifies registered servers are reachable.
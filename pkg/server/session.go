@@ -37,6 +37,13 @@ type Session struct {
 
 	// V3 DM encryption (for anonymous users with ephemeral keys)
 	EncryptionPublicKey []byte // X25519 public key (32 bytes, session-only for anonymous)
+
+	// V4 resume tokens
+	ResumeTokenID int64 // Resume token the session logged in with or was given, 0 if none (protected by mu)
+
+	// V4 two-factor authentication (protected by mu)
+	pendingLogin      *pendingLogin // Password login waiting for its two-factor step
+	pendingTOTPSecret []byte        // Secret from SETUP_TOTP waiting for CONFIRM_TOTP
 }
 
 // GetProtocolVersion returns the session's protocol version atomically.
@@ -51,7 +58,7 @@ func (s *Session) SetProtocolVersion(v uint8) {
 
 // SessionManager manages all active sessions
 type SessionManager struct {
-	db                       *database.MemDB
+	db                       database.Store
 	sessions                 map[uint64]*Session
 	nextID                   uint64
 	mu                       sync.RWMutex
@@ -65,7 +72,7 @@ type SessionManager struct {
 }
 
 // NewSessionManager creates a new session manager
-func NewSessionManager(db *database.MemDB, sessionTimeoutSeconds int) *SessionManager {
+func NewSessionManager(db database.Store, sessionTimeoutSeconds int) *SessionManager {
 	// Activity update interval is half the session timeout
 	activityIntervalMs := int64(sessionTimeoutSeconds) * 500 // half in milliseconds
 
@@ -350,6 +357,32 @@ func (sm *SessionManager) UnsubscribeFromChannel(sess *Session, channelSub Chann
 	sm.subIndexMu.Unlock()
 }
 
+// UnsubscribeFromChannelEverywhere removes the session's subscriptions to a
+// channel, its subchannels and its threads
+func (sm *SessionManager) UnsubscribeFromChannelEverywhere(sess *Session, channelID uint64) {
+	var channelSubs []ChannelSubscription
+	var threadIDs []uint64
+	sess.subMu.RLock()
+	for channelSub := range sess.subscribedChannels {
+		if channelSub.ChannelID == channelID {
+			channelSubs = append(channelSubs, channelSub)
+		}
+	}
+	for threadID, channelSub := range sess.subscribedThreads {
+		if channelSub.ChannelID == channelID {
+			threadIDs = append(threadIDs, threadID)
+		}
+	}
+	sess.subMu.RUnlock()
+
+	for _, channelSub := range channelSubs {
+		sm.UnsubscribeFromChannel(sess, channelSub)
+	}
+	for _, threadID := range threadIDs {
+		sm.UnsubscribeFromThread(sess, threadID)
+	}
+}
+
 // GetThreadSubscribers returns all sessions subscribed to a thread (optimized via reverse index)
 func (sm *SessionManager) GetThreadSubscribers(threadID uint64) []*Session {
 	sm.subIndexMu.RLock()
@@ -93,6 +93,16 @@ func (s *Server) handleSSHConnection(conn net.Conn, config *ssh.ServerConfig) {
 	defer s.wg.Done()
 	defer conn.Close()
 
+	// Enforce per-IP connection limit before the handshake. There is no
+	// protocol to send an ERROR frame over yet, so the client just sees the
+	// connection close.
+	ip := remoteIP(conn.RemoteAddr().String())
+	if !s.connectionLimiter.acquire(ip) {
+		s.recordConnectionRejection(ip)
+		return
+	}
+	defer s.connectionLimiter.release(ip)
+
 	// Perform SSH handshake
 	sshConn, chans, reqs, err := ssh.NewServerConn(conn, config)
 	if err != nil {
@@ -118,38 +128,93 @@ func (s *Server) handleSSHConnection(conn net.Conn, config *ssh.ServerConfig) {
 			continue
 		}
 
-		// Handle the session in the existing handler
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			go s.handleSSHChannelRequests(requests)
 			// Pass SSH permissions (contains authenticated user info)
-			s.handleSSHSession(channel, sshConn.Permissions)
+			s.handleSSHChannel(channel, requests, sshConn.Permissions)
 		}()
 	}
 }
 
-func (s *Server) handleSSHChannelRequests(requests <-chan *ssh.Request) {
+// handleSSHChannel decides what a session channel is used for. Clients that
+// request a PTY (plain `ssh`) get the TUI, everything else speaks the binary
+// protocol.
+func (s *Server) handleSSHChannel(channel ssh.Channel, requests <-chan *ssh.Request, permissions *ssh.Permissions) {
+	start := make(chan *sshPty, 1)
+	resize := make(chan sshWindowSize, 1)
+	go s.handleSSHChannelRequests(requests, start, resize)
+
+	var pty *sshPty
+	select {
+	case pty = <-start:
+	case <-time.After(sshModeTimeout):
+		// Older clients open the channel without sending any requests
+	}
+
+	if pty != nil {
+		s.handleSSHTerminal(channel, permissions, pty, resize)
+		return
+	}
+	s.handleSSHSession(&sshChannelConn{channel: channel}, permissions)
+}
+
+// handleSSHChannelRequests answers channel requests. The first shell or
+// superchat subsystem request is reported on start, along with the PTY if one
+// was requested before it. Terminal size changes are reported on resize, which
+// only ever holds the latest size.
+func (s *Server) handleSSHChannelRequests(requests <-chan *ssh.Request, start chan<- *sshPty, resize chan sshWindowSize) {
+	var pty *sshPty
+	started := false
+
 	for req := range requests {
+		ok := true
 		switch req.Type {
-		case "shell", "pty-req", "env", "window-change":
-			if req.WantReply {
-				req.Reply(true, nil)
+		case "pty-req":
+			p, err := parsePtyRequest(req.Payload)
+			if err != nil {
+				ok = false
+				break
 			}
-		default:
-			if req.WantReply {
-				req.Reply(false, nil)
+			pty = p
+		case "shell":
+			if !started {
+				started = true
+				start <- pty
+			}
+		case "subsystem":
+			var sub struct{ Name string }
+			ok = ssh.Unmarshal(req.Payload, &sub) == nil && sub.Name == sshSubsystemName
+			if ok && !started {
+				started = true
+				start <- nil
+			}
+		case "window-change":
+			size, err := parseWindowChange(req.Payload)
+			if err != nil {
+				ok = false
+				break
+			}
+			select {
+			case <-resize:
+			default:
 			}
+			resize <- size
+		case "env":
+		default:
+			ok = false
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
 		}
 	}
 }
 
-// handleSSHSession wraps an SSH channel and uses the existing protocol handler
-func (s *Server) handleSSHSession(channel ssh.Channel, permissions *ssh.Permissions) {
-	defer channel.Close()
-
-	// Wrap the SSH channel as a net.Conn-like interface
-	conn := &sshChannelConn{channel: channel}
+// handleSSHSession runs the binary protocol for an SSH-authenticated user. conn
+// is either the SSH channel itself or, for terminal users, the server end of
+// the pipe the TUI talks through.
+func (s *Server) handleSSHSession(conn net.Conn, permissions *ssh.Permissions) {
+	defer conn.Close()
 
 	// Extract authenticated user info from SSH permissions (V2 feature)
 	var userID *int64
@@ -127,6 +127,13 @@ func openSSHSession(t *testing.T, client *ssh.Client) (ssh.Channel, <-chan *ssh.
 		return nil, nil, fmt.Errorf("failed to open session channel: %w", err)
 	}
 
+	// Ask for the binary protocol like the client does
+	subsystem := ssh.Marshal(struct{ Name string }{sshSubsystemName})
+	if _, err := channel.SendRequest("subsystem", false, subsystem); err != nil {
+		channel.Close()
+		return nil, nil, fmt.Errorf("failed to request subsystem: %w", err)
+	}
+
 	return channel, requests, nil
 }
 
@@ -635,6 +642,124 @@ func TestSSHPingMessage(t *testing.T) {
 	}
 }
 
+// TestSSHSessionWithoutRequests verifies older clients that send no channel
+// requests still get the binary protocol
+func TestSSHSessionWithoutRequests(t *testing.T) {
+	srv, _, cleanup := testServerWithSSH(t)
+	defer cleanup()
+
+	client, err := connectSSH(t, srv.sshListener.Addr().String())
+	if err != nil {
+		t.Fatalf("SSH connection failed: %v", err)
+	}
+	defer client.Close()
+
+	channel, requests, err := client.OpenChannel("session", nil)
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(requests)
+
+	frame, err := readSSHMessageWithTimeout(t, channel, sshModeTimeout+2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to read SERVER_CONFIG: %v", err)
+	}
+	if frame.Type != protocol.TypeServerConfig {
+		t.Errorf("Expected SERVER_CONFIG (0x%02X), got 0x%02X", protocol.TypeServerConfig, frame.Type)
+	}
+}
+
+// TestSSHTerminalSession verifies a session with a PTY gets the TUI
+func TestSSHTerminalSession(t *testing.T) {
+	srv, _, cleanup := testServerWithSSH(t)
+	defer cleanup()
+
+	client, err := connectSSH(t, srv.sshListener.Addr().String())
+	if err != nil {
+		t.Fatalf("SSH connection failed: %v", err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe: %v", err)
+	}
+	if err := session.RequestPty("xterm-256color", 40, 120, ssh.TerminalModes{}); err != nil {
+		t.Fatalf("RequestPty: %v", err)
+	}
+	if err := session.Shell(); err != nil {
+		t.Fatalf("Shell: %v", err)
+	}
+
+	// The TUI renders its header once it knows the terminal size
+	found := make(chan bool, 1)
+	go func() {
+		var output bytes.Buffer
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdout.Read(buf)
+			output.Write(buf[:n])
+			if bytes.Contains(output.Bytes(), []byte("SuperChat")) {
+				found <- true
+				return
+			}
+			if err != nil {
+				found <- false
+				return
+			}
+		}
+	}()
+
+	select {
+	case ok := <-found:
+		if !ok {
+			t.Fatal("Session ended before the TUI rendered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the TUI to render")
+	}
+
+	if err := session.WindowChange(30, 100); err != nil {
+		t.Errorf("WindowChange: %v", err)
+	}
+}
+
+// TestParsePtyRequest verifies pty-req and window-change payloads are decoded
+func TestParsePtyRequest(t *testing.T) {
+	payload := ssh.Marshal(struct {
+		Term                  string
+		Columns, Rows, PW, PH uint32
+		Modes                 string
+	}{"xterm", 80, 24, 640, 480, ""})
+
+	pty, err := parsePtyRequest(payload)
+	if err != nil {
+		t.Fatalf("parsePtyRequest: %v", err)
+	}
+	if pty.Term != "xterm" || pty.Width != 80 || pty.Height != 24 {
+		t.Errorf("unexpected pty %+v", pty)
+	}
+
+	size, err := parseWindowChange(ssh.Marshal(sshWindowSize{Width: 132, Height: 50}))
+	if err != nil {
+		t.Fatalf("parseWindowChange: %v", err)
+	}
+	if size.Width != 132 || size.Height != 50 {
+		t.Errorf("unexpected size %+v", size)
+	}
+
+	if _, err := parsePtyRequest([]byte{0, 0}); err == nil {
+		t.Error("expected error for truncated payload")
+	}
+}
+
 // TestSSHChannelConnWrapper tests the sshChannelConn wrapper
 func TestSSHChannelConnWrapper(t *testing.T) {
 	srv, _, cleanup := testServerWithSSH(t)
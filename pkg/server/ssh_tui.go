@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshSubsystemName is requested by the SuperChat client to get the binary
+	// protocol without waiting for sshModeTimeout
+	sshSubsystemName = "superchat"
+
+	// sshModeTimeout is how long a session channel may stay silent before we
+	// assume an older client that expects the binary protocol right away
+	sshModeTimeout = time.Second
+)
+
+// sshPty is the terminal requested with "pty-req" (RFC 4254 section 6.2)
+type sshPty struct {
+	Term   string
+	Width  uint32
+	Height uint32
+	PixelW uint32
+	PixelH uint32
+	Modes  string
+}
+
+// sshWindowSize is the payload of "window-change" (RFC 4254 section 6.7)
+type sshWindowSize struct {
+	Width  uint32
+	Height uint32
+	PixelW uint32
+	PixelH uint32
+}
+
+func parsePtyRequest(payload []byte) (*sshPty, error) {
+	pty := &sshPty{}
+	if err := ssh.Unmarshal(payload, pty); err != nil {
+		return nil, err
+	}
+	return pty, nil
+}
+
+func parseWindowChange(payload []byte) (sshWindowSize, error) {
+	var size sshWindowSize
+	err := ssh.Unmarshal(payload, &size)
+	return size, err
+}
+
+var sshTerminalRendererOnce sync.Once
+
+// handleSSHTerminal runs the client TUI inside an SSH session. The TUI talks to
+// an ordinary SSH session over an in-memory pipe, so the user is authenticated
+// exactly as if they had connected with the client.
+func (s *Server) handleSSHTerminal(channel ssh.Channel, permissions *ssh.Permissions, pty *sshPty, resize <-chan sshWindowSize) {
+	defer channel.Close()
+
+	// Styles are rendered by the process-wide lipgloss renderer, which would
+	// otherwise inspect the server's own stdout
+	sshTerminalRendererOnce.Do(func() {
+		lipgloss.SetColorProfile(termenv.ANSI256)
+		lipgloss.SetHasDarkBackground(true)
+	})
+
+	nickname := ""
+	if permissions != nil {
+		nickname = permissions.Extensions["nickname"]
+	}
+
+	serverEnd, clientEnd := net.Pipe()
+	sessionDone := make(chan struct{})
+	go func() {
+		defer close(sessionDone)
+		s.handleSSHSession(serverEnd, permissions)
+	}()
+
+	// Client state only lives as long as the SSH session
+	stateDir, err := os.MkdirTemp("", "superchat-ssh-")
+	if err != nil {
+		log.Printf("Failed to create SSH terminal state directory: %v", err)
+		clientEnd.Close()
+		<-sessionDone
+		return
+	}
+	defer os.RemoveAll(stateDir)
+
+	state, err := client.OpenState(filepath.Join(stateDir, "state.db"))
+	if err != nil {
+		log.Printf("Failed to open SSH terminal state: %v", err)
+		clientEnd.Close()
+		<-sessionDone
+		return
+	}
+	defer state.Close()
+	state.SetFirstRunComplete()
+
+	host := s.config.PublicHostname
+	conn := client.NewInProcessConnection(clientEnd, "ssh://"+nickname+"@"+host, host)
+	conn.Connect()
+
+	model := ui.NewModel(conn, state, s.version, false, 0, nil, stateDir, nil)
+	model.SetRemoteTerminal(channel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	program := tea.NewProgram(model,
+		tea.WithContext(ctx),
+		tea.WithInput(channel),
+		tea.WithOutput(channel),
+		tea.WithEnvironment([]string{"TERM=" + pty.Term}),
+		tea.WithAltScreen(),
+		tea.WithoutSignalHandler(),
+	)
+
+	// Bubble Tea only measures real terminals, so pass the PTY size along
+	go func() {
+		program.Send(tea.WindowSizeMsg{Width: int(pty.Width), Height: int(pty.Height)})
+		for {
+			select {
+			case size := <-resize:
+				program.Send(tea.WindowSizeMsg{Width: int(size.Width), Height: int(size.Height)})
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	debugLog.Printf("SSH terminal started for %s (%s %dx%d)", nickname, pty.Term, pty.Width, pty.Height)
+	if _, err := program.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		log.Printf("SSH terminal for %s failed: %v", nickname, err)
+	}
+	cancel()
+
+	conn.Close()
+	<-sessionDone
+
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+}
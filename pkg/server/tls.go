@@ -0,0 +1,252 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// tlsHandshakeTimeout bounds how long a client may take to complete the TLS handshake
+const tlsHandshakeTimeout = 10 * time.Second
+
+// startTLSServer starts the TLS listener for the binary protocol (sc+tls:// / scs://)
+func (s *Server) startTLSServer() error {
+	if s.config.TLSPort <= 0 {
+		return nil
+	}
+	if strings.TrimSpace(s.config.TLSCertPath) == "" || strings.TrimSpace(s.config.TLSKeyPath) == "" {
+		log.Printf("TLS server disabled (tls_cert/tls_key not configured)")
+		return nil
+	}
+
+	tlsConfig, err := s.loadTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	addr := fmt.Sprintf(":%d", s.config.TLSPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.tlsListener = listener
+
+	if tlsConfig.ClientCAs != nil {
+		log.Printf("TLS server listening on %s (client certificate auth enabled)", addr)
+	} else {
+		log.Printf("TLS server listening on %s", addr)
+	}
+
+	s.wg.Add(1)
+	go s.acceptTLSLoop(listener, tlsConfig)
+
+	return nil
+}
+
+// loadTLSConfig builds the TLS configuration from the configured cert, key and optional client CA
+func (s *Server) loadTLSConfig() (*tls.Config, error) {
+	certPath, err := expandHomePath(s.config.TLSCertPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPath, err := expandHomePath(s.config.TLSKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// Client certificates are optional: clients without one connect anonymously
+	// and can still log in with a password.
+	if strings.TrimSpace(s.config.TLSClientCAPath) != "" {
+		caPath, err := expandHomePath(s.config.TLSClientCAPath)
+		if err != nil {
+			return nil, err
+		}
+		caPEM, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in TLS client CA %s", caPath)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// acceptTLSLoop accepts incoming TLS connections
+func (s *Server) acceptTLSLoop(listener net.Listener, tlsConfig *tls.Config) {
+	defer s.wg.Done()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.shutdown:
+				return
+			default:
+				log.Printf("TLS accept error: %v", err)
+				continue
+			}
+		}
+
+		// Disable Nagle's algorithm on the raw socket before wrapping it
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			tcpConn.SetNoDelay(true)
+		}
+
+		// Take the per-IP connection slot before the handshake, so clients
+		// can't get around the limit with handshakes they never finish. The
+		// client can't read a protocol error before the handshake, so it is
+		// just disconnected.
+		ip := remoteIP(conn.RemoteAddr().String())
+		if !s.connectionLimiter.acquire(ip) {
+			s.recordConnectionRejection(ip)
+			conn.Close()
+			continue
+		}
+
+		go s.handleTLSConnection(tls.Server(conn, tlsConfig), ip)
+	}
+}
+
+// handleTLSConnection completes the TLS handshake, maps an optional client
+// certificate to a user, then hands off to the regular connection setup.
+// The caller holds a per-IP connection slot for ip.
+func (s *Server) handleTLSConnection(conn *tls.Conn, ip string) {
+	conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		debugLog.Printf("TLS handshake with %s failed: %v", conn.RemoteAddr(), err)
+		s.connectionLimiter.release(ip)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	user, ban, err := s.userForClientCertificate(conn.ConnectionState())
+	if err != nil {
+		log.Printf("TLS client certificate rejected from %s: %v", conn.RemoteAddr(), err)
+		_ = sendDisconnectFrame(conn, err.Error(), 0)
+		s.connectionLimiter.release(ip)
+		conn.Close()
+		return
+	}
+
+	s.setupConnection(conn, "tls", ip, user, ban)
+}
+
+// userForClientCertificate maps a verified client certificate to a registered
+// user by its subject common name. Returns nil without error when the client
+// did not present a certificate.
+func (s *Server) userForClientCertificate(state tls.ConnectionState) (*database.User, *database.Ban, error) {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, nil, nil
+	}
+
+	nickname := state.VerifiedChains[0][0].Subject.CommonName
+	if nickname == "" {
+		return nil, nil, fmt.Errorf("client certificate has no common name")
+	}
+
+	user, err := s.db.GetUserByNickname(nickname)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("client certificate for %q does not match a registered user", nickname)
+		}
+		return nil, nil, fmt.Errorf("failed to look up user %q: %w", nickname, err)
+	}
+
+	ban, err := s.db.GetActiveBanForUser(&user.ID, &user.Nickname)
+	if err != nil {
+		log.Printf("TLS auth: failed to check ban status for user %s (ID: %d): %v", user.Nickname, user.ID, err)
+		// Continue with auth - don't block on ban check failures
+	}
+	if ban != nil && !ban.Shadowban {
+		bannedUntil := "permanently"
+		if ban.BannedUntil != nil {
+			bannedUntil = fmt.Sprintf("until %s", time.Unix(*ban.BannedUntil/1000, 0).Format(time.RFC3339))
+		}
+		return nil, nil, fmt.Errorf("account banned %s", bannedUntil)
+	}
+
+	// Sync admin flag from config (config is source of truth)
+	expectedAdmin := s.isAdminNickname(user.Nickname)
+	hasAdmin := user.UserFlags&uint8(protocol.UserFlagAdmin) != 0
+	if expectedAdmin != hasAdmin {
+		if expectedAdmin {
+			user.UserFlags |= uint8(protocol.UserFlagAdmin)
+		} else {
+			user.UserFlags &^= uint8(protocol.UserFlagAdmin)
+		}
+		if err := s.db.UpdateUserFlags(user.ID, user.UserFlags); err != nil {
+			log.Printf("TLS auth: failed to sync admin flags for user %s: %v", user.Nickname, err)
+		}
+	}
+
+	return user, ban, nil
+}
+
+// sendCertificateAuthResponse finishes login for a session authenticated by
+// client certificate, mirroring what AUTH_REQUEST does for passwords
+func (s *Server) sendCertificateAuthResponse(sess *Session, user *database.User, ban *database.Ban) error {
+	sess.mu.Lock()
+	sess.UserFlags = user.UserFlags
+	sess.Shadowbanned = ban != nil && ban.Shadowban
+	sess.mu.Unlock()
+
+	if err := s.db.UpdateUserLastSeen(user.ID); err != nil {
+		log.Printf("Session %d: failed to update user last_seen: %v", sess.ID, err)
+	}
+
+	flags := protocol.UserFlags(user.UserFlags)
+	authResp := &protocol.AuthResponseMessage{
+		Success:   true,
+		UserID:    uint64(user.ID),
+		Nickname:  user.Nickname,
+		Message:   fmt.Sprintf("Authenticated via client certificate as %s", user.Nickname),
+		UserFlags: &flags,
+	}
+	if err := s.sendMessage(sess, protocol.TypeAuthResponse, authResp); err != nil {
+		return err
+	}
+	debugLog.Printf("Session %d: Auto-authenticated TLS user %s (ID: %d)", sess.ID, user.Nickname, user.ID)
+	s.sendServerPresenceSnapshot(sess)
+	s.notifyServerPresence(sess, true)
+	return nil
+}
+
+// expandHomePath expands a leading ~/ to the user's home directory
+func expandHomePath(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, path[2:])
+	}
+	return path, nil
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate creates a certificate for commonName, signed by parent (self-signed if parent is nil)
+func testCertificate(t *testing.T, commonName string, isCA bool, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	parentCert, parentKey := template, any(key)
+	if parent != nil {
+		parentCert = parent.Leaf
+		parentKey = parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// tlsHandshake runs a handshake over an in-memory pipe and returns the server's view of it
+func tlsHandshake(t *testing.T, serverConfig *tls.Config, clientCert *tls.Certificate) tls.ConnectionState {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	clientConfig := &tls.Config{InsecureSkipVerify: true}
+	if clientCert != nil {
+		clientConfig.Certificates = []tls.Certificate{*clientCert}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- tls.Client(clientConn, clientConfig).Handshake()
+	}()
+
+	server := tls.Server(serverConn, serverConfig)
+	if err := server.Handshake(); err != nil {
+		t.Fatalf("server handshake: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("client handshake: %v", err)
+	}
+	return server.ConnectionState()
+}
+
+func TestUserForClientCertificate(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	ca := testCertificate(t, "SuperChat Test CA", true, nil)
+	serverCert := testCertificate(t, "localhost", false, &ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}
+
+	t.Run("no certificate", func(t *testing.T) {
+		user, _, err := srv.userForClientCertificate(tlsHandshake(t, serverConfig, nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if user != nil {
+			t.Errorf("expected anonymous connection, got user %s", user.Nickname)
+		}
+	})
+
+	t.Run("registered user", func(t *testing.T) {
+		clientCert := testCertificate(t, "alice", false, &ca)
+		user, ban, err := srv.userForClientCertificate(tlsHandshake(t, serverConfig, &clientCert))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if user == nil || user.Nickname != "alice" {
+			t.Fatalf("expected user alice, got %+v", user)
+		}
+		if ban != nil {
+			t.Errorf("expected no ban, got %+v", ban)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		clientCert := testCertificate(t, "mallory", false, &ca)
+		_, _, err := srv.userForClientCertificate(tlsHandshake(t, serverConfig, &clientCert))
+		if err == nil || !strings.Contains(err.Error(), "does not match a registered user") {
+			t.Fatalf("expected unknown user error, got %v", err)
+		}
+	})
+
+	t.Run("banned user", func(t *testing.T) {
+		nickname := "alice"
+		if _, err := srv.db.CreateUserBan(&aliceID, &nickname, "test", false, nil, "admin", "127.0.0.1"); err != nil {
+			t.Fatalf("CreateUserBan: %v", err)
+		}
+		clientCert := testCertificate(t, "alice", false, &ca)
+		_, _, err := srv.userForClientCertificate(tlsHandshake(t, serverConfig, &clientCert))
+		if err == nil || !strings.Contains(err.Error(), "banned") {
+			t.Fatalf("expected ban error, got %v", err)
+		}
+	})
+}
+
+func TestTLSConnectionLimit(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	srv.config.MaxConnectionsPerIP = 1
+	srv.connectionLimiter = newConnectionLimiter(1)
+	srv.shutdown = make(chan struct{})
+
+	serverCert := testCertificate(t, "localhost", false, nil)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	srv.wg.Add(1)
+	go srv.acceptTLSLoop(listener, &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	defer func() {
+		close(srv.shutdown)
+		listener.Close()
+		srv.wg.Wait()
+	}()
+
+	connections := func() int {
+		srv.connectionLimiter.mu.Lock()
+		defer srv.connectionLimiter.mu.Unlock()
+		return srv.connectionLimiter.counts["127.0.0.1"]
+	}
+	waitFor := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for connections() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d connection(s) from 127.0.0.1, got %d", want, connections())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A client that never starts the handshake still takes the slot
+	idle, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	waitFor(1)
+
+	second, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected the second connection to be closed, got %v", err)
+	}
+
+	// A failed handshake gives the slot back
+	idle.Close()
+	waitFor(0)
+}
@@ -0,0 +1,487 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// Two-factor authentication (V4). Password accounts can enroll an RFC 6238
+// authenticator (HMAC-SHA1, 6 digits, 30 second steps). After that a correct
+// password gets an AUTH_RESPONSE challenge instead of a login, answered with
+// AUTH_TOTP. Accounts the require_2fa config setting applies to that haven't
+// enrolled are challenged to do so before they're logged in. SSH and client
+// certificate logins don't use a password, so they're never challenged.
+//
+// Logging in again starts a new pending login, so invalid codes are also
+// counted per user in totpLimiter, together with invalid codes sent to
+// CONFIRM_TOTP and DISABLE_TOTP. A user who runs out is locked out of all
+// three, whatever the code, until the bucket refills.
+
+const (
+	totpPeriod          = 30 * time.Second
+	totpDigits          = 6
+	totpSkew            = 1  // Steps either side of now that are accepted, for clock drift
+	totpMaxAttempts     = 5  // Invalid codes before the pending login is dropped
+	totpLockoutAttempts = 10 // Invalid codes per user, across sessions, before they're locked out
+	totpLockoutWindow   = time.Hour
+	recoveryCodeCount   = 10 // Recovery codes handed out at enrollment
+)
+
+// Values for the require_2fa config setting
+const (
+	require2FAAdmin     = "admin"     // Users in admin_users
+	require2FAModerator = "moderator" // Owners and moderators of any channel
+)
+
+// pendingLogin is a password login waiting for its two-factor step
+type pendingLogin struct {
+	userID       int64
+	challenge    uint8 // protocol.AuthChallenge*
+	requestToken bool  // The AUTH_REQUEST asked for a resume token
+	attempts     int   // Invalid codes so far
+}
+
+// validateRequire2FA checks the require_2fa setting at startup
+func validateRequire2FA(kinds []string) error {
+	for _, kind := range kinds {
+		if kind != require2FAAdmin && kind != require2FAModerator {
+			return fmt.Errorf("require_2fa: unknown account kind %q (expected %q or %q)", kind, require2FAAdmin, require2FAModerator)
+		}
+	}
+	return nil
+}
+
+// totpCode returns the code for a time step (RFC 4226 truncation)
+func totpCode(secret []byte, step int64, digits int) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulus := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulus)
+}
+
+// matchTOTP checks a code against the steps around now, returning the step
+// it matched
+func matchTOTP(secret []byte, code string, now time.Time) (int64, bool) {
+	current := now.Unix() / int64(totpPeriod/time.Second)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(secret, step, totpDigits)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// encodeTOTPSecret returns a secret the way authenticator apps expect it
+func encodeTOTPSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+// totpURI returns the otpauth:// URI authenticator apps read from QR codes
+func totpURI(issuer, nickname string, secret []byte) string {
+	params := url.Values{}
+	params.Set("secret", encodeTOTPSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+	label := url.PathEscape(issuer + ":" + nickname)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in recovery codes
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// hashRecoveryCode returns the hash a recovery code is stored as
+func hashRecoveryCode(code string) []byte {
+	hash := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hash[:]
+}
+
+// newRecoveryCodes makes a set of recovery codes and their hashes
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encodeTOTPSecret(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// isTOTPCode reports whether a code looks like an authenticator code rather
+// than a recovery code
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTOTPCode verifies an authenticator or recovery code for a user. Each
+// code only works once.
+func (s *Server) checkTOTPCode(totp *database.UserTOTP, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if isTOTPCode(code) {
+		step, ok := matchTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return s.db.UseTOTPStep(totp.UserID, step)
+	}
+	return s.db.UseTOTPRecoveryCode(totp.UserID, hashRecoveryCode(code))
+}
+
+// requires2FA reports whether the require_2fa setting applies to a user
+func (s *Server) requires2FA(user *database.User) (bool, error) {
+	for _, kind := range s.config.Require2FA {
+		switch kind {
+		case require2FAAdmin:
+			if s.isAdminNickname(user.Nickname) {
+				return true, nil
+			}
+		case require2FAModerator:
+			if hasRole, err := s.db.HasChannelRole(user.ID); err != nil || hasRole {
+				return hasRole, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// loginChallenge returns the two-factor step a password login for a user
+// needs, if any
+func (s *Server) loginChallenge(user *database.User) (uint8, error) {
+	totp, err := s.db.GetTOTP(user.ID)
+	if err != nil {
+		return protocol.AuthChallengeNone, err
+	}
+	if totp != nil {
+		return protocol.AuthChallengeTOTP, nil
+	}
+	required, err := s.requires2FA(user)
+	if err != nil {
+		return protocol.AuthChallengeNone, err
+	}
+	if required {
+		return protocol.AuthChallengeTOTPSetup, nil
+	}
+	return protocol.AuthChallengeNone, nil
+}
+
+// challengeLogin holds a password login until the two-factor step is done
+func (s *Server) challengeLogin(sess *Session, user *database.User, challenge uint8, requestToken bool) error {
+	sess.mu.Lock()
+	sess.pendingLogin = &pendingLogin{userID: user.ID, challenge: challenge, requestToken: requestToken}
+	sess.pendingTOTPSecret = nil
+	sess.mu.Unlock()
+
+	message := "Enter the code from your authenticator app"
+	if challenge == protocol.AuthChallengeTOTPSetup {
+		message = "This account requires two-factor authentication. Set up an authenticator app to continue."
+	}
+	log.Printf("Session %d: password accepted for user %s, waiting for two-factor step %d", sess.ID, user.Nickname, challenge)
+	return s.sendMessage(sess, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{
+		Success:   false,
+		Message:   message,
+		Challenge: challenge,
+	})
+}
+
+// finishPendingLogin logs in the user of a session's pending login
+func (s *Server) finishPendingLogin(sess *Session, pending *pendingLogin) error {
+	sess.mu.Lock()
+	sess.pendingLogin = nil
+	sess.mu.Unlock()
+
+	user, err := s.db.GetUserByID(pending.userID)
+	if err == sql.ErrNoRows {
+		return s.sendMessage(sess, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{
+			Success: false,
+			Message: "Invalid credentials",
+		})
+	}
+	if err != nil {
+		return s.dbError(sess, "GetUserByID", err)
+	}
+	return s.completeLogin(sess, user, 0, pending.requestToken)
+}
+
+// handleAuthTOTP handles AUTH_TOTP, the answer to a two-factor challenge
+func (s *Server) handleAuthTOTP(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.AuthTOTPMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	pending := sess.pendingLogin
+	sess.mu.RUnlock()
+	if pending == nil || pending.challenge != protocol.AuthChallengeTOTP {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "No two-factor challenge pending")
+	}
+
+	lockoutKey := totpLockoutKey(pending.userID)
+	if wait := s.totpLimiter.blocked(lockoutKey); wait > 0 {
+		sess.mu.Lock()
+		sess.pendingLogin = nil
+		sess.mu.Unlock()
+		log.Printf("Session %d: AUTH_TOTP refused for user %d, locked out after too many invalid codes", sess.ID, pending.userID)
+		return s.sendMessage(sess, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{
+			Success: false,
+			Message: "Too many invalid codes for this account, " + formatRetryAfter(wait),
+		})
+	}
+
+	totp, err := s.db.GetTOTP(pending.userID)
+	if err != nil {
+		return s.dbError(sess, "GetTOTP", err)
+	}
+	ok := false
+	if totp != nil {
+		if ok, err = s.checkTOTPCode(totp, msg.Code); err != nil {
+			return s.dbError(sess, "checkTOTPCode", err)
+		}
+	}
+	if ok {
+		log.Printf("Session %d: two-factor code accepted for user %d", sess.ID, pending.userID)
+		return s.finishPendingLogin(sess, pending)
+	}
+
+	s.totpLimiter.allow(lockoutKey)
+	sess.mu.Lock()
+	pending.attempts++
+	attempts := pending.attempts
+	if attempts >= totpMaxAttempts {
+		sess.pendingLogin = nil
+	}
+	sess.mu.Unlock()
+
+	log.Printf("Session %d: AUTH_TOTP failed for user %d (attempt %d)", sess.ID, pending.userID, attempts)
+	resp := &protocol.AuthResponseMessage{
+		Success:   false,
+		Message:   "Invalid code",
+		Challenge: protocol.AuthChallengeTOTP,
+	}
+	if attempts >= totpMaxAttempts {
+		resp.Message = "Too many invalid codes, please log in again"
+		resp.Challenge = protocol.AuthChallengeNone
+	}
+	return s.sendMessage(sess, protocol.TypeAuthResponse, resp)
+}
+
+// totpLockoutKey is the totpLimiter key counting a user's invalid codes
+func totpLockoutKey(userID int64) string {
+	return fmt.Sprintf("totp:%d", userID)
+}
+
+// sendTOTPLockedOut refuses a code from a user who is locked out of
+// two-factor authentication
+func (s *Server) sendTOTPLockedOut(sess *Session, userID int64, wait time.Duration) error {
+	log.Printf("Session %d: two-factor code refused for user %d, locked out after too many invalid codes", sess.ID, userID)
+	return s.sendError(sess, protocol.ErrCodeRateLimitExceeded, "Too many invalid codes for this account, "+formatRetryAfter(wait))
+}
+
+// totpUser returns the user a session may enroll an authenticator for: the
+// logged in user, or the user of a pending login that must enroll first
+func (s *Server) totpUser(sess *Session) (*database.User, *pendingLogin, error) {
+	sess.mu.RLock()
+	userID := sess.UserID
+	pending := sess.pendingLogin
+	sess.mu.RUnlock()
+
+	if pending != nil && pending.challenge == protocol.AuthChallengeTOTPSetup {
+		user, err := s.db.GetUserByID(pending.userID)
+		return user, pending, err
+	}
+	if userID == nil {
+		return nil, nil, nil
+	}
+	user, err := s.db.GetUserByID(*userID)
+	return user, nil, err
+}
+
+// handleSetupTOTP handles SETUP_TOTP, making a new secret for the session to
+// confirm with CONFIRM_TOTP
+func (s *Server) handleSetupTOTP(sess *Session, frame *protocol.Frame) error {
+	user, _, err := s.totpUser(sess)
+	if err != nil {
+		return s.dbError(sess, "GetUserByID", err)
+	}
+	if user == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required")
+	}
+	if user.PasswordHash == "" {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Two-factor authentication is only used for password logins")
+	}
+	totp, err := s.db.GetTOTP(user.ID)
+	if err != nil {
+		return s.dbError(sess, "GetTOTP", err)
+	}
+	if totp != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Two-factor authentication is already enabled")
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInternalError, "Failed to create secret")
+	}
+	sess.mu.Lock()
+	sess.pendingTOTPSecret = secret
+	sess.mu.Unlock()
+
+	return s.sendMessage(sess, protocol.TypeTOTPSetup, &protocol.TOTPSetupMessage{
+		Secret: encodeTOTPSecret(secret),
+		URI:    totpURI(s.config.ServerName, user.Nickname, secret),
+	})
+}
+
+// handleConfirmTOTP handles CONFIRM_TOTP, enrolling the secret from
+// SETUP_TOTP once the authenticator produces a matching code
+func (s *Server) handleConfirmTOTP(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ConfirmTOTPMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	user, pending, err := s.totpUser(sess)
+	if err != nil {
+		return s.dbError(sess, "GetUserByID", err)
+	}
+	if user == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required")
+	}
+	sess.mu.RLock()
+	secret := sess.pendingTOTPSecret
+	currentTokenID := sess.ResumeTokenID
+	sess.mu.RUnlock()
+	if secret == nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Send SETUP_TOTP first")
+	}
+	lockoutKey := totpLockoutKey(user.ID)
+	if wait := s.totpLimiter.blocked(lockoutKey); wait > 0 {
+		return s.sendTOTPLockedOut(sess, user.ID, wait)
+	}
+
+	step, ok := matchTOTP(secret, strings.TrimSpace(msg.Code), time.Now())
+	if !ok {
+		s.totpLimiter.allow(lockoutKey)
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Invalid code")
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return s.sendError(sess, protocol.ErrCodeInternalError, "Failed to create recovery codes")
+	}
+	if err := s.db.EnableTOTP(user.ID, secret, hashes); err != nil {
+		return s.dbError(sess, "EnableTOTP", err)
+	}
+	// The confirming code can't be used to log in as well
+	if _, err := s.db.UseTOTPStep(user.ID, step); err != nil {
+		log.Printf("Session %d: failed to record two-factor step: %v", sess.ID, err)
+	}
+	sess.mu.Lock()
+	sess.pendingTOTPSecret = nil
+	sess.mu.Unlock()
+
+	// Logins from before the second factor have to log in again
+	if count, err := s.db.DeleteUserResumeTokens(user.ID, currentTokenID); err != nil {
+		log.Printf("Session %d: failed to delete resume tokens after enabling two-factor: %v", sess.ID, err)
+	} else if count > 0 {
+		log.Printf("Deleted %d resume token(s) for user %s after enabling two-factor", count, user.Nickname)
+	}
+
+	log.Printf("Session %d: two-factor authentication enabled for user %s", sess.ID, user.Nickname)
+	if err := s.sendMessage(sess, protocol.TypeTOTPEnabled, &protocol.TOTPEnabledMessage{RecoveryCodes: codes}); err != nil {
+		return err
+	}
+	if pending != nil {
+		return s.finishPendingLogin(sess, pending)
+	}
+	return nil
+}
+
+// handleDisableTOTP handles DISABLE_TOTP, removing the user's authenticator
+func (s *Server) handleDisableTOTP(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required")
+	}
+
+	msg := &protocol.DisableTOTPMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	user, err := s.db.GetUserByID(*userID)
+	if err != nil {
+		return s.dbError(sess, "GetUserByID", err)
+	}
+	totp, err := s.db.GetTOTP(user.ID)
+	if err != nil {
+		return s.dbError(sess, "GetTOTP", err)
+	}
+	if totp == nil {
+		return s.sendError(sess, protocol.ErrCodeNotFound, "Two-factor authentication is not enabled")
+	}
+	required, err := s.requires2FA(user)
+	if err != nil {
+		return s.dbError(sess, "requires2FA", err)
+	}
+	if required {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "This server requires two-factor authentication for your account")
+	}
+	lockoutKey := totpLockoutKey(user.ID)
+	if wait := s.totpLimiter.blocked(lockoutKey); wait > 0 {
+		return s.sendTOTPLockedOut(sess, user.ID, wait)
+	}
+	ok, err := s.checkTOTPCode(totp, msg.Code)
+	if err != nil {
+		return s.dbError(sess, "checkTOTPCode", err)
+	}
+	if !ok {
+		s.totpLimiter.allow(lockoutKey)
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Invalid code")
+	}
+
+	if err := s.db.DisableTOTP(user.ID); err != nil {
+		return s.dbError(sess, "DisableTOTP", err)
+	}
+	log.Printf("Session %d: two-factor authentication disabled for user %s", sess.ID, user.Nickname)
+	return s.sendMessage(sess, protocol.TypeTOTPDisabled, &protocol.TOTPDisabledMessage{})
+}
@@ -0,0 +1,360 @@
+package server
+
+import (
+	"encoding/base32"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1
+	secret := []byte("12345678901234567890")
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		if code := totpCode(secret, tc.unix/30, 8); code != tc.code {
+			t.Errorf("at %d: expected %s, got %s", tc.unix, tc.code, code)
+		}
+	}
+
+	now := time.Unix(1234567890, 0)
+	code := totpCode(secret, now.Unix()/30, totpDigits)
+	if step, ok := matchTOTP(secret, code, now.Add(totpPeriod)); !ok || step != now.Unix()/30 {
+		t.Error("expected a code from the previous step to match")
+	}
+	if _, ok := matchTOTP(secret, code, now.Add(3*totpPeriod)); ok {
+		t.Error("expected an old code not to match")
+	}
+
+	if err := validateRequire2FA([]string{"admin", "moderator"}); err != nil {
+		t.Errorf("validateRequire2FA: %v", err)
+	}
+	if err := validateRequire2FA([]string{"admins"}); err == nil {
+		t.Error("expected an unknown account kind to be rejected")
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+	srv.config.AdminUsers = []string{"root"}
+	srv.config.Require2FA = []string{"admin"}
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("client-hash"), bcrypt.MinCost)
+	for _, nickname := range []string{"alice", "root"} {
+		if _, err := srv.db.CreateUser(nickname, string(hash), 0); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	connect := func(t *testing.T) (*Session, *mockConn) {
+		t.Helper()
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(nil, "", "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return sess, conn
+	}
+	handle := func(t *testing.T, sess *Session, msgType uint8, msg protocol.ProtocolMessage, handle func(*Session, *protocol.Frame) error) {
+		t.Helper()
+		if err := handle(sess, encodeAdminFrame(t, msgType, msg)); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+	}
+	// expect decodes the next frame written to a connection as msg
+	expect := func(t *testing.T, conn *mockConn, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		frame, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		if frame.Type != msgType {
+			t.Fatalf("expected 0x%02X, got 0x%02X", msgType, frame.Type)
+		}
+		if err := msg.Decode(frame.Payload); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+	}
+	login := func(t *testing.T, nickname string) (*Session, *mockConn, *protocol.AuthResponseMessage) {
+		t.Helper()
+		sess, conn := connect(t)
+		handle(t, sess, protocol.TypeAuthRequest, &protocol.AuthRequestMessage{Nickname: nickname, Password: "client-hash"}, srv.handleAuthRequest)
+		auth := &protocol.AuthResponseMessage{}
+		expect(t, conn, protocol.TypeAuthResponse, auth)
+		return sess, conn, auth
+	}
+	answer := func(t *testing.T, sess *Session, conn *mockConn, code string) *protocol.AuthResponseMessage {
+		t.Helper()
+		handle(t, sess, protocol.TypeAuthTOTP, &protocol.AuthTOTPMessage{Code: code}, srv.handleAuthTOTP)
+		auth := &protocol.AuthResponseMessage{}
+		expect(t, conn, protocol.TypeAuthResponse, auth)
+		return auth
+	}
+	// enroll sets up an authenticator and returns its secret and recovery codes
+	enroll := func(t *testing.T, sess *Session, conn *mockConn) ([]byte, int64, []string) {
+		t.Helper()
+		handle(t, sess, protocol.TypeSetupTOTP, &protocol.SetupTOTPMessage{}, srv.handleSetupTOTP)
+		setup := &protocol.TOTPSetupMessage{}
+		expect(t, conn, protocol.TypeTOTPSetup, setup)
+		secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(setup.Secret)
+		if err != nil {
+			t.Fatalf("secret: %v", err)
+		}
+
+		handle(t, sess, protocol.TypeConfirmTOTP, &protocol.ConfirmTOTPMessage{Code: "000000x"}, srv.handleConfirmTOTP)
+		errMsg := &protocol.ErrorMessage{}
+		if expect(t, conn, protocol.TypeError, errMsg); errMsg.ErrorCode != protocol.ErrCodeInvalidInput {
+			t.Errorf("expected a wrong code to be rejected, got %d", errMsg.ErrorCode)
+		}
+
+		step := time.Now().Unix() / 30
+		handle(t, sess, protocol.TypeConfirmTOTP, &protocol.ConfirmTOTPMessage{Code: totpCode(secret, step, totpDigits)}, srv.handleConfirmTOTP)
+		enabled := &protocol.TOTPEnabledMessage{}
+		if expect(t, conn, protocol.TypeTOTPEnabled, enabled); len(enabled.RecoveryCodes) != recoveryCodeCount {
+			t.Fatalf("expected %d recovery codes, got %v", recoveryCodeCount, enabled.RecoveryCodes)
+		}
+		return secret, step, enabled.RecoveryCodes
+	}
+
+	// Without an authenticator, alice logs in with just the password
+	alice, aliceConn, auth := login(t, "alice")
+	if !auth.Success {
+		t.Fatalf("expected to log in, got %q", auth.Message)
+	}
+	aliceConn.writeBuf.Reset()
+	secret, step, recoveryCodes := enroll(t, alice, aliceConn)
+
+	// Now the password is only the first step
+	sess, conn, auth := login(t, "alice")
+	if auth.Success || auth.Challenge != protocol.AuthChallengeTOTP || sess.UserID != nil {
+		t.Fatalf("expected a two-factor challenge, got %+v", auth)
+	}
+	if auth := answer(t, sess, conn, "123456x"); auth.Success || auth.Challenge != protocol.AuthChallengeTOTP {
+		t.Fatalf("expected to be asked again after a wrong code, got %+v", auth)
+	}
+	// The code that confirmed the authenticator can't be reused
+	if auth := answer(t, sess, conn, totpCode(secret, step, totpDigits)); auth.Success {
+		t.Fatal("expected the confirming code to be refused")
+	}
+	code := totpCode(secret, step+1, totpDigits)
+	if auth := answer(t, sess, conn, code); !auth.Success || sess.UserID == nil {
+		t.Fatalf("expected to log in with the code, got %+v", auth)
+	}
+
+	// Codes work once, recovery codes too
+	sess, conn, _ = login(t, "alice")
+	if auth := answer(t, sess, conn, code); auth.Success {
+		t.Fatal("expected a replayed code to be refused")
+	}
+	if auth := answer(t, sess, conn, recoveryCodes[0]); !auth.Success {
+		t.Fatalf("expected to log in with a recovery code, got %q", auth.Message)
+	}
+	sess, conn, _ = login(t, "alice")
+	if auth := answer(t, sess, conn, recoveryCodes[0]); auth.Success {
+		t.Fatal("expected a used recovery code to be refused")
+	}
+
+	// Too many wrong codes drop the login
+	for i := 2; i < totpMaxAttempts; i++ {
+		answer(t, sess, conn, wrongTOTPCode(secret))
+	}
+	if auth := answer(t, sess, conn, wrongTOTPCode(secret)); auth.Success || auth.Challenge != protocol.AuthChallengeNone {
+		t.Fatalf("expected the login to be dropped, got %+v", auth)
+	}
+	handle(t, sess, protocol.TypeAuthTOTP, &protocol.AuthTOTPMessage{Code: recoveryCodes[1]}, srv.handleAuthTOTP)
+	errMsg := &protocol.ErrorMessage{}
+	if expect(t, conn, protocol.TypeError, errMsg); errMsg.ErrorCode != protocol.ErrCodeInvalidInput {
+		t.Errorf("expected no challenge to be pending, got %d", errMsg.ErrorCode)
+	}
+
+	// Disabling takes a code
+	aliceConn.writeBuf.Reset()
+	handle(t, alice, protocol.TypeDisableTOTP, &protocol.DisableTOTPMessage{Code: recoveryCodes[1]}, srv.handleDisableTOTP)
+	expect(t, aliceConn, protocol.TypeTOTPDisabled, &protocol.TOTPDisabledMessage{})
+	if _, _, auth := login(t, "alice"); !auth.Success {
+		t.Fatalf("expected to log in with just the password again, got %q", auth.Message)
+	}
+
+	// Admins must enroll before they're logged in, and can't disable it
+	root, rootConn, auth := login(t, "root")
+	if auth.Success || auth.Challenge != protocol.AuthChallengeTOTPSetup {
+		t.Fatalf("expected to be asked to enroll, got %+v", auth)
+	}
+	handle(t, root, protocol.TypeAuthTOTP, &protocol.AuthTOTPMessage{Code: "000000"}, srv.handleAuthTOTP)
+	if expect(t, rootConn, protocol.TypeError, errMsg); errMsg.ErrorCode != protocol.ErrCodeInvalidInput {
+		t.Errorf("expected AUTH_TOTP not to skip enrolling, got %d", errMsg.ErrorCode)
+	}
+	secret, step, _ = enroll(t, root, rootConn)
+	if expect(t, rootConn, protocol.TypeAuthResponse, auth); !auth.Success || root.UserID == nil {
+		t.Fatalf("expected to be logged in after enrolling, got %+v", auth)
+	}
+	rootConn.writeBuf.Reset()
+	handle(t, root, protocol.TypeDisableTOTP, &protocol.DisableTOTPMessage{Code: totpCode(secret, step+1, totpDigits)}, srv.handleDisableTOTP)
+	if expect(t, rootConn, protocol.TypeError, errMsg); errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+		t.Errorf("expected permission denied, got %d", errMsg.ErrorCode)
+	}
+}
+
+// wrongTOTPCode returns a code that differs from every code accepted for
+// secret around now
+func wrongTOTPCode(secret []byte) string {
+	now := time.Now()
+	for n := 0; ; n++ {
+		code := fmt.Sprintf("%06d", n)
+		_, valid := matchTOTP(secret, code, now)
+		_, validNext := matchTOTP(secret, code, now.Add(totpPeriod))
+		if !valid && !validNext {
+			return code
+		}
+	}
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+	srv.totpLimiter = newRateLimiter(totpLockoutAttempts, totpLockoutWindow)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("client-hash"), bcrypt.MinCost)
+	userID, err := srv.db.CreateUser("alice", string(hash), 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	secret := []byte("12345678901234567890")
+	if err := srv.db.EnableTOTP(userID, secret, nil); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+
+	// try logs in on a new connection and answers the challenge with code
+	try := func(t *testing.T, code string) *protocol.AuthResponseMessage {
+		t.Helper()
+		conn := newMockConn()
+		sess, err := srv.sessions.CreateSession(nil, "", "tcp", conn)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		if err := srv.handleAuthRequest(sess, encodeAdminFrame(t, protocol.TypeAuthRequest, &protocol.AuthRequestMessage{Nickname: "alice", Password: "client-hash"})); err != nil {
+			t.Fatalf("handleAuthRequest: %v", err)
+		}
+		if err := srv.handleAuthTOTP(sess, encodeAdminFrame(t, protocol.TypeAuthTOTP, &protocol.AuthTOTPMessage{Code: code})); err != nil {
+			t.Fatalf("handleAuthTOTP: %v", err)
+		}
+		auth := &protocol.AuthResponseMessage{}
+		for _, msg := range []*protocol.AuthResponseMessage{{}, auth} {
+			frame, err := protocol.DecodeFrame(conn.writeBuf)
+			if err != nil {
+				t.Fatalf("DecodeFrame: %v", err)
+			}
+			if err := msg.Decode(frame.Payload); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+		}
+		return auth
+	}
+
+	// Starting a new login for every guess doesn't reset the count
+	for i := 0; i < totpLockoutAttempts; i++ {
+		if auth := try(t, wrongTOTPCode(secret)); auth.Success || auth.Challenge != protocol.AuthChallengeTOTP {
+			t.Fatalf("attempt %d: expected to be asked again, got %+v", i+1, auth)
+		}
+	}
+	code := totpCode(secret, time.Now().Unix()/30, totpDigits)
+	if auth := try(t, code); auth.Success || auth.Challenge != protocol.AuthChallengeNone {
+		t.Fatalf("expected the account to be locked out, got %+v", auth)
+	}
+
+	// The lockout wears off
+	srv.totpLimiter.now = func() time.Time { return time.Now().Add(totpLockoutWindow) }
+	if auth := try(t, code); !auth.Success {
+		t.Fatalf("expected to log in once the lockout is over, got %q", auth.Message)
+	}
+}
+
+func TestTwoFactorLockoutCoversDisableAndConfirm(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+	srv.totpLimiter = newRateLimiter(totpLockoutAttempts, totpLockoutWindow)
+
+	userID, err := srv.db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	secret := []byte("12345678901234567890")
+	if err := srv.db.EnableTOTP(userID, secret, nil); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+	conn := newMockConn()
+	sess, err := srv.sessions.CreateSession(&userID, "alice", "tcp", conn)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	// disable sends DISABLE_TOTP and returns the error it got, if any
+	disable := func(t *testing.T, code string) *protocol.ErrorMessage {
+		t.Helper()
+		conn.writeBuf.Reset()
+		if err := srv.handleDisableTOTP(sess, encodeAdminFrame(t, protocol.TypeDisableTOTP, &protocol.DisableTOTPMessage{Code: code})); err != nil {
+			t.Fatalf("handleDisableTOTP: %v", err)
+		}
+		frame, err := protocol.DecodeFrame(conn.writeBuf)
+		if err != nil {
+			t.Fatalf("DecodeFrame: %v", err)
+		}
+		if frame.Type != protocol.TypeError {
+			return nil
+		}
+		errMsg := &protocol.ErrorMessage{}
+		if err := errMsg.Decode(frame.Payload); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		return errMsg
+	}
+
+	// Guessing codes for DISABLE_TOTP counts against the same lockout as
+	// logging in
+	for i := 0; i < totpLockoutAttempts; i++ {
+		if errMsg := disable(t, wrongTOTPCode(secret)); errMsg == nil || errMsg.ErrorCode != protocol.ErrCodeInvalidInput {
+			t.Fatalf("attempt %d: expected the code to be refused, got %+v", i+1, errMsg)
+		}
+	}
+	code := totpCode(secret, time.Now().Unix()/30, totpDigits)
+	if errMsg := disable(t, code); errMsg == nil || errMsg.ErrorCode != protocol.ErrCodeRateLimitExceeded {
+		t.Fatalf("expected the account to be locked out, got %+v", errMsg)
+	}
+	if totp, _ := srv.db.GetTOTP(userID); totp == nil {
+		t.Fatal("expected two-factor to stay enabled while locked out")
+	}
+
+	// So does CONFIRM_TOTP
+	sess.mu.Lock()
+	sess.pendingTOTPSecret = secret
+	sess.mu.Unlock()
+	conn.writeBuf.Reset()
+	if err := srv.handleConfirmTOTP(sess, encodeAdminFrame(t, protocol.TypeConfirmTOTP, &protocol.ConfirmTOTPMessage{Code: code})); err != nil {
+		t.Fatalf("handleConfirmTOTP: %v", err)
+	}
+	frame, err := protocol.DecodeFrame(conn.writeBuf)
+	if err != nil {
+		t.Fatalf("DecodeFrame: %v", err)
+	}
+	errMsg := &protocol.ErrorMessage{}
+	if frame.Type != protocol.TypeError || errMsg.Decode(frame.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodeRateLimitExceeded {
+		t.Fatalf("expected CONFIRM_TOTP to be locked out too, got 0x%02X", frame.Type)
+	}
+
+	// The lockout wears off
+	srv.totpLimiter.now = func() time.Time { return time.Now().Add(totpLockoutWindow) }
+	if errMsg := disable(t, code); errMsg != nil {
+		t.Fatalf("expected to disable once the lockout is over, got %q", errMsg.Message)
+	}
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// Webhook event types (used in the events filter of [[webhooks]])
+const (
+	webhookEventMessageCreated = "message.created"
+	webhookEventMessageEdited  = "message.edited"
+	webhookEventMessageDeleted = "message.deleted"
+	webhookEventChannelCreated = "channel.created"
+	webhookEventUserRegistered = "user.registered"
+	webhookEventBanCreated     = "ban.created"
+)
+
+var webhookEvents = map[string]bool{
+	webhookEventMessageCreated: true,
+	webhookEventMessageEdited:  true,
+	webhookEventMessageDeleted: true,
+	webhookEventChannelCreated: true,
+	webhookEventUserRegistered: true,
+	webhookEventBanCreated:     true,
+}
+
+// Delivery tuning
+const (
+	webhookBatchSize        = 50
+	webhookMaxAttempts      = 8
+	webhookPollInterval     = 5 * time.Second
+	webhookRequestTimeout   = 10 * time.Second
+	webhookDefaultRetryBase = 10 * time.Second
+	webhookMaxRetryDelay    = time.Hour
+)
+
+// WebhookConfig configures one outgoing webhook
+type WebhookConfig struct {
+	URL      string
+	Secret   string   // HMAC-SHA256 key for the X-SuperChat-Signature header
+	Events   []string // Event types to send (empty = all)
+	Channels []string // Channel names to send channel events for (empty = all public channels)
+}
+
+// wantsEvent reports whether the webhook is subscribed to an event.
+// channel is empty for events that don't belong to a channel; the channel
+// filter doesn't apply to those.
+func (w *WebhookConfig) wantsEvent(event, channel string) bool {
+	if len(w.Events) > 0 && !containsFold(w.Events, event) {
+		return false
+	}
+	if channel != "" && len(w.Channels) > 0 {
+		return containsFold(w.Channels, channel)
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimPrefix(item, "#"), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWebhooks checks [[webhooks]] entries at startup
+func validateWebhooks(hooks []WebhookConfig) error {
+	for i, hook := range hooks {
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %d: url must be an http(s) URL, got %q", i+1, hook.URL)
+		}
+		for _, event := range hook.Events {
+			if !webhookEvents[event] {
+				return fmt.Errorf("webhook %d: unknown event %q", i+1, event)
+			}
+		}
+	}
+	return nil
+}
+
+// webhookPayload is the JSON body POSTed to webhooks
+type webhookPayload struct {
+	Event     string      `json:"event"`
+	Timestamp int64       `json:"timestamp"` // Unix milliseconds
+	Data      interface{} `json:"data"`
+}
+
+type webhookMessage struct {
+	ID           uint64  `json:"id"`
+	ChannelID    uint64  `json:"channel_id"`
+	Channel      string  `json:"channel"`
+	SubchannelID *uint64 `json:"subchannel_id,omitempty"`
+	ParentID     *uint64 `json:"parent_id,omitempty"`
+	Author       string  `json:"author"`
+	Content      string  `json:"content"`
+	CreatedAt    int64   `json:"created_at"`
+	EditedAt     *int64  `json:"edited_at,omitempty"`
+	DeletedAt    *int64  `json:"deleted_at,omitempty"`
+}
+
+type webhookChannel struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	DisplayName    string `json:"display_name"`
+	Description    string `json:"description,omitempty"`
+	Type           uint8  `json:"type"`
+	RetentionHours uint32 `json:"retention_hours"`
+}
+
+type webhookUser struct {
+	ID       int64  `json:"id"`
+	Nickname string `json:"nickname"`
+}
+
+type webhookBan struct {
+	ID              int64   `json:"id"`
+	Type            string  `json:"type"` // "user" or "ip"
+	UserID          *uint64 `json:"user_id,omitempty"`
+	Nickname        *string `json:"nickname,omitempty"`
+	IPCIDR          string  `json:"ip_cidr,omitempty"`
+	Reason          string  `json:"reason"`
+	Shadowban       bool    `json:"shadowban,omitempty"`
+	DurationSeconds *uint64 `json:"duration_seconds,omitempty"`
+	BannedBy        string  `json:"banned_by"`
+}
+
+// webhookDispatcher queues events for outgoing webhooks and delivers them
+// with retries. The queue lives in the database so pending events survive
+// restarts. A nil dispatcher (no webhooks configured) ignores all events.
+type webhookDispatcher struct {
+	db        database.Store
+	hooks     []WebhookConfig
+	client    *http.Client
+	wake      chan struct{}
+	retryBase time.Duration // Delay before the first retry, doubled on each attempt
+}
+
+// newWebhookDispatcher returns nil when no webhooks are configured
+func newWebhookDispatcher(db database.Store, hooks []WebhookConfig) *webhookDispatcher {
+	if len(hooks) == 0 {
+		return nil
+	}
+	return &webhookDispatcher{
+		db:        db,
+		hooks:     hooks,
+		client:    &http.Client{Timeout: webhookRequestTimeout},
+		wake:      make(chan struct{}, 1),
+		retryBase: webhookDefaultRetryBase,
+	}
+}
+
+// enqueue queues an event for every webhook subscribed to it
+func (d *webhookDispatcher) enqueue(event, channel string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	body, err := json.Marshal(webhookPayload{Event: event, Timestamp: now, Data: data})
+	if err != nil {
+		errorLog.Printf("Webhook: failed to encode %s event: %v", event, err)
+		return
+	}
+
+	queued := false
+	for i := range d.hooks {
+		if !d.hooks[i].wantsEvent(event, channel) {
+			continue
+		}
+		delivery := &database.WebhookDelivery{
+			WebhookURL:    d.hooks[i].URL,
+			Event:         event,
+			Payload:       string(body),
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		if err := d.db.EnqueueWebhookDelivery(delivery); err != nil {
+			errorLog.Printf("Webhook: failed to queue %s for %s: %v", event, d.hooks[i].URL, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// messageEvent queues a message event. Messages in DMs, private channels and
+// channels kept to registered or invited users never leave the server.
+func (d *webhookDispatcher) messageEvent(event string, msg *protocol.Message, deletedAt *int64) {
+	if d == nil {
+		return
+	}
+
+	channel, err := d.db.GetChannel(int64(msg.ChannelID))
+	if err != nil || channel.IsDM || channel.IsPrivate {
+		return
+	}
+	modes := protocol.ChannelModes(channel.Modes)
+	if modes.Has(protocol.ChannelModeRegisteredOnly) || modes.Has(protocol.ChannelModeInviteOnly) {
+		return
+	}
+
+	data := webhookMessage{
+		ID:           msg.ID,
+		ChannelID:    msg.ChannelID,
+		Channel:      channel.Name,
+		SubchannelID: msg.SubchannelID,
+		ParentID:     msg.ParentID,
+		Author:       msg.AuthorNickname,
+		Content:      msg.Content,
+		CreatedAt:    msg.CreatedAt.UnixMilli(),
+		DeletedAt:    deletedAt,
+	}
+	if msg.EditedAt != nil {
+		editedAt := msg.EditedAt.UnixMilli()
+		data.EditedAt = &editedAt
+	}
+	d.enqueue(event, channel.Name, data)
+}
+
+// authorMessageEvent queues a message.edited or message.deleted event, unless
+// the message's author is shadowbanned. Their posts never reach webhooks, so
+// edits and deletes of them mustn't either.
+func (s *Server) authorMessageEvent(event string, dbMsg *database.Message, deletedAt *int64) {
+	if s.webhooks == nil {
+		return
+	}
+	var nickname *string
+	if dbMsg.AuthorUserID == nil {
+		nickname = &dbMsg.AuthorNickname
+	}
+	if ban, err := s.db.GetActiveBanForUser(dbMsg.AuthorUserID, nickname); err == nil && ban != nil && ban.Shadowban {
+		return
+	}
+	s.webhooks.messageEvent(event, convertDBMessageToProtocol(dbMsg, s.db), deletedAt)
+}
+
+// channelCreated queues a channel.created event for a public channel
+func (d *webhookDispatcher) channelCreated(ch *database.Channel) {
+	if d == nil || ch.IsDM || ch.IsPrivate {
+		return
+	}
+	d.enqueue(webhookEventChannelCreated, ch.Name, webhookChannel{
+		ID:             ch.ID,
+		Name:           ch.Name,
+		DisplayName:    ch.DisplayName,
+		Description:    safeDeref(ch.Description, ""),
+		Type:           ch.ChannelType,
+		RetentionHours: ch.MessageRetentionHours,
+	})
+}
+
+// run delivers queued events until shutdown is closed
+func (d *webhookDispatcher) run(shutdown <-chan struct{}) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	// Deliver anything left over from before a restart
+	d.deliverDue()
+
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+			d.deliverDue()
+		case <-d.wake:
+			d.deliverDue()
+		}
+	}
+}
+
+// deliverDue sends every delivery whose next attempt is due
+func (d *webhookDispatcher) deliverDue() {
+	for {
+		deliveries, err := d.db.ListDueWebhookDeliveries(time.Now().UnixMilli(), webhookBatchSize)
+		if err != nil {
+			errorLog.Printf("Webhook: failed to load queue: %v", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			d.attempt(delivery)
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// attempt sends one delivery and removes or reschedules it
+func (d *webhookDispatcher) attempt(delivery *database.WebhookDelivery) {
+	hook := d.findHook(delivery.WebhookURL)
+	if hook == nil {
+		// Webhook was removed from the config since the event was queued
+		log.Printf("Webhook: dropping %s delivery %d for unconfigured webhook %s", delivery.Event, delivery.ID, delivery.WebhookURL)
+		d.remove(delivery)
+		return
+	}
+
+	err := d.post(hook, delivery)
+	if err == nil {
+		d.remove(delivery)
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	if attempts >= webhookMaxAttempts {
+		errorLog.Printf("Webhook: giving up on %s delivery %d to %s after %d attempts: %v", delivery.Event, delivery.ID, hook.URL, attempts, err)
+		d.remove(delivery)
+		return
+	}
+
+	next := time.Now().Add(d.retryDelay(attempts)).UnixMilli()
+	if err := d.db.RescheduleWebhookDelivery(delivery.ID, attempts, next, err.Error()); err != nil {
+		errorLog.Printf("Webhook: failed to reschedule delivery %d: %v", delivery.ID, err)
+	}
+}
+
+func (d *webhookDispatcher) remove(delivery *database.WebhookDelivery) {
+	if err := d.db.DeleteWebhookDelivery(delivery.ID); err != nil {
+		errorLog.Printf("Webhook: failed to remove delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// retryDelay returns the backoff before the given retry attempt
+func (d *webhookDispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.retryBase << (attempts - 1)
+	if delay > webhookMaxRetryDelay || delay < 0 {
+		return webhookMaxRetryDelay
+	}
+	return delay
+}
+
+func (d *webhookDispatcher) findHook(webhookURL string) *WebhookConfig {
+	for i := range d.hooks {
+		if d.hooks[i].URL == webhookURL {
+			return &d.hooks[i]
+		}
+	}
+	return nil
+}
+
+// post sends a delivery. Any 2xx response counts as delivered.
+func (d *webhookDispatcher) post(hook *WebhookConfig, delivery *database.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SuperChat-Webhook")
+	req.Header.Set("X-SuperChat-Event", delivery.Event)
+	req.Header.Set("X-SuperChat-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-SuperChat-Signature", signWebhookPayload(hook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhookPayload returns the X-SuperChat-Signature header value
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
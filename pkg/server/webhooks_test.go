@@ -0,0 +1,374 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// webhookReceiver records requests sent to an httptest server
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []receivedWebhook
+	status   []int // Response status per request (200 once exhausted)
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, status ...int) (*webhookReceiver, *httptest.Server) {
+	r := &webhookReceiver{status: status}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedWebhook{header: req.Header.Clone(), body: body})
+		code := http.StatusOK
+		if len(r.status) > 0 {
+			code = r.status[0]
+			r.status = r.status[1:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(ts.Close)
+	return r, ts
+}
+
+func (r *webhookReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+func decodeWebhookPayload(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var payload map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // Snowflake IDs don't fit in a float64
+	if err := dec.Decode(&payload); err != nil {
+		t.Fatalf("invalid webhook body %q: %v", body, err)
+	}
+	return payload
+}
+
+func TestWebhookDeliverySignsPayload(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	receiver, ts := newWebhookReceiver(t)
+	dispatcher := newWebhookDispatcher(srv.db, []WebhookConfig{{URL: ts.URL, Secret: "s3cret"}})
+
+	dispatcher.enqueue(webhookEventUserRegistered, "", webhookUser{ID: 7, Nickname: "alice"})
+	dispatcher.deliverDue()
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	req := requests[0]
+	if got := req.header.Get("X-SuperChat-Event"); got != webhookEventUserRegistered {
+		t.Errorf("X-SuperChat-Event = %q", got)
+	}
+	if got, want := req.header.Get("X-SuperChat-Signature"), signWebhookPayload("s3cret", req.body); got != want {
+		t.Errorf("X-SuperChat-Signature = %q, want %q", got, want)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	payload := decodeWebhookPayload(t, req.body)
+	data := payload["data"].(map[string]interface{})
+	if payload["event"] != webhookEventUserRegistered || data["nickname"] != "alice" || data["id"] != json.Number("7") {
+		t.Errorf("unexpected payload %s", req.body)
+	}
+
+	// Delivered events leave the queue
+	pending, err := srv.db.ListDueWebhookDeliveries(time.Now().UnixMilli(), 10)
+	if err != nil {
+		t.Fatalf("ListDueWebhookDeliveries: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("expected empty queue, got %d deliveries", len(pending))
+	}
+}
+
+func TestWebhookDeliveryRetries(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	receiver, ts := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusOK)
+	dispatcher := newWebhookDispatcher(srv.db, []WebhookConfig{{URL: ts.URL}})
+	dispatcher.retryBase = 0
+
+	dispatcher.enqueue(webhookEventUserRegistered, "", webhookUser{ID: 1, Nickname: "alice"})
+	dispatcher.deliverDue()
+
+	pending, err := srv.db.ListDueWebhookDeliveries(time.Now().UnixMilli(), 10)
+	if err != nil {
+		t.Fatalf("ListDueWebhookDeliveries: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == nil || *pending[0].LastError != "HTTP 500" {
+		t.Fatalf("after failed attempt queue = %+v", pending)
+	}
+
+	dispatcher.deliverDue()
+
+	requests := receiver.received()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if string(requests[0].body) != string(requests[1].body) {
+		t.Error("retry sent a different body")
+	}
+	pending, _ = srv.db.ListDueWebhookDeliveries(time.Now().UnixMilli(), 10)
+	if len(pending) != 0 {
+		t.Errorf("expected empty queue after retry, got %d deliveries", len(pending))
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	status := make([]int, webhookMaxAttempts)
+	for i := range status {
+		status[i] = http.StatusBadGateway
+	}
+	receiver, ts := newWebhookReceiver(t, status...)
+	dispatcher := newWebhookDispatcher(srv.db, []WebhookConfig{{URL: ts.URL}})
+	dispatcher.retryBase = 0
+
+	dispatcher.enqueue(webhookEventUserRegistered, "", webhookUser{ID: 1, Nickname: "alice"})
+	for i := 0; i < webhookMaxAttempts+2; i++ {
+		dispatcher.deliverDue()
+	}
+
+	if got := len(receiver.received()); got != webhookMaxAttempts {
+		t.Errorf("expected %d attempts, got %d", webhookMaxAttempts, got)
+	}
+	pending, _ := srv.db.ListDueWebhookDeliveries(time.Now().UnixMilli(), 10)
+	if len(pending) != 0 {
+		t.Errorf("expected delivery to be dropped, got %d deliveries", len(pending))
+	}
+}
+
+func TestWebhookFilters(t *testing.T) {
+	hook := WebhookConfig{
+		Events:   []string{webhookEventMessageCreated, webhookEventUserRegistered},
+		Channels: []string{"#general"},
+	}
+
+	tests := []struct {
+		event   string
+		channel string
+		want    bool
+	}{
+		{webhookEventMessageCreated, "general", true},
+		{webhookEventMessageCreated, "General", true},
+		{webhookEventMessageCreated, "random", false},
+		{webhookEventMessageEdited, "general", false},
+		{webhookEventUserRegistered, "", true},
+		{webhookEventBanCreated, "", false},
+	}
+	for _, tt := range tests {
+		if got := hook.wantsEvent(tt.event, tt.channel); got != tt.want {
+			t.Errorf("wantsEvent(%q, %q) = %v, want %v", tt.event, tt.channel, got, tt.want)
+		}
+	}
+
+	all := WebhookConfig{}
+	if !all.wantsEvent(webhookEventBanCreated, "") || !all.wantsEvent(webhookEventMessageDeleted, "anything") {
+		t.Error("webhook without filters should receive every event")
+	}
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	valid := []WebhookConfig{{URL: "https://hooks.example.com/x", Events: []string{webhookEventBanCreated}}}
+	if err := validateWebhooks(valid); err != nil {
+		t.Errorf("validateWebhooks(valid) = %v", err)
+	}
+
+	invalid := [][]WebhookConfig{
+		{{URL: ""}},
+		{{URL: "ftp://hooks.example.com"}},
+		{{URL: "https://hooks.example.com", Events: []string{"message.posted"}}},
+	}
+	for _, hooks := range invalid {
+		if err := validateWebhooks(hooks); err == nil {
+			t.Errorf("validateWebhooks(%+v) succeeded, want error", hooks)
+		}
+	}
+}
+
+func TestWebhookMessageEvents(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "#general")
+	randomID := createTestChannel(t, db, "random", "#random")
+	reloadMemDB(t, srv, db)
+
+	receiver, ts := newWebhookReceiver(t)
+	srv.webhooks = newWebhookDispatcher(srv.db, []WebhookConfig{{URL: ts.URL, Channels: []string{"general"}}})
+
+	sess := testSession(srv)
+	srv.sessions.UpdateNickname(sess.ID, "poster")
+
+	for _, id := range []int64{channelID, randomID} {
+		frame, err := encodePostMessageMessage(&protocol.PostMessageMessage{ChannelID: uint64(id), Content: "hello hooks"})
+		if err != nil {
+			t.Fatalf("Failed to encode message: %v", err)
+		}
+		if err := srv.handlePostMessage(sess, frame); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+	}
+	srv.webhooks.deliverDue()
+
+	// Only the #general message matches the channel filter
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	payload := decodeWebhookPayload(t, requests[0].body)
+	data := payload["data"].(map[string]interface{})
+	if payload["event"] != webhookEventMessageCreated || data["channel"] != "general" || data["content"] != "hello hooks" || data["author"] != "~poster" {
+		t.Fatalf("unexpected payload %s", requests[0].body)
+	}
+
+	msgID, err := strconv.ParseUint(string(data["id"].(json.Number)), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid message id %v", data["id"])
+	}
+	frame, err := encodeDeleteMessageMessage(&protocol.DeleteMessageMessage{MessageID: msgID})
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	if err := srv.handleDeleteMessage(sess, frame); err != nil {
+		t.Fatalf("handleDeleteMessage: %v", err)
+	}
+	srv.webhooks.deliverDue()
+
+	requests = receiver.received()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	payload = decodeWebhookPayload(t, requests[1].body)
+	data = payload["data"].(map[string]interface{})
+	if payload["event"] != webhookEventMessageDeleted || data["deleted_at"] == nil {
+		t.Errorf("unexpected payload %s", requests[1].body)
+	}
+}
+
+func TestWebhookSkipsShadowbannedMessages(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "#general")
+	reloadMemDB(t, srv, db)
+
+	receiver, ts := newWebhookReceiver(t)
+	srv.webhooks = newWebhookDispatcher(srv.db, []WebhookConfig{{URL: ts.URL}})
+
+	sess := testSession(srv)
+	srv.sessions.UpdateNickname(sess.ID, "troll")
+	sess.mu.Lock()
+	sess.Shadowbanned = true
+	sess.mu.Unlock()
+
+	frame, err := encodePostMessageMessage(&protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: "spam"})
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	if err := srv.handlePostMessage(sess, frame); err != nil {
+		t.Fatalf("handlePostMessage: %v", err)
+	}
+	srv.webhooks.deliverDue()
+
+	if got := len(receiver.received()); got != 0 {
+		t.Errorf("expected no webhook for shadowbanned message, got %d", got)
+	}
+}
+
+func TestWebhookSkipsShadowbannedEditsAndDeletes(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "#general")
+	reloadMemDB(t, srv, db)
+
+	receiver, ts := newWebhookReceiver(t)
+	srv.webhooks = newWebhookDispatcher(srv.db, []WebhookConfig{{URL: ts.URL}})
+	srv.config.AdminUsers = []string{"root"}
+
+	trollID, err := srv.db.CreateUser("troll", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	nickname := "troll"
+	if _, err := srv.db.CreateUserBan(&trollID, &nickname, "spam", true, nil, "root", "127.0.0.1"); err != nil {
+		t.Fatalf("CreateUserBan: %v", err)
+	}
+	troll, err := srv.sessions.CreateSession(&trollID, "troll", "tcp", newMockConn())
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	troll.mu.Lock()
+	troll.Shadowbanned = true
+	troll.mu.Unlock()
+
+	rootID, err := srv.db.CreateUser("root", "hash", uint8(protocol.UserFlagAdmin))
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	root, err := srv.sessions.CreateSession(&rootID, "root", "tcp", newMockConn())
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	post := func() uint64 {
+		t.Helper()
+		frame, err := encodePostMessageMessage(&protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: "spam"})
+		if err != nil {
+			t.Fatalf("Failed to encode message: %v", err)
+		}
+		if err := srv.handlePostMessage(troll, frame); err != nil {
+			t.Fatalf("handlePostMessage: %v", err)
+		}
+		messages, err := srv.db.ListRootMessages(channelID, nil, 1, nil, nil)
+		if err != nil || len(messages) == 0 {
+			t.Fatalf("ListRootMessages: %v", err)
+		}
+		return uint64(messages[0].ID)
+	}
+
+	// The author edits and deletes their own message
+	msgID := post()
+	if err := srv.handleEditMessage(troll, encodeAdminFrame(t, protocol.TypeEditMessage, &protocol.EditMessageMessage{MessageID: msgID, NewContent: "more spam"})); err != nil {
+		t.Fatalf("handleEditMessage: %v", err)
+	}
+	if err := srv.handleDeleteMessage(troll, encodeAdminFrame(t, protocol.TypeDeleteMessage, &protocol.DeleteMessageMessage{MessageID: msgID})); err != nil {
+		t.Fatalf("handleDeleteMessage: %v", err)
+	}
+
+	// An admin deletes it: the author's ban decides, not the session's
+	msgID = post()
+	if err := srv.handleDeleteMessage(root, encodeAdminFrame(t, protocol.TypeDeleteMessage, &protocol.DeleteMessageMessage{MessageID: msgID})); err != nil {
+		t.Fatalf("handleDeleteMessage: %v", err)
+	}
+	srv.webhooks.deliverDue()
+
+	if got := len(receiver.received()); got != 0 {
+		t.Errorf("expected no webhooks for a shadowbanned user's messages, got %d", got)
+	}
+}
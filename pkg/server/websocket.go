@@ -45,10 +45,18 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Wrap WebSocket as net.Conn
 	conn := NewWebSocketConn(ws)
 
+	// Enforce per-IP connection limit before allocating a session
+	ip := remoteIP(conn.RemoteAddr().String())
+	if !s.connectionLimiter.acquire(ip) {
+		s.rejectConnection(conn, ip)
+		return
+	}
+
 	// Create session (exactly like TCP handler does)
 	sess, err := s.sessions.CreateSession(nil, "", "websocket", conn)
 	if err != nil {
 		log.Printf("Failed to create WebSocket session: %v", err)
+		s.connectionLimiter.release(ip)
 		conn.Close()
 		return
 	}
@@ -61,7 +69,10 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	s.sendServerConfig(sess)
 
 	// Spawn goroutine for message loop (same as TCP handler does)
-	go s.messageLoop(sess, conn)
+	go func() {
+		defer s.connectionLimiter.release(ip)
+		s.messageLoop(sess, conn)
+	}()
 }
 
 // NewWebSocketConn creates a new WebSocket connection adapter
@@ -476,6 +476,8 @@ export interface NewMessage {
   created_at: bigint;
   edited_at: { present: number, value?: bigint };
   reply_count: number;
+  plus_one_count: number;
+  plus_one_anon_count: number;
 }
 
 export class NewMessageEncoder extends BitStreamEncoder {
@@ -519,6 +521,8 @@ export class NewMessageEncoder extends BitStreamEncoder {
       this.writeInt64(value.edited_at.value, "big_endian");
     }
     this.writeUint32(value.reply_count, "big_endian");
+    this.writeUint32(value.plus_one_count, "big_endian");
+    this.writeUint32(value.plus_one_anon_count, "big_endian");
     return this.finish();
   }
 }
@@ -569,6 +573,8 @@ export class NewMessageDecoder extends BitStreamDecoder {
       value.edited_at.value = this.readInt64("big_endian");
     }
     value.reply_count = this.readUint32("big_endian");
+    value.plus_one_count = this.readUint32("big_endian");
+    value.plus_one_anon_count = this.readUint32("big_endian");
     return value;
   }
 }
@@ -716,6 +722,8 @@ export interface Channel {
   retention_hours: number;
   has_subchannels: number;
   subchannel_count: number;
+  archived: number;
+  modes: number;
 }
 
 export class ChannelEncoder extends BitStreamEncoder {
@@ -746,6 +754,8 @@ export class ChannelEncoder extends BitStreamEncoder {
     this.writeUint32(value.retention_hours, "big_endian");
     this.writeUint8(value.has_subchannels);
     this.writeUint16(value.subchannel_count, "big_endian");
+    this.writeUint8(value.archived);
+    this.writeUint8(value.modes);
     return this.finish();
   }
 }
@@ -777,6 +787,8 @@ export class ChannelDecoder extends BitStreamDecoder {
     value.retention_hours = this.readUint32("big_endian");
     value.has_subchannels = this.readUint8();
     value.subchannel_count = this.readUint16("big_endian");
+    value.archived = this.readUint8();
+    value.modes = this.readUint8();
     return value;
   }
 }
@@ -819,6 +831,8 @@ export class ChannelListEncoder extends BitStreamEncoder {
       this.writeUint32(value_channels_item.retention_hours, "big_endian");
       this.writeUint8(value_channels_item.has_subchannels);
       this.writeUint16(value_channels_item.subchannel_count, "big_endian");
+      this.writeUint8(value_channels_item.archived);
+      this.writeUint8(value_channels_item.modes);
     }
     return this.finish();
   }
@@ -860,6 +874,8 @@ export class ChannelListDecoder extends BitStreamDecoder {
       channels_item.retention_hours = this.readUint32("big_endian");
       channels_item.has_subchannels = this.readUint8();
       channels_item.subchannel_count = this.readUint16("big_endian");
+      channels_item.archived = this.readUint8();
+      channels_item.modes = this.readUint8();
       value.channels.push(channels_item);
     }
     return value;
@@ -1067,6 +1083,8 @@ export interface Message {
   created_at: bigint;
   edited_at: { present: number, value?: bigint };
   reply_count: number;
+  plus_one_count: number;
+  plus_one_anon_count: number;
 }
 
 export class MessageEncoder extends BitStreamEncoder {
@@ -1110,6 +1128,8 @@ export class MessageEncoder extends BitStreamEncoder {
       this.writeInt64(value.edited_at.value, "big_endian");
     }
     this.writeUint32(value.reply_count, "big_endian");
+    this.writeUint32(value.plus_one_count, "big_endian");
+    this.writeUint32(value.plus_one_anon_count, "big_endian");
     return this.finish();
   }
 }
@@ -1160,6 +1180,8 @@ export class MessageDecoder extends BitStreamDecoder {
       value.edited_at.value = this.readInt64("big_endian");
     }
     value.reply_count = this.readUint32("big_endian");
+    value.plus_one_count = this.readUint32("big_endian");
+    value.plus_one_anon_count = this.readUint32("big_endian");
     return value;
   }
 }
@@ -1227,6 +1249,8 @@ export class MessageListEncoder extends BitStreamEncoder {
         this.writeInt64(value_messages_item.edited_at.value, "big_endian");
       }
       this.writeUint32(value_messages_item.reply_count, "big_endian");
+      this.writeUint32(value_messages_item.plus_one_count, "big_endian");
+      this.writeUint32(value_messages_item.plus_one_anon_count, "big_endian");
     }
     return this.finish();
   }
@@ -1298,6 +1322,8 @@ export class MessageListDecoder extends BitStreamDecoder {
         messages_item.edited_at.value = this.readInt64("big_endian");
       }
       messages_item.reply_count = this.readUint32("big_endian");
+      messages_item.plus_one_count = this.readUint32("big_endian");
+      messages_item.plus_one_anon_count = this.readUint32("big_endian");
       value.messages.push(messages_item);
     }
     return value;
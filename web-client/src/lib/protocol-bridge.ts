@@ -339,6 +339,8 @@ export class ProtocolBridge {
       is_operator: 0,
       has_subchannels: 0,
       subchannel_count: 0,
+      archived: 0,
+      modes: 0,
     })
   }
 